@@ -432,3 +432,31 @@ func TestMCPClientSessionPersistence(t *testing.T) {
 		assert.Equal(t, sessionID, session.ID(), "Session ID should remain constant")
 	}
 }
+
+// TestMCPClientResources tests listing and reading the files of the sandbox as resources
+func TestMCPClientResources(t *testing.T) {
+	_, session := setupMCPClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	templates, err := session.ListResourceTemplates(ctx, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, templates.ResourceTemplates)
+	assert.Equal(t, "file:///{+path}", templates.ResourceTemplates[0].URITemplate)
+
+	resources, err := session.ListResources(ctx, nil)
+	require.NoError(t, err)
+	assert.NotNil(t, resources.Resources)
+
+	// Directories are read as a JSON listing
+	dir, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "file:///etc"})
+	require.NoError(t, err)
+	require.Len(t, dir.Contents, 1)
+	assert.Equal(t, "application/json", dir.Contents[0].MIMEType)
+
+	var listing map[string]any
+	require.NoError(t, json.Unmarshal([]byte(dir.Contents[0].Text), &listing))
+
+	_, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "file:///nonexistent/file.txt"})
+	assert.Error(t, err)
+}
@@ -361,14 +361,108 @@ collectLoop:
 		}
 	}
 
-	// We expect at least 5 lines like "tick 1", ..., "tick 5"
+	// We expect the 5 lines "tick 1", ..., "tick 5" in stdout frames, which may hold
+	// several lines
 	count := 0
 	for _, line := range received {
-		if strings.HasPrefix(line, "stdout:") && strings.Contains(line, "tick") {
-			count++
+		var frame map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &frame))
+		if frame["stream"] == "stdout" {
+			count += strings.Count(frame["data"].(string), "tick")
 		}
 	}
 	assert.GreaterOrEqual(t, count, 5, "should receive at least 5 tick lines from stream")
+
+	// The legacy plain stream prefixes the output with its stream
+	plainResp, err := common.MakeRequest(http.MethodGet, "/process/"+processName+"/logs/stream?format=plain", nil)
+	require.NoError(t, err)
+	defer plainResp.Body.Close()
+	assert.Equal(t, http.StatusOK, plainResp.StatusCode)
+	assert.Equal(t, "text/plain; charset=utf-8", plainResp.Header.Get("Content-Type"))
+}
+
+// readLogFrames decodes the JSON frames of a log stream until stop returns true for one
+// of them or the stream ends
+func readLogFrames(t *testing.T, resp *http.Response, stop func(frame map[string]interface{}) bool) []map[string]interface{} {
+	t.Helper()
+	frames := []map[string]interface{}{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var frame map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &frame))
+		frames = append(frames, frame)
+		if stop(frame) {
+			break
+		}
+	}
+	return frames
+}
+
+// stdoutData concatenates the stdout output of log frames
+func stdoutData(frames []map[string]interface{}) string {
+	var data strings.Builder
+	for _, frame := range frames {
+		if frame["stream"] == "stdout" {
+			data.WriteString(frame["data"].(string))
+		}
+	}
+	return data.String()
+}
+
+func TestProcessStreamLogsResume(t *testing.T) {
+	processRequest := map[string]interface{}{
+		"command": "for i in $(seq 1 6); do echo tick $i; sleep 0.2; done",
+		"cwd":     "/",
+	}
+	resp, err := common.MakeRequest(http.MethodPost, "/process", processRequest)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var processResponse map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&processResponse))
+	processName := processResponse["name"].(string)
+	expected := "tick 1\ntick 2\ntick 3\ntick 4\ntick 5\ntick 6\n"
+	isDone := func(frame map[string]interface{}) bool { return frame["done"] == true }
+
+	// Disconnect after the first output, then resume from the seq of its frame
+	streamResp, err := common.MakeRequest(http.MethodGet, "/process/"+processName+"/logs/stream", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, streamResp.StatusCode)
+	first := readLogFrames(t, streamResp, func(frame map[string]interface{}) bool {
+		return frame["stream"] == "stdout" || frame["done"] == true
+	})
+	streamResp.Body.Close()
+	last := first[len(first)-1]
+	require.Equal(t, "stdout", last["stream"], "the process should write output before it ends")
+	seq := int64(last["seq"].(float64))
+
+	resumedResp, err := common.MakeRequest(http.MethodGet, fmt.Sprintf("/process/%s/logs/stream?fromOffset=%d", processName, seq), nil)
+	require.NoError(t, err)
+	defer resumedResp.Body.Close()
+	require.Equal(t, http.StatusOK, resumedResp.StatusCode)
+	resumed := readLogFrames(t, resumedResp, isDone)
+	require.NotEmpty(t, resumed)
+	assert.True(t, isDone(resumed[len(resumed)-1]), "the resumed stream should end with the process")
+	assert.Equal(t, expected, stdoutData(first)+stdoutData(resumed), "the resumed stream should neither repeat nor skip output")
+	assert.Equal(t, float64(len(expected)), resumed[len(resumed)-1]["seq"])
+
+	// The tail starts at the last lines
+	tailResp, err := common.MakeRequest(http.MethodGet, "/process/"+processName+"/logs/stream?tail=2", nil)
+	require.NoError(t, err)
+	defer tailResp.Body.Close()
+	require.Equal(t, http.StatusOK, tailResp.StatusCode)
+	assert.Equal(t, "tick 5\ntick 6\n", stdoutData(readLogFrames(t, tailResp, isDone)))
+
+	// Out of range and conflicting parameters are rejected
+	for _, query := range []string{"fromOffset=-1", "fromOffset=abc", fmt.Sprintf("fromOffset=%d", len(expected)+1), "fromOffset=0&tail=1", "tail=0", "stream=both"} {
+		badResp, err := common.MakeRequest(http.MethodGet, "/process/"+processName+"/logs/stream?"+query, nil)
+		require.NoError(t, err)
+		var errorResponse map[string]interface{}
+		require.NoError(t, json.NewDecoder(badResp.Body).Decode(&errorResponse))
+		badResp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, badResp.StatusCode, query)
+		assert.Equal(t, "INVALID_REQUEST", errorResponse["code"], query)
+	}
 }
 
 func TestProcessKillWithChildProcesses(t *testing.T) {
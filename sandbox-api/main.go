@@ -4,18 +4,37 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/blaxel-ai/sandbox-api/docs" // swagger generated docs
 	"github.com/blaxel-ai/sandbox-api/src/api"
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/audit"
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/handler/logsink"
+	"github.com/blaxel-ai/sandbox-api/src/handler/lsp"
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/activity"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tlsconfig"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
 	"github.com/blaxel-ai/sandbox-api/src/mcp"
+	"github.com/blaxel-ai/sandbox-api/src/rpc"
+	"github.com/blaxel-ai/sandbox-api/src/ws"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // @title           Sandbox API
@@ -28,14 +47,12 @@ import (
 // @name Authorization
 // @BasePath        /
 func main() {
-	logrus.SetFormatter(&logrus.TextFormatter{
-		DisableColors: true,
-	})
-	logrus.SetLevel(logrus.DebugLevel)
-
 	// Load .env file
 	_ = godotenv.Load()
 
+	// Write structured JSON logs
+	logging.Setup()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -63,6 +80,15 @@ func main() {
 	shortPort := flag.Int("p", 8080, "Port to listen on (shorthand)")
 	command := flag.String("command", "", "Command to execute")
 	shortCommand := flag.String("c", "", "Command to execute (shorthand)")
+	socketPath := flag.String("socket", os.Getenv("SOCKET_PATH"), "Unix socket to also serve the API on (SOCKET_PATH)")
+	socketOnly := flag.Bool("socket-only", os.Getenv("SOCKET_ONLY") == "true", "Only serve the API on the unix socket, not over TCP (SOCKET_ONLY)")
+	grpcPort := flag.Int("grpc-port", rpc.PortFromEnv(), "Port to serve the gRPC API on, 0 to disable it (GRPC_PORT)")
+	tlsConfig := tlsconfig.ConfigFromEnv()
+	flag.StringVar(&tlsConfig.CertFile, "tls-cert", tlsConfig.CertFile, "TLS certificate file (TLS_CERT_FILE)")
+	flag.StringVar(&tlsConfig.KeyFile, "tls-key", tlsConfig.KeyFile, "TLS key file (TLS_KEY_FILE)")
+	flag.BoolVar(&tlsConfig.SelfSigned, "tls-self-signed", tlsConfig.SelfSigned, "Serve TLS with a generated self-signed certificate (TLS_SELF_SIGNED)")
+	flag.StringVar(&tlsConfig.ClientCAFile, "tls-client-ca", tlsConfig.ClientCAFile, "CA bundle client certificates are verified against (TLS_CLIENT_CA_FILE)")
+	flag.StringVar(&tlsConfig.ClientAuth, "tls-client-auth", tlsConfig.ClientAuth, "Client certificates: none, optional or require, the default with a client CA (TLS_CLIENT_AUTH)")
 	flag.Parse()
 
 	// Use the port provided by either flag
@@ -84,58 +110,76 @@ func main() {
 		logrus.Infof("Shell args: %s", os.Getenv("SHELL_ARGS"))
 	}
 
-	// Check for command after the flags
-	if commandValue != "" {
-		// Join all remaining arguments as they may form the command
-		logrus.Infof("Executing command: %s", commandValue)
-
-		// Create the command with the context
-		// Use SHELL and SHELL_ARGS environment variables if set
-		shell := os.Getenv("SHELL")
-		if shell == "" {
-			shell = "sh"
-		}
+	// Mount the overlay before any process or handler sees the files under it
+	if err := filesystem.MountOverlayFromEnv(); err != nil {
+		logrus.Warnf("Overlay disabled: %v", err)
+	}
 
-		shellArgs := os.Getenv("SHELL_ARGS")
-		if shellArgs == "" {
-			shellArgs = "-c"
+	// Check the commands of processes against the policy shipped with the image
+	if path := policy.FileFromEnv(); path != "" {
+		if err := policy.GetEngine().LoadFile(path); err != nil {
+			logrus.Fatalf("Failed to load process policy from %s: %v", path, err)
 		}
+	}
 
-		// Build command arguments
-		cmdArgs := []string{}
-		if shellArgs != "" {
-			cmdArgs = append(cmdArgs, strings.Fields(shellArgs)...)
+	// Restore the process table saved by the previous run, and keep it saved so that
+	// running processes are adopted again after a crash
+	stateDir := process.StateDirFromEnv()
+	if err := process.GetProcessManager().LoadState(stateDir); err != nil {
+		logrus.Warnf("Failed to restore process table: %v", err)
+	}
+	if err := process.GetProcessManager().PersistTo(stateDir); err != nil {
+		logrus.Warnf("Failed to persist process table: %v", err)
+	}
+	process.GetProcessManager().StartRetention(process.RetentionPolicyFromEnv())
+	// Forward the output of every process to the log sinks of LOG_SINKS, or of the API
+	if err := logsink.GetForwarder().ConfigureFromEnv(); err != nil {
+		logrus.Warnf("Failed to configure log sinks: %v", err)
+	}
+	process.GetProcessManager().AddOutputListener(func(p *process.ProcessInfo, stream string, data []byte) {
+		logsink.GetForwarder().Write(p.PID, p.Name, stream, data)
+	})
+	// Publish sandbox.idle once nothing happened for IDLE_TIMEOUT: API calls, process
+	// output and clients connected to the ports of processes, except the API one
+	process.GetProcessManager().AddOutputListener(func(p *process.ProcessInfo, stream string, data []byte) {
+		activity.GetTracker().Touch(activity.SourceProcess)
+	})
+	activity.GetTracker().IgnorePort(portValue)
+	activity.GetTracker().Start()
+	// Run the startup command as the managed process named startup, its output and the
+	// output of the startup commands also written to the log of the API
+	process.GetProcessManager().AddOutputListener(func(p *process.ProcessInfo, stream string, data []byte) {
+		if p.Name == process.StartupProcessName || strings.HasPrefix(p.Name, process.StartupGroupName+"-") {
+			_, _ = logrus.StandardLogger().Out.Write(data)
 		}
-		cmdArgs = append(cmdArgs, commandValue)
-
-		cmd := exec.CommandContext(ctx, shell, cmdArgs...)
-		cmd.Stdout = logrus.StandardLogger().Out
-		cmd.Stderr = logrus.StandardLogger().Out
+	})
+	if commandValue != "" {
+		logrus.Infof("Executing command: %s", commandValue)
+	}
+	if _, err := process.GetProcessManager().InitStartupCommand(process.StartupCommand{Command: commandValue, WorkingDir: "/"}); err != nil {
+		logrus.Errorf("Failed to start command: %v", err)
+	}
+	// Run the startup commands of STARTUP_COMMANDS or STARTUP_FILE as the startup process
+	// group, in order and according to their dependencies
+	startupCommands, err := process.StartupCommandsFromEnv()
+	if err != nil {
+		logrus.Errorf("Failed to load startup commands: %v", err)
+	} else if _, err := process.GetProcessManager().InitStartupCommands(startupCommands); err != nil {
+		logrus.Errorf("Failed to start startup commands: %v", err)
+	}
+	// Load the process templates shipped with the image
+	process.GetTemplateRegistry()
 
-		cmd.Dir = "/"
+	// Export spans over OTLP when an endpoint is configured
+	shutdownTracing, err := tracing.Init(ctx)
+	if err != nil {
+		logrus.Warnf("Failed to set up tracing: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
 
-		// Start the command in a goroutine so it doesn't block the server
-		go func() {
-			// Start the command
-			if err := cmd.Start(); err != nil {
-				logrus.Fatalf("Failed to start command: %v", err)
-				return
-			}
-			logrus.Infof("Command started successfully")
-
-			// Wait for the command to complete
-			if err := cmd.Wait(); err != nil {
-				// Check if context was cancelled
-				select {
-				case <-ctx.Done():
-					logrus.Infof("Command was cancelled")
-				default:
-					logrus.Infof("Command exited with error: %v", err)
-				}
-			} else {
-				logrus.Infof("Command completed successfully")
-			}
-		}()
+	// Index the working directory in the background for code search
+	if handler.IndexEnabledFromEnv() {
+		handler.GetWorkspaceIndex().Start()
 	}
 
 	// Set up the router with all our API routes
@@ -148,11 +192,11 @@ func main() {
 	if err := mcpServer.Serve(); err != nil {
 		logrus.Fatalf("Failed to start MCP server: %v", err)
 	}
+	// Multiplexed operations over a single WebSocket connection
+	ws.NewServer(router)
 
 	// Start the server with custom timeout configuration for large file uploads
 	serverAddr := fmt.Sprintf(":%d", portValue)
-	logrus.Infof("Starting Sandbox API server on %s", serverAddr)
-
 	server := &http.Server{
 		Addr:              serverAddr,
 		Handler:           router,
@@ -162,8 +206,143 @@ func main() {
 		IdleTimeout:       2 * time.Minute,  // Keep-alive connections timeout
 		MaxHeaderBytes:    1 << 20,          // 1 MB max header size
 	}
+	// Terminate TLS in the server when no gateway does, optionally requiring client certificates
+	if tlsConfig.Enabled() || tlsConfig.ClientCAFile != "" {
+		server.TLSConfig, err = tlsConfig.ServerConfig()
+		if err != nil {
+			logrus.Fatalf("Failed to set up TLS: %v", err)
+		}
+		logrus.Infof("Serving TLS (client certificates: %s)", tlsConfig.ClientAuthMode())
+	}
+
+	// Shut down gracefully on SIGTERM and SIGINT
+	signalCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	if err := server.ListenAndServe(); err != nil {
+	// Serve over TCP and, for colocated clients, on a unix socket. The socket is only
+	// protected by its permissions (SOCKET_MODE), it is served without TLS.
+	if *socketOnly && *socketPath == "" {
+		logrus.Fatalf("A unix socket is required to serve the API only on it")
+	}
+	if *socketOnly && server.TLSConfig != nil {
+		logrus.Warnf("TLS is not used when serving the API only on a unix socket")
+	}
+	serverErr := make(chan error, 3)
+	if *socketPath != "" {
+		listener, err := lib.ListenUnix(*socketPath, lib.SocketModeFromEnv())
+		if err != nil {
+			logrus.Fatalf("Failed to listen on unix socket %s: %v", *socketPath, err)
+		}
+		logrus.Infof("Starting Sandbox API server on unix socket %s", *socketPath)
+		go func() {
+			serverErr <- server.Serve(listener)
+		}()
+	}
+	if !*socketOnly {
+		logrus.Infof("Starting Sandbox API server on %s", serverAddr)
+		go func() {
+			if server.TLSConfig != nil {
+				// The certificates are in the TLS configuration
+				serverErr <- server.ListenAndServeTLS("", "")
+				return
+			}
+			serverErr <- server.ListenAndServe()
+		}()
+	}
+
+	// Serve the gRPC API on its own port, with the TLS configuration of the REST API
+	var grpcServer *rpc.Server
+	if *grpcPort != 0 {
+		var options []grpc.ServerOption
+		if server.TLSConfig != nil {
+			options = append(options, grpc.Creds(credentials.NewTLS(server.TLSConfig)))
+		}
+		grpcServer = rpc.NewServer(options...)
+		grpcAddr := fmt.Sprintf(":%d", *grpcPort)
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			logrus.Fatalf("Failed to listen on %s for gRPC: %v", grpcAddr, err)
+		}
+		logrus.Infof("Starting gRPC server on %s", grpcAddr)
+		go func() {
+			serverErr <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
 		logrus.Fatalf("Failed to start server: %v", err)
+	case <-signalCtx.Done():
+	}
+
+	shutdown(server, grpcServer, stateDir, shutdownTracing)
+}
+
+// shutdown stops accepting requests and gRPC calls and waits for the in-flight ones,
+// optionally terminates the managed processes, then saves the process table and their
+// output and flushes the pending log lines and spans.
+// Waiting for requests and for processes are each bounded by SHUTDOWN_TIMEOUT.
+func shutdown(server *http.Server, grpcServer *rpc.Server, stateDir string, shutdownTracing func(context.Context) error) {
+	timeout := shutdownTimeoutFromEnv()
+	logrus.Infof("Shutting down (timeout: %s)", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if err := server.Shutdown(ctx); err != nil {
+		// Streams like log tails never finish by themselves
+		logrus.Warnf("Closing connections still open after %s: %v", timeout, err)
+		_ = server.Close()
+	}
+	if grpcServer != nil {
+		grpcServer.Stop(ctx)
+	}
+	cancel()
+
+	// Language servers only live as long as their client connection
+	lsp.GetManager().StopAll()
+	handler.GetWorkspaceIndex().Stop()
+
+	pm := process.GetProcessManager()
+	if os.Getenv("SHUTDOWN_TERMINATE_PROCESSES") == "true" {
+		logrus.Info("Terminating managed processes")
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		pm.TerminateAll(ctx)
+		cancel()
+	}
+
+	if err := pm.SaveState(stateDir); err != nil {
+		logrus.Errorf("Failed to save process table: %v", err)
+	} else {
+		logrus.Infof("Process table saved to %s", stateDir)
+	}
+
+	// Send the output still queued for the log sinks
+	logsink.GetForwarder().Close(timeout)
+
+	if err := audit.GetLogger().Close(); err != nil {
+		logrus.Warnf("Failed to close audit log: %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	if err := shutdownTracing(ctx); err != nil {
+		logrus.Warnf("Failed to flush spans: %v", err)
+	}
+	cancel()
+	logrus.Info("Shutdown complete")
+}
+
+// shutdownTimeoutFromEnv returns how long the shutdown may take, read in seconds from
+// SHUTDOWN_TIMEOUT
+func shutdownTimeoutFromEnv() time.Duration {
+	const defaultTimeout = 30 * time.Second
+
+	value := os.Getenv("SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return defaultTimeout
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		logrus.Warnf("Invalid SHUTDOWN_TIMEOUT value '%s', using default of %s", value, defaultTimeout)
+		return defaultTimeout
 	}
+	return time.Duration(seconds) * time.Second
 }
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,9 +15,21 @@ import (
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 
 	_ "github.com/blaxel-ai/sandbox-api/docs" // Import generated docs
 	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/audit"
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib/activity"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/cors"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
 )
 
 // SetupRouter configures all the routes for the Sandbox API
@@ -25,12 +41,27 @@ func SetupRouter(disableRequestLogging ...bool) *gin.Engine {
 	// Add recovery middleware
 	r.Use(gin.Recovery())
 
+	// Add middleware identifying requests in logs and responses
+	r.Use(requestIDMiddleware())
+
 	// Add middleware for CORS
-	r.Use(corsMiddleware())
+	r.Use(corsMiddleware(cors.GetPolicy()))
 
 	// Add middleware to prevent caching
 	r.Use(noCacheMiddleware())
 
+	// Add middleware recording request latencies
+	r.Use(metricsMiddleware())
+
+	// Add middleware tracing requests with OpenTelemetry
+	r.Use(tracingMiddleware())
+
+	// Add middleware recording every operation to the audit log
+	r.Use(auditMiddleware(audit.GetLogger()))
+
+	// Add middleware recording API calls as activity of the sandbox
+	r.Use(activityMiddleware(activity.GetTracker()))
+
 	// Add logrus middleware unless disabled
 	skipLogging := len(disableRequestLogging) > 0 && disableRequestLogging[0]
 	if !skipLogging {
@@ -49,6 +80,48 @@ func SetupRouter(disableRequestLogging ...bool) *gin.Engine {
 	processHandler := handler.NewProcessHandler()
 	networkHandler := handler.NewNetworkHandler()
 	codegenHandler := handler.NewCodegenHandler(fsHandler)
+	schedulerHandler := handler.NewSchedulerHandler()
+	gitHandler := handler.NewGitHandler(fsHandler)
+	codeHandler := handler.NewCodeHandler(fsHandler)
+	lspHandler := handler.NewLSPHandler(fsHandler)
+	indexHandler := handler.NewIndexHandler(fsHandler)
+	snapshotHandler := handler.NewSnapshotHandler(fsHandler)
+	artifactHandler := handler.NewArtifactHandler(fsHandler)
+	jobHandler := handler.NewJobHandler()
+	recordingHandler := handler.NewRecordingHandler()
+	kvHandler := handler.NewKVHandler()
+	containerHandler := handler.NewContainerHandler()
+	packageHandler := handler.NewPackageHandler()
+	scaffoldHandler := handler.NewScaffoldHandler(fsHandler)
+	proxyHandler := handler.NewProxyHandler()
+	auditHandler := handler.NewAuditHandler()
+	configHandler := handler.NewConfigHandler()
+	policyHandler := handler.NewPolicyHandler()
+	webhookHandler := handler.NewWebhookHandler(fsHandler, networkHandler)
+	activityHandler := handler.NewActivityHandler()
+	infoHandler := handler.NewInfoHandler(r)
+
+	// Metrics computed at scrape time
+	metrics.Processes.SetSource(processHandler.CountProcessesByStatus)
+	metrics.MultipartUploads.SetSource(func() map[string]float64 {
+		return map[string]float64{"": float64(fsHandler.CountMultipartUploads())}
+	})
+	metrics.WatchedDirectories.SetSource(func() map[string]float64 {
+		usage := filesystem.GetWatchUsage()
+		return map[string]float64{"used": float64(usage.Watched), "budget": float64(usage.Budget)}
+	})
+	metrics.StreamLimits.SetSource(func() map[string]float64 {
+		return map[string]float64{
+			"log_streams": float64(streamlimit.LogStreams().Max()),
+			"watchers":    float64(streamlimit.Watchers().Max()),
+		}
+	})
+	metrics.PolledDirectoryTrees.SetSource(func() map[string]float64 {
+		return map[string]float64{"": float64(filesystem.GetWatchUsage().Polled)}
+	})
+
+	// Reject filesystem writes once the disk quota is reached
+	r.Use(filesystemQuotaMiddleware(fsHandler))
 
 	// Custom filesystem tree router middleware to handle tree-specific routes
 	r.Use(func(c *gin.Context) {
@@ -102,38 +175,200 @@ func SetupRouter(disableRequestLogging ...bool) *gin.Engine {
 		c.Next()
 	})
 
-	// Multipart upload routes (separate endpoint to avoid wildcard conflicts)
+	// Filesystem sub-resource routes (/filesystem/{path}/<name>) can't be registered
+	// next to the /filesystem/*path catch-all, so they are dispatched by suffix
+	r.Use(filesystemSubresourceMiddleware(fsHandler.IsExistingPathRequest, []filesystemSubresource{
+		{http.MethodGet, "/archive", fsHandler.HandleGetArchive},
+		{http.MethodGet, "/search", fsHandler.HandleSearch},
+		{http.MethodPost, "/grep", fsHandler.HandleGrep},
+		{http.MethodPost, "/replace", fsHandler.HandleReplace},
+		{http.MethodPost, "/lock", fsHandler.HandleAcquireLock},
+		{http.MethodGet, "/lock", fsHandler.HandleGetLock},
+		{http.MethodDelete, "/lock", fsHandler.HandleReleaseLock},
+		{http.MethodGet, "/locks", fsHandler.HandleListLocks},
+		{http.MethodGet, "/stat", fsHandler.HandleGetStat},
+		{http.MethodPost, "/permissions", fsHandler.HandleSetPermissions},
+		{http.MethodGet, "/usage", fsHandler.HandleGetUsage},
+	}))
+
+	// Multipart upload and download routes (separate endpoint to avoid wildcard conflicts)
 	r.GET("/filesystem-multipart", fsHandler.HandleListMultipartUploads)
 	r.POST("/filesystem-multipart/initiate/*path", fsHandler.HandleInitiateMultipartUpload)
 	r.PUT("/filesystem-multipart/:uploadId/part", fsHandler.HandleUploadPart)
 	r.POST("/filesystem-multipart/:uploadId/complete", fsHandler.HandleCompleteMultipartUpload)
 	r.DELETE("/filesystem-multipart/:uploadId/abort", fsHandler.HandleAbortMultipartUpload)
+	r.GET("/filesystem-multipart/:uploadId", fsHandler.HandleGetMultipartUpload)
 	r.GET("/filesystem-multipart/:uploadId/parts", fsHandler.HandleListParts)
+	r.POST("/filesystem-multipart/download/initiate", fsHandler.HandleInitiateMultipartDownload)
+	r.GET("/filesystem-multipart/download/:downloadId/part", fsHandler.HandleDownloadPart)
+	r.DELETE("/filesystem-multipart/download/:downloadId", fsHandler.HandleAbortMultipartDownload)
 
 	// Filesystem routes
 	r.GET("/watch/filesystem/*path", fsHandler.HandleWatchDirectory)
+	r.POST("/filesystem/sync/*path", fsHandler.HandleSync)
+	r.POST("/filesystem/batch", fsHandler.HandleBatch)
+	r.POST("/filesystem/diff", fsHandler.HandleDiff)
+	r.POST("/filesystem/patch", fsHandler.HandleApplyPatch)
 	r.GET("/filesystem/*path", fsHandler.HandleGetFile)
 	r.PUT("/filesystem/*path", fsHandler.HandleCreateOrUpdateFile)
+	r.PATCH("/filesystem/*path", fsHandler.HandlePatchFile)
 	r.DELETE("/filesystem/*path", fsHandler.HandleDeleteFile)
 
+	// Snapshot routes
+	r.GET("/snapshots", snapshotHandler.HandleListSnapshots)
+	r.POST("/snapshots", snapshotHandler.HandleCreateSnapshot)
+	r.GET("/snapshots/:id", snapshotHandler.HandleGetSnapshot)
+	r.DELETE("/snapshots/:id", snapshotHandler.HandleDeleteSnapshot)
+	r.POST("/snapshots/:id/restore", snapshotHandler.HandleRestoreSnapshot)
+
+	// Artifact routes
+	r.GET("/artifacts/export", artifactHandler.HandleListArtifactExports)
+	r.POST("/artifacts/export", artifactHandler.HandleExportArtifact)
+	r.GET("/artifacts/export/:id", artifactHandler.HandleGetArtifactExport)
+
+	// Job routes
+	r.GET("/jobs", jobHandler.HandleListJobs)
+	r.GET("/jobs/:id", jobHandler.HandleGetJob)
+	r.POST("/jobs/:id/cancel", jobHandler.HandleCancelJob)
+	r.GET("/jobs/:id/output", jobHandler.HandleGetJobOutput)
+
+	// Webhook routes
+	r.GET("/webhooks", webhookHandler.HandleListWebhooks)
+	r.POST("/webhooks", webhookHandler.HandleCreateWebhook)
+	r.GET("/webhooks/:id", webhookHandler.HandleGetWebhook)
+	r.DELETE("/webhooks/:id", webhookHandler.HandleDeleteWebhook)
+
+	// Activity routes
+	r.GET("/activity", activityHandler.HandleGetActivity)
+	r.PUT("/activity/idle-timeout", activityHandler.HandleSetIdleTimeout)
+
+	// Config routes
+	r.GET("/config/workdir", configHandler.HandleGetWorkingDir)
+	r.POST("/config/workdir", configHandler.HandleSetWorkingDir)
+	r.GET("/config/log-sinks", configHandler.HandleGetLogSinks)
+	r.PUT("/config/log-sinks", configHandler.HandleSetLogSinks)
+	r.GET("/config/startup-command", configHandler.HandleGetStartupCommand)
+	r.PUT("/config/startup-command", configHandler.HandleSetStartupCommand)
+	r.GET("/config/startup-commands", configHandler.HandleGetStartupCommands)
+	r.PUT("/config/startup-commands", configHandler.HandleSetStartupCommands)
+	r.GET("/config/cors", configHandler.HandleGetCORS)
+	r.PUT("/config/cors", configHandler.HandleSetCORS)
+
+	// Process policy routes
+	r.GET("/policy", policyHandler.HandleGetPolicy)
+	r.PUT("/policy", policyHandler.HandleSetPolicy)
+	r.POST("/policy/check", policyHandler.HandleCheckPolicy)
+	r.GET("/policy/rejections", policyHandler.HandleListPolicyRejections)
+
 	// Process routes
 	r.GET("/process", processHandler.HandleListProcesses)
 	r.POST("/process", processHandler.HandleExecuteCommand)
+	r.DELETE("/process", processHandler.HandleRemoveProcesses)
+	r.POST("/process/bulk", processHandler.HandleBulkProcesses)
+	r.GET("/process/system", processHandler.HandleListSystemProcesses)
+	r.POST("/process/system/:pid/adopt", processHandler.HandleAdoptProcess)
 	r.GET("/process/:identifier/logs", processHandler.HandleGetProcessLogs)
 	r.GET("/process/:identifier/logs/stream", processHandler.HandleGetProcessLogsStream)
+	r.GET("/process/:identifier/logs/download", processHandler.HandleDownloadProcessLogs)
+	r.GET("/process/:identifier/wait", processHandler.HandleWaitProcess)
+	r.GET("/process/:identifier/env", processHandler.HandleGetProcessEnv)
 	r.DELETE("/process/:identifier", processHandler.HandleStopProcess)
 	r.DELETE("/process/:identifier/kill", processHandler.HandleKillProcess)
+	r.POST("/process/:identifier/signal", processHandler.HandleSignalProcess)
+	r.DELETE("/process/:identifier/record", processHandler.HandleRemoveProcessRecord)
 	r.GET("/process/:identifier", processHandler.HandleGetProcess)
+	r.POST("/process/from-template/:name", processHandler.HandleStartProcessFromTemplate)
+
+	// Process template routes
+	r.GET("/process-templates", processHandler.HandleListProcessTemplates)
+	r.POST("/process-templates", processHandler.HandleRegisterProcessTemplate)
+	r.GET("/process-templates/:name", processHandler.HandleGetProcessTemplate)
+	r.DELETE("/process-templates/:name", processHandler.HandleDeleteProcessTemplate)
+
+	// Process group routes
+	r.GET("/process-group", processHandler.HandleListProcessGroups)
+	r.POST("/process-group", processHandler.HandleStartProcessGroup)
+	r.GET("/process-group/:name", processHandler.HandleGetProcessGroup)
+	r.DELETE("/process-group/:name", processHandler.HandleStopProcessGroup)
+	r.DELETE("/process-group/:name/kill", processHandler.HandleKillProcessGroup)
+
+	// Recording routes
+	r.GET("/recordings", recordingHandler.HandleListRecordings)
+	r.GET("/recordings/:id", recordingHandler.HandleDownloadRecording)
+
+	// Key/value routes
+	r.GET("/kv", kvHandler.HandleListKV)
+	r.GET("/kv/*key", kvHandler.HandleGetKV)
+	r.PUT("/kv/*key", kvHandler.HandleSetKV)
+	r.DELETE("/kv/*key", kvHandler.HandleDeleteKV)
+
+	// Container routes
+	r.GET("/containers", containerHandler.HandleListContainers)
+	r.POST("/containers", containerHandler.HandleRunContainer)
+	r.GET("/containers/:id", containerHandler.HandleGetContainer)
+	r.DELETE("/containers/:id", containerHandler.HandleStopContainer)
+	r.GET("/containers/:id/logs", containerHandler.HandleGetContainerLogs)
+	r.GET("/containers/:id/logs/stream", containerHandler.HandleGetContainerLogsStream)
+
+	// Package routes
+	r.POST("/packages/install", packageHandler.HandleInstallPackages)
+
+	// Scaffold routes
+	r.GET("/scaffold/templates", scaffoldHandler.HandleListScaffoldTemplates)
+	r.POST("/scaffold", scaffoldHandler.HandleScaffold)
+
+	// Schedule routes
+	r.GET("/schedules", schedulerHandler.HandleListSchedules)
+	r.POST("/schedules", schedulerHandler.HandleCreateSchedule)
+	r.GET("/schedules/:name", schedulerHandler.HandleGetSchedule)
+	r.DELETE("/schedules/:name", schedulerHandler.HandleDeleteSchedule)
 
 	// Network routes
 	r.GET("/network/process/:pid/ports", networkHandler.HandleGetPorts)
 	r.POST("/network/process/:pid/monitor", networkHandler.HandleMonitorPorts)
 	r.DELETE("/network/process/:pid/monitor", networkHandler.HandleStopMonitoringPorts)
+	r.POST("/http-request", networkHandler.HandleHTTPRequest)
 
 	// Codegen routes
 	r.PUT("/codegen/fastapply/*path", codegenHandler.HandleFastApply)
+	r.POST("/codegen/apply/:diffId", codegenHandler.HandleApplyDiff)
+	r.POST("/codegen/apply-batch", codegenHandler.HandleApplyBatch)
 	r.GET("/codegen/reranking/*path", codegenHandler.HandleReranking)
 
+	// Workspace index routes
+	r.GET("/index/status", indexHandler.HandleIndexStatus)
+	r.POST("/index/rebuild", indexHandler.HandleIndexRebuild)
+	r.GET("/index/search", indexHandler.HandleIndexSearch)
+
+	// Code check routes
+	r.POST("/code/check", codeHandler.HandleCheck)
+
+	// Language server routes
+	r.GET("/lsp", lspHandler.HandleListLanguageServers)
+	r.GET("/lsp/:language", lspHandler.HandleLanguageServer)
+
+	// Git routes
+	r.POST("/git/clone", gitHandler.HandleClone)
+	r.GET("/git/status/*path", gitHandler.HandleStatus)
+	r.GET("/git/diff/*path", gitHandler.HandleDiff)
+	r.POST("/git/checkout/*path", gitHandler.HandleCheckout)
+	r.POST("/git/add/*path", gitHandler.HandleAdd)
+	r.POST("/git/commit/*path", gitHandler.HandleCommit)
+	r.POST("/git/push/*path", gitHandler.HandlePush)
+	r.POST("/git/pull/*path", gitHandler.HandlePull)
+
+	// Proxy routes to services listening inside the sandbox
+	r.Any("/proxy/:port/*path", proxyHandler.HandleProxy)
+
+	// Audit routes
+	r.GET("/audit", auditHandler.HandleGetAudit)
+
+	// Metrics route (Prometheus text format)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Sandbox info route, listing the features available and the routes
+	r.GET("/info", infoHandler.HandleGetInfo)
+
 	// Health check route
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -150,12 +385,133 @@ func SetupRouter(disableRequestLogging ...bool) *gin.Engine {
 	return r
 }
 
-// corsMiddleware adds CORS headers to all responses
-func corsMiddleware() gin.HandlerFunc {
+// filesystemSubresource is a handler of "<method> /filesystem/{path}<suffix>" requests
+type filesystemSubresource struct {
+	method  string
+	suffix  string
+	handler gin.HandlerFunc
+}
+
+// filesystemSubresourceMiddleware dispatches "/filesystem/{path}/<name>" requests to the
+// first of routes matching their method and suffix. The "path" param is rewritten to the
+// target path so handlers can use the usual path extraction. Requests for an existing
+// file or directory named like a sub-resource, such as /usr/bin/stat or /var/lock, are
+// left to the catch-all routes, exists being called with the "path" param set to the
+// full path.
+func filesystemSubresourceMiddleware(exists func(c *gin.Context) bool, routes []filesystemSubresource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, "/filesystem/") {
+			c.Next()
+			return
+		}
+
+		for _, route := range routes {
+			if c.Request.Method != route.method || !strings.HasSuffix(path, route.suffix) {
+				continue
+			}
+
+			fullPath := strings.TrimPrefix(path, "/filesystem")
+			originalPath, hadPath := c.Params.Get("path")
+			setPathParam(c, fullPath)
+			if exists(c) {
+				if hadPath {
+					setPathParam(c, originalPath)
+				}
+				break
+			}
+
+			targetPath := strings.TrimSuffix(fullPath, route.suffix)
+			if targetPath == "" {
+				targetPath = "/"
+			}
+			setPathParam(c, targetPath)
+
+			route.handler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// setPathParam sets the "path" param of the request, adding it when the request did not
+// match a route with one
+func setPathParam(c *gin.Context, value string) {
+	for i := range c.Params {
+		if c.Params[i].Key == "path" {
+			c.Params[i].Value = value
+			return
+		}
+	}
+	c.Params = append(c.Params, gin.Param{Key: "path", Value: value})
+}
+
+// filesystemQuotaMiddleware rejects requests writing files with 507 Insufficient Storage
+// when the disk usage plus the request body would exceed the configured quota
+func filesystemQuotaMiddleware(fsHandler *handler.FileSystemHandler) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		path := c.Request.URL.Path
+		method := c.Request.Method
+
+		writes := false
+		switch {
+		case strings.HasPrefix(path, "/filesystem-multipart/download/"):
+			// Downloads write nothing
+		case strings.HasPrefix(path, "/filesystem-multipart/"):
+			writes = method == http.MethodPut || method == http.MethodPost
+		case strings.HasPrefix(path, "/filesystem/sync/"), path == "/filesystem/batch", path == "/filesystem/patch":
+			writes = method == http.MethodPost
+		case strings.HasPrefix(path, "/filesystem/"):
+			writes = method == http.MethodPut || method == http.MethodPatch ||
+				(method == http.MethodPost && strings.HasSuffix(path, "/replace"))
+		}
+
+		if writes {
+			if err := fsHandler.CheckQuota(c.Request.ContentLength); err != nil {
+				status := apierror.HTTPStatus(err, http.StatusUnprocessableEntity)
+				c.AbortWithStatusJSON(status, handler.NewErrorResponse(status, err))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Request headers browsers may send and response headers they may read cross-origin
+var (
+	corsAllowedHeaders = strings.Join([]string{
+		"Content-Type", "Authorization", "If-Match", logging.RequestIDHeader, handler.RunAsHeader, handler.LockHolderHeader,
+		"X-Git-Username", "X-Git-Token", "traceparent", "tracestate",
+	}, ", ")
+	corsExposedHeaders = strings.Join([]string{
+		logging.RequestIDHeader, "ETag", "Content-Disposition", "X-Part-Number", "X-Part-Offset",
+	}, ", ")
+)
+
+// corsMaxAge is how long browsers may cache the response of a preflight request, in seconds
+const corsMaxAge = "600"
+
+// corsMiddleware adds CORS headers to the responses of requests from the allowed
+// origins, see cors.Policy. Browsers of other origins get none and cannot read them.
+func corsMiddleware(policy *cors.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if origin != "" && policy.Allowed(origin) {
+			if policy.AllowsAny() {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				// Only a single origin can be allowed, credentials with it
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -166,6 +522,24 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// requestIDMiddleware identifies every request with the ID sent by the client in the
+// X-Request-ID header, or a new one, which is returned in the response and added to
+// the log entries of the request
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(logging.RequestIDHeader)
+		if !logging.ValidRequestID(requestID) {
+			requestID = logging.NewID()
+		}
+		// Keep it in the headers for handlers reading them, like the MCP server
+		c.Request.Header.Set(logging.RequestIDHeader, requestID)
+		c.Writer.Header().Set(logging.RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
+
 // noCacheMiddleware adds no-cache headers to all responses to prevent caching issues
 func noCacheMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -178,6 +552,202 @@ func noCacheMiddleware() gin.HandlerFunc {
 	}
 }
 
+// metricsMiddleware records the latency of every request per route
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.RequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// tracingSkippedPaths are not traced: health checks, scrapes and documentation
+var tracingSkippedPaths = []string{"/health", "/metrics", "/swagger"}
+
+// tracingMiddleware starts a span for each request, continuing the trace propagated by
+// the caller. The file path and process of the request are recorded on the span.
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, skipped := range tracingSkippedPaths {
+			if path == skipped || strings.HasPrefix(path, skipped+"/") {
+				c.Next()
+				return
+			}
+		}
+
+		ctx, span := tracing.StartServer(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header), c.Request.Method,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("url.path", path),
+			attribute.String("client.address", c.ClientIP()),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		span.SetName(c.Request.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if filePath := c.Param("path"); filePath != "" {
+			span.SetAttributes(tracing.AttrFilePath.String(filePath))
+		}
+		if identifier := c.Param("identifier"); identifier != "" {
+			span.SetAttributes(tracing.AttrProcessIdentifier.String(identifier))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// activitySkippedPaths are not activity: health checks, scrapes, documentation and the
+// activity itself, which are polled from outside the sandbox
+var activitySkippedPaths = []string{"/health", "/metrics", "/swagger", "/activity"}
+
+// activityMiddleware records every request as an activity, when it starts and when it
+// ends for long-running ones like streams and proxied WebSockets
+func activityMiddleware(tracker *activity.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, skipped := range activitySkippedPaths {
+			if path == skipped || strings.HasPrefix(path, skipped+"/") {
+				c.Next()
+				return
+			}
+		}
+
+		tracker.Touch(activity.SourceAPI)
+		c.Next()
+		tracker.Touch(activity.SourceAPI)
+	}
+}
+
+// maxAuditBodySize is the size above which request bodies are not recorded in the audit log
+const maxAuditBodySize = 64 * 1024
+
+// auditSkippedPaths are not recorded in the audit log: health checks, scrapes and
+// documentation, and MCP requests whose tool calls are recorded by the MCP server
+var auditSkippedPaths = []string{"/health", "/metrics", "/swagger", "/mcp"}
+
+// auditMiddleware records every request in the audit log, with its query parameters
+// and JSON body as arguments
+func auditMiddleware(logger *audit.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, skipped := range auditSkippedPaths {
+			if path == skipped || strings.HasPrefix(path, skipped+"/") {
+				c.Next()
+				return
+			}
+		}
+
+		arguments := map[string]interface{}{}
+		if query := c.Request.URL.Query(); len(query) > 0 {
+			values := make(map[string]interface{}, len(query))
+			for key, value := range query {
+				values[key] = strings.Join(value, ",")
+			}
+			arguments["query"] = values
+		}
+		if c.Request.ContentLength > 0 {
+			if strings.HasPrefix(c.ContentType(), "application/json") && c.Request.ContentLength <= maxAuditBodySize {
+				body, err := io.ReadAll(c.Request.Body)
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+				if err == nil && json.Valid(body) {
+					var value interface{}
+					_ = json.Unmarshal(body, &value)
+					arguments["body"] = value
+				}
+			} else {
+				arguments["bodySize"] = c.Request.ContentLength
+			}
+		}
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		entry := audit.Entry{
+			Timestamp:  start.UTC(),
+			Source:     audit.SourceHTTP,
+			Operation:  c.Request.Method + " " + route,
+			Target:     path,
+			Caller:     audit.NewCaller(c.ClientIP(), c.Request.Header),
+			Status:     status,
+			Success:    status < http.StatusBadRequest,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if len(arguments) > 0 {
+			entry.Arguments = audit.SanitizeValue(arguments).(map[string]interface{})
+		}
+		if !entry.Success {
+			var errorResponse handler.ErrorResponse
+			if json.Unmarshal(writer.errorBody.Bytes(), &errorResponse) == nil {
+				entry.Error = errorResponse.Error
+			}
+		}
+		logger.Record(entry)
+	}
+}
+
+// auditResponseWriter keeps the beginning of error responses to record their message
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	errorBody bytes.Buffer
+}
+
+// maxAuditErrorSize is the size of the beginning of error responses kept
+const maxAuditErrorSize = 4096
+
+// Write writes the response and keeps the beginning of error responses
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	w.keepError(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes the response and keeps the beginning of error responses
+func (w *auditResponseWriter) WriteString(data string) (int, error) {
+	w.keepError([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Unwrap returns the wrapped writer, to reach the features of the writer of net/http
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// keepError keeps data if the response is an error and the limit is not reached
+func (w *auditResponseWriter) keepError(data []byte) {
+	if w.Status() < http.StatusBadRequest {
+		return
+	}
+	if remaining := maxAuditErrorSize - w.errorBody.Len(); remaining > 0 {
+		w.errorBody.Write(data[:min(len(data), remaining)])
+	}
+}
+
 func logrusMiddleware() gin.HandlerFunc {
 	var skip map[string]struct{}
 
@@ -202,16 +772,23 @@ func logrusMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		entry := logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":    c.Request.Method,
+			"path":      path,
+			"status":    statusCode,
+			"size":      dataLength,
+			"latencyMs": latency,
+		})
 		if len(c.Errors) > 0 {
-			logrus.Error(c.Errors.ByType(gin.ErrorTypePrivate).String())
+			entry.Error(c.Errors.ByType(gin.ErrorTypePrivate).String())
 		} else {
 			msg := fmt.Sprintf("%s %s %d %d %dms", c.Request.Method, path, statusCode, dataLength, latency)
 			if statusCode >= http.StatusInternalServerError {
-				logrus.Error(msg)
+				entry.Error(msg)
 			} else if statusCode >= http.StatusBadRequest {
-				logrus.Error(msg)
+				entry.Error(msg)
 			} else {
-				logrus.Info(msg)
+				entry.Info(msg)
 			}
 		}
 	}
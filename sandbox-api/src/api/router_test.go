@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFilesystemSubresourceExistingDirectory tests that an existing directory named
+// like a sub-resource is served by the filesystem routes rather than the sub-resource
+func TestFilesystemSubresourceExistingDirectory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	router := SetupRouter(true)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "lock"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lock", "inside.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, encodeFilesystemPath(filepath.Join(dir, "lock")), nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "inside.txt") {
+		t.Errorf("Expected the listing of the lock directory, got %s", w.Body.String())
+	}
+
+	// A sub-resource of a path which does not exist as a file or directory still applies
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, encodeFilesystemPath(filepath.Join(dir, "stat")), nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stat struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stat); err != nil || stat.Path != dir {
+		t.Errorf("Expected the stat of %s, got %s", dir, w.Body.String())
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/activity"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// ActivityHandler handles the activity of the sandbox
+type ActivityHandler struct {
+	*BaseHandler
+	tracker *activity.Tracker
+}
+
+// NewActivityHandler creates a new activity handler
+func NewActivityHandler() *ActivityHandler {
+	return &ActivityHandler{
+		BaseHandler: NewBaseHandler(),
+		tracker:     activity.GetTracker(),
+	}
+}
+
+// IdleTimeoutRequest is the request body for changing the idle timeout
+type IdleTimeoutRequest struct {
+	// IdleTimeout is the time without activity after which the sandbox is idle, in seconds, 0 to disable the idle events
+	IdleTimeout *int `json:"idleTimeout" example:"600" binding:"required"`
+} // @name IdleTimeoutRequest
+
+// HandleGetActivity handles GET requests to /activity
+// @Summary Get the activity of the sandbox
+// @Description Get the time of the last activity of the sandbox and whether it is idle. API calls, WebSocket operations, output of the managed processes and clients outside the sandbox connected to the ports processes listen on are activity; requests to /activity, /health and /metrics are not, so that polling does not keep the sandbox awake. Register a webhook for the sandbox.idle event to be called once the idle timeout (IDLE_TIMEOUT, in seconds, 10 minutes by default) elapsed without activity, and for sandbox.active on the next activity.
+// @Tags activity
+// @Produce json
+// @Success 200 {object} activity.Status "Activity"
+// @Router /activity [get]
+func (h *ActivityHandler) HandleGetActivity(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.tracker.Status())
+}
+
+// HandleSetIdleTimeout handles PUT requests to /activity/idle-timeout
+// @Summary Set the idle timeout
+// @Description Change the time without activity after which the sandbox.idle event is published. An idle sandbox is idle again once the new timeout elapsed since its last activity.
+// @Tags activity
+// @Accept json
+// @Produce json
+// @Param request body IdleTimeoutRequest true "Idle timeout"
+// @Success 200 {object} activity.Status "Activity"
+// @Failure 400 {object} ErrorResponse "Invalid idle timeout"
+// @Router /activity/idle-timeout [put]
+func (h *ActivityHandler) HandleSetIdleTimeout(c *gin.Context) {
+	var req IdleTimeoutRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if *req.IdleTimeout < 0 {
+		h.SendError(c, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "idleTimeout must not be negative"))
+		return
+	}
+	h.tracker.SetIdleTimeout(time.Duration(*req.IdleTimeout) * time.Second)
+	h.SendJSON(c, http.StatusOK, h.tracker.Status())
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/artifact"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// ArtifactHandler handles the exports of files and directories to object storage
+type ArtifactHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+	exports    *artifact.Manager
+}
+
+// NewArtifactHandler creates a new artifact handler resolving paths like the filesystem handler
+func NewArtifactHandler(fsHandler *FileSystemHandler) *ArtifactHandler {
+	return &ArtifactHandler{
+		BaseHandler: NewBaseHandler(),
+		FileSystem:  fsHandler,
+		exports:     artifact.GetManager(),
+	}
+}
+
+// ExportArtifactRequest is the request body for exporting a file or directory
+type ExportArtifactRequest struct {
+	Path string `json:"path" example:"/home/user/app/dist" binding:"required"`
+	// Destination is an s3:// or gs:// location, the file or archive being uploaded under
+	// its name when it ends with a slash
+	Destination string `json:"destination" example:"s3://my-bucket/builds/dist.tar.gz" binding:"required"`
+	// CredentialsRef is the prefix of the environment variables holding the endpoint and
+	// credentials of the bucket: <ref>_ACCESS_KEY_ID, <ref>_SECRET_ACCESS_KEY,
+	// <ref>_ENDPOINT and <ref>_REGION. Defaults to ARTIFACTS.
+	CredentialsRef string `json:"credentialsRef" example:"ARTIFACTS"`
+	// Format is the format of the archive directories are uploaded as
+	Format string `json:"format" example:"tar.gz" enums:"tar.gz,zip"`
+} // @name ExportArtifactRequest
+
+// HandleExportArtifact handles POST requests to /artifacts/export
+// @Summary Export a file or directory to object storage
+// @Description Upload a file, or an archive of a directory, from the sandbox straight to an S3 or GCS bucket, so that large build outputs don't go through the client. The export runs as a background job: poll GET /artifacts/export/{id} for its progress, and cancel it with POST /jobs/{id}/cancel. Credentials are never sent in the request, credentialsRef names the environment variables holding them, falling back to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+// @Tags artifacts
+// @Accept json
+// @Produce json
+// @Param request body ExportArtifactRequest true "Export request"
+// @Success 202 {object} artifact.Export "Export started"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 404 {object} ErrorResponse "Path not found"
+// @Router /artifacts/export [post]
+func (h *ArtifactHandler) HandleExportArtifact(c *gin.Context) {
+	var req ExportArtifactRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	absPath, err := h.FileSystem.fs.GetAbsolutePath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	export, err := h.exports.Start(h.FileSystem.fs, artifact.Request{
+		Path:           absPath,
+		Destination:    req.Destination,
+		CredentialsRef: req.CredentialsRef,
+		Format:         req.Format,
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusAccepted, export)
+}
+
+// HandleListArtifactExports handles GET requests to /artifacts/export
+// @Summary List exports
+// @Description List the running and recent exports, oldest first
+// @Tags artifacts
+// @Produce json
+// @Success 200 {array} artifact.Export "Exports"
+// @Router /artifacts/export [get]
+func (h *ArtifactHandler) HandleListArtifactExports(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.exports.List())
+}
+
+// HandleGetArtifactExport handles GET requests to /artifacts/export/{id}
+// @Summary Get an export
+// @Description Get the status and progress of an export
+// @Tags artifacts
+// @Produce json
+// @Param id path string true "Export id"
+// @Success 200 {object} artifact.Export "Export"
+// @Failure 404 {object} ErrorResponse "Export not found"
+// @Router /artifacts/export/{id} [get]
+func (h *ArtifactHandler) HandleGetArtifactExport(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	export, err := h.exports.Get(id)
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, export)
+}
@@ -0,0 +1,313 @@
+// Package artifact exports the files and directories of the sandbox to object storage.
+// Exports run as background jobs, streaming the file or an archive of the directory to
+// the bucket as it is read, and report their progress until they complete.
+package artifact
+
+import (
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+	"github.com/blaxel-ai/sandbox-api/src/lib/objectstore"
+)
+
+// Statuses of an export, those of its job
+const (
+	StatusRunning   = jobs.StatusRunning
+	StatusCompleted = jobs.StatusCompleted
+	StatusFailed    = jobs.StatusFailed
+	StatusCancelled = jobs.StatusCancelled
+)
+
+// DefaultCredentialsRef is the credentials reference of the exports which don't name one
+const DefaultCredentialsRef = "ARTIFACTS"
+
+// ErrExportNotFound is returned for an unknown export id
+var ErrExportNotFound = apierror.New(apierror.CodeNotFound, "export not found")
+
+// credentialsRefPattern matches the names of the credentials references, the prefixes
+// of the environment variables holding the credentials
+var credentialsRefPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Export is the upload of a file or directory to object storage
+type Export struct {
+	// ID is the id of the export and of its job
+	ID   string `json:"id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	Path string `json:"path" example:"/home/user/app/dist" binding:"required"`
+	// Destination is the location of the uploaded object
+	Destination string `json:"destination" example:"s3://my-bucket/builds/dist.tar.gz" binding:"required"`
+	// Format is the format of the archive directories are uploaded as
+	Format string `json:"format,omitempty" example:"tar.gz" enums:"tar.gz,zip"`
+	Status string `json:"status" example:"running" enums:"running,completed,failed,cancelled" binding:"required"`
+	// BytesRead is the number of bytes of the file, or of the archive, read and uploaded
+	BytesRead int64 `json:"bytesRead" example:"1048576" binding:"required"`
+	// TotalBytes is the size of the exported file, 0 for directories whose archive size
+	// isn't known until it is written
+	TotalBytes int64 `json:"totalBytes" example:"4194304" binding:"required"`
+	// Progress is the percentage of the file, or of the content of the directory, uploaded
+	Progress    float64    `json:"progress" example:"25" binding:"required"`
+	Error       string     `json:"error,omitempty" example:"PUT my-bucket/builds/dist.tar.gz: Access Denied"`
+	StartedAt   time.Time  `json:"startedAt" binding:"required"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+} // @name ArtifactExport
+
+// Uploader uploads objects whose size isn't known beforehand, implemented by
+// objectstore.Client
+type Uploader interface {
+	Upload(ctx context.Context, key string, body io.Reader) error
+}
+
+// UploaderFactory returns the uploader to the bucket of a location with the credentials
+// of a reference
+type UploaderFactory func(credentialsRef string, location objectstore.Location) Uploader
+
+// ClientFromEnv is the UploaderFactory reading the endpoint and credentials of a
+// reference from the environment variables it prefixes, see objectstore.ConfigFromEnv
+func ClientFromEnv(credentialsRef string, location objectstore.Location) Uploader {
+	return objectstore.New(objectstore.ConfigFromEnv(credentialsRef, location), location.Bucket)
+}
+
+// Manager runs the exports as jobs and keeps their details
+type Manager struct {
+	mu          sync.RWMutex
+	exports     map[string]*export
+	newUploader UploaderFactory
+	jobs        *jobs.Manager
+}
+
+// Global manager instance
+var (
+	manager     *Manager
+	managerOnce sync.Once
+)
+
+// GetManager returns the manager of the exports, with the credentials of the environment
+// and the jobs of the API
+func GetManager() *Manager {
+	managerOnce.Do(func() {
+		manager = NewManager(ClientFromEnv, jobs.GetManager())
+	})
+	return manager
+}
+
+// NewManager creates a manager uploading with the uploaders of newUploader, in jobs of
+// jobManager
+func NewManager(newUploader UploaderFactory, jobManager *jobs.Manager) *Manager {
+	return &Manager{exports: make(map[string]*export), newUploader: newUploader, jobs: jobManager}
+}
+
+// Request is a request to export a file or directory
+type Request struct {
+	// Path is the absolute path of the file or directory
+	Path string
+	// Destination is an s3:// or gs:// location. Files or archives are uploaded under
+	// their name when it ends with a slash.
+	Destination string
+	// CredentialsRef names the credentials of the bucket, DefaultCredentialsRef when empty
+	CredentialsRef string
+	// Format is the format of the archive of directories, tar.gz when empty
+	Format string
+}
+
+// Start starts the job exporting a file or directory and returns the export, running
+func (m *Manager) Start(fs *filesystem.Filesystem, req Request) (Export, error) {
+	location, err := objectstore.ParseLocation(req.Destination)
+	if err != nil {
+		return Export{}, apierror.Wrap(apierror.CodeInvalidRequest, err)
+	}
+	credentialsRef := req.CredentialsRef
+	if credentialsRef == "" {
+		credentialsRef = DefaultCredentialsRef
+	}
+	if !credentialsRefPattern.MatchString(credentialsRef) {
+		return Export{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid credentialsRef '%s': must be the prefix of environment variables, like %s", credentialsRef, DefaultCredentialsRef)
+	}
+
+	isDir, err := fs.DirectoryExists(req.Path)
+	if err != nil {
+		return Export{}, err
+	}
+	format := ""
+	var size int64
+	if isDir {
+		format = req.Format
+		if format == "" {
+			format = filesystem.ArchiveFormatTarGz
+		}
+		if format != filesystem.ArchiveFormatTarGz && format != filesystem.ArchiveFormatZip {
+			return Export{}, apierror.Newf(apierror.CodeInvalidRequest, "unsupported archive format '%s', expected 'tar.gz' or 'zip'", format)
+		}
+	} else {
+		info, err := fs.GetFileInfo(req.Path)
+		if err != nil {
+			return Export{}, err
+		}
+		size = info.Size
+	}
+
+	// A destination ending with a slash is a directory the file is uploaded to
+	key := strings.TrimSuffix(location.Prefix, "/")
+	if key == "" || strings.HasSuffix(req.Destination, "/") {
+		name := filepath.Base(req.Path)
+		if name == "/" {
+			name = "root"
+		}
+		key = path.Join(key, name)
+		if isDir {
+			key += "." + format
+		}
+	}
+
+	e := &export{
+		export: Export{
+			Path:        req.Path,
+			Destination: location.Scheme + "://" + location.Bucket + "/" + key,
+			Format:      format,
+			TotalBytes:  size,
+		},
+		jobs: m.jobs,
+	}
+	uploader := m.newUploader(credentialsRef, location)
+
+	// The job waits for the export to be registered with its id before it runs
+	m.mu.Lock()
+	job := m.jobs.Start(jobs.TypeExport, req.Path, func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+		m.mu.RLock()
+		m.mu.RUnlock()
+		if err := e.run(ctx, reporter, fs, uploader, key, isDir); err != nil {
+			return nil, err
+		}
+		result := e.export
+		result.BytesRead = e.read.Load()
+		result.Status = StatusCompleted
+		result.Progress = 100
+		now := time.Now()
+		result.CompletedAt = &now
+		return result, nil
+	})
+	e.export.ID = job.ID
+	e.export.StartedAt = job.CreatedAt
+	m.add(e)
+	m.mu.Unlock()
+	return e.status()
+}
+
+// List returns the exports, oldest first
+func (m *Manager) List() []Export {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exports := make([]Export, 0, len(m.exports))
+	for _, e := range m.exports {
+		if export, err := e.status(); err == nil {
+			exports = append(exports, export)
+		}
+	}
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].StartedAt.Before(exports[j].StartedAt)
+	})
+	return exports
+}
+
+// Get returns an export
+func (m *Manager) Get(id string) (Export, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, exists := m.exports[id]
+	if !exists {
+		return Export{}, ErrExportNotFound
+	}
+	return e.status()
+}
+
+// add registers an export, forgetting the exports whose jobs are forgotten. m.mu is held
+// by the caller.
+func (m *Manager) add(e *export) {
+	m.exports[e.export.ID] = e
+	for id, other := range m.exports {
+		if _, err := m.jobs.Get(id); err != nil {
+			delete(m.exports, other.export.ID)
+		}
+	}
+}
+
+// export is an export and the bytes it has read
+type export struct {
+	export Export
+	read   atomic.Int64
+	jobs   *jobs.Manager
+}
+
+// run uploads the file, or an archive of the directory, until ctx is cancelled
+func (e *export) run(ctx context.Context, reporter *jobs.Reporter, fs *filesystem.Filesystem, uploader Uploader, key string, isDir bool) error {
+	var source io.Reader
+	if isDir {
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(fs.WriteArchiveWithProgress(e.export.Path, e.export.Format, jobs.Writer(ctx, writer), reporter.Progress))
+		}()
+		defer reader.Close()
+		source = &countingReader{r: reader, n: &e.read}
+	} else {
+		file, _, err := fs.OpenFile(e.export.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		source = &countingReader{r: file, n: &e.read, progress: func(read int64) {
+			reporter.Progress(read, e.export.TotalBytes)
+		}}
+	}
+
+	if err := uploader.Upload(ctx, key, source); err != nil {
+		return err
+	}
+	logrus.Infof("Exported %s to %s (%d bytes)", e.export.Path, e.export.Destination, e.read.Load())
+	return nil
+}
+
+// status returns the export with the status and progress of its job, ErrExportNotFound
+// once the job is forgotten
+func (e *export) status() (Export, error) {
+	job, err := e.jobs.Get(e.export.ID)
+	if err != nil {
+		return Export{}, ErrExportNotFound
+	}
+	export := e.export
+	export.BytesRead = e.read.Load()
+	export.Status = job.Status
+	export.Progress = job.Progress
+	export.Error = job.Error
+	export.CompletedAt = job.CompletedAt
+	return export, nil
+}
+
+// countingReader counts the bytes read from r, and reports them to progress when it is
+// not nil
+type countingReader struct {
+	r        io.Reader
+	n        *atomic.Int64
+	progress func(read int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	read := c.n.Add(int64(n))
+	if c.progress != nil {
+		c.progress(read)
+	}
+	return n, err
+}
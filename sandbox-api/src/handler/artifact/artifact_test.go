@@ -0,0 +1,191 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+	"github.com/blaxel-ai/sandbox-api/src/lib/objectstore"
+)
+
+// memoryUploader keeps the uploaded objects by bucket and key
+type memoryUploader struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	refs    []string
+}
+
+func (m *memoryUploader) factory(credentialsRef string, location objectstore.Location) Uploader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refs = append(m.refs, credentialsRef)
+	m.bucket = location.Bucket
+	return m
+}
+
+func (m *memoryUploader) Upload(ctx context.Context, key string, body io.Reader) error {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = content
+	return nil
+}
+
+// wait returns the export once it is no longer running
+func wait(t *testing.T, m *Manager, id string) Export {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		export, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Failed to get export: %v", err)
+		}
+		if export.Status != StatusRunning {
+			return export
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Export %s did not complete", id)
+	return Export{}
+}
+
+// TestExportFile tests exporting a file under its own key and into a directory
+func TestExportFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(target, []byte("build report"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uploader := &memoryUploader{objects: map[string][]byte{}}
+	m := NewManager(uploader.factory, jobs.NewManager())
+	fs := filesystem.NewFilesystem("/")
+
+	for destination, key := range map[string]string{
+		"s3://bucket/builds/latest.txt": "builds/latest.txt",
+		"s3://bucket/builds/":           "builds/report.txt",
+	} {
+		export, err := m.Start(fs, Request{Path: target, Destination: destination})
+		if err != nil {
+			t.Fatalf("Failed to start export: %v", err)
+		}
+		export = wait(t, m, export.ID)
+		if export.Status != StatusCompleted || export.Progress != 100 || export.BytesRead != 12 || export.TotalBytes != 12 {
+			t.Errorf("Unexpected export %+v", export)
+		}
+		if export.Destination != "s3://bucket/"+key || string(uploader.objects[key]) != "build report" {
+			t.Errorf("Expected the file to be uploaded to %s, got %s and %v", key, export.Destination, uploader.objects)
+		}
+	}
+	if uploader.refs[0] != DefaultCredentialsRef {
+		t.Errorf("Expected the default credentials, got %v", uploader.refs)
+	}
+}
+
+// TestExportDirectory tests exporting a directory as a tarball
+func TestExportDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uploader := &memoryUploader{objects: map[string][]byte{}}
+	m := NewManager(uploader.factory, jobs.NewManager())
+
+	export, err := m.Start(filesystem.NewFilesystem("/"), Request{Path: dir, Destination: "gs://bucket", CredentialsRef: "BUILD_CACHE"})
+	if err != nil {
+		t.Fatalf("Failed to start export: %v", err)
+	}
+	if export = wait(t, m, export.ID); export.Status != StatusCompleted || export.Destination != "gs://bucket/dist.tar.gz" {
+		t.Fatalf("Unexpected export %+v", export)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(uploader.objects["dist.tar.gz"]))
+	if err != nil {
+		t.Fatalf("Expected a tarball: %v", err)
+	}
+	names := map[string]bool{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names[header.Name] = true
+	}
+	if !names["assets/app.js"] {
+		t.Errorf("Expected the archive to contain assets/app.js, got %v", names)
+	}
+}
+
+// TestExportInvalid tests that invalid requests fail before anything is uploaded
+func TestExportInvalid(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(target, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager((&memoryUploader{objects: map[string][]byte{}}).factory, jobs.NewManager())
+	fs := filesystem.NewFilesystem("/")
+
+	for _, req := range []Request{
+		{Path: target, Destination: "https://bucket/key"},
+		{Path: target, Destination: "s3://bucket/key", CredentialsRef: "lower-case"},
+		{Path: filepath.Dir(target), Destination: "s3://bucket/key", Format: "rar"},
+	} {
+		_, err := m.Start(fs, req)
+		if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeInvalidRequest {
+			t.Errorf("Expected INVALID_REQUEST for %+v, got %v", req, err)
+		}
+	}
+	_, err := m.Start(fs, Request{Path: target + ".missing", Destination: "s3://bucket/key"})
+	if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeFSNotFound {
+		t.Errorf("Expected FS_NOT_FOUND, got %v", err)
+	}
+	if len(m.List()) != 0 {
+		t.Errorf("Expected no export to be started")
+	}
+}
+
+// blockingUploader reads the body until its context is cancelled
+type blockingUploader struct{}
+
+func (blockingUploader) Upload(ctx context.Context, key string, body io.Reader) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestExportCancel tests that cancelling the job of an export stops the upload
+func TestExportCancel(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(target, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jobManager := jobs.NewManager()
+	m := NewManager(func(string, objectstore.Location) Uploader { return blockingUploader{} }, jobManager)
+
+	export, err := m.Start(filesystem.NewFilesystem("/"), Request{Path: target, Destination: "s3://bucket/key"})
+	if err != nil {
+		t.Fatalf("Failed to start export: %v", err)
+	}
+	if job, err := jobManager.Cancel(export.ID); err != nil || job.Type != jobs.TypeExport {
+		t.Fatalf("Failed to cancel the job of the export: %+v (%v)", job, err)
+	}
+	if export, _ = m.Get(export.ID); export.Status != StatusCancelled {
+		t.Errorf("Expected the export to be cancelled, got %+v", export)
+	}
+}
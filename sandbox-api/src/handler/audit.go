@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/audit"
+)
+
+// maxAuditLimit is the largest number of entries returned by a single audit query
+const maxAuditLimit = 10000
+
+// AuditHandler serves the audit log of the operations performed in the sandbox
+type AuditHandler struct {
+	*BaseHandler
+	logger *audit.Logger
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{
+		BaseHandler: NewBaseHandler(),
+		logger:      audit.GetLogger(),
+	}
+}
+
+// parseAuditFilter reads the audit filter from the query parameters
+func (h *AuditHandler) parseAuditFilter(c *gin.Context) (audit.Filter, error) {
+	filter := audit.Filter{
+		Source:    c.Query("source"),
+		Operation: c.Query("operation"),
+		Target:    c.Query("target"),
+	}
+
+	for param, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := c.Query(param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: must be an RFC 3339 timestamp", param)
+			}
+			*value = parsed
+		}
+	}
+
+	if raw := c.Query("success"); raw != "" {
+		success, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid success: must be true or false")
+		}
+		filter.Success = &success
+	}
+
+	limit, err := strconv.Atoi(h.GetQueryParam(c, "limit", strconv.Itoa(audit.DefaultLimit)))
+	if err != nil || limit < 1 {
+		return filter, fmt.Errorf("invalid limit: must be a positive number")
+	}
+	filter.Limit = min(limit, maxAuditLimit)
+
+	return filter, nil
+}
+
+// HandleGetAudit handles GET requests to /audit
+// @Summary Get the audit log
+// @Description Get the most recent operations performed in the sandbox through the REST API, the WebSocket endpoint and MCP tools, oldest first. Secrets and environment variable values are redacted from the recorded arguments, and long values are truncated. With follow=true, the matching entries are streamed as JSON lines, followed by the new ones as they are recorded.
+// @Tags audit
+// @Produce json
+// @Param source query string false "Only entries of this source: http, ws, mcp or grpc"
+// @Param operation query string false "Only entries whose operation contains this value"
+// @Param target query string false "Only entries whose target path contains this value"
+// @Param since query string false "Only entries recorded at or after this RFC 3339 timestamp"
+// @Param until query string false "Only entries recorded at or before this RFC 3339 timestamp"
+// @Param success query boolean false "Only successful or failed entries"
+// @Param limit query int false "Maximum number of entries (default: 100, max: 10000)"
+// @Param follow query boolean false "Stream new entries as they are recorded"
+// @Success 200 {array} audit.Entry "Audit entries"
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /audit [get]
+func (h *AuditHandler) HandleGetAudit(c *gin.Context) {
+	filter, err := h.parseAuditFilter(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if c.Query("follow") != "true" {
+		entries, err := h.logger.Query(filter)
+		if err != nil {
+			h.SendError(c, http.StatusInternalServerError, err)
+			return
+		}
+		h.SendJSON(c, http.StatusOK, entries)
+		return
+	}
+
+	entries, tail, stop, err := h.logger.Tail(filter)
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer stop()
+
+	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	writeEntry := func(entry audit.Entry) error {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := c.Writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for _, entry := range entries {
+		if err := writeEntry(entry); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	// Keepalive ticker to prevent idle timeouts while tailing
+	keepaliveTicker := time.NewTicker(30 * time.Second)
+	defer keepaliveTicker.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry := <-tail:
+			if err := writeEntry(entry); err != nil {
+				return
+			}
+		case <-keepaliveTicker.C:
+			if _, err := c.Writer.Write([]byte("[keepalive]\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
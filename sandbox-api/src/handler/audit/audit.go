@@ -0,0 +1,286 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sources of the audited operations
+const (
+	SourceHTTP      = "http"
+	SourceWebSocket = "ws"
+	SourceMCP       = "mcp"
+	SourceGRPC      = "grpc"
+)
+
+const (
+	// DefaultLimit is the number of entries returned by queries that don't set a limit
+	DefaultLimit = 100
+	// tailBufferSize is the number of entries buffered for a tail before new ones are dropped
+	tailBufferSize = 256
+	// maxEntrySize is the size of the longest entry line read back from the log
+	maxEntrySize = 1024 * 1024
+)
+
+// Caller identifies who performed an operation. Tokens are never recorded, only a
+// fingerprint allowing to tell callers apart.
+type Caller struct {
+	IP               string `json:"ip,omitempty" example:"10.0.0.12"`
+	UserAgent        string `json:"userAgent,omitempty" example:"blaxel-sdk/1.0"`
+	TokenFingerprint string `json:"tokenFingerprint,omitempty" example:"5e884898da280471"` // sha256 prefix of the Authorization header
+} // @name AuditCaller
+
+// Entry is an audited operation
+type Entry struct {
+	Timestamp  time.Time              `json:"timestamp" binding:"required"`
+	Source     string                 `json:"source" example:"http" binding:"required"`                     // http, ws, mcp or grpc
+	Operation  string                 `json:"operation" example:"PUT /filesystem/*path" binding:"required"` // route, WebSocket operation, MCP tool or gRPC method
+	Target     string                 `json:"target,omitempty" example:"/filesystem/tmp/notes.txt"`         // request path of HTTP operations
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	Caller     Caller                 `json:"caller" binding:"required"`
+	Status     int                    `json:"status,omitempty" example:"200"` // status of HTTP operations
+	Success    bool                   `json:"success" example:"true" binding:"required"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"durationMs" example:"12" binding:"required"`
+} // @name AuditEntry
+
+// Filter selects audit entries. Zero values match every entry.
+type Filter struct {
+	Source    string
+	Operation string // substring of the operation
+	Target    string // substring of the target
+	Since     time.Time
+	Until     time.Time
+	Success   *bool
+	Limit     int // number of most recent matching entries, DefaultLimit when zero
+}
+
+// Match reports whether an entry is selected by the filter
+func (f Filter) Match(entry Entry) bool {
+	if f.Source != "" && entry.Source != f.Source {
+		return false
+	}
+	if f.Operation != "" && !strings.Contains(entry.Operation, f.Operation) {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(entry.Target, f.Target) {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.Success != nil && entry.Success != *f.Success {
+		return false
+	}
+	return true
+}
+
+// Logger records audit entries to an append-only JSONL file
+type Logger struct {
+	path    string
+	file    *os.File
+	tails   map[int]chan Entry
+	filters map[int]Filter
+	nextID  int
+	mu      sync.Mutex
+}
+
+// Global audit logger instance
+var (
+	logger     *Logger
+	loggerOnce sync.Once
+)
+
+// GetLogger returns the audit logger writing to the file configured by AUDIT_LOG_PATH
+func GetLogger() *Logger {
+	loggerOnce.Do(func() {
+		logger = NewLogger(LogPathFromEnv())
+	})
+	return logger
+}
+
+// LogPathFromEnv returns the path of the audit log, read from AUDIT_LOG_PATH and
+// defaulting to sandbox-audit.jsonl in the temp directory
+func LogPathFromEnv() string {
+	if path := os.Getenv("AUDIT_LOG_PATH"); path != "" {
+		return path
+	}
+	return filepath.Join(os.TempDir(), "sandbox-audit.jsonl")
+}
+
+// NewLogger creates a logger appending to the file at path. The file is created on
+// the first recorded entry.
+func NewLogger(path string) *Logger {
+	return &Logger{
+		path:    path,
+		tails:   make(map[int]chan Entry),
+		filters: make(map[int]Filter),
+	}
+}
+
+// Path returns the path of the audit log
+func (l *Logger) Path() string {
+	return l.path
+}
+
+// Record appends an entry to the log and sends it to the matching tails. Failures
+// are logged, auditing never fails an operation.
+func (l *Logger) Record(entry Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logrus.Errorf("Failed to marshal audit entry: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+			logrus.Errorf("Failed to create audit log directory: %v", err)
+			return
+		}
+		file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			logrus.Errorf("Failed to open audit log: %v", err)
+			return
+		}
+		l.file = file
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		logrus.Errorf("Failed to write audit entry: %v", err)
+		return
+	}
+
+	for id, tail := range l.tails {
+		if !l.filters[id].Match(entry) {
+			continue
+		}
+		select {
+		case tail <- entry:
+		default:
+			logrus.Debugf("Audit tail %d is too slow, dropping entry", id)
+		}
+	}
+}
+
+// Query returns the most recent entries matching the filter, oldest first
+func (l *Logger) Query(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.query(filter)
+}
+
+// Tail returns the most recent entries matching the filter, like Query, and a channel
+// receiving the matching entries recorded afterwards until the returned function is
+// called. Entries are dropped when the channel is not drained fast enough.
+func (l *Logger) Tail(filter Filter) ([]Entry, <-chan Entry, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.query(filter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	l.nextID++
+	id := l.nextID
+	tail := make(chan Entry, tailBufferSize)
+	l.tails[id] = tail
+	l.filters[id] = filter
+
+	return entries, tail, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.tails, id)
+		delete(l.filters, id)
+	}, nil
+}
+
+// query reads the log back. The mutex must be held.
+func (l *Logger) query(filter Filter) ([]Entry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	// Keep the last matching entries in a ring buffer
+	ring := make([]Entry, 0, limit)
+	start := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A partially written line, e.g. after a crash
+			continue
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		if len(ring) < limit {
+			ring = append(ring, entry)
+		} else {
+			ring[start] = entry
+			start = (start + 1) % limit
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return append(ring[start:], ring[:start]...), nil
+}
+
+// Close closes the log file
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// NewCaller identifies the caller of an operation from its IP and request headers
+func NewCaller(ip string, header http.Header) Caller {
+	caller := Caller{IP: ip}
+	if header == nil {
+		return caller
+	}
+	caller.UserAgent = header.Get("User-Agent")
+	if authorization := header.Get("Authorization"); authorization != "" {
+		sum := sha256.Sum256([]byte(authorization))
+		caller.TokenFingerprint = hex.EncodeToString(sum[:8])
+	}
+	return caller
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestQueryAndTail tests recording entries and reading them back
+func TestQueryAndTail(t *testing.T) {
+	logger := NewLogger(filepath.Join(t.TempDir(), "audit", "audit.jsonl"))
+	defer func() { _ = logger.Close() }()
+
+	entries, err := logger.Query(Filter{})
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Expected no entries before the log exists, got %v (%v)", entries, err)
+	}
+
+	start := time.Now().UTC()
+	for i, operation := range []string{"PUT /filesystem/*path", "GET /process", "process:start", "PUT /filesystem/*path"} {
+		source := SourceHTTP
+		if !strings.Contains(operation, "/") {
+			source = SourceWebSocket
+		}
+		logger.Record(Entry{Timestamp: start.Add(time.Duration(i) * time.Second), Source: source, Operation: operation, Success: i != 3})
+	}
+
+	entries, err = logger.Query(Filter{Operation: "/filesystem"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 filesystem entries, got %v (%v)", entries, err)
+	}
+	failed := false
+	entries, _ = logger.Query(Filter{Success: &failed})
+	if len(entries) != 1 || !entries[0].Timestamp.Equal(start.Add(3*time.Second)) {
+		t.Errorf("Expected the failed entry, got %v", entries)
+	}
+	entries, _ = logger.Query(Filter{Source: SourceHTTP, Limit: 2})
+	if len(entries) != 2 || entries[0].Operation != "GET /process" || entries[1].Operation != "PUT /filesystem/*path" {
+		t.Errorf("Expected the 2 most recent HTTP entries, oldest first, got %v", entries)
+	}
+	entries, _ = logger.Query(Filter{Since: start.Add(time.Second), Until: start.Add(2 * time.Second)})
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 entries in the time range, got %v", entries)
+	}
+
+	entries, tail, stop, err := logger.Tail(Filter{Source: SourceWebSocket})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected 1 WebSocket entry, got %v (%v)", entries, err)
+	}
+	logger.Record(Entry{Source: SourceHTTP, Operation: "GET /audit"})
+	logger.Record(Entry{Source: SourceWebSocket, Operation: "filesystem:watch:start"})
+	select {
+	case entry := <-tail:
+		if entry.Operation != "filesystem:watch:start" || entry.Timestamp.IsZero() {
+			t.Errorf("Expected the new WebSocket entry, got %v", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the tailed entry")
+	}
+	stop()
+}
+
+// TestSanitizeJSON tests that secrets are redacted and long values truncated
+func TestSanitizeJSON(t *testing.T) {
+	arguments := SanitizeJSON([]byte(`{"command":"npm start","env":{"API_KEY":"abc"},"gitToken":"xyz","files":[{"content":"` + strings.Repeat("a", 1000) + `"}]}`))
+
+	if arguments["command"] != "npm start" {
+		t.Errorf("Expected command to be kept, got %v", arguments["command"])
+	}
+	if env := arguments["env"].(map[string]interface{}); env["API_KEY"] != redacted {
+		t.Errorf("Expected environment values to be redacted, got %v", env)
+	}
+	if arguments["gitToken"] != redacted {
+		t.Errorf("Expected token to be redacted, got %v", arguments["gitToken"])
+	}
+	content := arguments["files"].([]interface{})[0].(map[string]interface{})["content"].(string)
+	if !strings.HasSuffix(content, "... (1000 bytes)") || len(content) > maxStringLength+32 {
+		t.Errorf("Expected content to be truncated, got %s", content)
+	}
+
+	if arguments := SanitizeJSON([]byte(`"value"`)); arguments["value"] != "value" {
+		t.Errorf("Expected non object arguments to be wrapped, got %v", arguments)
+	}
+	if arguments := SanitizeJSON([]byte(`{`)); arguments != nil {
+		t.Errorf("Expected invalid JSON to be ignored, got %v", arguments)
+	}
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxStringLength is the length above which string arguments are truncated, e.g.
+	// the content of written files
+	maxStringLength = 256
+	// redacted replaces the values of secret arguments
+	redacted = "[REDACTED]"
+)
+
+// secretKeys are substrings of the argument names whose values are never recorded
+var secretKeys = []string{"token", "password", "secret", "apikey", "api_key", "credential", "authorization"}
+
+// SanitizeJSON decodes JSON arguments and makes them safe to record with SanitizeValue.
+// Objects are returned as is, other values under a "value" key. Invalid JSON returns nil.
+func SanitizeJSON(data []byte) map[string]interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	return toArguments(SanitizeValue(value))
+}
+
+// SanitizeArguments converts arguments to JSON values and makes them safe to record
+// with SanitizeValue
+func SanitizeArguments(arguments interface{}) map[string]interface{} {
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return nil
+	}
+	return SanitizeJSON(data)
+}
+
+// SanitizeValue redacts the values of secret keys and of environment variables, and
+// truncates long strings, in a decoded JSON value
+func SanitizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		sanitized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			switch {
+			case isSecretKey(key):
+				sanitized[key] = redacted
+			case key == "env":
+				sanitized[key] = redactValues(item)
+			default:
+				sanitized[key] = SanitizeValue(item)
+			}
+		}
+		return sanitized
+	case []interface{}:
+		sanitized := make([]interface{}, len(v))
+		for i, item := range v {
+			sanitized[i] = SanitizeValue(item)
+		}
+		return sanitized
+	case string:
+		if len(v) > maxStringLength {
+			return fmt.Sprintf("%s... (%d bytes)", v[:maxStringLength], len(v))
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// isSecretKey reports whether the value of an argument is a secret
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValues keeps the keys of an object, e.g. the names of environment variables,
+// and redacts its values
+func redactValues(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return redacted
+	}
+	sanitized := make(map[string]interface{}, len(object))
+	for key := range object {
+		sanitized[key] = redacted
+	}
+	return sanitized
+}
+
+// toArguments returns an object as is and wraps other values
+func toArguments(value interface{}) map[string]interface{} {
+	if object, ok := value.(map[string]interface{}); ok {
+		return object
+	}
+	return map[string]interface{}{"value": value}
+}
@@ -5,6 +5,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
 )
 
 // BaseHandler provides common functionality for both MCP and API handlers
@@ -17,22 +20,32 @@ func NewBaseHandler() *BaseHandler {
 	return &BaseHandler{}
 }
 
-// ErrorResponse represents an error response
+// ErrorResponse represents an error response. Code is stable and meant to be matched
+// by clients, unlike the message.
 type ErrorResponse struct {
-	Error string `json:"error" example:"Error message" binding:"required"`
+	Error   string         `json:"error" example:"Error message" binding:"required"`
+	Code    apierror.Code  `json:"code" example:"FS_NOT_FOUND" binding:"required"`
+	Details map[string]any `json:"details,omitempty"`
 } // @name ErrorResponse
 
+// NewErrorResponse returns the response of an error, with the code of the error or the
+// generic code of the status
+func NewErrorResponse(status int, err error) ErrorResponse {
+	body := apierror.NewBody(err, apierror.CodeForStatus(status))
+	return ErrorResponse{Error: body.Error, Code: body.Code, Details: body.Details}
+}
+
 // SuccessResponse represents a success response
 type SuccessResponse struct {
 	Path    string `json:"path" example:"/path/to/file"`
 	Message string `json:"message" example:"File created successfully" binding:"required"`
 } // @name SuccessResponse
 
-// SendError sends a standardized error response
+// SendError sends a standardized error response. Errors with a code are sent with the
+// status of their code, see apierror.HTTPStatus.
 func (h *BaseHandler) SendError(c *gin.Context, status int, err error) {
-	c.JSON(status, ErrorResponse{
-		Error: err.Error(),
-	})
+	status = apierror.HTTPStatus(err, status)
+	c.JSON(status, NewErrorResponse(status, err))
 }
 
 // SendSuccess sends a standardized success response
@@ -72,10 +85,11 @@ func (h *BaseHandler) GetQueryParam(c *gin.Context, param string, defaultValue s
 	return value
 }
 
-// BindJSON binds the request body to a struct and returns an error if it fails
+// BindJSON binds the request body to a struct and validates it with its binding tags,
+// returning an INVALID_REQUEST error listing the invalid fields if it fails
 func (h *BaseHandler) BindJSON(c *gin.Context, obj interface{}) error {
 	if err := c.ShouldBindJSON(obj); err != nil {
-		return fmt.Errorf("invalid request body: %w", err)
+		return validation.Error(err)
 	}
 	return nil
 }
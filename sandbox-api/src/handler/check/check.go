@@ -0,0 +1,361 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// toolTimeout bounds the time a single linter or formatter runs
+const toolTimeout = 2 * time.Minute
+
+// Severities of diagnostics
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is an issue reported by a linter or formatter. Line and column are 1-based,
+// and zero for issues about a whole file.
+type Diagnostic struct {
+	File     string `json:"file" example:"/home/user/app/main.go" binding:"required"`
+	Line     int    `json:"line" example:"12" binding:"required"`
+	Column   int    `json:"column" example:"5" binding:"required"`
+	Severity string `json:"severity" example:"error" enums:"error,warning" binding:"required"`
+	Message  string `json:"message" example:"undefined: foo" binding:"required"`
+	Rule     string `json:"rule,omitempty" example:"no-unused-vars"`
+	Tool     string `json:"tool" example:"eslint" binding:"required"`
+} // @name CodeDiagnostic
+
+// ToolRun reports whether a tool ran on the checked path
+type ToolRun struct {
+	Tool  string `json:"tool" example:"gofmt" binding:"required"`
+	Ran   bool   `json:"ran" example:"true" binding:"required"`
+	Error string `json:"error,omitempty" example:"ruff is not installed"`
+} // @name CodeCheckTool
+
+// Result is the outcome of a check
+type Result struct {
+	Diagnostics []Diagnostic `json:"diagnostics" binding:"required"`
+	Tools       []ToolRun    `json:"tools" binding:"required"`
+	Fixed       bool         `json:"fixed" example:"false" binding:"required"`
+} // @name CodeCheckResult
+
+// tool is a linter or formatter. Detect returns the directory of the project it
+// applies to, or false when the checked path is not such a project.
+type tool struct {
+	name   string
+	detect func(target string) (string, bool)
+	run    func(ctx context.Context, project, target string, fix bool) ([]Diagnostic, error)
+}
+
+// tools are the supported linters and formatters, in the order they run
+var tools = []tool{
+	{name: "gofmt", detect: detectGo, run: runGofmt},
+	{name: "eslint", detect: detectESLint, run: runESLint},
+	{name: "ruff", detect: detectRuff, run: runRuff},
+}
+
+// Tools returns the names of the supported linters and formatters
+func Tools() []string {
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// Run checks a file or directory with the given tools, or with the tools detected from
+// the project when names is empty, fixing what the tools can fix when fix is set. Tools
+// which are missing or fail are reported in the result rather than as an error.
+func Run(ctx context.Context, target string, names []string, fix bool) (*Result, error) {
+	for _, name := range names {
+		if !slices.Contains(Tools(), name) {
+			return nil, fmt.Errorf("unknown tool '%s', expected one of %s", name, strings.Join(Tools(), ", "))
+		}
+	}
+	if _, err := os.Stat(target); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Diagnostics: []Diagnostic{}, Tools: []ToolRun{}, Fixed: fix}
+	for _, t := range tools {
+		project, detected := t.detect(target)
+		if len(names) > 0 {
+			if !slices.Contains(names, t.name) {
+				continue
+			}
+			if !detected {
+				project = projectDir(target)
+			}
+		} else if !detected {
+			continue
+		}
+
+		toolCtx, cancel := context.WithTimeout(ctx, toolTimeout)
+		diagnostics, err := t.run(toolCtx, project, target, fix)
+		cancel()
+
+		run := ToolRun{Tool: t.name, Ran: err == nil}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		result.Tools = append(result.Tools, run)
+		result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	}
+	return result, nil
+}
+
+// projectDir returns the directory of a checked path
+func projectDir(target string) string {
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		return filepath.Dir(target)
+	}
+	return target
+}
+
+// findUp returns the closest directory from the checked path up containing one of the
+// marker files
+func findUp(target string, markers ...string) (string, bool) {
+	for dir := projectDir(target); ; dir = filepath.Dir(dir) {
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir, true
+			}
+		}
+		if dir == filepath.Dir(dir) {
+			return "", false
+		}
+	}
+}
+
+// hasExtension reports whether the checked path is a file with one of the extensions, or
+// a directory containing such files
+func hasExtension(target string, extensions ...string) bool {
+	info, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() {
+		return slices.Contains(extensions, filepath.Ext(target))
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && slices.Contains(extensions, filepath.Ext(entry.Name())) {
+			return true
+		}
+	}
+	return false
+}
+
+// command runs a tool in dir and returns its standard output and error. Exit statuses in
+// okStatus are not failures, linters use them to report issues.
+func command(ctx context.Context, dir, name string, args []string, okStatus ...int) ([]byte, []byte, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not installed", filepath.Base(name))
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && slices.Contains(okStatus, exitErr.ExitCode())) {
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("%s timed out after %s", filepath.Base(name), toolTimeout)
+		}
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, nil, fmt.Errorf("%s failed: %s", filepath.Base(name), message)
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
+
+// detectGo detects Go modules and Go files
+func detectGo(target string) (string, bool) {
+	if dir, found := findUp(target, "go.mod"); found {
+		return dir, true
+	}
+	return projectDir(target), hasExtension(target, ".go")
+}
+
+// gofmtError matches the syntax errors reported by gofmt
+var gofmtError = regexp.MustCompile(`^(.+?):(\d+):(\d+): (.+)$`)
+
+// runGofmt lists the Go files which are not formatted, or formats them with fix
+func runGofmt(ctx context.Context, project, target string, fix bool) ([]Diagnostic, error) {
+	mode := "-l"
+	if fix {
+		mode = "-w"
+	}
+	// gofmt exits with 2 on syntax errors, which are reported as diagnostics
+	stdout, stderr, err := command(ctx, project, "gofmt", []string{mode, "-e", target}, 2)
+	if err != nil {
+		return nil, err
+	}
+	return parseGofmt(string(stdout), string(stderr), fix), nil
+}
+
+// parseGofmt parses the files listed by gofmt -l and its syntax errors
+func parseGofmt(stdout, stderr string, fix bool) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, line := range strings.Split(stderr, "\n") {
+		match := gofmtError.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		diagnostics = append(diagnostics, Diagnostic{
+			File: match[1], Line: lineNumber, Column: column,
+			Severity: SeverityError, Message: match[4], Tool: "gofmt",
+		})
+	}
+	if fix {
+		return diagnostics
+	}
+	for _, file := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if file == "" {
+			continue
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			File: file, Severity: SeverityWarning, Message: "file is not formatted with gofmt", Tool: "gofmt",
+		})
+	}
+	return diagnostics
+}
+
+// eslintConfigs are the configuration files of ESLint
+var eslintConfigs = []string{
+	"eslint.config.js", "eslint.config.mjs", "eslint.config.cjs", "eslint.config.ts",
+	".eslintrc", ".eslintrc.js", ".eslintrc.cjs", ".eslintrc.json", ".eslintrc.yml", ".eslintrc.yaml",
+}
+
+// detectESLint detects projects with an ESLint configuration
+func detectESLint(target string) (string, bool) {
+	return findUp(target, eslintConfigs...)
+}
+
+// runESLint lints JavaScript and TypeScript files with the ESLint of the project
+func runESLint(ctx context.Context, project, target string, fix bool) ([]Diagnostic, error) {
+	binary := filepath.Join(project, "node_modules", ".bin", "eslint")
+	if _, err := os.Stat(binary); err != nil {
+		binary = "eslint"
+	}
+	args := []string{"--format", "json"}
+	if fix {
+		args = append(args, "--fix")
+	}
+	// ESLint exits with 1 when it reports errors
+	stdout, _, err := command(ctx, project, binary, append(args, target), 1)
+	if err != nil {
+		return nil, err
+	}
+	return parseESLint(stdout)
+}
+
+// parseESLint parses the output of eslint --format json
+func parseESLint(output []byte) ([]Diagnostic, error) {
+	var files []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID   *string `json:"ruleId"`
+			Severity int     `json:"severity"`
+			Message  string  `json:"message"`
+			Line     int     `json:"line"`
+			Column   int     `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(output, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse eslint output: %w", err)
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, file := range files {
+		for _, message := range file.Messages {
+			diagnostic := Diagnostic{
+				File: file.FilePath, Line: message.Line, Column: message.Column,
+				Severity: SeverityWarning, Message: message.Message, Tool: "eslint",
+			}
+			if message.Severity == 2 {
+				diagnostic.Severity = SeverityError
+			}
+			if message.RuleID != nil {
+				diagnostic.Rule = *message.RuleID
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics, nil
+}
+
+// detectRuff detects Python projects and Python files
+func detectRuff(target string) (string, bool) {
+	if dir, found := findUp(target, "ruff.toml", ".ruff.toml", "pyproject.toml", "setup.py", "setup.cfg"); found {
+		return dir, true
+	}
+	return projectDir(target), hasExtension(target, ".py", ".pyi")
+}
+
+// runRuff lints Python files with ruff
+func runRuff(ctx context.Context, project, target string, fix bool) ([]Diagnostic, error) {
+	args := []string{"check", "--output-format", "json", "--exit-zero"}
+	if fix {
+		args = append(args, "--fix")
+	}
+	stdout, _, err := command(ctx, project, "ruff", append(args, target))
+	if err != nil {
+		return nil, err
+	}
+	return parseRuff(stdout)
+}
+
+// parseRuff parses the output of ruff check --output-format json
+func parseRuff(output []byte) ([]Diagnostic, error) {
+	var violations []struct {
+		Code     *string `json:"code"`
+		Message  string  `json:"message"`
+		Filename string  `json:"filename"`
+		Location struct {
+			Row    int `json:"row"`
+			Column int `json:"column"`
+		} `json:"location"`
+	}
+	if err := json.Unmarshal(output, &violations); err != nil {
+		return nil, fmt.Errorf("failed to parse ruff output: %w", err)
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, violation := range violations {
+		diagnostic := Diagnostic{
+			File: violation.Filename, Line: violation.Location.Row, Column: violation.Location.Column,
+			Severity: SeverityWarning, Message: violation.Message, Tool: "ruff",
+		}
+		// Syntax errors have no rule code
+		if violation.Code == nil {
+			diagnostic.Severity = SeverityError
+		} else {
+			diagnostic.Rule = *violation.Code
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics, nil
+}
@@ -0,0 +1,97 @@
+package check
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestRunGofmt tests detecting a Go project, reporting unformatted files and fixing them
+func TestRunGofmt(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt is not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\nfunc main(){}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(context.Background(), dir, nil, false)
+	if err != nil {
+		t.Fatalf("Failed to check: %v", err)
+	}
+	if len(result.Tools) != 1 || result.Tools[0].Tool != "gofmt" || !result.Tools[0].Ran {
+		t.Fatalf("Expected only gofmt to run, got %+v", result.Tools)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].File != file || result.Diagnostics[0].Severity != SeverityWarning {
+		t.Fatalf("Expected main.go to be reported, got %+v", result.Diagnostics)
+	}
+
+	if _, err := Run(context.Background(), file, []string{"gofmt"}, true); err != nil {
+		t.Fatalf("Failed to fix: %v", err)
+	}
+	content, _ := os.ReadFile(file)
+	if string(content) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("Expected main.go to be formatted, got %q", content)
+	}
+
+	if _, err := Run(context.Background(), dir, []string{"unknown"}, false); err == nil {
+		t.Error("Expected an error for an unknown tool")
+	}
+}
+
+// TestParseGofmt tests parsing syntax errors reported by gofmt
+func TestParseGofmt(t *testing.T) {
+	diagnostics := parseGofmt("", "/app/main.go:3:1: expected declaration, found 'x'\n", false)
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %+v", diagnostics)
+	}
+	if d := diagnostics[0]; d.File != "/app/main.go" || d.Line != 3 || d.Column != 1 || d.Severity != SeverityError {
+		t.Errorf("Unexpected diagnostic: %+v", d)
+	}
+}
+
+// TestParseESLint tests parsing the JSON output of ESLint
+func TestParseESLint(t *testing.T) {
+	output := `[{"filePath":"/app/index.js","messages":[
+		{"ruleId":"no-unused-vars","severity":2,"message":"'a' is defined but never used.","line":1,"column":7},
+		{"ruleId":null,"severity":1,"message":"File ignored","line":0,"column":0}]}]`
+	diagnostics, err := parseESLint([]byte(output))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %+v", diagnostics)
+	}
+	if d := diagnostics[0]; d.Rule != "no-unused-vars" || d.Severity != SeverityError || d.Line != 1 || d.Column != 7 {
+		t.Errorf("Unexpected diagnostic: %+v", d)
+	}
+	if d := diagnostics[1]; d.Rule != "" || d.Severity != SeverityWarning {
+		t.Errorf("Unexpected diagnostic: %+v", d)
+	}
+}
+
+// TestParseRuff tests parsing the JSON output of ruff
+func TestParseRuff(t *testing.T) {
+	output := `[{"code":"F401","message":"'os' imported but unused","filename":"/app/main.py","location":{"row":1,"column":8}},
+		{"code":null,"message":"SyntaxError: Expected an expression","filename":"/app/bad.py","location":{"row":2,"column":5}}]`
+	diagnostics, err := parseRuff([]byte(output))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %+v", diagnostics)
+	}
+	if d := diagnostics[0]; d.Rule != "F401" || d.Severity != SeverityWarning || d.File != "/app/main.py" || d.Line != 1 {
+		t.Errorf("Unexpected diagnostic: %+v", d)
+	}
+	if d := diagnostics[1]; d.Severity != SeverityError {
+		t.Errorf("Expected a syntax error, got %+v", d)
+	}
+}
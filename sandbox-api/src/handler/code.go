@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/check"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// CodeHandler handles checks of the code of the sandbox with linters and formatters
+type CodeHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+}
+
+// NewCodeHandler creates a new code handler resolving paths like the filesystem handler
+func NewCodeHandler(fsHandler *FileSystemHandler) *CodeHandler {
+	return &CodeHandler{
+		BaseHandler: NewBaseHandler(),
+		FileSystem:  fsHandler,
+	}
+}
+
+// CodeCheckRequest represents the request body for checking code
+type CodeCheckRequest struct {
+	Path  string   `json:"path" example:"src"`
+	Tools []string `json:"tools" example:"gofmt,eslint"`
+	Fix   bool     `json:"fix" example:"false"`
+} // @name CodeCheckRequest
+
+// HandleCheck handles POST requests to /code/check
+// @Summary Run linters and formatters
+// @Description Check a file or directory with the linters and formatters of its project, and return their diagnostics. Without tools, they are detected from the project: gofmt for Go modules and files, eslint for projects with an ESLint configuration (using the ESLint of the project when installed), ruff for Python projects and files.
+// @Description
+// @Description With fix, the tools fix what they can (gofmt -w, eslint --fix, ruff check --fix) and report the remaining issues. Tools which are not installed or fail are reported with their error in tools.
+// @Tags code
+// @Accept json
+// @Produce json
+// @Param request body CodeCheckRequest true "Path to check, defaults to the working directory"
+// @Success 200 {object} check.Result "Diagnostics"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 404 {object} ErrorResponse "Path not found"
+// @Router /code/check [post]
+func (h *CodeHandler) HandleCheck(c *gin.Context) {
+	var req CodeCheckRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	path := req.Path
+	if path == "" {
+		path = "."
+	}
+	target, err := h.FileSystem.fs.GetAbsolutePath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := check.Run(c.Request.Context(), target, req.Tools, req.Fix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			h.SendError(c, http.StatusNotFound, apierror.Newf(apierror.CodeFSNotFound, "path not found: %s", target))
+			return
+		}
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, result)
+}
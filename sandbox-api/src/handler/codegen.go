@@ -1,14 +1,20 @@
 package handler
 
 import (
+	"cmp"
+	"context"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/handler/index"
 	"github.com/blaxel-ai/sandbox-api/src/lib"
 	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
 	"github.com/gin-gonic/gin"
@@ -19,12 +25,14 @@ import (
 type CodegenHandler struct {
 	BaseHandler
 	FileSystem *FileSystemHandler
+	newClient  func() (codegen.Client, error)
 }
 
 // NewCodegenHandler creates a new codegen handler
 func NewCodegenHandler(fsHandler *FileSystemHandler) *CodegenHandler {
 	return &CodegenHandler{
 		FileSystem: fsHandler,
+		newClient:  codegen.NewClient,
 	}
 }
 
@@ -61,6 +69,7 @@ func (h *CodegenHandler) extractPathFromRequest(c *gin.Context) string {
 type ApplyEditRequest struct {
 	CodeEdit string `json:"codeEdit" binding:"required" example:"// Add world parameter\nfunction hello(world) {\n  console.log('Hello', world);\n}"`
 	Model    string `json:"model,omitempty" example:"auto"`
+	Preview  bool   `json:"preview,omitempty" example:"false"`
 } // @name ApplyEditRequest
 
 // ApplyEditResponse represents the response for applying code edits
@@ -71,6 +80,12 @@ type ApplyEditResponse struct {
 	UpdatedContent  string `json:"updatedContent" example:"function hello(world) {\n  console.log('Hello', world);\n}"`
 	Provider        string `json:"provider" example:"Relace"`
 	Message         string `json:"message,omitempty" example:"Code edit applied successfully"`
+	// Set for previews only: the edit to review and the ID to apply it with
+	DiffID string             `json:"diffId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Diff   string             `json:"diff,omitempty" example:"--- a/src/main.js\n+++ b/src/main.js\n@@ -1,3 +1,3 @@\n-function hello() {\n+function hello(world) {"`
+	Hunks  []codegen.DiffHunk `json:"hunks,omitempty"`
+	// ExpiresAt is when a preview can no longer be applied
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 } // @name ApplyEditResponse
 
 // HandleFastApply applies a code edit using the configured LLM provider
@@ -99,11 +114,14 @@ type ApplyEditResponse struct {
 // @Description If you plan on deleting a section, you must provide context before and after to delete it. If the initial code is "Block 1\nBlock 2\nBlock 3", and you want to remove Block 2, you would output "// ... existing code ...\nBlock 1\nBlock 3\n// ... existing code ...".
 // @Description
 // @Description Make sure it is clear what the edit should be, and where it should be applied. Make edits to a file in a single edit_file call instead of multiple edit_file calls to the same file. The apply model can handle many distinct edits at once.
+// @Description
+// @Description With preview set, the file is not written: the response contains the unified diff and hunks of the edit, and a diffId to apply it with POST /codegen/apply/{diffId} once reviewed.
 // @Tags fastapply
 // @Accept json
 // @Produce json
 // @Param path path string true "Path to the file to edit (relative to workspace)"
 // @Param request body ApplyEditRequest true "Code edit request"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
 // @Success 200 {object} ApplyEditResponse "Code edit applied successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity - failed to process the request"
@@ -150,6 +168,11 @@ func (h *CodegenHandler) HandleFastApply(c *gin.Context) {
 		return
 	}
 
+	// Previews do not write the file, the lock is checked when they are applied
+	if !req.Preview && !h.FileSystem.checkLocks(c, filePath) {
+		return
+	}
+
 	// Check if file exists and read its content
 	fileExists, err := h.FileSystem.FileExists(filePath)
 	if err != nil {
@@ -168,7 +191,7 @@ func (h *CodegenHandler) HandleFastApply(c *gin.Context) {
 	}
 
 	// Create client
-	client, err := codegen.NewClient()
+	client, err := h.newClient()
 	if err != nil {
 		logrus.Errorf("Failed to create fastapply client: %v", err)
 		h.SendError(c, http.StatusUnprocessableEntity, err)
@@ -188,13 +211,33 @@ func (h *CodegenHandler) HandleFastApply(c *gin.Context) {
 		return
 	}
 
+	// Keep the edit to be applied once its diff is reviewed
+	if req.Preview {
+		preview := &codegen.Preview{
+			Path:     filePath,
+			Exists:   fileExists,
+			Original: originalContent,
+			Updated:  updatedContent,
+			Provider: client.ProviderName(),
+		}
+		codegen.GetPreviewStore().Add(preview)
+		c.JSON(http.StatusOK, h.previewResponse(preview))
+		return
+	}
+
 	// Write the updated content back to the file
+	owner, ok := h.FileSystem.fileOwner(c)
+	if !ok {
+		return
+	}
+	created := owner.track(filePath)
 	err = h.FileSystem.WriteFile(filePath, []byte(updatedContent), 0644)
 	if err != nil {
 		logrus.Errorf("Failed to write file: %v", err)
 		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("failed to write file: %w", err))
 		return
 	}
+	created()
 
 	// Return the result
 	c.JSON(http.StatusOK, ApplyEditResponse{
@@ -207,6 +250,256 @@ func (h *CodegenHandler) HandleFastApply(c *gin.Context) {
 	})
 }
 
+// previewResponse returns the response of a previewed edit, with its diff
+func (h *CodegenHandler) previewResponse(preview *codegen.Preview) ApplyEditResponse {
+	diff, hunks := codegen.Diff(preview.Path, preview.Original, preview.Updated)
+	return ApplyEditResponse{
+		Success:         true,
+		Path:            preview.Path,
+		OriginalContent: preview.Original,
+		UpdatedContent:  preview.Updated,
+		Provider:        preview.Provider,
+		Message:         fmt.Sprintf("Code edit previewed for %s, apply it with POST /codegen/apply/%s", preview.Path, preview.ID),
+		DiffID:          preview.ID,
+		Diff:            diff,
+		Hunks:           hunks,
+		ExpiresAt:       &preview.ExpiresAt,
+	}
+}
+
+// HandleApplyDiff applies a previewed code edit
+// @Summary Apply previewed code edit
+// @Description Writes a code edit previewed with PUT /codegen/fastapply/{path} and preview set. The edit is rejected if the file changed since the preview. A preview can be applied once, and expires after 15 minutes.
+// @Tags fastapply
+// @Produce json
+// @Param diffId path string true "ID of the previewed edit"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
+// @Success 200 {object} ApplyEditResponse "Code edit applied successfully"
+// @Failure 404 {object} ErrorResponse "Preview not found or expired"
+// @Failure 409 {object} ErrorResponse "File changed since the preview"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity - failed to write the file"
+// @Router /codegen/apply/{diffId} [post]
+func (h *CodegenHandler) HandleApplyDiff(c *gin.Context) {
+	diffID := c.Param("diffId")
+	preview, exists := codegen.GetPreviewStore().Take(diffID)
+	if !exists {
+		h.SendError(c, http.StatusNotFound, fmt.Errorf("preview %s not found or expired", diffID))
+		return
+	}
+	if !h.FileSystem.checkLocks(c, preview.Path) {
+		return
+	}
+
+	// The edit was computed from the content at preview time
+	fileExists, err := h.FileSystem.FileExists(preview.Path)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("failed to check if file exists: %w", err))
+		return
+	}
+	currentContent := ""
+	if fileExists {
+		file, err := h.FileSystem.ReadFile(preview.Path)
+		if err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("failed to read file: %w", err))
+			return
+		}
+		currentContent = string(file.Content)
+	}
+	if fileExists != preview.Exists || currentContent != preview.Original {
+		h.SendError(c, http.StatusConflict, fmt.Errorf("file %s changed since the preview, preview the edit again", preview.Path))
+		return
+	}
+
+	owner, ok := h.FileSystem.fileOwner(c)
+	if !ok {
+		return
+	}
+	created := owner.track(preview.Path)
+	if err := h.FileSystem.WriteFile(preview.Path, []byte(preview.Updated), 0644); err != nil {
+		logrus.Errorf("Failed to write file: %v", err)
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("failed to write file: %w", err))
+		return
+	}
+	created()
+
+	c.JSON(http.StatusOK, ApplyEditResponse{
+		Success:         true,
+		Path:            preview.Path,
+		OriginalContent: preview.Original,
+		UpdatedContent:  preview.Updated,
+		Provider:        preview.Provider,
+		Message:         fmt.Sprintf("Code edit applied successfully to %s", preview.Path),
+	})
+}
+
+// batchApplyConcurrency is the number of edits of a batch applied by the provider at once
+const batchApplyConcurrency = 4
+
+// BatchEdit is the edit of a file of a batch
+type BatchEdit struct {
+	Path     string `json:"path" binding:"required" example:"src/main.js"`
+	CodeEdit string `json:"codeEdit" binding:"required" example:"// ... existing code ...\nfunction hello(world) {\n// ... existing code ..."`
+	Model    string `json:"model,omitempty" example:"auto"`
+} // @name BatchEdit
+
+// ApplyBatchRequest represents the request body for applying edits to several files
+type ApplyBatchRequest struct {
+	Edits []BatchEdit `json:"edits" binding:"required"`
+} // @name ApplyBatchRequest
+
+// ApplyBatchResponse represents the response for applying edits to several files
+type ApplyBatchResponse struct {
+	Success  bool                `json:"success" example:"true"`
+	Files    []ApplyEditResponse `json:"files"`
+	Provider string              `json:"provider" example:"Relace"`
+	Message  string              `json:"message,omitempty" example:"Code edits applied successfully to 2 files"`
+} // @name ApplyBatchResponse
+
+// HandleApplyBatch applies code edits to several files at once
+// @Summary Apply code edits to several files
+// @Description Uses the configured LLM provider (Relace or Morph) to apply an edit to each file, in the same format as PUT /codegen/fastapply/{path}, then writes all files at once.
+// @Description
+// @Description The batch is all-or-nothing: when the provider fails to apply any edit, no file is written, and when writing any file fails, the files already written are restored. Each file can only be edited once per batch.
+// @Tags fastapply
+// @Accept json
+// @Produce json
+// @Param request body ApplyBatchRequest true "Code edits to apply"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
+// @Success 200 {object} ApplyBatchResponse "Code edits applied successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "An edit failed and no file was modified"
+// @Router /codegen/apply-batch [post]
+func (h *CodegenHandler) HandleApplyBatch(c *gin.Context) {
+	if !codegen.IsEnabled() {
+		h.SendError(c, http.StatusBadRequest,
+			fmt.Errorf("codegen tools are not configured, follow this documentation to configure it: https://docs.blaxel.ai/Sandboxes/Codegen"))
+		return
+	}
+
+	var req ApplyBatchRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Edits) == 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("at least one edit is required"))
+		return
+	}
+	owner, ok := h.FileSystem.fileOwner(c)
+	if !ok {
+		return
+	}
+
+	// Read every file before asking the provider for any edit
+	files := make([]ApplyEditResponse, len(req.Edits))
+	operations := make([]filesystem.BatchOperation, len(req.Edits))
+	edited := make(map[string]bool, len(req.Edits))
+	for i, edit := range req.Edits {
+		path, err := lib.FormatPath(edit.Path)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		if path == "" {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("edit %d: file path is required", i))
+			return
+		}
+		if edited[path] {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("edit %d: %s is edited more than once, combine its edits", i, path))
+			return
+		}
+		edited[path] = true
+
+		isDir, err := h.FileSystem.DirectoryExists(path)
+		if err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("edit %d: failed to check path: %w", i, err))
+			return
+		}
+		if isDir {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("edit %d: %s is a directory, not a file", i, path))
+			return
+		}
+		fileExists, err := h.FileSystem.FileExists(path)
+		if err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("edit %d: failed to check if file exists: %w", i, err))
+			return
+		}
+
+		files[i].Path = path
+		operations[i] = filesystem.BatchOperation{Operation: filesystem.BatchWrite, Path: path}
+		if fileExists {
+			file, err := h.FileSystem.ReadFile(path)
+			if err != nil {
+				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("edit %d: failed to read file: %w", i, err))
+				return
+			}
+			files[i].OriginalContent = string(file.Content)
+			// Rewritten files keep their permissions
+			operations[i].Permissions = fmt.Sprintf("%o", file.Permissions.Perm())
+		}
+	}
+
+	if err := h.FileSystem.fs.CheckBatchLocks(lockHolder(c), operations); err != nil {
+		h.SendError(c, http.StatusLocked, err)
+		return
+	}
+
+	client, err := h.newClient()
+	if err != nil {
+		logrus.Errorf("Failed to create fastapply client: %v", err)
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	logrus.Infof("Applying code edits to %d files using %s provider", len(req.Edits), client.ProviderName())
+	errs := make([]error, len(req.Edits))
+	sem := make(chan struct{}, batchApplyConcurrency)
+	var wg sync.WaitGroup
+	for i, edit := range req.Edits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			model := edit.Model
+			if model == "" {
+				model = "auto"
+			}
+			files[i].UpdatedContent, errs[i] = client.ApplyCodeEdit(files[i].OriginalContent, edit.CodeEdit, model)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			logrus.Errorf("Failed to apply code edit to %s: %v", files[i].Path, err)
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("edit %d (%s) failed, no file was modified: %w", i, files[i].Path, err))
+			return
+		}
+	}
+
+	for i := range operations {
+		operations[i].Content = files[i].UpdatedContent
+	}
+	if err := h.FileSystem.ApplyBatch(operations, owner); err != nil {
+		logrus.Errorf("Failed to write code edits: %v", err)
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("failed to write files, the batch was rolled back: %w", err))
+		return
+	}
+
+	for i := range files {
+		files[i].Success = true
+		files[i].Provider = client.ProviderName()
+		files[i].Message = fmt.Sprintf("Code edit applied successfully to %s", files[i].Path)
+	}
+	c.JSON(http.StatusOK, ApplyBatchResponse{
+		Success:  true,
+		Files:    files,
+		Provider: client.ProviderName(),
+		Message:  fmt.Sprintf("Code edits applied successfully to %d files", len(files)),
+	})
+}
+
 // RerankingRequest represents the query parameters for code reranking
 type RerankingRequest struct {
 	Query          string  `form:"query" binding:"required" example:"user authentication middleware"`
@@ -276,17 +569,6 @@ func (h *CodegenHandler) HandleReranking(c *gin.Context) {
 		return
 	}
 
-	// Set defaults
-	scoreThreshold := req.ScoreThreshold
-	if scoreThreshold == 0 {
-		scoreThreshold = 0.5
-	}
-
-	tokenLimit := req.TokenLimit
-	if tokenLimit == 0 {
-		tokenLimit = 30000
-	}
-
 	// Check if directory exists
 	isDir, err := h.FileSystem.DirectoryExists(directory)
 	if err != nil {
@@ -299,7 +581,7 @@ func (h *CodegenHandler) HandleReranking(c *gin.Context) {
 	}
 
 	// Create client
-	client, err := codegen.NewClient()
+	client, err := h.newClient()
 	if err != nil {
 		logrus.Errorf("Failed to create fastapply client: %v", err)
 		h.SendError(c, http.StatusUnprocessableEntity, err)
@@ -307,47 +589,20 @@ func (h *CodegenHandler) HandleReranking(c *gin.Context) {
 	}
 
 	// Check if the client supports reranking (only Relace does)
-	reranker, ok := client.(codegen.CodeReranker)
-	if !ok {
+	if _, ok := client.(codegen.CodeReranker); !ok {
 		h.SendError(c, http.StatusServiceUnavailable,
 			fmt.Errorf("code reranking is only available with Relace. Set RELACE_API_KEY to use this feature"))
 		return
 	}
 
-	// Collect documents from the directory
-	documents, err := h.collectDocumentsFromDirectory(directory, req.FilePattern)
-	if err != nil {
-		logrus.Errorf("Failed to collect documents: %v", err)
-		h.SendError(c, http.StatusUnprocessableEntity, err)
-		return
-	}
-
-	if len(documents) == 0 {
-		c.JSON(http.StatusOK, RerankingResponse{
-			Success: true,
-			Files:   []RankedFile{},
-			Message: "No files found matching criteria",
-		})
-		return
-	}
-
-	// Perform reranking
-	logrus.Infof("Performing code reranking on %d files using %s", len(documents), client.ProviderName())
-	rankedFiles, err := reranker.RerankCode(documents, req.Query, tokenLimit)
+	// Rank the files matched by the workspace index, or every file of the directory
+	filteredFiles, err := h.RerankFiles(c.Request.Context(), directory, req, func([]RankedFile) {})
 	if err != nil {
 		logrus.Errorf("Failed to rerank code: %v", err)
 		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	// Filter by score threshold
-	filteredFiles := make([]RankedFile, 0)
-	for _, file := range rankedFiles {
-		if file.Score >= scoreThreshold {
-			filteredFiles = append(filteredFiles, file)
-		}
-	}
-
 	// Return the result
 	c.JSON(http.StatusOK, RerankingResponse{
 		Success: true,
@@ -356,91 +611,176 @@ func (h *CodegenHandler) HandleReranking(c *gin.Context) {
 	})
 }
 
-// collectDocumentsFromDirectory walks a directory and collects all eligible code files
-func (h *CodegenHandler) collectDocumentsFromDirectory(directory, filePattern string) ([]codegen.CodebaseDocument, error) {
-	// Compile regex pattern if provided
+// isBinaryFile checks if a file is binary by looking for null bytes
+// and checking the ratio of non-printable characters
+func (h *CodegenHandler) isBinaryFile(content []byte) bool {
+	return false
+}
+
+const (
+	// rerankBatchSize is the number of files sent per request to the reranking provider
+	// by RerankFiles
+	rerankBatchSize = 50
+	// maxRerankedFiles bounds the number of files of a directory ranked by RerankFiles
+	maxRerankedFiles = 5000
+	// maxIndexedCandidates is the number of files matched by the workspace index ranked by
+	// RerankFiles
+	maxIndexedCandidates = 500
+	// defaultScoreThreshold and defaultTokenLimit apply when a reranking request leaves
+	// them unset
+	defaultScoreThreshold = 0.5
+	defaultTokenLimit     = 30000
+)
+
+// rerankSkippedDirs are dependency and build directories whose files are not ranked
+var rerankSkippedDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// RerankFiles ranks the files of a directory by relevance to the query, sending them to
+// the reranking provider in batches. The files of each batch scoring at least the score
+// threshold are passed to partial as soon as the batch is ranked. Hidden files, files
+// ignored by the .gitignore of the directory and files of dependency and build
+// directories are skipped, and once the workspace index is built only the files it
+// matches with the query are ranked. It returns the retained files from most to least relevant,
+// within the token limit of the request.
+func (h *CodegenHandler) RerankFiles(ctx context.Context, directory string, req RerankingRequest, partial func([]RankedFile)) ([]RankedFile, error) {
+	if !codegen.IsEnabled() {
+		return nil, fmt.Errorf("codegen tools are not configured, follow this documentation to configure it: https://docs.blaxel.ai/Sandboxes/Codegen")
+	}
+	if req.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	scoreThreshold := req.ScoreThreshold
+	if scoreThreshold == 0 {
+		scoreThreshold = defaultScoreThreshold
+	}
+	tokenLimit := req.TokenLimit
+	if tokenLimit == 0 {
+		tokenLimit = defaultTokenLimit
+	}
 	var fileRegex *regexp.Regexp
-	if filePattern != "" {
+	if req.FilePattern != "" {
 		var err error
-		fileRegex, err = regexp.Compile(filePattern)
+		fileRegex, err = regexp.Compile(req.FilePattern)
 		if err != nil {
 			return nil, fmt.Errorf("invalid file pattern regex: %w", err)
 		}
 	}
 
-	documents := []codegen.CodebaseDocument{}
+	directory, err := lib.FormatPath(directory)
+	if err != nil {
+		return nil, err
+	}
+	if directory == "" {
+		directory = "."
+	}
+	isDir, err := h.FileSystem.DirectoryExists(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check directory: %w", err)
+	}
+	if !isDir {
+		return nil, fmt.Errorf("path is not a directory: %s", directory)
+	}
 
-	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	client, err := h.newClient()
+	if err != nil {
+		return nil, err
+	}
+	reranker, ok := client.(codegen.CodeReranker)
+	if !ok {
+		return nil, fmt.Errorf("current provider (%s) does not support reranking", client.ProviderName())
+	}
 
-		// Skip directories
-		if d.IsDir() {
-			return nil
-		}
+	root, err := h.FileSystem.fs.GetAbsolutePath(directory)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := h.rerankCandidates(root, req.Query, fileRegex)
+	if err != nil {
+		return nil, err
+	}
 
-		fileSplittered := strings.Split(path, "/")
-		filename := fileSplittered[len(fileSplittered)-1]
-		// Skip hidden files and common directories to ignore
-		if h.shouldSkipFile(path) {
-			return nil
+	logrus.Infof("Performing code reranking on %d files using %s", len(paths), client.ProviderName())
+	ranked := make([]RankedFile, 0)
+	for start := 0; start < len(paths); start += rerankBatchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
-		// Apply file pattern filter if provided
-		if fileRegex != nil && !fileRegex.MatchString(path) && !fileRegex.MatchString(filename) {
-			return nil
+		// Files are read one batch at a time to bound memory
+		documents := make([]codegen.CodebaseDocument, 0, rerankBatchSize)
+		for _, path := range paths[start:min(start+rerankBatchSize, len(paths))] {
+			content, err := os.ReadFile(path)
+			if err != nil || h.isBinaryFile(content) {
+				continue
+			}
+			documents = append(documents, codegen.CodebaseDocument{Path: path, Content: string(content)})
 		}
-
-		// Read file content
-		content, err := os.ReadFile(path)
-		if err != nil {
-			// Skip files we can't read
-			return nil
+		if len(documents) == 0 {
+			continue
 		}
 
-		// Skip binary files
-		if h.isBinaryFile(content) {
-			return nil
+		files, err := reranker.RerankCode(documents, req.Query, tokenLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rerank documents: %w", err)
 		}
-
-		// Add to documents
-		documents = append(documents, codegen.CodebaseDocument{
-			Path:    path,
-			Content: string(content),
+		files = slices.DeleteFunc(files, func(file RankedFile) bool {
+			return file.Score < scoreThreshold
 		})
+		if len(files) > 0 {
+			partial(files)
+			ranked = append(ranked, files...)
+		}
+	}
 
-		return nil
+	// Each batch is within the token limit, the files of all batches must be as well
+	slices.SortStableFunc(ranked, func(a, b RankedFile) int {
+		return cmp.Compare(b.Score, a.Score)
 	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
+	tokens := 0
+	for i, file := range ranked {
+		tokens += estimateTokens(file.Content)
+		if tokens > tokenLimit {
+			return ranked[:i], nil
+		}
 	}
-
-	return documents, nil
+	return ranked, nil
 }
 
-// shouldSkipFile determines if a file should be skipped during directory traversal
-func (h *CodegenHandler) shouldSkipFile(path string) bool {
-	// Skip hidden files and directories
-	base := filepath.Base(path)
-	if len(base) > 0 && base[0] == '.' {
-		return true
-	}
-
-	// Skip common directories to ignore
-	skipDirs := []string{"node_modules", "vendor", ".git", "dist", "build", "target", "__pycache__", ".venv"}
-	for _, dir := range skipDirs {
-		if filepath.Base(filepath.Dir(path)) == dir {
-			return true
+// rerankCandidates returns the files of a directory to rank. Once the workspace index is
+// built, only the files it matches with the query are ranked, from most to least
+// relevant, rather than every file of the directory.
+func (h *CodegenHandler) rerankCandidates(root, query string, fileRegex *regexp.Regexp) ([]string, error) {
+	workspaceIndex := GetWorkspaceIndex()
+	if workspaceIndex.Ready() && !isSkippedPath(workspaceIndex.Root(), root) {
+		results := workspaceIndex.Search(query, index.SearchOptions{
+			Limit:       maxIndexedCandidates,
+			Directory:   root,
+			FilePattern: fileRegex,
+		})
+		if len(results) > 0 {
+			paths := make([]string, 0, len(results))
+			for _, result := range results {
+				paths = append(paths, result.File)
+			}
+			return paths, nil
 		}
 	}
 
-	return false
+	paths, truncated, err := h.FileSystem.ListFiles(root, maxRerankedFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	if truncated {
+		logrus.Warnf("Reranking only the first %d files of %s", maxRerankedFiles, root)
+	}
+	return slices.DeleteFunc(withoutSkippedPaths(root, paths), func(path string) bool {
+		return fileRegex != nil && !fileRegex.MatchString(path) && !fileRegex.MatchString(filepath.Base(path))
+	}), nil
 }
 
-// isBinaryFile checks if a file is binary by looking for null bytes
-// and checking the ratio of non-printable characters
-func (h *CodegenHandler) isBinaryFile(content []byte) bool {
-	return false
+// estimateTokens approximates the number of tokens of a text, about four bytes each
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
 }
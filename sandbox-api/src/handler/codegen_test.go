@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
+)
+
+// newCodegenRouter returns a router serving the fastapply routes of a codegen handler
+func newCodegenRouter(t *testing.T) (*gin.Engine, *CodegenHandler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("MORPH_API_KEY", "test")
+	t.Setenv("RELACE_API_KEY", "")
+
+	h := NewCodegenHandler(NewFileSystemHandler())
+	router := gin.New()
+	router.PUT("/codegen/fastapply/*path", h.HandleFastApply)
+	router.POST("/codegen/apply/:diffId", h.HandleApplyDiff)
+	router.POST("/codegen/apply-batch", h.HandleApplyBatch)
+	return router, h
+}
+
+// serveJSON sends a request with a JSON body to router, as holder when not empty
+func serveJSON(router *gin.Engine, method string, url string, body any, holder string) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, url, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if holder != "" {
+		req.Header.Set(LockHolderHeader, holder)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// lockPath locks an absolute path for holder, enforcing the lock, until the test ends
+func lockPath(t *testing.T, path string, holder string) {
+	t.Helper()
+	if _, err := filesystem.GetLockTable().Acquire(path, holder, time.Minute, true); err != nil {
+		t.Fatalf("Failed to lock %s: %v", path, err)
+	}
+	t.Cleanup(func() { _ = filesystem.GetLockTable().Release(path, holder) })
+}
+
+// assertContent fails the test when the file at path does not hold content
+func assertContent(t *testing.T, path string, content string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if string(data) != content {
+		t.Errorf("Expected %s to hold %q, got %q", path, content, data)
+	}
+}
+
+// TestCodegenLocks tests that the fastapply routes reject writes to paths locked by
+// another holder
+func TestCodegenLocks(t *testing.T) {
+	router, _ := newCodegenRouter(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	lockPath(t, dir, "agent-1")
+
+	t.Run("FastApply", func(t *testing.T) {
+		w := serveJSON(router, http.MethodPut, "/codegen/fastapply%2F"+path[1:], ApplyEditRequest{CodeEdit: "package app\n"}, "agent-2")
+		if w.Code != http.StatusLocked {
+			t.Errorf("Expected status 423, got %d: %s", w.Code, w.Body.String())
+		}
+		assertContent(t, path, "package main\n")
+	})
+
+	t.Run("ApplyDiff", func(t *testing.T) {
+		preview := &codegen.Preview{Path: path, Exists: true, Original: "package main\n", Updated: "package app\n"}
+		codegen.GetPreviewStore().Add(preview)
+		w := serveJSON(router, http.MethodPost, "/codegen/apply/"+preview.ID, nil, "agent-2")
+		if w.Code != http.StatusLocked {
+			t.Errorf("Expected status 423, got %d: %s", w.Code, w.Body.String())
+		}
+		assertContent(t, path, "package main\n")
+
+		// The holder of the lock applies its own previews
+		codegen.GetPreviewStore().Add(preview)
+		w = serveJSON(router, http.MethodPost, "/codegen/apply/"+preview.ID, nil, "agent-1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		assertContent(t, path, "package app\n")
+	})
+
+	t.Run("ApplyBatch", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "other.go")
+		req := ApplyBatchRequest{Edits: []BatchEdit{
+			{Path: other, CodeEdit: "package other\n"},
+			{Path: path, CodeEdit: "package batch\n"},
+		}}
+		w := serveJSON(router, http.MethodPost, "/codegen/apply-batch", req, "agent-2")
+		if w.Code != http.StatusLocked {
+			t.Errorf("Expected status 423, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, err := os.Stat(other); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be written, got %v", other, err)
+		}
+	})
+}
+
+// stubClient is a fastapply provider applying edits with a function
+type stubClient struct {
+	apply func(originalContent string, codeEdit string) (string, error)
+}
+
+func (s *stubClient) ApplyCodeEdit(originalContent, codeEdit, model string) (string, error) {
+	return s.apply(originalContent, codeEdit)
+}
+
+func (s *stubClient) ProviderName() string {
+	return "Stub"
+}
+
+// TestApplyBatchRollback tests that a batch leaves every file untouched when one of
+// its edits or writes fails
+func TestApplyBatchRollback(t *testing.T) {
+	router, h := newCodegenRouter(t)
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.go")
+	second := filepath.Join(dir, "second.go")
+	created := filepath.Join(dir, "created.go")
+	for _, path := range []string{first, second} {
+		if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	t.Run("EditFails", func(t *testing.T) {
+		h.newClient = func() (codegen.Client, error) {
+			return &stubClient{apply: func(originalContent string, codeEdit string) (string, error) {
+				if codeEdit == "fail" {
+					return "", errors.New("provider error")
+				}
+				return codeEdit, nil
+			}}, nil
+		}
+		req := ApplyBatchRequest{Edits: []BatchEdit{
+			{Path: first, CodeEdit: "package first\n"},
+			{Path: second, CodeEdit: "fail"},
+			{Path: created, CodeEdit: "package created\n"},
+		}}
+		w := serveJSON(router, http.MethodPost, "/codegen/apply-batch", req, "")
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "no file was modified") {
+			t.Errorf("Expected status 422, got %d: %s", w.Code, w.Body.String())
+		}
+		assertContent(t, first, "package main\n")
+		assertContent(t, second, "package main\n")
+		if _, err := os.Stat(created); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be created, got %v", created, err)
+		}
+	})
+
+	t.Run("WriteFails", func(t *testing.T) {
+		// A directory appears where the last file is written, once the files were read
+		h.newClient = func() (codegen.Client, error) {
+			return &stubClient{apply: func(originalContent string, codeEdit string) (string, error) {
+				if err := os.MkdirAll(created, 0755); err != nil {
+					return "", err
+				}
+				return codeEdit, nil
+			}}, nil
+		}
+		t.Cleanup(func() { _ = os.RemoveAll(created) })
+		req := ApplyBatchRequest{Edits: []BatchEdit{
+			{Path: first, CodeEdit: "package first\n"},
+			{Path: second, CodeEdit: "package second\n"},
+			{Path: created, CodeEdit: "package created\n"},
+		}}
+		w := serveJSON(router, http.MethodPost, "/codegen/apply-batch", req, "")
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "rolled back") {
+			t.Errorf("Expected status 422, got %d: %s", w.Code, w.Body.String())
+		}
+		assertContent(t, first, "package main\n")
+		assertContent(t, second, "package main\n")
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to read directory: %v", err)
+		}
+		if len(entries) != 3 {
+			t.Errorf("Expected the batch to leave no file behind, got %d entries", len(entries))
+		}
+	})
+}
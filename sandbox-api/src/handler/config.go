@@ -0,0 +1,325 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/logsink"
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/cors"
+)
+
+// ConfigHandler handles the sandbox-level settings
+type ConfigHandler struct {
+	*BaseHandler
+}
+
+// NewConfigHandler creates a new config handler
+func NewConfigHandler() *ConfigHandler {
+	return &ConfigHandler{
+		BaseHandler: NewBaseHandler(),
+	}
+}
+
+// WorkingDirRequest is the request body for changing the default working directory
+type WorkingDirRequest struct {
+	Path string `json:"path" example:"/home/user/app" binding:"required"`
+} // @name WorkingDirRequest
+
+// WorkingDirResponse is the default working directory of the sandbox
+type WorkingDirResponse struct {
+	WorkingDir string `json:"workingDir" example:"/home/user/app" binding:"required"`
+	Source     string `json:"source" example:"config" enums:"env,cwd,config" binding:"required"`
+	// Resolved is the path given in the query resolved against the working directory
+	Resolved string `json:"resolved,omitempty" example:"/home/user/app/src"`
+} // @name WorkingDirResponse
+
+// HandleGetWorkingDir handles GET requests to /config/workdir
+// @Summary Get the default working directory
+// @Description Get the default working directory of the sandbox and where it comes from: the WORKDIR environment variable (env), the directory the server started in (cwd), or POST /config/workdir (config). Relative filesystem paths and processes started without a working directory, from the REST API, MCP tools and WebSocket operations, resolve against it. With path, also resolve that path against it.
+// @Tags config
+// @Produce json
+// @Param path query string false "Path to resolve against the working directory"
+// @Success 200 {object} WorkingDirResponse "Default working directory"
+// @Failure 400 {object} ErrorResponse "Invalid path"
+// @Router /config/workdir [get]
+func (h *ConfigHandler) HandleGetWorkingDir(c *gin.Context) {
+	workingDir, source := lib.WorkingDirWithSource()
+	response := WorkingDirResponse{WorkingDir: workingDir, Source: source}
+	if path := c.Query("path"); path != "" {
+		resolved, err := lib.ResolveWorkingDir(path)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		response.Resolved = resolved
+	}
+	h.SendJSON(c, http.StatusOK, response)
+}
+
+// HandleSetWorkingDir handles POST requests to /config/workdir
+// @Summary Set the default working directory
+// @Description Change the default working directory of the sandbox. A relative path resolves against the current one, and the directory must exist. Running processes are not affected.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Param request body WorkingDirRequest true "Working directory"
+// @Success 200 {object} WorkingDirResponse "Default working directory"
+// @Failure 400 {object} ErrorResponse "Invalid or missing directory"
+// @Router /config/workdir [post]
+func (h *ConfigHandler) HandleSetWorkingDir(c *gin.Context) {
+	var req WorkingDirRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := lib.SetWorkingDir(req.Path); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	workingDir, source := lib.WorkingDirWithSource()
+	h.SendJSON(c, http.StatusOK, WorkingDirResponse{WorkingDir: workingDir, Source: source})
+}
+
+// LogSinksRequest is the request body for replacing the log sinks
+type LogSinksRequest struct {
+	Sinks []logsink.SinkConfig `json:"sinks"`
+} // @name LogSinksRequest
+
+// LogSinksResponse is the log sinks process output is forwarded to
+type LogSinksResponse struct {
+	Sinks  []logsink.SinkStatus `json:"sinks" binding:"required"`
+	Source string               `json:"source" example:"api" enums:"none,env,api" binding:"required"`
+} // @name LogSinksResponse
+
+func logSinksResponse() LogSinksResponse {
+	sinks, source := logsink.GetForwarder().Sinks()
+	return LogSinksResponse{Sinks: sinks, Source: source}
+}
+
+// HandleGetLogSinks handles GET requests to /config/log-sinks
+// @Summary Get the log sinks
+// @Description Get the sinks the output of every managed process is forwarded to, with the number of lines sent and dropped, and where they come from: the LOG_SINKS environment variable (env, a JSON array of sinks) or PUT /config/log-sinks (api). Header values are redacted.
+// @Tags config
+// @Produce json
+// @Success 200 {object} LogSinksResponse "Log sinks"
+// @Router /config/log-sinks [get]
+func (h *ConfigHandler) HandleGetLogSinks(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, logSinksResponse())
+}
+
+// HandleSetLogSinks handles PUT requests to /config/log-sinks
+// @Summary Replace the log sinks
+// @Description Replace the sinks the output of every managed process is forwarded to, line by line, with the sandbox, process and stream labels: syslog (RFC 5424 over udp://, tcp:// or unix://), http (JSON arrays of lines POSTed to the URL) or loki (push API). Lines are sent in batches every second; they are dropped when a sink does not keep up or keeps failing. An empty list stops forwarding.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Param request body LogSinksRequest true "Log sinks"
+// @Success 200 {object} LogSinksResponse "Log sinks"
+// @Failure 400 {object} ErrorResponse "Invalid log sink"
+// @Router /config/log-sinks [put]
+func (h *ConfigHandler) HandleSetLogSinks(c *gin.Context) {
+	var req LogSinksRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := logsink.GetForwarder().SetSinks(req.Sinks); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, logSinksResponse())
+}
+
+// StartupCommandResponse is the startup command and its process
+type StartupCommandResponse struct {
+	process.StartupCommand
+	// Process is the startup process, absent when there is no startup command
+	Process *ProcessResponse `json:"process,omitempty"`
+} // @name StartupCommandResponse
+
+func startupCommandResponse() StartupCommandResponse {
+	startup, p := process.GetProcessManager().StartupCommand()
+	response := StartupCommandResponse{StartupCommand: startup}
+	if p != nil {
+		processResponse := newProcessResponse(p)
+		response.Process = &processResponse
+	}
+	return response
+}
+
+// HandleGetStartupCommand handles GET requests to /config/startup-command
+// @Summary Get the startup command
+// @Description Get the command run when the sandbox starts, given with the -c flag of the API or set with PUT /config/startup-command, and its process. The startup command runs as the managed process named startup, so its logs, status, restarts and stop/kill are also available through the /process/startup endpoints.
+// @Tags config
+// @Produce json
+// @Success 200 {object} StartupCommandResponse "Startup command"
+// @Router /config/startup-command [get]
+func (h *ConfigHandler) HandleGetStartupCommand(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, startupCommandResponse())
+}
+
+// HandleSetStartupCommand handles PUT requests to /config/startup-command
+// @Summary Replace the startup command
+// @Description Replace the command run when the sandbox starts. The process of the previous startup command is stopped, and killed if still running after 10 seconds, then the new command is started as the startup process. An empty command only stops the previous one. The startup command given with the -c flag runs again when the API restarts.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Param request body process.StartupCommand true "Startup command"
+// @Success 200 {object} StartupCommandResponse "Startup command"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 403 {object} ErrorResponse "Command denied by the process policy"
+// @Failure 422 {object} ErrorResponse "Command could not be started"
+// @Router /config/startup-command [put]
+func (h *ConfigHandler) HandleSetStartupCommand(c *gin.Context) {
+	var req process.StartupCommand
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := process.NewRestartConfig(req.RestartPolicy, false, req.MaxRestarts, nil, 0); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	_, err := process.GetProcessManager().SetStartupCommand(req)
+	if errors.Is(err, policy.ErrDenied) {
+		h.SendError(c, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, startupCommandResponse())
+}
+
+// StartupCommandsRequest is the request body for replacing the startup commands
+type StartupCommandsRequest struct {
+	Commands []process.GroupProcessSpec `json:"commands"`
+} // @name StartupCommandsRequest
+
+// StartupCommandsResponse is the startup commands and the state of their processes
+type StartupCommandsResponse struct {
+	Commands []process.GroupProcessSpec `json:"commands" binding:"required"`
+	// Group is the startup process group, absent when the commands are not running from this run of the API
+	Group *process.GroupInfo `json:"group,omitempty"`
+} // @name StartupCommandsResponse
+
+func startupCommandsResponse() StartupCommandsResponse {
+	commands, group := process.GetProcessManager().StartupCommands()
+	response := StartupCommandsResponse{Commands: commands}
+	if group != nil {
+		info := group.Info()
+		response.Group = &info
+	}
+	return response
+}
+
+// HandleGetStartupCommands handles GET requests to /config/startup-commands
+// @Summary Get the startup commands
+// @Description Get the commands run when the sandbox starts, from the STARTUP_COMMANDS environment variable (a JSON array), the JSON or YAML file of STARTUP_FILE, or PUT /config/startup-commands, and the state of their processes. They run as the process group named startup, each command as the process startup-<name>.
+// @Tags config
+// @Produce json
+// @Success 200 {object} StartupCommandsResponse "Startup commands"
+// @Router /config/startup-commands [get]
+func (h *ConfigHandler) HandleGetStartupCommands(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, startupCommandsResponse())
+}
+
+// HandleSetStartupCommands handles PUT requests to /config/startup-commands
+// @Summary Replace the startup commands
+// @Description Replace the commands run when the sandbox starts. The processes of the previous ones are stopped, and killed if still running after 10 seconds, then the new ones are started like a process group: each once the commands it depends on are ready, with its own readiness check and restart policy. An empty list only stops the previous ones. The startup commands of the environment run again when the API restarts.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Param request body StartupCommandsRequest true "Startup commands"
+// @Success 200 {object} StartupCommandsResponse "Startup commands"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 403 {object} ErrorResponse "Command denied by the process policy"
+// @Failure 422 {object} ErrorResponse "Invalid startup commands"
+// @Router /config/startup-commands [put]
+func (h *ConfigHandler) HandleSetStartupCommands(c *gin.Context) {
+	var req StartupCommandsRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	for i := range req.Commands {
+		if req.Commands[i].WorkingDir == "" {
+			continue
+		}
+		formattedWorkingDir, err := lib.FormatPath(req.Commands[i].WorkingDir)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		req.Commands[i].WorkingDir = formattedWorkingDir
+	}
+
+	_, err := process.GetProcessManager().SetStartupCommands(req.Commands)
+	if errors.Is(err, policy.ErrDenied) {
+		h.SendError(c, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, startupCommandsResponse())
+}
+
+// CORSRequest is the request body for replacing the origins allowed to call the API
+type CORSRequest struct {
+	AllowedOrigins []string `json:"allowedOrigins" example:"https://app.example.com,https://*.example.com"`
+} // @name CORSRequest
+
+// CORSResponse is the origins allowed to call the API from a browser
+type CORSResponse struct {
+	AllowedOrigins []string `json:"allowedOrigins" example:"*" binding:"required"`
+	Source         string   `json:"source" example:"env" enums:"default,env,api" binding:"required"`
+} // @name CORSResponse
+
+func corsResponse() CORSResponse {
+	origins, source := cors.GetPolicy().AllowedOrigins()
+	return CORSResponse{AllowedOrigins: origins, Source: source}
+}
+
+// HandleGetCORS handles GET requests to /config/cors
+// @Summary Get the allowed origins
+// @Description Get the origins browsers may call the API from, and where they come from: every origin by default (default), the comma-separated CORS_ALLOWED_ORIGINS environment variable (env) or PUT /config/cors (api). They apply to the CORS headers of the REST routes and to the origin check of the WebSocket endpoints (/ws, /lsp).
+// @Tags config
+// @Produce json
+// @Success 200 {object} CORSResponse "Allowed origins"
+// @Router /config/cors [get]
+func (h *ConfigHandler) HandleGetCORS(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, corsResponse())
+}
+
+// HandleSetCORS handles PUT requests to /config/cors
+// @Summary Replace the allowed origins
+// @Description Replace the origins browsers may call the API from: * for every origin, origins like https://app.example.com, or patterns like https://*.example.com for the subdomains of a domain. Responses to the allowed origins carry CORS headers, with credentials unless every origin is allowed; WebSocket upgrades from other origins are rejected with 403. Clients without an Origin header, which are not browsers, and pages of the sandbox itself are always allowed. An empty list allows no browser origin.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Param request body CORSRequest true "Allowed origins"
+// @Success 200 {object} CORSResponse "Allowed origins"
+// @Failure 400 {object} ErrorResponse "Invalid origin"
+// @Router /config/cors [put]
+func (h *ConfigHandler) HandleSetCORS(c *gin.Context) {
+	var req CORSRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := cors.GetPolicy().SetAllowedOrigins(req.AllowedOrigins); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, corsResponse())
+}
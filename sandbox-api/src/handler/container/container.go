@@ -0,0 +1,608 @@
+// Package container controls the containers of a Docker or Podman runtime running in the
+// sandbox through its socket and the Docker Engine API, which Podman implements too, so
+// that they can be listed, run, followed and stopped with structured responses rather
+// than by scraping the output of the docker CLI.
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// ErrUnavailable is returned when no container runtime socket was found or it does not answer
+var ErrUnavailable = apierror.New(apierror.CodeUnavailable, "no Docker or Podman socket found, set CONTAINER_SOCKET")
+
+// Container is a container of the runtime
+type Container struct {
+	ID      string `json:"id" example:"4f66ad9a0b2e" binding:"required"`
+	Name    string `json:"name" example:"postgres" binding:"required"`
+	Image   string `json:"image" example:"postgres:16" binding:"required"`
+	Command string `json:"command" example:"docker-entrypoint.sh postgres"`
+	// State is created, running, paused, restarting, removing, exited or dead
+	State string `json:"state" example:"running" binding:"required"`
+	// Status is the human readable status given by the runtime
+	Status    string            `json:"status" example:"Up 2 minutes"`
+	CreatedAt time.Time         `json:"createdAt" binding:"required"`
+	Ports     []Port            `json:"ports"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// ExitCode is the exit code of an exited container
+	ExitCode *int `json:"exitCode,omitempty" example:"0"`
+} // @name Container
+
+// Port is a port of a container published on the sandbox
+type Port struct {
+	ContainerPort int    `json:"containerPort" example:"5432" binding:"required"`
+	HostPort      int    `json:"hostPort,omitempty" example:"5432"`
+	HostIP        string `json:"hostIp,omitempty" example:"0.0.0.0"`
+	// Protocol is tcp, udp or sctp
+	Protocol string `json:"protocol" example:"tcp" binding:"required"`
+} // @name ContainerPort
+
+// RunRequest describes a container to create and start
+type RunRequest struct {
+	// Image is pulled when it is not present
+	Image string `json:"image" example:"postgres:16" binding:"required"`
+	Name  string `json:"name,omitempty" example:"postgres"`
+	// Command overrides the command of the image
+	Command    []string          `json:"command,omitempty" example:"postgres,-c,log_statement=all"`
+	Env        map[string]string `json:"env,omitempty"`
+	WorkingDir string            `json:"workingDir,omitempty" example:"/app"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// Volumes are bind mounts, as host-path:container-path[:ro], or named volumes. Host
+	// paths must be within the roots of CONTAINER_VOLUME_ROOTS.
+	Volumes []string `json:"volumes,omitempty" example:"/blaxel/app:/app:ro"`
+	// Ports are the ports of the container to publish on the sandbox
+	Ports []Port `json:"ports,omitempty"`
+	// AutoRemove removes the container when it exits
+	AutoRemove bool `json:"autoRemove,omitempty" example:"false"`
+} // @name RunContainerRequest
+
+// LogOptions selects the logs of a container to read
+type LogOptions struct {
+	// Follow keeps reading the logs until the container exits or the context is done
+	Follow bool
+	// Tail is the number of lines to read from the end of the logs, all of them when 0
+	Tail int
+}
+
+// Client talks to the runtime through its socket
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// Global client instance
+var (
+	client     *Client
+	clientOnce sync.Once
+)
+
+// GetClient returns the client of the runtime socket found by SocketFromEnv
+func GetClient() *Client {
+	clientOnce.Do(func() {
+		client = NewClient(SocketFromEnv())
+	})
+	return client
+}
+
+// NewClient creates a client of the runtime listening on socket. With an empty socket,
+// every call fails with ErrUnavailable.
+func NewClient(socket string) *Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &Client{
+		socket: socket,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// VolumeRootsFromEnv returns the directories host paths of volumes must be within, read
+// from the comma-separated CONTAINER_VOLUME_ROOTS, and defaulting to /blaxel
+func VolumeRootsFromEnv() []string {
+	value := os.Getenv("CONTAINER_VOLUME_ROOTS")
+	if value == "" {
+		return []string{"/blaxel"}
+	}
+	var roots []string
+	for _, root := range strings.Split(value, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, filepath.Clean(root))
+		}
+	}
+	return roots
+}
+
+// SocketFromEnv returns the socket of the runtime, read from CONTAINER_SOCKET, then from
+// DOCKER_HOST when it is a unix:// address, and defaulting to the first of the usual Docker
+// and Podman sockets that exists. It is empty when none is found.
+func SocketFromEnv() string {
+	if socket := os.Getenv("CONTAINER_SOCKET"); socket != "" {
+		return strings.TrimPrefix(socket, "unix://")
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		if strings.HasPrefix(host, "unix://") {
+			return strings.TrimPrefix(host, "unix://")
+		}
+		logrus.Warnf("Invalid DOCKER_HOST value '%s', only unix:// sockets are supported", host)
+	}
+	candidates := []string{"/var/run/docker.sock"}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock")
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Socket returns the socket of the runtime, empty when none was found
+func (c *Client) Socket() string {
+	return c.socket
+}
+
+// List returns the containers, only the running ones unless all is set, sorted by name
+func (c *Client) List(ctx context.Context, all bool) ([]Container, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	var summaries []struct {
+		ID      string `json:"Id"`
+		Names   []string
+		Image   string
+		Command string
+		Created int64
+		State   string
+		Status  string
+		Ports   []struct {
+			IP          string
+			PrivatePort int
+			PublicPort  int
+			Type        string
+		}
+		Labels map[string]string
+	}
+	if err := c.do(ctx, http.MethodGet, "/containers/json", query, nil, &summaries); err != nil {
+		return nil, err
+	}
+
+	containers := make([]Container, 0, len(summaries))
+	for _, s := range summaries {
+		container := Container{
+			ID:        s.ID,
+			Image:     s.Image,
+			Command:   s.Command,
+			State:     s.State,
+			Status:    s.Status,
+			CreatedAt: time.Unix(s.Created, 0).UTC(),
+			Ports:     []Port{},
+			Labels:    s.Labels,
+		}
+		if len(s.Names) > 0 {
+			container.Name = strings.TrimPrefix(s.Names[0], "/")
+		}
+		for _, p := range s.Ports {
+			container.Ports = append(container.Ports, Port{ContainerPort: p.PrivatePort, HostPort: p.PublicPort, HostIP: p.IP, Protocol: p.Type})
+		}
+		containers = append(containers, container)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Name < containers[j].Name
+	})
+	return containers, nil
+}
+
+// inspectResponse is the part of the response of the runtime to an inspection we use
+type inspectResponse struct {
+	ID      string `json:"Id"`
+	Name    string
+	Created time.Time
+	Path    string
+	Args    []string
+	State   struct {
+		Status   string
+		ExitCode int
+	}
+	Config struct {
+		Image  string
+		Tty    bool
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string
+		}
+	}
+}
+
+// Inspect returns a container by id or name
+func (c *Client) Inspect(ctx context.Context, id string) (Container, error) {
+	inspect, err := c.inspect(ctx, id)
+	if err != nil {
+		return Container{}, err
+	}
+
+	container := Container{
+		ID:        inspect.ID,
+		Name:      strings.TrimPrefix(inspect.Name, "/"),
+		Image:     inspect.Config.Image,
+		Command:   strings.TrimSpace(inspect.Path + " " + strings.Join(inspect.Args, " ")),
+		State:     inspect.State.Status,
+		Status:    inspect.State.Status,
+		CreatedAt: inspect.Created.UTC(),
+		Ports:     []Port{},
+		Labels:    inspect.Config.Labels,
+	}
+	if inspect.State.Status == "exited" || inspect.State.Status == "dead" {
+		exitCode := inspect.State.ExitCode
+		container.ExitCode = &exitCode
+	}
+	for spec, bindings := range inspect.NetworkSettings.Ports {
+		port, protocol := parsePortSpec(spec)
+		if len(bindings) == 0 {
+			container.Ports = append(container.Ports, Port{ContainerPort: port, Protocol: protocol})
+		}
+		for _, binding := range bindings {
+			hostPort, _ := strconv.Atoi(binding.HostPort)
+			container.Ports = append(container.Ports, Port{ContainerPort: port, HostPort: hostPort, HostIP: binding.HostIP, Protocol: protocol})
+		}
+	}
+	sort.Slice(container.Ports, func(i, j int) bool {
+		return container.Ports[i].ContainerPort < container.Ports[j].ContainerPort
+	})
+	return container, nil
+}
+
+// commandLine returns the command of a container as a shell command line, for the policy
+func commandLine(command []string) string {
+	args := make([]string, 0, len(command))
+	for _, arg := range command {
+		if arg == "" || strings.IndexFunc(arg, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%_+=:,./-", r))
+		}) >= 0 {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// checkVolume rejects a bind mount whose host path, once its symbolic links are resolved,
+// is not within one of roots. Named volumes are not host paths and are accepted.
+func checkVolume(volume string, roots []string) error {
+	host, _, _ := strings.Cut(volume, ":")
+	if !strings.ContainsRune(host, '/') && !strings.HasPrefix(host, ".") {
+		return nil
+	}
+	if !filepath.IsAbs(host) {
+		return apierror.Newf(apierror.CodeInvalidRequest, "volume %s: host path must be absolute", volume)
+	}
+	path := filepath.Clean(host)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		if relPath, err := filepath.Rel(root, path); err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return apierror.Newf(apierror.CodeFSOutsideRoot, "volume %s: host path must be within %s", volume, strings.Join(roots, ", "))
+}
+
+func (c *Client) inspect(ctx context.Context, id string) (inspectResponse, error) {
+	var inspect inspectResponse
+	err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, nil, &inspect)
+	return inspect, err
+}
+
+// Run creates and starts a container, pulling its image first when it is not present.
+// Its command is checked against the process policy, and the host paths of its volumes
+// must be within the roots of CONTAINER_VOLUME_ROOTS.
+func (c *Client) Run(ctx context.Context, req RunRequest) (Container, error) {
+	if req.Image == "" {
+		return Container{}, apierror.New(apierror.CodeInvalidRequest, "image is required")
+	}
+	if len(req.Command) > 0 {
+		if err := policy.GetEngine().Check(commandLine(req.Command), req.WorkingDir); err != nil {
+			return Container{}, err
+		}
+	}
+	roots := VolumeRootsFromEnv()
+	for _, volume := range req.Volumes {
+		if err := checkVolume(volume, roots); err != nil {
+			return Container{}, err
+		}
+	}
+
+	body := map[string]any{
+		"Image":  req.Image,
+		"Labels": req.Labels,
+	}
+	if len(req.Command) > 0 {
+		body["Cmd"] = req.Command
+	}
+	if req.WorkingDir != "" {
+		body["WorkingDir"] = req.WorkingDir
+	}
+	if len(req.Env) > 0 {
+		env := make([]string, 0, len(req.Env))
+		for key, value := range req.Env {
+			env = append(env, key+"="+value)
+		}
+		sort.Strings(env)
+		body["Env"] = env
+	}
+	hostConfig := map[string]any{
+		"AutoRemove": req.AutoRemove,
+		"Binds":      req.Volumes,
+	}
+	if len(req.Ports) > 0 {
+		exposed := map[string]struct{}{}
+		bindings := map[string][]map[string]string{}
+		for _, port := range req.Ports {
+			if port.ContainerPort <= 0 || port.ContainerPort > 65535 {
+				return Container{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid container port %d", port.ContainerPort)
+			}
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			spec := fmt.Sprintf("%d/%s", port.ContainerPort, protocol)
+			exposed[spec] = struct{}{}
+			binding := map[string]string{"HostIp": port.HostIP}
+			if port.HostPort > 0 {
+				binding["HostPort"] = strconv.Itoa(port.HostPort)
+			}
+			bindings[spec] = append(bindings[spec], binding)
+		}
+		body["ExposedPorts"] = exposed
+		hostConfig["PortBindings"] = bindings
+	}
+	body["HostConfig"] = hostConfig
+
+	query := url.Values{}
+	if req.Name != "" {
+		query.Set("name", req.Name)
+	}
+	var created struct {
+		ID       string `json:"Id"`
+		Warnings []string
+	}
+	err := c.do(ctx, http.MethodPost, "/containers/create", query, body, &created)
+	if errors.Is(err, apierror.New(apierror.CodeNotFound, "")) {
+		// The image is not present, pull it and try again
+		if err := c.pull(ctx, req.Image); err != nil {
+			return Container{}, err
+		}
+		err = c.do(ctx, http.MethodPost, "/containers/create", query, body, &created)
+	}
+	if err != nil {
+		return Container{}, err
+	}
+	for _, warning := range created.Warnings {
+		logrus.Warnf("Container %s: %s", created.ID, warning)
+	}
+
+	if err := c.do(ctx, http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil); err != nil {
+		return Container{}, err
+	}
+	container, err := c.Inspect(ctx, created.ID)
+	if errors.Is(err, apierror.New(apierror.CodeNotFound, "")) && req.AutoRemove {
+		// The container already exited and was removed
+		return Container{ID: created.ID, Name: req.Name, Image: req.Image, State: "removed", Ports: []Port{}}, nil
+	}
+	return container, err
+}
+
+// pull pulls an image, the runtime streaming its progress as JSON messages until done
+func (c *Client) pull(ctx context.Context, image string) error {
+	logrus.Infof("Pulling container image %s", image)
+	resp, err := c.request(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {image}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to pull %s: %w", image, err)
+		}
+		if message.Error != "" {
+			return apierror.Newf(apierror.CodeNotFound, "failed to pull %s: %s", image, message.Error)
+		}
+	}
+}
+
+// Logs writes the logs of a container to stdout and stderr, until its current end or, when
+// following, until the container exits or ctx is done
+func (c *Client) Logs(ctx context.Context, id string, opts LogOptions, stdout, stderr io.Writer) error {
+	inspect, err := c.inspect(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if opts.Follow {
+		query.Set("follow", "1")
+	}
+	if opts.Tail > 0 {
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	resp, err := c.request(ctx, http.MethodGet, "/containers/"+url.PathEscape(inspect.ID)+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The output of a container with a TTY is not multiplexed, stderr being merged in stdout
+	if inspect.Config.Tty {
+		_, err = io.Copy(stdout, resp.Body)
+	} else {
+		err = demultiplex(resp.Body, stdout, stderr)
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// demultiplex splits the multiplexed output of a container, a sequence of frames of an
+// 8-byte header, the stream and the size of the payload, followed by the payload
+func demultiplex(r io.Reader, stdout, stderr io.Writer) error {
+	reader := bufio.NewReader(r)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			return err
+		}
+	}
+}
+
+// Stop stops a container, killing it when it has not exited after timeout. With a nil
+// timeout, the stop timeout of the container is used.
+func (c *Client) Stop(ctx context.Context, id string, timeout *int) error {
+	query := url.Values{}
+	if timeout != nil {
+		query.Set("t", strconv.Itoa(*timeout))
+	}
+	return c.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/stop", query, nil, nil)
+}
+
+// do sends a request to the runtime and decodes its JSON response into out, when not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, out any) error {
+	resp, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return apierror.Wrap(apierror.CodeBadGateway, fmt.Errorf("invalid response from the container runtime: %w", err))
+	}
+	return nil
+}
+
+// request sends a request to the runtime, turning its error responses into API errors.
+// The caller closes the body of the response.
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	if c.socket == "" {
+		return nil, ErrUnavailable
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := url.URL{Scheme: "http", Host: "container-runtime", Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, apierror.Newf(apierror.CodeUnavailable, "container runtime at %s is not available: %v", c.socket, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, responseError(resp)
+}
+
+// responseError returns the API error matching an error response of the runtime
+func responseError(resp *http.Response) error {
+	var message struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(data, &message); err != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(data))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return apierror.New(apierror.CodeConflict, "container is not running")
+	case http.StatusBadRequest:
+		return apierror.New(apierror.CodeInvalidRequest, message.Message)
+	case http.StatusNotFound:
+		return apierror.New(apierror.CodeNotFound, message.Message)
+	case http.StatusConflict:
+		return apierror.New(apierror.CodeConflict, message.Message)
+	default:
+		return apierror.Newf(apierror.CodeBadGateway, "container runtime returned %d: %s", resp.StatusCode, message.Message)
+	}
+}
+
+// parsePortSpec parses a port of the runtime, like 80/tcp
+func parsePortSpec(spec string) (int, string) {
+	port, protocol, found := strings.Cut(spec, "/")
+	if !found {
+		protocol = "tcp"
+	}
+	number, _ := strconv.Atoi(port)
+	return number, protocol
+}
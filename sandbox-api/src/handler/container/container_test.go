@@ -0,0 +1,248 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// fakeRuntime serves the part of the Docker Engine API used by the client on a unix socket
+type fakeRuntime struct {
+	mu      sync.Mutex
+	images  map[string]bool
+	created map[string]any
+	running map[string]bool
+	tty     bool
+}
+
+func newFakeRuntime(t *testing.T) (*fakeRuntime, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	f := &fakeRuntime{images: map[string]bool{}, running: map[string]bool{}}
+	server := httptest.NewUnstartedServer(f)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return f, socket
+}
+
+func (f *fakeRuntime) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/json":
+		containers := []map[string]any{{"Id": "aaa", "Names": []string{"/web"}, "Image": "nginx", "State": "running", "Created": 1700000000,
+			"Ports": []map[string]any{{"PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"}}}}
+		if r.URL.Query().Get("all") == "1" {
+			containers = append(containers, map[string]any{"Id": "bbb", "Names": []string{"/db"}, "Image": "postgres", "State": "exited"})
+		}
+		_ = json.NewEncoder(w).Encode(containers)
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/create":
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !f.images[body["Image"].(string)] {
+			http.Error(w, `{"message": "No such image"}`, http.StatusNotFound)
+			return
+		}
+		f.created = body
+		_ = json.NewEncoder(w).Encode(map[string]any{"Id": "ccc"})
+	case r.Method == http.MethodPost && r.URL.Path == "/images/create":
+		image := r.URL.Query().Get("fromImage")
+		if image == "missing" {
+			_, _ = w.Write([]byte(`{"status": "Pulling"}` + "\n" + `{"error": "manifest unknown"}` + "\n"))
+			return
+		}
+		f.images[image] = true
+		_, _ = w.Write([]byte(`{"status": "Pulling"}` + "\n" + `{"status": "Downloaded"}` + "\n"))
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/ccc/start":
+		f.running["ccc"] = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/ccc/json":
+		state := "exited"
+		if f.running["ccc"] {
+			state = "running"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Id": "ccc", "Name": "/api", "Path": "node", "Args": []string{"server.js"},
+			"State":           map[string]any{"Status": state},
+			"Config":          map[string]any{"Image": "node:20", "Tty": f.tty},
+			"NetworkSettings": map[string]any{"Ports": map[string]any{"3000/tcp": []map[string]string{{"HostIp": "0.0.0.0", "HostPort": "3000"}}}}})
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/ccc/logs":
+		if f.tty {
+			_, _ = w.Write([]byte("out\nerr\n"))
+			return
+		}
+		for _, frame := range []struct {
+			stream byte
+			data   string
+		}{{1, "out\n"}, {2, "err\n"}, {1, "more"}} {
+			header := make([]byte, 8)
+			header[0] = frame.stream
+			binary.BigEndian.PutUint32(header[4:], uint32(len(frame.data)))
+			_, _ = w.Write(append(header, frame.data...))
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/ccc/stop":
+		if !f.running["ccc"] {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		f.running["ccc"] = false
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"message": "No such container"}`, http.StatusNotFound)
+	}
+}
+
+// TestClientRun tests running a container whose image must be pulled first, then stopping it
+func TestClientRun(t *testing.T) {
+	f, socket := newFakeRuntime(t)
+	c := NewClient(socket)
+	ctx := context.Background()
+
+	container, err := c.Run(ctx, RunRequest{
+		Image: "node:20",
+		Name:  "api",
+		Env:   map[string]string{"PORT": "3000"},
+		Ports: []Port{{ContainerPort: 3000, HostPort: 3000}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to run: %v", err)
+	}
+	if container.ID != "ccc" || container.Name != "api" || container.State != "running" || container.Command != "node server.js" {
+		t.Errorf("Unexpected container %+v", container)
+	}
+	if len(container.Ports) != 1 || container.Ports[0] != (Port{ContainerPort: 3000, HostPort: 3000, HostIP: "0.0.0.0", Protocol: "tcp"}) {
+		t.Errorf("Unexpected ports %+v", container.Ports)
+	}
+	if env := f.created["Env"].([]any); len(env) != 1 || env[0] != "PORT=3000" {
+		t.Errorf("Unexpected environment %v", f.created["Env"])
+	}
+
+	if err := c.Stop(ctx, "ccc", nil); err != nil {
+		t.Fatalf("Failed to stop: %v", err)
+	}
+	if err := c.Stop(ctx, "ccc", nil); !errors.Is(err, apierror.New(apierror.CodeConflict, "")) {
+		t.Errorf("Expected CONFLICT stopping a stopped container, got %v", err)
+	}
+	if container, _ := c.Inspect(ctx, "ccc"); container.ExitCode == nil || *container.ExitCode != 0 {
+		t.Errorf("Expected the exit code of the stopped container, got %+v", container)
+	}
+	if err := c.Stop(ctx, "unknown", nil); !errors.Is(err, apierror.New(apierror.CodeNotFound, "")) {
+		t.Errorf("Expected NOT_FOUND stopping an unknown container, got %v", err)
+	}
+	if _, err := c.Run(ctx, RunRequest{Image: "missing"}); err == nil || !strings.Contains(err.Error(), "manifest unknown") {
+		t.Errorf("Expected the pull error, got %v", err)
+	}
+}
+
+// TestClientRunChecks tests that containers whose command is denied by the process
+// policy, or which mount host paths outside of the volume roots, are not created
+func TestClientRunChecks(t *testing.T) {
+	f, socket := newFakeRuntime(t)
+	f.images["alpine"] = true
+	c := NewClient(socket)
+	ctx := context.Background()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	t.Setenv("CONTAINER_VOLUME_ROOTS", "/unused, "+root)
+
+	previous := policy.GetEngine().Policy()
+	if _, err := policy.GetEngine().SetPolicy(policy.Policy{
+		DefaultAction: policy.ActionAllow,
+		Rules:         []policy.Rule{{Name: "no-rm", Action: policy.ActionDeny, Program: "rm"}},
+	}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+	t.Cleanup(func() { _, _ = policy.GetEngine().SetPolicy(previous) })
+
+	if _, err := c.Run(ctx, RunRequest{Image: "alpine", Command: []string{"sh", "-c", "rm -rf /data"}}); !errors.Is(err, policy.ErrDenied) {
+		t.Errorf("Expected the command to be denied, got %v", err)
+	}
+	for _, volume := range []string{"/etc:/etc", root + "/../x:/x", filepath.Join(root, "link") + ":/x:ro", "data/x:/x"} {
+		if _, err := c.Run(ctx, RunRequest{Image: "alpine", Volumes: []string{volume}}); apierror.From(err) == nil {
+			t.Errorf("Expected volume %s to be rejected, got %v", volume, err)
+		}
+	}
+	if f.created != nil {
+		t.Fatalf("Expected no container to be created, got %v", f.created)
+	}
+
+	volumes := []string{root + ":/app", filepath.Join(root, "src") + ":/src:ro", "cache:/cache"}
+	if _, err := c.Run(ctx, RunRequest{Image: "alpine", Command: []string{"ls", "/app"}, Volumes: volumes}); err != nil {
+		t.Fatalf("Failed to run: %v", err)
+	}
+	if binds := f.created["HostConfig"].(map[string]any)["Binds"].([]any); len(binds) != len(volumes) {
+		t.Errorf("Unexpected binds %v", binds)
+	}
+}
+
+// TestClientList tests listing the running or all containers
+func TestClientList(t *testing.T) {
+	_, socket := newFakeRuntime(t)
+	c := NewClient(socket)
+
+	containers, err := c.List(context.Background(), false)
+	if err != nil || len(containers) != 1 || containers[0].Name != "web" || containers[0].Ports[0].HostPort != 8080 {
+		t.Fatalf("Unexpected containers %+v (%v)", containers, err)
+	}
+	containers, err = c.List(context.Background(), true)
+	if err != nil || len(containers) != 2 || containers[0].Name != "db" {
+		t.Errorf("Expected all the containers sorted by name, got %+v (%v)", containers, err)
+	}
+}
+
+// TestClientLogs tests that the logs of containers are split in stdout and stderr, unless
+// they have a TTY
+func TestClientLogs(t *testing.T) {
+	f, socket := newFakeRuntime(t)
+	c := NewClient(socket)
+
+	var stdout, stderr bytes.Buffer
+	if err := c.Logs(context.Background(), "ccc", LogOptions{}, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to read logs: %v", err)
+	}
+	if stdout.String() != "out\nmore" || stderr.String() != "err\n" {
+		t.Errorf("Unexpected logs %q and %q", stdout.String(), stderr.String())
+	}
+
+	f.tty = true
+	stdout.Reset()
+	stderr.Reset()
+	if err := c.Logs(context.Background(), "ccc", LogOptions{}, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to read logs: %v", err)
+	}
+	if stdout.String() != "out\nerr\n" || stderr.Len() != 0 {
+		t.Errorf("Expected the raw output of a TTY, got %q and %q", stdout.String(), stderr.String())
+	}
+}
+
+// TestClientUnavailable tests that calls fail with UNAVAILABLE without a runtime
+func TestClientUnavailable(t *testing.T) {
+	for _, socket := range []string{"", filepath.Join(t.TempDir(), "missing.sock")} {
+		_, err := NewClient(socket).List(context.Background(), true)
+		if !errors.Is(err, apierror.New(apierror.CodeUnavailable, "")) {
+			t.Errorf("Expected UNAVAILABLE for socket %q, got %v", socket, err)
+		}
+	}
+}
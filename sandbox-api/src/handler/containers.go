@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/container"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+)
+
+// ContainerHandler handles the containers of the Docker or Podman runtime of the sandbox
+type ContainerHandler struct {
+	*BaseHandler
+	client *container.Client
+}
+
+// NewContainerHandler creates a new container handler on the runtime socket found at startup
+func NewContainerHandler() *ContainerHandler {
+	return &ContainerHandler{
+		BaseHandler: NewBaseHandler(),
+		client:      container.GetClient(),
+	}
+}
+
+// ContainerLogs is the output of a container
+type ContainerLogs struct {
+	Stdout string `json:"stdout" example:"stdout output" binding:"required"`
+	Stderr string `json:"stderr" example:"stderr output" binding:"required"`
+	Logs   string `json:"logs" example:"logs output" binding:"required"`
+} // @name ContainerLogs
+
+// HandleListContainers handles GET requests to /containers
+// @Summary List containers
+// @Description List the containers of the Docker or Podman runtime of the sandbox, sorted by name. The runtime socket is read from CONTAINER_SOCKET, then DOCKER_HOST, and defaults to the usual Docker and Podman sockets.
+// @Tags containers
+// @Produce json
+// @Param all query boolean false "Also list the containers which are not running"
+// @Success 200 {array} container.Container "Containers"
+// @Failure 502 {object} ErrorResponse "Container runtime error"
+// @Failure 503 {object} ErrorResponse "No container runtime available"
+// @Router /containers [get]
+func (h *ContainerHandler) HandleListContainers(c *gin.Context) {
+	containers, err := h.client.List(c.Request.Context(), c.Query("all") == "true")
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, containers)
+}
+
+// HandleRunContainer handles POST requests to /containers
+// @Summary Run a container
+// @Description Create and start a container, pulling its image first when it is not present. Its command is checked against the process policy, and the host paths of its volumes must be within the comma-separated CONTAINER_VOLUME_ROOTS (default /blaxel).
+// @Tags containers
+// @Accept json
+// @Produce json
+// @Param request body container.RunRequest true "Container to run"
+// @Success 200 {object} container.Container "Container started"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 403 {object} ErrorResponse "Command denied by the process policy"
+// @Failure 404 {object} ErrorResponse "Image not found"
+// @Failure 409 {object} ErrorResponse "Container name already in use"
+// @Failure 502 {object} ErrorResponse "Container runtime error"
+// @Failure 503 {object} ErrorResponse "No container runtime available"
+// @Router /containers [post]
+func (h *ContainerHandler) HandleRunContainer(c *gin.Context) {
+	var req container.RunRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	started, err := h.client.Run(c.Request.Context(), req)
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, started)
+}
+
+// HandleGetContainer handles GET requests to /containers/{id}
+// @Summary Get a container
+// @Description Get a container by id or name
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container id or name"
+// @Success 200 {object} container.Container "Container"
+// @Failure 404 {object} ErrorResponse "Container not found"
+// @Failure 503 {object} ErrorResponse "No container runtime available"
+// @Router /containers/{id} [get]
+func (h *ContainerHandler) HandleGetContainer(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	found, err := h.client.Inspect(c.Request.Context(), id)
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, found)
+}
+
+// HandleStopContainer handles DELETE requests to /containers/{id}
+// @Summary Stop a container
+// @Description Stop a container, killing it when it has not exited after timeout. Containers run with autoRemove are removed once stopped.
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container id or name"
+// @Param timeout query int false "Seconds to wait before killing the container (default: the stop timeout of the container)"
+// @Success 200 {object} SuccessResponse "Container stopped"
+// @Failure 400 {object} ErrorResponse "Invalid timeout"
+// @Failure 404 {object} ErrorResponse "Container not found"
+// @Failure 409 {object} ErrorResponse "Container is not running"
+// @Failure 503 {object} ErrorResponse "No container runtime available"
+// @Router /containers/{id} [delete]
+func (h *ContainerHandler) HandleStopContainer(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	var timeout *int
+	if value := c.Query("timeout"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			h.SendError(c, http.StatusBadRequest, apierror.Newf(apierror.CodeInvalidRequest, "invalid timeout %q", value))
+			return
+		}
+		timeout = &seconds
+	}
+
+	if err := h.client.Stop(c.Request.Context(), id, timeout); err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendSuccess(c, "Container stopped successfully")
+}
+
+// HandleGetContainerLogs handles GET requests to /containers/{id}/logs
+// @Summary Get container logs
+// @Description Get the stdout and stderr output of a container. The output of a container run with a TTY is all in stdout.
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container id or name"
+// @Param tail query int false "Only return the last N lines"
+// @Success 200 {object} ContainerLogs "Container logs"
+// @Failure 400 {object} ErrorResponse "Invalid tail"
+// @Failure 404 {object} ErrorResponse "Container not found"
+// @Failure 503 {object} ErrorResponse "No container runtime available"
+// @Router /containers/{id}/logs [get]
+func (h *ContainerHandler) HandleGetContainerLogs(c *gin.Context) {
+	id, opts, err := h.parseContainerLogs(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var stdout, stderr, logs bytes.Buffer
+	if err := h.client.Logs(c.Request.Context(), id, opts, io.MultiWriter(&stdout, &logs), io.MultiWriter(&stderr, &logs)); err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, ContainerLogs{Stdout: stdout.String(), Stderr: stderr.String(), Logs: logs.String()})
+}
+
+// HandleGetContainerLogsStream handles GET requests to /containers/{id}/logs/stream
+// @Summary Stream container logs in real time
+// @Description Streams the stdout and stderr output of a container in real time, one line per log, prefixed with 'stdout:' or 'stderr:' like the logs of processes. Closes when the container exits or the client disconnects.
+// @Tags containers
+// @Produce plain
+// @Param id path string true "Container id or name"
+// @Param tail query int false "Only stream the last N lines written before the request, then the new ones"
+// @Success 200 {string} string "Stream of container logs, one line per log (prefixed with stdout:/stderr:)"
+// @Failure 400 {object} ErrorResponse "Invalid tail"
+// @Failure 404 {object} ErrorResponse "Container not found"
+// @Failure 429 {object} ErrorResponse "Too many log streams open"
+// @Failure 503 {object} ErrorResponse "No container runtime available"
+// @Router /containers/{id}/logs/stream [get]
+func (h *ContainerHandler) HandleGetContainerLogsStream(c *gin.Context) {
+	id, opts, err := h.parseContainerLogs(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	opts.Follow = true
+
+	// Fail before streaming when the container does not exist
+	if _, err := h.client.Inspect(c.Request.Context(), id); err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	release, err := streamlimit.LogStreams().Acquire(c.Request.RemoteAddr)
+	if err != nil {
+		h.SendError(c, http.StatusTooManyRequests, err)
+		return
+	}
+	defer release()
+
+	// Set headers for streaming
+	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Writer.Flush()
+
+	metrics.ActiveLogStreams.Inc()
+	defer metrics.ActiveLogStreams.Dec()
+
+	rw := &ResponseWriter{gin: c}
+	stdout := &prefixedLineWriter{w: rw, prefix: "stdout:"}
+	stderr := &prefixedLineWriter{w: rw, prefix: "stderr:"}
+	// Headers are sent, errors can only end the stream
+	_ = h.client.Logs(c.Request.Context(), id, opts, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+}
+
+// parseContainerLogs returns the container and options of a logs request
+func (h *ContainerHandler) parseContainerLogs(c *gin.Context) (string, container.LogOptions, error) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		return "", container.LogOptions{}, err
+	}
+	var opts container.LogOptions
+	if value := c.Query("tail"); value != "" {
+		tail, err := strconv.Atoi(value)
+		if err != nil || tail < 0 {
+			return "", container.LogOptions{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid tail %q", value)
+		}
+		opts.Tail = tail
+	}
+	return id, opts, nil
+}
+
+// prefixedLineWriter writes each complete line written to it to w with a prefix, keeping
+// the rest until its end is written or Flush is called
+type prefixedLineWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	prefix  string
+	partial []byte
+}
+
+func (p *prefixedLineWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.partial = append(p.partial, data...)
+	for {
+		end := bytes.IndexByte(p.partial, '\n')
+		if end < 0 {
+			return len(data), nil
+		}
+		line := append([]byte(p.prefix), p.partial[:end+1]...)
+		p.partial = p.partial[end+1:]
+		if _, err := p.w.Write(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush writes the last line when it does not end with a newline
+func (p *prefixedLineWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.partial) > 0 {
+		_, _ = p.w.Write(append(append([]byte(p.prefix), p.partial...), '\n'))
+		p.partial = nil
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// LockHolderHeader is the request header identifying the holder of the locks a request
+// is made by. Writes to paths under an enforced lock of another holder are rejected.
+const LockHolderHeader = "X-Lock-Holder"
+
+// LockRequest is the request body for locking a path
+type LockRequest struct {
+	// Holder identifies the client locking the path, the X-Lock-Holder header by default
+	Holder string `json:"holder" example:"agent-1"`
+	// TTL is the number of seconds the lock lasts unless renewed (default: 60, max: 86400)
+	TTL int `json:"ttl" example:"60" binding:"gte=0"`
+	// Enforce rejects the writes of other holders instead of only advising them
+	Enforce bool `json:"enforce" example:"false"`
+} // @name LockRequest
+
+// lockHolder returns the lock holder of a request, from the X-Lock-Holder header or the
+// holder query parameter
+func lockHolder(c *gin.Context) string {
+	if holder := c.GetHeader(LockHolderHeader); holder != "" {
+		return holder
+	}
+	return c.Query("holder")
+}
+
+// checkLocks sends a 423 error and returns false when writing one of the paths is
+// rejected by the enforced lock of another holder than the one of the request
+func (h *FileSystemHandler) checkLocks(c *gin.Context, paths ...string) bool {
+	if err := h.fs.CheckLocks(lockHolder(c), paths...); err != nil {
+		h.SendError(c, http.StatusLocked, err)
+		return false
+	}
+	return true
+}
+
+// lockPath returns the absolute path of the lock of a request
+func (h *FileSystemHandler) lockPath(c *gin.Context) (string, bool) {
+	path, err := lib.FormatPath(h.extractPathFromRequest(c))
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return "", false
+	}
+	absPath, err := h.fs.ResolvePath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return "", false
+	}
+	return absPath, true
+}
+
+// HandleAcquireLock handles POST requests to /filesystem/:path/lock
+// @Summary Lock a file or directory
+// @Description Acquire an advisory lease on a file or a directory, covering everything below it, so that cooperating clients coordinate their edits. The path does not need to exist. Locks are held in memory until released, or until they expire after their TTL. The holder of a lock renews it by locking the path again.
+// @Description
+// @Description Locks are advisory unless enforce is set: writes to an enforced lock's paths by requests without its holder in the X-Lock-Holder header are then rejected with 423 FS_LOCKED.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "File or directory to lock"
+// @Param X-Lock-Holder header string false "Holder of the lock, when not in the body"
+// @Param request body LockRequest true "Holder and duration of the lock"
+// @Success 200 {object} filesystem.FileLock "Lock acquired or renewed"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 423 {object} ErrorResponse "Path, a parent or a path below it is locked by another holder"
+// @Router /filesystem/{path}/lock [post]
+func (h *FileSystemHandler) HandleAcquireLock(c *gin.Context) {
+	path, ok := h.lockPath(c)
+	if !ok {
+		return
+	}
+	var req LockRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Holder == "" {
+		req.Holder = lockHolder(c)
+	}
+
+	lock, err := filesystem.GetLockTable().Acquire(path, req.Holder, time.Duration(req.TTL)*time.Second, req.Enforce)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, lock)
+}
+
+// HandleGetLock handles GET requests to /filesystem/:path/lock
+// @Summary Get the lock of a file or directory
+// @Description Get the holder and expiration of the lock of a path. Locks of its parents are not returned, see /filesystem/{path}/locks for the locks below a directory.
+// @Tags filesystem
+// @Produce json
+// @Param path path string true "Locked file or directory"
+// @Success 200 {object} filesystem.FileLock "Lock"
+// @Failure 400 {object} ErrorResponse "Invalid path"
+// @Failure 404 {object} ErrorResponse "Path is not locked"
+// @Router /filesystem/{path}/lock [get]
+func (h *FileSystemHandler) HandleGetLock(c *gin.Context) {
+	path, ok := h.lockPath(c)
+	if !ok {
+		return
+	}
+
+	lock, err := filesystem.GetLockTable().Get(path)
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, lock)
+}
+
+// HandleListLocks handles GET requests to /filesystem/:path/locks
+// @Summary List locks
+// @Description List the locks of a directory and of the paths below it, sorted by path. Use /filesystem/locks for the locks below the working directory, or /filesystem/%2F/locks for every lock.
+// @Tags filesystem
+// @Produce json
+// @Param path path string true "Directory whose locks are listed"
+// @Success 200 {array} filesystem.FileLock "Locks"
+// @Failure 400 {object} ErrorResponse "Invalid path"
+// @Router /filesystem/{path}/locks [get]
+func (h *FileSystemHandler) HandleListLocks(c *gin.Context) {
+	path, ok := h.lockPath(c)
+	if !ok {
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, filesystem.GetLockTable().List(path))
+}
+
+// HandleReleaseLock handles DELETE requests to /filesystem/:path/lock
+// @Summary Release the lock of a file or directory
+// @Description Release the lock of a path, which only its holder can do
+// @Tags filesystem
+// @Produce json
+// @Param path path string true "Locked file or directory"
+// @Param X-Lock-Holder header string false "Holder of the lock"
+// @Param holder query string false "Holder of the lock, when not in the X-Lock-Holder header"
+// @Success 200 {object} SuccessResponse "Lock released"
+// @Failure 400 {object} ErrorResponse "Invalid path"
+// @Failure 404 {object} ErrorResponse "Path is not locked"
+// @Failure 423 {object} ErrorResponse "Path is locked by another holder"
+// @Router /filesystem/{path}/lock [delete]
+func (h *FileSystemHandler) HandleReleaseLock(c *gin.Context) {
+	path, ok := h.lockPath(c)
+	if !ok {
+		return
+	}
+
+	if err := filesystem.GetLockTable().Release(path, lockHolder(c)); err != nil {
+		h.SendError(c, http.StatusLocked, err)
+		return
+	}
+
+	h.SendSuccessWithPath(c, path, "Lock released successfully")
+}
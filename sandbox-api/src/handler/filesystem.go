@@ -1,14 +1,18 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -17,30 +21,123 @@ import (
 
 	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
 	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/service"
 )
 
 // FileSystemHandler handles filesystem operations
 type FileSystemHandler struct {
 	*BaseHandler
 	fs               *filesystem.Filesystem
+	files            *service.FileSystem
 	multipartManager *filesystem.MultipartManager
+	quota            uint64
+
+	// conditionalWriteMu serializes writes with an If-Match header, so that two clients
+	// holding the same ETag can't both pass the check before either one writes
+	conditionalWriteMu sync.Mutex
 }
 
 // FileEvent represents a file event
 type FileEvent struct {
-	Op    string  `json:"op"`
+	// Op is MOVED for a file moved within the watched directories, joined with the
+	// other operations merged into the event
+	Op    string  `json:"op" example:"WRITE"`
 	Name  string  `json:"name"`
 	Path  string  `json:"path"`
 	Error *string `json:"error"`
+	// Size, ModTime and IsDir describe the file after the event, they are not set when
+	// it no longer exists
+	Size    *int64     `json:"size,omitempty" example:"1024"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+	IsDir   bool       `json:"isDir" example:"false"`
+	// From and To are the previous and new paths of a moved file
+	From string `json:"from,omitempty" example:"/app/old.txt"`
+	To   string `json:"to,omitempty" example:"/app/new.txt"`
+	// Content is the content of a watched file after the event with content=full, and
+	// Diff the unified diff of its content since the previous event with content=diff
+	Content *string `json:"content,omitempty"`
+	Diff    *string `json:"diff,omitempty" example:"--- a/app/main.go\n+++ b/app/main.go\n@@ -1 +1 @@\n-package app\n+package main"`
+	// ContentOmitted is true when the watched file is binary or too large for its content
+	// or diff to be included
+	ContentOmitted bool `json:"contentOmitted,omitempty" example:"false"`
 } // @name FileEvent
 
+// TreeRequest is the request body for creating or updating a directory tree
+type TreeRequest struct {
+	// Files maps the paths of files, relative to the root directory, to their content
+	Files map[string]string `json:"files"`
+} // @name TreeRequest
+
 // FileRequest represents the request body for creating or updating a file
 type FileRequest struct {
-	Content     string `json:"content" example:"file contents here"`
+	Content string `json:"content" example:"file contents here"`
+	// Encoding is the encoding of content, utf-8 by default or base64 for binary content
+	Encoding    string `json:"encoding" example:"utf-8" enums:"utf-8,base64"`
 	IsDirectory bool   `json:"isDirectory" example:"false"`
 	Permissions string `json:"permissions" example:"0644"`
+	Target      string `json:"target" example:"../shared/config.json"`
+	Hardlink    bool   `json:"hardlink" example:"false"`
+	Append      bool   `json:"append" example:"false"`
 } // @name FileRequest
 
+// BatchRequest represents an ordered list of filesystem operations applied atomically
+type BatchRequest struct {
+	Operations []filesystem.BatchOperation `json:"operations" binding:"required"`
+} // @name BatchRequest
+
+// BatchResponse represents the result of a batch of filesystem operations
+type BatchResponse struct {
+	Applied int    `json:"applied" example:"3" binding:"required"`
+	Message string `json:"message" example:"Batch applied successfully" binding:"required"`
+} // @name BatchResponse
+
+// DiffRequest is the request body for comparing two paths, or a path and content
+type DiffRequest struct {
+	// From is the file or directory to compare
+	From string `json:"from" example:"/app/main.go" binding:"required"`
+	// To is the file or directory From is compared to, unless content is set
+	To string `json:"to,omitempty" example:"/app-v2/main.go"`
+	// Content is compared to the file From, as if it replaced it, instead of To
+	Content *string `json:"content,omitempty" example:"package main"`
+	// Context is the number of unchanged lines around the changes (default: 3)
+	Context *int `json:"context,omitempty" example:"3"`
+	// Text compares binary files as text rather than only reporting that they differ
+	Text bool `json:"text,omitempty" example:"false"`
+	// Exclude are globs of the files and directories skipped when comparing directories
+	Exclude []string `json:"exclude,omitempty" example:"node_modules,.git"`
+} // @name DiffRequest
+
+// ApplyPatchRequest is the request body for applying a unified diff to the filesystem
+type ApplyPatchRequest struct {
+	// Patch is a unified diff, optionally in git format with renames, mode changes, created and deleted files
+	Patch string `json:"patch" example:"--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package app\n+package main\n" binding:"required"`
+	// Directory is the directory the paths of the patch are relative to (default: working directory)
+	Directory string `json:"directory,omitempty" example:"/app"`
+	// Strip is the number of leading components removed from the paths of the patch, like patch -p (default: 1 for paths prefixed with a/ and b/, 0 otherwise)
+	Strip *int `json:"strip,omitempty" example:"1"`
+	// DryRun reports where the hunks apply without writing anything
+	DryRun bool `json:"dryRun,omitempty" example:"false"`
+	// Reject is what is done when hunks do not apply: abort writes nothing, skip writes the hunks which apply, file also writes the others to .rej files (default: abort)
+	Reject string `json:"reject,omitempty" example:"abort" enums:"abort,skip,file"`
+} // @name ApplyPatchRequest
+
+// PermissionsRequest represents the request body for changing the mode and ownership of a file
+type PermissionsRequest struct {
+	Mode      string `json:"mode" example:"0755"`
+	Owner     string `json:"owner" example:"node"`
+	Group     string `json:"group" example:"node"`
+	Recursive bool   `json:"recursive" example:"false"`
+} // @name PermissionsRequest
+
+// FilePatchRequest represents the request body for applying range edits to a file
+type FilePatchRequest struct {
+	Edits []filesystem.FileEdit `json:"edits" binding:"required"`
+} // @name FilePatchRequest
+
 // MultipartInitiateRequest represents the request body for initiating a multipart upload
 type MultipartInitiateRequest struct {
 	Permissions string `json:"permissions" example:"0644"`
@@ -68,6 +165,8 @@ type MultipartPartInfo struct {
 // MultipartCompleteRequest represents the request body for completing a multipart upload
 type MultipartCompleteRequest struct {
 	Parts []MultipartPartInfo `json:"parts"`
+	// Checksum is the sha256 of the whole file, verified before the file is replaced
+	Checksum string `json:"checksum,omitempty" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
 } // @name MultipartCompleteRequest
 
 // MultipartListPartsResponse represents the response when listing parts
@@ -83,31 +182,15 @@ type MultipartListUploadsResponse struct {
 
 // NewFileSystemHandler creates a new filesystem handler
 func NewFileSystemHandler() *FileSystemHandler {
-	// Get working directory from environment or use default
-	workingDir := os.Getenv("WORKDIR")
-	if workingDir == "" {
-		// Try to get current working directory
-		if cwd, err := os.Getwd(); err == nil {
-			workingDir = cwd
-		} else {
-			// Default to / if we can't get the current directory
-			workingDir = "/"
-		}
-	}
-
-	// Setup multipart uploads directory
-	uploadsDir := filepath.Join(os.TempDir(), "multipart-uploads")
-	multipartManager := filesystem.NewMultipartManager(uploadsDir)
-
-	// Load existing uploads from disk
-	if multipartManager != nil {
-		_ = multipartManager.LoadUploads()
-	}
-
+	// Relative paths follow the default working directory of the sandbox
+	fs := filesystem.NewFilesystemWithWorkingDir("/", "")
+	quota := filesystem.QuotaFromEnv()
 	return &FileSystemHandler{
 		BaseHandler:      NewBaseHandler(),
-		fs:               filesystem.NewFilesystemWithWorkingDir("/", workingDir),
-		multipartManager: multipartManager,
+		fs:               fs,
+		files:            service.NewFileSystem(fs, quota),
+		multipartManager: filesystem.GetMultipartManager(),
+		quota:            quota,
 	}
 }
 
@@ -140,9 +223,20 @@ func (h *FileSystemHandler) extractPathFromRequest(c *gin.Context) string {
 	return path
 }
 
+// IsExistingPathRequest reports whether the path of the request is an existing file
+// or directory
+func (h *FileSystemHandler) IsExistingPathRequest(c *gin.Context) bool {
+	path := h.extractPathFromRequest(c)
+	if exists, err := h.fs.FileExists(path); err == nil && exists {
+		return true
+	}
+	exists, err := h.fs.DirectoryExists(path)
+	return err == nil && exists
+}
+
 // GetWorkingDirectory gets the current working directory
 func (h *FileSystemHandler) GetWorkingDirectory() (string, error) {
-	return h.fs.WorkingDir, nil
+	return h.fs.GetWorkingDir(), nil
 }
 
 // ListDirectory lists the contents of a directory
@@ -165,6 +259,16 @@ func (h *FileSystemHandler) WriteFile(path string, content []byte, permissions o
 	return h.fs.WriteFile(path, content, permissions)
 }
 
+// AppendFile appends content to a file, created with permissions if needed
+func (h *FileSystemHandler) AppendFile(path string, content []byte, permissions os.FileMode) error {
+	return h.fs.AppendFile(path, content, permissions)
+}
+
+// GetETag returns the ETag of a file, the quoted sha256 of its content
+func (h *FileSystemHandler) GetETag(path string) (string, error) {
+	return h.fs.GetETag(path)
+}
+
 // DirectoryExists checks if a path is a directory
 func (h *FileSystemHandler) DirectoryExists(path string) (bool, error) {
 	return h.fs.DirectoryExists(path)
@@ -175,6 +279,50 @@ func (h *FileSystemHandler) DeleteDirectory(path string, recursive bool) error {
 	return h.fs.DeleteDirectory(path, recursive)
 }
 
+// Write creates a directory, or writes or appends to a file, like every API does
+func (h *FileSystemHandler) Write(req service.WriteRequest) (service.WriteResult, error) {
+	return h.files.Write(req)
+}
+
+// WriteTree writes files by path relative to a root directory, returning the formatted
+// root, for the lock holder holder and giving the files created to runAs
+func (h *FileSystemHandler) WriteTree(root string, files map[string]string, holder string, runAs lib.RunAs) (string, error) {
+	return h.files.WriteTree(root, files, holder, runAs)
+}
+
+// CreateLink creates a symbolic link at path pointing to target, or a hard link to the
+// file at target when hardlink is set, for the lock holder holder. The link and the
+// missing parent directories are given to runAs.
+func (h *FileSystemHandler) CreateLink(path string, target string, hardlink bool, holder string, runAs lib.RunAs) error {
+	if target == "" {
+		return apierror.New(apierror.CodeInvalidRequest, "target is required to create a link")
+	}
+	owner, err := h.files.Owner(runAs)
+	if err != nil {
+		return err
+	}
+	if err := h.fs.CheckLocks(holder, path); err != nil {
+		return err
+	}
+
+	created := h.fs.TrackCreated(path, owner)
+	if hardlink {
+		if err := h.fs.CreateHardlink(path, target); err != nil {
+			return fmt.Errorf("error creating hard link: %w", err)
+		}
+	} else if err := h.fs.CreateSymlink(path, target); err != nil {
+		return fmt.Errorf("error creating symlink: %w", err)
+	}
+	created()
+	return nil
+}
+
+// Delete deletes a file or a directory for the lock holder holder, returning whether it
+// was a directory
+func (h *FileSystemHandler) Delete(path string, recursive bool, holder string) (bool, error) {
+	return h.files.Delete(path, recursive, holder)
+}
+
 // FileExists checks if a path is a file
 func (h *FileSystemHandler) FileExists(path string) (bool, error) {
 	return h.fs.FileExists(path)
@@ -185,6 +333,301 @@ func (h *FileSystemHandler) DeleteFile(path string) error {
 	return h.fs.DeleteFile(path)
 }
 
+// ListFiles returns the absolute paths of at most limit files below a directory, skipping
+// hidden entries and the ones ignored by the .gitignore file of the directory
+func (h *FileSystemHandler) ListFiles(path string, limit int) ([]string, bool, error) {
+	absPath, err := h.fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	patterns, err := filesystem.LoadGitignore(absPath)
+	if err != nil {
+		return nil, false, err
+	}
+	return h.fs.ListFiles(path, patterns, limit)
+}
+
+// ApplyBatch applies filesystem operations in order, rolling back every applied operation
+// when one fails, and gives the files created to owner
+func (h *FileSystemHandler) ApplyBatch(operations []filesystem.BatchOperation, owner *fileOwner) error {
+	return h.fs.ApplyBatchAs(operations, owner.credential)
+}
+
+// WatchDirectory watches a directory, and its subdirectories if recursive is set, calling
+// callback for every event not matching the gitignore-style ignore patterns. With gitignore
+// set, the patterns of the .gitignore file of the directory and the .git directory are
+// ignored as well.
+func (h *FileSystemHandler) WatchDirectory(path string, recursive bool, ignore []string, gitignore bool, callback func(event FileEvent)) (func(), error) {
+	return h.watch(path, recursive, ignore, gitignore, func(event filesystem.WatchEvent) {
+		callback(newFileEvent(event))
+	})
+}
+
+// WatchDirectoryBatched watches a directory like WatchDirectory, calling callback with
+// batches of the events received within debounce of each other, the events of a path
+// being merged, see filesystem.EventBatcher
+func (h *FileSystemHandler) WatchDirectoryBatched(path string, recursive bool, ignore []string, gitignore bool, debounce time.Duration, callback func(events []FileEvent)) (func(), error) {
+	batcher := filesystem.NewEventBatcher(debounce, func(events []filesystem.WatchEvent) {
+		batch := make([]FileEvent, len(events))
+		for i, event := range events {
+			batch[i] = newFileEvent(event)
+		}
+		callback(batch)
+	})
+	stop, err := h.watch(path, recursive, ignore, gitignore, batcher.Add)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stop()
+		batcher.Stop()
+	}, nil
+}
+
+// WatchFile watches a single file, calling callback for each of its events. With the
+// content mode full or diff, the events include the content of the file or its diff
+// since the previous event, see filesystem.ContentTracker.
+func (h *FileSystemHandler) WatchFile(path string, content string, callback func(event FileEvent)) (func(), error) {
+	addContent, err := h.fileEventContent(path, content)
+	if err != nil {
+		return nil, err
+	}
+	return h.fs.WatchFile(path, func(event fsnotify.Event) {
+		callback(addContent(newFileEvent(filesystem.WatchEvent{Event: event})))
+	})
+}
+
+// WatchFileBatched watches a single file like WatchFile, calling callback with the events
+// received within debounce of each other merged into one. The content is read once per
+// batch.
+func (h *FileSystemHandler) WatchFileBatched(path string, content string, debounce time.Duration, callback func(events []FileEvent)) (func(), error) {
+	addContent, err := h.fileEventContent(path, content)
+	if err != nil {
+		return nil, err
+	}
+	batcher := filesystem.NewEventBatcher(debounce, func(events []filesystem.WatchEvent) {
+		batch := make([]FileEvent, len(events))
+		for i, event := range events {
+			batch[i] = addContent(newFileEvent(event))
+		}
+		callback(batch)
+	})
+	stop, err := h.fs.WatchFile(path, func(event fsnotify.Event) {
+		batcher.Add(filesystem.WatchEvent{Event: event})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stop()
+		batcher.Stop()
+	}, nil
+}
+
+// fileEventContent returns the function adding the content of a watched file to its
+// events for the content mode, which leaves them as is without one
+func (h *FileSystemHandler) fileEventContent(path string, content string) (func(event FileEvent) FileEvent, error) {
+	if content == "" {
+		return func(event FileEvent) FileEvent { return event }, nil
+	}
+	tracker, err := h.fs.NewContentTracker(path, content)
+	if err != nil {
+		return nil, err
+	}
+	return func(event FileEvent) FileEvent {
+		delta := tracker.Next()
+		event.Content = delta.Content
+		event.Diff = delta.Diff
+		event.ContentOmitted = delta.Omitted
+		return event
+	}, nil
+}
+
+// defaultWatchDebounce is the debounce delay of batched watches without one
+const defaultWatchDebounce = 100 * time.Millisecond
+
+// maxWatchDebounce is the longest debounce delay of watches
+const maxWatchDebounce = time.Minute
+
+// WatchDebounce returns the debounce delay of a watch from its debounceMs parameter, 0
+// when its events are sent as they come
+func WatchDebounce(debounceMs int, batch bool) (time.Duration, error) {
+	debounce := time.Duration(debounceMs) * time.Millisecond
+	if debounce < 0 || debounce > maxWatchDebounce {
+		return 0, apierror.Newf(apierror.CodeInvalidRequest, "debounceMs must be between 0 and %d", maxWatchDebounce.Milliseconds())
+	}
+	if debounce == 0 && batch {
+		debounce = defaultWatchDebounce
+	}
+	return debounce, nil
+}
+
+// newFileEvent returns the FileEvent of a watcher event, with the current metadata of
+// the file. Errors of the watch are events with Error set, for the watched directory.
+func newFileEvent(event filesystem.WatchEvent) FileEvent {
+	if event.Err != nil {
+		message := event.Err.Error()
+		return FileEvent{
+			Op:    "ERROR",
+			Name:  filepath.Base(event.Name),
+			Path:  filepath.Dir(event.Name),
+			Error: &message,
+		}
+	}
+
+	fileEvent := FileEvent{
+		Op:    event.Op.String(),
+		Name:  filepath.Base(event.Name),
+		Path:  filepath.Dir(event.Name),
+		Error: nil,
+	}
+	if event.From != "" {
+		// The CREATE of the new path is part of the move
+		fileEvent.Op = "MOVED"
+		if others := event.Op &^ fsnotify.Create; others != 0 {
+			fileEvent.Op += "|" + others.String()
+		}
+		fileEvent.From = event.From
+		fileEvent.To = event.Name
+	}
+
+	if info, err := os.Stat(event.Name); err == nil {
+		size := info.Size()
+		modTime := info.ModTime()
+		fileEvent.Size = &size
+		fileEvent.ModTime = &modTime
+		fileEvent.IsDir = info.IsDir()
+	}
+	return fileEvent
+}
+
+// watch watches a directory for WatchDirectory and WatchDirectoryBatched. The RENAME
+// and CREATE events of a file moved within the directory are paired into one, see
+// filesystem.RenamePairer.
+func (h *FileSystemHandler) watch(path string, recursive bool, ignore []string, gitignore bool, callback func(event filesystem.WatchEvent)) (func(), error) {
+	absPath, err := h.fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := ignore
+	if gitignore {
+		gitignorePatterns, err := filesystem.LoadGitignore(absPath)
+		if err != nil {
+			return nil, err
+		}
+		// User patterns come last so they can override the .gitignore ones
+		patterns = append(append([]string{".git/"}, gitignorePatterns...), ignore...)
+	}
+	matcher := filesystem.NewIgnoreMatcher(patterns)
+
+	shouldIgnore := func(eventPath string) bool {
+		rel, err := filepath.Rel(absPath, eventPath)
+		if err != nil {
+			return false
+		}
+		info, err := os.Stat(eventPath)
+		return matcher.Match(rel, err == nil && info.IsDir())
+	}
+
+	pairer := filesystem.NewRenamePairer(callback)
+	onEvent := func(event fsnotify.Event) {
+		if shouldIgnore(event.Name) {
+			return
+		}
+		pairer.Add(event)
+	}
+
+	var stop func()
+	if recursive {
+		// Ignored directories are not watched at all, nothing below them can be
+		// re-included
+		skipDir := func(dir string) bool {
+			rel, err := filepath.Rel(absPath, dir)
+			return err == nil && rel != "." && matcher.Match(rel, true)
+		}
+		onError := func(err error) {
+			callback(filesystem.WatchEvent{Event: fsnotify.Event{Name: absPath}, Err: err})
+		}
+		stop, err = h.fs.WatchDirectoryRecursive(path, skipDir, onEvent, onError)
+	} else {
+		stop, err = h.fs.WatchDirectory(path, onEvent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stop()
+		pairer.Stop()
+	}, nil
+}
+
+// checkIfMatch checks the If-Match header of a write against the ETag of the target file.
+// It sends a 412 and returns false when the file changed since the client read it.
+func (h *FileSystemHandler) checkIfMatch(c *gin.Context, ifMatch string) bool {
+	path, err := lib.FormatPath(h.extractPathFromRequest(c))
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return false
+	}
+	if err := h.fs.CheckIfMatch(path, ifMatch); err != nil {
+		if errors.Is(err, filesystem.ErrPreconditionFailed) {
+			h.SendError(c, http.StatusPreconditionFailed, err)
+		} else {
+			h.SendError(c, http.StatusUnprocessableEntity, err)
+		}
+		return false
+	}
+	return true
+}
+
+// setETag sets the ETag header to the ETag of the file at path, if it can be computed
+func (h *FileSystemHandler) setETag(c *gin.Context, path string) {
+	if etag, err := h.fs.GetETag(path); err == nil {
+		c.Header("ETag", etag)
+	}
+}
+
+// sendfileWriter lets files be copied to the connection by the kernel with sendfile:
+// gin's writer does not implement io.ReaderFrom, which the writer of net/http does
+type sendfileWriter struct {
+	gin.ResponseWriter
+	// sent counts the bytes written by ReadFrom, which gin's writer does not see
+	sent int64
+}
+
+// ReadFrom writes the content of r to the response, with the ReadFrom of the writer of
+// net/http when it is reachable
+func (w *sendfileWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	w.WriteHeaderNow()
+	defer func() { w.sent += n }()
+
+	var writer http.ResponseWriter = w.ResponseWriter
+	for {
+		if readerFrom, ok := writer.(io.ReaderFrom); ok {
+			return readerFrom.ReadFrom(r)
+		}
+		unwrapper, ok := writer.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return io.Copy(w.ResponseWriter, r)
+		}
+		writer = unwrapper.Unwrap()
+	}
+}
+
+// Size returns the number of bytes of the body written
+func (w *sendfileWriter) Size() int {
+	return w.ResponseWriter.Size() + int(w.sent)
+}
+
+// CountMultipartUploads returns the number of multipart uploads in flight
+func (h *FileSystemHandler) CountMultipartUploads() int {
+	if h.multipartManager == nil {
+		return 0
+	}
+	return len(h.multipartManager.ListUploads())
+}
+
 // HandleFileSystemRequest handles GET requests to /filesystem/:path
 // It returns either file content or directory listing depending on the path
 // For files, the response format depends on the Accept header:
@@ -193,14 +636,35 @@ func (h *FileSystemHandler) DeleteFile(path string) error {
 // - download=true query parameter forces download mode
 // @Summary Get file or directory information
 // @Description Get content of a file or listing of a directory. Use Accept header to control response format for files.
+// @Description In download mode the Range and conditional headers are supported, and the file is sent by the kernel with sendfile. In JSON mode offset and length read part of a file, a negative offset reading from the end.
+// @Description File responses carry an ETag header, the quoted sha256 of the whole file content. The MIME type of a file is detected from its extension, or else from its first 512 bytes: it is the Content-Type of downloads and the contentType of JSON responses.
 // @Tags filesystem
 // @Accept json
 // @Produce json,octet-stream
 // @Param path path string true "File or directory path"
 // @Param download query boolean false "Force download mode for files"
+// @Param contentType query string false "Content-Type of the response, instead of the type detected from the extension or content of the file (download mode)"
+// @Param Range header string false "Byte range to download, e.g. bytes=0-1023 (download mode)"
+// @Param If-None-Match header string false "ETag of a cached copy, answered with 304 when the file is unchanged (download mode)"
+// @Param If-Modified-Since header string false "Date of a cached copy, answered with 304 when the file was not modified since (download mode)"
+// @Param If-Range header string false "ETag or date the Range applies to, the whole file being sent if it changed (download mode)"
+// @Param recursive query boolean false "List subdirectories recursively, nesting their content (directories)"
+// @Param maxDepth query integer false "Number of levels listed recursively, unlimited if not set (directories)"
+// @Param glob query string false "Comma separated globs of the files to list recursively, e.g. *.go (directories)"
+// @Param includeHidden query boolean false "List entries whose name starts with a dot when listing recursively (directories)" default(true)
+// @Param limit query integer false "List the entries of a directory flat, page by page, at most limit per page (default: 1000, max: 10000). Subdirectories are followed by their content when listing recursively, with globs only the matching files are listed (directories)"
+// @Param pageToken query string false "nextPageToken of the previous page, to list the next one (directories)"
+// @Param stream query boolean false "Stream the entries of a directory flat as they are read, one JSON entry per line (application/x-ndjson). An error while streaming is sent as a last line with an error field (directories)"
+// @Param offset query integer false "Byte offset to read from, negative to read from the end of the file (JSON mode)"
+// @Param length query integer false "Number of bytes to read, the rest of the file if not set (JSON mode)"
+// @Param encoding query string false "Encoding of the content: utf-8, or base64 to read binary content. By default utf-8 for text files and base64 for binary files (JSON mode)" Enums(utf-8, base64)
 // @Success 200 {file} file "File content (download mode)"
+// @Success 206 {file} file "Partial file content (download mode with Range header)"
+// @Header 200 {string} ETag "Quoted sha256 of the file content, to use in If-Match when writing the file"
 // @Success 200 {object} filesystem.FileWithContent "File content (JSON mode)"
 // @Success 200 {object} filesystem.Directory "Directory listing"
+// @Success 200 {object} filesystem.DirectoryPage "Page of a directory listing (with limit or pageToken)"
+// @Success 200 {object} filesystem.Entry "Directory entry, one per line (with stream)"
 // @Failure 404 {object} ErrorResponse "File or directory not found"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
 // @Failure 500 {object} ErrorResponse "Internal server error"
@@ -238,7 +702,7 @@ func (h *FileSystemHandler) HandleGetFile(c *gin.Context) {
 		return
 	}
 
-	h.SendError(c, http.StatusNotFound, fmt.Errorf("file or directory not found"))
+	h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "file or directory not found"))
 }
 
 // handleReadFile handles requests to read a file
@@ -270,79 +734,72 @@ func (h *FileSystemHandler) handleReadFile(c *gin.Context, path string) {
 	}
 
 	if wantsDownload {
-		// Stream binary content directly from disk (no memory buffering)
-		absPath, err := h.fs.GetAbsolutePath(path)
-		if err != nil {
-			h.SendError(c, http.StatusBadRequest, err)
-			return
-		}
-
-		// Get file info for headers
-		info, err := os.Stat(absPath)
+		// Stream binary content directly from disk (no memory buffering), mounted files
+		// are read from their backend
+		file, info, err := h.fs.OpenFile(path)
 		if err != nil {
+			if errors.Is(err, filesystem.ErrIsDirectory) {
+				h.SendError(c, http.StatusBadRequest, fmt.Errorf("path is a directory, not a file"))
+				return
+			}
 			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error reading file: %w", err))
 			return
 		}
+		defer file.Close()
 
-		if info.IsDir() {
-			h.SendError(c, http.StatusBadRequest, fmt.Errorf("path is a directory, not a file"))
+		filename := filepath.Base(path)
+		contentType := c.Query("contentType")
+		if contentType != "" {
+			if _, _, err := mime.ParseMediaType(contentType); err != nil {
+				h.SendError(c, http.StatusBadRequest, apierror.Newf(apierror.CodeInvalidRequest, "invalid contentType: %w", err))
+				return
+			}
+		} else if contentType, err = filesystem.ReaderContentType(filename, file); err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error reading file: %w", err))
 			return
 		}
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Header("Content-Type", contentType)
 
-		filename := filepath.Base(path)
+		// ServeContent answers If-None-Match, If-Match and If-Range against the ETag, and
+		// If-Modified-Since against the modification time
+		h.setETag(c, path)
 
-		// Set appropriate Content-Type based on file extension
-		contentType := "application/octet-stream"
-		ext := filepath.Ext(filename)
-		switch ext {
-		case ".txt", ".log":
-			contentType = "text/plain"
-		case ".html", ".htm":
-			contentType = "text/html"
-		case ".css":
-			contentType = "text/css"
-		case ".js":
-			contentType = "application/javascript"
-		case ".json":
-			contentType = "application/json"
-		case ".xml":
-			contentType = "application/xml"
-		case ".pdf":
-			contentType = "application/pdf"
-		case ".zip":
-			contentType = "application/zip"
-		case ".tar":
-			contentType = "application/x-tar"
-		case ".gz":
-			contentType = "application/gzip"
-		case ".jpg", ".jpeg":
-			contentType = "image/jpeg"
-		case ".png":
-			contentType = "image/png"
-		case ".gif":
-			contentType = "image/gif"
-		case ".svg":
-			contentType = "image/svg+xml"
-		}
+		// ServeContent sets Content-Length and answers Range requests with 206, the file
+		// is sent with sendfile
+		writer := &sendfileWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		http.ServeContent(writer, c.Request, filename, info.ModTime(), file)
+		return
+	}
 
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-		c.Header("Content-Type", contentType)
-		c.Header("Content-Length", strconv.FormatInt(info.Size(), 10))
+	encoding := c.Query("encoding")
+	if _, err := filesystem.ContentEncoding(encoding, nil); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
 
-		// Open file and stream directly to response
-		file, err := os.Open(absPath)
+	// JSON mode with a range: read only the requested part of the file
+	if c.Query("offset") != "" || c.Query("length") != "" {
+		offset, err := strconv.ParseInt(h.GetQueryParam(c, "offset", "0"), 10, 64)
 		if err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error opening file: %w", err))
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid offset: must be an integer"))
+			return
+		}
+		length, err := strconv.ParseInt(h.GetQueryParam(c, "length", "0"), 10, 64)
+		if err != nil || length < 0 {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid length: must be a positive integer"))
 			return
 		}
-		defer file.Close()
 
-		// Stream file content directly to HTTP response (no memory buffering)
-		c.Status(http.StatusOK)
-		if _, err := io.Copy(c.Writer, file); err != nil {
-			logrus.Errorf("Error streaming file: %v", err)
+		file, err := h.fs.ReadFileRange(path, offset, length)
+		if err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error reading file: %w", err))
 			return
 		}
+		file.Encoding = encoding
+		h.setETag(c, path)
+		h.SendJSON(c, http.StatusOK, file)
 		return
 	}
 
@@ -354,43 +811,133 @@ func (h *FileSystemHandler) handleReadFile(c *gin.Context, path string) {
 	}
 
 	// Default behavior: return JSON response
+	file.Encoding = encoding
+	c.Header("ETag", filesystem.ContentETag(file.Content))
 	h.SendJSON(c, http.StatusOK, file)
 }
 
-// handleListDirectory handles requests to list a directory
-func (h *FileSystemHandler) handleListDirectory(c *gin.Context, path string) {
-	dir, err := h.ListDirectory(path)
+// HandleGetArchive handles GET requests to /filesystem/:path/archive
+// @Summary Download a directory as an archive
+// @Description Streams a compressed archive (tar.gz or zip) of a directory and all its contents. With async=true the archive is written by a background job instead, downloaded from /jobs/{id}/output once completed.
+// @Tags filesystem
+// @Produce octet-stream
+// @Param path path string true "Directory path"
+// @Param format query string false "Archive format" Enums(tar.gz, zip) default(tar.gz)
+// @Param async query boolean false "Write the archive in a background job"
+// @Success 200 {file} file "Archive content"
+// @Success 202 {object} jobs.Job "Job started (async)"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "Directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/{path}/archive [get]
+func (h *FileSystemHandler) HandleGetArchive(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
 	if err != nil {
-		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error listing directory: %w", err))
+		h.SendError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	h.SendJSON(c, http.StatusOK, dir)
+	format := h.GetQueryParam(c, "format", filesystem.ArchiveFormatTarGz)
+	contentType := ""
+	switch format {
+	case filesystem.ArchiveFormatTarGz:
+		contentType = "application/gzip"
+	case filesystem.ArchiveFormatZip:
+		contentType = "application/zip"
+	default:
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("unsupported archive format '%s', expected 'tar.gz' or 'zip'", format))
+		return
+	}
+
+	isDir, err := h.DirectoryExists(path)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !isDir {
+		h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "directory not found"))
+		return
+	}
+
+	name := filepath.Base(h.fs.ResolveDisplayPath(path))
+	if name == "/" || name == "." {
+		name = "root"
+	}
+
+	if wantsAsync(c) {
+		h.startJob(c, jobs.TypeArchive, h.fs.ResolveDisplayPath(path), func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+			return nil, h.writeArchiveOutput(ctx, reporter, path, format, name+"."+format)
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, format))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+
+	// The archive is streamed, so errors after this point can only be logged
+	if err := h.fs.WriteArchive(path, format, c.Writer); err != nil {
+		logrus.Errorf("Error streaming archive: %v", err)
+	}
 }
 
-// HandleCreateOrUpdateFile handles PUT requests to /filesystem/:path
-// @Summary Create or update a file or directory
-// @Description Create or update a file or directory
+// writeArchiveOutput writes the archive of an archive job to a temporary file, the output
+// of the job
+func (h *FileSystemHandler) writeArchiveOutput(ctx context.Context, reporter *jobs.Reporter, path string, format string, name string) error {
+	file, err := os.CreateTemp("", "archive-*."+format)
+	if err != nil {
+		return err
+	}
+	// Set first for the file to be removed if the job fails
+	reporter.SetOutput(file.Name(), name, 0)
+
+	err = h.fs.WriteArchiveWithProgress(path, format, jobs.Writer(ctx, file), reporter.Progress)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		return err
+	}
+	reporter.SetOutput(file.Name(), name, info.Size())
+	return nil
+}
+
+// splitQueryList returns the values of a query parameter given either repeated or comma separated
+func splitQueryList(c *gin.Context, param string) []string {
+	var values []string
+	for _, value := range c.QueryArray(param) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// HandleSearch handles GET requests to /filesystem/:path/search
+// @Summary Search files
+// @Description Search the files under a directory, either by fuzzy matching their name or by looking for the query in their content (case insensitive). Binary files are skipped in content searches.
 // @Tags filesystem
-// @Accept json
 // @Produce json
-// @Param path path string true "File or directory path"
-// @Param request body FileRequest true "File or directory details"
-// @Success 200 {object} SuccessResponse "Success message"
+// @Param path path string true "Directory to search in, use /filesystem/search for the working directory"
+// @Param q query string true "Search query"
+// @Param type query string false "Search type" Enums(name, content) default(name)
+// @Param include query string false "Comma separated globs of the files to include, e.g. *.go,*.ts"
+// @Param exclude query string false "Comma separated globs of the files and directories to exclude, e.g. node_modules,.git"
+// @Param maxResults query integer false "Maximum number of matches" default(100)
+// @Success 200 {object} filesystem.SearchResult "Search results"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "Directory not found"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
-// @Failure 500 {object} ErrorResponse "Internal server error"
-// @Router /filesystem/{path} [put]
-func (h *FileSystemHandler) HandleCreateOrUpdateFile(c *gin.Context) {
-	contentType := c.GetHeader("Content-Type")
-	if strings.HasPrefix(contentType, "multipart/form-data") {
-		h.HandleCreateOrUpdateBinary(c)
-	} else {
-		h.HandleCreateOrUpdateFileJSON(c)
-	}
-}
-
-func (h *FileSystemHandler) HandleCreateOrUpdateFileJSON(c *gin.Context) {
+// @Router /filesystem/{path}/search [get]
+func (h *FileSystemHandler) HandleSearch(c *gin.Context) {
 	path := h.extractPathFromRequest(c)
 
 	path, err := lib.FormatPath(path)
@@ -399,128 +946,1083 @@ func (h *FileSystemHandler) HandleCreateOrUpdateFileJSON(c *gin.Context) {
 		return
 	}
 
-	var request struct {
-		Content     string `json:"content"`
-		IsDirectory bool   `json:"isDirectory"`
-		Permissions string `json:"permissions"`
+	opts := filesystem.SearchOptions{
+		Query:   c.Query("q"),
+		Type:    h.GetQueryParam(c, "type", filesystem.SearchTypeName),
+		Include: splitQueryList(c, "include"),
+		Exclude: splitQueryList(c, "exclude"),
+	}
+	if maxResults := c.Query("maxResults"); maxResults != "" {
+		opts.MaxResults, err = strconv.Atoi(maxResults)
+		if err != nil || opts.MaxResults <= 0 {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid maxResults: must be a positive integer"))
+			return
+		}
 	}
 
-	if err := h.BindJSON(c, &request); err != nil {
-		h.SendError(c, http.StatusBadRequest, err)
+	result, err := h.Search(path, opts)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	// Parse permissions or use appropriate defaults
-	var permissions os.FileMode
-	if request.Permissions != "" {
-		permInt, err := strconv.ParseUint(request.Permissions, 8, 32)
-		if err != nil {
-			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid permissions format '%s': %w", request.Permissions, err))
-			return
-		}
-		permissions = os.FileMode(permInt)
-	} else {
-		// Use appropriate defaults: 0755 for directories, 0644 for files
-		if request.IsDirectory {
-			permissions = 0755
-		} else {
-			permissions = 0644
-		}
+	h.SendJSON(c, http.StatusOK, result)
+}
+
+// Search searches the files under the directory at path by name or content, see
+// filesystem.Filesystem.Search. It fails with INVALID_REQUEST for invalid options and
+// FS_NOT_FOUND when the directory doesn't exist.
+func (h *FileSystemHandler) Search(path string, opts filesystem.SearchOptions) (*filesystem.SearchResult, error) {
+	if opts.Query == "" {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "query parameter 'q' is required")
+	}
+	if opts.Type == "" {
+		opts.Type = filesystem.SearchTypeName
+	}
+	if opts.Type != filesystem.SearchTypeName && opts.Type != filesystem.SearchTypeContent {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "unsupported search type '%s', expected 'name' or 'content'", opts.Type)
+	}
+	if opts.MaxResults < 0 {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "invalid maxResults: must be a positive integer")
 	}
 
-	// Handle directory creation
-	if request.IsDirectory {
-		// Directories need different default permissions than files
-		if request.Permissions == "" {
-			permissions = 0755
-		}
-		if err := h.CreateDirectory(path, permissions); err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error creating directory: %w", err))
-			return
-		}
-		h.SendSuccessWithPath(c, path, "Directory created successfully")
+	isDir, err := h.DirectoryExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		return nil, apierror.New(apierror.CodeFSNotFound, "directory not found")
+	}
+	return h.fs.Search(path, opts)
+}
+
+// HandleGetStat handles GET requests to /filesystem/:path/stat
+// @Summary Get file metadata
+// @Description Get the size, permissions, modification time, owner and symlink target of a file, directory or symlink without reading its content. Checksums of the content can be requested for change detection.
+// @Tags filesystem
+// @Produce json
+// @Param path path string true "File, directory or symlink path"
+// @Param checksum query string false "Comma separated checksum algorithms to compute" Enums(md5, sha256)
+// @Success 200 {object} filesystem.FileStat "File metadata"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "File not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/{path}/stat [get]
+func (h *FileSystemHandler) HandleGetStat(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	checksums := splitQueryList(c, "checksum")
+	for _, algorithm := range checksums {
+		if algorithm != filesystem.ChecksumMD5 && algorithm != filesystem.ChecksumSHA256 {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("unsupported checksum algorithm '%s', expected 'md5' or 'sha256'", algorithm))
+			return
+		}
+	}
+
+	stat, err := h.fs.Stat(path, checksums)
+	if err != nil {
+		if os.IsNotExist(err) {
+			h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "file not found"))
+			return
+		}
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, stat)
+}
+
+// SyncManifestRequest represents the manifest of the files a directory must contain
+type SyncManifestRequest struct {
+	Files []filesystem.SyncEntry `json:"files"`
+} // @name SyncManifestRequest
+
+// SyncResponse represents the files written and deleted by a sync upload
+type SyncResponse struct {
+	Path     string   `json:"path" example:"/home/user/app" binding:"required"`
+	Uploaded []string `json:"uploaded" example:"src/main.go" binding:"required"`
+	Deleted  []string `json:"deleted" example:"src/old.go" binding:"required"`
+} // @name SyncResponse
+
+// HandleSync handles POST requests to /filesystem/sync/:path
+// @Summary Sync a directory
+// @Description Synchronize a directory with a local copy in two steps, so only changed files are transferred.
+// @Description
+// @Description 1. Send a JSON manifest of every file (path relative to the directory and sha256 of the content). The response lists the files to upload (missing or different) and the files to delete (not in the manifest). Nothing is modified.
+// @Description 2. Send a multipart/form-data request with one "file" part per file to upload, the relative path being the part filename, and one "delete" field per relative path to delete.
+// @Tags filesystem
+// @Accept json,mpfd
+// @Produce json
+// @Param path path string true "Directory path"
+// @Param request body SyncManifestRequest false "Manifest of the local files (JSON step)"
+// @Param async query boolean false "Compare the manifest in a background job, whose result is the plan (JSON step)"
+// @Success 200 {object} filesystem.SyncPlan "Files to upload and delete (JSON step)"
+// @Success 202 {object} jobs.Job "Job started (JSON step, async)"
+// @Success 201 {object} SyncResponse "Files written and deleted (multipart step)"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/sync/{path} [post]
+func (h *FileSystemHandler) HandleSync(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/form-data") {
+		if !h.checkLocks(c, path) {
+			return
+		}
+		h.handleSyncUpload(c, path)
+		return
+	}
+
+	var req SyncManifestRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if wantsAsync(c) {
+		h.startJob(c, jobs.TypeSync, h.fs.ResolveDisplayPath(path), func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+			return h.fs.PlanSync(path, req.Files)
+		})
+		return
+	}
+
+	plan, err := h.fs.PlanSync(path, req.Files)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, plan)
+}
+
+// handleSyncUpload writes and deletes the files of the multipart step of a sync
+func (h *FileSystemHandler) handleSyncUpload(c *gin.Context, path string) {
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("error reading multipart data: %w", err))
+		return
+	}
+
+	response := SyncResponse{Path: h.fs.ResolveDisplayPath(path), Uploaded: []string{}, Deleted: []string{}}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("error reading multipart part: %w", err))
+			return
+		}
+
+		switch part.FormName() {
+		case "file":
+			// part.FileName() strips directories, the relative path is read from the header
+			_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+			rel := params["filename"]
+			if rel == "" {
+				_ = part.Close()
+				h.SendError(c, http.StatusBadRequest, fmt.Errorf("missing filename in 'file' part"))
+				return
+			}
+			if err := h.fs.SyncWriteFile(path, rel, part); err != nil {
+				_ = part.Close()
+				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error writing %s: %w", rel, err))
+				return
+			}
+			response.Uploaded = append(response.Uploaded, rel)
+		case "delete":
+			data, _ := io.ReadAll(part)
+			rel := strings.TrimSpace(string(data))
+			if err := h.fs.SyncDeleteFile(path, rel); err != nil {
+				_ = part.Close()
+				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error deleting %s: %w", rel, err))
+				return
+			}
+			response.Deleted = append(response.Deleted, rel)
+		}
+		_ = part.Close()
+	}
+
+	h.SendJSON(c, http.StatusCreated, response)
+}
+
+// HandleBatch handles POST requests to /filesystem/batch
+// @Summary Apply filesystem operations atomically
+// @Description Apply an ordered list of write, mkdir, delete, move and chmod operations with all-or-nothing semantics. Writes are staged to temporary files and renamed into place, replaced and deleted entries are kept aside until the whole batch succeeds, and if an operation fails every operation applied before it is rolled back.
+// @Description
+// @Description Fields per operation: write uses content and permissions (default 0644), mkdir uses permissions (default 0755), delete uses recursive for non-empty directories, move uses destination (an existing file there is replaced), chmod uses permissions.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param request body BatchRequest true "Operations to apply in order"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
+// @Success 200 {object} BatchResponse "Batch applied"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "An operation failed and the batch was rolled back"
+// @Router /filesystem/batch [post]
+func (h *FileSystemHandler) HandleBatch(c *gin.Context) {
+	var req BatchRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Operations) == 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("at least one operation is required"))
+		return
+	}
+
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		formattedPath, err := lib.FormatPath(op.Path)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		op.Path = formattedPath
+		if op.Destination != "" {
+			formattedDestination, err := lib.FormatPath(op.Destination)
+			if err != nil {
+				h.SendError(c, http.StatusBadRequest, err)
+				return
+			}
+			op.Destination = formattedDestination
+		}
+	}
+
+	if err := h.fs.CheckBatchLocks(lockHolder(c), req.Operations); err != nil {
+		h.SendError(c, http.StatusLocked, err)
+		return
+	}
+	owner, ok := h.fileOwner(c)
+	if !ok {
+		return
+	}
+	if err := h.fs.ApplyBatchAs(req.Operations, owner.credential); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, BatchResponse{
+		Applied: len(req.Operations),
+		Message: "Batch applied successfully",
+	})
+}
+
+// HandleDiff handles POST requests to /filesystem/diff
+// @Summary Compare files
+// @Description Produce the unified diff between two files, two directories compared file by file, or a file and inline content, with its hunks. Binary files, detected by a NUL byte in their first 8000 bytes, and files larger than 8MiB are only reported as differing unless text is set for the binary ones.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param request body DiffRequest true "Paths or path and content to compare"
+// @Success 200 {object} filesystem.DiffResult "Diff"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 404 {object} ErrorResponse "File or directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/diff [post]
+func (h *FileSystemHandler) HandleDiff(c *gin.Context) {
+	var req DiffRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if (req.To == "") == (req.Content == nil) {
+		h.SendError(c, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "either to or content is required"))
+		return
+	}
+	opts := filesystem.DiffOptions{Context: filesystem.DefaultDiffContext, Text: req.Text, Exclude: req.Exclude}
+	if req.Context != nil {
+		if *req.Context < 0 || *req.Context > filesystem.MaxDiffContext {
+			h.SendError(c, http.StatusBadRequest, apierror.Newf(apierror.CodeInvalidRequest, "context must be between 0 and %d", filesystem.MaxDiffContext))
+			return
+		}
+		opts.Context = *req.Context
+	}
+	from, err := lib.FormatPath(req.From)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var result *filesystem.DiffResult
+	if req.Content != nil {
+		result, err = h.fs.DiffContent(from, []byte(*req.Content), opts)
+	} else {
+		to, formatErr := lib.FormatPath(req.To)
+		if formatErr != nil {
+			h.SendError(c, http.StatusBadRequest, formatErr)
+			return
+		}
+		result, err = h.fs.DiffPaths(from, to, opts)
+	}
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, result)
+}
+
+// HandleApplyPatch handles POST requests to /filesystem/patch
+// @Summary Apply a patch
+// @Description Apply a unified diff, or a git patch with renames, copies, mode changes, created and deleted files, like git apply or patch. Each hunk applies where its lines are found nearest to the line it expects, and the result of every hunk is reported. Binary patches are not supported.
+// @Description
+// @Description The changes are written together or not at all. When a hunk does not apply, nothing is written unless reject is skip or file, which write the hunks which apply, file also writing the others to a .rej file next to their file. Rejected hunks are reported with applied set to false rather than as an error.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param request body ApplyPatchRequest true "Patch to apply"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
+// @Success 200 {object} filesystem.PatchResult "Result of every hunk"
+// @Failure 400 {object} ErrorResponse "Invalid request or patch"
+// @Failure 404 {object} ErrorResponse "Directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/patch [post]
+func (h *FileSystemHandler) HandleApplyPatch(c *gin.Context) {
+	var req ApplyPatchRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	directory, err := lib.FormatPath(req.Directory)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	owner, ok := h.fileOwner(c)
+	if !ok {
+		return
+	}
+	opts := filesystem.PatchOptions{Strip: -1, DryRun: req.DryRun, Reject: req.Reject, Holder: lockHolder(c), Owner: owner.credential}
+	if req.Strip != nil {
+		if *req.Strip < 0 {
+			h.SendError(c, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "strip cannot be negative"))
+			return
+		}
+		opts.Strip = *req.Strip
+	}
+
+	result, err := h.fs.ApplyPatch(directory, req.Patch, opts)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, result)
+}
+
+// HandleSetPermissions handles POST requests to /filesystem/:path/permissions
+// @Summary Change file permissions and ownership
+// @Description Change the mode, owner and/or group of a file, directory or symlink, optionally recursively. Owner and group are names or numeric ids.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "File or directory path"
+// @Param request body PermissionsRequest true "Mode and ownership to set"
+// @Success 200 {object} SuccessResponse "Success message"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "File not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/{path}/permissions [post]
+func (h *FileSystemHandler) HandleSetPermissions(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req PermissionsRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.SetPermissions(path, req, lockHolder(c)); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendSuccessWithPath(c, path, "Permissions updated successfully")
+}
+
+// SetPermissions changes the mode, owner and group of a path, leaving the ones empty in
+// req unchanged, for the lock holder holder. It fails with FS_NOT_FOUND when the path
+// doesn't exist.
+func (h *FileSystemHandler) SetPermissions(path string, req PermissionsRequest, holder string) error {
+	if req.Mode == "" && req.Owner == "" && req.Group == "" {
+		return apierror.New(apierror.CodeInvalidRequest, "at least one of mode, owner or group is required")
+	}
+	var mode *os.FileMode
+	if req.Mode != "" {
+		permInt, err := strconv.ParseUint(req.Mode, 8, 32)
+		if err != nil {
+			return apierror.Newf(apierror.CodeInvalidRequest, "invalid mode format '%s': %w", req.Mode, err)
+		}
+		fileMode := os.FileMode(permInt)
+		mode = &fileMode
+	}
+
+	if err := h.fs.CheckLocks(holder, path); err != nil {
+		return err
+	}
+	if err := h.fs.SetPermissions(path, mode, req.Owner, req.Group, req.Recursive); err != nil {
+		if os.IsNotExist(err) {
+			return apierror.New(apierror.CodeFSNotFound, "file not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckQuota returns filesystem.ErrQuotaExceeded if writing size bytes would take the
+// disk usage over the quota set with FILESYSTEM_QUOTA_BYTES
+func (h *FileSystemHandler) CheckQuota(size int64) error {
+	return h.files.CheckQuota(size)
+}
+
+// HandleGetUsage handles GET requests to /filesystem/:path/usage
+// @Summary Get disk usage
+// @Description Get the total, used and free space of the disk holding a directory, the configured quota if any, and the size of each entry of the directory, largest first. When a quota is set with FILESYSTEM_QUOTA_BYTES, writes which would exceed it are rejected with 507.
+// @Tags filesystem
+// @Produce json
+// @Param path path string true "Directory path, use /filesystem/usage for the working directory"
+// @Success 200 {object} filesystem.DiskUsage "Disk usage"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "Directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/{path}/usage [get]
+func (h *FileSystemHandler) HandleGetUsage(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	isDir, err := h.DirectoryExists(path)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !isDir {
+		h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "directory not found"))
+		return
+	}
+
+	usage, err := h.fs.GetUsage(path)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	usage.Quota = h.quota
+
+	h.SendJSON(c, http.StatusOK, usage)
+}
+
+// GrepRequest represents the request body for a content grep
+type GrepRequest struct {
+	Pattern       string   `json:"pattern" example:"func \\w+Handler" binding:"required"`
+	CaseSensitive bool     `json:"caseSensitive" example:"false"`
+	Include       []string `json:"include" example:"*.go"`
+	Exclude       []string `json:"exclude" example:"node_modules"`
+	MaxResults    int      `json:"maxResults" example:"1000"`
+	ContextLines  int      `json:"contextLines" example:"2"`
+} // @name GrepRequest
+
+// HandleGrep handles POST requests to /filesystem/:path/grep
+// @Summary Grep file contents
+// @Description Search the content of the files under a directory for a regular expression and return structured matches with their line number, submatch byte offsets and context lines. Uses ripgrep when installed. Binary files are skipped.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "Directory to search in, use /filesystem/grep for the working directory"
+// @Param request body GrepRequest true "Grep request"
+// @Success 200 {object} filesystem.GrepResult "Grep results"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "Directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/{path}/grep [post]
+func (h *FileSystemHandler) HandleGrep(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req GrepRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.MaxResults < 0 || req.ContextLines < 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("maxResults and contextLines must be positive numbers"))
+		return
+	}
+
+	isDir, err := h.DirectoryExists(path)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !isDir {
+		h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "directory not found"))
+		return
+	}
+
+	result, err := h.fs.Grep(path, filesystem.GrepOptions{
+		Pattern:       req.Pattern,
+		CaseSensitive: req.CaseSensitive,
+		Include:       req.Include,
+		Exclude:       req.Exclude,
+		MaxResults:    req.MaxResults,
+		ContextLines:  req.ContextLines,
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, result)
+}
+
+// ReplaceRequest represents the request body for a search and replace
+type ReplaceRequest struct {
+	Pattern string `json:"pattern" example:"Handle(\\w+)" binding:"required"`
+	// Replacement replaces the matches, $1 and ${name} expanding to the groups of the pattern unless literal is set
+	Replacement string `json:"replacement" example:"Serve$1"`
+	// Literal matches pattern and inserts replacement as they are instead of as a regular expression and a template
+	Literal    bool     `json:"literal" example:"false"`
+	IgnoreCase bool     `json:"ignoreCase" example:"false"`
+	Include    []string `json:"include" example:"*.go"`
+	Exclude    []string `json:"exclude" example:"node_modules"`
+	// DryRun previews the replacements without writing them
+	DryRun bool `json:"dryRun" example:"true"`
+} // @name ReplaceRequest
+
+// HandleReplace handles POST requests to /filesystem/:path/replace
+// @Summary Search and replace in files
+// @Description Replace the matches of a regular expression, or of a literal string, in a file or in the files under a directory filtered by include and exclude globs. Patterns match the whole content of files, so (?m) and (?s) flags can be used. Binary files and files larger than 10MiB are skipped.
+// @Description
+// @Description Every match is returned with its line, column and replacement, along with the unified diff of every changed file. With dryRun nothing is written, to review the changes first. Otherwise the changed files are written atomically: all of them are replaced or none is.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "File or directory to replace in, use /filesystem/replace for the working directory"
+// @Param request body ReplaceRequest true "Replace request"
+// @Success 200 {object} filesystem.ReplaceResult "Replacements"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "File or directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /filesystem/{path}/replace [post]
+func (h *FileSystemHandler) HandleReplace(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ReplaceRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.fs.Replace(path, filesystem.ReplaceOptions{
+		Pattern:     req.Pattern,
+		Replacement: req.Replacement,
+		Literal:     req.Literal,
+		IgnoreCase:  req.IgnoreCase,
+		Include:     req.Include,
+		Exclude:     req.Exclude,
+		DryRun:      req.DryRun,
+		Holder:      lockHolder(c),
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, result)
+}
+
+// parseTreeOptions reads the recursive listing query parameters
+func parseTreeOptions(c *gin.Context) (bool, filesystem.TreeOptions, error) {
+	opts := filesystem.TreeOptions{
+		Globs:         splitQueryList(c, "glob"),
+		IncludeHidden: c.Query("includeHidden") != "false",
+	}
+	if maxDepth := c.Query("maxDepth"); maxDepth != "" {
+		depth, err := strconv.Atoi(maxDepth)
+		if err != nil || depth < 0 {
+			return false, opts, fmt.Errorf("invalid maxDepth: must be a positive integer")
+		}
+		opts.MaxDepth = depth
+	}
+	return c.Query("recursive") == "true", opts, nil
+}
+
+// Sizes of the pages of directory listings
+const (
+	defaultListPageSize = 1000
+	maxListPageSize     = 10000
+)
+
+// streamFlushInterval is the number of streamed entries sent at once
+const streamFlushInterval = 256
+
+// handleListEntries lists a directory page by page when limit or pageToken is set, or
+// streams its entries as NDJSON when stream is set, instead of returning the whole
+// listing at once. It returns false for the other listings.
+func (h *FileSystemHandler) handleListEntries(c *gin.Context, path string, recursive bool, opts filesystem.TreeOptions) bool {
+	pageToken := c.Query("pageToken")
+	if c.Query("stream") == "true" {
+		h.streamEntries(c, path, recursive, opts, pageToken)
+		return true
+	}
+	if c.Query("limit") == "" && pageToken == "" {
+		return false
+	}
+
+	limit := defaultListPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxListPageSize {
+			h.SendError(c, http.StatusBadRequest, apierror.Newf(apierror.CodeInvalidRequest, "invalid limit: must be between 1 and %d", maxListPageSize))
+			return true
+		}
+		limit = n
+	}
+	page, err := h.fs.ListDirectoryPage(path, recursive, opts, pageToken, limit)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error listing directory: %w", err))
+		return true
+	}
+	h.SendJSON(c, http.StatusOK, page)
+	return true
+}
+
+// streamEntries sends the entries of a directory as NDJSON as they are read, until the
+// client disconnects
+func (h *FileSystemHandler) streamEntries(c *gin.Context, path string, recursive bool, opts filesystem.TreeOptions, pageToken string) {
+	ctx := c.Request.Context()
+	encoder := json.NewEncoder(c.Writer)
+	count := 0
+	var writeErr error
+	err := h.fs.WalkDirectory(path, recursive, opts, pageToken, func(entry filesystem.Entry) bool {
+		if count == 0 {
+			c.Writer.Header().Set("Content-Type", "application/x-ndjson")
+			c.Writer.Header().Set("X-Accel-Buffering", "no")
+			c.Writer.WriteHeader(http.StatusOK)
+		}
+		count++
+		if writeErr = encoder.Encode(entry); writeErr != nil {
+			return false
+		}
+		if count%streamFlushInterval == 0 {
+			c.Writer.Flush()
+		}
+		return ctx.Err() == nil
+	})
+	if writeErr != nil || ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		if count == 0 {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error listing directory: %w", err))
+			return
+		}
+		// The status is already sent, the error is the last line
+		_, _ = c.Writer.WriteString(apierror.JSON(fmt.Errorf("error listing directory: %w", err), apierror.CodeUnprocessable) + "\n")
+	}
+	if count == 0 {
+		c.Writer.Header().Set("Content-Type", "application/x-ndjson")
+		c.Writer.WriteHeader(http.StatusOK)
+	}
+	c.Writer.Flush()
+}
+
+// handleListDirectory handles requests to list a directory
+func (h *FileSystemHandler) handleListDirectory(c *gin.Context, path string) {
+	recursive, opts, err := parseTreeOptions(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if h.handleListEntries(c, path, recursive, opts) {
+		return
+	}
+
+	var dir *filesystem.Directory
+	if recursive {
+		dir, err = h.fs.ListDirectoryRecursive(path, opts)
+	} else {
+		dir, err = h.ListDirectory(path)
+	}
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error listing directory: %w", err))
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, dir)
+}
+
+// HandleCreateOrUpdateFile handles PUT requests to /filesystem/:path
+// @Summary Create or update a file or directory
+// @Description Create or update a file or directory. When target is set, a symbolic link to target is created instead, or a hard link if hardlink is set.
+// @Description With append set (or ?append=true for multipart uploads and streamed bodies) the content is added to the end of the file, which is created if needed, instead of replacing it.
+// @Description With ?stream=true the raw request body is written to the file as it is received, without JSON or multipart framing, so that content generated on the fly can be piped with a chunked body.
+// @Description With an If-Match header the write only happens if the file still has one of the given ETags (as returned by reads), so concurrent editors don't silently overwrite each other.
+// @Description The files and directories created by the write are owned by the user of the X-Run-As header, or of RUN_AS.
+// @Tags filesystem
+// @Accept json,octet-stream
+// @Produce json
+// @Param path path string true "File or directory path"
+// @Param If-Match header string false "ETag the file must still have, or * for any existing file"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created by the write, RUN_AS by default"
+// @Param append query boolean false "Append the uploaded file or streamed body to the end of the file (multipart uploads and stream)"
+// @Param stream query boolean false "Write the raw request body to the file as it is received, e.g. a chunked body generated on the fly, instead of a JSON or multipart body"
+// @Param permissions query string false "Permissions of the file created (stream)" default(0644)
+// @Param request body FileRequest true "File or directory details"
+// @Success 200 {object} SuccessResponse "Success message"
+// @Header 200 {string} ETag "ETag of the written file"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 412 {object} ErrorResponse "File changed since it was read"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /filesystem/{path} [put]
+func (h *FileSystemHandler) HandleCreateOrUpdateFile(c *gin.Context) {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		h.conditionalWriteMu.Lock()
+		defer h.conditionalWriteMu.Unlock()
+		if !h.checkIfMatch(c, ifMatch) {
+			return
+		}
+	}
+
+	contentType := c.GetHeader("Content-Type")
+	if c.Query("stream") == "true" {
+		h.handleStreamWrite(c)
+	} else if strings.HasPrefix(contentType, "multipart/form-data") {
+		h.HandleCreateOrUpdateBinary(c)
+	} else {
+		h.HandleCreateOrUpdateFileJSON(c)
+	}
+}
+
+func (h *FileSystemHandler) HandleCreateOrUpdateFileJSON(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var request struct {
+		Content     string `json:"content"`
+		Encoding    string `json:"encoding"`
+		IsDirectory bool   `json:"isDirectory"`
+		Permissions string `json:"permissions"`
+		Target      string `json:"target"`
+		Hardlink    bool   `json:"hardlink"`
+		Append      bool   `json:"append"`
+	}
+
+	if err := h.BindJSON(c, &request); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	owner, ok := h.fileOwner(c)
+	if !ok {
+		return
+	}
+
+	// Handle link creation
+	if request.Hardlink && request.Target == "" {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("target is required to create a hard link"))
+		return
+	}
+	if request.Target != "" {
+		if request.IsDirectory {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("target and isDirectory are mutually exclusive"))
+			return
+		}
+		if err := h.CreateLink(path, request.Target, request.Hardlink, lockHolder(c), owner.runAs); err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if request.Hardlink {
+			h.SendSuccessWithPath(c, path, "Hard link created successfully")
+		} else {
+			h.SendSuccessWithPath(c, path, "Symlink created successfully")
+		}
+		return
+	}
+
+	permissions, err := service.ParsePermissions(request.Permissions, request.IsDirectory)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	var content []byte
+	if !request.IsDirectory {
+		if content, err = filesystem.DecodeContent(request.Content, request.Encoding); err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// Permissions only apply when the file is created
+	result, err := h.Write(service.WriteRequest{
+		Path:        path,
+		Content:     content,
+		Permissions: permissions,
+		IsDirectory: request.IsDirectory,
+		Append:      request.Append,
+		Holder:      lockHolder(c),
+		RunAs:       owner.runAs,
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	switch {
+	case request.IsDirectory:
+		h.SendSuccessWithPath(c, path, "Directory created successfully")
+	case request.Append:
+		c.Header("ETag", result.ETag)
+		h.SendSuccessWithPath(c, path, "File appended successfully")
+	default:
+		c.Header("ETag", result.ETag)
+		h.SendSuccessWithPath(c, path, "File created/updated successfully")
+	}
+}
+
+func (h *FileSystemHandler) HandleCreateOrUpdateBinary(c *gin.Context) {
+	// Get path from form data
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !h.checkLocks(c, path) {
+		return
+	}
+
+	// Use streaming multipart reader to avoid extra buffering/copies
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("error reading multipart data: %w", err))
+		return
+	}
+
+	owner, ok := h.fileOwner(c)
+	if !ok {
+		return
+	}
+	created := owner.track(path)
+
+	var permissions os.FileMode = 0644
+	var wroteFile bool
+	appendMode := c.Query("append") == "true"
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("error reading multipart part: %w", err))
+			return
+		}
+
+		name := part.FormName()
+		filename := part.FileName()
+		if name == "permissions" && filename == "" {
+			// read small permission value
+			data, _ := io.ReadAll(part)
+			if len(data) > 0 {
+				permInt, perr := strconv.ParseUint(strings.TrimSpace(string(data)), 8, 32)
+				if perr != nil {
+					_ = part.Close()
+					h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid permissions format '%s': %w", strings.TrimSpace(string(data)), perr))
+					return
+				}
+				permissions = os.FileMode(permInt)
+			}
+			_ = part.Close()
+			continue
+		}
+
+		if name == "file" && filename != "" && !wroteFile {
+			// Stream directly to disk with requested permissions
+			write := h.fs.WriteFileFromReader
+			if appendMode {
+				write = h.fs.AppendFileFromReader
+			}
+			if err := write(path, part, permissions); err != nil {
+				_ = part.Close()
+				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error writing binary file: %w", err))
+				return
+			}
+			wroteFile = true
+			_ = part.Close()
+			continue
+		}
+
+		// Close any unhandled parts
+		_ = part.Close()
+	}
+
+	if !wroteFile {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("missing 'file' field in multipart form"))
+		return
+	}
+
+	created()
+	h.setETag(c, path)
+	h.SendSuccessWithPath(c, path, "Binary file uploaded successfully")
+}
+
+// handleStreamWrite writes the raw body of a request to a file as it is received, for
+// clients generating the content on the fly with a chunked body
+func (h *FileSystemHandler) handleStreamWrite(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !h.checkLocks(c, path) {
+		return
+	}
+
+	var permissions os.FileMode = 0644
+	if value := c.Query("permissions"); value != "" {
+		permInt, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid permissions format '%s': %w", value, err))
+			return
+		}
+		permissions = os.FileMode(permInt)
+	}
+
+	// The size of chunked bodies is unknown when the quota is checked before the handler
+	body, err := h.fs.LimitToQuota(h.quota, c.Request.Body)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	owner, ok := h.fileOwner(c)
+	if !ok {
+		return
+	}
+	created := owner.track(path)
+
+	write := h.fs.WriteFileFromReader
+	if c.Query("append") == "true" {
+		write = h.fs.AppendFileFromReader
+	}
+	if err := write(path, body, permissions); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error writing file: %w", err))
+		return
+	}
+
+	created()
+	h.setETag(c, path)
+	h.SendSuccessWithPath(c, path, "File streamed successfully")
+}
+
+// HandlePatchFile handles PATCH requests to /filesystem/:path
+// @Summary Apply partial edits to a file
+// @Description Apply byte-range or line-range edits (insert, replace, delete) to an existing file without re-uploading it. Edits are applied in order and the file is replaced atomically.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "File path"
+// @Param If-Match header string false "ETag the file must still have"
+// @Param request body FilePatchRequest true "Ordered list of edits"
+// @Success 200 {object} SuccessResponse "Success message"
+// @Header 200 {string} ETag "ETag of the patched file"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "File not found"
+// @Failure 412 {object} ErrorResponse "File changed since it was read"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /filesystem/{path} [patch]
+func (h *FileSystemHandler) HandlePatchFile(c *gin.Context) {
+	path := h.extractPathFromRequest(c)
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	// Handle file creation/update
-	if err := h.WriteFile(path, []byte(request.Content), permissions); err != nil {
-		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error writing file: %w", err))
+	var request FilePatchRequest
+	if err := h.BindJSON(c, &request); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	h.SendSuccessWithPath(c, path, "File created/updated successfully")
-}
-
-func (h *FileSystemHandler) HandleCreateOrUpdateBinary(c *gin.Context) {
-	// Get path from form data
-	path := h.extractPathFromRequest(c)
-
-	path, err := lib.FormatPath(path)
-	if err != nil {
-		h.SendError(c, http.StatusBadRequest, err)
+	if len(request.Edits) == 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("at least one edit is required"))
 		return
 	}
 
-	// Use streaming multipart reader to avoid extra buffering/copies
-	mr, err := c.Request.MultipartReader()
+	isFile, err := h.FileExists(path)
 	if err != nil {
-		h.SendError(c, http.StatusBadRequest, fmt.Errorf("error reading multipart data: %w", err))
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !isFile {
+		h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "file not found"))
 		return
 	}
 
-	var permissions os.FileMode = 0644
-	var wroteFile bool
-
-	for {
-		part, err := mr.NextPart()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			h.SendError(c, http.StatusBadRequest, fmt.Errorf("error reading multipart part: %w", err))
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		h.conditionalWriteMu.Lock()
+		defer h.conditionalWriteMu.Unlock()
+		if !h.checkIfMatch(c, ifMatch) {
 			return
 		}
-
-		name := part.FormName()
-		filename := part.FileName()
-		if name == "permissions" && filename == "" {
-			// read small permission value
-			data, _ := io.ReadAll(part)
-			if len(data) > 0 {
-				permInt, perr := strconv.ParseUint(strings.TrimSpace(string(data)), 8, 32)
-				if perr != nil {
-					_ = part.Close()
-					h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid permissions format '%s': %w", strings.TrimSpace(string(data)), perr))
-					return
-				}
-				permissions = os.FileMode(permInt)
-			}
-			_ = part.Close()
-			continue
-		}
-
-		if name == "file" && filename != "" && !wroteFile {
-			// Stream directly to disk with requested permissions
-			if err := h.fs.WriteFileFromReader(path, part, permissions); err != nil {
-				_ = part.Close()
-				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error writing binary file: %w", err))
-				return
-			}
-			wroteFile = true
-			_ = part.Close()
-			continue
-		}
-
-		// Close any unhandled parts
-		_ = part.Close()
 	}
 
-	if !wroteFile {
-		h.SendError(c, http.StatusBadRequest, fmt.Errorf("missing 'file' field in multipart form"))
+	if !h.checkLocks(c, path) {
+		return
+	}
+	if err := h.fs.PatchFile(path, request.Edits); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error patching file: %w", err))
 		return
 	}
 
-	h.SendSuccessWithPath(c, path, "Binary file uploaded successfully")
+	h.setETag(c, path)
+	h.SendSuccessWithPath(c, path, "File patched successfully")
 }
 
 // HandleDeleteFileOrDirectory handles DELETE requests to /filesystem/:path
@@ -545,48 +2047,40 @@ func (h *FileSystemHandler) HandleDeleteFile(c *gin.Context) {
 		return
 	}
 
-	recursive := c.Query("recursive")
-
-	// Check if it's a directory
-	isDir, err := h.DirectoryExists(path)
+	isDir, err := h.Delete(path, c.Query("recursive") == "true", lockHolder(c))
 	if err != nil {
 		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
-
 	if isDir {
-		// Delete directory
-		err := h.DeleteDirectory(path, recursive == "true")
-		if err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error deleting directory: %w", err))
-			return
-		}
 		h.SendSuccessWithPath(c, path, "Directory deleted successfully")
 		return
 	}
-
-	// Check if it's a file
-	isFile, err := h.FileExists(path)
-	if err != nil {
-		h.SendError(c, http.StatusUnprocessableEntity, err)
-		return
-	}
-
-	if isFile {
-		// Delete file
-		err := h.DeleteFile(path)
-		if err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error deleting file: %w", err))
-			return
-		}
-		h.SendSuccessWithPath(c, path, "File deleted successfully")
-		return
-	}
-
-	h.SendError(c, http.StatusNotFound, fmt.Errorf("file or directory not found"))
+	h.SendSuccessWithPath(c, path, "File deleted successfully")
 }
 
 // HandleGetTree handles GET requests for directory trees
+// @Summary Get directory tree
+// @Description Get the listing of a directory, optionally recursive with the content of its subdirectories nested
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "Root directory path"
+// @Param recursive query boolean false "List subdirectories recursively, nesting their content"
+// @Param maxDepth query integer false "Number of levels listed recursively, unlimited if not set"
+// @Param glob query string false "Comma separated globs of the files to list recursively, e.g. *.go"
+// @Param includeHidden query boolean false "List entries whose name starts with a dot when listing recursively" default(true)
+// @Param limit query integer false "List the entries flat, page by page, at most limit per page (default: 1000, max: 10000)"
+// @Param pageToken query string false "nextPageToken of the previous page, to list the next one"
+// @Param stream query boolean false "Stream the entries flat as they are read, one JSON entry per line (application/x-ndjson)"
+// @Success 200 {object} filesystem.Directory "Directory tree"
+// @Success 200 {object} filesystem.DirectoryPage "Page of a directory listing (with limit or pageToken)"
+// @Success 200 {object} filesystem.Entry "Directory entry, one per line (with stream)"
+// @Failure 400 {object} ErrorResponse "Path is not a directory or invalid query parameters"
+// @Failure 404 {object} ErrorResponse "Directory not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /filesystem/tree/{path} [get]
 func (h *FileSystemHandler) HandleGetTree(c *gin.Context) {
 	rootPath, exists := c.Get("rootPath")
 	if !exists {
@@ -619,8 +2113,22 @@ func (h *FileSystemHandler) HandleGetTree(c *gin.Context) {
 		return
 	}
 
+	recursive, opts, err := parseTreeOptions(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if h.handleListEntries(c, rootPathStr, recursive, opts) {
+		return
+	}
+
 	// Get directory listing
-	dir, err := h.ListDirectory(rootPathStr)
+	var dir *filesystem.Directory
+	if recursive {
+		dir, err = h.fs.ListDirectoryRecursive(rootPathStr, opts)
+	} else {
+		dir, err = h.ListDirectory(rootPathStr)
+	}
 	if err != nil {
 		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error getting file system tree: %w", err))
 		return
@@ -630,6 +2138,19 @@ func (h *FileSystemHandler) HandleGetTree(c *gin.Context) {
 }
 
 // HandleCreateOrUpdateTree handles PUT requests for directory trees
+// @Summary Create or update directory tree
+// @Description Create a directory with files, by path relative to it. Missing directories are created and existing files replaced.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "Root directory path"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
+// @Param request body TreeRequest true "Files to write"
+// @Success 200 {object} filesystem.Directory "Updated directory listing"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /filesystem/tree/{path} [put]
 func (h *FileSystemHandler) HandleCreateOrUpdateTree(c *gin.Context) {
 	rootPath, exists := c.Get("rootPath")
 	if !exists {
@@ -649,56 +2170,18 @@ func (h *FileSystemHandler) HandleCreateOrUpdateTree(c *gin.Context) {
 		return
 	}
 
-	var request struct {
-		Files map[string]string `json:"files"`
-	}
-
+	var request TreeRequest
 	if err := h.BindJSON(c, &request); err != nil {
 		h.SendError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	// Create the root directory if it doesn't exist
-	isDir, err := h.DirectoryExists(rootPathStr)
-	if err != nil {
+	runAs := lib.ParseRunAs(c.GetHeader(RunAsHeader))
+	if _, err := h.WriteTree(rootPathStr, request.Files, lockHolder(c), runAs); err != nil {
 		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	if !isDir {
-		if err := h.CreateDirectory(rootPathStr, 0755); err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error creating root directory: %w", err))
-			return
-		}
-	}
-
-	// Create files
-	for filePath, content := range request.Files {
-		// Get the absolute path of the file
-		absPath := filepath.Join(rootPathStr, filePath)
-
-		// Create parent directories if they don't exist
-		parentDir := filepath.Dir(absPath)
-		isDir, err := h.DirectoryExists(parentDir)
-		if err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, err)
-			return
-		}
-
-		if !isDir {
-			if err := h.CreateDirectory(parentDir, 0755); err != nil {
-				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error creating parent directory: %w", err))
-				return
-			}
-		}
-
-		// Write the file
-		if err := h.WriteFile(absPath, []byte(content), 0644); err != nil {
-			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error writing file: %w", err))
-			return
-		}
-	}
-
 	// Get updated tree
 	dir, err := h.ListDirectory(rootPathStr)
 	if err != nil {
@@ -710,6 +2193,17 @@ func (h *FileSystemHandler) HandleCreateOrUpdateTree(c *gin.Context) {
 }
 
 // HandleDeleteTree handles DELETE requests for directory trees
+// @Summary Delete directory tree
+// @Description Delete a directory, with its content when recursive is set
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param path path string true "Root directory path"
+// @Param recursive query boolean false "Delete the content of the directory"
+// @Success 200 {object} SuccessResponse "Success message"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /filesystem/tree/{path} [delete]
 func (h *FileSystemHandler) HandleDeleteTree(c *gin.Context) {
 	rootPath, exists := c.Get("rootPath")
 	if !exists {
@@ -725,6 +2219,9 @@ func (h *FileSystemHandler) HandleDeleteTree(c *gin.Context) {
 	}
 
 	recursive := c.Query("recursive") == "true"
+	if !h.checkLocks(c, rootPathStr) {
+		return
+	}
 
 	// Delete the directory
 	if err := h.DeleteDirectory(rootPathStr, recursive); err != nil {
@@ -746,71 +2243,50 @@ func (h *FileSystemHandler) HandleDeleteTree(c *gin.Context) {
 // @Success 200 {object} MultipartInitiateResponse "Upload session created"
 // @Failure 400 {object} ErrorResponse "Bad request"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
 // @Router /filesystem-multipart/initiate/{path} [post]
 func (h *FileSystemHandler) HandleInitiateMultipartUpload(c *gin.Context) {
-	if h.multipartManager == nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("multipart upload not available"))
-		return
-	}
-
-	path := h.extractPathFromRequest(c)
-	path, err := lib.FormatPath(path)
-	if err != nil {
-		h.SendError(c, http.StatusBadRequest, err)
-		return
-	}
-
-	// Get absolute path for final destination
-	absPath, err := h.fs.GetAbsolutePath(path)
-	if err != nil {
-		h.SendError(c, http.StatusBadRequest, err)
-		return
-	}
-
 	// Parse optional permissions
 	var request MultipartInitiateRequest
 	_ = h.BindJSON(c, &request)
 
-	var permissions os.FileMode = 0644
-	if request.Permissions != "" {
-		permInt, err := strconv.ParseUint(request.Permissions, 8, 32)
-		if err != nil {
-			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid permissions format: %w", err))
-			return
-		}
-		permissions = os.FileMode(permInt)
+	path := h.extractPathFromRequest(c)
+	if !h.checkLocks(c, path) {
+		return
 	}
-
-	upload, err := h.multipartManager.InitiateUpload(absPath, permissions)
+	upload, err := h.InitiateMultipartUpload(path, request.Permissions)
 	if err != nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("failed to initiate upload: %w", err))
+		h.SendError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	response := MultipartInitiateResponse{
 		UploadID: upload.UploadID,
-		Path:     absPath,
+		Path:     upload.Path,
 	}
 	h.SendJSON(c, http.StatusOK, response)
 }
 
 // HandleUploadPart uploads a single part of a multipart upload
 // @Summary Upload part
-// @Description Upload a single part of a multipart upload
+// @Description Upload a single part of a multipart upload. A part interrupted while being sent is listed as partial with the size received, and can be continued by sending the rest of its content with that offset.
 // @Tags filesystem
 // @Accept multipart/form-data
 // @Produce json
 // @Param uploadId path string true "Upload ID"
 // @Param partNumber query int true "Part number (1-10000)"
+// @Param offset query int false "Offset to continue a partial part from, at most the size received so far (default: 0, the whole part)"
 // @Param file formData file true "Part data"
 // @Success 200 {object} MultipartUploadPartResponse "Part uploaded"
 // @Failure 400 {object} ErrorResponse "Bad request"
 // @Failure 404 {object} ErrorResponse "Upload not found"
+// @Failure 409 {object} ErrorResponse "Upload is being completed"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
 // @Router /filesystem-multipart/{uploadId}/part [put]
 func (h *FileSystemHandler) HandleUploadPart(c *gin.Context) {
 	if h.multipartManager == nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("multipart upload not available"))
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
 		return
 	}
 
@@ -832,6 +2308,12 @@ func (h *FileSystemHandler) HandleUploadPart(c *gin.Context) {
 		return
 	}
 
+	offset, err := strconv.ParseInt(h.GetQueryParam(c, "offset", "0"), 10, 64)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid offset: %w", err))
+		return
+	}
+
 	// Use streaming multipart reader
 	mr, err := c.Request.MultipartReader()
 	if err != nil {
@@ -851,10 +2333,10 @@ func (h *FileSystemHandler) HandleUploadPart(c *gin.Context) {
 		}
 
 		if part.FormName() == "file" {
-			uploadedPart, err = h.multipartManager.UploadPart(uploadID, partNumber, part)
+			uploadedPart, err = h.UploadMultipartPart(uploadID, partNumber, offset, part)
 			_ = part.Close()
 			if err != nil {
-				h.SendError(c, http.StatusInternalServerError, fmt.Errorf("failed to upload part: %w", err))
+				h.SendError(c, http.StatusInternalServerError, err)
 				return
 			}
 			break
@@ -877,20 +2359,23 @@ func (h *FileSystemHandler) HandleUploadPart(c *gin.Context) {
 
 // HandleCompleteMultipartUpload completes a multipart upload
 // @Summary Complete multipart upload
-// @Description Complete a multipart upload by assembling all parts
+// @Description Complete a multipart upload by assembling all parts. The file is only replaced once assembled, and, when a checksum is given, once its sha256 is verified.
 // @Tags filesystem
 // @Accept json
 // @Produce json
 // @Param uploadId path string true "Upload ID"
 // @Param request body MultipartCompleteRequest true "List of parts"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created by the write, RUN_AS by default"
 // @Success 200 {object} SuccessResponse "Upload completed"
 // @Failure 400 {object} ErrorResponse "Bad request"
 // @Failure 404 {object} ErrorResponse "Upload not found"
+// @Failure 409 {object} ErrorResponse "Upload is being completed, or parts are being uploaded"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
 // @Router /filesystem-multipart/{uploadId}/complete [post]
 func (h *FileSystemHandler) HandleCompleteMultipartUpload(c *gin.Context) {
 	if h.multipartManager == nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("multipart upload not available"))
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
 		return
 	}
 
@@ -906,33 +2391,13 @@ func (h *FileSystemHandler) HandleCompleteMultipartUpload(c *gin.Context) {
 		return
 	}
 
-	if len(request.Parts) == 0 {
-		h.SendError(c, http.StatusBadRequest, fmt.Errorf("at least one part is required"))
-		return
-	}
-
-	// Get upload metadata to get the path
-	upload, err := h.multipartManager.GetUpload(uploadID)
+	path, err := h.CompleteMultipartUpload(uploadID, request.Parts, request.Checksum, c.GetHeader(RunAsHeader))
 	if err != nil {
-		h.SendError(c, http.StatusNotFound, err)
-		return
-	}
-
-	// Convert MultipartPartInfo to UploadedPart for the manager
-	parts := make([]filesystem.UploadedPart, len(request.Parts))
-	for i, p := range request.Parts {
-		parts[i] = filesystem.UploadedPart{
-			PartNumber: p.PartNumber,
-			ETag:       p.ETag,
-		}
-	}
-
-	if err := h.multipartManager.CompleteUpload(uploadID, parts); err != nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("failed to complete upload: %w", err))
+		h.SendError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	h.SendSuccessWithPath(c, upload.Path, "Multipart upload completed successfully")
+	h.SendSuccessWithPath(c, path, "Multipart upload completed successfully")
 }
 
 // HandleAbortMultipartUpload aborts a multipart upload
@@ -944,11 +2409,13 @@ func (h *FileSystemHandler) HandleCompleteMultipartUpload(c *gin.Context) {
 // @Success 200 {object} SuccessResponse "Upload aborted"
 // @Failure 400 {object} ErrorResponse "Bad request"
 // @Failure 404 {object} ErrorResponse "Upload not found"
+// @Failure 409 {object} ErrorResponse "Upload is being completed"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
 // @Router /filesystem-multipart/{uploadId}/abort [delete]
 func (h *FileSystemHandler) HandleAbortMultipartUpload(c *gin.Context) {
 	if h.multipartManager == nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("multipart upload not available"))
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
 		return
 	}
 
@@ -958,7 +2425,7 @@ func (h *FileSystemHandler) HandleAbortMultipartUpload(c *gin.Context) {
 		return
 	}
 
-	if err := h.multipartManager.AbortUpload(uploadID); err != nil {
+	if err := h.AbortMultipartUpload(uploadID); err != nil {
 		h.SendError(c, http.StatusNotFound, err)
 		return
 	}
@@ -966,6 +2433,30 @@ func (h *FileSystemHandler) HandleAbortMultipartUpload(c *gin.Context) {
 	h.SendSuccess(c, "Multipart upload aborted successfully")
 }
 
+// HandleGetMultipartUpload returns the status of a multipart upload
+// @Summary Get multipart upload
+// @Description Get a multipart upload with its parts. While it is being completed, its status is completing and the progress of the assembly of its parts is reported. Completed uploads are removed.
+// @Tags filesystem
+// @Produce json
+// @Param uploadId path string true "Upload ID"
+// @Success 200 {object} filesystem.MultipartUpload "Upload"
+// @Failure 404 {object} ErrorResponse "Upload not found"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
+// @Router /filesystem-multipart/{uploadId} [get]
+func (h *FileSystemHandler) HandleGetMultipartUpload(c *gin.Context) {
+	if h.multipartManager == nil {
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
+		return
+	}
+
+	upload, err := h.multipartManager.UploadStatus(c.Param("uploadId"))
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, upload)
+}
+
 // HandleListParts lists all uploaded parts for a multipart upload
 // @Summary List parts
 // @Description List all uploaded parts for a multipart upload
@@ -976,10 +2467,11 @@ func (h *FileSystemHandler) HandleAbortMultipartUpload(c *gin.Context) {
 // @Failure 400 {object} ErrorResponse "Bad request"
 // @Failure 404 {object} ErrorResponse "Upload not found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
 // @Router /filesystem-multipart/{uploadId}/parts [get]
 func (h *FileSystemHandler) HandleListParts(c *gin.Context) {
 	if h.multipartManager == nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("multipart upload not available"))
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
 		return
 	}
 
@@ -1015,10 +2507,11 @@ func (h *FileSystemHandler) HandleListParts(c *gin.Context) {
 // @Produce json
 // @Success 200 {object} MultipartListUploadsResponse "List of active uploads"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
 // @Router /filesystem-multipart [get]
 func (h *FileSystemHandler) HandleListMultipartUploads(c *gin.Context) {
 	if h.multipartManager == nil {
-		h.SendError(c, http.StatusInternalServerError, fmt.Errorf("multipart upload not available"))
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
 		return
 	}
 
@@ -1030,15 +2523,23 @@ func (h *FileSystemHandler) HandleListMultipartUploads(c *gin.Context) {
 	h.SendJSON(c, http.StatusOK, response)
 }
 
-// HandleWatchDirectory streams file modification events for a directory
-// @Summary Stream file modification events in a directory
-// @Description Streams the path of modified files (one per line) in the given directory. Closes when the client disconnects.
+// HandleWatchDirectory streams file modification events for a directory or a file
+// @Summary Stream file modification events in a directory or of a file
+// @Description Streams the path of modified files (one per line) in the given directory. Closes when the client disconnects. Events include the size, modification time and type of the file when it still exists. A file moved within the watched directory is a single MOVED event with its from and to paths. Directories of a recursive watch matching the ignore patterns are not watched, and those beyond the inotify watch budget set with WATCH_MAX_DIRECTORIES are polled every 2 seconds, which is reported with an ERROR event. With debounceMs, the events of a path within the delay are merged into one, with the operations of all of them. With batch, the events are sent as a JSON array per line.
+// @Description
+// @Description When the path is a file, only its events are streamed, including its replacement by a rename as editors save files. With content=full, each event includes the content of the file, and with content=diff the unified diff of its content since the previous event, so that the file does not need to be downloaded again. Binary files and files larger than 1MiB have contentOmitted set instead.
 // @Tags filesystem
 // @Produce plain
-// @Param ignore query string false "Ignore patterns (comma-separated)"
-// @Param path path string true "Directory path to watch"
+// @Param ignore query string false "Gitignore-style ignore patterns (comma-separated), e.g. node_modules/**,*.log,!keep.log"
+// @Param gitignore query boolean false "Also ignore the patterns of the .gitignore file of the directory and the .git directory"
+// @Param debounceMs query integer false "Delay without events after which the merged events are sent, 100 by default with batch"
+// @Param batch query boolean false "Send the events received within debounceMs as a JSON array"
+// @Param content query string false "Include the content (full) or the diff (diff) of a watched file in its events" Enums(full, diff)
+// @Param path path string true "Directory or file path to watch"
 // @Success 200 {string} string "Stream of modified file paths, one per line"
 // @Failure 400 {object} ErrorResponse "Invalid path"
+// @Failure 404 {object} ErrorResponse "Path not found"
+// @Failure 429 {object} ErrorResponse "Too many filesystem watches open"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /watch/filesystem/{path} [get]
 func (h *FileSystemHandler) HandleWatchDirectory(c *gin.Context) {
@@ -1056,13 +2557,21 @@ func (h *FileSystemHandler) HandleWatchDirectory(c *gin.Context) {
 	if ignoreParam != "" {
 		ignorePatterns = strings.Split(ignoreParam, ",")
 	}
-	shouldIgnore := func(eventPath string) bool {
-		for _, pattern := range ignorePatterns {
-			if pattern != "" && strings.Contains(eventPath, pattern) {
-				return true
-			}
+	gitignore := c.Query("gitignore") == "true"
+
+	debounceMs := 0
+	if param := c.Query("debounceMs"); param != "" {
+		debounceMs, err = strconv.Atoi(param)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid debounceMs: %w", err))
+			return
 		}
-		return false
+	}
+	batch := c.Query("batch") == "true"
+	debounce, err := WatchDebounce(debounceMs, batch)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
 	}
 
 	recursive := false
@@ -1074,16 +2583,44 @@ func (h *FileSystemHandler) HandleWatchDirectory(c *gin.Context) {
 		}
 	}
 
+	content := c.Query("content")
+	if err := filesystem.ValidateWatchContent(content); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	isDir, err := h.DirectoryExists(path)
 	if err != nil {
 		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
+	isFile := false
+	if !isDir && !recursive {
+		if isFile, err = h.FileExists(path); err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
 
-	if !isDir {
+	if !isDir && recursive {
 		h.SendError(c, http.StatusBadRequest, fmt.Errorf("path is not a directory"))
 		return
 	}
+	if !isDir && !isFile {
+		h.SendError(c, http.StatusNotFound, apierror.New(apierror.CodeFSNotFound, "path not found"))
+		return
+	}
+	if isDir && content != "" {
+		h.SendError(c, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "content is only supported when watching a file"))
+		return
+	}
+
+	release, err := streamlimit.Watchers().Acquire(c.Request.RemoteAddr)
+	if err != nil {
+		h.SendError(c, http.StatusTooManyRequests, err)
+		return
+	}
+	defer release()
 
 	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	c.Writer.Header().Set("Transfer-Encoding", "chunked")
@@ -1098,53 +2635,43 @@ func (h *FileSystemHandler) HandleWatchDirectory(c *gin.Context) {
 	ctx := c.Request.Context()
 	done := make(chan struct{})
 
+	writeLine := func(msg any) {
+		defer func() { _ = recover() }()
+		json, err := json.Marshal(msg)
+		if err != nil {
+			logrus.Error("Error marshalling file event:", err)
+			return
+		}
+		if _, err := c.Writer.Write([]byte(string(json) + "\n")); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+
+	writeBatch := func(events []FileEvent) {
+		if batch {
+			writeLine(events)
+			return
+		}
+		for _, msg := range events {
+			writeLine(msg)
+		}
+	}
+
 	var stop func()
-	if recursive {
-		stop, err = h.fs.WatchDirectoryRecursive(path, func(event fsnotify.Event) {
-			defer func() { _ = recover() }()
-			if shouldIgnore(event.Name) {
-				return
-			}
-			msg := FileEvent{
-				Op:    event.Op.String(),
-				Name:  strings.Split(event.Name, "/")[len(strings.Split(event.Name, "/"))-1],
-				Path:  strings.Join(strings.Split(event.Name, "/")[:len(strings.Split(event.Name, "/"))-1], "/"),
-				Error: nil,
-			}
-			json, err := json.Marshal(msg)
-			if err != nil {
-				logrus.Error("Error marshalling file event:", err)
-				h.SendError(c, http.StatusInternalServerError, err)
-				return
-			}
-			if _, err := c.Writer.Write([]byte(string(json) + "\n")); err != nil {
-				return
-			}
-			flusher.Flush()
+	switch {
+	case isFile && debounce == 0:
+		stop, err = h.WatchFile(path, content, func(msg FileEvent) {
+			writeLine(msg)
 		})
-	} else {
-		stop, err = h.fs.WatchDirectory(path, func(event fsnotify.Event) {
-			defer func() { _ = recover() }()
-			if shouldIgnore(event.Name) {
-				return
-			}
-			msg := FileEvent{
-				Op:    event.Op.String(),
-				Name:  strings.Split(event.Name, "/")[len(strings.Split(event.Name, "/"))-1],
-				Path:  strings.Join(strings.Split(event.Name, "/")[:len(strings.Split(event.Name, "/"))-1], "/"),
-				Error: nil,
-			}
-			json, err := json.Marshal(msg)
-			if err != nil {
-				logrus.Error("Error marshalling file event:", err)
-				h.SendError(c, http.StatusInternalServerError, err)
-				return
-			}
-			if _, err := c.Writer.Write([]byte(string(json) + "\n")); err != nil {
-				return
-			}
-			flusher.Flush()
+	case isFile:
+		stop, err = h.WatchFileBatched(path, content, debounce, writeBatch)
+	case debounce == 0:
+		stop, err = h.WatchDirectory(path, recursive, ignorePatterns, gitignore, func(msg FileEvent) {
+			writeLine(msg)
 		})
+	default:
+		stop, err = h.WatchDirectoryBatched(path, recursive, ignorePatterns, gitignore, debounce, writeBatch)
 	}
 	if err != nil {
 		h.SendError(c, http.StatusInternalServerError, err)
@@ -1152,6 +2679,9 @@ func (h *FileSystemHandler) HandleWatchDirectory(c *gin.Context) {
 	}
 	defer stop() // Ensures watcher is removed when handler exits
 
+	metrics.ActiveWatchers.Inc()
+	defer metrics.ActiveWatchers.Dec()
+
 	// Keepalive ticker to prevent idle timeouts while watching
 	keepaliveTicker := time.NewTicker(30 * time.Second)
 	defer keepaliveTicker.Stop()
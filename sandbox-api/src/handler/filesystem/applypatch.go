@@ -0,0 +1,668 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// What ApplyPatch does with the hunks which do not apply
+const (
+	// PatchRejectAbort writes nothing when a hunk does not apply, like git apply
+	PatchRejectAbort = "abort"
+	// PatchRejectSkip writes the hunks which apply and only reports the others
+	PatchRejectSkip = "skip"
+	// PatchRejectFile writes the hunks which apply and the others to a .rej file next to
+	// their file, like patch
+	PatchRejectFile = "file"
+)
+
+// Statuses of the files of a patch, besides the ones of diffs
+const (
+	PatchStatusRenamed = "renamed"
+	PatchStatusCopied  = "copied"
+)
+
+// Statuses of the hunks of a patch
+const (
+	PatchHunkApplied  = "applied"
+	PatchHunkRejected = "rejected"
+)
+
+// PatchOptions are the options of ApplyPatch
+type PatchOptions struct {
+	// Strip is the number of leading components removed from the paths of the patch, like
+	// patch -p. When negative, it is 1 for paths prefixed with a/ and b/ and 0 otherwise.
+	Strip int
+	// DryRun checks where the hunks apply without writing anything
+	DryRun bool
+	// Reject is what is done with the hunks which do not apply, PatchRejectAbort by default
+	Reject string
+	// Holder is the lock holder the patch is applied by, see CheckLocks
+	Holder string
+	// Owner is given the files and directories created by the patch, nil for the user of
+	// the API
+	Owner *lib.Credential
+}
+
+// PatchHunkResult is the result of applying a hunk of a patch
+type PatchHunkResult struct {
+	Header string `json:"header" example:"@@ -10,3 +10,4 @@" binding:"required"`
+	Status string `json:"status" example:"applied" enums:"applied,rejected" binding:"required"`
+	// Line is the line of the original file the hunk applied at
+	Line int `json:"line,omitempty" example:"12"`
+	// Offset is the number of lines between where the hunk was expected and where it applied
+	Offset int    `json:"offset" example:"2"`
+	Error  string `json:"error,omitempty" example:"the lines of the hunk were not found"`
+} // @name PatchHunkResult
+
+// PatchFileResult is the result of applying the changes of a patch to a file
+type PatchFileResult struct {
+	// Path is the path of the file once patched, or of the deleted file
+	Path string `json:"path" example:"/app/src/main.go" binding:"required"`
+	// OldPath is the path of the file a renamed or copied file comes from
+	OldPath string `json:"oldPath,omitempty" example:"/app/main.go"`
+	Status  string `json:"status" example:"modified" enums:"modified,added,deleted,renamed,copied" binding:"required"`
+	// Applied is true when every hunk of the file applied
+	Applied bool              `json:"applied" example:"true"`
+	Hunks   []PatchHunkResult `json:"hunks" binding:"required"`
+	// RejectFile is the file the rejected hunks are written to
+	RejectFile string `json:"rejectFile,omitempty" example:"/app/src/main.go.rej"`
+	// Error is set when the file cannot be patched at all, every hunk being rejected then
+	Error string `json:"error,omitempty" example:"file already exists"`
+} // @name PatchFileResult
+
+// PatchResult is the result of applying a patch
+type PatchResult struct {
+	// Applied is true when every hunk of every file applied
+	Applied bool `json:"applied" example:"true"`
+	// Written is true when changes were written, false for dry runs and aborted patches
+	Written       bool              `json:"written" example:"true"`
+	DryRun        bool              `json:"dryRun" example:"false"`
+	Files         []PatchFileResult `json:"files" binding:"required"`
+	HunksApplied  int               `json:"hunksApplied" example:"3"`
+	HunksRejected int               `json:"hunksRejected" example:"0"`
+} // @name PatchResult
+
+// ApplyPatch applies a unified diff, optionally in git format with renames, copies, mode
+// changes, created and deleted files, to the files below dir. Hunks apply where their
+// lines are found nearest to the line they expect. All the changes are written with
+// ApplyBatch, so they are written together or not at all.
+func (fs *Filesystem) ApplyPatch(dir string, patch string, opts PatchOptions) (*PatchResult, error) {
+	absDir, err := fs.GetAbsolutePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := fs.stat(absDir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+	switch opts.Reject {
+	case "":
+		opts.Reject = PatchRejectAbort
+	case PatchRejectAbort, PatchRejectSkip, PatchRejectFile:
+	default:
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid reject '%s', expected 'abort', 'skip' or 'file'", opts.Reject)
+	}
+
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &patchState{dir: absDir, opts: opts, files: make(map[string]*patchedFile)}
+	result := &PatchResult{Applied: true, DryRun: opts.DryRun, Files: make([]PatchFileResult, 0, len(files))}
+	for _, file := range files {
+		fileResult := state.apply(file)
+		for _, hunk := range fileResult.Hunks {
+			if hunk.Status == PatchHunkApplied {
+				result.HunksApplied++
+			} else {
+				result.HunksRejected++
+			}
+		}
+		result.Applied = result.Applied && fileResult.Applied
+		result.Files = append(result.Files, fileResult)
+	}
+
+	if opts.DryRun || (!result.Applied && opts.Reject == PatchRejectAbort) || len(state.operations) == 0 {
+		return result, nil
+	}
+	if err := fs.CheckBatchLocks(opts.Holder, state.operations); err != nil {
+		return nil, err
+	}
+	if err := fs.ApplyBatchAs(state.operations, opts.Owner); err != nil {
+		return nil, err
+	}
+	result.Written = true
+	return result, nil
+}
+
+// patchState is the state of a patch being applied
+type patchState struct {
+	dir  string
+	opts PatchOptions
+	// files are the files patched so far, nil when deleted, so that a file can be changed
+	// by several parts of a patch
+	files      map[string]*patchedFile
+	operations []BatchOperation
+}
+
+// patchedFile is a file patched by a part of a patch
+type patchedFile struct {
+	content string
+	mode    os.FileMode
+}
+
+// apply applies the changes of a patch to a file, recording the operations writing them
+func (s *patchState) apply(file *filePatch) PatchFileResult {
+	result := PatchFileResult{Status: DiffStatusModified, Hunks: make([]PatchHunkResult, 0, len(file.hunks))}
+	fail := func(err error) PatchFileResult {
+		result.Error = err.Error()
+		result.Hunks = result.Hunks[:0]
+		for _, hunk := range file.hunks {
+			result.Hunks = append(result.Hunks, PatchHunkResult{Header: hunk.header, Status: PatchHunkRejected, Error: result.Error})
+		}
+		result.Applied = false
+		s.reject(&result, file, file.hunks)
+		return result
+	}
+
+	oldPath, newPath, err := file.paths(s.opts.Strip)
+	var source, target string
+	if err == nil && oldPath != "" {
+		source, err = s.resolve(oldPath)
+	}
+	if err == nil && newPath != "" {
+		target, err = s.resolve(newPath)
+	}
+	switch {
+	case source == "":
+		result.Status = DiffStatusAdded
+	case target == "":
+		result.Status = DiffStatusDeleted
+		target = source
+	case file.copy:
+		result.Status = PatchStatusCopied
+	case source != target:
+		result.Status = PatchStatusRenamed
+	}
+	result.Path = target
+	if result.Status == PatchStatusRenamed || result.Status == PatchStatusCopied {
+		result.OldPath = source
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if file.binary {
+		return fail(fmt.Errorf("binary patches are not supported"))
+	}
+
+	content, mode := "", os.FileMode(0644)
+	if source != "" {
+		var exists bool
+		content, mode, exists, err = s.read(source)
+		if err != nil {
+			return fail(err)
+		}
+		if !exists {
+			return fail(fmt.Errorf("file does not exist"))
+		}
+	}
+	if source != target {
+		if _, _, exists, err := s.read(target); err != nil {
+			return fail(err)
+		} else if exists {
+			return fail(fmt.Errorf("file already exists"))
+		}
+	}
+	if file.mode != 0 {
+		mode = file.mode
+	}
+
+	patched, rejected := applyHunks(content, file.hunks, &result)
+	result.Applied = len(rejected) == 0
+	if result.Applied && result.Status == DiffStatusDeleted && patched != "" {
+		return fail(fmt.Errorf("file is not empty once the deleted lines are removed"))
+	}
+	s.reject(&result, file, rejected)
+	if len(rejected) == len(file.hunks) && len(file.hunks) > 0 {
+		return result
+	}
+
+	permissions := fmt.Sprintf("%04o", mode.Perm())
+	switch result.Status {
+	case DiffStatusDeleted:
+		if result.Applied {
+			s.files[target] = nil
+			s.operations = append(s.operations, BatchOperation{Operation: BatchDelete, Path: target})
+		}
+	case DiffStatusModified:
+		if patched == content {
+			if file.mode != 0 {
+				s.operations = append(s.operations, BatchOperation{Operation: BatchChmod, Path: target, Permissions: permissions})
+			}
+			break
+		}
+		fallthrough
+	default:
+		s.files[target] = &patchedFile{content: patched, mode: mode}
+		s.operations = append(s.operations, BatchOperation{Operation: BatchWrite, Path: target, Content: patched, Permissions: permissions})
+		if result.Status == PatchStatusRenamed {
+			s.files[source] = nil
+			s.operations = append(s.operations, BatchOperation{Operation: BatchDelete, Path: source})
+		}
+	}
+	return result
+}
+
+// reject records the .rej file of the rejected hunks of a file, when they are written to one
+func (s *patchState) reject(result *PatchFileResult, file *filePatch, hunks []*patchHunk) {
+	if s.opts.Reject != PatchRejectFile || len(hunks) == 0 || result.Path == "" {
+		return
+	}
+	var content strings.Builder
+	fmt.Fprintf(&content, "--- %s\n+++ %s\n", file.oldRaw, file.newRaw)
+	for _, hunk := range hunks {
+		content.WriteString(hunk.text)
+	}
+	result.RejectFile = result.Path + ".rej"
+	s.operations = append(s.operations, BatchOperation{Operation: BatchWrite, Path: result.RejectFile, Content: content.String()})
+}
+
+// resolve returns the absolute path of a path of the patch, which must stay below the
+// directory the patch is applied to
+func (s *patchState) resolve(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", apierror.Newf(apierror.CodeInvalidRequest, "path %s of the patch is absolute, set strip to remove its leading components", path)
+	}
+	absPath := filepath.Join(s.dir, path)
+	if rel, err := filepath.Rel(s.dir, absPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", apierror.Newf(apierror.CodeInvalidRequest, "path %s of the patch is outside of %s", path, s.dir)
+	}
+	return absPath, nil
+}
+
+// read returns the content and mode of a file, as patched so far, and whether it exists
+func (s *patchState) read(absPath string) (string, os.FileMode, bool, error) {
+	if file, patched := s.files[absPath]; patched {
+		if file == nil {
+			return "", 0, false, nil
+		}
+		return file.content, file.mode, true, nil
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", 0, false, nil
+		}
+		return "", 0, false, err
+	}
+	if info.IsDir() {
+		return "", 0, false, ErrIsDirectory
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return string(content), info.Mode().Perm(), true, nil
+}
+
+// applyHunks applies hunks in order to content, adding their results to result. It
+// returns the patched content and the hunks which did not apply.
+func applyHunks(content string, hunks []*patchHunk, result *PatchFileResult) (string, []*patchHunk) {
+	lines := splitLinesKeepEnds(content)
+	patched := make([]string, 0, len(lines))
+	rejected := []*patchHunk{}
+	// pos is the first line not patched yet, and offset the offset of the last applied
+	// hunk, which the next ones are likely to have too
+	pos, offset := 0, 0
+	for _, hunk := range hunks {
+		expected := hunk.oldStart - 1
+		if len(hunk.old) == 0 {
+			// The range of a hunk only adding lines starts at the line they follow
+			expected = hunk.oldStart
+		}
+		index := findLines(lines, hunk.old, pos, expected+offset)
+		if index < 0 {
+			rejected = append(rejected, hunk)
+			result.Hunks = append(result.Hunks, PatchHunkResult{Header: hunk.header, Status: PatchHunkRejected, Error: "the lines of the hunk were not found"})
+			continue
+		}
+		patched = append(patched, lines[pos:index]...)
+		patched = append(patched, hunk.new...)
+		pos = index + len(hunk.old)
+		offset = index - expected
+		result.Hunks = append(result.Hunks, PatchHunkResult{Header: hunk.header, Status: PatchHunkApplied, Line: index + 1, Offset: offset})
+	}
+	patched = append(patched, lines[pos:]...)
+	return strings.Join(patched, ""), rejected
+}
+
+// findLines returns the index of the occurrence of want in lines nearest to target, not
+// before from, or -1
+func findLines(lines []string, want []string, from int, target int) int {
+	last := len(lines) - len(want)
+	if last < from {
+		return -1
+	}
+	target = max(from, min(target, last))
+	for delta := 0; target-delta >= from || target+delta <= last; delta++ {
+		if i := target - delta; i >= from && equalLines(lines[i:i+len(want)], want) {
+			return i
+		}
+		if i := target + delta; delta > 0 && i <= last && equalLines(lines[i:i+len(want)], want) {
+			return i
+		}
+	}
+	return -1
+}
+
+// equalLines reports whether two lists of lines are equal
+func equalLines(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLinesKeepEnds splits content into lines, keeping their line endings
+func splitLinesKeepEnds(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// devNullPath is the path of the missing side of a created or deleted file in patches
+const devNullPath = "/dev/null"
+
+// filePatch is the part of a patch changing a file
+type filePatch struct {
+	// oldRaw and newRaw are the paths of the file before and after the patch, with their
+	// prefixes, or /dev/null
+	oldRaw, newRaw string
+	// renameFrom and renameTo are the paths of git renames and copies, without prefixes
+	renameFrom, renameTo string
+	git                  bool
+	// headers is true once the --- and +++ lines of the file are read
+	headers bool
+	created bool
+	deleted bool
+	copy    bool
+	binary  bool
+	// mode is the mode of a created file or of a mode change, 0 when unchanged
+	mode  os.FileMode
+	hunks []*patchHunk
+}
+
+// paths returns the paths of the file before and after the patch, empty for a created or
+// deleted file, with strip leading components removed
+func (f *filePatch) paths(strip int) (string, string, error) {
+	if strip < 0 {
+		strip = 0
+		if (f.oldRaw == devNullPath || strings.HasPrefix(f.oldRaw, "a/")) && (f.newRaw == devNullPath || strings.HasPrefix(f.newRaw, "b/")) {
+			strip = 1
+		}
+	}
+	oldPath, err := stripPath(f.oldRaw, strip)
+	if err != nil {
+		return "", "", err
+	}
+	newPath, err := stripPath(f.newRaw, strip)
+	if err != nil {
+		return "", "", err
+	}
+	if f.renameFrom != "" && f.renameTo != "" {
+		oldPath, newPath = f.renameFrom, f.renameTo
+	}
+	if f.created {
+		oldPath = ""
+	}
+	if f.deleted {
+		newPath = ""
+	}
+	if oldPath == "" && newPath == "" {
+		return "", "", fmt.Errorf("the patch has no path for the file")
+	}
+	return oldPath, newPath, nil
+}
+
+// stripPath removes strip leading components from a path of a patch, empty for /dev/null
+func stripPath(path string, strip int) (string, error) {
+	if path == devNullPath || path == "" {
+		return "", nil
+	}
+	stripped := path
+	for i := 0; i < strip; i++ {
+		_, rest, found := strings.Cut(stripped, "/")
+		if !found {
+			return "", apierror.Newf(apierror.CodeInvalidRequest, "cannot strip %d components from %s", strip, path)
+		}
+		stripped = strings.TrimLeft(rest, "/")
+	}
+	return stripped, nil
+}
+
+// patchHunk is a hunk of a patch
+type patchHunk struct {
+	header             string
+	oldStart, newStart int
+	// old and new are the lines of the hunk before and after the patch, with their line
+	// endings, except for the last line of a file not ending with a newline
+	old, new []string
+	// text is the hunk as in the patch
+	text string
+}
+
+// hunkHeaderRegexp matches the header of a hunk, a missing count meaning one line
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch parses a unified diff, in git format or not. Lines outside of files and
+// hunks, like the message of a git format-patch email, are ignored.
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := strings.SplitAfter(patch, "\n")
+	files := []*filePatch{}
+	var file *filePatch
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = &filePatch{git: true}
+			file.oldRaw, file.newRaw = parseGitDiffPaths(strings.TrimPrefix(line, "diff --git "))
+			files = append(files, file)
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if file == nil || !file.git || file.headers || len(file.hunks) > 0 {
+				file = &filePatch{}
+				files = append(files, file)
+			}
+			file.headers = true
+			file.oldRaw = parsePatchPath(line[len("--- "):])
+			file.newRaw = parsePatchPath(strings.TrimRight(lines[i+1], "\r\n")[len("+++ "):])
+			if file.oldRaw == devNullPath {
+				file.created = true
+			}
+			if file.newRaw == devNullPath {
+				file.deleted = true
+			}
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			if file == nil {
+				return nil, apierror.Newf(apierror.CodeInvalidRequest, "line %d: hunk without file header", i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			file.hunks = append(file.hunks, hunk)
+			i = next - 1
+		case file != nil && file.git && !file.headers && len(file.hunks) == 0:
+			if err := parseGitHeader(file, line); err != nil {
+				return nil, apierror.Newf(apierror.CodeInvalidRequest, "line %d: %v", i+1, err)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "the patch changes no file")
+	}
+	return files, nil
+}
+
+// parseGitHeader parses an extended header line of a git diff
+func parseGitHeader(file *filePatch, line string) error {
+	var err error
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		file.created = true
+		file.mode, err = parseGitMode(strings.TrimPrefix(line, "new file mode "))
+	case strings.HasPrefix(line, "deleted file mode "):
+		file.deleted = true
+	case strings.HasPrefix(line, "new mode "):
+		file.mode, err = parseGitMode(strings.TrimPrefix(line, "new mode "))
+	case strings.HasPrefix(line, "rename from "):
+		file.renameFrom = parsePatchPath(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		file.renameTo = parsePatchPath(strings.TrimPrefix(line, "rename to "))
+	case strings.HasPrefix(line, "copy from "):
+		file.copy = true
+		file.renameFrom = parsePatchPath(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		file.copy = true
+		file.renameTo = parsePatchPath(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+		file.binary = true
+	}
+	return err
+}
+
+// parseGitMode parses the mode of a git header, like 100755
+func parseGitMode(mode string) (os.FileMode, error) {
+	value, err := strconv.ParseUint(strings.TrimSpace(mode), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode '%s'", mode)
+	}
+	return os.FileMode(value).Perm(), nil
+}
+
+// parsePatchPath parses a path of a patch, which git quotes when it has special
+// characters, and diff follows with a tab and a timestamp
+func parsePatchPath(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if quoted, err := strconv.QuotedPrefix(path); err == nil {
+			if unquoted, err := strconv.Unquote(quoted); err == nil {
+				return unquoted
+			}
+		}
+	}
+	path, _, _ = strings.Cut(path, "\t")
+	return strings.TrimRight(path, " ")
+}
+
+// parseGitDiffPaths parses the paths of a "diff --git" line, only used when the file has
+// no --- and +++ lines, like for renames and mode changes. Unquoted paths are separated by
+// a space which is ambiguous when they contain spaces, so the split giving the same path
+// on both sides is preferred.
+func parseGitDiffPaths(paths string) (string, string) {
+	if strings.HasPrefix(paths, `"`) {
+		if quoted, err := strconv.QuotedPrefix(paths); err == nil {
+			return parsePatchPath(quoted), parsePatchPath(strings.TrimPrefix(paths[len(quoted):], " "))
+		}
+	}
+	if half := len(paths) / 2; len(paths)%2 == 1 && paths[half] == ' ' {
+		oldPath, newPath := paths[:half], paths[half+1:]
+		_, oldRest, _ := strings.Cut(oldPath, "/")
+		_, newRest, _ := strings.Cut(newPath, "/")
+		if oldRest == newRest {
+			return oldPath, newPath
+		}
+	}
+	if i := strings.Index(paths, " b/"); i >= 0 {
+		return paths[:i], paths[i+1:]
+	}
+	oldPath, newPath, _ := strings.Cut(paths, " ")
+	return oldPath, parsePatchPath(newPath)
+}
+
+// parseHunk parses the hunk starting at lines[start], returning the index of the line
+// following it
+func parseHunk(lines []string, start int) (*patchHunk, int, error) {
+	header := strings.TrimRight(lines[start], "\r\n")
+	match := hunkHeaderRegexp.FindStringSubmatch(header)
+	if match == nil {
+		return nil, 0, apierror.Newf(apierror.CodeInvalidRequest, "line %d: invalid hunk header '%s'", start+1, header)
+	}
+	count := func(value string) int {
+		if value == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(value)
+		return n
+	}
+	hunk := &patchHunk{header: header}
+	hunk.oldStart, _ = strconv.Atoi(match[1])
+	hunk.newStart, _ = strconv.Atoi(match[3])
+	oldLines, newLines := count(match[2]), count(match[4])
+
+	// previous is the kind of the previous line, which a "\ No newline at end of file"
+	// marker applies to
+	previous := byte(0)
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		done := len(hunk.old) >= oldLines && len(hunk.new) >= newLines
+		if done && !strings.HasPrefix(line, `\`) {
+			break
+		}
+		if line == "" {
+			return nil, 0, apierror.Newf(apierror.CodeInvalidRequest, "line %d: hunk '%s' is truncated", start+1, header)
+		}
+		kind := line[0]
+		switch kind {
+		case '\n', '\r':
+			// An empty context line, its space having been trimmed
+			kind = ' '
+			line = " " + line
+		}
+		switch kind {
+		case ' ':
+			hunk.old = append(hunk.old, line[1:])
+			hunk.new = append(hunk.new, line[1:])
+		case '-':
+			hunk.old = append(hunk.old, line[1:])
+		case '+':
+			hunk.new = append(hunk.new, line[1:])
+		case '\\':
+			if previous == ' ' || previous == '-' {
+				hunk.old[len(hunk.old)-1] = strings.TrimSuffix(hunk.old[len(hunk.old)-1], "\n")
+			}
+			if previous == ' ' || previous == '+' {
+				hunk.new[len(hunk.new)-1] = strings.TrimSuffix(hunk.new[len(hunk.new)-1], "\n")
+			}
+		default:
+			return nil, 0, apierror.Newf(apierror.CodeInvalidRequest, "line %d: unexpected line in hunk '%s'", i+1, header)
+		}
+		if len(hunk.old) > oldLines || len(hunk.new) > newLines {
+			return nil, 0, apierror.Newf(apierror.CodeInvalidRequest, "line %d: hunk '%s' has more lines than its header says", i+1, header)
+		}
+		previous = kind
+	}
+	if len(hunk.old) < oldLines || len(hunk.new) < newLines {
+		return nil, 0, apierror.Newf(apierror.CodeInvalidRequest, "line %d: hunk '%s' is truncated", start+1, header)
+	}
+
+	hunk.text = strings.Join(lines[start:i], "")
+	if !strings.HasSuffix(hunk.text, "\n") {
+		hunk.text += "\n"
+	}
+	return hunk, i, nil
+}
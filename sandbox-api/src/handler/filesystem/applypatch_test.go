@@ -0,0 +1,202 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestApplyPatch tests applying a git patch creating, deleting, renaming and changing files
+func TestApplyPatch(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for path, content := range map[string]string{
+		"main.go":     "package main\n\nimport \"fmt\"\n\n// main prints\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n",
+		"old.txt":     "a\nb\n",
+		"legacy.txt":  "gone\n",
+		"run.sh":      "#!/bin/sh\n",
+		"version.txt": "1",
+	} {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	read := func(path string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, path))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(content)
+	}
+
+	// The main.go hunk expects its lines 2 lines above where they are
+	patch := `From 3f2a Mon Sep 17 00:00:00 2001
+Subject: [PATCH] Update
+
+---
+ main.go | 2 +-
+diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -4,3 +4,3 @@ import "fmt"
+ func main() {
+-	fmt.Println("hi")
++	fmt.Println("hello")
+ }
+diff --git a/notes/new.txt b/notes/new.txt
+new file mode 100644
+--- /dev/null
++++ b/notes/new.txt
+@@ -0,0 +1,2 @@
++first
++second
+diff --git a/legacy.txt b/legacy.txt
+deleted file mode 100644
+--- a/legacy.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/old.txt b/renamed.txt
+similarity index 50%
+rename from old.txt
+rename to renamed.txt
+--- a/old.txt
++++ b/renamed.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/version.txt b/version.txt
+--- a/version.txt
++++ b/version.txt
+@@ -1 +1 @@
+-1
+\ No newline at end of file
++2
+--
+2.43.0
+`
+	result, err := fs.ApplyPatch(tempDir, patch, PatchOptions{Strip: -1})
+	if err != nil {
+		t.Fatalf("Failed to apply patch: %v", err)
+	}
+	if !result.Applied || !result.Written || result.HunksApplied != 5 || result.HunksRejected != 0 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	statuses := []string{}
+	for _, file := range result.Files {
+		statuses = append(statuses, file.Status)
+	}
+	if strings.Join(statuses, ",") != "modified,added,deleted,renamed,modified,modified" {
+		t.Errorf("Unexpected statuses %v", statuses)
+	}
+	if hunk := result.Files[0].Hunks[0]; hunk.Line != 6 || hunk.Offset != 2 {
+		t.Errorf("Expected the main.go hunk to apply 2 lines below, got %+v", hunk)
+	}
+
+	if !strings.Contains(read("main.go"), "fmt.Println(\"hello\")") {
+		t.Errorf("Unexpected main.go:\n%s", read("main.go"))
+	}
+	if read("notes/new.txt") != "first\nsecond\n" || read("legacy.txt") != "<missing>" {
+		t.Errorf("Expected notes/new.txt to be created and legacy.txt deleted")
+	}
+	if read("old.txt") != "<missing>" || read("renamed.txt") != "a\nc\n" {
+		t.Errorf("Expected old.txt to be renamed, got %q", read("renamed.txt"))
+	}
+	if info, err := os.Stat(filepath.Join(tempDir, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected run.sh to be executable, got %v (%v)", info.Mode(), err)
+	}
+	if read("version.txt") != "2\n" {
+		t.Errorf("Expected a newline to be added to version.txt, got %q", read("version.txt"))
+	}
+}
+
+// TestApplyPatchRejects tests what happens to the hunks which do not apply
+func TestApplyPatchRejects(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	original := "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+	if err := fs.WriteFile("list.txt", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	read := func(path string) string {
+		content, _ := os.ReadFile(filepath.Join(tempDir, path))
+		return string(content)
+	}
+
+	patch := `--- list.txt
++++ list.txt
+@@ -1,2 +1,2 @@
+-one
++ONE
+ two
+@@ -6,2 +6,2 @@
+ six
+-eight
++EIGHT
+`
+	for _, reject := range []string{PatchRejectAbort, PatchRejectSkip, PatchRejectFile} {
+		result, err := fs.ApplyPatch(tempDir, patch, PatchOptions{Strip: -1, DryRun: true, Reject: reject})
+		if err != nil || result.Applied || result.Written || result.HunksApplied != 1 || result.HunksRejected != 1 {
+			t.Errorf("Unexpected dry run result %+v (%v)", result, err)
+		}
+	}
+	if read("list.txt") != original {
+		t.Fatal("Expected a dry run to write nothing")
+	}
+
+	result, err := fs.ApplyPatch(tempDir, patch, PatchOptions{Strip: -1})
+	if err != nil || result.Written || read("list.txt") != original {
+		t.Fatalf("Expected an aborted patch to write nothing, got %+v (%v)", result, err)
+	}
+	if hunk := result.Files[0].Hunks[1]; hunk.Status != PatchHunkRejected || hunk.Header != "@@ -6,2 +6,2 @@" {
+		t.Errorf("Expected the second hunk to be rejected, got %+v", hunk)
+	}
+
+	result, err = fs.ApplyPatch(tempDir, patch, PatchOptions{Strip: -1, Reject: PatchRejectFile})
+	if err != nil || !result.Written || !strings.HasPrefix(read("list.txt"), "ONE\n") {
+		t.Fatalf("Expected the first hunk to be written, got %+v (%v)", result, err)
+	}
+	if result.Files[0].RejectFile != filepath.Join(tempDir, "list.txt.rej") || read("list.txt.rej") != "--- list.txt\n+++ list.txt\n@@ -6,2 +6,2 @@\n six\n-eight\n+EIGHT\n" {
+		t.Errorf("Unexpected reject file %s:\n%s", result.Files[0].RejectFile, read("list.txt.rej"))
+	}
+
+	// A missing file rejects all its hunks
+	result, err = fs.ApplyPatch(tempDir, "--- a/missing.txt\n+++ b/missing.txt\n@@ -1 +1 @@\n-x\n+y\n", PatchOptions{Strip: -1, Reject: PatchRejectSkip})
+	if err != nil || result.Written || result.Files[0].Error == "" || result.HunksRejected != 1 {
+		t.Errorf("Expected the missing file to be rejected, got %+v (%v)", result, err)
+	}
+}
+
+// TestParsePatchErrors tests that invalid patches and paths are rejected
+func TestParsePatchErrors(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for _, patch := range []string{
+		"",
+		"not a patch\n",
+		"@@ -1 +1 @@\n-x\n+y\n",
+		"--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n-x\n+y\n",
+		"--- a/x\n+++ b/x\n@@ -1 +1 @@\n-x\n*y\n",
+	} {
+		if _, err := fs.ApplyPatch(tempDir, patch, PatchOptions{Strip: -1}); !errors.Is(err, apierror.New(apierror.CodeInvalidRequest, "")) {
+			t.Errorf("Expected INVALID_REQUEST for %q, got %v", patch, err)
+		}
+	}
+
+	result, err := fs.ApplyPatch(tempDir, "--- a/../outside.txt\n+++ b/../outside.txt\n@@ -0,0 +1 @@\n+x\n", PatchOptions{Strip: -1})
+	if err != nil || result.Applied || !strings.Contains(result.Files[0].Error, "outside") {
+		t.Errorf("Expected a path outside of the directory to be rejected, got %+v (%v)", result, err)
+	}
+}
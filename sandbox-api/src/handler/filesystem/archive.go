@@ -0,0 +1,229 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Archive formats supported by WriteArchive
+const (
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatZip   = "zip"
+)
+
+// WriteArchive streams a compressed archive of the directory at path to w.
+// Entries are stored relative to the archived directory.
+func (fs *Filesystem) WriteArchive(path string, format string, w io.Writer) error {
+	return fs.WriteArchiveWithProgress(path, format, w, nil)
+}
+
+// ArchiveProgressFunc is called as the content of the files is archived, with the bytes
+// archived so far out of the total size of the files
+type ArchiveProgressFunc func(done int64, total int64)
+
+// WriteArchiveWithProgress is WriteArchive reporting its progress to progress, when it
+// is not nil. The size of the files is added up before they are archived.
+func (fs *Filesystem) WriteArchiveWithProgress(path string, format string, w io.Writer, progress ArchiveProgressFunc) error {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDirectory
+	}
+
+	var counter *archiveCounter
+	if progress != nil {
+		total, err := filesSize(absPath)
+		if err != nil {
+			return err
+		}
+		counter = &archiveCounter{total: total, report: progress}
+	}
+
+	switch format {
+	case ArchiveFormatTarGz:
+		return writeTarGz(absPath, w, counter)
+	case ArchiveFormatZip:
+		return writeZip(absPath, w, counter)
+	default:
+		return fmt.Errorf("unsupported archive format '%s', expected 'tar.gz' or 'zip'", format)
+	}
+}
+
+// writeTarGz writes a gzip-compressed tarball of root to w
+func writeTarGz(root string, w io.Writer, counter *archiveCounter) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFileTo(path, tarWriter, counter)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// writeZip writes a zip archive of root to w. Only directories and regular files are included.
+func writeZip(root string, w io.Writer, counter *archiveCounter) error {
+	zipWriter := zip.NewWriter(w)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		entryWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyFileTo(path, entryWriter, counter)
+	})
+	if err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+// copyFileTo streams the file at path into w, counting its bytes with counter unless
+// it is nil
+func copyFileTo(path string, w io.Writer, counter *archiveCounter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if counter != nil {
+		w = &countingWriter{w: w, counter: counter}
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// archiveCounter counts the bytes of file content archived
+type archiveCounter struct {
+	done   int64
+	total  int64
+	report ArchiveProgressFunc
+}
+
+// countingWriter reports the bytes written to w to counter
+type countingWriter struct {
+	w       io.Writer
+	counter *archiveCounter
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.counter.done += int64(n)
+	c.counter.report(c.counter.done, c.counter.total)
+	return n, err
+}
+
+// filesSize returns the total size of the regular files under root
+func filesSize(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
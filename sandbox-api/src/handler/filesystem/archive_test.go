@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"testing"
+)
+
+// TestWriteArchive tests tar.gz and zip archive generation
+func TestWriteArchive(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("project/main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := fs.WriteFile("project/pkg/util.go", []byte("package pkg\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	expected := []string{"main.go", "pkg/", "pkg/util.go"}
+
+	t.Run("TarGz", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := fs.WriteArchive("project", ArchiveFormatTarGz, &buf); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+
+		gzipReader, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("Failed to open gzip stream: %v", err)
+		}
+		tarReader := tar.NewReader(gzipReader)
+
+		var names []string
+		contents := map[string]string{}
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Failed to read tar entry: %v", err)
+			}
+			names = append(names, header.Name)
+			data, _ := io.ReadAll(tarReader)
+			contents[header.Name] = string(data)
+		}
+		sort.Strings(names)
+
+		if len(names) != len(expected) {
+			t.Fatalf("Expected entries %v, got %v", expected, names)
+		}
+		for i := range expected {
+			if names[i] != expected[i] {
+				t.Errorf("Expected entry %s, got %s", expected[i], names[i])
+			}
+		}
+		if contents["pkg/util.go"] != "package pkg\n" {
+			t.Errorf("Unexpected content for pkg/util.go: %q", contents["pkg/util.go"])
+		}
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := fs.WriteArchive("project", ArchiveFormatZip, &buf); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+
+		zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("Failed to open zip archive: %v", err)
+		}
+
+		var names []string
+		for _, f := range zipReader.File {
+			names = append(names, f.Name)
+		}
+		sort.Strings(names)
+
+		if len(names) != len(expected) {
+			t.Fatalf("Expected entries %v, got %v", expected, names)
+		}
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := fs.WriteArchive("project", "rar", &buf); err == nil {
+			t.Error("Expected error for unsupported format, but got none")
+		}
+	})
+
+	t.Run("NotADirectory", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := fs.WriteArchive("project/main.go", ArchiveFormatZip, &buf); err == nil {
+			t.Error("Expected error when archiving a file, but got none")
+		}
+	})
+}
@@ -0,0 +1,324 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/objectstore"
+)
+
+// ErrMountPoint is returned when deleting the directory a backend is mounted on
+var ErrMountPoint = apierror.New(apierror.CodeConflict, "path is a mount point")
+
+// ErrMountedPath is returned by the operations working on local files for the paths
+// served by a backend, see Mount
+var ErrMountedPath = apierror.New(apierror.CodeUnprocessable, "operation not supported on a mounted path")
+
+// Backend stores the files of a mount. Names are slash-separated paths within the
+// mount, starting with a slash, the root of the mount being "/". Failures for missing
+// files are *os.PathError wrapping os.ErrNotExist, as with the os package.
+type Backend interface {
+	// Stat returns the info of a file or directory
+	Stat(name string) (os.FileInfo, error)
+	// ReadDir returns the info of the entries of a directory, sorted by name
+	ReadDir(name string) ([]os.FileInfo, error)
+	// ReadFile returns the content of a file
+	ReadFile(name string) ([]byte, error)
+	// WriteFile writes the content read from r to a file, or appends it, creating the
+	// file and its parent directories if needed
+	WriteFile(name string, r io.Reader, perm os.FileMode, append bool) error
+	// MkdirAll creates a directory and its parents
+	MkdirAll(name string, perm os.FileMode) error
+	// Remove removes a file or directory, with its content when recursive is set. An
+	// empty directory is removed without it.
+	Remove(name string, recursive bool) error
+}
+
+// Mount is a backend serving the files under an absolute path
+type Mount struct {
+	Path    string
+	Backend Backend
+}
+
+// Mount serves the files under path from backend rather than from the local
+// filesystem. The reads, writes, listings and deletions of the filesystem go to the
+// backend; the other operations, like moves, searches or watches, fail with
+// ErrMountedPath on the paths of the mount. Processes don't see the mounted files.
+func (fs *Filesystem) Mount(path string, backend Backend) {
+	mounts := append([]Mount(nil), fs.mounts...)
+	fs.mounts = append(mounts, Mount{Path: filepath.Clean(path), Backend: backend})
+}
+
+// Mounts returns the backends mounted on the filesystem
+func (fs *Filesystem) Mounts() []Mount {
+	return fs.mounts
+}
+
+// mounted returns the backend serving absPath and the name of absPath within it, from
+// the mount of the longest path containing absPath
+func (fs *Filesystem) mounted(absPath string) (Backend, string, bool) {
+	var found *Mount
+	for i, mount := range fs.mounts {
+		if absPath != mount.Path && !strings.HasPrefix(absPath, strings.TrimSuffix(mount.Path, "/")+"/") {
+			continue
+		}
+		if found == nil || len(mount.Path) > len(found.Path) {
+			found = &fs.mounts[i]
+		}
+	}
+	if found == nil {
+		return nil, "", false
+	}
+	return found.Backend, path.Join("/", filepath.ToSlash(strings.TrimPrefix(absPath, found.Path))), true
+}
+
+// stat returns the info of the file at absPath, following symbolic links
+func (fs *Filesystem) stat(absPath string) (os.FileInfo, error) {
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.Stat(name)
+	}
+	return os.Stat(absPath)
+}
+
+// readFile returns the content of the file at absPath
+func (fs *Filesystem) readFile(absPath string) ([]byte, error) {
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.ReadFile(name)
+	}
+	return os.ReadFile(absPath)
+}
+
+// ownerAndGroup returns the owner and group of the file at absPath. Mounted files
+// whose backend doesn't know their owner have none.
+func (fs *Filesystem) ownerAndGroup(absPath string, info os.FileInfo) (string, string, error) {
+	if _, _, ok := fs.mounted(absPath); ok {
+		owner, group := statOwnerAndGroup(info)
+		return owner, group, nil
+	}
+	return fs.getFileOwnerAndGroup(absPath)
+}
+
+// statOwnerAndGroup returns the names of the owner and group of a file from its info,
+// or their ids when they have no name, or nothing when the info has no owner
+func statOwnerAndGroup(info os.FileInfo) (string, string) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+
+	ownerName := strconv.FormatUint(uint64(stat.Uid), 10)
+	if u, err := user.LookupId(ownerName); err == nil {
+		ownerName = u.Username
+	}
+	groupName := strconv.FormatUint(uint64(stat.Gid), 10)
+	if g, err := user.LookupGroupId(groupName); err == nil {
+		groupName = g.Name
+	}
+	return ownerName, groupName
+}
+
+// nopSeekCloser is the content of a mounted file, read in memory
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// OpenFile opens the file at path for reading. Mounted files are read in memory.
+func (fs *Filesystem) OpenFile(path string) (io.ReadSeekCloser, os.FileInfo, error) {
+	absPath, err := fs.ResolvePath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := fs.stat(absPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return nil, nil, ErrIsDirectory
+	}
+
+	if backend, name, ok := fs.mounted(absPath); ok {
+		content, err := backend.ReadFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nopSeekCloser{bytes.NewReader(content)}, info, nil
+	}
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// mountPointsIn returns the names of the mount points directly under the directory at
+// absPath
+func (fs *Filesystem) mountPointsIn(absPath string) []string {
+	var names []string
+	for _, mount := range fs.mounts {
+		if mount.Path != absPath && filepath.Dir(mount.Path) == absPath {
+			names = append(names, filepath.Base(mount.Path))
+		}
+	}
+	return names
+}
+
+// DirBackend stores the files of a mount in a local directory
+type DirBackend struct {
+	Dir string
+}
+
+// NewDirBackend returns a backend storing files in dir
+func NewDirBackend(dir string) *DirBackend {
+	return &DirBackend{Dir: dir}
+}
+
+func (b *DirBackend) path(name string) string {
+	return filepath.Join(b.Dir, filepath.FromSlash(name))
+}
+
+// Stat implements Backend
+func (b *DirBackend) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(b.path(name))
+}
+
+// ReadDir implements Backend
+func (b *DirBackend) ReadDir(name string) ([]os.FileInfo, error) {
+	return readDirInfos(b.path(name))
+}
+
+// ReadFile implements Backend
+func (b *DirBackend) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(b.path(name))
+}
+
+// WriteFile implements Backend
+func (b *DirBackend) WriteFile(name string, r io.Reader, perm os.FileMode, append bool) error {
+	return writeLocalFile(b.path(name), r, perm, append)
+}
+
+// MkdirAll implements Backend
+func (b *DirBackend) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(b.path(name), perm)
+}
+
+// Remove implements Backend
+func (b *DirBackend) Remove(name string, recursive bool) error {
+	if recursive {
+		return os.RemoveAll(b.path(name))
+	}
+	return os.Remove(b.path(name))
+}
+
+// readDirInfos returns the info of the entries of a local directory, without following
+// symbolic links
+func readDirInfos(dir string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := os.Lstat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+// sortInfos sorts the info of the entries of a directory by name
+func sortInfos(infos []os.FileInfo) {
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+}
+
+// writeLocalFile writes or appends the content read from r to a local file, creating
+// its parent directories
+func writeLocalFile(absPath string, r io.Reader, perm os.FileMode, append bool) error {
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if append {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(absPath, flags, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		if !append {
+			_ = os.Remove(absPath)
+		}
+		return err
+	}
+	return f.Close()
+}
+
+// Mounts configured from the environment, shared by the filesystems of all handlers
+var (
+	defaultMounts     []Mount
+	defaultMountsOnce sync.Once
+)
+
+// DefaultMounts returns the mounts configured from the environment, see MountsFromEnv
+func DefaultMounts() []Mount {
+	defaultMountsOnce.Do(func() {
+		defaultMounts = MountsFromEnv()
+	})
+	return defaultMounts
+}
+
+// MountsFromEnv returns the mounts configured from the environment: ARTIFACTS_BUCKET, an
+// s3:// or gs:// location, mounts the objects under it on ARTIFACTS_PATH, /artifacts by
+// default, see objectstore.ConfigFromEnv for the endpoint and credentials. The overlay
+// of FILESYSTEM_OVERLAY_UPPER is a kernel mount, see MountOverlayFromEnv.
+func MountsFromEnv() []Mount {
+	var mounts []Mount
+
+	if bucket := os.Getenv("ARTIFACTS_BUCKET"); bucket != "" {
+		mountPath := os.Getenv("ARTIFACTS_PATH")
+		if mountPath == "" {
+			mountPath = "/artifacts"
+		}
+		location, err := objectstore.ParseLocation(bucket)
+		switch {
+		case err != nil:
+			logrus.Warnf("Invalid ARTIFACTS_BUCKET value '%s', artifacts disabled: %v", bucket, err)
+		case !filepath.IsAbs(mountPath):
+			logrus.Warnf("Invalid ARTIFACTS_PATH value '%s', artifacts disabled", mountPath)
+		default:
+			client := objectstore.New(objectstore.ConfigFromEnv("ARTIFACTS", location), location.Bucket)
+			mounts = append(mounts, Mount{Path: filepath.Clean(mountPath), Backend: NewObjectBackend(client, location.Prefix)})
+			logrus.Infof("Mounted %s on %s", location, mountPath)
+		}
+	}
+
+	return mounts
+}
+
+// mountPathError is the failure of an operation on a mount point
+func mountPathError(op string, absPath string) error {
+	return fmt.Errorf("%w: cannot %s %s", ErrMountPoint, op, absPath)
+}
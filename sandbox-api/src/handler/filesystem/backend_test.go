@@ -0,0 +1,250 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/objectstore"
+)
+
+// memoryStore is an ObjectStore keeping objects in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: map[string][]byte{}}
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) (io.ReadCloser, objectstore.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.objects[key]
+	if !ok {
+		return nil, objectstore.Object{}, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(content)), objectstore.Object{Key: key, Size: int64(len(content))}, nil
+}
+
+func (m *memoryStore) Head(ctx context.Context, key string) (objectstore.Object, error) {
+	body, object, err := m.Get(ctx, key)
+	if err == nil {
+		body.Close()
+	}
+	return object, err
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) != size {
+		return errors.New("size mismatch")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = content
+	return nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memoryStore) List(ctx context.Context, prefix string, delimiter string, limit int) ([]objectstore.Object, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var objects []objectstore.Object
+	var prefixes []string
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			common := key[:len(prefix)+i+1]
+			if len(prefixes) == 0 || prefixes[len(prefixes)-1] != common {
+				prefixes = append(prefixes, common)
+			}
+			continue
+		}
+		objects = append(objects, objectstore.Object{Key: key, Size: int64(len(m.objects[key]))})
+	}
+	return objects, prefixes, nil
+}
+
+func names(infos []os.FileInfo) []string {
+	result := make([]string, len(infos))
+	for i, info := range infos {
+		result[i] = info.Name()
+	}
+	return result
+}
+
+// TestMountOverlay tests that the writes under an overlay go to the upper directory,
+// for the API and processes alike
+func TestMountOverlay(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Mounting an overlay needs root")
+	}
+	dir := t.TempDir()
+	mountPath, upper := filepath.Join(dir, "workspace"), filepath.Join(dir, "upper")
+	if err := os.MkdirAll(mountPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mountPath, "base.txt"), []byte("base"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := MountOverlay(mountPath, mountPath, upper, filepath.Join(dir, "work")); err != nil {
+		t.Skipf("Overlays are not supported: %v", err)
+	}
+	t.Cleanup(func() { _ = syscall.Unmount(mountPath, 0) })
+	if mounted, err := isOverlayMounted(mountPath); err != nil || !mounted {
+		t.Errorf("Expected the overlay to be mounted, got %v (%v)", mounted, err)
+	}
+
+	fs := NewFilesystem(dir)
+	if err := fs.WriteFile(filepath.Join(mountPath, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if err := fs.MoveFile(filepath.Join(mountPath, "base.txt"), filepath.Join(mountPath, "moved.txt")); err != nil {
+		t.Fatalf("Failed to move: %v", err)
+	}
+	if output, err := exec.Command("ls", mountPath).Output(); err != nil || string(output) != "moved.txt\npackage.json\n" {
+		t.Errorf("Expected processes to see the files of the API, got %q (%v)", output, err)
+	}
+	if _, err := os.Stat(filepath.Join(upper, "package.json")); err != nil {
+		t.Errorf("Expected the file to be written to the upper directory, got %v", err)
+	}
+
+	// The lower directory is hidden by the mount, it is only left untouched once unmounted
+	if err := syscall.Unmount(mountPath, 0); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(mountPath); len(entries) != 1 || entries[0].Name() != "base.txt" {
+		t.Errorf("Expected the lower directory to be untouched, got %v", entries)
+	}
+}
+
+// TestObjectBackend tests files and directories stored as objects
+func TestObjectBackend(t *testing.T) {
+	store := newMemoryStore()
+	backend := NewObjectBackend(store, "sandbox")
+
+	if err := backend.WriteFile("/out/report.txt", strings.NewReader("hello"), 0644, false); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if err := backend.WriteFile("/out/report.txt", strings.NewReader(" world"), 0644, true); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if content := string(store.objects["sandbox/out/report.txt"]); content != "hello world" {
+		t.Errorf("Unexpected object content %q", content)
+	}
+	if err := backend.MkdirAll("/out/empty", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if info, err := backend.Stat("/out"); err != nil || !info.IsDir() {
+		t.Errorf("Expected /out to be a directory, got %v (%v)", info, err)
+	}
+	if _, err := backend.Stat("/missing"); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing file not to exist, got %v", err)
+	}
+	infos, err := backend.ReadDir("/out")
+	if err != nil || strings.Join(names(infos), ",") != "empty,report.txt" {
+		t.Errorf("Unexpected listing %v (%v)", names(infos), err)
+	}
+
+	if err := backend.Remove("/out", false); err == nil {
+		t.Errorf("Expected removing a non-empty directory to fail")
+	}
+	if err := backend.Remove("/out", true); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("Expected all objects to be deleted, got %v", store.objects)
+	}
+}
+
+// TestMount tests that the operations on the paths of a mount go to its backend
+func TestMount(t *testing.T) {
+	root := t.TempDir()
+	fs := NewFilesystem(root)
+	mountPath := filepath.Join(root, "artifacts")
+	store := newMemoryStore()
+	fs.Mount(mountPath, NewObjectBackend(store, ""))
+
+	target := filepath.Join(mountPath, "build", "out.bin")
+	if err := fs.WriteFile(target, []byte("artifact"), 0644); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if _, ok := store.objects["build/out.bin"]; !ok {
+		t.Errorf("Expected the file to be stored as an object, got %v", store.objects)
+	}
+	if _, err := os.Stat(mountPath); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written locally, got %v", err)
+	}
+
+	file, err := fs.ReadFile(target)
+	if err != nil || string(file.Content) != "artifact" {
+		t.Fatalf("Failed to read mounted file: %v", err)
+	}
+	part, err := fs.ReadFileRange(target, 2, 3)
+	if err != nil || string(part.Content) != "tif" {
+		t.Errorf("Unexpected range %v (%v)", part, err)
+	}
+	if etag, err := fs.GetETag(target); err != nil || etag != ContentETag([]byte("artifact")) {
+		t.Errorf("Unexpected ETag %s (%v)", etag, err)
+	}
+
+	dir, err := fs.ListDirectory(root)
+	if err != nil || len(dir.Subdirectories) != 1 || dir.Subdirectories[0].Name != "artifacts" {
+		t.Errorf("Expected the mount point to be listed, got %+v (%v)", dir, err)
+	}
+	if err := fs.DeleteDirectory(mountPath, true); !errors.Is(err, ErrMountPoint) {
+		t.Errorf("Expected deleting the mount point to fail, got %v", err)
+	}
+	if err := fs.DeleteFile(target); err != nil {
+		t.Errorf("Failed to delete mounted file: %v", err)
+	}
+	if exists, _ := fs.FileExists(target); exists {
+		t.Errorf("Expected the deleted file not to exist")
+	}
+
+	// Operations on local files fail rather than not seeing the mounted files
+	if err := fs.WriteFile(target, []byte("artifact"), 0644); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if err := fs.MoveFile(target, filepath.Join(root, "out.bin")); !errors.Is(err, ErrMountedPath) {
+		t.Errorf("Expected moving a mounted file to fail, got %v", err)
+	}
+	if _, err := fs.Grep(mountPath, GrepOptions{Pattern: "artifact"}); !errors.Is(err, ErrMountedPath) {
+		t.Errorf("Expected searching a mount to fail, got %v", err)
+	}
+	if _, err := fs.WatchDirectory(mountPath, func(fsnotify.Event) {}); !errors.Is(err, ErrMountedPath) {
+		t.Errorf("Expected watching a mount to fail, got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// Batch operation types
+const (
+	BatchWrite  = "write"
+	BatchMkdir  = "mkdir"
+	BatchDelete = "delete"
+	BatchMove   = "move"
+	BatchChmod  = "chmod"
+)
+
+// BatchOperation is a single operation of a batch
+type BatchOperation struct {
+	Operation   string `json:"operation" example:"write" enums:"write,mkdir,delete,move,chmod" binding:"required"`
+	Path        string `json:"path" example:"src/app.ts" binding:"required"`
+	Content     string `json:"content,omitempty" example:"export const app = {}"`
+	Destination string `json:"destination,omitempty" example:"src/main.ts"`
+	Permissions string `json:"permissions,omitempty" example:"0644"`
+	Recursive   bool   `json:"recursive,omitempty" example:"false"`
+} // @name BatchOperation
+
+// BatchError reports the operation of a batch that failed
+type BatchError struct {
+	Index     int
+	Operation BatchOperation
+	Err       error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s) failed: %v", e.Index, e.Operation.Operation, e.Operation.Path, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// batch is the state of a batch being applied. Every change is recorded with the way to
+// undo it, and replaced or deleted entries are moved aside to backups in their own
+// directory, so that renames stay atomic and the batch can be rolled back.
+type batch struct {
+	id      string
+	undo    []func() error
+	backups []string
+	// owner is given the files and directories created by the batch, nil for the user
+	// of the API
+	owner *lib.Credential
+}
+
+// ApplyBatch applies operations in order with all-or-nothing semantics. Writes are staged
+// to temporary files and renamed into place, and when an operation fails every operation
+// applied before it is rolled back, leaving the tree as it was.
+func (fs *Filesystem) ApplyBatch(operations []BatchOperation) error {
+	return fs.ApplyBatchAs(operations, nil)
+}
+
+// ApplyBatchAs applies operations like ApplyBatch, giving the files and directories
+// created by the batch to owner. Replaced files keep their owner.
+func (fs *Filesystem) ApplyBatchAs(operations []BatchOperation, owner *lib.Credential) error {
+	// Validate the whole batch before touching the filesystem
+	paths := make([]string, len(operations))
+	destinations := make([]string, len(operations))
+	modes := make([]os.FileMode, len(operations))
+	for i, op := range operations {
+		var err error
+		if paths[i], err = fs.GetAbsolutePath(op.Path); err != nil {
+			return &BatchError{Index: i, Operation: op, Err: err}
+		}
+		if modes[i], err = parseBatchMode(op); err != nil {
+			return &BatchError{Index: i, Operation: op, Err: err}
+		}
+
+		switch op.Operation {
+		case BatchWrite, BatchMkdir, BatchDelete, BatchChmod:
+		case BatchMove:
+			if op.Destination == "" {
+				return &BatchError{Index: i, Operation: op, Err: fmt.Errorf("destination is required")}
+			}
+			if destinations[i], err = fs.GetAbsolutePath(op.Destination); err != nil {
+				return &BatchError{Index: i, Operation: op, Err: err}
+			}
+		default:
+			return &BatchError{Index: i, Operation: op, Err: fmt.Errorf("unknown operation '%s'", op.Operation)}
+		}
+	}
+
+	b := &batch{id: uuid.New().String()[:8], owner: owner}
+	for i, op := range operations {
+		var err error
+		switch op.Operation {
+		case BatchWrite:
+			err = b.write(paths[i], []byte(op.Content), modes[i])
+		case BatchMkdir:
+			err = b.mkdir(paths[i], modes[i])
+		case BatchDelete:
+			err = b.delete(paths[i], op.Recursive)
+		case BatchMove:
+			err = b.move(paths[i], destinations[i])
+		case BatchChmod:
+			err = b.chmod(paths[i], modes[i])
+		}
+		if err != nil {
+			if rollbackErr := b.rollback(); rollbackErr != nil {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+			}
+			return &BatchError{Index: i, Operation: op, Err: err}
+		}
+	}
+
+	b.commit()
+	return nil
+}
+
+// parseBatchMode returns the mode of an operation, with defaults for writes and mkdirs
+func parseBatchMode(op BatchOperation) (os.FileMode, error) {
+	if op.Permissions == "" {
+		switch op.Operation {
+		case BatchChmod:
+			return 0, fmt.Errorf("permissions are required")
+		case BatchMkdir:
+			return 0755, nil
+		}
+		return 0644, nil
+	}
+	perm, err := strconv.ParseUint(op.Permissions, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid permissions format '%s': %w", op.Permissions, err)
+	}
+	return os.FileMode(perm), nil
+}
+
+// sidePath returns a path next to path for a staged file or a backup of this batch
+func (b *batch) sidePath(path string, kind string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.batch-%s.%s", filepath.Base(path), b.id, kind))
+}
+
+// mkdirParents creates the missing parent directories of path
+func (b *batch) mkdirParents(path string) error {
+	return b.mkdir(filepath.Dir(path), 0755)
+}
+
+// mkdir creates a directory and its missing parents
+func (b *batch) mkdir(path string, mode os.FileMode) error {
+	// Find the topmost missing directory, which is removed on rollback
+	created := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		created = dir
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	if created == "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("path exists and is not a directory")
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(path, mode); err != nil {
+		return err
+	}
+	b.undo = append(b.undo, func() error { return os.RemoveAll(created) })
+	if b.owner != nil {
+		return b.owner.ChownAll(created)
+	}
+	return nil
+}
+
+// moveAside renames an existing entry at path to a backup, restored on rollback
+func (b *batch) moveAside(path string) error {
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	backup := b.sidePath(path, "bak")
+	if err := os.Rename(path, backup); err != nil {
+		return err
+	}
+	b.backups = append(b.backups, backup)
+	b.undo = append(b.undo, func() error { return os.Rename(backup, path) })
+	return nil
+}
+
+// write stages content to a temporary file and renames it over path
+func (b *batch) write(path string, content []byte, mode os.FileMode) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("path is a directory")
+	}
+	if err := b.mkdirParents(path); err != nil {
+		return err
+	}
+
+	staged := b.sidePath(path, "tmp")
+	if err := os.WriteFile(staged, content, mode); err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	if err := os.Chmod(staged, mode); err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	// A replaced file keeps its owner, a created one is given to the owner of the batch
+	var err error
+	if info, statErr := os.Lstat(path); statErr == nil {
+		err = copyOwner(staged, info)
+	} else if b.owner != nil {
+		err = b.owner.Chown(staged)
+	}
+	if err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	if err := b.moveAside(path); err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	if err := os.Rename(staged, path); err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	b.undo = append(b.undo, func() error { return os.Remove(path) })
+	return nil
+}
+
+// delete moves the entry at path aside, it is removed when the batch is committed
+func (b *batch) delete(path string, recursive bool) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !recursive {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("directory is not empty, set recursive to delete it")
+		}
+	}
+	return b.moveAside(path)
+}
+
+// move renames the entry at path to destination, replacing an existing file
+func (b *batch) move(path string, destination string) error {
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(destination); err == nil && info.IsDir() {
+		return fmt.Errorf("destination is an existing directory")
+	}
+	if err := b.mkdirParents(destination); err != nil {
+		return err
+	}
+	if err := b.moveAside(destination); err != nil {
+		return err
+	}
+	if err := os.Rename(path, destination); err != nil {
+		return err
+	}
+	b.undo = append(b.undo, func() error { return os.Rename(destination, path) })
+	return nil
+}
+
+// chmod changes the mode of the entry at path
+func (b *batch) chmod(path string, mode os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	previous := info.Mode().Perm()
+	b.undo = append(b.undo, func() error { return os.Chmod(path, previous) })
+	return nil
+}
+
+// rollback undoes the applied operations in reverse order
+func (b *batch) rollback() error {
+	var firstErr error
+	for i := len(b.undo) - 1; i >= 0; i-- {
+		if err := b.undo[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// commit removes the backups of replaced and deleted entries
+func (b *batch) commit() {
+	for _, backup := range b.backups {
+		_ = os.RemoveAll(backup)
+	}
+}
@@ -0,0 +1,153 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// TestApplyBatch tests batch operations and their rollback
+func TestApplyBatch(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for path, content := range map[string]string{
+		"src/app.ts":     "old app",
+		"src/legacy.ts":  "legacy",
+		"src/util.ts":    "util",
+		"scripts/run.sh": "#!/bin/sh",
+	} {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	read := func(path string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, path))
+		if err != nil {
+			return ""
+		}
+		return string(content)
+	}
+	// entries lists the directory entries, to check that no staged file or backup is left
+	entries := func(path string) int {
+		list, _ := os.ReadDir(filepath.Join(tempDir, path))
+		return len(list)
+	}
+
+	t.Run("Rollback", func(t *testing.T) {
+		err := fs.ApplyBatch([]BatchOperation{
+			{Operation: BatchWrite, Path: "src/app.ts", Content: "new app"},
+			{Operation: BatchMkdir, Path: "src/components/ui"},
+			{Operation: BatchWrite, Path: "src/components/ui/button.ts", Content: "button"},
+			{Operation: BatchMove, Path: "src/util.ts", Destination: "src/lib/util.ts"},
+			{Operation: BatchDelete, Path: "src/legacy.ts"},
+			{Operation: BatchChmod, Path: "scripts/run.sh", Permissions: "0755"},
+			{Operation: BatchDelete, Path: "src/missing.ts"},
+		})
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || batchErr.Index != 6 {
+			t.Fatalf("Expected the last operation to fail, got %v", err)
+		}
+
+		if read("src/app.ts") != "old app" || read("src/legacy.ts") != "legacy" || read("src/util.ts") != "util" {
+			t.Error("Expected replaced, moved and deleted files to be restored")
+		}
+		for _, path := range []string{"src/components", "src/lib"} {
+			if _, err := os.Stat(filepath.Join(tempDir, path)); !os.IsNotExist(err) {
+				t.Errorf("Expected created directory %s to be removed, got %v", path, err)
+			}
+		}
+		if info, _ := os.Stat(filepath.Join(tempDir, "scripts/run.sh")); info.Mode().Perm() != 0644 {
+			t.Errorf("Expected mode to be restored to 644, got %v", info.Mode().Perm())
+		}
+		if entries("src") != 3 {
+			t.Errorf("Expected src to hold its 3 original files only, got %d entries", entries("src"))
+		}
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		err := fs.ApplyBatch([]BatchOperation{
+			{Operation: BatchWrite, Path: "src/app.ts", Content: "new app"},
+			{Operation: BatchWrite, Path: "src/components/button.ts", Content: "button"},
+			{Operation: BatchMove, Path: "src/util.ts", Destination: "src/lib/util.ts"},
+			{Operation: BatchDelete, Path: "src/legacy.ts"},
+			{Operation: BatchChmod, Path: "scripts/run.sh", Permissions: "0755"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to apply batch: %v", err)
+		}
+
+		if read("src/app.ts") != "new app" || read("src/components/button.ts") != "button" || read("src/lib/util.ts") != "util" {
+			t.Error("Expected writes and moves to be applied")
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "src/legacy.ts")); !os.IsNotExist(err) {
+			t.Errorf("Expected src/legacy.ts to be deleted, got %v", err)
+		}
+		if info, _ := os.Stat(filepath.Join(tempDir, "scripts/run.sh")); info.Mode().Perm() != 0755 {
+			t.Errorf("Expected mode 755, got %v", info.Mode().Perm())
+		}
+		if entries("src") != 3 {
+			t.Errorf("Expected no backups left in src, got %d entries", entries("src"))
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		err := fs.ApplyBatch([]BatchOperation{
+			{Operation: BatchWrite, Path: "src/app.ts", Content: "changed"},
+			{Operation: "rename", Path: "src/app.ts"},
+		})
+		if err == nil {
+			t.Fatal("Expected error for an unknown operation, but got none")
+		}
+		if read("src/app.ts") != "new app" {
+			t.Error("Expected nothing to be applied when the batch is invalid")
+		}
+	})
+}
+
+// TestApplyBatchAs tests that the entries created by a batch are given to its owner while
+// replaced files keep their own
+func TestApplyBatchAs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing the owner of files requires root")
+	}
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("kept.txt", []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Chown(filepath.Join(tempDir, "kept.txt"), 4321, 4321); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &lib.Credential{UID: 1234, GID: 1234}
+	err := fs.ApplyBatchAs([]BatchOperation{
+		{Operation: BatchWrite, Path: "kept.txt", Content: "new"},
+		{Operation: BatchWrite, Path: "src/app.ts", Content: "app"},
+		{Operation: BatchMkdir, Path: "assets/images"},
+	}, owner)
+	if err != nil {
+		t.Fatalf("Failed to apply batch: %v", err)
+	}
+
+	for path, uid := range map[string]uint32{
+		"kept.txt":      4321,
+		"src":           1234,
+		"src/app.ts":    1234,
+		"assets":        1234,
+		"assets/images": 1234,
+	} {
+		info, err := os.Lstat(filepath.Join(tempDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat := info.Sys().(*syscall.Stat_t); stat.Uid != uid || stat.Gid != uid {
+			t.Errorf("Expected %s to be owned by %d, got %d:%d", path, uid, stat.Uid, stat.Gid)
+		}
+	}
+}
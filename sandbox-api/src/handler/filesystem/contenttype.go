@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// DetectContentType returns the MIME type of a file from the type of its extension, or
+// else from its first bytes, as net/http does. Sniffing recognizes common binary formats
+// and tells text from binary content, which extensions can't for the files without one.
+func DetectContentType(name string, head []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(head[:min(len(head), sniffLen)])
+}
+
+// FileContentType returns the MIME type of the file at absPath, see DetectContentType
+func FileContentType(absPath string) (string, error) {
+	file, err := os.Open(absPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return ReaderContentType(absPath, file)
+}
+
+// ReaderContentType returns the MIME type of the file name whose content r reads, see
+// DetectContentType. r is rewound to the beginning of the content.
+func ReaderContentType(name string, r io.ReadSeeker) (string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return DetectContentType(name, head[:n]), nil
+}
@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDetectContentType tests detecting types from extensions and content
+func TestDetectContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 16))
+	for _, test := range []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{"data.json", []byte(`{"a": 1}`), "application/json"},
+		{"image", png, "image/png"},
+		{"README", []byte("# Title\n"), "text/plain; charset=utf-8"},
+		{"binary", []byte{0x7f, 'E', 'L', 'F', 0, 0, 1}, "application/octet-stream"},
+	} {
+		if contentType := DetectContentType(test.name, test.head); contentType != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.name, contentType)
+		}
+	}
+}
+
+// TestReadFileContentType tests that reads return the type of the file, detected from
+// its beginning for range reads
+func TestReadFileContentType(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	content := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 1024)
+	if err := fs.WriteFile("screenshot", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	file, err := fs.ReadFile("screenshot")
+	if err != nil || file.ContentType != "image/png" {
+		t.Errorf("Expected image/png, got %+v (%v)", file, err)
+	}
+	file, err = fs.ReadFileRange("screenshot", 600, 10)
+	if err != nil || file.ContentType != "image/png" {
+		t.Errorf("Expected image/png for a range, got %+v (%v)", file, err)
+	}
+}
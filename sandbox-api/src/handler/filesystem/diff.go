@@ -0,0 +1,271 @@
+package filesystem
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
+)
+
+// Limits of diffs
+const (
+	// DefaultDiffContext is the number of unchanged lines around the changes of a hunk
+	DefaultDiffContext = 3
+	// MaxDiffContext is the maximum number of unchanged lines around the changes of a hunk
+	MaxDiffContext = 10000
+	// maxDiffFiles is the maximum number of files compared between two directories
+	maxDiffFiles = 10000
+	// maxDiffFileSize is the size above which files are compared as binary files
+	maxDiffFileSize = 8 << 20
+)
+
+// Statuses of the files of a diff
+const (
+	DiffStatusModified = "modified"
+	DiffStatusAdded    = "added"
+	DiffStatusDeleted  = "deleted"
+)
+
+// devNull labels the missing side of an added or deleted file, as in unified diffs
+const devNull = "/dev/null"
+
+// DiffOptions are the options of a diff
+type DiffOptions struct {
+	// Context is the number of unchanged lines around the changes of a hunk
+	Context int
+	// Text compares all files as text, even the ones that look binary
+	Text bool
+	// Exclude are globs of the files and directories skipped when comparing directories
+	Exclude []string
+}
+
+// FileDiff is the difference between two versions of a file
+type FileDiff struct {
+	// From is the path of the old version, empty for an added file
+	From string `json:"from,omitempty" example:"/app/main.go"`
+	// To is the path of the new version, empty for a deleted file
+	To     string `json:"to,omitempty" example:"/app-v2/main.go"`
+	Status string `json:"status" example:"modified" enums:"modified,added,deleted" binding:"required"`
+	// Binary is true when either version is binary, without diff nor hunks then
+	Binary    bool               `json:"binary" example:"false"`
+	Diff      string             `json:"diff" example:"--- a/app/main.go\n+++ b/app-v2/main.go\n@@ -1,3 +1,3 @@\n-package app\n+package main"`
+	Hunks     []codegen.DiffHunk `json:"hunks"`
+	Additions int                `json:"additions" example:"1"`
+	Deletions int                `json:"deletions" example:"1"`
+} // @name FileDiff
+
+// DiffResult is the difference between two files, two directories, or a file and content
+type DiffResult struct {
+	Identical bool `json:"identical" example:"false"`
+	// Files are the files which differ, sorted by path
+	Files []FileDiff `json:"files" binding:"required"`
+	// Diff is the unified diff of all the files
+	Diff      string `json:"diff" example:"--- a/app/main.go\n+++ b/app-v2/main.go\n@@ -1,3 +1,3 @@\n-package app\n+package main"`
+	Additions int    `json:"additions" example:"1"`
+	Deletions int    `json:"deletions" example:"1"`
+} // @name DiffResult
+
+// DiffPaths compares two files, or two directories file by file
+func (fs *Filesystem) DiffPaths(from string, to string, opts DiffOptions) (*DiffResult, error) {
+	absFrom, err := fs.ResolvePath(from)
+	if err != nil {
+		return nil, err
+	}
+	absTo, err := fs.ResolvePath(to)
+	if err != nil {
+		return nil, err
+	}
+	fromInfo, err := fs.stat(absFrom)
+	if err != nil {
+		return nil, err
+	}
+	toInfo, err := fs.stat(absTo)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromInfo.IsDir() != toInfo.IsDir() {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "cannot compare a file with a directory")
+	}
+	if !fromInfo.IsDir() {
+		fromContent, fromTooLarge, err := fs.readDiffFile(absFrom, fromInfo.Size())
+		if err != nil {
+			return nil, err
+		}
+		toContent, toTooLarge, err := fs.readDiffFile(absTo, toInfo.Size())
+		if err != nil {
+			return nil, err
+		}
+		file := diffFile(fs.ResolveDisplayPath(from), fs.ResolveDisplayPath(to), fromContent, toContent, fromTooLarge || toTooLarge, opts)
+		return newDiffResult(file), nil
+	}
+
+	fromFiles, err := fs.diffDirectoryFiles(from, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := fs.diffDirectoryFiles(to, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	rels := make([]string, 0, len(fromFiles)+len(toFiles))
+	for rel := range fromFiles {
+		rels = append(rels, rel)
+	}
+	for rel := range toFiles {
+		if _, exists := fromFiles[rel]; !exists {
+			rels = append(rels, rel)
+		}
+	}
+	if len(rels) > maxDiffFiles {
+		return nil, apierror.Newf(apierror.CodeUnprocessable, "cannot compare more than %d files, exclude some of them", maxDiffFiles)
+	}
+	sort.Strings(rels)
+
+	fromDisplay := fs.ResolveDisplayPath(from)
+	toDisplay := fs.ResolveDisplayPath(to)
+	files := make([]*FileDiff, 0, len(rels))
+	for _, rel := range rels {
+		var fromPath, toPath string
+		var fromContent, toContent []byte
+		var fromTooLarge, toTooLarge bool
+		if absPath, exists := fromFiles[rel]; exists {
+			fromPath = filepath.Join(fromDisplay, rel)
+			if fromContent, fromTooLarge, err = fs.readDiffFile(absPath, -1); err != nil {
+				return nil, err
+			}
+		}
+		if absPath, exists := toFiles[rel]; exists {
+			toPath = filepath.Join(toDisplay, rel)
+			if toContent, toTooLarge, err = fs.readDiffFile(absPath, -1); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, diffFile(fromPath, toPath, fromContent, toContent, fromTooLarge || toTooLarge, opts))
+	}
+	return newDiffResult(files...), nil
+}
+
+// DiffContent compares a file with content, as if the file was replaced by the content
+func (fs *Filesystem) DiffContent(path string, content []byte, opts DiffOptions) (*DiffResult, error) {
+	absPath, err := fs.ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrIsDirectory
+	}
+	original, tooLarge, err := fs.readDiffFile(absPath, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	displayPath := fs.ResolveDisplayPath(path)
+	return newDiffResult(diffFile(displayPath, displayPath, original, content, tooLarge || len(content) > maxDiffFileSize, opts)), nil
+}
+
+// diffDirectoryFiles returns the absolute paths of the files below a directory by their
+// path relative to it
+func (fs *Filesystem) diffDirectoryFiles(root string, exclude []string) (map[string]string, error) {
+	absRoot, err := fs.GetAbsolutePath(root)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	err = fs.walkFiles(root, nil, exclude, func(path string) error {
+		if len(files) >= maxDiffFiles {
+			return apierror.Newf(apierror.CodeUnprocessable, "cannot compare more than %d files, exclude some of them", maxDiffFiles)
+		}
+		rel, _ := filepath.Rel(absRoot, path)
+		files[rel] = path
+		return nil
+	})
+	return files, err
+}
+
+// readDiffFile reads a file to compare, unless it is too large to be compared as text.
+// size is the size of the file when known, or -1.
+func (fs *Filesystem) readDiffFile(absPath string, size int64) ([]byte, bool, error) {
+	if size > maxDiffFileSize {
+		return nil, true, nil
+	}
+	content, err := fs.readFile(absPath)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(content) > maxDiffFileSize {
+		return nil, true, nil
+	}
+	return content, false, nil
+}
+
+// diffFile compares two versions of a file, an empty path meaning that the version does
+// not exist. Files too large to be read are compared as binary files, that always differ.
+// It returns nil when the versions are identical.
+func diffFile(from string, to string, fromContent []byte, toContent []byte, tooLarge bool, opts DiffOptions) *FileDiff {
+	file := &FileDiff{From: from, To: to, Status: DiffStatusModified, Hunks: []codegen.DiffHunk{}}
+	fromLabel, toLabel := "a"+from, "b"+to
+	switch {
+	case from == "":
+		file.Status = DiffStatusAdded
+		fromLabel = devNull
+	case to == "":
+		file.Status = DiffStatusDeleted
+		toLabel = devNull
+	}
+
+	if !tooLarge && from != "" && to != "" && bytes.Equal(fromContent, toContent) {
+		return nil
+	}
+	if tooLarge || (!opts.Text && (looksBinary(fromContent) || looksBinary(toContent))) {
+		file.Binary = true
+		file.Diff = "Binary files " + fromLabel + " and " + toLabel + " differ\n"
+		return file
+	}
+
+	file.Diff, file.Hunks = codegen.Unified(fromLabel, toLabel, string(fromContent), string(toContent), opts.Context)
+	if file.Diff == "" {
+		// An empty file was added or deleted
+		file.Diff = "--- " + fromLabel + "\n+++ " + toLabel + "\n"
+	}
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				file.Additions++
+			case strings.HasPrefix(line, "-"):
+				file.Deletions++
+			}
+		}
+	}
+	return file
+}
+
+// looksBinary reports whether the first bytes of content contain a NUL byte, like isBinary
+func looksBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLength)], 0) != -1
+}
+
+// newDiffResult returns the result of the comparison of files, nil for the identical ones
+func newDiffResult(files ...*FileDiff) *DiffResult {
+	result := &DiffResult{Files: []FileDiff{}}
+	var diff strings.Builder
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		result.Files = append(result.Files, *file)
+		result.Additions += file.Additions
+		result.Deletions += file.Deletions
+		diff.WriteString(file.Diff)
+	}
+	result.Diff = diff.String()
+	result.Identical = len(result.Files) == 0
+	return result
+}
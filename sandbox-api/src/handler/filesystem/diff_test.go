@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestDiffPaths tests comparing two files and two directories
+func TestDiffPaths(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for path, content := range map[string]string{
+		"v1/main.go":        "package main\n\nfunc main() {}\n",
+		"v1/README.md":      "# app\n",
+		"v1/removed.txt":    "gone\n",
+		"v1/logo.png":       "\x89PNG\x00\x01",
+		"v1/node_modules/x": "1\n",
+		"v2/main.go":        "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		"v2/README.md":      "# app\n",
+		"v2/added.txt":      "new\n",
+		"v2/logo.png":       "\x89PNG\x00\x02",
+		"v2/node_modules/x": "2\n",
+	} {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	result, err := fs.DiffPaths("v1/main.go", "v2/main.go", DiffOptions{Context: 0})
+	if err != nil || len(result.Files) != 1 || result.Identical {
+		t.Fatalf("Unexpected diff %+v (%v)", result, err)
+	}
+	file := result.Files[0]
+	if !strings.HasSuffix(file.From, "v1/main.go") || !strings.Contains(file.Diff, "@@ -3,1 +3,3 @@\n-func main() {}\n+func main() {\n+\tprintln(1)\n+}\n") {
+		t.Errorf("Unexpected file diff %+v", file)
+	}
+	if file.Additions != 3 || file.Deletions != 1 || result.Additions != 3 {
+		t.Errorf("Expected 3 additions and 1 deletion, got %d and %d", file.Additions, file.Deletions)
+	}
+
+	result, err = fs.DiffPaths("v1", "v2", DiffOptions{Context: DefaultDiffContext, Exclude: []string{"node_modules"}})
+	if err != nil {
+		t.Fatalf("Failed to diff directories: %v", err)
+	}
+	statuses := []string{}
+	for _, file := range result.Files {
+		statuses = append(statuses, file.Status)
+	}
+	// added.txt, logo.png, main.go and removed.txt, README.md is identical
+	if strings.Join(statuses, ",") != "added,modified,modified,deleted" {
+		t.Fatalf("Unexpected files %+v", result.Files)
+	}
+	if !result.Files[1].Binary || result.Files[1].Diff == "" || len(result.Files[1].Hunks) != 0 {
+		t.Errorf("Expected logo.png to be compared as binary, got %+v", result.Files[1])
+	}
+	if !strings.HasPrefix(result.Files[0].Diff, "--- /dev/null\n") || !strings.Contains(result.Files[3].Diff, "+++ /dev/null\n") {
+		t.Errorf("Expected added and deleted files to be compared with /dev/null, got %q and %q", result.Files[0].Diff, result.Files[3].Diff)
+	}
+
+	result, _ = fs.DiffPaths("v1/logo.png", "v2/logo.png", DiffOptions{Text: true})
+	if result.Files[0].Binary || len(result.Files[0].Hunks) != 1 {
+		t.Errorf("Expected binary files to be compared as text, got %+v", result.Files[0])
+	}
+
+	if result, err := fs.DiffPaths("v1/README.md", "v2/README.md", DiffOptions{}); err != nil || !result.Identical || result.Diff != "" {
+		t.Errorf("Expected identical files, got %+v (%v)", result, err)
+	}
+	if _, err := fs.DiffPaths("v1", "v2/main.go", DiffOptions{}); !errors.Is(err, apierror.New(apierror.CodeInvalidRequest, "")) {
+		t.Errorf("Expected comparing a directory with a file to fail, got %v", err)
+	}
+	if _, err := fs.DiffPaths("v1/missing", "v2/main.go", DiffOptions{}); apierror.From(err) == nil || apierror.From(err).Code != apierror.CodeFSNotFound {
+		t.Errorf("Expected FS_NOT_FOUND for a missing file, got %v", err)
+	}
+}
+
+// TestDiffContent tests comparing a file with content
+func TestDiffContent(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("config.yaml", []byte("port: 80\nhost: a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	result, err := fs.DiffContent("config.yaml", []byte("port: 8080\nhost: a\n"), DiffOptions{Context: 1})
+	if err != nil || len(result.Files) != 1 {
+		t.Fatalf("Unexpected diff %+v (%v)", result, err)
+	}
+	if !strings.HasSuffix(strings.SplitN(result.Diff, "\n", 2)[0], "config.yaml") || !strings.Contains(result.Diff, "@@ -1,2 +1,2 @@\n-port: 80\n+port: 8080\n host: a\n") {
+		t.Errorf("Unexpected diff:\n%s", result.Diff)
+	}
+	if result, _ := fs.DiffContent("config.yaml", []byte("port: 80\nhost: a\n"), DiffOptions{}); !result.Identical {
+		t.Errorf("Expected the same content to be identical, got %+v", result)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
@@ -13,6 +14,9 @@ import (
 type Subdirectory struct {
 	Path string `json:"path" binding:"required"`
 	Name string `json:"name" binding:"required"`
+	// Files and Subdirectories are only set by recursive listings
+	Files          []*File         `json:"files,omitempty"`
+	Subdirectories []*Subdirectory `json:"subdirectories,omitempty"`
 } // @name Subdirectory
 
 // Directory represents a directory in the filesystem
@@ -132,8 +136,10 @@ func (fs *Filesystem) WatchDirectory(path string, callback func(event fsnotify.E
 
 	err = watcher.Add(absPath)
 	if err != nil {
+		_ = watcher.Close()
 		return nil, err
 	}
+	watchesUsed.Add(1)
 
 	stopChan := make(chan struct{})
 	go func() {
@@ -160,13 +166,17 @@ func (fs *Filesystem) WatchDirectory(path string, callback func(event fsnotify.E
 	stop := func() {
 		close(stopChan)
 		_ = watcher.Close()
+		watchesUsed.Add(-1)
 	}
 	return stop, nil
 }
 
-// WatchDirectoryRecursive watches a directory and all its subdirectories for changes.
-// The callback is called with the event when a change occurs.
-func (fs *Filesystem) WatchDirectoryRecursive(path string, callback func(event fsnotify.Event)) (func(), error) {
+// WatchDirectoryRecursive watches a directory and all its subdirectories for changes,
+// except the subdirectories skip returns true for, skip may be nil. The callback is
+// called with the event when a change occurs, and onError with the errors of the watch.
+// The subdirectories beyond the inotify watch budget shared by all watches are polled
+// instead, see WatchBudgetFromEnv.
+func (fs *Filesystem) WatchDirectoryRecursive(path string, skip func(dir string) bool, callback func(event fsnotify.Event), onError func(err error)) (func(), error) {
 	absPath, err := fs.GetAbsolutePath(path)
 	if err != nil {
 		return nil, err
@@ -177,29 +187,29 @@ func (fs *Filesystem) WatchDirectoryRecursive(path string, callback func(event f
 		return nil, err
 	}
 
-	// Helper to add all subdirectories
-	addDirs := func(root string) error {
-		return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return watcher.Add(p)
-			}
-			return nil
-		})
+	w := &recursiveWatch{
+		watcher: watcher,
+		skip:    skip,
+		onError: onError,
+		poller:  newTreePoller(skip),
 	}
-
-	if err := addDirs(absPath); err != nil {
+	if err := w.addDirs(absPath, false); err != nil {
 		_ = watcher.Close()
+		w.release()
 		return nil, err
 	}
 
 	stopChan := make(chan struct{})
 
 	go func() {
+		defer w.release()
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+
 		for {
 			select {
+			case <-stopChan:
+				return
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
@@ -209,15 +219,25 @@ func (fs *Filesystem) WatchDirectoryRecursive(path string, callback func(event f
 				if event.Op&fsnotify.Create != 0 {
 					info, err := os.Stat(event.Name)
 					if err == nil && info.IsDir() {
-						_ = addDirs(event.Name)
+						_ = w.addDirs(event.Name, true)
 					}
 				}
-				// If a directory is removed, watcher will error on it, but fsnotify cleans up
+				// fsnotify drops the directories removed or moved, they are recounted
+				// on the next tick
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					w.dirty = true
+				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
 				logrus.Error("error:", err)
+				onError(err)
+			case <-ticker.C:
+				if w.dirty {
+					w.sync()
+				}
+				w.poller.poll(callback)
 			}
 		}
 	}()
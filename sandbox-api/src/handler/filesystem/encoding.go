@@ -0,0 +1,52 @@
+package filesystem
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Encodings of file content in JSON
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingBase64 = "base64"
+)
+
+// ContentEncoding returns the encoding content is sent with: the requested encoding, or
+// else utf-8 for valid UTF-8 content and base64 for binary content
+func ContentEncoding(requested string, content []byte) (string, error) {
+	switch requested {
+	case EncodingUTF8, EncodingBase64:
+		return requested, nil
+	case "":
+		if utf8.Valid(content) {
+			return EncodingUTF8, nil
+		}
+		return EncodingBase64, nil
+	}
+	return "", apierror.Newf(apierror.CodeInvalidRequest, "invalid encoding '%s', expected '%s' or '%s'", requested, EncodingUTF8, EncodingBase64)
+}
+
+// EncodeContent returns content encoded with an encoding returned by ContentEncoding
+func EncodeContent(content []byte, encoding string) string {
+	if encoding == EncodingBase64 {
+		return base64.StdEncoding.EncodeToString(content)
+	}
+	return string(content)
+}
+
+// DecodeContent returns the content sent with an encoding, utf-8 when empty
+func DecodeContent(content string, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingUTF8, "":
+		return []byte(content), nil
+	case EncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid base64 content: %w", err)
+		}
+		return decoded, nil
+	}
+	return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid encoding '%s', expected '%s' or '%s'", encoding, EncodingUTF8, EncodingBase64)
+}
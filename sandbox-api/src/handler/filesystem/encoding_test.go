@@ -0,0 +1,41 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestFileWithContentEncoding tests that text and binary content round-trip through JSON
+func TestFileWithContentEncoding(t *testing.T) {
+	for _, test := range []struct {
+		content  string
+		encoding string
+		expected string
+	}{
+		{"hello", "", EncodingUTF8},
+		{"\x00\xff\xfe", "", EncodingBase64},
+		{"hello", EncodingBase64, EncodingBase64},
+	} {
+		file := FileWithContentByte{Content: []byte(test.content), Encoding: test.encoding}
+		data, err := json.Marshal(file)
+		if err != nil {
+			t.Fatalf("Failed to marshal file: %v", err)
+		}
+		if !strings.Contains(string(data), `"encoding":"`+test.expected+`"`) {
+			t.Errorf("Expected the %s encoding, got %s", test.expected, data)
+		}
+
+		var decoded FileWithContentByte
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal file: %v", err)
+		}
+		if string(decoded.Content) != test.content {
+			t.Errorf("Expected %q to round-trip, got %q", test.content, decoded.Content)
+		}
+	}
+
+	if _, err := json.Marshal(FileWithContentByte{Encoding: "latin1"}); err == nil {
+		t.Error("Expected an error for an invalid encoding")
+	}
+}
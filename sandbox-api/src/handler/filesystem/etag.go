@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// ErrPreconditionFailed is returned when a file doesn't match the ETag a write is conditioned on
+var ErrPreconditionFailed = apierror.New(apierror.CodeFSPreconditionFailed, "precondition failed")
+
+// ContentETag returns the strong ETag of content, its quoted sha256
+func ContentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// maxCachedETags is the number of file ETags kept, so that the large files downloaded
+// repeatedly are not hashed on each download
+const maxCachedETags = 1024
+
+// racyModTime is the age under which the modification time of a file is not trusted to
+// change with its next write, as it may land in the same tick of the coarse clock of the
+// filesystem. The ETags of files modified more recently are not cached.
+const racyModTime = time.Second
+
+// fileVersion identifies a version of a file without reading it
+type fileVersion struct {
+	size    int64
+	modTime int64
+	inode   uint64
+}
+
+// cachedETag is the ETag of a version of a file
+type cachedETag struct {
+	version fileVersion
+	etag    string
+}
+
+// etagCache keeps the ETags of files by absolute path
+var etagCache = struct {
+	sync.Mutex
+	entries map[string]cachedETag
+}{entries: map[string]cachedETag{}}
+
+// versionOf returns the version of a file from its info
+func versionOf(info os.FileInfo) fileVersion {
+	version := fileVersion{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		version.inode = uint64(stat.Ino)
+	}
+	return version
+}
+
+// GetETag returns the ETag of the file at path, the quoted sha256 of its content. The
+// ETag is computed once per version of the file, see maxCachedETags, but on each call
+// for mounted files.
+func (fs *Filesystem) GetETag(path string) (string, error) {
+	absPath, err := fs.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if _, _, ok := fs.mounted(absPath); ok {
+		content, err := fs.readFile(absPath)
+		if err != nil {
+			return "", err
+		}
+		return ContentETag(content), nil
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	version := versionOf(info)
+
+	etagCache.Lock()
+	cached, exists := etagCache.entries[absPath]
+	etagCache.Unlock()
+	if exists && cached.version == version {
+		return cached.etag, nil
+	}
+
+	checksums, err := fileChecksums(absPath, []string{ChecksumSHA256})
+	if err != nil {
+		return "", err
+	}
+	etag := `"` + checksums[ChecksumSHA256] + `"`
+
+	// The file may have been written while hashed, the ETag is cached only if the file
+	// still has the version it had before, old enough not to be racy
+	if info, err := os.Stat(absPath); err == nil && versionOf(info) == version && time.Since(info.ModTime()) > racyModTime {
+		etagCache.Lock()
+		if len(etagCache.entries) >= maxCachedETags {
+			for path := range etagCache.entries {
+				delete(etagCache.entries, path)
+				break
+			}
+		}
+		etagCache.entries[absPath] = cachedETag{version: version, etag: etag}
+		etagCache.Unlock()
+	}
+	return etag, nil
+}
+
+// CheckIfMatch returns ErrPreconditionFailed unless the file at path matches one of the
+// comma separated ETags of an If-Match header, "*" matching any existing file. Weak ETags
+// never match, as If-Match uses the strong comparison.
+func (fs *Filesystem) CheckIfMatch(path string, ifMatch string) error {
+	etag, err := fs.GetETag(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: file does not exist", ErrPreconditionFailed)
+		}
+		return err
+	}
+
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: file has changed, current ETag is %s", ErrPreconditionFailed.WithDetail("currentETag", etag), etag)
+}
@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCheckIfMatch tests ETags and If-Match preconditions
+func TestCheckIfMatch(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("notes.txt", []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	etag, err := fs.GetETag("notes.txt")
+	if err != nil {
+		t.Fatalf("Failed to get ETag: %v", err)
+	}
+	if etag != ContentETag([]byte("v1")) {
+		t.Errorf("Expected the ETag of the file to match the ETag of its content, got %s", etag)
+	}
+
+	for _, ifMatch := range []string{etag, `"other", ` + etag, "*"} {
+		if err := fs.CheckIfMatch("notes.txt", ifMatch); err != nil {
+			t.Errorf("Expected If-Match %s to match, got %v", ifMatch, err)
+		}
+	}
+
+	if err := fs.WriteFile("notes.txt", []byte("v2"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, ifMatch := range []string{etag, "W/" + ContentETag([]byte("v2"))} {
+		if err := fs.CheckIfMatch("notes.txt", ifMatch); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("Expected If-Match %s to fail, got %v", ifMatch, err)
+		}
+	}
+	if err := fs.CheckIfMatch("missing.txt", "*"); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected If-Match * to fail for a missing file, got %v", err)
+	}
+}
+
+// TestGetETagCache tests that the ETag of a file is computed once per version of the
+// file, except for recently modified files
+func TestGetETagCache(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("artifact.bin", []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	absPath := filepath.Join(tempDir, "artifact.bin")
+	cached := func() bool {
+		etagCache.Lock()
+		defer etagCache.Unlock()
+		_, exists := etagCache.entries[absPath]
+		return exists
+	}
+
+	if _, err := fs.GetETag("artifact.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if cached() {
+		t.Error("Expected the ETag of a file just modified not to be cached")
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(absPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if etag, err := fs.GetETag("artifact.bin"); err != nil || etag != ContentETag([]byte("v1")) || !cached() {
+		t.Errorf("Expected the ETag of v1 to be cached, got %s (%v)", etag, err)
+	}
+
+	if err := fs.WriteFile("artifact.bin", []byte("v2"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if etag, err := fs.GetETag("artifact.bin"); err != nil || etag != ContentETag([]byte("v2")) {
+		t.Errorf("Expected the ETag of v2, got %s (%v)", etag, err)
+	}
+}
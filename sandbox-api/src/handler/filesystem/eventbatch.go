@@ -0,0 +1,97 @@
+package filesystem
+
+import (
+	"sync"
+	"time"
+)
+
+// maxBatchDelays is how many debounce delays a batch waits at most, so that continuous
+// changes are still emitted
+const maxBatchDelays = 10
+
+// EventBatcher coalesces the events of watched files into batches, to emit bursts of
+// changes like a package install as a few messages. A batch is emitted once no event
+// was received for the debounce delay, or ten delays after its first event. The events
+// of a path in a batch are merged into one, with the operations of all of them and the
+// previous path of the file when it was moved.
+type EventBatcher struct {
+	debounce time.Duration
+	emit     func(events []WatchEvent)
+	emitMu   sync.Mutex
+
+	mu      sync.Mutex
+	events  []WatchEvent
+	paths   map[string]int // index of the event of a path in events
+	first   time.Time
+	timer   *time.Timer
+	stopped bool
+}
+
+// NewEventBatcher returns an EventBatcher calling emit with each batch of events
+func NewEventBatcher(debounce time.Duration, emit func(events []WatchEvent)) *EventBatcher {
+	return &EventBatcher{
+		debounce: debounce,
+		emit:     emit,
+		paths:    make(map[string]int),
+	}
+}
+
+// Add adds an event to the current batch
+func (b *EventBatcher) Add(event WatchEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return
+	}
+	if event.Err != nil {
+		b.events = append(b.events, event)
+	} else if i, exists := b.paths[event.Name]; exists {
+		b.events[i].Op |= event.Op
+		if event.From != "" {
+			b.events[i].From = event.From
+		}
+	} else {
+		b.paths[event.Name] = len(b.events)
+		b.events = append(b.events, event)
+	}
+
+	if b.timer == nil {
+		b.first = time.Now()
+		b.timer = time.AfterFunc(b.debounce, b.flush)
+		return
+	}
+	if time.Since(b.first)+b.debounce < maxBatchDelays*b.debounce {
+		b.timer.Reset(b.debounce)
+	}
+}
+
+// flush emits the current batch
+func (b *EventBatcher) flush() {
+	// Batches are emitted in order
+	b.emitMu.Lock()
+	defer b.emitMu.Unlock()
+
+	b.mu.Lock()
+	events := b.events
+	b.events = nil
+	b.paths = make(map[string]int)
+	b.timer = nil
+	stopped := b.stopped
+	b.mu.Unlock()
+
+	if len(events) > 0 && !stopped {
+		b.emit(events)
+	}
+}
+
+// Stop drops the current batch and ignores the events added afterwards
+func (b *EventBatcher) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
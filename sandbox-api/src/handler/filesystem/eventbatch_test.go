@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestEventBatcher tests coalescing the events of a path into a single batch
+func TestEventBatcher(t *testing.T) {
+	batches := make(chan []WatchEvent, 10)
+	b := NewEventBatcher(50*time.Millisecond, func(events []WatchEvent) {
+		batches <- events
+	})
+	defer b.Stop()
+
+	b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/a", Op: fsnotify.Create}})
+	b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/b", Op: fsnotify.Write}})
+	b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/a", Op: fsnotify.Write}})
+
+	select {
+	case events := <-batches:
+		if len(events) != 2 || events[0].Name != "/tmp/a" || events[0].Op != fsnotify.Create|fsnotify.Write || events[1].Name != "/tmp/b" {
+			t.Errorf("Unexpected batch %v", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a batch")
+	}
+
+	b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/c", Op: fsnotify.Remove}})
+	select {
+	case events := <-batches:
+		if len(events) != 1 || events[0].Name != "/tmp/c" {
+			t.Errorf("Unexpected batch %v", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a second batch")
+	}
+}
+
+// TestEventBatcherMaxDelay tests that continuous events are still emitted
+func TestEventBatcherMaxDelay(t *testing.T) {
+	batches := make(chan []WatchEvent, 10)
+	b := NewEventBatcher(20*time.Millisecond, func(events []WatchEvent) {
+		batches <- events
+	})
+	defer b.Stop()
+
+	deadline := time.After(time.Second)
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-batches:
+			return
+		case <-deadline:
+			t.Fatal("Expected a batch while events keep coming")
+		case <-ticker.C:
+			b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/a", Op: fsnotify.Write}})
+		}
+	}
+}
+
+// TestEventBatcherStop tests that a stopped batcher emits nothing
+func TestEventBatcherStop(t *testing.T) {
+	emitted := make(chan struct{}, 1)
+	b := NewEventBatcher(10*time.Millisecond, func(events []WatchEvent) {
+		emitted <- struct{}{}
+	})
+	b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/a", Op: fsnotify.Write}})
+	b.Stop()
+	b.Add(WatchEvent{Event: fsnotify.Event{Name: "/tmp/b", Op: fsnotify.Write}})
+
+	select {
+	case <-emitted:
+		t.Error("Expected no batch after stopping")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,23 +1,37 @@
 package filesystem
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+var (
+	// ErrIsDirectory is returned for paths pointing to a directory where a file is expected
+	ErrIsDirectory = apierror.New(apierror.CodeFSIsADirectory, "path points to a directory, not a file")
+	// ErrNotDirectory is returned for paths pointing to a file where a directory is expected
+	ErrNotDirectory = apierror.New(apierror.CodeFSNotADirectory, "path points to a file, not a directory")
+	// ErrOutsideRoot is returned for relative paths escaping the root directory
+	ErrOutsideRoot = apierror.New(apierror.CodeFSOutsideRoot, "path is outside of the root directory")
 )
 
 // Filesystem represents the root directory of the filesystem
 type Filesystem struct {
 	Root       string `json:"root"`
 	WorkingDir string `json:"workingDir"`
+	// mounts are the backends serving the files under some paths, see Mount
+	mounts []Mount
 } // @name Filesystem
 
 // FileByte represents a file in the filesystem
@@ -125,13 +139,29 @@ func (f *FileByte) UnmarshalJSON(data []byte) error {
 
 type FileWithContentByte struct {
 	FileByte
-	Content []byte `json:"-"`
+	Content     []byte     `json:"-"`
+	Range       *FileRange `json:"-"`
+	ContentType string     `json:"-"`
+	// Encoding is the encoding of the content in JSON, see ContentEncoding
+	Encoding string `json:"-"`
 }
 
+// FileRange is the part of a file returned by a range read
+type FileRange struct {
+	Offset int64 `json:"offset" example:"1048576" binding:"required"`
+	Length int64 `json:"length" example:"4096" binding:"required"`
+} // @name FileRange
+
 // FileWithContent is a data transfer object for FileWithContent with encoded content
 type FileWithContent struct {
 	File
-	Content string `json:"content" binding:"required"`
+	Content string     `json:"content" binding:"required"`
+	Range   *FileRange `json:"range,omitempty"`
+	// ContentType is the MIME type of the file, from its extension or else its content
+	ContentType string `json:"contentType,omitempty" example:"text/plain; charset=utf-8"`
+	// Encoding is utf-8 for text content and base64 for binary content, unless another
+	// encoding was requested
+	Encoding string `json:"encoding" example:"utf-8" enums:"utf-8,base64"`
 } // @name FileWithContent
 
 // MarshalJSON implements json.Marshaler for custom JSON marshaling
@@ -145,9 +175,16 @@ func (f FileWithContentByte) MarshalJSON() ([]byte, error) {
 		Group:        f.Group,
 	}
 
+	encoding, err := ContentEncoding(f.Encoding, f.Content)
+	if err != nil {
+		return nil, err
+	}
 	return json.Marshal(FileWithContent{
-		File:    fileDTO,
-		Content: string(f.Content),
+		File:        fileDTO,
+		Content:     EncodeContent(f.Content, encoding),
+		Range:       f.Range,
+		ContentType: f.ContentType,
+		Encoding:    encoding,
 	})
 }
 
@@ -169,30 +206,64 @@ func (f *FileWithContentByte) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	content, err := DecodeContent(dto.Content, dto.Encoding)
+	if err != nil {
+		return err
+	}
+
 	f.FileByte = file
-	f.Content = []byte(dto.Content)
+	f.Content = content
+	f.Range = dto.Range
+	f.ContentType = dto.ContentType
+	f.Encoding = dto.Encoding
 
 	return nil
 }
 
 func NewFilesystem(root string) *Filesystem {
-	return &Filesystem{Root: root, WorkingDir: root}
+	return &Filesystem{Root: root, WorkingDir: root, mounts: DefaultMounts()}
 }
 
+// NewFilesystemWithWorkingDir creates a filesystem resolving relative paths against
+// workingDir, or against the default working directory of the sandbox when it is empty
 func NewFilesystemWithWorkingDir(root string, workingDir string) *Filesystem {
-	return &Filesystem{Root: root, WorkingDir: workingDir}
+	return &Filesystem{Root: root, WorkingDir: workingDir, mounts: DefaultMounts()}
+}
+
+// GetWorkingDir returns the directory relative paths resolve against
+func (fs *Filesystem) GetWorkingDir() string {
+	if fs.WorkingDir != "" {
+		return fs.WorkingDir
+	}
+	return lib.WorkingDir()
 }
 
 // ResolveDisplayPath converts "." to the actual working directory for display purposes
 func (fs *Filesystem) ResolveDisplayPath(path string) string {
 	if path == "." || path == "./" {
-		return fs.WorkingDir
+		return fs.GetWorkingDir()
 	}
 	return path
 }
 
-// GetAbsolutePath gets the absolute path, ensuring it's within the root
+// GetAbsolutePath gets the absolute path, ensuring it's within the root. The operations
+// resolving their paths with it work on local files, so paths served by a mounted
+// backend are rejected with ErrMountedPath; the operations going through the backend
+// resolve them with ResolvePath.
 func (fs *Filesystem) GetAbsolutePath(path string) (string, error) {
+	absPath, err := fs.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if _, _, ok := fs.mounted(absPath); ok {
+		return "", fmt.Errorf("%w: %s", ErrMountedPath, absPath)
+	}
+	return absPath, nil
+}
+
+// ResolvePath gets the absolute path like GetAbsolutePath, including the paths served
+// by a mounted backend
+func (fs *Filesystem) ResolvePath(path string) (string, error) {
 	var absPath string
 
 	// If path is absolute (starts with /), use it directly
@@ -200,7 +271,7 @@ func (fs *Filesystem) GetAbsolutePath(path string) (string, error) {
 		absPath = path
 	} else {
 		// If path is relative, resolve it from the working directory
-		absPath = filepath.Join(fs.WorkingDir, path)
+		absPath = filepath.Join(fs.GetWorkingDir(), path)
 	}
 
 	// Clean the path to resolve . and .. references
@@ -212,7 +283,7 @@ func (fs *Filesystem) GetAbsolutePath(path string) (string, error) {
 	if !filepath.IsAbs(path) {
 		// Verify the path is within the root to prevent path traversal for relative paths
 		if relPath, err := filepath.Rel(fs.Root, absPath); err != nil || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
-			return "", errors.New("path is outside of the root directory")
+			return "", ErrOutsideRoot
 		}
 	}
 
@@ -221,12 +292,12 @@ func (fs *Filesystem) GetAbsolutePath(path string) (string, error) {
 
 // FileExists checks if a file exists at the given path
 func (fs *Filesystem) FileExists(path string) (bool, error) {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return false, err
 	}
 
-	info, err := os.Stat(absPath)
+	info, err := fs.stat(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
@@ -239,12 +310,12 @@ func (fs *Filesystem) FileExists(path string) (bool, error) {
 
 // DirectoryExists checks if a directory exists at the given path
 func (fs *Filesystem) DirectoryExists(path string) (bool, error) {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return false, err
 	}
 
-	info, err := os.Stat(absPath)
+	info, err := fs.stat(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
@@ -257,29 +328,94 @@ func (fs *Filesystem) DirectoryExists(path string) (bool, error) {
 
 // ReadFile reads a file and returns its contents
 func (fs *Filesystem) ReadFile(path string) (*FileWithContentByte, error) {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get file information
-	info, err := os.Stat(absPath)
+	info, err := fs.stat(absPath)
 	if err != nil {
 		return nil, err
 	}
 
 	if info.IsDir() {
-		return nil, errors.New("path points to a directory, not a file")
+		return nil, ErrIsDirectory
 	}
 
 	// Read content
-	content, err := os.ReadFile(absPath)
+	content, err := fs.readFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fs.newFileWithContent(path, absPath, info, content)
+	if err != nil {
+		return nil, err
+	}
+	result.ContentType = DetectContentType(absPath, content)
+	return result, nil
+}
+
+// ReadFileRange reads length bytes of a file from offset. A negative offset is relative
+// to the end of the file and a length of 0 or less reads until the end. The returned
+// size is the size of the whole file.
+func (fs *Filesystem) ReadFileRange(path string, offset int64, length int64) (*FileWithContentByte, error) {
+	absPath, err := fs.ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mounted files are read whole, backends don't read ranges
+	file, info, err := fs.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	start := offset
+	if start < 0 {
+		start = max(info.Size()+offset, 0)
+	}
+	start = min(start, info.Size())
+	end := info.Size()
+	if length > 0 {
+		end = min(start+length, end)
+	}
+
+	content := make([]byte, end-start)
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(file, content); err != nil {
+		return nil, err
+	}
+
+	result, err := fs.newFileWithContent(path, absPath, info, content)
 	if err != nil {
 		return nil, err
 	}
+	result.Range = &FileRange{Offset: start, Length: end - start}
 
+	// The type is detected from the beginning of the file, whatever the range read
+	head := content
+	if start != 0 {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		head = make([]byte, min(info.Size(), sniffLen))
+		if _, err := io.ReadFull(file, head); err != nil {
+			return nil, err
+		}
+	}
+	result.ContentType = DetectContentType(absPath, head)
+	return result, nil
+}
+
+// newFileWithContent builds the file returned by the read functions
+func (fs *Filesystem) newFileWithContent(path string, absPath string, info os.FileInfo, content []byte) (*FileWithContentByte, error) {
 	// Get owner and group
-	owner, group, err := fs.getFileOwnerAndGroup(absPath)
+	owner, group, err := fs.ownerAndGroup(absPath, info)
 	if err != nil {
 		return nil, err
 	}
@@ -301,10 +437,13 @@ func (fs *Filesystem) ReadFile(path string) (*FileWithContentByte, error) {
 
 // WriteFile writes content to a file
 func (fs *Filesystem) WriteFile(path string, content []byte, perm os.FileMode) error {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return err
 	}
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.WriteFile(name, bytes.NewReader(content), perm, false)
+	}
 
 	// Ensure parent directory exists
 	dir := filepath.Dir(absPath)
@@ -317,10 +456,13 @@ func (fs *Filesystem) WriteFile(path string, content []byte, perm os.FileMode) e
 
 // WriteFileFromReader streams content from a reader to a file on disk
 func (fs *Filesystem) WriteFileFromReader(path string, r io.Reader, perm os.FileMode) error {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return err
 	}
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.WriteFile(name, r, perm, false)
+	}
 
 	// Ensure parent directory exists
 	dir := filepath.Dir(absPath)
@@ -343,19 +485,60 @@ func (fs *Filesystem) WriteFileFromReader(path string, r io.Reader, perm os.File
 	return nil
 }
 
+// AppendFileFromReader streams content from a reader to the end of a file, creating it
+// if needed. The file is locked for the duration of the append so that concurrent
+// appends don't interleave.
+func (fs *Filesystem) AppendFileFromReader(path string, r io.Reader, perm os.FileMode) error {
+	absPath, err := fs.ResolvePath(path)
+	if err != nil {
+		return err
+	}
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.WriteFile(name, r, perm, true)
+	}
+
+	// Ensure parent directory exists
+	dir := filepath.Dir(absPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(absPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer func() { _ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN) }()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// AppendFile appends content to the end of a file, creating it if needed
+func (fs *Filesystem) AppendFile(path string, content []byte, perm os.FileMode) error {
+	return fs.AppendFileFromReader(path, bytes.NewReader(content), perm)
+}
+
 // CreateDirectory creates a directory at the given path
 func (fs *Filesystem) CreateDirectory(path string, perm os.FileMode) error {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return err
 	}
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.MkdirAll(name, perm)
+	}
 
 	return os.MkdirAll(absPath, perm)
 }
 
 // ListDirectory lists files and directories in the given path
 func (fs *Filesystem) ListDirectory(path string) (*Directory, error) {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -364,75 +547,103 @@ func (fs *Filesystem) ListDirectory(path string) (*Directory, error) {
 	displayPath := fs.ResolveDisplayPath(path)
 	dir := NewDirectory(displayPath)
 
-	entries, err := os.ReadDir(absPath)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
-		// Use displayPath for the entry paths too
-		entryPath := filepath.Join(displayPath, entry.Name())
-		absEntryPath := filepath.Join(absPath, entry.Name())
-
-		// Use os.Lstat to get info about the symlink itself, not its target
-		// This prevents errors when symlinks point to non-existent targets
-		info, err := os.Lstat(absEntryPath)
+	var infos []os.FileInfo
+	if backend, name, ok := fs.mounted(absPath); ok {
+		infos, err = backend.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		entries, err := os.ReadDir(absPath)
 		if err != nil {
 			return nil, err
 		}
+		for _, entry := range entries {
+			// Use os.Lstat to get info about the symlink itself, not its target
+			// This prevents errors when symlinks point to non-existent targets
+			info, err := os.Lstat(filepath.Join(absPath, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	listed := map[string]bool{}
+	for _, info := range infos {
+		listed[info.Name()] = true
+		// Use displayPath for the entry paths too
+		entryPath := filepath.Join(displayPath, info.Name())
+		absEntryPath := filepath.Join(absPath, info.Name())
 
 		if info.IsDir() {
-			dir.AddSubdirectory(&Subdirectory{Path: entryPath, Name: entry.Name()})
+			dir.AddSubdirectory(&Subdirectory{Path: entryPath, Name: info.Name()})
 		} else {
 			// It's a file or symlink
-			owner, group, err := fs.getFileOwnerAndGroup(absEntryPath)
+			owner, group, err := fs.ownerAndGroup(absEntryPath, info)
 			if err != nil {
 				return nil, err
 			}
 
-			file := &File{Path: entryPath, Name: entry.Name(), Permissions: fmt.Sprintf("%o", info.Mode()), Size: info.Size(), LastModified: info.ModTime(), Owner: owner, Group: group}
+			file := &File{Path: entryPath, Name: info.Name(), Permissions: fmt.Sprintf("%o", info.Mode()), Size: info.Size(), LastModified: info.ModTime(), Owner: owner, Group: group}
 			dir.AddFile(file)
 		}
 	}
 
+	// Mount points are listed even when there is no local directory to mount them on
+	for _, name := range fs.mountPointsIn(absPath) {
+		if !listed[name] {
+			dir.AddSubdirectory(&Subdirectory{Path: filepath.Join(displayPath, name), Name: name})
+		}
+	}
+
 	return dir, nil
 }
 
 // DeleteFile deletes a file at the given path
 func (fs *Filesystem) DeleteFile(path string) error {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return err
 	}
 
-	fileInfo, err := os.Stat(absPath)
+	fileInfo, err := fs.stat(absPath)
 	if err != nil {
 		return err
 	}
 
 	if fileInfo.IsDir() {
-		return errors.New("path points to a directory, not a file")
+		return ErrIsDirectory
 	}
 
+	if backend, name, ok := fs.mounted(absPath); ok {
+		return backend.Remove(name, false)
+	}
 	return os.Remove(absPath)
 }
 
 // DeleteDirectory deletes a directory at the given path
 func (fs *Filesystem) DeleteDirectory(path string, recursive bool) error {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return err
 	}
 
-	fileInfo, err := os.Stat(absPath)
+	fileInfo, err := fs.stat(absPath)
 	if err != nil {
 		return err
 	}
 
 	if !fileInfo.IsDir() {
-		return errors.New("path points to a file, not a directory")
+		return ErrNotDirectory
 	}
 
+	if backend, name, ok := fs.mounted(absPath); ok {
+		if name == "/" {
+			return mountPathError("delete", absPath)
+		}
+		return backend.Remove(name, recursive)
+	}
 	if recursive {
 		return os.RemoveAll(absPath)
 	}
@@ -502,48 +713,31 @@ func (fs *Filesystem) getFileOwnerAndGroup(path string) (string, string, error)
 		return "", "", err
 	}
 
-	stat, ok := info.Sys().(*syscall.Stat_t)
-	if !ok {
+	if _, ok := info.Sys().(*syscall.Stat_t); !ok {
 		return "", "", errors.New("failed to get file stat")
 	}
 
-	uid := stat.Uid
-	gid := stat.Gid
-
-	// Try to get username from UID
-	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
-	ownerName := strconv.FormatUint(uint64(uid), 10)
-	if err == nil {
-		ownerName = u.Username
-	}
-
-	// Try to get group name from GID
-	g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10))
-	groupName := strconv.FormatUint(uint64(gid), 10)
-	if err == nil {
-		groupName = g.Name
-	}
-
+	ownerName, groupName := statOwnerAndGroup(info)
 	return ownerName, groupName, nil
 }
 
 // GetFileInfo returns file information without reading its content
 func (fs *Filesystem) GetFileInfo(path string) (*FileByte, error) {
-	absPath, err := fs.GetAbsolutePath(path)
+	absPath, err := fs.ResolvePath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	info, err := os.Stat(absPath)
+	info, err := fs.stat(absPath)
 	if err != nil {
 		return nil, err
 	}
 
 	if info.IsDir() {
-		return nil, errors.New("path points to a directory, not a file")
+		return nil, ErrIsDirectory
 	}
 
-	owner, group, err := fs.getFileOwnerAndGroup(absPath)
+	owner, group, err := fs.ownerAndGroup(absPath, info)
 	if err != nil {
 		return nil, err
 	}
@@ -597,17 +791,6 @@ func (fs *Filesystem) CreateOrUpdateFile(path string, content string, isDirector
 		return fs.CreateDirectory(path, perm)
 	}
 
-	// Get absolute path for directory creation
-	absPath, err := fs.GetAbsolutePath(path)
-	if err != nil {
-		return err
-	}
-
-	// Ensure parent directory exists
-	dir := filepath.Dir(absPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
+	// WriteFile creates the parent directories
 	return fs.WriteFile(path, []byte(content), perm)
 }
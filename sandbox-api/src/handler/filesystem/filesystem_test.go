@@ -3,6 +3,9 @@ package filesystem
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -347,3 +350,84 @@ func TestErrorHandling(t *testing.T) {
 		t.Errorf("Expected error when getting file info for directory, got none")
 	}
 }
+
+// TestReadFileRange tests partial reads from the start, middle and end of a file
+func TestReadFileRange(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("app.log", []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		offset, length int64
+		content        string
+		start          int64
+	}{
+		{offset: 2, length: 3, content: "234", start: 2},
+		{offset: 7, length: 0, content: "789", start: 7},
+		{offset: -4, length: 2, content: "67", start: 6},
+		{offset: -20, length: 3, content: "012", start: 0},
+		{offset: 15, length: 3, content: "", start: 10},
+	}
+	for _, test := range tests {
+		file, err := fs.ReadFileRange("app.log", test.offset, test.length)
+		if err != nil {
+			t.Fatalf("Failed to read range %d+%d: %v", test.offset, test.length, err)
+		}
+		if string(file.Content) != test.content || file.Range.Offset != test.start {
+			t.Errorf("Range %d+%d: expected %q at %d, got %q at %d", test.offset, test.length, test.content, test.start, file.Content, file.Range.Offset)
+		}
+		if file.Size != 10 || file.Range.Length != int64(len(test.content)) {
+			t.Errorf("Range %d+%d: unexpected size %d or length %d", test.offset, test.length, file.Size, file.Range.Length)
+		}
+	}
+}
+
+// TestAppendFile tests appending to new and existing files, including concurrent appends
+func TestAppendFile(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.AppendFile("logs/app.log", []byte("first\n"), 0600); err != nil {
+		t.Fatalf("Failed to append to a new file: %v", err)
+	}
+	if err := fs.AppendFile("logs/app.log", []byte("second\n"), 0644); err != nil {
+		t.Fatalf("Failed to append to an existing file: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "logs", "app.log"))
+	if err != nil || string(content) != "first\nsecond\n" {
+		t.Errorf("Expected both appends in order, got %q (%v)", content, err)
+	}
+	if info, _ := os.Stat(filepath.Join(tempDir, "logs", "app.log")); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the mode of the created file to be kept, got %v", info.Mode().Perm())
+	}
+
+	// Large concurrent appends must not interleave
+	chunk := strings.Repeat("x", 256*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = fs.AppendFile("logs/big.log", []byte(strconv.Itoa(i)+chunk+"\n"), 0644)
+		}(i)
+	}
+	wg.Wait()
+
+	content, err = os.ReadFile(filepath.Join(tempDir, "logs", "big.log"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) != len(chunk)+1 || strings.Trim(line[1:], "x") != "" {
+			t.Errorf("Expected appends not to interleave, got a line of %d bytes", len(line))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
+)
+
+// Content included in the events of a watched file
+const (
+	// WatchContentFull includes the content of the file after the event
+	WatchContentFull = "full"
+	// WatchContentDiff includes the unified diff of the content since the previous event
+	WatchContentDiff = "diff"
+)
+
+// MaxWatchContentSize is the size above which the content of a watched file is not
+// included in its events
+const MaxWatchContentSize = 1 << 20
+
+// ValidateWatchContent checks the content mode of a file watch, empty for none
+func ValidateWatchContent(mode string) error {
+	switch mode {
+	case "", WatchContentFull, WatchContentDiff:
+		return nil
+	}
+	return apierror.Newf(apierror.CodeInvalidRequest, "content must be %s or %s", WatchContentFull, WatchContentDiff)
+}
+
+// WatchFile watches a single file for changes. Its parent directory is watched, so that
+// the file being replaced by a rename, as editors save files, or deleted and created
+// again is reported too. The callback is called with the events of the file only, the
+// watch stops when the parent directory is removed.
+func (fs *Filesystem) WatchFile(path string, callback func(event fsnotify.Event)) (func(), error) {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(absPath)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	watchesUsed.Add(1)
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Name == absPath {
+					callback(event)
+				}
+				if (event.Op&fsnotify.Remove != 0 || event.Op&fsnotify.Rename != 0) && event.Name == dir {
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logrus.Error("error:", err)
+			}
+		}
+	}()
+
+	return func() {
+		_ = watcher.Close()
+		watchesUsed.Add(-1)
+	}, nil
+}
+
+// ContentDelta is the content of a watched file after an event, see ContentTracker
+type ContentDelta struct {
+	// Content is the content of the file, nil when it no longer exists
+	Content *string
+	// Diff is the unified diff of the content since the previous event, empty when it
+	// did not change
+	Diff *string
+	// Omitted is true when the file is binary or larger than MaxWatchContentSize, without
+	// Content nor Diff then
+	Omitted bool
+}
+
+// ContentTracker keeps the last content of a watched file to compute the content
+// delta of its events. It is safe for concurrent use.
+type ContentTracker struct {
+	fs      *Filesystem
+	absPath string
+	mode    string
+
+	mu sync.Mutex
+	// last is the content of the file at the previous event, nil when it did not
+	// exist or could not be included
+	last []byte
+}
+
+// NewContentTracker returns a tracker of the content of the file at path, reading its
+// current content as the base of the first diff. mode is WatchContentFull or
+// WatchContentDiff.
+func (fs *Filesystem) NewContentTracker(path string, mode string) (*ContentTracker, error) {
+	if err := ValidateWatchContent(mode); err != nil {
+		return nil, err
+	}
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &ContentTracker{fs: fs, absPath: absPath, mode: mode}
+	t.last, _, _ = t.read()
+	return t, nil
+}
+
+// Next reads the content of the file after an event and returns its delta from the
+// content at the previous event
+func (t *ContentTracker) Next() ContentDelta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	content, exists, omitted := t.read()
+	previous := t.last
+	t.last = content
+	if omitted {
+		return ContentDelta{Omitted: true}
+	}
+
+	var delta ContentDelta
+	if exists {
+		text := string(content)
+		delta.Content = &text
+	}
+	if t.mode != WatchContentDiff {
+		return delta
+	}
+	delta.Content = nil
+
+	fromLabel, toLabel := "a"+t.absPath, "b"+t.absPath
+	if previous == nil {
+		fromLabel = devNull
+	}
+	if !exists {
+		toLabel = devNull
+	}
+	diff := ""
+	if !bytes.Equal(previous, content) {
+		diff, _ = codegen.Unified(fromLabel, toLabel, string(previous), string(content), DefaultDiffContext)
+	}
+	delta.Diff = &diff
+	return delta
+}
+
+// read returns the content of the file, whether it exists, and whether it is too large
+// or binary to be included
+func (t *ContentTracker) read() ([]byte, bool, bool) {
+	info, err := t.fs.stat(t.absPath)
+	if err != nil || info.IsDir() {
+		return nil, false, false
+	}
+	if info.Size() > MaxWatchContentSize {
+		return nil, true, true
+	}
+	content, err := t.fs.readFile(t.absPath)
+	if os.IsNotExist(err) {
+		return nil, false, false
+	}
+	if err != nil || len(content) > MaxWatchContentSize || looksBinary(content) {
+		return nil, true, true
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return content, true, false
+}
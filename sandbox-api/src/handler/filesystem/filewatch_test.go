@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestWatchFile tests that only the events of the watched file are reported, including
+// its replacement by a rename
+func TestWatchFile(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "main.go")
+	if err := os.WriteFile(target, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan fsnotify.Event, 100)
+	stop, err := NewFilesystem(root).WatchFile("main.go", func(event fsnotify.Event) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer stop()
+
+	if err := os.WriteFile(filepath.Join(root, "other.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".main.go.swp"), []byte("package app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, ".main.go.swp"), target); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Name != target || !event.Has(fsnotify.Create) {
+			t.Errorf("Expected the CREATE of the watched file, got %v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an event of the replaced file")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestContentTracker tests the full content and diffs of the events of a watched file
+func TestContentTracker(t *testing.T) {
+	root := t.TempDir()
+	fs := NewFilesystem(root)
+	target := filepath.Join(root, "main.go")
+	write := func(content string) {
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package app\n\nfunc main() {}\n")
+
+	full, err := fs.NewContentTracker("main.go", WatchContentFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := fs.NewContentTracker("main.go", WatchContentDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("package main\n\nfunc main() {}\n")
+	if delta := full.Next(); delta.Content == nil || *delta.Content != "package main\n\nfunc main() {}\n" || delta.Diff != nil {
+		t.Errorf("Unexpected full delta %+v", delta)
+	}
+	delta := diffs.Next()
+	if delta.Content != nil || delta.Diff == nil || !strings.Contains(*delta.Diff, "-package app\n+package main\n") {
+		t.Fatalf("Unexpected diff delta %+v", delta)
+	}
+	if !strings.HasPrefix(*delta.Diff, "--- a"+target+"\n+++ b"+target+"\n") {
+		t.Errorf("Unexpected diff labels:\n%s", *delta.Diff)
+	}
+	if delta := diffs.Next(); delta.Diff == nil || *delta.Diff != "" {
+		t.Errorf("Expected an empty diff for unchanged content, got %+v", delta)
+	}
+
+	if err := os.Remove(target); err != nil {
+		t.Fatal(err)
+	}
+	if delta := full.Next(); delta.Content != nil || delta.Omitted {
+		t.Errorf("Expected no content for a deleted file, got %+v", delta)
+	}
+	if delta := diffs.Next(); delta.Diff == nil || !strings.Contains(*delta.Diff, "+++ /dev/null\n") {
+		t.Errorf("Expected the diff of a deleted file, got %+v", delta)
+	}
+
+	write("binary\x00content")
+	if delta := diffs.Next(); !delta.Omitted || delta.Diff != nil {
+		t.Errorf("Expected the content of a binary file to be omitted, got %+v", delta)
+	}
+
+	if _, err := fs.NewContentTracker("main.go", "patch"); err == nil {
+		t.Error("Expected an invalid content mode to be rejected")
+	}
+}
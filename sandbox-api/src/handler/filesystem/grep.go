@@ -0,0 +1,306 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultGrepMaxResults is the number of matches returned when no limit is given
+const DefaultGrepMaxResults = 1000
+
+// maxGrepFileSize is the size above which files are skipped by the regexp engine
+const maxGrepFileSize = 10 * 1024 * 1024
+
+// ripgrepBinary is the ripgrep executable used when it is installed, the builtin
+// regexp engine is used otherwise
+var ripgrepBinary = "rg"
+
+// GrepOptions are the options of a content grep
+type GrepOptions struct {
+	Pattern       string
+	CaseSensitive bool
+	Include       []string
+	Exclude       []string
+	MaxResults    int
+	ContextLines  int
+}
+
+// GrepSubmatch is the position of a match in a line, as byte offsets
+type GrepSubmatch struct {
+	Start int    `json:"start" example:"5" binding:"required"`
+	End   int    `json:"end" example:"18" binding:"required"`
+	Text  string `json:"text" example:"HandleRequest" binding:"required"`
+} // @name GrepSubmatch
+
+// GrepMatch is a line matching the pattern, with its surrounding context lines
+type GrepMatch struct {
+	Path          string         `json:"path" example:"/home/user/app/main.go" binding:"required"`
+	LineNumber    int            `json:"lineNumber" example:"12" binding:"required"`
+	Line          string         `json:"line" example:"func HandleRequest() {" binding:"required"`
+	Submatches    []GrepSubmatch `json:"submatches" binding:"required"`
+	ContextBefore []string       `json:"contextBefore,omitempty"`
+	ContextAfter  []string       `json:"contextAfter,omitempty"`
+} // @name GrepMatch
+
+// GrepResult is the result of a content grep
+type GrepResult struct {
+	Matches   []GrepMatch `json:"matches" binding:"required"`
+	Truncated bool        `json:"truncated" example:"false"`
+	Engine    string      `json:"engine" example:"ripgrep" enums:"ripgrep,regexp" binding:"required"`
+} // @name GrepResult
+
+// Grep searches the content of the files under root for a regular expression. It uses
+// ripgrep when installed and falls back to the Go regexp engine otherwise. Binary files
+// are skipped and hidden or ignored files are searched unless excluded.
+func (fs *Filesystem) Grep(root string, opts GrepOptions) (*GrepResult, error) {
+	if opts.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	if opts.ContextLines < 0 {
+		return nil, fmt.Errorf("invalid context lines: must be a positive number")
+	}
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultGrepMaxResults
+	}
+
+	absRoot, err := fs.GetAbsolutePath(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	if ripgrepBinary != "" {
+		if rg, err := exec.LookPath(ripgrepBinary); err == nil {
+			return grepWithRipgrep(rg, absRoot, opts)
+		}
+	}
+	return fs.grepWithRegexp(absRoot, opts)
+}
+
+// grepWithRegexp greps the files under root with the Go regexp engine
+func (fs *Filesystem) grepWithRegexp(root string, opts GrepOptions) (*GrepResult, error) {
+	pattern := opts.Pattern
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	result := &GrepResult{Matches: []GrepMatch{}, Engine: "regexp"}
+	err = fs.walkFiles(root, opts.Include, opts.Exclude, func(path string) error {
+		grepFile(path, re, opts, result)
+		if result.Truncated {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// grepFile appends the matches of re in the file at path to result
+func grepFile(path string, re *regexp.Regexp, opts GrepOptions, result *GrepResult) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err != nil || info.Size() > maxGrepFileSize {
+		return
+	}
+	reader := bufio.NewReader(file)
+	if isBinary(reader) {
+		return
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxGrepFileSize)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	for i, line := range lines {
+		locations := re.FindAllStringIndex(line, -1)
+		if len(locations) == 0 {
+			continue
+		}
+		if len(result.Matches) >= opts.MaxResults {
+			result.Truncated = true
+			return
+		}
+
+		match := GrepMatch{
+			Path:       path,
+			LineNumber: i + 1,
+			Line:       line,
+			Submatches: make([]GrepSubmatch, 0, len(locations)),
+		}
+		for _, location := range locations {
+			match.Submatches = append(match.Submatches, GrepSubmatch{
+				Start: location[0],
+				End:   location[1],
+				Text:  line[location[0]:location[1]],
+			})
+		}
+		if opts.ContextLines > 0 {
+			match.ContextBefore = lines[max(0, i-opts.ContextLines):i]
+			match.ContextAfter = lines[i+1 : min(len(lines), i+1+opts.ContextLines)]
+		}
+		result.Matches = append(result.Matches, match)
+	}
+}
+
+// grepWithRipgrep greps the files under root with the ripgrep executable at rg
+func grepWithRipgrep(rg string, root string, opts GrepOptions) (*GrepResult, error) {
+	args := []string{"--json", "--hidden", "--no-ignore"}
+	if !opts.CaseSensitive {
+		args = append(args, "--ignore-case")
+	}
+	if opts.ContextLines > 0 {
+		args = append(args, "--context", strconv.Itoa(opts.ContextLines))
+	}
+	for _, include := range opts.Include {
+		args = append(args, "--glob", include)
+	}
+	for _, exclude := range opts.Exclude {
+		args = append(args, "--glob", "!"+exclude)
+	}
+	args = append(args, "--regexp", opts.Pattern, "--", root)
+
+	cmd := exec.Command(rg, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ripgrep: %w", err)
+	}
+
+	result := parseRipgrepOutput(stdout, opts)
+	if result.Truncated {
+		// Stop searching once enough matches were found
+		_ = cmd.Process.Kill()
+	}
+	err = cmd.Wait()
+
+	// ripgrep exits with 1 when nothing matched and 2 on errors, which may be partial
+	// such as unreadable files
+	if exitErr, ok := err.(*exec.ExitError); ok && !result.Truncated {
+		if exitErr.ExitCode() == 2 && len(result.Matches) == 0 {
+			return nil, fmt.Errorf("ripgrep failed: %s", strings.TrimSpace(stderr.String()))
+		}
+	} else if err != nil && !result.Truncated {
+		return nil, fmt.Errorf("ripgrep failed: %w", err)
+	}
+	return result, nil
+}
+
+// ripgrepMessage is a line of the ripgrep --json output
+type ripgrepMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Path struct {
+			Text string `json:"text"`
+		} `json:"path"`
+		Lines struct {
+			Text string `json:"text"`
+		} `json:"lines"`
+		LineNumber int `json:"line_number"`
+		Submatches []struct {
+			Match struct {
+				Text string `json:"text"`
+			} `json:"match"`
+			Start int `json:"start"`
+			End   int `json:"end"`
+		} `json:"submatches"`
+	} `json:"data"`
+}
+
+// ripgrepContextLine is a context line waiting to be attached to the next match
+type ripgrepContextLine struct {
+	lineNumber int
+	text       string
+}
+
+// parseRipgrepOutput reads ripgrep --json messages until the output ends or
+// opts.MaxResults matches were read. Context lines are attached to the matches
+// they are within opts.ContextLines lines of.
+func parseRipgrepOutput(r io.Reader, opts GrepOptions) *GrepResult {
+	result := &GrepResult{Matches: []GrepMatch{}, Engine: "ripgrep"}
+	var pending []ripgrepContextLine
+	var last *GrepMatch
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxGrepFileSize)
+	for scanner.Scan() {
+		var message ripgrepMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		data := message.Data
+		text := strings.TrimRight(data.Lines.Text, "\r\n")
+
+		switch message.Type {
+		case "begin":
+			pending = nil
+			last = nil
+		case "context":
+			if last != nil && data.LineNumber-last.LineNumber <= opts.ContextLines {
+				last.ContextAfter = append(last.ContextAfter, text)
+			}
+			pending = append(pending, ripgrepContextLine{lineNumber: data.LineNumber, text: text})
+		case "match":
+			if len(result.Matches) >= opts.MaxResults {
+				result.Truncated = true
+				return result
+			}
+
+			match := GrepMatch{
+				Path:       data.Path.Text,
+				LineNumber: data.LineNumber,
+				Line:       text,
+				Submatches: make([]GrepSubmatch, 0, len(data.Submatches)),
+			}
+			for _, submatch := range data.Submatches {
+				match.Submatches = append(match.Submatches, GrepSubmatch{
+					Start: submatch.Start,
+					End:   submatch.End,
+					Text:  submatch.Match.Text,
+				})
+			}
+			for _, line := range pending {
+				if match.LineNumber-line.lineNumber <= opts.ContextLines {
+					match.ContextBefore = append(match.ContextBefore, line.text)
+				}
+			}
+			pending = nil
+
+			result.Matches = append(result.Matches, match)
+			last = &result.Matches[len(result.Matches)-1]
+		}
+	}
+	return result
+}
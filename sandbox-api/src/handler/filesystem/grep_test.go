@@ -0,0 +1,117 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGrep tests the regexp engine with submatches, context lines and limits
+func TestGrep(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Force the builtin engine so the results don't depend on ripgrep being installed
+	defer func(binary string) { ripgrepBinary = binary }(ripgrepBinary)
+	ripgrepBinary = ""
+
+	files := map[string]string{
+		"project/main.go":       "package main\n\nfunc main() {\n\tuserHandler()\n\tadminHandler()\n}\n",
+		"project/handlers.go":   "package main\n\nfunc userHandler() {}\nfunc adminHandler() {}\n",
+		"project/vendor/x.go":   "func vendorHandler() {}\n",
+		"project/notes/todo.md": "Rename userHandler\n",
+	}
+	for path, content := range files {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	t.Run("Submatches", func(t *testing.T) {
+		result, err := fs.Grep("project", GrepOptions{
+			Pattern:       `func (\w+)Handler`,
+			CaseSensitive: true,
+			Include:       []string{"*.go"},
+			Exclude:       []string{"vendor"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to grep: %v", err)
+		}
+		if result.Engine != "regexp" || len(result.Matches) != 2 {
+			t.Fatalf("Expected 2 matches from the regexp engine, got %+v", result)
+		}
+		match := result.Matches[0]
+		if filepath.Base(match.Path) != "handlers.go" || match.LineNumber != 3 {
+			t.Errorf("Unexpected match: %+v", match)
+		}
+		if len(match.Submatches) != 1 || match.Submatches[0].Start != 0 || match.Submatches[0].Text != "func userHandler" {
+			t.Errorf("Unexpected submatches: %+v", match.Submatches)
+		}
+	})
+
+	t.Run("ContextAndCase", func(t *testing.T) {
+		result, err := fs.Grep("project", GrepOptions{
+			Pattern:      "USERHANDLER",
+			Include:      []string{"main.go"},
+			ContextLines: 1,
+		})
+		if err != nil {
+			t.Fatalf("Failed to grep: %v", err)
+		}
+		if len(result.Matches) != 1 {
+			t.Fatalf("Expected 1 case insensitive match, got %+v", result.Matches)
+		}
+		match := result.Matches[0]
+		if strings.Join(match.ContextBefore, "|") != "func main() {" || strings.Join(match.ContextAfter, "|") != "\tadminHandler()" {
+			t.Errorf("Unexpected context: %q %q", match.ContextBefore, match.ContextAfter)
+		}
+		if match.Submatches[0].Start != 1 || match.Submatches[0].End != 12 {
+			t.Errorf("Unexpected submatch offsets: %+v", match.Submatches[0])
+		}
+	})
+
+	t.Run("MaxResults", func(t *testing.T) {
+		result, err := fs.Grep("project", GrepOptions{Pattern: "Handler", MaxResults: 2})
+		if err != nil {
+			t.Fatalf("Failed to grep: %v", err)
+		}
+		if len(result.Matches) != 2 || !result.Truncated {
+			t.Errorf("Expected 2 truncated matches, got %+v", result)
+		}
+	})
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		if _, err := fs.Grep("project", GrepOptions{Pattern: "("}); err == nil {
+			t.Error("Expected error for an invalid pattern, but got none")
+		}
+	})
+}
+
+// TestParseRipgrepOutput tests the conversion of ripgrep --json messages
+func TestParseRipgrepOutput(t *testing.T) {
+	output := strings.Join([]string{
+		`{"type":"begin","data":{"path":{"text":"/app/main.go"}}}`,
+		`{"type":"context","data":{"path":{"text":"/app/main.go"},"lines":{"text":"func main() {\n"},"line_number":3,"submatches":[]}}`,
+		`{"type":"match","data":{"path":{"text":"/app/main.go"},"lines":{"text":"\tuserHandler()\n"},"line_number":4,"submatches":[{"match":{"text":"userHandler"},"start":1,"end":12}]}}`,
+		`{"type":"context","data":{"path":{"text":"/app/main.go"},"lines":{"text":"}\n"},"line_number":5,"submatches":[]}}`,
+		`{"type":"end","data":{"path":{"text":"/app/main.go"}}}`,
+		`{"type":"begin","data":{"path":{"text":"/app/other.go"}}}`,
+		`{"type":"match","data":{"path":{"text":"/app/other.go"},"lines":{"text":"userHandler\n"},"line_number":1,"submatches":[{"match":{"text":"userHandler"},"start":0,"end":11}]}}`,
+		`{"type":"summary","data":{}}`,
+	}, "\n")
+
+	result := parseRipgrepOutput(strings.NewReader(output), GrepOptions{ContextLines: 1, MaxResults: 1})
+	if len(result.Matches) != 1 || !result.Truncated {
+		t.Fatalf("Expected a single truncated match, got %+v", result)
+	}
+	match := result.Matches[0]
+	if match.Path != "/app/main.go" || match.LineNumber != 4 || match.Line != "\tuserHandler()" {
+		t.Errorf("Unexpected match: %+v", match)
+	}
+	if len(match.ContextBefore) != 1 || len(match.ContextAfter) != 1 || match.ContextAfter[0] != "}" {
+		t.Errorf("Unexpected context: %q %q", match.ContextBefore, match.ContextAfter)
+	}
+	if match.Submatches[0].Start != 1 || match.Submatches[0].End != 12 {
+		t.Errorf("Unexpected submatch offsets: %+v", match.Submatches[0])
+	}
+}
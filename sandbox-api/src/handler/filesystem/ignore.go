@@ -0,0 +1,167 @@
+package filesystem
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a compiled gitignore pattern
+type ignoreRule struct {
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// IgnoreMatcher matches paths against gitignore-style patterns: "*" and "?" don't match
+// "/", "**" matches across directories, a leading "!" re-includes a path, a trailing "/"
+// only matches directories, and patterns containing a "/" are anchored to the root while
+// others match at any level. The last matching pattern wins, and nothing below an ignored
+// directory can be re-included.
+type IgnoreMatcher struct {
+	rules []ignoreRule
+}
+
+// NewIgnoreMatcher compiles gitignore-style patterns. Blank lines and comments are skipped.
+func NewIgnoreMatcher(patterns []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	for _, pattern := range patterns {
+		if rule, ok := compileIgnorePattern(pattern); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m
+}
+
+// LoadGitignore returns the patterns of the .gitignore file at the root of dir, if any
+func LoadGitignore(dir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+// Match reports whether the slash-separated path rel, relative to the root of the patterns,
+// is ignored. isDir tells whether rel itself is a directory.
+func (m *IgnoreMatcher) Match(rel string, isDir bool) bool {
+	if len(m.rules) == 0 {
+		return false
+	}
+	rel = strings.Trim(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+
+	components := strings.Split(rel, "/")
+	for i := 1; i <= len(components); i++ {
+		last := i == len(components)
+		ignored := m.matchPath(strings.Join(components[:i], "/"), !last || isDir)
+		if ignored || last {
+			return ignored
+		}
+	}
+	return false
+}
+
+// matchPath applies the rules to a single path, the last matching rule wins
+func (m *IgnoreMatcher) matchPath(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.regex.MatchString(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// compileIgnorePattern translates a gitignore pattern into a regular expression
+func compileIgnorePattern(pattern string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return rule, false
+	}
+
+	// Patterns with a slash other than a trailing one are relative to the root
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case pattern[i:] == "**" && i > 0 && pattern[i-1] == '/':
+			expr.WriteString(".*")
+			i++
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case ch == '*':
+			expr.WriteString("[^/]*")
+		case ch == '?':
+			expr.WriteString("[^/]")
+		case ch == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	expr.WriteString("$")
+
+	regex, err := regexp.Compile(expr.String())
+	if err != nil {
+		return rule, false
+	}
+	rule.regex = regex
+	return rule, true
+}
@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestIgnoreMatcher tests gitignore-style pattern matching
+func TestIgnoreMatcher(t *testing.T) {
+	matcher := NewIgnoreMatcher([]string{
+		"# comment",
+		"",
+		"node_modules/**",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"docs/**/*.tmp",
+		"cache/",
+		"file?.txt",
+	})
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"node_modules/react/index.js", false, true},
+		{"app/node_modules/lib.js", false, false},
+		{"debug.log", false, true},
+		{"logs/server.log", false, true},
+		{"keep.log", false, false},
+		{"logs/keep.log", false, false},
+		{"build", true, true},
+		{"build/out.js", false, true},
+		{"src/build/out.js", false, false},
+		{"docs/a/b/draft.tmp", false, true},
+		{"docs/draft.tmp", false, true},
+		{"draft.tmp", false, false},
+		{"cache", true, true},
+		{"cache", false, false},
+		{"cache/data.bin", false, true},
+		{"file1.txt", false, true},
+		{"file10.txt", false, false},
+		{"src/main.go", false, false},
+		{"logging.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := matcher.Match(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Match(%q, %v) = %v, expected %v", tt.path, tt.isDir, got, tt.expected)
+		}
+	}
+
+	// Files can't be re-included below an ignored directory
+	matcher = NewIgnoreMatcher([]string{"vendor/", "!vendor/keep.go"})
+	if !matcher.Match("vendor/keep.go", false) {
+		t.Error("Expected file below an ignored directory to stay ignored")
+	}
+}
+
+// TestLoadGitignore tests reading the patterns of a .gitignore file
+func TestLoadGitignore(t *testing.T) {
+	dir := t.TempDir()
+
+	patterns, err := LoadGitignore(dir)
+	if err != nil || patterns != nil {
+		t.Errorf("Expected no patterns without a .gitignore, got %v (%v)", patterns, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("dist/\n*.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+	patterns, err = LoadGitignore(dir)
+	if err != nil {
+		t.Fatalf("Failed to load .gitignore: %v", err)
+	}
+	if !NewIgnoreMatcher(patterns).Match("dist/app.js", false) {
+		t.Errorf("Expected dist/app.js to be ignored with patterns %v", patterns)
+	}
+}
@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// CreateSymlink creates a symbolic link at path pointing to target. The target is
+// stored as given, so relative targets are resolved from the directory of the link.
+// An existing symlink at path is replaced.
+func (fs *Filesystem) CreateSymlink(path string, target string) error {
+	if target == "" {
+		return fmt.Errorf("symlink target is required")
+	}
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+	if err := prepareLinkPath(absPath); err != nil {
+		return err
+	}
+	return os.Symlink(target, absPath)
+}
+
+// CreateHardlink creates a hard link at path to the existing file at target. The target
+// is resolved like any other path of the API.
+func (fs *Filesystem) CreateHardlink(path string, target string) error {
+	if target == "" {
+		return fmt.Errorf("hard link target is required")
+	}
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+	absTarget, err := fs.GetAbsolutePath(target)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(absTarget)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("hard links to directories are not supported")
+	}
+	if err := prepareLinkPath(absPath); err != nil {
+		return err
+	}
+	return os.Link(absTarget, absPath)
+}
+
+// prepareLinkPath creates the parent directories of a link and removes an existing
+// symlink at its path. Any other existing file is an error.
+func prepareLinkPath(absPath string) error {
+	if info, err := os.Lstat(absPath); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("path already exists")
+		}
+		if err := os.Remove(absPath); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(filepath.Dir(absPath), 0755)
+}
+
+// SetPermissions changes the mode and/or the owner and group of the entry at path, and
+// of everything below it if recursive is set. Owner and group are names or numeric ids,
+// empty values are left unchanged.
+func (fs *Filesystem) SetPermissions(path string, mode *os.FileMode, owner string, group string, recursive bool) error {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+
+	uid, gid := -1, -1
+	if owner != "" {
+		if uid, err = lookupUID(owner); err != nil {
+			return err
+		}
+	}
+	if group != "" {
+		if gid, err = lookupGID(group); err != nil {
+			return err
+		}
+	}
+
+	apply := func(p string, info os.FileInfo) error {
+		// Symlinks have no mode of their own, only their ownership can change
+		if mode != nil && info.Mode()&os.ModeSymlink == 0 {
+			if err := os.Chmod(p, *mode); err != nil {
+				return err
+			}
+		}
+		if uid != -1 || gid != -1 {
+			if err := os.Lchown(p, uid, gid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return err
+	}
+	if !recursive || !info.IsDir() {
+		return apply(absPath, info)
+	}
+	return filepath.Walk(absPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return apply(p, info)
+	})
+}
+
+// lookupUID returns the uid of a user name or numeric id
+func lookupUID(owner string) (int, error) {
+	if uid, err := strconv.Atoi(owner); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return -1, fmt.Errorf("unknown owner '%s'", owner)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGID returns the gid of a group name or numeric id
+func lookupGID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return -1, fmt.Errorf("unknown group '%s'", group)
+	}
+	return strconv.Atoi(g.Gid)
+}
@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestLinks tests symlink and hard link creation
+func TestLinks(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("data/config.json", []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	t.Run("Symlink", func(t *testing.T) {
+		if err := fs.CreateSymlink("links/config.json", "../data/config.json"); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(tempDir, "links", "config.json"))
+		if err != nil || string(content) != "{}" {
+			t.Errorf("Expected symlink to resolve to the target content, got %q (%v)", content, err)
+		}
+
+		// An existing symlink is replaced, a regular file is not
+		if err := fs.CreateSymlink("links/config.json", "../data/other.json"); err != nil {
+			t.Errorf("Failed to replace symlink: %v", err)
+		}
+		if err := fs.CreateSymlink("data/config.json", "other.json"); err == nil {
+			t.Error("Expected error when a file exists at the link path, but got none")
+		}
+	})
+
+	t.Run("Hardlink", func(t *testing.T) {
+		if err := fs.CreateHardlink("data/hardlink.json", "data/config.json"); err != nil {
+			t.Fatalf("Failed to create hard link: %v", err)
+		}
+		original, _ := os.Stat(filepath.Join(tempDir, "data", "config.json"))
+		link, _ := os.Stat(filepath.Join(tempDir, "data", "hardlink.json"))
+		if !os.SameFile(original, link) {
+			t.Error("Expected hard link to share the inode of its target")
+		}
+		if err := fs.CreateHardlink("data/dir-link", "data"); err == nil {
+			t.Error("Expected error for a hard link to a directory, but got none")
+		}
+	})
+}
+
+// TestSetPermissions tests mode and ownership changes
+func TestSetPermissions(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("bin/run.sh", []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	mode := os.FileMode(0750)
+	if err := fs.SetPermissions("bin", &mode, "", "", true); err != nil {
+		t.Fatalf("Failed to set permissions: %v", err)
+	}
+	for _, path := range []string{"bin", "bin/run.sh"} {
+		info, err := os.Stat(filepath.Join(tempDir, path))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != 0750 {
+			t.Errorf("Expected %s to have mode 750, got %v", path, info.Mode().Perm())
+		}
+	}
+
+	// Changing to the current owner works without privileges
+	if err := fs.SetPermissions("bin/run.sh", nil, strconv.Itoa(os.Getuid()), "", false); err != nil {
+		t.Errorf("Failed to set owner: %v", err)
+	}
+	if err := fs.SetPermissions("bin/run.sh", nil, "no-such-user-in-sandbox", "", false); err == nil {
+		t.Error("Expected error for an unknown owner, but got none")
+	}
+	if err := fs.SetPermissions("missing", &mode, "", "", false); !os.IsNotExist(err) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package filesystem
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Entry types
+const (
+	EntryTypeFile      = "file"
+	EntryTypeDirectory = "directory"
+)
+
+// Entry is an entry of a flat directory listing, as listed page by page or streamed
+type Entry struct {
+	// Type is file or directory, symlinks being files
+	Type string `json:"type" binding:"required"`
+	File
+	// rel is the path relative to the listed directory
+	rel string
+} // @name DirectoryEntry
+
+// DirectoryPage is a page of a directory listing. NextPageToken is set when there are
+// more entries, to pass as pageToken to list the next page.
+type DirectoryPage struct {
+	Path          string  `json:"path" binding:"required"`
+	Entries       []Entry `json:"entries" binding:"required"`
+	NextPageToken string  `json:"nextPageToken,omitempty"`
+} // @name DirectoryPage
+
+// errWalkStop stops a directory walk once its callback asked to
+var errWalkStop = errors.New("walk stopped")
+
+// WalkDirectory calls fn with the entries of a directory in lexical order of their path,
+// a directory being followed by its content when listing recursively, up to
+// opts.MaxDepth levels. With globs, only the matching files are listed. The walk starts
+// after the entry of the page token after, and stops when fn returns false.
+//
+// Entries are read one directory at a time, so that a large tree is never held in
+// memory. The entries removed while walking are skipped.
+func (fs *Filesystem) WalkDirectory(path string, recursive bool, opts TreeOptions, after string, fn func(entry Entry) bool) error {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+	var afterRel string
+	if after != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil || !filepath.IsLocal(string(decoded)) {
+			return apierror.New(apierror.CodeInvalidRequest, "invalid pageToken")
+		}
+		afterRel = string(decoded)
+	}
+	if !recursive {
+		opts.MaxDepth = 1
+		opts.Globs = nil
+	}
+
+	err = fs.walkEntries(absPath, fs.ResolveDisplayPath(path), "", 1, opts, afterRel, fn)
+	if errors.Is(err, errWalkStop) {
+		return nil
+	}
+	return err
+}
+
+// walkEntries walks the entries of the directory at absPath, rel being its path relative
+// to the walked root and depth its level starting at 1
+func (fs *Filesystem) walkEntries(absPath string, displayPath string, rel string, depth int, opts TreeOptions, after string, fn func(entry Entry) bool) error {
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		// Subdirectories removed while walking are skipped, the walked root must exist
+		if rel != "" && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, dirEntry := range entries {
+		if !opts.IncludeHidden && strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		entryRel := filepath.Join(rel, dirEntry.Name())
+		order := comparePaths(entryRel, after)
+		// The entries of previous pages are skipped, with the content of the directories
+		// before the last entry listed
+		if after != "" && order <= 0 && !(dirEntry.IsDir() && (order == 0 || isWithin(after, entryRel))) {
+			continue
+		}
+
+		absEntryPath := filepath.Join(absPath, dirEntry.Name())
+		entryPath := filepath.Join(displayPath, dirEntry.Name())
+		// Use os.Lstat so symlinks are listed as files and never followed
+		info, err := os.Lstat(absEntryPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		listed := after == "" || order > 0
+		if info.IsDir() {
+			if listed && len(opts.Globs) == 0 {
+				if err := fs.emitEntry(EntryTypeDirectory, absEntryPath, entryPath, entryRel, info, fn); err != nil {
+					return err
+				}
+			}
+			if opts.MaxDepth == 0 || depth < opts.MaxDepth {
+				if err := fs.walkEntries(absEntryPath, entryPath, entryRel, depth+1, opts, after, fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if len(opts.Globs) > 0 && !matchAnyGlob(opts.Globs, entryRel) {
+			continue
+		}
+		if err := fs.emitEntry(EntryTypeFile, absEntryPath, entryPath, entryRel, info, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitEntry calls fn with an entry, returning errWalkStop when fn stops the walk
+func (fs *Filesystem) emitEntry(entryType string, absPath string, path string, rel string, info os.FileInfo, fn func(entry Entry) bool) error {
+	owner, group, err := fs.getFileOwnerAndGroup(absPath)
+	if err != nil {
+		return err
+	}
+	entry := Entry{
+		Type: entryType,
+		File: File{Path: path, Name: info.Name(), Permissions: fmt.Sprintf("%o", info.Mode()), Size: info.Size(), LastModified: info.ModTime(), Owner: owner, Group: group},
+		rel:  rel,
+	}
+	if !fn(entry) {
+		return errWalkStop
+	}
+	return nil
+}
+
+// ListDirectoryPage lists at most limit entries of a directory, after the entry of the
+// page token after, see WalkDirectory
+func (fs *Filesystem) ListDirectoryPage(path string, recursive bool, opts TreeOptions, after string, limit int) (*DirectoryPage, error) {
+	page := &DirectoryPage{Path: fs.ResolveDisplayPath(path), Entries: []Entry{}}
+	more := false
+	err := fs.WalkDirectory(path, recursive, opts, after, func(entry Entry) bool {
+		if len(page.Entries) == limit {
+			more = true
+			return false
+		}
+		page.Entries = append(page.Entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if more {
+		page.NextPageToken = PageToken(page.Entries[len(page.Entries)-1])
+	}
+	return page, nil
+}
+
+// PageToken returns the page token resuming a listing after an entry
+func PageToken(entry Entry) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(entry.rel))
+}
+
+// comparePaths compares relative paths element by element, in the order a walk lists
+// them: a directory comes right before its content
+func comparePaths(a, b string) int {
+	as, bs := strings.Split(a, string(filepath.Separator)), strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// isWithin reports whether the relative path is in the directory dir
+func isWithin(path string, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
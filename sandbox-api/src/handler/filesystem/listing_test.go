@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// entryNames returns the names of entries relative to the listed directory, with a
+// trailing slash for directories
+func entryNames(entries []Entry) []string {
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.rel
+		if entry.Type == EntryTypeDirectory {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestListDirectoryPage tests listing a tree page by page, resuming in and after
+// subdirectories
+func TestListDirectoryPage(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for _, path := range []string{
+		"project/a.txt",
+		"project/a/b.go",
+		"project/a/c/d.go",
+		"project/b.go",
+		"project/.env",
+	} {
+		if err := fs.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	t.Run("Recursive", func(t *testing.T) {
+		var entries []Entry
+		token := ""
+		for pages := 0; pages == 0 || token != ""; pages++ {
+			if pages > 10 {
+				t.Fatal("Expected the listing to end")
+			}
+			page, err := fs.ListDirectoryPage("project", true, TreeOptions{}, token, 2)
+			if err != nil {
+				t.Fatalf("Failed to list page: %v", err)
+			}
+			if len(page.Entries) > 2 {
+				t.Fatalf("Expected at most 2 entries, got %d", len(page.Entries))
+			}
+			entries = append(entries, page.Entries...)
+			token = page.NextPageToken
+		}
+		expected := []string{"a/", "a/b.go", "a/c/", "a/c/d.go", "a.txt", "b.go"}
+		if names := entryNames(entries); !reflect.DeepEqual(names, expected) {
+			t.Errorf("Expected %v, got %v", expected, names)
+		}
+	})
+
+	t.Run("NonRecursive", func(t *testing.T) {
+		page, err := fs.ListDirectoryPage("project", false, TreeOptions{IncludeHidden: true}, "", 10)
+		if err != nil {
+			t.Fatalf("Failed to list page: %v", err)
+		}
+		expected := []string{".env", "a/", "a.txt", "b.go"}
+		if names := entryNames(page.Entries); !reflect.DeepEqual(names, expected) || page.NextPageToken != "" {
+			t.Errorf("Expected %v on a single page, got %v (%q)", expected, names, page.NextPageToken)
+		}
+	})
+
+	t.Run("Globs", func(t *testing.T) {
+		page, err := fs.ListDirectoryPage("project", true, TreeOptions{Globs: []string{"*.go"}}, PageToken(Entry{rel: "a/b.go"}), 10)
+		if err != nil {
+			t.Fatalf("Failed to list page: %v", err)
+		}
+		expected := []string{"a/c/d.go", "b.go"}
+		if names := entryNames(page.Entries); !reflect.DeepEqual(names, expected) {
+			t.Errorf("Expected %v, got %v", expected, names)
+		}
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		for _, token := range []string{"%%%", PageToken(Entry{rel: "../etc"})} {
+			if _, err := fs.ListDirectoryPage("project", true, TreeOptions{}, token, 10); !errors.Is(err, apierror.New(apierror.CodeInvalidRequest, "")) {
+				t.Errorf("Expected an invalid request for %q, got %v", token, err)
+			}
+		}
+	})
+}
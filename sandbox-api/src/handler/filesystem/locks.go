@@ -0,0 +1,217 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Lock durations
+const (
+	// DefaultLockTTL is the duration of a lock when none is given
+	DefaultLockTTL = time.Minute
+	// MaxLockTTL is the maximum duration of a lock, which can be renewed
+	MaxLockTTL = 24 * time.Hour
+)
+
+// FileLock is an advisory lease on a file or a directory, and everything below it, held
+// by a client until it is released or expires
+type FileLock struct {
+	Path string `json:"path" example:"/app/src/main.go" binding:"required"`
+	// Holder identifies the client holding the lock, like the name of an agent
+	Holder string `json:"holder" example:"agent-1" binding:"required"`
+	// Enforced locks reject the writes of other holders, the others are only advisory
+	Enforced   bool      `json:"enforced" example:"false"`
+	AcquiredAt time.Time `json:"acquiredAt" example:"2025-01-01T12:00:00Z" binding:"required"`
+	ExpiresAt  time.Time `json:"expiresAt" example:"2025-01-01T12:01:00Z" binding:"required"`
+} // @name FileLock
+
+// ErrLockNotFound is returned when a path is not locked
+var ErrLockNotFound = apierror.New(apierror.CodeFSLockNotFound, "path is not locked")
+
+// LockTable holds the locks of paths. Locks are kept in memory, by absolute path, and a
+// lock on a directory covers everything below it, so overlapping locks conflict.
+type LockTable struct {
+	mu    sync.Mutex
+	locks map[string]*FileLock
+	now   func() time.Time
+}
+
+var (
+	lockTable     *LockTable
+	lockTableOnce sync.Once
+)
+
+// GetLockTable returns the singleton lock table, shared by every API
+func GetLockTable() *LockTable {
+	lockTableOnce.Do(func() {
+		lockTable = NewLockTable()
+	})
+	return lockTable
+}
+
+// NewLockTable creates an empty lock table
+func NewLockTable() *LockTable {
+	return &LockTable{locks: make(map[string]*FileLock), now: time.Now}
+}
+
+// Acquire locks an absolute path for holder during ttl. The holder of the lock renews
+// it, replacing its duration and enforcement. It fails with FS_LOCKED when the path, one
+// of its parents or a path below it is locked by another holder.
+func (t *LockTable) Acquire(path string, holder string, ttl time.Duration, enforced bool) (FileLock, error) {
+	if holder == "" {
+		return FileLock{}, apierror.New(apierror.CodeInvalidRequest, "holder is required")
+	}
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	if ttl > MaxLockTTL {
+		return FileLock{}, apierror.Newf(apierror.CodeInvalidRequest, "ttl cannot exceed %s", MaxLockTTL)
+	}
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.expire(now)
+	for _, lock := range t.locks {
+		if lock.Holder != holder && pathsOverlap(lock.Path, path) {
+			return FileLock{}, lockedError(lock)
+		}
+	}
+
+	lock, renewed := t.locks[path]
+	if !renewed {
+		lock = &FileLock{Path: path, Holder: holder, AcquiredAt: now}
+		t.locks[path] = lock
+	}
+	lock.Enforced = enforced
+	lock.ExpiresAt = now.Add(ttl)
+	return *lock, nil
+}
+
+// Release releases the lock of holder on an absolute path. It fails with
+// FS_LOCK_NOT_FOUND when the path is not locked and FS_LOCKED when another holder
+// holds its lock.
+func (t *LockTable) Release(path string, holder string) error {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(t.now())
+	lock, exists := t.locks[path]
+	if !exists {
+		return ErrLockNotFound
+	}
+	if lock.Holder != holder {
+		return lockedError(lock)
+	}
+	delete(t.locks, path)
+	return nil
+}
+
+// Get returns the lock of an absolute path, or ErrLockNotFound
+func (t *LockTable) Get(path string) (FileLock, error) {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(t.now())
+	lock, exists := t.locks[path]
+	if !exists {
+		return FileLock{}, ErrLockNotFound
+	}
+	return *lock, nil
+}
+
+// List returns the locks of an absolute path and the paths below it, sorted by path
+func (t *LockTable) List(path string) []FileLock {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(t.now())
+	locks := []FileLock{}
+	for _, lock := range t.locks {
+		if isPathWithin(lock.Path, path) {
+			locks = append(locks, *lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Path < locks[j].Path })
+	return locks
+}
+
+// Check fails with FS_LOCKED when writing one of the absolute paths is rejected by the
+// enforced lock of another holder than holder, a lock covering the path or a path below
+// it. Advisory locks never reject writes.
+func (t *LockTable) Check(holder string, paths ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.locks) == 0 {
+		return nil
+	}
+	t.expire(t.now())
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		for _, lock := range t.locks {
+			if lock.Enforced && lock.Holder != holder && pathsOverlap(lock.Path, path) {
+				return lockedError(lock)
+			}
+		}
+	}
+	return nil
+}
+
+// expire removes the locks expired at now
+func (t *LockTable) expire(now time.Time) {
+	for path, lock := range t.locks {
+		if !now.Before(lock.ExpiresAt) {
+			delete(t.locks, path)
+		}
+	}
+}
+
+// lockedError is the FS_LOCKED error for a path locked by another holder
+func lockedError(lock *FileLock) error {
+	return apierror.Newf(apierror.CodeFSLocked, "%s is locked by %s until %s", lock.Path, lock.Holder, lock.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// pathsOverlap reports whether a path is the same as another one, or below it
+func pathsOverlap(a string, b string) bool {
+	return isPathWithin(a, b) || isPathWithin(b, a)
+}
+
+// isPathWithin reports whether the absolute path is dir or below it
+func isPathWithin(path string, dir string) bool {
+	return path == dir || dir == "/" || isWithin(path, dir)
+}
+
+// CheckLocks fails with FS_LOCKED when writing one of the paths is rejected by the
+// enforced lock of another holder than holder, see LockTable.Check
+func (fs *Filesystem) CheckLocks(holder string, paths ...string) error {
+	absPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		absPath, err := fs.ResolvePath(path)
+		if err != nil {
+			return err
+		}
+		absPaths = append(absPaths, absPath)
+	}
+	return GetLockTable().Check(holder, absPaths...)
+}
+
+// CheckBatchLocks checks the locks of the paths written by the operations of a batch,
+// see CheckLocks
+func (fs *Filesystem) CheckBatchLocks(holder string, operations []BatchOperation) error {
+	paths := make([]string, 0, len(operations))
+	for _, op := range operations {
+		paths = append(paths, op.Path)
+		if op.Destination != "" {
+			paths = append(paths, op.Destination)
+		}
+	}
+	return fs.CheckLocks(holder, paths...)
+}
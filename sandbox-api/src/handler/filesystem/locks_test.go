@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestLockTable tests acquiring, renewing, releasing and expiring locks
+func TestLockTable(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	table := NewLockTable()
+	table.now = func() time.Time { return now }
+	locked := apierror.New(apierror.CodeFSLocked, "")
+
+	lock, err := table.Acquire("/app/src", "agent-1", 0, false)
+	if err != nil || lock.Holder != "agent-1" || !lock.ExpiresAt.Equal(now.Add(DefaultLockTTL)) {
+		t.Fatalf("Unexpected lock %+v (%v)", lock, err)
+	}
+	// Overlapping paths are locked too
+	for _, path := range []string{"/app/src", "/app", "/app/src/main.go", "/"} {
+		if _, err := table.Acquire(path, "agent-2", time.Minute, false); !errors.Is(err, locked) {
+			t.Errorf("Expected %s to be locked by agent-1, got %v", path, err)
+		}
+	}
+	if _, err := table.Acquire("/app/srcs", "agent-2", time.Minute, false); err != nil {
+		t.Errorf("Expected a sibling path to be lockable, got %v", err)
+	}
+
+	// The holder renews its lock
+	now = now.Add(30 * time.Second)
+	if lock, err = table.Acquire("/app/src/", "agent-1", time.Hour, true); err != nil || !lock.Enforced || !lock.ExpiresAt.Equal(now.Add(time.Hour)) || lock.AcquiredAt.Equal(now) {
+		t.Errorf("Expected the lock to be renewed, got %+v (%v)", lock, err)
+	}
+	if locks := table.List("/app"); len(locks) != 2 || locks[0].Path != "/app/src" {
+		t.Errorf("Unexpected locks %+v", locks)
+	}
+
+	if err := table.Release("/app/src", "agent-2"); !errors.Is(err, locked) {
+		t.Errorf("Expected another holder not to release the lock, got %v", err)
+	}
+	if err := table.Release("/app/src", "agent-1"); err != nil {
+		t.Errorf("Failed to release the lock: %v", err)
+	}
+	if _, err := table.Get("/app/src"); !errors.Is(err, ErrLockNotFound) {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+
+	// /app/srcs expires a minute after it was locked
+	now = now.Add(30 * time.Second)
+	if _, err := table.Get("/app/srcs"); !errors.Is(err, ErrLockNotFound) {
+		t.Errorf("Expected the lock to expire, got %v", err)
+	}
+	if _, err := table.Acquire("/app", "", time.Minute, false); err == nil {
+		t.Error("Expected a holder to be required")
+	}
+	if _, err := table.Acquire("/app", "agent-1", MaxLockTTL+time.Second, false); err == nil {
+		t.Error("Expected a TTL above the maximum to be rejected")
+	}
+}
+
+// TestLockTableCheck tests that enforced locks reject the writes of other holders
+func TestLockTableCheck(t *testing.T) {
+	table := NewLockTable()
+	if _, err := table.Acquire("/app/src", "agent-1", time.Minute, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Acquire("/app/docs", "agent-1", time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		holder string
+		path   string
+		locked bool
+	}{
+		{"agent-2", "/app/src/main.go", true},
+		{"", "/app/src", true},
+		{"agent-2", "/app", true},
+		{"agent-1", "/app/src/main.go", false},
+		{"agent-2", "/app/docs/README.md", false},
+		{"agent-2", "/app/README.md", false},
+	} {
+		err := table.Check(test.holder, "/tmp/other", test.path)
+		if locked := errors.Is(err, apierror.New(apierror.CodeFSLocked, "")); locked != test.locked {
+			t.Errorf("Expected %s locked=%v for %q, got %v", test.path, test.locked, test.holder, err)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package filesystem
 
 import (
+	"bufio"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -9,12 +11,24 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Statuses of multipart uploads
+const (
+	UploadStatusUploading  = "uploading"
+	UploadStatusCompleting = "completing"
 )
 
+// assemblyBufferSize is the size of the buffer parts are assembled through
+const assemblyBufferSize = 1024 * 1024
+
 // MultipartUpload represents an in-progress multipart upload
 type MultipartUpload struct {
 	UploadID    string                `json:"uploadId" example:"550e8400-e29b-41d4-a716-446655440000"`
@@ -22,7 +36,34 @@ type MultipartUpload struct {
 	Permissions os.FileMode           `json:"permissions" swaggertype:"integer" example:"420"`
 	InitiatedAt time.Time             `json:"initiatedAt"`
 	Parts       map[int]*UploadedPart `json:"parts"`
-	mu          sync.RWMutex          `json:"-" swaggerignore:"true"`
+	Status      string                `json:"status" example:"uploading" enums:"uploading,completing"`
+	// Progress is the progress of the assembly of the parts while completing
+	Progress *AssemblyProgress `json:"progress,omitempty"`
+	// writing is the number of parts being written
+	writing int
+	mu      sync.RWMutex `json:"-" swaggerignore:"true"`
+}
+
+// AssemblyProgress is the progress of the assembly of the parts of an upload
+type AssemblyProgress struct {
+	PartsAssembled int   `json:"partsAssembled" example:"3"`
+	PartCount      int   `json:"partCount" example:"10"`
+	BytesAssembled int64 `json:"bytesAssembled" example:"15728640"`
+	TotalBytes     int64 `json:"totalBytes" example:"52428800"`
+}
+
+// progressWriter counts the bytes written through it in the progress of an upload
+type progressWriter struct {
+	writer io.Writer
+	upload *MultipartUpload
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.upload.mu.Lock()
+	w.upload.Progress.BytesAssembled += int64(n)
+	w.upload.mu.Unlock()
+	return n, err
 }
 
 // UploadedPart represents a single uploaded part
@@ -31,11 +72,16 @@ type UploadedPart struct {
 	ETag       string    `json:"etag" example:"5d41402abc4b2a76b9719d911017c592"`
 	Size       int64     `json:"size" example:"5242880"`
 	UploadedAt time.Time `json:"uploadedAt"`
+	// Partial is true for a part interrupted while being sent, Size bytes were received
+	// and the rest can be sent from that offset
+	Partial bool `json:"partial,omitempty" example:"false"`
 }
 
-// MultipartManager manages multipart upload sessions
+// MultipartManager manages multipart upload and download sessions. Downloads only
+// live in memory, they are cheap to initiate again.
 type MultipartManager struct {
 	uploads    map[string]*MultipartUpload
+	downloads  map[string]*MultipartDownload
 	uploadsDir string
 	mu         sync.RWMutex
 }
@@ -49,10 +95,31 @@ func NewMultipartManager(uploadsDir string) *MultipartManager {
 
 	return &MultipartManager{
 		uploads:    make(map[string]*MultipartUpload),
+		downloads:  make(map[string]*MultipartDownload),
 		uploadsDir: uploadsDir,
 	}
 }
 
+// Global multipart manager instance, shared by the REST, WebSocket and MCP handlers so
+// that sessions started with one can be continued with another
+var (
+	multipartManager     *MultipartManager
+	multipartManagerOnce sync.Once
+)
+
+// GetMultipartManager returns the singleton multipart manager, with its sessions loaded
+// from the multipart-uploads temporary directory. It is nil when the directory cannot
+// be created.
+func GetMultipartManager() *MultipartManager {
+	multipartManagerOnce.Do(func() {
+		multipartManager = NewMultipartManager(filepath.Join(os.TempDir(), "multipart-uploads"))
+		if multipartManager != nil {
+			_ = multipartManager.LoadUploads()
+		}
+	})
+	return multipartManager
+}
+
 // InitiateUpload creates a new multipart upload session
 func (m *MultipartManager) InitiateUpload(path string, permissions os.FileMode) (*MultipartUpload, error) {
 	m.mu.Lock()
@@ -66,6 +133,7 @@ func (m *MultipartManager) InitiateUpload(path string, permissions os.FileMode)
 		Permissions: permissions,
 		InitiatedAt: time.Now(),
 		Parts:       make(map[int]*UploadedPart),
+		Status:      UploadStatusUploading,
 	}
 
 	m.uploads[uploadID] = upload
@@ -87,35 +155,67 @@ func (m *MultipartManager) InitiateUpload(path string, permissions os.FileMode)
 	return upload, nil
 }
 
-// UploadPart uploads a single part of a multipart upload
-func (m *MultipartManager) UploadPart(uploadID string, partNumber int, reader io.Reader) (*UploadedPart, error) {
+// UploadPart uploads a single part of a multipart upload. A part interrupted while
+// being sent is kept as a partial part, which is continued by sending the rest of its
+// content from an offset up to its size.
+func (m *MultipartManager) UploadPart(uploadID string, partNumber int, offset int64, reader io.Reader) (*UploadedPart, error) {
 	m.mu.RLock()
 	upload, exists := m.uploads[uploadID]
 	m.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("upload not found: %s", uploadID)
+		return nil, apierror.Newf(apierror.CodeMultipartUploadNotFound, "upload not found: %s", uploadID)
 	}
 
 	if partNumber < 1 || partNumber > 10000 {
-		return nil, fmt.Errorf("part number must be between 1 and 10000")
+		return nil, apierror.New(apierror.CodeMultipartInvalidPartNumber, "part number must be between 1 and 10000")
+	}
+	if offset < 0 {
+		return nil, apierror.New(apierror.CodeMultipartInvalidOffset, "offset must not be negative")
+	}
+
+	// Parts cannot change while the upload is being completed
+	upload.mu.Lock()
+	if upload.Status == UploadStatusCompleting {
+		upload.mu.Unlock()
+		return nil, apierror.Newf(apierror.CodeMultipartUploadBusy, "upload %s is being completed", uploadID)
 	}
+	upload.writing++
+	upload.mu.Unlock()
+	defer func() {
+		upload.mu.Lock()
+		upload.writing--
+		upload.mu.Unlock()
+	}()
+
+	// Calculate MD5 hash while writing
+	hash := md5.New()
 
-	// Create part file
+	// Create part file, or continue it from the offset
 	partPath := filepath.Join(m.uploadsDir, uploadID, fmt.Sprintf("part-%d", partNumber))
-	partFile, err := os.Create(partPath)
+	var partFile *os.File
+	var err error
+	if offset == 0 {
+		partFile, err = os.Create(partPath)
+	} else {
+		partFile, err = continuePart(partPath, partNumber, offset, hash)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create part file: %w", err)
+		return nil, err
 	}
 	defer partFile.Close()
 
-	// Calculate MD5 hash while writing
-	hash := md5.New()
 	multiWriter := io.MultiWriter(partFile, hash)
 
-	size, err := io.Copy(multiWriter, reader)
+	written, err := io.Copy(multiWriter, reader)
 	if err != nil {
-		_ = os.Remove(partPath)
+		// Keep what was received so that the part can be continued
+		m.recordPart(upload, &UploadedPart{
+			PartNumber: partNumber,
+			Size:       offset + written,
+			UploadedAt: time.Now(),
+			Partial:    true,
+		})
 		return nil, fmt.Errorf("failed to write part: %w", err)
 	}
 
@@ -124,99 +224,242 @@ func (m *MultipartManager) UploadPart(uploadID string, partNumber int, reader io
 	part := &UploadedPart{
 		PartNumber: partNumber,
 		ETag:       etag,
-		Size:       size,
+		Size:       offset + written,
 		UploadedAt: time.Now(),
 	}
+	if err := m.recordPart(upload, part); err != nil {
+		return nil, err
+	}
 
-	// Update upload metadata
+	return part, nil
+}
+
+// continuePart opens the file of a part to write from offset, which must not be past
+// the content received so far, and hashes the content before offset
+func continuePart(partPath string, partNumber int, offset int64, hash io.Writer) (*os.File, error) {
+	partFile, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part file: %w", err)
+	}
+	info, err := partFile.Stat()
+	if err != nil {
+		_ = partFile.Close()
+		return nil, fmt.Errorf("failed to open part file: %w", err)
+	}
+	if offset > info.Size() {
+		_ = partFile.Close()
+		return nil, apierror.Newf(apierror.CodeMultipartInvalidOffset, "offset %d is past the %d bytes received for part %d", offset, info.Size(), partNumber).
+			WithDetail("partNumber", partNumber).
+			WithDetail("receivedSize", info.Size())
+	}
+	if err := partFile.Truncate(offset); err != nil {
+		_ = partFile.Close()
+		return nil, fmt.Errorf("failed to truncate part file: %w", err)
+	}
+	if _, err := io.CopyN(hash, partFile, offset); err != nil {
+		_ = partFile.Close()
+		return nil, fmt.Errorf("failed to read part file: %w", err)
+	}
+	return partFile, nil
+}
+
+// recordPart adds a part to the metadata of an upload
+func (m *MultipartManager) recordPart(upload *MultipartUpload, part *UploadedPart) error {
 	upload.mu.Lock()
-	upload.Parts[partNumber] = part
+	upload.Parts[part.PartNumber] = part
 	upload.mu.Unlock()
 
 	// Save updated metadata
 	if err := m.saveMetadata(upload); err != nil {
-		return nil, fmt.Errorf("failed to save metadata: %w", err)
+		return fmt.Errorf("failed to save metadata: %w", err)
 	}
-
-	return part, nil
+	return nil
 }
 
-// CompleteUpload assembles all parts into the final file
-func (m *MultipartManager) CompleteUpload(uploadID string, parts []UploadedPart) error {
+// CompleteUpload assembles all parts into the final file. The file is assembled next
+// to the target, synced to disk and renamed over the target once complete, after
+// verifying that its sha256 is checksum when not empty, so that the target is never
+// left truncated. Parts cannot be uploaded while completing, and the progress of the
+// assembly is reported by the upload.
+func (m *MultipartManager) CompleteUpload(uploadID string, parts []UploadedPart, checksum string) (err error) {
 	m.mu.RLock()
 	upload, exists := m.uploads[uploadID]
 	m.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("upload not found: %s", uploadID)
+		return apierror.Newf(apierror.CodeMultipartUploadNotFound, "upload not found: %s", uploadID)
 	}
 
-	upload.mu.RLock()
-	defer upload.mu.RUnlock()
+	upload.mu.Lock()
+	if upload.Status == UploadStatusCompleting {
+		upload.mu.Unlock()
+		return apierror.Newf(apierror.CodeMultipartUploadBusy, "upload %s is already being completed", uploadID)
+	}
+	if upload.writing > 0 {
+		upload.mu.Unlock()
+		return apierror.Newf(apierror.CodeMultipartUploadBusy, "parts of upload %s are still being uploaded", uploadID)
+	}
 
 	// Validate all parts are present
+	var totalBytes int64
 	for _, part := range parts {
 		storedPart, exists := upload.Parts[part.PartNumber]
 		if !exists {
-			return fmt.Errorf("part %d not found", part.PartNumber)
+			upload.mu.Unlock()
+			return apierror.Newf(apierror.CodeMultipartPartNotFound, "part %d not found", part.PartNumber).WithDetail("partNumber", part.PartNumber)
+		}
+		if storedPart.Partial {
+			upload.mu.Unlock()
+			return apierror.Newf(apierror.CodeMultipartPartIncomplete, "part %d is incomplete, %d bytes were received", part.PartNumber, storedPart.Size).
+				WithDetail("partNumber", part.PartNumber).
+				WithDetail("receivedSize", storedPart.Size)
 		}
 		if storedPart.ETag != part.ETag {
-			return fmt.Errorf("etag mismatch for part %d", part.PartNumber)
+			upload.mu.Unlock()
+			return apierror.Newf(apierror.CodeMultipartETagMismatch, "etag mismatch for part %d", part.PartNumber).WithDetail("partNumber", part.PartNumber)
 		}
+		totalBytes += storedPart.Size
 	}
+	upload.Status = UploadStatusCompleting
+	upload.Progress = &AssemblyProgress{PartCount: len(parts), TotalBytes: totalBytes}
+	upload.mu.Unlock()
+	_ = m.saveMetadata(upload)
+
+	// The upload can be completed again after a failure
+	defer func() {
+		if err == nil {
+			return
+		}
+		upload.mu.Lock()
+		upload.Status = UploadStatusUploading
+		upload.Progress = nil
+		upload.mu.Unlock()
+		_ = m.saveMetadata(upload)
+	}()
 
 	// Sort parts by part number
 	sort.Slice(parts, func(i, j int) bool {
 		return parts[i].PartNumber < parts[j].PartNumber
 	})
 
+	if err := m.assemble(upload, parts, checksum); err != nil {
+		return err
+	}
+
+	// Clean up upload directory and metadata. Errors are ignored since the file is
+	// already created.
+	m.mu.Lock()
+	_ = m.removeUpload(uploadID)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// assemble concatenates the parts of an upload into a temporary file next to its
+// target, and renames it over the target once synced to disk
+func (m *MultipartManager) assemble(upload *MultipartUpload, parts []UploadedPart, checksum string) error {
 	// Create parent directories if they don't exist
 	dir := filepath.Dir(upload.Path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create parent directory: %w", err)
 	}
 
-	// Create final file
-	finalFile, err := os.OpenFile(upload.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, upload.Permissions)
+	assembled, err := os.CreateTemp(dir, assemblyPattern(upload.Path))
 	if err != nil {
 		return fmt.Errorf("failed to create final file: %w", err)
 	}
-	defer finalFile.Close()
+	defer func() {
+		_ = assembled.Close()
+		_ = os.Remove(assembled.Name())
+	}()
+
+	hash := sha256.New()
+	writer := io.Writer(assembled)
+	if checksum != "" {
+		writer = io.MultiWriter(assembled, hash)
+	}
+	buffered := bufio.NewWriterSize(writer, assemblyBufferSize)
+	progress := progressWriter{writer: buffered, upload: upload}
 
 	// Concatenate all parts in order
 	for _, part := range parts {
-		partPath := filepath.Join(m.uploadsDir, uploadID, fmt.Sprintf("part-%d", part.PartNumber))
+		partPath := filepath.Join(m.uploadsDir, upload.UploadID, fmt.Sprintf("part-%d", part.PartNumber))
 		partFile, err := os.Open(partPath)
 		if err != nil {
 			return fmt.Errorf("failed to open part %d: %w", part.PartNumber, err)
 		}
 
-		if _, err := io.Copy(finalFile, partFile); err != nil {
+		if _, err := io.Copy(progress, partFile); err != nil {
 			_ = partFile.Close()
 			return fmt.Errorf("failed to copy part %d: %w", part.PartNumber, err)
 		}
 		_ = partFile.Close()
+
+		upload.mu.Lock()
+		upload.Progress.PartsAssembled++
+		upload.mu.Unlock()
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write final file: %w", err)
 	}
 
-	// Clean up upload directory and metadata
-	if err := m.AbortUpload(uploadID); err != nil {
-		// Log error but don't fail since file is already created
-		return nil
+	if checksum != "" {
+		if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, checksum) {
+			return apierror.Newf(apierror.CodeMultipartChecksumMismatch, "sha256 mismatch: expected %s, assembled file has %s", checksum, actual).
+				WithDetail("expected", checksum).
+				WithDetail("actual", actual)
+		}
+	}
+
+	if err := assembled.Chmod(upload.Permissions); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := assembled.Sync(); err != nil {
+		return fmt.Errorf("failed to sync final file: %w", err)
+	}
+	if err := assembled.Close(); err != nil {
+		return fmt.Errorf("failed to write final file: %w", err)
+	}
+	if err := os.Rename(assembled.Name(), upload.Path); err != nil {
+		return fmt.Errorf("failed to replace final file: %w", err)
 	}
 
+	// Sync the directory so that the rename survives a crash
+	if dirFile, err := os.Open(dir); err == nil {
+		_ = dirFile.Sync()
+		_ = dirFile.Close()
+	}
 	return nil
 }
 
+// assemblyPattern returns the pattern of the temporary files the target path is
+// assembled into
+func assemblyPattern(path string) string {
+	return "." + filepath.Base(path) + ".upload-*"
+}
+
 // AbortUpload cancels an upload and cleans up all parts
 func (m *MultipartManager) AbortUpload(uploadID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	_, exists := m.uploads[uploadID]
+	upload, exists := m.uploads[uploadID]
 	if !exists {
-		return fmt.Errorf("upload not found: %s", uploadID)
+		return apierror.Newf(apierror.CodeMultipartUploadNotFound, "upload not found: %s", uploadID)
+	}
+
+	upload.mu.RLock()
+	completing := upload.Status == UploadStatusCompleting
+	upload.mu.RUnlock()
+	if completing {
+		return apierror.Newf(apierror.CodeMultipartUploadBusy, "upload %s is being completed", uploadID)
 	}
 
+	return m.removeUpload(uploadID)
+}
+
+// removeUpload removes an upload and its parts, m.mu must be held
+func (m *MultipartManager) removeUpload(uploadID string) error {
 	// Remove upload directory with all parts
 	uploadDir := filepath.Join(m.uploadsDir, uploadID)
 	if err := os.RemoveAll(uploadDir); err != nil {
@@ -236,7 +479,7 @@ func (m *MultipartManager) ListParts(uploadID string) ([]*UploadedPart, error) {
 	m.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("upload not found: %s", uploadID)
+		return nil, apierror.Newf(apierror.CodeMultipartUploadNotFound, "upload not found: %s", uploadID)
 	}
 
 	upload.mu.RLock()
@@ -255,6 +498,36 @@ func (m *MultipartManager) ListParts(uploadID string) ([]*UploadedPart, error) {
 	return parts, nil
 }
 
+// UploadStatus returns a copy of the metadata of an upload, safe to read while the
+// upload is being completed
+func (m *MultipartManager) UploadStatus(uploadID string) (*MultipartUpload, error) {
+	upload, err := m.GetUpload(uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	upload.mu.RLock()
+	defer upload.mu.RUnlock()
+
+	status := &MultipartUpload{
+		UploadID:    upload.UploadID,
+		Path:        upload.Path,
+		Permissions: upload.Permissions,
+		InitiatedAt: upload.InitiatedAt,
+		Parts:       make(map[int]*UploadedPart, len(upload.Parts)),
+		Status:      upload.Status,
+	}
+	for number, part := range upload.Parts {
+		copied := *part
+		status.Parts[number] = &copied
+	}
+	if upload.Progress != nil {
+		progress := *upload.Progress
+		status.Progress = &progress
+	}
+	return status, nil
+}
+
 // GetUpload returns upload metadata
 func (m *MultipartManager) GetUpload(uploadID string) (*MultipartUpload, error) {
 	m.mu.RLock()
@@ -262,7 +535,7 @@ func (m *MultipartManager) GetUpload(uploadID string) (*MultipartUpload, error)
 
 	upload, exists := m.uploads[uploadID]
 	if !exists {
-		return nil, fmt.Errorf("upload not found: %s", uploadID)
+		return nil, apierror.Newf(apierror.CodeMultipartUploadNotFound, "upload not found: %s", uploadID)
 	}
 
 	return upload, nil
@@ -331,13 +604,24 @@ func (m *MultipartManager) LoadUploads() error {
 			continue
 		}
 
+		// An upload being completed was interrupted, its target was left untouched
+		if upload.Status == UploadStatusCompleting {
+			if leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(upload.Path), assemblyPattern(upload.Path))); err == nil {
+				for _, leftover := range leftovers {
+					_ = os.Remove(leftover)
+				}
+			}
+		}
+		upload.Status = UploadStatusUploading
+		upload.Progress = nil
+
 		m.uploads[upload.UploadID] = &upload
 	}
 
 	return nil
 }
 
-// CleanupExpired removes uploads older than the specified duration
+// CleanupExpired removes uploads and downloads older than the specified duration
 func (m *MultipartManager) CleanupExpired(maxAge time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -348,9 +632,10 @@ func (m *MultipartManager) CleanupExpired(maxAge time.Duration) error {
 	for uploadID, upload := range m.uploads {
 		upload.mu.RLock()
 		age := now.Sub(upload.InitiatedAt)
+		completing := upload.Status == UploadStatusCompleting
 		upload.mu.RUnlock()
 
-		if age > maxAge {
+		if age > maxAge && !completing {
 			expired = append(expired, uploadID)
 		}
 	}
@@ -361,5 +646,11 @@ func (m *MultipartManager) CleanupExpired(maxAge time.Duration) error {
 		delete(m.uploads, uploadID)
 	}
 
+	for downloadID, download := range m.downloads {
+		if now.Sub(download.InitiatedAt) > maxAge {
+			delete(m.downloads, downloadID)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,195 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// failingReader returns its content then fails, like a connection dropped mid-part
+type failingReader struct {
+	content io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.content.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// TestUploadPartContinuation tests continuing a part interrupted while being sent
+func TestUploadPartContinuation(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMultipartManager(filepath.Join(dir, "uploads"))
+	target := filepath.Join(dir, "out.txt")
+
+	upload, err := m.InitiateUpload(target, 0644)
+	if err != nil {
+		t.Fatalf("Failed to initiate upload: %v", err)
+	}
+
+	if _, err := m.UploadPart(upload.UploadID, 1, 0, &failingReader{strings.NewReader("hello ")}); err == nil {
+		t.Fatal("Expected the interrupted part to fail")
+	}
+	parts, err := m.ListParts(upload.UploadID)
+	if err != nil || len(parts) != 1 || !parts[0].Partial || parts[0].Size != 6 {
+		t.Fatalf("Expected a partial part of 6 bytes, got %+v (%v)", parts, err)
+	}
+	if err := m.CompleteUpload(upload.UploadID, []UploadedPart{{PartNumber: 1}}, ""); !errors.Is(err, apierror.New(apierror.CodeMultipartPartIncomplete, "")) {
+		t.Errorf("Expected completing with a partial part to fail, got %v", err)
+	}
+
+	if _, err := m.UploadPart(upload.UploadID, 1, 7, strings.NewReader("world")); !errors.Is(err, apierror.New(apierror.CodeMultipartInvalidOffset, "")) {
+		t.Errorf("Expected an offset past the received size to fail, got %v", err)
+	}
+	part, err := m.UploadPart(upload.UploadID, 1, 6, strings.NewReader("world"))
+	if err != nil {
+		t.Fatalf("Failed to continue part: %v", err)
+	}
+	full, err := m.UploadPart(upload.UploadID, 2, 0, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.Partial || part.Size != 11 || part.ETag != full.ETag {
+		t.Errorf("Expected the continued part to match the whole part, got %+v and %+v", part, full)
+	}
+
+	if err := m.CompleteUpload(upload.UploadID, []UploadedPart{{PartNumber: 1, ETag: part.ETag}}, ""); err != nil {
+		t.Fatalf("Failed to complete upload: %v", err)
+	}
+	if content, err := os.ReadFile(target); err != nil || string(content) != "hello world" {
+		t.Errorf("Unexpected assembled file %q (%v)", content, err)
+	}
+}
+
+// TestCompleteUploadChecksum tests verifying the sha256 of the assembled file
+func TestCompleteUploadChecksum(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMultipartManager(filepath.Join(dir, "uploads"))
+	target := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(target, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	upload, err := m.InitiateUpload(target, 0600)
+	if err != nil {
+		t.Fatalf("Failed to initiate upload: %v", err)
+	}
+	first, err := m.UploadPart(upload.UploadID, 1, 0, strings.NewReader("new "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.UploadPart(upload.UploadID, 2, 0, strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := []UploadedPart{{PartNumber: 2, ETag: second.ETag}, {PartNumber: 1, ETag: first.ETag}}
+
+	wrong := sha256.Sum256([]byte("other content"))
+	if err := m.CompleteUpload(upload.UploadID, parts, hex.EncodeToString(wrong[:])); !errors.Is(err, apierror.New(apierror.CodeMultipartChecksumMismatch, "")) {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "original" {
+		t.Errorf("Expected the target to be left unchanged, got %q", content)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected no temporary file to be left, got %d entries", len(entries))
+	}
+
+	sum := sha256.Sum256([]byte("new content"))
+	if err := m.CompleteUpload(upload.UploadID, parts, strings.ToUpper(hex.EncodeToString(sum[:]))); err != nil {
+		t.Fatalf("Failed to complete upload: %v", err)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil || string(content) != "new content" {
+		t.Errorf("Unexpected assembled file %q (%v)", content, err)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the permissions of the upload, got %v (%v)", info.Mode(), err)
+	}
+}
+
+// TestCompleteUploadBusy tests refusing to complete an upload while parts are written,
+// and to change an upload while it is completed
+func TestCompleteUploadBusy(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMultipartManager(filepath.Join(dir, "uploads"))
+
+	upload, err := m.InitiateUpload(filepath.Join(dir, "out.txt"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to initiate upload: %v", err)
+	}
+	part, err := m.UploadPart(upload.UploadID, 1, 0, strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy := apierror.New(apierror.CodeMultipartUploadBusy, "")
+
+	upload.writing++
+	if err := m.CompleteUpload(upload.UploadID, []UploadedPart{{PartNumber: 1, ETag: part.ETag}}, ""); !errors.Is(err, busy) {
+		t.Errorf("Expected completing while a part is written to fail, got %v", err)
+	}
+	upload.writing--
+
+	upload.Status = UploadStatusCompleting
+	if _, err := m.UploadPart(upload.UploadID, 2, 0, strings.NewReader("more")); !errors.Is(err, busy) {
+		t.Errorf("Expected uploading while completing to fail, got %v", err)
+	}
+	if err := m.AbortUpload(upload.UploadID); !errors.Is(err, busy) {
+		t.Errorf("Expected aborting while completing to fail, got %v", err)
+	}
+	upload.Status = UploadStatusUploading
+
+	// A failed completion leaves the upload to complete again
+	if err := m.CompleteUpload(upload.UploadID, []UploadedPart{{PartNumber: 1, ETag: part.ETag}}, "00"); err == nil {
+		t.Fatal("Expected a checksum mismatch")
+	}
+	status, err := m.UploadStatus(upload.UploadID)
+	if err != nil || status.Status != UploadStatusUploading || status.Progress != nil || len(status.Parts) != 1 {
+		t.Errorf("Expected the upload to be uploading again, got %+v (%v)", status, err)
+	}
+}
+
+// TestLoadInterruptedCompletion tests recovering an upload interrupted while completed
+func TestLoadInterruptedCompletion(t *testing.T) {
+	dir := t.TempDir()
+	uploadsDir := filepath.Join(dir, "uploads")
+	target := filepath.Join(dir, "out.txt")
+	m := NewMultipartManager(uploadsDir)
+
+	upload, err := m.InitiateUpload(target, 0644)
+	if err != nil {
+		t.Fatalf("Failed to initiate upload: %v", err)
+	}
+	upload.Status = UploadStatusCompleting
+	upload.Progress = &AssemblyProgress{PartCount: 1}
+	if err := m.saveMetadata(upload); err != nil {
+		t.Fatal(err)
+	}
+	leftover, err := os.CreateTemp(dir, assemblyPattern(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = leftover.Close()
+
+	restarted := NewMultipartManager(uploadsDir)
+	if err := restarted.LoadUploads(); err != nil {
+		t.Fatal(err)
+	}
+	status, err := restarted.UploadStatus(upload.UploadID)
+	if err != nil || status.Status != UploadStatusUploading || status.Progress != nil {
+		t.Errorf("Expected the upload to be uploading again, got %+v (%v)", status, err)
+	}
+	if _, err := os.Stat(leftover.Name()); !os.IsNotExist(err) {
+		t.Errorf("Expected the partially assembled file to be removed, got %v", err)
+	}
+}
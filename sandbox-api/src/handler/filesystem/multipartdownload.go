@@ -0,0 +1,168 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+const (
+	// DefaultDownloadPartSize is the size of the parts of a download when the client
+	// does not choose one
+	DefaultDownloadPartSize = 8 * 1024 * 1024
+	// MinDownloadPartSize is the smallest size of the parts of a download
+	MinDownloadPartSize = 64 * 1024
+	// maxDownloadParts is the largest number of parts of a download, like uploads
+	maxDownloadParts = 10000
+)
+
+// MultipartDownload represents a download of a file in parts, which clients can fetch
+// in parallel and retry independently
+type MultipartDownload struct {
+	DownloadID string `json:"downloadId" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	Path       string `json:"path" example:"/tmp/largefile.dat" binding:"required"`
+	Size       int64  `json:"size" example:"52428800" binding:"required"`
+	PartSize   int64  `json:"partSize" example:"8388608" binding:"required"`
+	PartCount  int    `json:"partCount" example:"7" binding:"required"`
+	// Checksum is the sha256 of the whole file
+	Checksum     string         `json:"checksum" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" binding:"required"`
+	LastModified time.Time      `json:"lastModified" binding:"required"`
+	InitiatedAt  time.Time      `json:"initiatedAt" binding:"required"`
+	Parts        []DownloadPart `json:"parts" binding:"required"`
+} // @name MultipartDownload
+
+// DownloadPart represents a single part of a download, the bytes from Offset to
+// Offset+Size of the file
+type DownloadPart struct {
+	PartNumber int   `json:"partNumber" example:"1" binding:"required"`
+	Offset     int64 `json:"offset" example:"0" binding:"required"`
+	Size       int64 `json:"size" example:"8388608" binding:"required"`
+	// Checksum is the sha256 of the content of the part
+	Checksum string `json:"checksum" example:"5d41402abc4b2a76b9719d911017c5925d41402abc4b2a76b9719d911017c592" binding:"required"`
+} // @name DownloadPart
+
+// InitiateDownload splits the file at path in parts of partSize bytes, the default
+// size when 0, and computes the checksums of the file and of each part. The size of
+// the parts grows when the file would have more than 10000 parts.
+func (m *MultipartManager) InitiateDownload(path string, partSize int64) (*MultipartDownload, error) {
+	if partSize == 0 {
+		partSize = DefaultDownloadPartSize
+	}
+	if partSize < MinDownloadPartSize {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "part size must be at least %d bytes", MinDownloadPartSize)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrIsDirectory
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("only regular files can be downloaded in parts")
+	}
+	if minSize := (info.Size() + maxDownloadParts - 1) / maxDownloadParts; partSize < minSize {
+		partSize = minSize
+	}
+
+	download := &MultipartDownload{
+		DownloadID:   uuid.New().String(),
+		Path:         path,
+		Size:         info.Size(),
+		PartSize:     partSize,
+		LastModified: info.ModTime(),
+		InitiatedAt:  time.Now(),
+		Parts:        make([]DownloadPart, 0),
+	}
+
+	// Hash the file and its parts in a single read
+	fileHash := sha256.New()
+	for offset := int64(0); offset < info.Size(); offset += partSize {
+		size := min(partSize, info.Size()-offset)
+		partHash := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(fileHash, partHash), file, size); err != nil {
+			return nil, fmt.Errorf("failed to read part %d: %w", len(download.Parts)+1, err)
+		}
+		download.Parts = append(download.Parts, DownloadPart{
+			PartNumber: len(download.Parts) + 1,
+			Offset:     offset,
+			Size:       size,
+			Checksum:   hex.EncodeToString(partHash.Sum(nil)),
+		})
+	}
+	download.PartCount = len(download.Parts)
+	download.Checksum = hex.EncodeToString(fileHash.Sum(nil))
+
+	m.mu.Lock()
+	m.downloads[download.DownloadID] = download
+	m.mu.Unlock()
+
+	return download, nil
+}
+
+// GetDownload returns download metadata
+func (m *MultipartManager) GetDownload(downloadID string) (*MultipartDownload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	download, exists := m.downloads[downloadID]
+	if !exists {
+		return nil, apierror.Newf(apierror.CodeMultipartDownloadNotFound, "download not found: %s", downloadID)
+	}
+	return download, nil
+}
+
+// OpenDownloadPart opens the file of a download and returns the reader of one of its
+// parts. It fails with ErrPreconditionFailed when the file changed since the download
+// was initiated, as its parts would no longer match their checksums.
+func (m *MultipartManager) OpenDownloadPart(downloadID string, partNumber int) (*os.File, *io.SectionReader, DownloadPart, error) {
+	download, err := m.GetDownload(downloadID)
+	if err != nil {
+		return nil, nil, DownloadPart{}, err
+	}
+	if partNumber < 1 || partNumber > download.PartCount {
+		return nil, nil, DownloadPart{}, apierror.Newf(apierror.CodeMultipartInvalidPartNumber, "part number must be between 1 and %d", download.PartCount)
+	}
+	part := download.Parts[partNumber-1]
+
+	file, err := os.Open(download.Path)
+	if err != nil {
+		return nil, nil, DownloadPart{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, DownloadPart{}, err
+	}
+	if info.Size() != download.Size || !info.ModTime().Equal(download.LastModified) {
+		_ = file.Close()
+		return nil, nil, DownloadPart{}, fmt.Errorf("%w: file has changed since the download was initiated", ErrPreconditionFailed)
+	}
+	return file, io.NewSectionReader(file, part.Offset, part.Size), part, nil
+}
+
+// AbortDownload forgets a download
+func (m *MultipartManager) AbortDownload(downloadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.downloads[downloadID]; !exists {
+		return apierror.Newf(apierror.CodeMultipartDownloadNotFound, "download not found: %s", downloadID)
+	}
+	delete(m.downloads, downloadID)
+	return nil
+}
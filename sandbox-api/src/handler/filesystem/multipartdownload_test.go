@@ -0,0 +1,106 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestMultipartDownload tests splitting a file in parts and reading them back
+func TestMultipartDownload(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMultipartManager(filepath.Join(dir, "uploads"))
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), (2*MinDownloadPartSize+100)/16+1)
+	path := filepath.Join(dir, "large.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	download, err := m.InitiateDownload(path, MinDownloadPartSize)
+	if err != nil {
+		t.Fatalf("Failed to initiate download: %v", err)
+	}
+	fileSum := sha256.Sum256(content)
+	if download.PartCount != 3 || download.Size != int64(len(content)) || download.Checksum != hex.EncodeToString(fileSum[:]) {
+		t.Fatalf("Unexpected download %+v", download)
+	}
+
+	// Parts are read in any order and assemble into the file
+	assembled := make([]byte, len(content))
+	for _, partNumber := range []int{3, 1, 2} {
+		file, reader, part, err := m.OpenDownloadPart(download.DownloadID, partNumber)
+		if err != nil {
+			t.Fatalf("Failed to open part %d: %v", partNumber, err)
+		}
+		data, err := io.ReadAll(reader)
+		_ = file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		partSum := sha256.Sum256(data)
+		if int64(len(data)) != part.Size || hex.EncodeToString(partSum[:]) != part.Checksum {
+			t.Errorf("Part %d does not match its size or checksum", partNumber)
+		}
+		copy(assembled[part.Offset:], data)
+	}
+	if !bytes.Equal(assembled, content) {
+		t.Error("Expected the parts to assemble into the file")
+	}
+
+	if _, _, _, err := m.OpenDownloadPart(download.DownloadID, 4); !errors.Is(err, apierror.New(apierror.CodeMultipartInvalidPartNumber, "")) {
+		t.Errorf("Expected an invalid part number, got %v", err)
+	}
+	if _, _, _, err := m.OpenDownloadPart("missing", 1); !errors.Is(err, apierror.New(apierror.CodeMultipartDownloadNotFound, "")) {
+		t.Errorf("Expected a missing download, got %v", err)
+	}
+
+	// Parts of a changed file no longer match their checksums
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := m.OpenDownloadPart(download.DownloadID, 1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected a changed file to fail, got %v", err)
+	}
+
+	if err := m.AbortDownload(download.DownloadID); err != nil {
+		t.Fatalf("Failed to abort download: %v", err)
+	}
+	if _, err := m.GetDownload(download.DownloadID); err == nil {
+		t.Error("Expected the aborted download to be forgotten")
+	}
+}
+
+// TestMultipartDownloadPartSize tests the default and minimum part sizes
+func TestMultipartDownloadPartSize(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMultipartManager(filepath.Join(dir, "uploads"))
+
+	path := filepath.Join(dir, "small.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	download, err := m.InitiateDownload(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to initiate download: %v", err)
+	}
+	if download.PartSize != DefaultDownloadPartSize || download.PartCount != 1 || download.Parts[0].Size != 5 {
+		t.Errorf("Unexpected download %+v", download)
+	}
+
+	if _, err := m.InitiateDownload(path, 1024); err == nil {
+		t.Error("Expected a part size below the minimum to fail")
+	}
+	if _, err := m.InitiateDownload(dir, 0); !errors.Is(err, ErrIsDirectory) {
+		t.Errorf("Expected a directory to fail, got %v", err)
+	}
+}
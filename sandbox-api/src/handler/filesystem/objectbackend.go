@@ -0,0 +1,232 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/objectstore"
+)
+
+// ObjectStore is the storage of the objects of an ObjectBackend, implemented by
+// objectstore.Client. Failures for missing objects wrap fs.ErrNotExist.
+type ObjectStore interface {
+	Get(ctx context.Context, key string) (io.ReadCloser, objectstore.Object, error)
+	Head(ctx context.Context, key string) (objectstore.Object, error)
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string, delimiter string, limit int) ([]objectstore.Object, []string, error)
+}
+
+// ObjectBackend stores the files of a mount as the objects of a bucket, under a prefix.
+// Directories are the prefixes of the keys of the objects, along with the empty objects
+// named after them with a trailing slash which MkdirAll creates. Object storage has
+// neither permissions nor appends: files have mode 0644 and appending to a file
+// rewrites it.
+type ObjectBackend struct {
+	store  ObjectStore
+	prefix string
+}
+
+// NewObjectBackend returns a backend storing files in store, under prefix
+func NewObjectBackend(store ObjectStore, prefix string) *ObjectBackend {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &ObjectBackend{store: store, prefix: prefix}
+}
+
+// key returns the key of the object of the file name
+func (b *ObjectBackend) key(name string) string {
+	return b.prefix + strings.TrimPrefix(name, "/")
+}
+
+// dirKey returns the prefix of the keys of the objects in the directory name
+func (b *ObjectBackend) dirKey(name string) string {
+	if name == "/" {
+		return b.prefix
+	}
+	return b.key(name) + "/"
+}
+
+// objectInfo is the info of a file or directory of an ObjectBackend
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i objectInfo) Name() string       { return i.name }
+func (i objectInfo) Size() int64        { return i.size }
+func (i objectInfo) ModTime() time.Time { return i.modTime }
+func (i objectInfo) IsDir() bool        { return i.dir }
+func (i objectInfo) Sys() any           { return nil }
+
+func (i objectInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// notExist returns the failure of op for a missing file, as returned by the os package
+func notExist(op string, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// Stat implements Backend
+func (b *ObjectBackend) Stat(name string) (os.FileInfo, error) {
+	if name == "/" {
+		return objectInfo{name: "/", dir: true}, nil
+	}
+	ctx := context.Background()
+	object, err := b.store.Head(ctx, b.key(name))
+	if err == nil {
+		return objectInfo{name: path.Base(name), size: object.Size, modTime: object.LastModified}, nil
+	}
+	if !objectstore.IsNotExist(err) {
+		return nil, err
+	}
+
+	objects, prefixes, err := b.store.List(ctx, b.dirKey(name), "/", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 && len(prefixes) == 0 {
+		return nil, notExist("stat", name)
+	}
+	return objectInfo{name: path.Base(name), dir: true}, nil
+}
+
+// ReadDir implements Backend
+func (b *ObjectBackend) ReadDir(name string) ([]os.FileInfo, error) {
+	dirKey := b.dirKey(name)
+	objects, prefixes, err := b.store.List(context.Background(), dirKey, "/", 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 && len(prefixes) == 0 {
+		info, err := b.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, &os.PathError{Op: "readdirent", Path: name, Err: syscall.ENOTDIR}
+		}
+	}
+
+	infos := make([]os.FileInfo, 0, len(objects)+len(prefixes))
+	for _, object := range objects {
+		if object.Key == dirKey {
+			continue
+		}
+		infos = append(infos, objectInfo{name: strings.TrimPrefix(object.Key, dirKey), size: object.Size, modTime: object.LastModified})
+	}
+	for _, prefix := range prefixes {
+		infos = append(infos, objectInfo{name: strings.TrimSuffix(strings.TrimPrefix(prefix, dirKey), "/"), dir: true})
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+// ReadFile implements Backend
+func (b *ObjectBackend) ReadFile(name string) ([]byte, error) {
+	body, _, err := b.store.Get(context.Background(), b.key(name))
+	if err != nil {
+		if objectstore.IsNotExist(err) {
+			return nil, notExist("open", name)
+		}
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// WriteFile implements Backend. The content is spooled to a temporary file, as objects
+// are uploaded with their size.
+func (b *ObjectBackend) WriteFile(name string, r io.Reader, perm os.FileMode, append bool) error {
+	ctx := context.Background()
+	spool, err := os.CreateTemp("", "object-upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+
+	if append {
+		body, _, err := b.store.Get(ctx, b.key(name))
+		switch {
+		case err == nil:
+			_, err = io.Copy(spool, body)
+			body.Close()
+			if err != nil {
+				return err
+			}
+		case !objectstore.IsNotExist(err):
+			return err
+		}
+	}
+	if _, err := io.Copy(spool, r); err != nil {
+		return err
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return b.store.Put(ctx, b.key(name), spool, size)
+}
+
+// MkdirAll implements Backend
+func (b *ObjectBackend) MkdirAll(name string, perm os.FileMode) error {
+	if name == "/" {
+		return nil
+	}
+	if info, err := b.Stat(name); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	return b.store.Put(context.Background(), b.dirKey(name), strings.NewReader(""), 0)
+}
+
+// Remove implements Backend
+func (b *ObjectBackend) Remove(name string, recursive bool) error {
+	ctx := context.Background()
+	info, err := b.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return b.store.Delete(ctx, b.key(name))
+	}
+
+	dirKey := b.dirKey(name)
+	objects, _, err := b.store.List(ctx, dirKey, "", 0)
+	if err != nil {
+		return err
+	}
+	if !recursive {
+		for _, object := range objects {
+			if object.Key != dirKey {
+				return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	for _, object := range objects {
+		if err := b.store.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MountOverlayFromEnv mounts an overlayfs on FILESYSTEM_OVERLAY_PATH when
+// FILESYSTEM_OVERLAY_UPPER is set. The writes under the path go to the upper directory
+// while FILESYSTEM_OVERLAY_LOWER, the path itself by default, is left untouched. The
+// overlay is a kernel mount, so the API and the processes see the same files; it needs
+// CAP_SYS_ADMIN. FILESYSTEM_OVERLAY_WORK is the work directory of overlayfs, on the
+// filesystem of the upper directory, a sibling of it by default. An overlay mounted by a
+// previous run of the API is kept.
+func MountOverlayFromEnv() error {
+	upper := os.Getenv("FILESYSTEM_OVERLAY_UPPER")
+	if upper == "" {
+		return nil
+	}
+	mountPath := os.Getenv("FILESYSTEM_OVERLAY_PATH")
+	lower := os.Getenv("FILESYSTEM_OVERLAY_LOWER")
+	if lower == "" {
+		lower = mountPath
+	}
+	work := os.Getenv("FILESYSTEM_OVERLAY_WORK")
+	if work == "" {
+		work = filepath.Join(filepath.Dir(upper), "."+filepath.Base(upper)+".work")
+	}
+	switch {
+	case !filepath.IsAbs(mountPath):
+		return fmt.Errorf("invalid FILESYSTEM_OVERLAY_PATH value '%s'", mountPath)
+	case !filepath.IsAbs(lower):
+		return fmt.Errorf("invalid FILESYSTEM_OVERLAY_LOWER value '%s'", lower)
+	case !filepath.IsAbs(upper):
+		return fmt.Errorf("invalid FILESYSTEM_OVERLAY_UPPER value '%s'", upper)
+	case !filepath.IsAbs(work):
+		return fmt.Errorf("invalid FILESYSTEM_OVERLAY_WORK value '%s'", work)
+	}
+
+	mountPath = filepath.Clean(mountPath)
+	if mounted, err := isOverlayMounted(mountPath); err != nil {
+		return err
+	} else if mounted {
+		logrus.Infof("Overlay already mounted on %s", mountPath)
+		return nil
+	}
+	if err := MountOverlay(mountPath, lower, upper, work); err != nil {
+		return err
+	}
+	logrus.Infof("Mounted overlay of %s on %s, writes go to %s", lower, mountPath, upper)
+	return nil
+}
+
+// MountOverlay mounts an overlayfs of the upper directory over the lower one on
+// mountPath, creating the directories if needed. work is the work directory of
+// overlayfs, on the filesystem of upper.
+func MountOverlay(mountPath string, lower string, upper string, work string) error {
+	for _, dir := range []string{mountPath, lower, upper, work} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", escapeOverlayPath(lower), escapeOverlayPath(upper), escapeOverlayPath(work))
+	if err := syscall.Mount("overlay", mountPath, "overlay", 0, options); err != nil {
+		return fmt.Errorf("could not mount overlay on %s: %w", mountPath, err)
+	}
+	return nil
+}
+
+// escapeOverlayPath escapes the characters of a directory separating the mount options
+// of overlayfs
+func escapeOverlayPath(dir string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ":", `\:`).Replace(dir)
+}
+
+// isOverlayMounted reports whether an overlayfs is mounted on mountPath
+func isOverlayMounted(mountPath string) (bool, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Spaces of mount points are escaped as \040
+		if len(fields) > 2 && fields[2] == "overlay" && strings.ReplaceAll(fields[1], `\040`, " ") == mountPath {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// TrackCreated records which part of a path does not exist yet. The returned function,
+// to call once the path is written, gives what was created to owner: the path and the
+// missing parent directories created along with it. Nothing is given when owner is nil,
+// the files then belong to the user of the API.
+func (fs *Filesystem) TrackCreated(path string, owner *lib.Credential) func() {
+	if owner == nil {
+		return func() {}
+	}
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return func() {}
+	}
+	return trackCreated(absPath, owner)
+}
+
+// trackCreated is TrackCreated for an absolute path
+func trackCreated(absPath string, owner *lib.Credential) func() {
+	created := ""
+	for current := absPath; ; current = filepath.Dir(current) {
+		if _, err := os.Lstat(current); err == nil {
+			break
+		}
+		created = current
+		if current == filepath.Dir(current) {
+			break
+		}
+	}
+	return func() {
+		if created == "" {
+			return
+		}
+		if err := owner.ChownAll(created); err != nil {
+			logrus.Warnf("Failed to give %s to uid %d: %v", created, owner.UID, err)
+		}
+	}
+}
+
+// GiveAll gives path and everything under it to owner, nothing being changed when owner
+// is nil
+func (fs *Filesystem) GiveAll(path string, owner *lib.Credential) {
+	if owner == nil {
+		return
+	}
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return
+	}
+	if err := owner.ChownAll(absPath); err != nil {
+		logrus.Warnf("Failed to give %s to uid %d: %v", absPath, owner.UID, err)
+	}
+}
+
+// copyOwner gives path the owner and group of the file described by info, so that a
+// file staged to replace another or copied from it keeps its owner. Not being allowed
+// to change the owner is not an error.
+func copyOwner(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Lchown(path, int(stat.Uid), int(stat.Gid)); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
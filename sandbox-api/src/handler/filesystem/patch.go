@@ -0,0 +1,191 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Edit operations supported by PatchFile
+const (
+	EditOperationInsert  = "insert"
+	EditOperationReplace = "replace"
+	EditOperationDelete  = "delete"
+)
+
+// Edit units supported by PatchFile
+const (
+	EditUnitBytes = "bytes"
+	EditUnitLines = "lines"
+)
+
+// FileEdit describes a single range edit applied to a file.
+// In "lines" mode Start and End are 1-indexed and inclusive; an insert places
+// Content before line Start (use lineCount+1 to append).
+// In "bytes" mode Start and End are 0-indexed offsets with End exclusive; an
+// insert places Content at offset Start.
+type FileEdit struct {
+	Operation string `json:"operation" example:"replace" enums:"insert,replace,delete" binding:"required"`
+	Unit      string `json:"unit" example:"lines" enums:"bytes,lines"`
+	Start     int64  `json:"start" example:"10"`
+	End       int64  `json:"end" example:"12"`
+	Content   string `json:"content" example:"new content\n"`
+} // @name FileEdit
+
+// PatchFile applies a list of range edits to an existing file.
+// Edits are applied in order, each one against the result of the previous edit.
+// The result is written to a temporary file and renamed over the original so
+// a failed edit never leaves the file half-modified.
+func (fs *Filesystem) PatchFile(path string, edits []FileEdit) error {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrIsDirectory
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return err
+	}
+
+	for i, edit := range edits {
+		content, err = applyEdit(content, edit)
+		if err != nil {
+			return fmt.Errorf("edit %d: %w", i, err)
+		}
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(absPath), "."+filepath.Base(absPath)+".patch-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(content); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := copyOwner(tmpPath, info); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, absPath)
+}
+
+// applyEdit applies a single edit to content and returns the new content
+func applyEdit(content []byte, edit FileEdit) ([]byte, error) {
+	unit := edit.Unit
+	if unit == "" {
+		unit = EditUnitLines
+	}
+
+	var start, end int64
+	var err error
+	switch unit {
+	case EditUnitLines:
+		start, end, err = lineRangeToOffsets(content, edit)
+	case EditUnitBytes:
+		start, end, err = byteRange(content, edit)
+	default:
+		return nil, fmt.Errorf("invalid unit '%s', expected 'bytes' or 'lines'", edit.Unit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var insert []byte
+	switch edit.Operation {
+	case EditOperationInsert:
+		end = start
+		insert = []byte(edit.Content)
+		// Text appended after a last line without a trailing newline starts a new line
+		if unit == EditUnitLines && start == int64(len(content)) && start > 0 && content[start-1] != '\n' {
+			insert = append([]byte{'\n'}, insert...)
+		}
+	case EditOperationReplace:
+		insert = []byte(edit.Content)
+	case EditOperationDelete:
+	default:
+		return nil, fmt.Errorf("invalid operation '%s', expected 'insert', 'replace' or 'delete'", edit.Operation)
+	}
+
+	result := make([]byte, 0, int64(len(content))-(end-start)+int64(len(insert)))
+	result = append(result, content[:start]...)
+	result = append(result, insert...)
+	result = append(result, content[end:]...)
+	return result, nil
+}
+
+// byteRange validates a byte range edit against content
+func byteRange(content []byte, edit FileEdit) (int64, int64, error) {
+	size := int64(len(content))
+	if edit.Start < 0 || edit.Start > size {
+		return 0, 0, fmt.Errorf("start offset %d is out of range (file size %d)", edit.Start, size)
+	}
+	if edit.Operation == EditOperationInsert {
+		return edit.Start, edit.Start, nil
+	}
+	if edit.End < edit.Start || edit.End > size {
+		return 0, 0, fmt.Errorf("end offset %d is out of range (start %d, file size %d)", edit.End, edit.Start, size)
+	}
+	return edit.Start, edit.End, nil
+}
+
+// lineRangeToOffsets converts a 1-indexed inclusive line range to byte offsets
+func lineRangeToOffsets(content []byte, edit FileEdit) (int64, int64, error) {
+	// lineStarts[i] is the byte offset where line i+1 begins
+	lineStarts := []int64{0}
+	for i, b := range content {
+		if b == '\n' && i+1 < len(content) {
+			lineStarts = append(lineStarts, int64(i+1))
+		}
+	}
+	lineCount := int64(len(lineStarts))
+	if len(content) == 0 {
+		lineCount = 0
+	}
+
+	offsetOfLine := func(line int64) int64 {
+		if line > lineCount {
+			return int64(len(content))
+		}
+		return lineStarts[line-1]
+	}
+
+	if edit.Operation == EditOperationInsert {
+		if edit.Start < 1 || edit.Start > lineCount+1 {
+			return 0, 0, fmt.Errorf("start line %d is out of range (file has %d lines)", edit.Start, lineCount)
+		}
+		offset := offsetOfLine(edit.Start)
+		return offset, offset, nil
+	}
+
+	if edit.Start < 1 || edit.Start > lineCount {
+		return 0, 0, fmt.Errorf("start line %d is out of range (file has %d lines)", edit.Start, lineCount)
+	}
+	end := edit.End
+	if end == 0 {
+		end = edit.Start
+	}
+	if end < edit.Start || end > lineCount {
+		return 0, 0, fmt.Errorf("end line %d is out of range (start %d, file has %d lines)", end, edit.Start, lineCount)
+	}
+	return offsetOfLine(edit.Start), offsetOfLine(end + 1), nil
+}
@@ -0,0 +1,114 @@
+package filesystem
+
+import (
+	"testing"
+)
+
+// TestPatchFile tests line and byte range edits
+func TestPatchFile(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name      string
+		initial   string
+		edits     []FileEdit
+		expected  string
+		shouldErr bool
+	}{
+		{
+			name:     "ReplaceLines",
+			initial:  "one\ntwo\nthree\n",
+			edits:    []FileEdit{{Operation: EditOperationReplace, Unit: EditUnitLines, Start: 2, End: 2, Content: "TWO\n"}},
+			expected: "one\nTWO\nthree\n",
+		},
+		{
+			name:     "InsertLineAtEnd",
+			initial:  "one\ntwo\n",
+			edits:    []FileEdit{{Operation: EditOperationInsert, Start: 3, Content: "three\n"}},
+			expected: "one\ntwo\nthree\n",
+		},
+		{
+			name:     "InsertLineAtEndWithoutTrailingNewline",
+			initial:  "one\ntwo",
+			edits:    []FileEdit{{Operation: EditOperationInsert, Start: 3, Content: "three\n"}},
+			expected: "one\ntwo\nthree\n",
+		},
+		{
+			name:     "InsertLineIntoEmptyFile",
+			initial:  "",
+			edits:    []FileEdit{{Operation: EditOperationInsert, Start: 1, Content: "one\n"}},
+			expected: "one\n",
+		},
+		{
+			name:     "DeleteLines",
+			initial:  "one\ntwo\nthree\nfour\n",
+			edits:    []FileEdit{{Operation: EditOperationDelete, Start: 2, End: 3}},
+			expected: "one\nfour\n",
+		},
+		{
+			name:    "SequentialEdits",
+			initial: "a\nb\nc\n",
+			edits: []FileEdit{
+				{Operation: EditOperationDelete, Start: 1},
+				{Operation: EditOperationInsert, Start: 1, Content: "z\n"},
+			},
+			expected: "z\nb\nc\n",
+		},
+		{
+			name:     "ReplaceBytes",
+			initial:  "hello world",
+			edits:    []FileEdit{{Operation: EditOperationReplace, Unit: EditUnitBytes, Start: 6, End: 11, Content: "there"}},
+			expected: "hello there",
+		},
+		{
+			name:     "InsertBytes",
+			initial:  "helloworld",
+			edits:    []FileEdit{{Operation: EditOperationInsert, Unit: EditUnitBytes, Start: 5, Content: " "}},
+			expected: "hello world",
+		},
+		{
+			name:      "LineOutOfRange",
+			initial:   "one\n",
+			edits:     []FileEdit{{Operation: EditOperationReplace, Start: 5, Content: "x"}},
+			shouldErr: true,
+		},
+		{
+			name:      "InvalidOperation",
+			initial:   "one\n",
+			edits:     []FileEdit{{Operation: "upsert", Start: 1}},
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := fs.WriteFile("patch.txt", []byte(tc.initial), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			err := fs.PatchFile("patch.txt", tc.edits)
+			if tc.shouldErr {
+				if err == nil {
+					t.Errorf("Expected error, but got none")
+				}
+				file, _ := fs.ReadFile("patch.txt")
+				if string(file.Content) != tc.initial {
+					t.Errorf("Expected file to be unchanged after failed patch, got %q", string(file.Content))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to patch file: %v", err)
+			}
+
+			file, err := fs.ReadFile("patch.txt")
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if string(file.Content) != tc.expected {
+				t.Errorf("Expected content to be %q, got %q", tc.expected, string(file.Content))
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// renamePairWindow is how long the rename of a file waits for the create of its new name
+const renamePairWindow = 20 * time.Millisecond
+
+// WatchEvent is an event of a watched directory. A file moved within the watched
+// directories is reported as a single CREATE of its new path, From being its previous
+// path. Err is set for the errors of the watch, Name being the watched directory.
+type WatchEvent struct {
+	fsnotify.Event
+	From string
+	Err  error
+}
+
+// RenamePairer pairs the RENAME and CREATE events fsnotify reports for a file moved
+// within the watched directories into a single WatchEvent. inotify reports both halves
+// of a move back to back, so a RENAME is paired with a CREATE coming right after it,
+// and emitted alone when none comes within a short delay.
+type RenamePairer struct {
+	emit func(event WatchEvent)
+
+	// mu is held while emitting, so events keep their order
+	mu      sync.Mutex
+	pending *fsnotify.Event
+	timer   *time.Timer
+	stopped bool
+}
+
+// NewRenamePairer returns a RenamePairer calling emit with the events added to it
+func NewRenamePairer(emit func(event WatchEvent)) *RenamePairer {
+	return &RenamePairer{emit: emit}
+}
+
+// Add adds an event, emitted right away unless it is a RENAME
+func (p *RenamePairer) Add(event fsnotify.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return
+	}
+	pending := p.pending
+	p.pending = nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+
+	if pending != nil {
+		if event.Op == fsnotify.Create {
+			p.emit(WatchEvent{Event: event, From: pending.Name})
+			return
+		}
+		p.emit(WatchEvent{Event: *pending})
+	}
+	if event.Op == fsnotify.Rename {
+		renamed := &event
+		p.pending = renamed
+		p.timer = time.AfterFunc(renamePairWindow, func() { p.flush(renamed) })
+		return
+	}
+	p.emit(WatchEvent{Event: event})
+}
+
+// flush emits a RENAME for which no CREATE came
+func (p *RenamePairer) flush(renamed *fsnotify.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The timer may fire while a later event is added
+	if p.stopped || p.pending != renamed {
+		return
+	}
+	p.pending = nil
+	p.timer = nil
+	p.emit(WatchEvent{Event: *renamed})
+}
+
+// Stop drops the pending RENAME and ignores the events added afterwards
+func (p *RenamePairer) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopped = true
+	p.pending = nil
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
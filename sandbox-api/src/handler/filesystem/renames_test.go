@@ -0,0 +1,43 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestRenamePairer tests pairing the halves of a move, and emitting renames out of the
+// watched directories alone
+func TestRenamePairer(t *testing.T) {
+	events := make(chan WatchEvent, 10)
+	p := NewRenamePairer(func(event WatchEvent) {
+		events <- event
+	})
+	defer p.Stop()
+
+	p.Add(fsnotify.Event{Name: "/tmp/old", Op: fsnotify.Rename})
+	p.Add(fsnotify.Event{Name: "/tmp/new", Op: fsnotify.Create})
+	if event := <-events; event.Name != "/tmp/new" || event.Op != fsnotify.Create || event.From != "/tmp/old" {
+		t.Errorf("Expected a move from /tmp/old to /tmp/new, got %+v", event)
+	}
+
+	p.Add(fsnotify.Event{Name: "/tmp/gone", Op: fsnotify.Rename})
+	p.Add(fsnotify.Event{Name: "/tmp/other", Op: fsnotify.Write})
+	if event := <-events; event.Name != "/tmp/gone" || event.Op != fsnotify.Rename || event.From != "" {
+		t.Errorf("Expected the rename first, got %+v", event)
+	}
+	if event := <-events; event.Name != "/tmp/other" {
+		t.Errorf("Expected the write next, got %+v", event)
+	}
+
+	p.Add(fsnotify.Event{Name: "/tmp/away", Op: fsnotify.Rename})
+	select {
+	case event := <-events:
+		if event.Name != "/tmp/away" || event.Op != fsnotify.Rename {
+			t.Errorf("Expected the unpaired rename, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the unpaired rename to be emitted")
+	}
+}
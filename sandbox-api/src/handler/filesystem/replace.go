@@ -0,0 +1,204 @@
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
+)
+
+// maxReplaceFiles is the maximum number of files changed by a replace
+const maxReplaceFiles = 10000
+
+// ReplaceOptions are the options of a search and replace
+type ReplaceOptions struct {
+	Pattern     string
+	Replacement string
+	// Literal matches Pattern and inserts Replacement as they are, rather than as a regexp
+	// and a template expanding $1 and ${name}
+	Literal    bool
+	IgnoreCase bool
+	Include    []string
+	Exclude    []string
+	// DryRun reports the replacements without writing them
+	DryRun bool
+	// Holder is the lock holder the replacements are made by, see CheckLocks
+	Holder string
+}
+
+// ReplaceMatch is a match of a search and replace
+type ReplaceMatch struct {
+	Line int `json:"line" example:"12" binding:"required"`
+	// Column is the byte offset of the match in its line, starting at 1
+	Column      int    `json:"column" example:"6" binding:"required"`
+	Text        string `json:"text" example:"HandleRequest" binding:"required"`
+	Replacement string `json:"replacement" example:"ServeRequest" binding:"required"`
+} // @name ReplaceMatch
+
+// ReplaceFile is a file changed by a search and replace
+type ReplaceFile struct {
+	Path         string         `json:"path" example:"/home/user/app/main.go" binding:"required"`
+	Replacements int            `json:"replacements" example:"2" binding:"required"`
+	Matches      []ReplaceMatch `json:"matches" binding:"required"`
+	// Diff is the unified diff of the replacements in the file
+	Diff string `json:"diff" example:"--- a/home/user/app/main.go\n+++ b/home/user/app/main.go\n@@ -12,1 +12,1 @@\n-func HandleRequest() {\n+func ServeRequest() {" binding:"required"`
+} // @name ReplaceFile
+
+// ReplaceResult is the result of a search and replace
+type ReplaceResult struct {
+	Files        []ReplaceFile `json:"files" binding:"required"`
+	Replacements int           `json:"replacements" example:"2" binding:"required"`
+	// Applied is true when the replacements were written
+	Applied bool `json:"applied" example:"true"`
+	DryRun  bool `json:"dryRun" example:"false"`
+	// Truncated is true when the matches of the files stop after DefaultGrepMaxResults,
+	// replacements being counted and diffs produced for all of them
+	Truncated bool `json:"truncated" example:"false"`
+} // @name ReplaceResult
+
+// Replace replaces the matches of a regexp, or a literal string, in the file at root or
+// the files under it. Binary files and files larger than the grep limit are skipped.
+// The changed files are written with ApplyBatch, so they are all changed or none is.
+func (fs *Filesystem) Replace(root string, opts ReplaceOptions) (*ReplaceResult, error) {
+	if opts.Pattern == "" {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "pattern is required")
+	}
+	pattern := opts.Pattern
+	if opts.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid pattern: %v", err)
+	}
+
+	absRoot, err := fs.GetAbsolutePath(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplaceResult{Files: []ReplaceFile{}, DryRun: opts.DryRun}
+	var operations []BatchOperation
+	listed := 0
+	replace := func(path string) error {
+		content, mode, ok := readReplaceFile(path)
+		if !ok {
+			return nil
+		}
+		updated, file := replaceContent(path, content, re, opts, DefaultGrepMaxResults-listed)
+		if file == nil {
+			return nil
+		}
+		if len(result.Files) >= maxReplaceFiles {
+			return apierror.Newf(apierror.CodeUnprocessable, "more than %d files would change, narrow the search with include or exclude", maxReplaceFiles)
+		}
+		listed += len(file.Matches)
+		result.Truncated = result.Truncated || len(file.Matches) < file.Replacements
+		result.Replacements += file.Replacements
+		result.Files = append(result.Files, *file)
+		operations = append(operations, BatchOperation{Operation: BatchWrite, Path: path, Content: updated, Permissions: fmt.Sprintf("%04o", mode.Perm())})
+		return nil
+	}
+	if info.IsDir() {
+		err = fs.walkFiles(absRoot, opts.Include, opts.Exclude, replace)
+	} else {
+		err = replace(absRoot)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun || len(operations) == 0 {
+		return result, nil
+	}
+	if err := fs.CheckBatchLocks(opts.Holder, operations); err != nil {
+		return nil, err
+	}
+	if err := fs.ApplyBatch(operations); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// readReplaceFile reads a file to search, returning false when it is binary, too large
+// or unreadable
+func readReplaceFile(path string) (string, os.FileMode, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() > maxGrepFileSize {
+		return "", 0, false
+	}
+	reader := bufio.NewReader(file)
+	if isBinary(reader) {
+		return "", 0, false
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", 0, false
+	}
+	return string(content), info.Mode(), true
+}
+
+// replaceContent replaces the matches of re in the content of the file at path. It
+// returns the updated content and the file with at most maxMatches of its matches, or
+// nil when nothing matches.
+func replaceContent(path string, content string, re *regexp.Regexp, opts ReplaceOptions, maxMatches int) (string, *ReplaceFile) {
+	locations := re.FindAllStringSubmatchIndex(content, -1)
+	if len(locations) == 0 {
+		return content, nil
+	}
+
+	file := &ReplaceFile{Path: path, Replacements: len(locations), Matches: []ReplaceMatch{}}
+	var updated strings.Builder
+	// line is the line of content[lineStart:], the start of the line of the last match
+	line, lineStart, last := 1, 0, 0
+	for _, location := range locations {
+		replacement := opts.Replacement
+		if !opts.Literal {
+			replacement = string(re.ExpandString(nil, opts.Replacement, content, location))
+		}
+		updated.WriteString(content[last:location[0]])
+		updated.WriteString(replacement)
+		last = location[1]
+
+		if len(file.Matches) < maxMatches {
+			line += strings.Count(content[lineStart:location[0]], "\n")
+			if i := strings.LastIndexByte(content[lineStart:location[0]], '\n'); i >= 0 {
+				lineStart += i + 1
+			}
+			file.Matches = append(file.Matches, ReplaceMatch{
+				Line:        line,
+				Column:      location[0] - lineStart + 1,
+				Text:        content[location[0]:location[1]],
+				Replacement: replacement,
+			})
+		}
+	}
+	updated.WriteString(content[last:])
+	if updated.String() == content {
+		return content, nil
+	}
+
+	name := filepath.ToSlash(strings.TrimPrefix(path, "/"))
+	file.Diff, _ = codegen.Unified("a/"+name, "b/"+name, content, updated.String(), DefaultDiffContext)
+	return updated.String(), file
+}
@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestReplace tests regexp and literal replacements, their preview and their application
+func TestReplace(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for path, content := range map[string]string{
+		"src/handler.go":        "package src\n\nfunc HandleRequest() {}\n\nvar _ = HandleRequest\n",
+		"src/handler_test.go":   "package src\n\n// HandleRequest is tested\n",
+		"src/vendor/lib.go":     "HandleRequest\n",
+		"README.md":             "Call HandleRequest.\n",
+		"src/image.bin":         "HandleRequest\x00",
+		"src/config/app.yaml":   "port: 8080\nhost: localhost:8080\n",
+		"src/config/other.yaml": "port: 9090\n",
+	} {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	read := func(path string) string {
+		content, _ := os.ReadFile(filepath.Join(tempDir, path))
+		return string(content)
+	}
+
+	opts := ReplaceOptions{
+		Pattern:     `Handle(\w+)`,
+		Replacement: "Serve$1",
+		Include:     []string{"*.go"},
+		Exclude:     []string{"vendor"},
+		DryRun:      true,
+	}
+	result, err := fs.Replace("src", opts)
+	if err != nil {
+		t.Fatalf("Failed to preview replacements: %v", err)
+	}
+	if result.Applied || len(result.Files) != 2 || result.Replacements != 3 {
+		t.Fatalf("Unexpected preview %+v", result)
+	}
+	match := result.Files[0].Matches[1]
+	if match.Line != 5 || match.Column != 9 || match.Text != "HandleRequest" || match.Replacement != "ServeRequest" {
+		t.Errorf("Unexpected match %+v", match)
+	}
+	if !strings.Contains(result.Files[0].Diff, "-func HandleRequest() {}\n+func ServeRequest() {}\n") {
+		t.Errorf("Unexpected diff:\n%s", result.Files[0].Diff)
+	}
+	if strings.Contains(read("src/handler.go"), "ServeRequest") {
+		t.Fatal("Expected a dry run to write nothing")
+	}
+
+	opts.DryRun = false
+	if result, err = fs.Replace("src", opts); err != nil || !result.Applied {
+		t.Fatalf("Failed to apply replacements: %+v (%v)", result, err)
+	}
+	if read("src/handler.go") != "package src\n\nfunc ServeRequest() {}\n\nvar _ = ServeRequest\n" || read("src/handler_test.go") != "package src\n\n// ServeRequest is tested\n" {
+		t.Errorf("Unexpected files:\n%s\n%s", read("src/handler.go"), read("src/handler_test.go"))
+	}
+	if read("src/vendor/lib.go") != "HandleRequest\n" || read("README.md") != "Call HandleRequest.\n" || read("src/image.bin") != "HandleRequest\x00" {
+		t.Error("Expected excluded, not included and binary files to be unchanged")
+	}
+
+	// Literal replacements do not expand $ nor match regexp syntax
+	result, err = fs.Replace("src/config/app.yaml", ReplaceOptions{Pattern: "localhost:8080", Replacement: "$HOST", Literal: true})
+	if err != nil || result.Replacements != 1 || read("src/config/app.yaml") != "port: 8080\nhost: $HOST\n" {
+		t.Errorf("Unexpected literal replacement %+v (%v): %q", result, err, read("src/config/app.yaml"))
+	}
+
+	if result, err := fs.Replace("src", ReplaceOptions{Pattern: "nothing matches this"}); err != nil || len(result.Files) != 0 || result.Applied {
+		t.Errorf("Expected no replacement, got %+v (%v)", result, err)
+	}
+	if _, err := fs.Replace("src", ReplaceOptions{Pattern: "("}); !errors.Is(err, apierror.New(apierror.CodeInvalidRequest, "")) {
+		t.Errorf("Expected an invalid pattern to be rejected, got %v", err)
+	}
+}
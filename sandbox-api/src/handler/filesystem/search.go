@@ -0,0 +1,204 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Search types supported by Search
+const (
+	SearchTypeName    = "name"
+	SearchTypeContent = "content"
+)
+
+// DefaultSearchMaxResults is the number of matches returned when no limit is given
+const DefaultSearchMaxResults = 100
+
+// maxSearchLineLength caps the line text returned with content matches
+const maxSearchLineLength = 500
+
+// binarySniffLength is the number of leading bytes checked to detect binary files
+const binarySniffLength = 8000
+
+// SearchOptions are the options of a file search
+type SearchOptions struct {
+	Query      string
+	Type       string
+	Include    []string
+	Exclude    []string
+	MaxResults int
+}
+
+// SearchMatch is a file matching a search, with the matching line for content searches
+type SearchMatch struct {
+	Path string `json:"path" example:"/home/user/app/src/main.go" binding:"required"`
+	Line int    `json:"line,omitempty" example:"12"`
+	Text string `json:"text,omitempty" example:"func main() {"`
+} // @name SearchMatch
+
+// SearchResult is the result of a file search
+type SearchResult struct {
+	Query     string        `json:"query" example:"main" binding:"required"`
+	Type      string        `json:"type" example:"name" binding:"required"`
+	Matches   []SearchMatch `json:"matches" binding:"required"`
+	Truncated bool          `json:"truncated" example:"false"`
+} // @name SearchResult
+
+// Search looks for files under root whose name fuzzy matches the query, or whose
+// content contains it (case insensitive), depending on the search type
+func (fs *Filesystem) Search(root string, opts SearchOptions) (*SearchResult, error) {
+	if opts.Query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if opts.Type == "" {
+		opts.Type = SearchTypeName
+	}
+	if opts.Type != SearchTypeName && opts.Type != SearchTypeContent {
+		return nil, fmt.Errorf("unsupported search type '%s', expected 'name' or 'content'", opts.Type)
+	}
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultSearchMaxResults
+	}
+
+	result := &SearchResult{
+		Query:   opts.Query,
+		Type:    opts.Type,
+		Matches: []SearchMatch{},
+	}
+	query := strings.ToLower(opts.Query)
+
+	err := fs.walkFiles(root, opts.Include, opts.Exclude, func(path string) error {
+		if opts.Type == SearchTypeName {
+			if FuzzyMatch(strings.ToLower(filepath.Base(path)), query) {
+				result.Matches = append(result.Matches, SearchMatch{Path: path})
+			}
+		} else {
+			searchFileContent(path, query, opts.MaxResults-len(result.Matches), result)
+		}
+		if len(result.Matches) >= opts.MaxResults {
+			result.Truncated = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// searchFileContent appends up to limit lines of the file at path containing query
+func searchFileContent(path string, query string, limit int, result *SearchResult) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if isBinary(reader) {
+		return
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	found := 0
+	for line := 1; scanner.Scan() && found < limit; line++ {
+		text := scanner.Text()
+		if !strings.Contains(strings.ToLower(text), query) {
+			continue
+		}
+		if len(text) > maxSearchLineLength {
+			text = text[:maxSearchLineLength]
+		}
+		result.Matches = append(result.Matches, SearchMatch{Path: path, Line: line, Text: text})
+		found++
+	}
+}
+
+// isBinary reports whether the first bytes of the content contain a NUL byte,
+// the same heuristic git and ripgrep use
+func isBinary(reader *bufio.Reader) bool {
+	head, err := reader.Peek(binarySniffLength)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return true
+	}
+	return bytes.IndexByte(head, 0) != -1
+}
+
+// walkFiles calls fn with the absolute path of every regular file under root which
+// matches one of the include globs, if any, and none of the exclude globs. Excluded
+// directories are skipped entirely. Unreadable entries are ignored.
+func (fs *Filesystem) walkFiles(root string, include []string, exclude []string, fn func(path string) error) error {
+	absRoot, err := fs.GetAbsolutePath(root)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(absRoot)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDirectory
+	}
+
+	return filepath.WalkDir(absRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == absRoot {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(absRoot, path)
+		if matchAnyGlob(exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(include) > 0 && !matchAnyGlob(include, rel) {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// matchAnyGlob reports whether one of the glob patterns matches the relative path
+// or its base name, so "*.go" matches at any depth and "src/*.go" only in src
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FuzzyMatch checks if query characters appear in order in the text
+func FuzzyMatch(text, query string) bool {
+	textIdx := 0
+	for _, char := range query {
+		found := false
+		for textIdx < len(text) {
+			if rune(text[textIdx]) == char {
+				found = true
+				textIdx++
+				break
+			}
+			textIdx++
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
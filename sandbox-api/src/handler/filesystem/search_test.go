@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestSearch tests name and content searches with include and exclude globs
+func TestSearch(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	files := map[string]string{
+		"project/main.go":                   "package main\n\nfunc main() {\n\tHandleRequest()\n}\n",
+		"project/handler.go":                "package main\n\nfunc HandleRequest() {}\n",
+		"project/README.md":                 "Call handleRequest to serve\n",
+		"project/node_modules/lib/index.js": "handleRequest()\n",
+		"project/image.bin":                 "handle\x00request",
+	}
+	for path, content := range files {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	t.Run("Name", func(t *testing.T) {
+		result, err := fs.Search("project", SearchOptions{Query: "hdlr", Type: SearchTypeName})
+		if err != nil {
+			t.Fatalf("Failed to search: %v", err)
+		}
+		if len(result.Matches) != 1 || filepath.Base(result.Matches[0].Path) != "handler.go" {
+			t.Errorf("Expected handler.go to match, got %+v", result.Matches)
+		}
+	})
+
+	t.Run("Content", func(t *testing.T) {
+		result, err := fs.Search("project", SearchOptions{
+			Query:   "handlerequest",
+			Type:    SearchTypeContent,
+			Exclude: []string{"node_modules"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to search: %v", err)
+		}
+		if len(result.Matches) != 3 {
+			t.Fatalf("Expected 3 matches outside node_modules and binaries, got %+v", result.Matches)
+		}
+		for _, match := range result.Matches {
+			if filepath.Base(match.Path) == "main.go" && (match.Line != 4 || match.Text != "\tHandleRequest()") {
+				t.Errorf("Unexpected match in main.go: %+v", match)
+			}
+		}
+	})
+
+	t.Run("IncludeAndLimit", func(t *testing.T) {
+		result, err := fs.Search("project", SearchOptions{
+			Query:      "package",
+			Type:       SearchTypeContent,
+			Include:    []string{"*.go"},
+			MaxResults: 1,
+		})
+		if err != nil {
+			t.Fatalf("Failed to search: %v", err)
+		}
+		if len(result.Matches) != 1 || !result.Truncated {
+			t.Errorf("Expected a single truncated match, got %+v", result)
+		}
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		if _, err := fs.Search("project", SearchOptions{Query: "main", Type: "semantic"}); err == nil {
+			t.Error("Expected error for an unsupported search type, but got none")
+		}
+	})
+}
@@ -0,0 +1,417 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+)
+
+// ErrSnapshotNotFound is returned for an unknown snapshot id
+var ErrSnapshotNotFound = apierror.New(apierror.CodeFSSnapshotNotFound, "snapshot not found")
+
+// Snapshot is a point-in-time copy of a directory
+type Snapshot struct {
+	ID        string    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	Name      string    `json:"name,omitempty" example:"before-refactor"`
+	Path      string    `json:"path" example:"/home/user/app" binding:"required"`
+	CreatedAt time.Time `json:"createdAt" binding:"required"`
+	Files     int       `json:"files" example:"42" binding:"required"`
+	Size      int64     `json:"size" example:"1048576" binding:"required"`
+} // @name Snapshot
+
+// SnapshotManager captures and restores directory snapshots. Each snapshot is a full copy
+// of the directory in which files unchanged since the previous snapshot of the same
+// directory are hard links to that snapshot, so only changed files take up space.
+// Snapshot files are never written in place, which keeps the shared inodes immutable.
+type SnapshotManager struct {
+	snapshots    map[string]*Snapshot
+	snapshotsDir string
+	mu           sync.RWMutex
+}
+
+// NewSnapshotManager creates a snapshot manager storing snapshots in snapshotsDir
+func NewSnapshotManager(snapshotsDir string) *SnapshotManager {
+	return &SnapshotManager{
+		snapshots:    make(map[string]*Snapshot),
+		snapshotsDir: snapshotsDir,
+	}
+}
+
+// dataDir returns the directory holding the files of a snapshot
+func (m *SnapshotManager) dataDir(id string) string {
+	return filepath.Join(m.snapshotsDir, id, "data")
+}
+
+// skip reports whether path is the snapshots directory itself, which is never captured
+// nor removed when it is inside the directory being snapshotted
+func (m *SnapshotManager) skip(path string) bool {
+	return path == m.snapshotsDir
+}
+
+// CreateSnapshot captures the directory at the absolute path absPath, publishing a
+// snapshot.created event
+func (m *SnapshotManager) CreateSnapshot(absPath string, name string) (*Snapshot, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a directory")
+	}
+
+	snapshot := &Snapshot{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Path:      absPath,
+		CreatedAt: time.Now(),
+	}
+
+	// Unchanged files are linked to the latest snapshot of the same directory
+	previousDir := ""
+	if previous := m.latest(absPath); previous != nil {
+		previousDir = m.dataDir(previous.ID)
+	}
+
+	dataDir := m.dataDir(snapshot.ID)
+	err = filepath.WalkDir(absPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if m.skip(path) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(absPath, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dataDir, rel)
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(dst, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+			return copyOwner(dst, info)
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(target, dst); err != nil {
+				return err
+			}
+			return copyOwner(dst, info)
+		case info.Mode().IsRegular():
+			snapshot.Files++
+			snapshot.Size += info.Size()
+			if previousDir != "" {
+				linked, err := linkIfUnchanged(filepath.Join(previousDir, rel), dst, info)
+				if err != nil || linked {
+					return err
+				}
+			}
+			return copyFile(path, dst, info)
+		}
+		// Sockets, devices and pipes are not captured
+		return nil
+	})
+	if err == nil {
+		err = m.saveMetadata(snapshot)
+	}
+	if err != nil {
+		_ = os.RemoveAll(filepath.Join(m.snapshotsDir, snapshot.ID))
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	m.mu.Lock()
+	m.snapshots[snapshot.ID] = snapshot
+	m.mu.Unlock()
+	events.Publish(events.SnapshotCreated, snapshot.ID, snapshot)
+	return snapshot, nil
+}
+
+// RestoreSnapshot brings the snapshotted directory back to its captured state: entries
+// created since are removed, and changed or deleted files are copied back from the snapshot
+// along with their owner
+func (m *SnapshotManager) RestoreSnapshot(id string) (*Snapshot, error) {
+	snapshot, err := m.GetSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	dataDir := m.dataDir(id)
+
+	// Never empty the directory because the snapshot files went missing
+	if _, err := os.Stat(dataDir); err != nil {
+		return nil, fmt.Errorf("snapshot files are unavailable: %w", err)
+	}
+	if err := os.MkdirAll(snapshot.Path, 0755); err != nil {
+		return nil, err
+	}
+
+	// Remove entries missing from the snapshot or whose type changed
+	err = filepath.WalkDir(snapshot.Path, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == snapshot.Path {
+			return nil
+		}
+		if m.skip(path) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(snapshot.Path, path)
+		if err != nil {
+			return err
+		}
+		captured, err := os.Lstat(filepath.Join(dataDir, rel))
+		if err == nil && captured.Mode().Type() == d.Type() {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	// Copy back everything that differs from the snapshot
+	err = filepath.WalkDir(dataDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(snapshot.Path, rel)
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return err
+			}
+			if err := copyOwner(dst, info); err != nil {
+				return err
+			}
+			return os.Chmod(dst, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if current, err := os.Readlink(dst); err == nil && current == target {
+				return nil
+			}
+			_ = os.Remove(dst)
+			if err := os.Symlink(target, dst); err != nil {
+				return err
+			}
+			return copyOwner(dst, info)
+		default:
+			if current, err := os.Lstat(dst); err == nil && sameContent(current, info) {
+				return nil
+			}
+			// Copy rather than link so that later writes don't alter the snapshot
+			return copyFile(path, dst, info)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// GetSnapshot returns the snapshot with the given id
+func (m *SnapshotManager) GetSnapshot(id string) (*Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot, exists := m.snapshots[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots returns all snapshots, oldest first
+func (m *SnapshotManager) ListSnapshots() []*Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshots := make([]*Snapshot, 0, len(m.snapshots))
+	for _, snapshot := range m.snapshots {
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots
+}
+
+// DeleteSnapshot removes a snapshot and its files
+func (m *SnapshotManager) DeleteSnapshot(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.snapshots[id]; !exists {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	if err := os.RemoveAll(filepath.Join(m.snapshotsDir, id)); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	delete(m.snapshots, id)
+	return nil
+}
+
+// latest returns the most recent snapshot of the directory at absPath, if any
+func (m *SnapshotManager) latest(absPath string) *Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *Snapshot
+	for _, snapshot := range m.snapshots {
+		if snapshot.Path == absPath && (latest == nil || snapshot.CreatedAt.After(latest.CreatedAt)) {
+			latest = snapshot
+		}
+	}
+	return latest
+}
+
+// saveMetadata saves snapshot metadata to disk
+func (m *SnapshotManager) saveMetadata(snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	metadataPath := filepath.Join(m.snapshotsDir, snapshot.ID, "metadata.json")
+	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshots loads all snapshot metadata from disk
+func (m *SnapshotManager) LoadSnapshots() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries, err := os.ReadDir(m.snapshotsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read snapshots directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.snapshotsDir, entry.Name(), "metadata.json"))
+		if err != nil {
+			// Skip incomplete snapshots
+			continue
+		}
+
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			// Skip corrupted snapshots
+			continue
+		}
+
+		m.snapshots[snapshot.ID] = &snapshot
+	}
+
+	return nil
+}
+
+// sameContent reports whether two regular files are assumed identical from their size,
+// modification time and mode, the way rsync does
+func sameContent(a os.FileInfo, b os.FileInfo) bool {
+	return a.Mode() == b.Mode() && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// linkIfUnchanged hard links dst to previous if previous holds the same content as info
+func linkIfUnchanged(previous string, dst string, info os.FileInfo) (bool, error) {
+	previousInfo, err := os.Lstat(previous)
+	if err != nil || !sameContent(previousInfo, info) {
+		return false, nil
+	}
+	if err := os.Link(previous, dst); err != nil {
+		// Fall back to a copy, e.g. when the link count limit is reached
+		return false, nil
+	}
+	return true, nil
+}
+
+// copyFile copies the regular file at src to dst, preserving its owner, mode and
+// modification time
+func copyFile(src string, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Write to a new inode so hard links to the previous file are left untouched
+	tmp := dst + ".snapshot-tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := copyOwner(tmp, info); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// SnapshotsDirFromEnv returns the directory snapshots are stored in, read from
+// SNAPSHOTS_DIR and defaulting to a directory under the system temp dir
+func SnapshotsDirFromEnv() string {
+	if dir := os.Getenv("SNAPSHOTS_DIR"); dir != "" {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(os.TempDir(), "sandbox-snapshots")
+}
@@ -0,0 +1,104 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSnapshots tests snapshot creation, file sharing between snapshots and restore
+func TestSnapshots(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for path, content := range map[string]string{
+		"app/main.go":      "package main",
+		"app/pkg/util.go":  "package pkg",
+		"app/README.md":    "# App",
+		"app/data/raw.csv": "a,b",
+	} {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	appDir := filepath.Join(tempDir, "app")
+	manager := NewSnapshotManager(filepath.Join(tempDir, "snapshots"))
+
+	first, err := manager.CreateSnapshot(appDir, "before")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if first.Files != 4 {
+		t.Errorf("Expected 4 files in the snapshot, got %d", first.Files)
+	}
+
+	// Writing in place must not alter the snapshot
+	if err := os.WriteFile(filepath.Join(appDir, "main.go"), []byte("package broken"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(manager.dataDir(first.ID), "main.go"))
+	if err != nil || string(content) != "package main" {
+		t.Errorf("Expected snapshot content to be unchanged, got %q (%v)", content, err)
+	}
+
+	// Unchanged files are shared with the previous snapshot
+	second, err := manager.CreateSnapshot(appDir, "")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	firstReadme, _ := os.Stat(filepath.Join(manager.dataDir(first.ID), "README.md"))
+	secondReadme, _ := os.Stat(filepath.Join(manager.dataDir(second.ID), "README.md"))
+	if !os.SameFile(firstReadme, secondReadme) {
+		t.Error("Expected unchanged file to be hard linked between snapshots")
+	}
+	firstMain, _ := os.Stat(filepath.Join(manager.dataDir(first.ID), "main.go"))
+	secondMain, _ := os.Stat(filepath.Join(manager.dataDir(second.ID), "main.go"))
+	if os.SameFile(firstMain, secondMain) {
+		t.Error("Expected changed file to be copied")
+	}
+
+	// Restore removes new entries and brings back changed and deleted files
+	if err := os.RemoveAll(filepath.Join(appDir, "data")); err != nil {
+		t.Fatalf("Failed to delete directory: %v", err)
+	}
+	if err := fs.WriteFile("app/new.txt", []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := manager.RestoreSnapshot(first.ID); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	for path, expected := range map[string]string{
+		"main.go":      "package main",
+		"data/raw.csv": "a,b",
+		"README.md":    "# App",
+	} {
+		content, err := os.ReadFile(filepath.Join(appDir, path))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to be restored to %q, got %q (%v)", path, expected, content, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(appDir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected new.txt to be removed, got %v", err)
+	}
+
+	// Snapshots are reloaded from disk
+	reloaded := NewSnapshotManager(filepath.Join(tempDir, "snapshots"))
+	if err := reloaded.LoadSnapshots(); err != nil {
+		t.Fatalf("Failed to load snapshots: %v", err)
+	}
+	snapshots := reloaded.ListSnapshots()
+	if len(snapshots) != 2 || snapshots[0].ID != first.ID || snapshots[0].Name != "before" {
+		t.Errorf("Expected 2 snapshots oldest first, got %+v", snapshots)
+	}
+
+	if err := manager.DeleteSnapshot(first.ID); err != nil {
+		t.Fatalf("Failed to delete snapshot: %v", err)
+	}
+	if _, err := manager.RestoreSnapshot(first.ID); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Expected snapshot not found error, got %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(manager.dataDir(second.ID), "README.md")); err != nil || string(content) != "# App" {
+		t.Errorf("Expected files shared with a deleted snapshot to remain, got %q (%v)", content, err)
+	}
+}
@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checksum algorithms supported by Stat
+const (
+	ChecksumMD5    = "md5"
+	ChecksumSHA256 = "sha256"
+)
+
+// File types reported by Stat
+const (
+	FileTypeFile      = "file"
+	FileTypeDirectory = "directory"
+	FileTypeSymlink   = "symlink"
+	FileTypeOther     = "other"
+)
+
+// FileStat is the metadata of a file, directory or symlink
+type FileStat struct {
+	Path          string            `json:"path" example:"/home/user/app/main.go" binding:"required"`
+	Name          string            `json:"name" example:"main.go" binding:"required"`
+	Type          string            `json:"type" example:"file" enums:"file,directory,symlink,other" binding:"required"`
+	Size          int64             `json:"size" example:"1024" binding:"required"`
+	Permissions   string            `json:"permissions" example:"644" binding:"required"`
+	Mode          string            `json:"mode" example:"-rw-r--r--" binding:"required"`
+	LastModified  time.Time         `json:"lastModified" binding:"required"`
+	Owner         string            `json:"owner" example:"root" binding:"required"`
+	Group         string            `json:"group" example:"root" binding:"required"`
+	SymlinkTarget string            `json:"symlinkTarget,omitempty" example:"../shared/main.go"`
+	Checksums     map[string]string `json:"checksums,omitempty" example:"sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
+} // @name FileStat
+
+// Stat returns the metadata of the entry at path without following a final symlink,
+// and the content checksums of the requested algorithms. Checksums of a symlink are
+// computed on its target.
+func (fs *Filesystem) Stat(path string, checksums []string) (*FileStat, error) {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, group, err := fs.getFileOwnerAndGroup(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := &FileStat{
+		Path:         absPath,
+		Name:         filepath.Base(absPath),
+		Size:         info.Size(),
+		Permissions:  fmt.Sprintf("%o", info.Mode().Perm()),
+		Mode:         info.Mode().String(),
+		LastModified: info.ModTime(),
+		Owner:        owner,
+		Group:        group,
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		stat.Type = FileTypeSymlink
+		if stat.SymlinkTarget, err = os.Readlink(absPath); err != nil {
+			return nil, err
+		}
+	case info.IsDir():
+		stat.Type = FileTypeDirectory
+	case info.Mode().IsRegular():
+		stat.Type = FileTypeFile
+	default:
+		stat.Type = FileTypeOther
+	}
+
+	if len(checksums) > 0 {
+		if stat.Checksums, err = fileChecksums(absPath, checksums); err != nil {
+			return nil, err
+		}
+	}
+	return stat, nil
+}
+
+// fileChecksums computes the checksums of the file at path in a single read
+func fileChecksums(path string, algorithms []string) (map[string]string, error) {
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		if _, exists := hashes[algorithm]; exists {
+			continue
+		}
+		var h hash.Hash
+		switch algorithm {
+		case ChecksumMD5:
+			h = md5.New()
+		case ChecksumSHA256:
+			h = sha256.New()
+		default:
+			return nil, fmt.Errorf("unsupported checksum algorithm '%s', expected 'md5' or 'sha256'", algorithm)
+		}
+		hashes[algorithm] = h
+		writers = append(writers, h)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("checksums can only be computed for files")
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(hashes))
+	for algorithm, h := range hashes {
+		checksums[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return checksums, nil
+}
@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStat tests file, directory and symlink metadata and checksums
+func TestStat(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("data/hello.txt", []byte("hello"), 0640); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("hello.txt", filepath.Join(tempDir, "data", "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	t.Run("File", func(t *testing.T) {
+		stat, err := fs.Stat("data/hello.txt", []string{ChecksumMD5, ChecksumSHA256})
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		if stat.Type != FileTypeFile || stat.Size != 5 || stat.Permissions != "640" || stat.Name != "hello.txt" {
+			t.Errorf("Unexpected file metadata: %+v", stat)
+		}
+		if stat.Checksums[ChecksumMD5] != "5d41402abc4b2a76b9719d911017c592" {
+			t.Errorf("Unexpected md5 checksum: %s", stat.Checksums[ChecksumMD5])
+		}
+		if stat.Checksums[ChecksumSHA256] != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+			t.Errorf("Unexpected sha256 checksum: %s", stat.Checksums[ChecksumSHA256])
+		}
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		stat, err := fs.Stat("data/link", []string{ChecksumMD5})
+		if err != nil {
+			t.Fatalf("Failed to stat symlink: %v", err)
+		}
+		if stat.Type != FileTypeSymlink || stat.SymlinkTarget != "hello.txt" {
+			t.Errorf("Unexpected symlink metadata: %+v", stat)
+		}
+		if stat.Checksums[ChecksumMD5] != "5d41402abc4b2a76b9719d911017c592" {
+			t.Errorf("Expected the checksum of the symlink target, got %s", stat.Checksums[ChecksumMD5])
+		}
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		stat, err := fs.Stat("data", nil)
+		if err != nil {
+			t.Fatalf("Failed to stat directory: %v", err)
+		}
+		if stat.Type != FileTypeDirectory || stat.Checksums != nil {
+			t.Errorf("Unexpected directory metadata: %+v", stat)
+		}
+		if _, err := fs.Stat("data", []string{ChecksumSHA256}); err == nil {
+			t.Error("Expected error for a directory checksum, but got none")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := fs.Stat("data/missing", nil); !os.IsNotExist(err) {
+			t.Errorf("Expected a not exist error, got %v", err)
+		}
+		if _, err := fs.Stat("data/hello.txt", []string{"crc32"}); err == nil {
+			t.Error("Expected error for an unsupported checksum algorithm, but got none")
+		}
+	})
+}
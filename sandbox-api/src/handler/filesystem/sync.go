@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncEntry is a file of a sync manifest, identified by its path relative to the
+// synced directory and the sha256 checksum of its content
+type SyncEntry struct {
+	Path string `json:"path" example:"src/main.go" binding:"required"`
+	Hash string `json:"hash" example:"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" binding:"required"`
+} // @name SyncEntry
+
+// SyncPlan lists the files to upload and delete to make a directory match a manifest
+type SyncPlan struct {
+	Upload    []string `json:"upload" example:"src/main.go" binding:"required"`
+	Delete    []string `json:"delete" example:"src/old.go" binding:"required"`
+	Unchanged int      `json:"unchanged" example:"42" binding:"required"`
+} // @name SyncPlan
+
+// resolveSyncPath returns the absolute path of a file relative to the synced directory,
+// rejecting paths which would escape it
+func resolveSyncPath(root string, rel string) (string, error) {
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid sync path '%s': must be relative to the synced directory", rel)
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// PlanSync compares the files of the directory at path with a manifest. Files missing
+// or with a different checksum must be uploaded, and files which are not in the
+// manifest must be deleted. A missing directory is synced from scratch.
+func (fs *Filesystem) PlanSync(path string, manifest []SyncEntry) (*SyncPlan, error) {
+	root, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{Upload: []string{}, Delete: []string{}}
+	inManifest := make(map[string]bool, len(manifest))
+	for _, entry := range manifest {
+		absPath, err := resolveSyncPath(root, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		rel := filepath.Clean(entry.Path)
+		if inManifest[rel] {
+			continue
+		}
+		inManifest[rel] = true
+
+		checksums, err := fileChecksums(absPath, []string{ChecksumSHA256})
+		if err != nil || !strings.EqualFold(checksums[ChecksumSHA256], entry.Hash) {
+			plan.Upload = append(plan.Upload, rel)
+			continue
+		}
+		plan.Unchanged++
+	}
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return plan, nil
+	}
+	err = filepath.WalkDir(root, func(absPath string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, absPath)
+		if err == nil && !inManifest[rel] {
+			plan.Delete = append(plan.Delete, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(plan.Delete)
+	return plan, nil
+}
+
+// SyncWriteFile writes a file of a sync, given relative to the directory at path
+func (fs *Filesystem) SyncWriteFile(path string, rel string, r io.Reader) error {
+	root, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+	absPath, err := resolveSyncPath(root, rel)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFileFromReader(absPath, r, 0644)
+}
+
+// SyncDeleteFile deletes a file of a sync, given relative to the directory at path.
+// Deleting a file which does not exist is not an error.
+func (fs *Filesystem) SyncDeleteFile(path string, rel string) error {
+	root, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return err
+	}
+	absPath, err := resolveSyncPath(root, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSync tests sync planning against a manifest and applying the changes
+func TestSync(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	hash := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	for path, content := range map[string]string{
+		"app/main.go":    "package main\n",
+		"app/util.go":    "package util\n",
+		"app/old/old.go": "package old\n",
+	} {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	manifest := []SyncEntry{
+		{Path: "main.go", Hash: hash("package main\n")},
+		{Path: "util.go", Hash: hash("package util // changed\n")},
+		{Path: "new/new.go", Hash: hash("package new\n")},
+	}
+
+	plan, err := fs.PlanSync("app", manifest)
+	if err != nil {
+		t.Fatalf("Failed to plan sync: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Upload, []string{"util.go", "new/new.go"}) {
+		t.Errorf("Unexpected files to upload: %v", plan.Upload)
+	}
+	if !reflect.DeepEqual(plan.Delete, []string{"old/old.go"}) {
+		t.Errorf("Unexpected files to delete: %v", plan.Delete)
+	}
+	if plan.Unchanged != 1 {
+		t.Errorf("Expected 1 unchanged file, got %d", plan.Unchanged)
+	}
+
+	if err := fs.SyncWriteFile("app", "new/new.go", strings.NewReader("package new\n")); err != nil {
+		t.Fatalf("Failed to write synced file: %v", err)
+	}
+	if err := fs.SyncWriteFile("app", "util.go", strings.NewReader("package util // changed\n")); err != nil {
+		t.Fatalf("Failed to write synced file: %v", err)
+	}
+	if err := fs.SyncDeleteFile("app", "old/old.go"); err != nil {
+		t.Fatalf("Failed to delete synced file: %v", err)
+	}
+
+	plan, err = fs.PlanSync("app", manifest)
+	if err != nil {
+		t.Fatalf("Failed to plan sync: %v", err)
+	}
+	if len(plan.Upload) != 0 || len(plan.Delete) != 0 || plan.Unchanged != 3 {
+		t.Errorf("Expected the directory to be in sync, got %+v", plan)
+	}
+
+	// Paths escaping the synced directory are rejected
+	if err := fs.SyncWriteFile("app", "../escape.txt", strings.NewReader("x")); err == nil {
+		t.Error("Expected error for a path outside of the synced directory, but got none")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("Expected no file to be written outside of the synced directory")
+	}
+	if _, err := fs.PlanSync("app", []SyncEntry{{Path: "/etc/passwd", Hash: "x"}}); err == nil {
+		t.Error("Expected error for an absolute manifest path, but got none")
+	}
+
+	// A missing directory is synced from scratch
+	plan, err = fs.PlanSync("missing", manifest)
+	if err != nil {
+		t.Fatalf("Failed to plan sync: %v", err)
+	}
+	if len(plan.Upload) != 3 || len(plan.Delete) != 0 {
+		t.Errorf("Expected every file to be uploaded, got %+v", plan)
+	}
+}
@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TreeOptions are the options of a recursive directory listing
+type TreeOptions struct {
+	// MaxDepth is the number of levels listed, 0 means unlimited
+	MaxDepth int
+	// Globs filter the files listed by name or relative path, directories are kept
+	// only if they contain a matching file
+	Globs []string
+	// IncludeHidden lists the files and directories whose name starts with a dot
+	IncludeHidden bool
+}
+
+// ListDirectoryRecursive lists a directory and its subdirectories. The files and
+// subdirectories of each subdirectory are nested in it.
+func (fs *Filesystem) ListDirectoryRecursive(path string, opts TreeOptions) (*Directory, error) {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	displayPath := fs.ResolveDisplayPath(path)
+	dir := NewDirectory(displayPath)
+	dir.Files, dir.Subdirectories, err = fs.listTree(absPath, displayPath, "", 1, opts)
+	if err != nil {
+		return nil, err
+	}
+	return dir, nil
+}
+
+// listTree lists the entries of the directory at absPath, rel being its path relative
+// to the listed root and depth its level starting at 1
+func (fs *Filesystem) listTree(absPath string, displayPath string, rel string, depth int, opts TreeOptions) ([]*File, []*Subdirectory, error) {
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := []*File{}
+	subdirectories := []*Subdirectory{}
+	for _, entry := range entries {
+		if !opts.IncludeHidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		entryPath := filepath.Join(displayPath, entry.Name())
+		absEntryPath := filepath.Join(absPath, entry.Name())
+		entryRel := filepath.Join(rel, entry.Name())
+
+		// Use os.Lstat so symlinks are listed as files and never followed
+		info, err := os.Lstat(absEntryPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if info.IsDir() {
+			subDir := &Subdirectory{Path: entryPath, Name: entry.Name()}
+			if opts.MaxDepth == 0 || depth < opts.MaxDepth {
+				subDir.Files, subDir.Subdirectories, err = fs.listTree(absEntryPath, entryPath, entryRel, depth+1, opts)
+				if err != nil {
+					return nil, nil, err
+				}
+				// With globs, only directories leading to a matching file are kept
+				if len(opts.Globs) > 0 && len(subDir.Files) == 0 && len(subDir.Subdirectories) == 0 {
+					continue
+				}
+			}
+			subdirectories = append(subdirectories, subDir)
+			continue
+		}
+
+		if len(opts.Globs) > 0 && !matchAnyGlob(opts.Globs, entryRel) {
+			continue
+		}
+		owner, group, err := fs.getFileOwnerAndGroup(absEntryPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, &File{Path: entryPath, Name: entry.Name(), Permissions: fmt.Sprintf("%o", info.Mode()), Size: info.Size(), LastModified: info.ModTime(), Owner: owner, Group: group})
+	}
+	return files, subdirectories, nil
+}
+
+// errListLimit stops a file walk once the limit of listed files is reached
+var errListLimit = errors.New("list limit reached")
+
+// ListFiles returns the absolute paths of the files below a directory in lexical order,
+// skipping hidden entries and the ones matching the gitignore-style ignore patterns. At
+// most limit paths are returned (0 for no limit), truncated is set when there are more.
+func (fs *Filesystem) ListFiles(path string, ignore []string, limit int) ([]string, bool, error) {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	matcher := NewIgnoreMatcher(ignore)
+
+	files := []string{}
+	truncated := false
+	err = filepath.WalkDir(absPath, func(entryPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are skipped, the listed directory must be readable
+			if entryPath == absPath {
+				return err
+			}
+			return nil
+		}
+		if entryPath == absPath {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(absPath, entryPath)
+		if strings.HasPrefix(entry.Name(), ".") || matcher.Match(rel, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if limit > 0 && len(files) == limit {
+			truncated = true
+			return errListLimit
+		}
+		files = append(files, entryPath)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errListLimit) {
+		return nil, false, err
+	}
+	return files, truncated, nil
+}
@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestListDirectoryRecursive tests nesting, depth limits, globs and hidden entries
+func TestListDirectoryRecursive(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for _, path := range []string{
+		"project/main.go",
+		"project/README.md",
+		"project/.env",
+		"project/pkg/util/util.go",
+		"project/pkg/util/util_test.go",
+		"project/docs/guide.md",
+		"project/.git/HEAD",
+	} {
+		if err := fs.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		dir, err := fs.ListDirectoryRecursive("project", TreeOptions{IncludeHidden: true})
+		if err != nil {
+			t.Fatalf("Failed to list directory: %v", err)
+		}
+		if len(dir.Files) != 3 || len(dir.Subdirectories) != 3 {
+			t.Fatalf("Expected 3 files and 3 subdirectories, got %d and %d", len(dir.Files), len(dir.Subdirectories))
+		}
+		pkg := dir.GetSubdirectory("pkg")
+		if pkg == nil || len(pkg.Subdirectories) != 1 || len(pkg.Subdirectories[0].Files) != 2 {
+			t.Errorf("Expected pkg/util to be nested with 2 files, got %+v", pkg)
+		}
+	})
+
+	t.Run("MaxDepth", func(t *testing.T) {
+		dir, err := fs.ListDirectoryRecursive("project", TreeOptions{MaxDepth: 2})
+		if err != nil {
+			t.Fatalf("Failed to list directory: %v", err)
+		}
+		pkg := dir.GetSubdirectory("pkg")
+		if pkg == nil || len(pkg.Subdirectories) != 1 || pkg.Subdirectories[0].Files != nil {
+			t.Errorf("Expected pkg/util to be listed but not expanded, got %+v", pkg)
+		}
+	})
+
+	t.Run("GlobAndHidden", func(t *testing.T) {
+		dir, err := fs.ListDirectoryRecursive("project", TreeOptions{Globs: []string{"*.go"}})
+		if err != nil {
+			t.Fatalf("Failed to list directory: %v", err)
+		}
+		if len(dir.Files) != 1 || dir.Files[0].Name != "main.go" {
+			t.Errorf("Expected only main.go at the root, got %+v", dir.Files)
+		}
+		if len(dir.Subdirectories) != 1 || dir.Subdirectories[0].Name != "pkg" {
+			t.Errorf("Expected only pkg to contain matching files, got %+v", dir.Subdirectories)
+		}
+	})
+}
+
+// TestListFiles tests the flat listing of files with ignore patterns and a limit
+func TestListFiles(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	for _, path := range []string{
+		"project/main.go",
+		"project/.env",
+		"project/build/out.bin",
+		"project/pkg/util.go",
+		"project/pkg/util.log",
+	} {
+		if err := fs.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	files, truncated, err := fs.ListFiles("project", []string{"build/", "*.log"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if truncated || len(files) != 2 || !strings.HasSuffix(files[0], "project/main.go") || !strings.HasSuffix(files[1], "project/pkg/util.go") {
+		t.Errorf("Expected main.go and pkg/util.go, got %v (truncated: %v)", files, truncated)
+	}
+
+	files, truncated, err = fs.ListFiles("project", nil, 2)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if !truncated || len(files) != 2 {
+		t.Errorf("Expected 2 files and truncation, got %v (truncated: %v)", files, truncated)
+	}
+}
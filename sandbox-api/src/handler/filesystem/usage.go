@@ -0,0 +1,153 @@
+package filesystem
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// ErrQuotaExceeded is returned when a write would take the disk usage over the quota
+var ErrQuotaExceeded = apierror.New(apierror.CodeFSQuotaExceeded, "filesystem quota exceeded")
+
+// DirectoryUsage is the size of an entry of the directory whose usage is reported
+type DirectoryUsage struct {
+	Path string `json:"path" example:"/home/user/app/node_modules" binding:"required"`
+	Size int64  `json:"size" example:"104857600" binding:"required"`
+} // @name DirectoryUsage
+
+// DiskUsage is the usage of the disk holding a directory and the size of its entries
+type DiskUsage struct {
+	Path    string           `json:"path" example:"/home/user/app" binding:"required"`
+	Total   uint64           `json:"total" example:"10737418240" binding:"required"`
+	Used    uint64           `json:"used" example:"2147483648" binding:"required"`
+	Free    uint64           `json:"free" example:"8589934592" binding:"required"`
+	Quota   uint64           `json:"quota,omitempty" example:"5368709120"`
+	Entries []DirectoryUsage `json:"entries" binding:"required"`
+} // @name DiskUsage
+
+// DiskStats returns the total, used and available bytes of the disk holding path
+func DiskStats(path string) (total uint64, used uint64, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, 0, err
+	}
+	blockSize := uint64(stat.Bsize)
+	total = stat.Blocks * blockSize
+	free = stat.Bavail * blockSize
+	used = total - stat.Bfree*blockSize
+	return total, used, free, nil
+}
+
+// GetUsage returns the usage of the disk holding the directory at path and the size of
+// each of its entries, largest first. Sizes are apparent sizes and symlinks are not followed.
+func (fs *Filesystem) GetUsage(path string) (*DiskUsage, error) {
+	absPath, err := fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &DiskUsage{Path: absPath, Entries: make([]DirectoryUsage, 0, len(entries))}
+	if usage.Total, usage.Used, usage.Free, err = DiskStats(absPath); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(absPath, entry.Name())
+		usage.Entries = append(usage.Entries, DirectoryUsage{Path: entryPath, Size: treeSize(entryPath)})
+	}
+	sort.Slice(usage.Entries, func(i, j int) bool {
+		return usage.Entries[i].Size > usage.Entries[j].Size
+	})
+	return usage, nil
+}
+
+// treeSize returns the total size of the files under path, ignoring unreadable entries
+func treeSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// CheckQuota returns ErrQuotaExceeded if writing additional bytes would take the usage of
+// the disk holding the working directory over quota. A quota of 0 means no quota.
+func (fs *Filesystem) CheckQuota(quota uint64, additional int64) error {
+	if quota == 0 {
+		return nil
+	}
+	_, used, _, err := DiskStats(fs.GetWorkingDir())
+	if err != nil {
+		return err
+	}
+	if used+uint64(max(additional, 0)) > quota {
+		return fmt.Errorf("%w: %d bytes used of %d", ErrQuotaExceeded, used, quota)
+	}
+	return nil
+}
+
+// quotaReader fails with ErrQuotaExceeded once more than remaining bytes are read
+type quotaReader struct {
+	r         io.Reader
+	remaining int64
+	quota     uint64
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	q.remaining -= int64(n)
+	if q.remaining < 0 {
+		return n, fmt.Errorf("%w: writing more than %d bytes would exceed it", ErrQuotaExceeded, q.quota)
+	}
+	return n, err
+}
+
+// LimitToQuota returns a reader failing with ErrQuotaExceeded once writing what it read
+// would take the usage of the disk over quota, for writes whose size is not known in
+// advance. A quota of 0 means no quota.
+func (fs *Filesystem) LimitToQuota(quota uint64, r io.Reader) (io.Reader, error) {
+	if quota == 0 {
+		return r, nil
+	}
+	_, used, _, err := DiskStats(fs.GetWorkingDir())
+	if err != nil {
+		return nil, err
+	}
+	if used >= quota {
+		return nil, fmt.Errorf("%w: %d bytes used of %d", ErrQuotaExceeded, used, quota)
+	}
+	return &quotaReader{r: r, remaining: int64(quota - used), quota: quota}, nil
+}
+
+// QuotaFromEnv returns the disk quota in bytes read from FILESYSTEM_QUOTA_BYTES, 0 if unset
+func QuotaFromEnv() uint64 {
+	value := os.Getenv("FILESYSTEM_QUOTA_BYTES")
+	if value == "" {
+		return 0
+	}
+	quota, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		logrus.Warnf("Invalid FILESYSTEM_QUOTA_BYTES value '%s', quota disabled", value)
+		return 0
+	}
+	return quota
+}
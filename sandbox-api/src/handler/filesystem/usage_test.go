@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGetUsage tests disk usage reporting and quota checks
+func TestGetUsage(t *testing.T) {
+	tempDir, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := fs.WriteFile("big/data.bin", make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := fs.WriteFile("small.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	usage, err := fs.GetUsage(".")
+	if err != nil {
+		t.Fatalf("Failed to get usage: %v", err)
+	}
+	if usage.Total == 0 || usage.Used > usage.Total {
+		t.Errorf("Unexpected disk stats: %+v", usage)
+	}
+	if len(usage.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", usage.Entries)
+	}
+	if usage.Entries[0].Path != filepath.Join(tempDir, "big") || usage.Entries[0].Size != 4096 {
+		t.Errorf("Expected big to be the largest entry with 4096 bytes, got %+v", usage.Entries[0])
+	}
+	if usage.Entries[1].Size != 5 {
+		t.Errorf("Expected small.txt to have 5 bytes, got %+v", usage.Entries[1])
+	}
+
+	if err := fs.CheckQuota(0, 1<<40); err != nil {
+		t.Errorf("Expected no quota check without a quota, got %v", err)
+	}
+	if err := fs.CheckQuota(usage.Used+usage.Free, 0); err != nil {
+		t.Errorf("Expected write to be allowed under the quota, got %v", err)
+	}
+	if err := fs.CheckQuota(1, 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected quota exceeded error, got %v", err)
+	}
+}
+
+// TestLimitToQuota tests failing writes of unknown size once they exceed the quota
+func TestLimitToQuota(t *testing.T) {
+	_, fs, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	usage, err := fs.GetUsage(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.LimitToQuota(1, strings.NewReader("data")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected quota exceeded error, got %v", err)
+	}
+
+	// The disk may be used by others meanwhile, a margin of 1MiB is left
+	r, err := fs.LimitToQuota(usage.Used+1<<20, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFileFromReader("fits.txt", r, 0644); err != nil {
+		t.Errorf("Expected the write to fit in the quota, got %v", err)
+	}
+	r, err = fs.LimitToQuota(usage.Used+1<<20, bytes.NewReader(make([]byte, 2<<20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFileFromReader("exceeds.txt", r, 0644); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected quota exceeded error, got %v", err)
+	}
+}
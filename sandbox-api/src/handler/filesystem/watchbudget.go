@@ -0,0 +1,263 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultWatchBudget is the watch budget when the kernel limit cannot be read, the
+	// default limit of most kernels
+	defaultWatchBudget = 8192
+	// maxUserWatchesPath holds the kernel limit of inotify watches of a user
+	maxUserWatchesPath = "/proc/sys/fs/inotify/max_user_watches"
+)
+
+// watchPollInterval is the interval between polls of the directories beyond the watch
+// budget
+var watchPollInterval = 2 * time.Second
+
+// Directories watched with inotify by all the watches, their limit, and the directory
+// trees polled instead
+var (
+	watchesUsed    atomic.Int64
+	watchLimit     atomic.Int64
+	watchLimitOnce sync.Once
+	treesPolled    atomic.Int64
+)
+
+// WatchUsage is the number of directories watched with inotify by all the watches, the
+// budget they share, and the number of directory trees polled beyond the budget
+type WatchUsage struct {
+	Watched int64
+	Budget  int64
+	Polled  int64
+}
+
+// GetWatchUsage returns the current usage of the watch budget
+func GetWatchUsage() WatchUsage {
+	return WatchUsage{
+		Watched: watchesUsed.Load(),
+		Budget:  watchBudget(),
+		Polled:  treesPolled.Load(),
+	}
+}
+
+// WatchBudgetFromEnv returns the number of directories watches can watch with inotify
+// together, read from WATCH_MAX_DIRECTORIES, by default 90% of the inotify watches the
+// kernel allows, the rest being left to the other programs of the sandbox
+func WatchBudgetFromEnv() int64 {
+	if value := os.Getenv("WATCH_MAX_DIRECTORIES"); value != "" {
+		budget, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && budget > 0 {
+			return budget
+		}
+		logrus.Warnf("Invalid WATCH_MAX_DIRECTORIES value '%s', using the kernel limit", value)
+	}
+
+	content, err := os.ReadFile(maxUserWatchesPath)
+	if err != nil {
+		return defaultWatchBudget
+	}
+	limit, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil || limit <= 0 {
+		return defaultWatchBudget
+	}
+	return max(limit*9/10, 1)
+}
+
+// watchBudget returns the watch budget, read from the environment on first use
+func watchBudget() int64 {
+	watchLimitOnce.Do(func() {
+		watchLimit.Store(WatchBudgetFromEnv())
+	})
+	return watchLimit.Load()
+}
+
+// recursiveWatch is the state of a WatchDirectoryRecursive watch: the number of
+// directories it watches with inotify and the trees it polls. It is only used by the
+// goroutine of the watch once started.
+type recursiveWatch struct {
+	watcher *fsnotify.Watcher
+	skip    func(dir string) bool
+	onError func(err error)
+	poller  *treePoller
+	// count is the number of directories of the watch counted in watchesUsed
+	count int64
+	// dirty is set when the watcher may have dropped directories, removed or moved
+	dirty bool
+	// exhausted is set once the client was told about the polled trees
+	exhausted bool
+}
+
+// addDirs watches root and its subdirectories, except the skipped ones. Directories
+// beyond the watch budget are polled with their subdirectories. created tells whether
+// root was just created, its content not having been reported yet.
+func (w *recursiveWatch) addDirs(root string, created bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.skip != nil && w.skip(path) {
+			return filepath.SkipDir
+		}
+
+		if watchesUsed.Load() >= watchBudget() {
+			w.poll(path, created)
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				// The kernel limit is reached, other programs using watches as well
+				w.poll(path, created)
+				return filepath.SkipDir
+			}
+			return err
+		}
+		w.count++
+		watchesUsed.Add(1)
+		return nil
+	})
+}
+
+// poll polls a tree beyond the watch budget. The client is told the first time only,
+// a large tree can have many trees polled.
+func (w *recursiveWatch) poll(dir string, created bool) {
+	if w.poller.covers(dir) {
+		return
+	}
+	w.poller.add(dir, !created)
+	treesPolled.Add(1)
+	if !w.exhausted {
+		w.exhausted = true
+		w.onError(fmt.Errorf("inotify watch budget of %d directories exhausted, %s and the directories beyond the budget are polled every %s", watchBudget(), dir, watchPollInterval))
+	}
+}
+
+// sync recounts the directories of the watch, which drops the directories removed or
+// moved away
+func (w *recursiveWatch) sync() {
+	count := int64(len(w.watcher.WatchList()))
+	watchesUsed.Add(count - w.count)
+	w.count = count
+	w.dirty = false
+}
+
+// release gives back the budget of the watch once it is stopped
+func (w *recursiveWatch) release() {
+	watchesUsed.Add(-w.count)
+	w.count = 0
+	treesPolled.Add(-int64(len(w.poller.roots)))
+}
+
+// fileState is the state of a polled file compared between polls
+type fileState struct {
+	modTime int64
+	size    int64
+	isDir   bool
+}
+
+// treePoller detects the changes of directory trees by comparing their content between
+// polls
+type treePoller struct {
+	skip  func(dir string) bool
+	roots []string
+	files map[string]fileState
+}
+
+// newTreePoller returns a poller of no tree, skipping the directories skip returns
+// true for
+func newTreePoller(skip func(dir string) bool) *treePoller {
+	return &treePoller{skip: skip, files: make(map[string]fileState)}
+}
+
+// covers reports whether dir is in one of the polled trees
+func (p *treePoller) covers(dir string) bool {
+	for _, root := range p.roots {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// add polls the tree at root. With baseline set, its current content is not reported
+// as created by the next poll.
+func (p *treePoller) add(root string, baseline bool) {
+	p.roots = append(p.roots, root)
+	if baseline {
+		p.scan(root, p.files)
+	}
+}
+
+// scan records the state of the files below root
+func (p *treePoller) scan(root string, files map[string]fileState) {
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			// The root itself is reported by the watch of its parent
+			return nil
+		}
+		if d.IsDir() && p.skip != nil && p.skip(path) {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[path] = fileState{modTime: info.ModTime().UnixNano(), size: info.Size(), isDir: d.IsDir()}
+		return nil
+	})
+}
+
+// poll calls callback with the files created, written and removed since the last poll
+func (p *treePoller) poll(callback func(event fsnotify.Event)) {
+	if len(p.roots) == 0 {
+		return
+	}
+	files := make(map[string]fileState, len(p.files))
+	for _, root := range p.roots {
+		p.scan(root, files)
+	}
+
+	// Sorted, so that directories are reported before their content
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		previous, existed := p.files[path]
+		if !existed {
+			callback(fsnotify.Event{Name: path, Op: fsnotify.Create})
+		} else if !files[path].isDir && files[path] != previous {
+			callback(fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	removed := make([]string, 0)
+	for path := range p.files {
+		if _, exists := files[path]; !exists {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	for _, path := range removed {
+		callback(fsnotify.Event{Name: path, Op: fsnotify.Remove})
+	}
+	p.files = files
+}
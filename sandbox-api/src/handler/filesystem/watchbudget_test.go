@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestWatchBudget tests polling the directories beyond the watch budget, and not
+// watching skipped directories
+func TestWatchBudget(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c/d", "node_modules/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Room for the root and a only, b and c are polled
+	previousLimit, previousInterval := watchBudget(), watchPollInterval
+	watchLimit.Store(watchesUsed.Load() + 2)
+	watchPollInterval = 50 * time.Millisecond
+	defer func() {
+		watchLimit.Store(previousLimit)
+		watchPollInterval = previousInterval
+	}()
+
+	before := GetWatchUsage()
+	events := make(chan fsnotify.Event, 100)
+	errs := make(chan error, 10)
+	skip := func(dir string) bool { return filepath.Base(dir) == "node_modules" }
+	stop, err := NewFilesystem(root).WatchDirectoryRecursive(root, skip, func(event fsnotify.Event) {
+		events <- event
+	}, func(err error) {
+		errs <- err
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	if usage := GetWatchUsage(); usage.Watched != before.Watched+2 || usage.Polled != before.Polled+2 {
+		t.Errorf("Expected 2 watched directories and 2 polled trees, got %+v", usage)
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "budget") {
+			t.Errorf("Unexpected error %v", err)
+		}
+	default:
+		t.Error("Expected the exhausted budget to be reported")
+	}
+
+	for _, file := range []string{"node_modules/pkg/index.js", "c/d/polled.txt", "a/watched.txt"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := make(map[string]bool)
+	timeout := time.After(2 * time.Second)
+	for !seen["a/watched.txt"] || !seen["c/d/polled.txt"] {
+		select {
+		case event := <-events:
+			rel, _ := filepath.Rel(root, event.Name)
+			seen[rel] = true
+		case <-timeout:
+			t.Fatalf("Expected events of the watched and polled files, got %v", seen)
+		}
+	}
+	if seen["node_modules/pkg/index.js"] {
+		t.Error("Expected no event for a skipped directory")
+	}
+
+	stop()
+	deadline := time.Now().Add(time.Second)
+	for GetWatchUsage() != before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if usage := GetWatchUsage(); usage != before {
+		t.Errorf("Expected the budget to be given back, got %+v instead of %+v", usage, before)
+	}
+}
@@ -0,0 +1,446 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/git"
+)
+
+// GitHandler handles git operations on repositories of the sandbox
+type GitHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+}
+
+// NewGitHandler creates a new git handler resolving repository paths like the filesystem handler
+func NewGitHandler(fsHandler *FileSystemHandler) *GitHandler {
+	return &GitHandler{
+		BaseHandler: NewBaseHandler(),
+		FileSystem:  fsHandler,
+	}
+}
+
+// GitCloneRequest represents the request body for cloning a repository
+type GitCloneRequest struct {
+	URL    string `json:"url" example:"https://github.com/blaxel-ai/sandbox.git" binding:"required"`
+	Path   string `json:"path" example:"/home/user/sandbox" binding:"required"`
+	Branch string `json:"branch" example:"main"`
+	Depth  int    `json:"depth" example:"1"`
+} // @name GitCloneRequest
+
+// GitCheckoutRequest represents the request body for checking out a ref
+type GitCheckoutRequest struct {
+	Ref    string `json:"ref" example:"feature/login" binding:"required"`
+	Create bool   `json:"create" example:"true"`
+} // @name GitCheckoutRequest
+
+// GitAddRequest represents the request body for staging changes
+type GitAddRequest struct {
+	Paths []string `json:"paths" example:"src/main.go"`
+} // @name GitAddRequest
+
+// GitCommitRequest represents the request body for creating a commit
+type GitCommitRequest struct {
+	Message     string `json:"message" example:"Fix login redirect" binding:"required"`
+	AuthorName  string `json:"authorName" example:"Jane Doe"`
+	AuthorEmail string `json:"authorEmail" example:"jane@example.com"`
+	All         bool   `json:"all" example:"false"`
+} // @name GitCommitRequest
+
+// GitCommitResponse represents the response after creating a commit
+type GitCommitResponse struct {
+	Hash    string `json:"hash" example:"3f1c2b9e8d7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e" binding:"required"`
+	Message string `json:"message" example:"Changes committed successfully" binding:"required"`
+} // @name GitCommitResponse
+
+// GitRemoteRequest represents the request body for pushing to or pulling from a remote
+type GitRemoteRequest struct {
+	Remote string `json:"remote" example:"origin"`
+	Branch string `json:"branch" example:"main"`
+} // @name GitRemoteRequest
+
+// GitDiffResponse represents the diff of a repository
+type GitDiffResponse struct {
+	Diff string `json:"diff" example:"diff --git a/main.go b/main.go" binding:"required"`
+} // @name GitDiffResponse
+
+// GitPullResponse represents the response after pulling from a remote
+type GitPullResponse struct {
+	Output  string `json:"output" example:"Already up to date."`
+	Message string `json:"message" example:"Changes pulled successfully" binding:"required"`
+} // @name GitPullResponse
+
+// extractPathFromRequest extracts the repository path from the request (same logic as FileSystemHandler)
+func (h *GitHandler) extractPathFromRequest(c *gin.Context) string {
+	path := c.Param("path")
+
+	// Check if the request URL explicitly contains %2F (encoded /)
+	rawURL := c.Request.URL.RawPath
+	if rawURL == "" {
+		rawURL = c.Request.URL.Path
+	}
+
+	// If the raw URL contains %2F, it's an explicit absolute path request
+	if strings.Contains(rawURL, "%2F") {
+		return path
+	}
+
+	// Otherwise treat the path as relative to the working directory
+	if path == "/" {
+		return "."
+	} else if strings.HasPrefix(path, "/") {
+		return path[1:]
+	}
+
+	return path
+}
+
+// repoPath resolves the repository path of the request against the working directory
+func (h *GitHandler) repoPath(c *gin.Context) (string, error) {
+	return h.ResolvePath(h.extractPathFromRequest(c))
+}
+
+// ResolvePath resolves a repository path against the working directory
+func (h *GitHandler) ResolvePath(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	return h.FileSystem.fs.GetAbsolutePath(path)
+}
+
+// credentials returns the git credentials given in the request headers, if any
+func (h *GitHandler) credentials(c *gin.Context) *git.Credentials {
+	token := c.GetHeader("X-Git-Token")
+	if token == "" {
+		return nil
+	}
+	return &git.Credentials{
+		Username: c.GetHeader("X-Git-Username"),
+		Token:    token,
+	}
+}
+
+// GetStatus returns the status of the repository at path
+func (h *GitHandler) GetStatus(ctx context.Context, path string) (*git.Status, error) {
+	repo, err := h.ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return git.GetStatus(ctx, repo)
+}
+
+// GetDiff returns the diff of the repository at path
+func (h *GitHandler) GetDiff(ctx context.Context, path string, staged bool, paths []string) (string, error) {
+	repo, err := h.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	return git.GetDiff(ctx, repo, staged, paths)
+}
+
+// Commit stages paths, if any, and commits them in the repository at path
+func (h *GitHandler) Commit(ctx context.Context, path string, paths []string, req GitCommitRequest) (string, error) {
+	repo, err := h.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) > 0 {
+		if err := git.Add(ctx, repo, paths); err != nil {
+			return "", err
+		}
+	}
+	return git.Commit(ctx, repo, git.CommitOptions{
+		Message:     req.Message,
+		AuthorName:  req.AuthorName,
+		AuthorEmail: req.AuthorEmail,
+		All:         req.All,
+	})
+}
+
+// HandleClone handles POST requests to /git/clone
+// @Summary Clone a repository
+// @Description Clone a git repository into the sandbox. Credentials for private repositories are passed in the X-Git-Username and X-Git-Token headers and are never stored.
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param X-Git-Username header string false "Username for the remote, defaults to x-access-token"
+// @Param X-Git-Token header string false "Token or password for the remote"
+// @Param request body GitCloneRequest true "Clone request"
+// @Param X-Run-As header string false "User[:group] owning the files and directories created, RUN_AS by default"
+// @Success 200 {object} SuccessResponse "Repository cloned"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/clone [post]
+func (h *GitHandler) HandleClone(c *gin.Context) {
+	var req GitCloneRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Depth < 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid depth: must be a positive number"))
+		return
+	}
+
+	path, err := h.ResolvePath(req.Path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	owner, ok := h.FileSystem.fileOwner(c)
+	if !ok {
+		return
+	}
+	created := owner.track(path)
+	if err := git.Clone(c.Request.Context(), req.URL, path, req.Branch, req.Depth, h.credentials(c)); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	created()
+	owner.giveAll(path)
+
+	h.SendSuccessWithPath(c, path, "Repository cloned successfully")
+}
+
+// HandleStatus handles GET requests to /git/status/{path}
+// @Summary Get repository status
+// @Description Get the current branch, upstream tracking information and changed files of a repository
+// @Tags git
+// @Produce json
+// @Param path path string true "Repository path"
+// @Success 200 {object} git.Status "Repository status"
+// @Failure 400 {object} ErrorResponse "Invalid path"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/status/{path} [get]
+func (h *GitHandler) HandleStatus(c *gin.Context) {
+	repo, err := h.repoPath(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	status, err := git.GetStatus(c.Request.Context(), repo)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, status)
+}
+
+// HandleDiff handles GET requests to /git/diff/{path}
+// @Summary Get repository diff
+// @Description Get the unified diff of the working tree, or of the staged changes
+// @Tags git
+// @Produce json
+// @Param path path string true "Repository path"
+// @Param staged query boolean false "Diff the staged changes instead of the working tree"
+// @Param paths query []string false "Restrict the diff to these paths" collectionFormat(multi)
+// @Success 200 {object} GitDiffResponse "Repository diff"
+// @Failure 400 {object} ErrorResponse "Invalid path"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/diff/{path} [get]
+func (h *GitHandler) HandleDiff(c *gin.Context) {
+	repo, err := h.repoPath(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	staged := h.GetQueryParam(c, "staged", "false") == "true"
+	diff, err := git.GetDiff(c.Request.Context(), repo, staged, c.QueryArray("paths"))
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, GitDiffResponse{Diff: diff})
+}
+
+// HandleCheckout handles POST requests to /git/checkout/{path}
+// @Summary Check out a ref
+// @Description Switch the repository to a branch, tag or commit, optionally creating a new branch
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param path path string true "Repository path"
+// @Param request body GitCheckoutRequest true "Checkout request"
+// @Success 200 {object} SuccessResponse "Ref checked out"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/checkout/{path} [post]
+func (h *GitHandler) HandleCheckout(c *gin.Context) {
+	repo, err := h.repoPath(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req GitCheckoutRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := git.Checkout(c.Request.Context(), repo, req.Ref, req.Create); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendSuccessWithPath(c, repo, "Ref checked out successfully")
+}
+
+// HandleAdd handles POST requests to /git/add/{path}
+// @Summary Stage changes
+// @Description Stage the given paths, or every change of the working tree if no path is given
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param path path string true "Repository path"
+// @Param request body GitAddRequest false "Paths to stage"
+// @Success 200 {object} SuccessResponse "Changes staged"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/add/{path} [post]
+func (h *GitHandler) HandleAdd(c *gin.Context) {
+	repo, err := h.repoPath(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req GitAddRequest
+	if c.Request.ContentLength > 0 {
+		if err := h.BindJSON(c, &req); err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := git.Add(c.Request.Context(), repo, req.Paths); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendSuccessWithPath(c, repo, "Changes staged successfully")
+}
+
+// HandleCommit handles POST requests to /git/commit/{path}
+// @Summary Commit changes
+// @Description Commit the staged changes, or every tracked change if all is set
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param path path string true "Repository path"
+// @Param request body GitCommitRequest true "Commit request"
+// @Success 200 {object} GitCommitResponse "Changes committed"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/commit/{path} [post]
+func (h *GitHandler) HandleCommit(c *gin.Context) {
+	repo, err := h.repoPath(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req GitCommitRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	hash, err := git.Commit(c.Request.Context(), repo, git.CommitOptions{
+		Message:     req.Message,
+		AuthorName:  req.AuthorName,
+		AuthorEmail: req.AuthorEmail,
+		All:         req.All,
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, GitCommitResponse{Hash: hash, Message: "Changes committed successfully"})
+}
+
+// HandlePush handles POST requests to /git/push/{path}
+// @Summary Push to a remote
+// @Description Push the current branch, or the given branch, to a remote. Credentials are passed in the X-Git-Username and X-Git-Token headers and are never stored.
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param path path string true "Repository path"
+// @Param X-Git-Username header string false "Username for the remote, defaults to x-access-token"
+// @Param X-Git-Token header string false "Token or password for the remote"
+// @Param request body GitRemoteRequest false "Remote and branch, defaults to the upstream of the current branch"
+// @Success 200 {object} SuccessResponse "Changes pushed"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/push/{path} [post]
+func (h *GitHandler) HandlePush(c *gin.Context) {
+	repo, req, ok := h.bindRemoteRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := git.Push(c.Request.Context(), repo, req.Remote, req.Branch, h.credentials(c)); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendSuccessWithPath(c, repo, "Changes pushed successfully")
+}
+
+// HandlePull handles POST requests to /git/pull/{path}
+// @Summary Pull from a remote
+// @Description Fetch and merge changes from a remote into the current branch. Credentials are passed in the X-Git-Username and X-Git-Token headers and are never stored.
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param path path string true "Repository path"
+// @Param X-Git-Username header string false "Username for the remote, defaults to x-access-token"
+// @Param X-Git-Token header string false "Token or password for the remote"
+// @Param request body GitRemoteRequest false "Remote and branch, defaults to the upstream of the current branch"
+// @Success 200 {object} GitPullResponse "Changes pulled"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Git error"
+// @Router /git/pull/{path} [post]
+func (h *GitHandler) HandlePull(c *gin.Context) {
+	repo, req, ok := h.bindRemoteRequest(c)
+	if !ok {
+		return
+	}
+
+	output, err := git.Pull(c.Request.Context(), repo, req.Remote, req.Branch, h.credentials(c))
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, GitPullResponse{Output: output, Message: "Changes pulled successfully"})
+}
+
+// bindRemoteRequest resolves the repository path and binds the optional remote request body
+func (h *GitHandler) bindRemoteRequest(c *gin.Context) (string, GitRemoteRequest, bool) {
+	var req GitRemoteRequest
+	repo, err := h.repoPath(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return "", req, false
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := h.BindJSON(c, &req); err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return "", req, false
+		}
+	}
+	return repo, req, true
+}
@@ -0,0 +1,299 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// defaultUsername is used for token authentication when no username is given
+const defaultUsername = "x-access-token"
+
+// Credentials are injected into remote operations as an HTTP authorization header,
+// so they never end up in the remote URL or the repository configuration
+type Credentials struct {
+	Username string
+	Token    string
+}
+
+// FileStatus is the status of a changed file in the repository
+type FileStatus struct {
+	Path     string `json:"path" example:"src/main.go" binding:"required"`
+	OrigPath string `json:"origPath,omitempty" example:"src/old.go"`
+	Index    string `json:"index" example:"M" binding:"required"`
+	WorkTree string `json:"workTree" example:"." binding:"required"`
+} // @name GitFileStatus
+
+// Status is the state of the repository working tree
+type Status struct {
+	Branch   string       `json:"branch" example:"main" binding:"required"`
+	Upstream string       `json:"upstream,omitempty" example:"origin/main"`
+	Ahead    int          `json:"ahead" example:"1"`
+	Behind   int          `json:"behind" example:"0"`
+	Clean    bool         `json:"clean" example:"false"`
+	Files    []FileStatus `json:"files" binding:"required"`
+} // @name GitStatus
+
+// CommitOptions are the options of a commit
+type CommitOptions struct {
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+	All         bool
+}
+
+// checkArg rejects a value given by the caller which git would parse as an option,
+// such as --upload-pack=<command>
+func checkArg(name string, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return apierror.Newf(apierror.CodeInvalidRequest, "%s must not start with '-'", name)
+	}
+	return nil
+}
+
+// run executes a git command in dir and returns its standard output
+func run(ctx context.Context, dir string, creds *Credentials, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never block waiting for credentials on a terminal
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	// Repositories are owned by the run-as user rather than the user of the API, which
+	// git refuses to work in unless they are marked safe
+	config := [][2]string{{"safe.directory", "*"}}
+	if creds != nil && creds.Token != "" {
+		username := creds.Username
+		if username == "" {
+			username = defaultUsername
+		}
+		// The header is configured through the environment rather than with -c, which
+		// would expose it in the command line of the process
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + creds.Token))
+		config = append(config, [2]string{"http.extraHeader", "Authorization: Basic " + auth})
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(config)))
+	for i, entry := range config {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, entry[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, entry[1]))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// Clone clones url into path, optionally on a given branch and with a limited history depth
+func Clone(ctx context.Context, url string, path string, branch string, depth int, creds *Credentials) error {
+	if err := checkArg("branch", branch); err != nil {
+		return err
+	}
+	args := []string{"clone"}
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	args = append(args, "--", url, path)
+	_, err := run(ctx, "", creds, nil, args...)
+	return err
+}
+
+// Checkout switches the repository to ref, creating it as a new branch if create is set
+func Checkout(ctx context.Context, repo string, ref string, create bool) error {
+	if err := checkArg("ref", ref); err != nil {
+		return err
+	}
+	args := []string{"checkout"}
+	if create {
+		args = append(args, "-b")
+	}
+	args = append(args, ref)
+	_, err := run(ctx, repo, nil, nil, args...)
+	return err
+}
+
+// GetStatus returns the branch and changed files of the repository
+func GetStatus(ctx context.Context, repo string) (*Status, error) {
+	output, err := run(ctx, repo, nil, nil, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(output), nil
+}
+
+// parseStatus parses the output of git status --porcelain=v2 --branch -z
+func parseStatus(output string) *Status {
+	status := &Status{Files: []FileStatus{}}
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+		switch entry[0] {
+		case '#':
+			fields := strings.Fields(entry)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.head":
+				status.Branch = fields[2]
+			case "branch.upstream":
+				status.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) == 4 {
+					status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+					status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+		case '1', 'u':
+			// Ordinary and unmerged entries: the path is the last field
+			count := 9
+			if entry[0] == 'u' {
+				count = 11
+			}
+			fields := strings.SplitN(entry, " ", count)
+			xy := fields[1]
+			status.Files = append(status.Files, FileStatus{
+				Path:     fields[len(fields)-1],
+				Index:    xy[:1],
+				WorkTree: xy[1:],
+			})
+		case '2':
+			// Renamed or copied entries are followed by the original path
+			fields := strings.SplitN(entry, " ", 10)
+			xy := fields[1]
+			file := FileStatus{
+				Path:     fields[len(fields)-1],
+				Index:    xy[:1],
+				WorkTree: xy[1:],
+			}
+			if i+1 < len(entries) {
+				i++
+				file.OrigPath = entries[i]
+			}
+			status.Files = append(status.Files, file)
+		case '?':
+			status.Files = append(status.Files, FileStatus{
+				Path:     entry[2:],
+				Index:    "?",
+				WorkTree: "?",
+			})
+		}
+	}
+	status.Clean = len(status.Files) == 0
+	return status
+}
+
+// GetDiff returns the diff of the working tree, or of the index if staged is set,
+// optionally restricted to paths
+func GetDiff(ctx context.Context, repo string, staged bool, paths []string) (string, error) {
+	args := []string{"diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	return run(ctx, repo, nil, nil, args...)
+}
+
+// Add stages paths, or every change in the working tree if no path is given
+func Add(ctx context.Context, repo string, paths []string) error {
+	args := []string{"add"}
+	if len(paths) == 0 {
+		args = append(args, "--all")
+	} else {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+	_, err := run(ctx, repo, nil, nil, args...)
+	return err
+}
+
+// Commit records the staged changes and returns the hash of the new commit
+func Commit(ctx context.Context, repo string, opts CommitOptions) (string, error) {
+	if opts.Message == "" {
+		return "", fmt.Errorf("commit message is required")
+	}
+
+	var env []string
+	if opts.AuthorName != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+opts.AuthorName, "GIT_COMMITTER_NAME="+opts.AuthorName)
+	}
+	if opts.AuthorEmail != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+opts.AuthorEmail, "GIT_COMMITTER_EMAIL="+opts.AuthorEmail)
+	}
+
+	args := []string{"commit", "--message", opts.Message}
+	if opts.All {
+		args = append(args, "--all")
+	}
+	if _, err := run(ctx, repo, nil, env, args...); err != nil {
+		return "", err
+	}
+
+	hash, err := run(ctx, repo, nil, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+// remoteArgs appends remote and branch to args, defaulting the remote to origin
+// when only a branch is given
+func remoteArgs(args []string, remote string, branch string) ([]string, error) {
+	if err := checkArg("remote", remote); err != nil {
+		return nil, err
+	}
+	if err := checkArg("branch", branch); err != nil {
+		return nil, err
+	}
+	if remote == "" && branch != "" {
+		remote = "origin"
+	}
+	if remote != "" {
+		args = append(args, remote)
+	}
+	if branch != "" {
+		args = append(args, branch)
+	}
+	return args, nil
+}
+
+// Push pushes the current branch, or branch if given, to remote
+func Push(ctx context.Context, repo string, remote string, branch string, creds *Credentials) error {
+	args, err := remoteArgs([]string{"push"}, remote, branch)
+	if err != nil {
+		return err
+	}
+	_, err = run(ctx, repo, creds, nil, args...)
+	return err
+}
+
+// Pull fetches and merges remote changes into the current branch and returns the git output
+func Pull(ctx context.Context, repo string, remote string, branch string, creds *Credentials) (string, error) {
+	args, err := remoteArgs([]string{"pull"}, remote, branch)
+	if err != nil {
+		return "", err
+	}
+	output, err := run(ctx, repo, creds, nil, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
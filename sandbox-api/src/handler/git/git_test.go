@@ -0,0 +1,160 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseStatus tests parsing of porcelain v2 status output
+func TestParseStatus(t *testing.T) {
+	output := strings.Join([]string{
+		"# branch.oid 3f1c2b9e8d7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"1 .M N... 100644 100644 100644 3f1c2b9 3f1c2b9 src/file with space.go",
+		"2 R. N... 100644 100644 100644 3f1c2b9 3f1c2b9 R100 new.go",
+		"old.go",
+		"? untracked.txt",
+		"",
+	}, "\x00")
+
+	status := parseStatus(output)
+	if status.Branch != "main" || status.Upstream != "origin/main" {
+		t.Errorf("Expected branch main tracking origin/main, got %s tracking %s", status.Branch, status.Upstream)
+	}
+	if status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("Expected ahead 2 and behind 1, got %d and %d", status.Ahead, status.Behind)
+	}
+	if len(status.Files) != 3 || status.Clean {
+		t.Fatalf("Expected 3 changed files, got %+v", status.Files)
+	}
+	if status.Files[0].Path != "src/file with space.go" || status.Files[0].WorkTree != "M" {
+		t.Errorf("Unexpected modified file: %+v", status.Files[0])
+	}
+	if status.Files[1].Path != "new.go" || status.Files[1].OrigPath != "old.go" || status.Files[1].Index != "R" {
+		t.Errorf("Unexpected renamed file: %+v", status.Files[1])
+	}
+	if status.Files[2].Path != "untracked.txt" || status.Files[2].Index != "?" {
+		t.Errorf("Unexpected untracked file: %+v", status.Files[2])
+	}
+}
+
+// TestGitWorkflow tests a commit, push and pull round trip through a local bare remote
+func TestGitWorkflow(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	repo := filepath.Join(dir, "repo")
+	commit := CommitOptions{AuthorName: "Test", AuthorEmail: "test@example.com"}
+
+	if _, err := run(ctx, dir, nil, nil, "init", "--bare", "--initial-branch=main", remote); err != nil {
+		t.Fatalf("Failed to create remote: %v", err)
+	}
+	if err := Clone(ctx, remote, repo, "", 0, nil); err != nil {
+		t.Fatalf("Failed to clone: %v", err)
+	}
+	if err := Checkout(ctx, repo, "main", true); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	status, err := GetStatus(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if len(status.Files) != 1 || status.Files[0].Index != "?" {
+		t.Errorf("Expected one untracked file, got %+v", status.Files)
+	}
+
+	if _, err := Commit(ctx, repo, commit); err == nil {
+		t.Error("Expected error when committing without a message, but got none")
+	}
+	if err := Add(ctx, repo, nil); err != nil {
+		t.Fatalf("Failed to stage changes: %v", err)
+	}
+	commit.Message = "Add readme"
+	hash, err := Commit(ctx, repo, commit)
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if len(hash) != 40 {
+		t.Errorf("Expected a commit hash, got %q", hash)
+	}
+	if err := Push(ctx, repo, "origin", "main", nil); err != nil {
+		t.Fatalf("Failed to push: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello world\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	diff, err := GetDiff(ctx, repo, false, nil)
+	if err != nil {
+		t.Fatalf("Failed to get diff: %v", err)
+	}
+	if !strings.Contains(diff, "+hello world") {
+		t.Errorf("Expected diff to contain the change, got %q", diff)
+	}
+	commit.Message = "Update readme"
+	commit.All = true
+	if _, err := Commit(ctx, repo, commit); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	// A second clone pulls the commits pushed after it was made
+	other := filepath.Join(dir, "other")
+	if err := Clone(ctx, remote, other, "main", 0, nil); err != nil {
+		t.Fatalf("Failed to clone: %v", err)
+	}
+	if err := Push(ctx, repo, "origin", "main", nil); err != nil {
+		t.Fatalf("Failed to push: %v", err)
+	}
+	if _, err := Pull(ctx, other, "", "", nil); err != nil {
+		t.Fatalf("Failed to pull: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(other, "README.md"))
+	if err != nil || string(content) != "hello world\n" {
+		t.Errorf("Expected pulled content, got %q (%v)", content, err)
+	}
+
+	status, err = GetStatus(ctx, other)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if !status.Clean || status.Branch != "main" || status.Upstream != "origin/main" {
+		t.Errorf("Expected a clean main branch tracking origin/main, got %+v", status)
+	}
+}
+
+// TestOptionInjection tests that the refs, remotes and branches given by the caller
+// cannot be parsed as options, which could run commands
+func TestOptionInjection(t *testing.T) {
+	ctx := context.Background()
+	repo := t.TempDir()
+	marker := filepath.Join(repo, "injected")
+	if _, err := run(ctx, repo, nil, nil, "init", "--initial-branch=main"); err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	command := "--upload-pack=touch " + marker
+	if err := Checkout(ctx, repo, "--orphan=other", false); err == nil {
+		t.Error("Expected an option as ref to be rejected")
+	}
+	if _, err := Pull(ctx, repo, command, "", nil); err == nil {
+		t.Error("Expected an option as remote to be rejected")
+	}
+	if err := Push(ctx, repo, "origin", "--receive-pack=touch "+marker, nil); err == nil {
+		t.Error("Expected an option as branch to be rejected")
+	}
+	if err := Clone(ctx, repo, filepath.Join(repo, "clone"), "--upload-pack=touch "+marker, 0, nil); err == nil {
+		t.Error("Expected an option as clone branch to be rejected")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected no command to be run")
+	}
+}
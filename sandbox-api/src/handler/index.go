@@ -0,0 +1,387 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/index"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+)
+
+const (
+	// maxIndexedFiles bounds the number of files of the working directory indexed
+	maxIndexedFiles = 20000
+	// indexUpdateDelay is how long changes are collected before the index is updated, so
+	// that bursts of events like a checkout update each file once
+	indexUpdateDelay = 500 * time.Millisecond
+	// defaultIndexSearchLimit is the number of results of a search when unset
+	defaultIndexSearchLimit = 20
+)
+
+// IndexEnabledFromEnv reports whether the working directory is indexed at startup, set
+// with INDEX_ENABLED (default: true)
+func IndexEnabledFromEnv() bool {
+	value := os.Getenv("INDEX_ENABLED")
+	if value == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Invalid INDEX_ENABLED value '%s', using default of true", value)
+		return true
+	}
+	return enabled
+}
+
+// WorkspaceIndex keeps an index of the files of the working directory up to date. It is
+// built in the background, then updated from the filesystem watcher.
+type WorkspaceIndex struct {
+	*index.Index
+	fs *FileSystemHandler
+
+	mu        sync.Mutex
+	started   bool
+	building  bool
+	built     chan struct{}
+	rebuild   bool
+	stopWatch func()
+	pending   map[string]bool
+	timer     *time.Timer
+}
+
+var (
+	workspaceIndex     *WorkspaceIndex
+	workspaceIndexOnce sync.Once
+)
+
+// GetWorkspaceIndex returns the index of the working directory. It is empty until
+// Start or Rebuild is called.
+func GetWorkspaceIndex() *WorkspaceIndex {
+	workspaceIndexOnce.Do(func() {
+		fsHandler := NewFileSystemHandler()
+		root, err := fsHandler.GetWorkingDirectory()
+		if err != nil {
+			root = "/"
+		}
+		workspaceIndex = &WorkspaceIndex{
+			Index:   index.New(root),
+			fs:      fsHandler,
+			pending: make(map[string]bool),
+		}
+	})
+	return workspaceIndex
+}
+
+// Start builds the index in the background and keeps it updated with the changes of
+// the working directory. It does nothing once started.
+func (w *WorkspaceIndex) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started {
+		return
+	}
+	w.started = true
+	w.building = true
+	w.built = make(chan struct{})
+
+	go func() {
+		// Watching first, so that no change made while building is missed
+		stop, err := w.fs.WatchDirectory(w.Root(), true, nil, true, func(event FileEvent) {
+			w.changed(filepath.Join(event.Path, event.Name))
+			if event.From != "" {
+				w.changed(event.From)
+			}
+		})
+		if err != nil {
+			logrus.Warnf("Failed to watch %s, the index will only be updated by rebuilds: %v", w.Root(), err)
+		} else {
+			w.mu.Lock()
+			w.stopWatch = stop
+			w.mu.Unlock()
+		}
+		w.build()
+	}()
+}
+
+// Rebuild rebuilds the index in the background, starting the index if needed. A rebuild
+// requested while one runs is done once it finishes.
+func (w *WorkspaceIndex) Rebuild() {
+	w.mu.Lock()
+	if !w.started {
+		w.mu.Unlock()
+		w.Start()
+		return
+	}
+	if w.building {
+		w.rebuild = true
+		w.mu.Unlock()
+		return
+	}
+	w.building = true
+	w.built = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.build()
+}
+
+// Wait waits for the build or rebuild running, if any, to finish, or fails when ctx is
+// done first
+func (w *WorkspaceIndex) Wait(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.building {
+		w.mu.Unlock()
+		return nil
+	}
+	built := w.built
+	w.mu.Unlock()
+
+	select {
+	case <-built:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops watching the working directory
+func (w *WorkspaceIndex) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopWatch != nil {
+		w.stopWatch()
+		w.stopWatch = nil
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// build indexes the files of the working directory, then applies the changes which
+// happened meanwhile
+func (w *WorkspaceIndex) build() {
+	for {
+		paths, truncated, err := w.fs.ListFiles(w.Root(), maxIndexedFiles)
+		if err != nil {
+			logrus.Errorf("Failed to list the files of %s to index: %v", w.Root(), err)
+		} else {
+			if truncated {
+				logrus.Warnf("Indexing only the first %d files of %s", maxIndexedFiles, w.Root())
+			}
+			paths = withoutSkippedPaths(w.Root(), paths)
+			w.Build(paths, truncated)
+			status := w.Status()
+			logrus.Infof("Indexed %d files and %d symbols of %s in %dms", status.Files, status.Symbols, w.Root(), status.BuildDurationMs)
+		}
+
+		w.mu.Lock()
+		if !w.rebuild {
+			w.building = false
+			close(w.built)
+			w.mu.Unlock()
+			break
+		}
+		w.rebuild = false
+		w.mu.Unlock()
+	}
+	w.flush()
+}
+
+// changed schedules the update of a changed path
+func (w *WorkspaceIndex) changed(path string) {
+	if isSkippedPath(w.Root(), path) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[path] = true
+	if w.timer == nil {
+		w.timer = time.AfterFunc(indexUpdateDelay, w.flush)
+	} else {
+		w.timer.Reset(indexUpdateDelay)
+	}
+}
+
+// flush updates the index with the pending changes, unless a build runs which will
+// flush them once done
+func (w *WorkspaceIndex) flush() {
+	w.mu.Lock()
+	if w.building || len(w.pending) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	pending := w.pending
+	w.pending = make(map[string]bool)
+	w.mu.Unlock()
+
+	for path := range pending {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			w.Remove(path)
+		case info.IsDir():
+			// Directories moved or created with their content raise no event per file
+			paths, _, err := w.fs.ListFiles(path, maxIndexedFiles)
+			if err != nil {
+				continue
+			}
+			for _, file := range withoutSkippedPaths(w.Root(), paths) {
+				w.Update(file)
+			}
+		default:
+			w.Update(path)
+		}
+	}
+}
+
+// withoutSkippedPaths removes the hidden and dependency paths from paths
+func withoutSkippedPaths(root string, paths []string) []string {
+	kept := paths[:0]
+	for _, path := range paths {
+		if !isSkippedPath(root, path) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// isSkippedPath reports whether a path below root is hidden or in a dependency or
+// build directory, which are neither indexed nor ranked
+func isSkippedPath(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if (strings.HasPrefix(part, ".") && part != ".") || rerankSkippedDirs[part] {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexHandler handles the requests to the workspace index
+type IndexHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+}
+
+// NewIndexHandler creates a new workspace index handler resolving paths like the
+// filesystem handler
+func NewIndexHandler(fsHandler *FileSystemHandler) *IndexHandler {
+	return &IndexHandler{
+		BaseHandler: NewBaseHandler(),
+		FileSystem:  fsHandler,
+	}
+}
+
+// IndexSearchRequest represents the query parameters of an index search
+type IndexSearchRequest struct {
+	Query       string `form:"query" binding:"required" example:"parse config file"`
+	Path        string `form:"path" example:"src"`
+	FilePattern string `form:"filePattern" example:".*\\.go$"`
+	Limit       int    `form:"limit" example:"20"`
+} // @name IndexSearchRequest
+
+// IndexSearchResponse represents the files matching an index search
+type IndexSearchResponse struct {
+	Results []index.Result `json:"results" binding:"required"`
+	Status  index.Status   `json:"status" binding:"required"`
+} // @name IndexSearchResponse
+
+// HandleIndexStatus handles GET requests to /index/status
+// @Summary Get the workspace index status
+// @Description Get the state of the index of the working directory: whether it is built, the number of indexed files and symbols, and when it was last built and updated
+// @Tags index
+// @Produce json
+// @Success 200 {object} index.Status "Index status"
+// @Router /index/status [get]
+func (h *IndexHandler) HandleIndexStatus(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, GetWorkspaceIndex().Status())
+}
+
+// HandleIndexRebuild handles POST requests to /index/rebuild
+// @Summary Rebuild the workspace index
+// @Description Rebuild the index of the working directory in the background, starting it if it is not running. Poll /index/status for completion, or request a job with async=true and follow the job.
+// @Tags index
+// @Produce json
+// @Param async query boolean false "Follow the rebuild with a background job, whose result is the index status"
+// @Success 202 {object} index.Status "Rebuild started"
+// @Router /index/rebuild [post]
+func (h *IndexHandler) HandleIndexRebuild(c *gin.Context) {
+	workspaceIndex := GetWorkspaceIndex()
+	if wantsAsync(c) {
+		h.startJob(c, jobs.TypeIndexRebuild, workspaceIndex.Root(), func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+			workspaceIndex.Rebuild()
+			if err := workspaceIndex.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return workspaceIndex.Status(), nil
+		})
+		return
+	}
+	workspaceIndex.Rebuild()
+	h.SendJSON(c, http.StatusAccepted, workspaceIndex.Status())
+}
+
+// HandleIndexSearch handles GET requests to /index/search
+// @Summary Search the workspace index
+// @Description Rank the files of the working directory by relevance to a query without reading them, from the terms of their content, the symbols they declare and their names. Files matching no term of the query are not returned.
+// @Tags index
+// @Produce json
+// @Param query query string true "Search query"
+// @Param path query string false "Directory to search in (relative to workspace)"
+// @Param filePattern query string false "Regex pattern to filter files (e.g., .*\\.ts$ for TypeScript files)"
+// @Param limit query int false "Maximum number of results (default: 20)"
+// @Success 200 {object} IndexSearchResponse "Matching files"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 503 {object} ErrorResponse "Index not built yet"
+// @Router /index/search [get]
+func (h *IndexHandler) HandleIndexSearch(c *gin.Context) {
+	var req IndexSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultIndexSearchLimit
+	}
+
+	opts := index.SearchOptions{Limit: req.Limit}
+	if req.FilePattern != "" {
+		fileRegex, err := regexp.Compile(req.FilePattern)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid file pattern regex: %w", err))
+			return
+		}
+		opts.FilePattern = fileRegex
+	}
+	if req.Path != "" {
+		directory, err := h.FileSystem.fs.GetAbsolutePath(req.Path)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		opts.Directory = directory
+	}
+
+	workspaceIndex := GetWorkspaceIndex()
+	if !workspaceIndex.Ready() {
+		h.SendError(c, http.StatusServiceUnavailable, fmt.Errorf("the workspace index is not built yet, start it with POST /index/rebuild"))
+		return
+	}
+	h.SendJSON(c, http.StatusOK, IndexSearchResponse{
+		Results: workspaceIndex.Search(req.Query, opts),
+		Status:  workspaceIndex.Status(),
+	})
+}
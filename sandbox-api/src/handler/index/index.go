@@ -0,0 +1,398 @@
+package index
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// MaxFileSize is the size above which files are not indexed
+	MaxFileSize = 1024 * 1024
+	// bm25K1 and bm25B are the parameters of the BM25 ranking of files
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// symbolBoost and pathBoost are added to the score of a file for each query term
+	// matching one of its symbols or its path
+	symbolBoost = 2.0
+	pathBoost   = 1.0
+	// maxMatchedSymbols is the number of matched symbols returned per file
+	maxMatchedSymbols = 5
+)
+
+// Index states
+const (
+	StateEmpty    = "empty"
+	StateBuilding = "building"
+	StateReady    = "ready"
+)
+
+// Symbol is a declaration found in an indexed file
+type Symbol struct {
+	Name string `json:"name" example:"HandleSearch" binding:"required"`
+	Kind string `json:"kind" example:"function" binding:"required"`
+	File string `json:"file" example:"/home/user/app/handler.go" binding:"required"`
+	Line int    `json:"line" example:"42" binding:"required"`
+} // @name IndexSymbol
+
+// Result is a file matching a search, with the symbols of the file matching it
+type Result struct {
+	File    string   `json:"file" example:"/home/user/app/handler.go" binding:"required"`
+	Score   float64  `json:"score" example:"7.5" binding:"required"`
+	Symbols []Symbol `json:"symbols" binding:"required"`
+} // @name IndexSearchResult
+
+// Status is the state of an index
+type Status struct {
+	State           string     `json:"state" example:"ready" enums:"empty,building,ready" binding:"required"`
+	Root            string     `json:"root" example:"/home/user/app" binding:"required"`
+	Files           int        `json:"files" example:"1200" binding:"required"`
+	Symbols         int        `json:"symbols" example:"8500" binding:"required"`
+	Terms           int        `json:"terms" example:"24000" binding:"required"`
+	Truncated       bool       `json:"truncated" example:"false" binding:"required"`
+	LastBuiltAt     *time.Time `json:"lastBuiltAt,omitempty"`
+	BuildDurationMs int64      `json:"buildDurationMs" example:"850" binding:"required"`
+	LastUpdatedAt   *time.Time `json:"lastUpdatedAt,omitempty"`
+} // @name IndexStatus
+
+// SearchOptions restrict a search
+type SearchOptions struct {
+	// Limit is the number of results, all matching files when zero
+	Limit int
+	// Directory restricts the results to the files below it
+	Directory string
+	// FilePattern restricts the results to the files whose path or name it matches
+	FilePattern *regexp.Regexp
+}
+
+// document is the indexed content of a file
+type document struct {
+	terms     map[string]int
+	length    int
+	symbols   []Symbol
+	pathTerms map[string]bool
+}
+
+// Index is an in-memory index of the terms and symbols of the files of a directory, to
+// rank files by relevance to a query without reading them
+type Index struct {
+	root string
+
+	mu            sync.RWMutex
+	documents     map[string]*document
+	frequencies   map[string]int
+	totalLength   int
+	state         string
+	truncated     bool
+	lastBuiltAt   time.Time
+	buildDuration time.Duration
+	lastUpdatedAt time.Time
+}
+
+// New creates an empty index of a directory
+func New(root string) *Index {
+	return &Index{
+		root:        root,
+		documents:   make(map[string]*document),
+		frequencies: make(map[string]int),
+		state:       StateEmpty,
+	}
+}
+
+// Root returns the directory of the index
+func (ix *Index) Root() string {
+	return ix.root
+}
+
+// Ready reports whether the index has been built
+func (ix *Index) Ready() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return !ix.lastBuiltAt.IsZero()
+}
+
+// Build replaces the content of the index with the given files. The previous content
+// stays searchable while the files are read. Truncated records that the files are only
+// part of the directory.
+func (ix *Index) Build(paths []string, truncated bool) {
+	ix.mu.Lock()
+	ix.state = StateBuilding
+	ix.mu.Unlock()
+
+	start := time.Now()
+	documents := make(map[string]*document, len(paths))
+	frequencies := make(map[string]int)
+	totalLength := 0
+	for _, path := range paths {
+		doc := readDocument(path)
+		if doc == nil {
+			continue
+		}
+		documents[path] = doc
+		totalLength += doc.length
+		for term := range doc.terms {
+			frequencies[term]++
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.documents = documents
+	ix.frequencies = frequencies
+	ix.totalLength = totalLength
+	ix.truncated = truncated
+	ix.state = StateReady
+	ix.lastBuiltAt = time.Now()
+	ix.buildDuration = time.Since(start)
+}
+
+// Update indexes the current content of a file, or removes it from the index when it
+// no longer exists or cannot be indexed
+func (ix *Index) Update(path string) {
+	doc := readDocument(path)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(path)
+	if doc != nil {
+		ix.documents[path] = doc
+		ix.totalLength += doc.length
+		for term := range doc.terms {
+			ix.frequencies[term]++
+		}
+	}
+	ix.lastUpdatedAt = time.Now()
+}
+
+// Remove removes a file, or every file below a directory, from the index
+func (ix *Index) Remove(path string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	for indexed := range ix.documents {
+		if indexed == path || strings.HasPrefix(indexed, prefix) {
+			ix.removeLocked(indexed)
+		}
+	}
+	ix.lastUpdatedAt = time.Now()
+}
+
+// removeLocked removes a file from the index, with the lock held
+func (ix *Index) removeLocked(path string) {
+	doc, exists := ix.documents[path]
+	if !exists {
+		return
+	}
+	delete(ix.documents, path)
+	ix.totalLength -= doc.length
+	for term := range doc.terms {
+		if ix.frequencies[term]--; ix.frequencies[term] <= 0 {
+			delete(ix.frequencies, term)
+		}
+	}
+}
+
+// Status returns the state of the index
+func (ix *Index) Status() Status {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	status := Status{
+		State:           ix.state,
+		Root:            ix.root,
+		Files:           len(ix.documents),
+		Terms:           len(ix.frequencies),
+		Truncated:       ix.truncated,
+		BuildDurationMs: ix.buildDuration.Milliseconds(),
+	}
+	for _, doc := range ix.documents {
+		status.Symbols += len(doc.symbols)
+	}
+	if !ix.lastBuiltAt.IsZero() {
+		lastBuiltAt := ix.lastBuiltAt
+		status.LastBuiltAt = &lastBuiltAt
+	}
+	if !ix.lastUpdatedAt.IsZero() {
+		lastUpdatedAt := ix.lastUpdatedAt
+		status.LastUpdatedAt = &lastUpdatedAt
+	}
+	return status
+}
+
+// Search ranks the indexed files by relevance to a query: BM25 over the terms of their
+// content, plus a boost for each query term naming one of their symbols or appearing
+// in their path. Files matching no query term are not returned.
+func (ix *Index) Search(query string, opts SearchOptions) []Result {
+	queryTerms := uniqueTerms(query)
+	results := []Result{}
+	if len(queryTerms) == 0 {
+		return results
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	count := float64(len(ix.documents))
+	averageLength := 1.0
+	if len(ix.documents) > 0 && ix.totalLength > 0 {
+		averageLength = float64(ix.totalLength) / count
+	}
+	directoryPrefix := ""
+	if opts.Directory != "" && opts.Directory != ix.root {
+		directoryPrefix = strings.TrimSuffix(opts.Directory, string(filepath.Separator)) + string(filepath.Separator)
+	}
+
+	for path, doc := range ix.documents {
+		if directoryPrefix != "" && !strings.HasPrefix(path, directoryPrefix) {
+			continue
+		}
+		if opts.FilePattern != nil && !opts.FilePattern.MatchString(path) && !opts.FilePattern.MatchString(filepath.Base(path)) {
+			continue
+		}
+
+		score := 0.0
+		for _, term := range queryTerms {
+			if frequency := float64(doc.terms[term]); frequency > 0 {
+				documents := float64(ix.frequencies[term])
+				idf := math.Log(1 + (count-documents+0.5)/(documents+0.5))
+				score += idf * frequency * (bm25K1 + 1) / (frequency + bm25K1*(1-bm25B+bm25B*float64(doc.length)/averageLength))
+			}
+			if doc.pathTerms[term] {
+				score += pathBoost
+			}
+		}
+
+		matched := []Symbol{}
+		for _, symbol := range doc.symbols {
+			for _, term := range queryTerms {
+				if strings.EqualFold(symbol.Name, term) || containsTerm(termsOf(symbol.Name), term) {
+					score += symbolBoost
+					if len(matched) < maxMatchedSymbols {
+						matched = append(matched, symbol)
+					}
+					break
+				}
+			}
+		}
+
+		if score > 0 {
+			results = append(results, Result{File: path, Score: math.Round(score*1000) / 1000, Symbols: matched})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].File < results[j].File
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results
+}
+
+// readDocument reads and indexes a file, nil when it is missing, too large or binary
+func readDocument(path string) *document {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > MaxFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+		return nil
+	}
+
+	doc := &document{terms: make(map[string]int), pathTerms: make(map[string]bool)}
+	for _, term := range termsOf(string(content)) {
+		doc.terms[term]++
+		doc.length++
+	}
+	for _, term := range termsOf(filepath.Base(path)) {
+		doc.pathTerms[term] = true
+	}
+	doc.symbols = extractSymbols(path, string(content))
+	return doc
+}
+
+// termsOf splits text into lowercase terms: words, and the parts of camelCase and
+// snake_case identifiers along with the whole identifiers
+func termsOf(text string) []string {
+	terms := []string{}
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			if term := strings.ToLower(word); isTerm(term) {
+				terms = append(terms, term)
+			}
+		}
+		for _, part := range parts {
+			if term := strings.ToLower(part); isTerm(term) {
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// uniqueTerms returns the distinct terms of a query
+func uniqueTerms(query string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, term := range termsOf(query) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// isTerm filters out single characters and numbers
+func isTerm(term string) bool {
+	if len(term) < 2 {
+		return false
+	}
+	return strings.IndexFunc(term, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0
+}
+
+// containsTerm reports whether terms contains term
+func containsTerm(terms []string, term string) bool {
+	for _, t := range terms {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
+
+// splitIdentifier splits a camelCase or snake_case identifier into its words
+func splitIdentifier(word string) []string {
+	parts := []string{}
+	for _, segment := range strings.Split(word, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
@@ -0,0 +1,117 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// writeFiles writes files relative to dir and returns their absolute paths
+func writeFiles(t *testing.T, dir string, files map[string]string) []string {
+	t.Helper()
+	paths := []string{}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// TestSearch tests ranking files by their content, symbols and names
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeFiles(t, dir, map[string]string{
+		"config/loader.go": "package config\n\n// LoadConfig reads the configuration file\nfunc LoadConfig(path string) (*Config, error) {\n\treturn parse(path)\n}\n",
+		"server/http.go":   "package server\n\ntype HTTPServer struct{}\n\nfunc (s *HTTPServer) Serve() error { return nil }\n",
+		"web/app.ts":       "export class UserService {\n  fetchUsers() {}\n}\nexport const loadConfig = async () => fetch('/config')\n",
+		"README.md":        "Nothing relevant here\n",
+		"image.png":        "\x89PNG\x00\x00binary",
+	})
+
+	ix := New(dir)
+	if ix.Ready() {
+		t.Fatal("Expected a new index not to be ready")
+	}
+	ix.Build(paths, false)
+
+	status := ix.Status()
+	if status.State != StateReady || status.Files != 4 || status.LastBuiltAt == nil {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+
+	results := ix.Search("load config", SearchOptions{})
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", results)
+	}
+	if results[0].File != filepath.Join(dir, "config/loader.go") {
+		t.Errorf("Expected loader.go to rank first, got %+v", results)
+	}
+	if len(results[0].Symbols) != 1 || results[0].Symbols[0].Name != "LoadConfig" || results[0].Symbols[0].Line != 4 {
+		t.Errorf("Expected LoadConfig to match, got %+v", results[0].Symbols)
+	}
+
+	results = ix.Search("HTTPServer", SearchOptions{})
+	if len(results) != 1 || results[0].File != filepath.Join(dir, "server/http.go") {
+		t.Fatalf("Expected http.go, got %+v", results)
+	}
+
+	results = ix.Search("config", SearchOptions{FilePattern: regexp.MustCompile(`\.ts$`)})
+	if len(results) != 1 || results[0].File != filepath.Join(dir, "web/app.ts") {
+		t.Errorf("Expected only app.ts with the file pattern, got %+v", results)
+	}
+	results = ix.Search("config", SearchOptions{Directory: filepath.Join(dir, "config")})
+	if len(results) != 1 || results[0].File != filepath.Join(dir, "config/loader.go") {
+		t.Errorf("Expected only loader.go in the directory, got %+v", results)
+	}
+	if results := ix.Search("config", SearchOptions{Limit: 1}); len(results) != 1 {
+		t.Errorf("Expected 1 result with the limit, got %+v", results)
+	}
+	if results := ix.Search("zzz", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected no result, got %+v", results)
+	}
+}
+
+// TestUpdateAndRemove tests updating the index as files change
+func TestUpdateAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeFiles(t, dir, map[string]string{"a/one.py": "def first(): pass\n"})
+	ix := New(dir)
+	ix.Build(paths, false)
+
+	added := writeFiles(t, dir, map[string]string{"a/two.py": "class Second:\n    pass\n"})
+	ix.Update(added[0])
+	if results := ix.Search("second", SearchOptions{}); len(results) != 1 || results[0].Symbols[0].Kind != "class" {
+		t.Fatalf("Expected the added file to be found, got %+v", results)
+	}
+
+	writeFiles(t, dir, map[string]string{"a/one.py": "def renamed(): pass\n"})
+	ix.Update(paths[0])
+	if results := ix.Search("first", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected the old content to be gone, got %+v", results)
+	}
+	if results := ix.Search("renamed", SearchOptions{}); len(results) != 1 {
+		t.Errorf("Expected the new content to be found, got %+v", results)
+	}
+
+	ix.Remove(filepath.Join(dir, "a"))
+	if status := ix.Status(); status.Files != 0 || status.Terms != 0 || status.LastUpdatedAt == nil {
+		t.Errorf("Expected the directory to be removed, got %+v", status)
+	}
+}
+
+// TestTermsOf tests splitting identifiers into terms
+func TestTermsOf(t *testing.T) {
+	got := termsOf("parseHTTPRequest user_id x 42")
+	want := []string{"parsehttprequest", "parse", "http", "request", "user_id", "user", "id"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,72 @@
+package index
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// symbolPattern matches a declaration on a line, the name being its first group
+type symbolPattern struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+var (
+	goSymbols = []symbolPattern{
+		{"function", regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`)},
+		{"type", regexp.MustCompile(`^type\s+([A-Za-z_]\w*)`)},
+		{"type", regexp.MustCompile(`^\s+([A-Z]\w*)\s+(?:struct|interface)\s*\{`)},
+	}
+	jsSymbols = []symbolPattern{
+		{"function", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`)},
+		{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`)},
+		{"type", regexp.MustCompile(`^\s*(?:export\s+)?(?:interface|type|enum)\s+([A-Za-z_$][\w$]*)`)},
+		{"function", regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`)},
+	}
+	pythonSymbols = []symbolPattern{
+		{"function", regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`)},
+		{"class", regexp.MustCompile(`^\s*class\s+([A-Za-z_]\w*)`)},
+	}
+	rustSymbols = []symbolPattern{
+		{"function", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+([A-Za-z_]\w*)`)},
+		{"type", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type)\s+([A-Za-z_]\w*)`)},
+	}
+	javaSymbols = []symbolPattern{
+		{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|abstract|final|static|sealed)\s+)*(?:class|interface|enum|record)\s+([A-Za-z_]\w*)`)},
+	}
+)
+
+// symbolPatterns are the declarations looked for by file extension
+var symbolPatterns = map[string][]symbolPattern{
+	".go":   goSymbols,
+	".js":   jsSymbols,
+	".jsx":  jsSymbols,
+	".mjs":  jsSymbols,
+	".cjs":  jsSymbols,
+	".ts":   jsSymbols,
+	".tsx":  jsSymbols,
+	".py":   pythonSymbols,
+	".rs":   rustSymbols,
+	".java": javaSymbols,
+	".kt":   javaSymbols,
+}
+
+// extractSymbols returns the declarations of a source file
+func extractSymbols(path, content string) []Symbol {
+	patterns, supported := symbolPatterns[strings.ToLower(filepath.Ext(path))]
+	if !supported {
+		return nil
+	}
+
+	symbols := []Symbol{}
+	for i, line := range strings.Split(content, "\n") {
+		for _, p := range patterns {
+			if match := p.pattern.FindStringSubmatch(line); match != nil {
+				symbols = append(symbols, Symbol{Name: match[1], Kind: p.kind, File: path, Line: i + 1})
+				break
+			}
+		}
+	}
+	return symbols
+}
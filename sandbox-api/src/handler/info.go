@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/handler/lsp"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
+)
+
+// InfoHandler describes the sandbox and what its API supports
+type InfoHandler struct {
+	*BaseHandler
+	router *gin.Engine
+}
+
+// NewInfoHandler creates a new info handler listing the routes of router
+func NewInfoHandler(router *gin.Engine) *InfoHandler {
+	return &InfoHandler{
+		BaseHandler: NewBaseHandler(),
+		router:      router,
+	}
+}
+
+// SandboxFeatures are the optional features of the API and whether they are available
+type SandboxFeatures struct {
+	Multipart bool `json:"multipart" example:"true" binding:"required"`
+	// Codegen is available with a fastapply provider configured
+	Codegen          bool     `json:"codegen" example:"true" binding:"required"`
+	CodegenProviders []string `json:"codegenProviders" example:"relace" binding:"required"`
+	// PTY is whether processes can be run in a pseudo-terminal
+	PTY bool `json:"pty" example:"false" binding:"required"`
+	// Git is available when git is installed in the sandbox
+	Git   bool `json:"git" example:"true" binding:"required"`
+	Index bool `json:"index" example:"true" binding:"required"`
+	// LSPLanguages are the languages whose language server is installed
+	LSPLanguages []string `json:"lspLanguages" example:"go,typescript" binding:"required"`
+	WebSocket    bool     `json:"websocket" example:"true" binding:"required"`
+	MCP          bool     `json:"mcp" example:"true" binding:"required"`
+	Tracing      bool     `json:"tracing" example:"false" binding:"required"`
+} // @name SandboxFeatures
+
+// SandboxRoute is a route of the API
+type SandboxRoute struct {
+	Method string `json:"method" example:"GET" binding:"required"`
+	Path   string `json:"path" example:"/filesystem/*path" binding:"required"`
+} // @name SandboxRoute
+
+// SandboxInfo describes the sandbox and what its API supports
+type SandboxInfo struct {
+	Version string `json:"version" example:"0.0.1" binding:"required"`
+	// Revision is the commit the API was built from
+	Revision   string             `json:"revision,omitempty" example:"9beb759"`
+	GoVersion  string             `json:"goVersion" example:"go1.25.0" binding:"required"`
+	OS         string             `json:"os" example:"linux" binding:"required"`
+	Arch       string             `json:"arch" example:"amd64" binding:"required"`
+	Hostname   string             `json:"hostname" example:"sandbox" binding:"required"`
+	Shell      string             `json:"shell" example:"/bin/bash" binding:"required"`
+	WorkingDir string             `json:"workingDir" example:"/home/user/app" binding:"required"`
+	Features   SandboxFeatures    `json:"features" binding:"required"`
+	Limits     lib.ResourceLimits `json:"limits" binding:"required"`
+	// DiskQuotaBytes is the quota of FILESYSTEM_QUOTA_BYTES, 0 when unset
+	DiskQuotaBytes uint64         `json:"diskQuotaBytes" example:"0" binding:"required"`
+	Routes         []SandboxRoute `json:"routes" binding:"required"`
+} // @name SandboxInfo
+
+// HandleGetInfo handles GET requests to /info
+// @Summary Get the sandbox info
+// @Description Get the version of the API, the features available in this sandbox (codegen providers, git, language servers...), the OS, shell, default working directory and resource limits, and the routes of the API, for clients to detect what the sandbox supports at runtime.
+// @Tags root
+// @Produce json
+// @Success 200 {object} SandboxInfo "Sandbox info"
+// @Router /info [get]
+func (h *InfoHandler) HandleGetInfo(c *gin.Context) {
+	hostname, _ := os.Hostname()
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "sh"
+	}
+	info := SandboxInfo{
+		Version:        lib.Version,
+		Revision:       lib.Revision(),
+		GoVersion:      runtime.Version(),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		Hostname:       hostname,
+		Shell:          shell,
+		WorkingDir:     lib.WorkingDir(),
+		Features:       sandboxFeatures(),
+		Limits:         lib.ReadResourceLimits(),
+		DiskQuotaBytes: filesystem.QuotaFromEnv(),
+		Routes:         make([]SandboxRoute, 0),
+	}
+	for _, route := range h.router.Routes() {
+		info.Routes = append(info.Routes, SandboxRoute{Method: route.Method, Path: route.Path})
+	}
+	sort.Slice(info.Routes, func(i, j int) bool {
+		if info.Routes[i].Path != info.Routes[j].Path {
+			return info.Routes[i].Path < info.Routes[j].Path
+		}
+		return info.Routes[i].Method < info.Routes[j].Method
+	})
+	h.SendJSON(c, http.StatusOK, info)
+}
+
+// sandboxFeatures returns the optional features of the API and whether they are available
+func sandboxFeatures() SandboxFeatures {
+	features := SandboxFeatures{
+		Multipart:        true,
+		Codegen:          codegen.IsEnabled(),
+		CodegenProviders: make([]string, 0),
+		Index:            IndexEnabledFromEnv(),
+		LSPLanguages:     make([]string, 0),
+		WebSocket:        true,
+		MCP:              true,
+		Tracing:          tracing.Enabled(),
+	}
+	for _, provider := range codegen.Providers() {
+		features.CodegenProviders = append(features.CodegenProviders, string(provider))
+	}
+	if _, err := exec.LookPath("git"); err == nil {
+		features.Git = true
+	}
+	for _, language := range lsp.GetManager().Languages() {
+		if language.Installed {
+			features.LSPLanguages = append(features.LSPLanguages, language.Language)
+		}
+	}
+	return features
+}
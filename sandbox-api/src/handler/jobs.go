@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+)
+
+// JobHandler handles the background jobs of the long operations
+type JobHandler struct {
+	*BaseHandler
+	jobs *jobs.Manager
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler() *JobHandler {
+	return &JobHandler{
+		BaseHandler: NewBaseHandler(),
+		jobs:        jobs.GetManager(),
+	}
+}
+
+// wantsAsync reports whether a request asks for its operation to run as a background job
+func wantsAsync(c *gin.Context) bool {
+	return c.Query("async") == "true"
+}
+
+// startJob runs fn as a background job and answers 202 with the job
+func (h *BaseHandler) startJob(c *gin.Context, jobType string, subject string, fn jobs.Func) {
+	h.SendJSON(c, http.StatusAccepted, jobs.GetManager().Start(jobType, subject, fn))
+}
+
+// sendJobError sends a 404 for unknown jobs or outputs, a 409 for finished jobs and a 422
+// otherwise
+func (h *JobHandler) sendJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound), errors.Is(err, jobs.ErrNoOutput):
+		h.SendError(c, http.StatusNotFound, err)
+	case errors.Is(err, jobs.ErrJobFinished):
+		h.SendError(c, http.StatusConflict, err)
+	default:
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+	}
+}
+
+// HandleListJobs handles GET requests to /jobs
+// @Summary List jobs
+// @Description List the running and recent background jobs, oldest first. Long operations (archives, sync plans, exports, index rebuilds, snapshots and package installs) run as jobs when requested with async=true.
+// @Tags jobs
+// @Produce json
+// @Param type query string false "Type of the jobs to list" Enums(archive, sync, export, index.rebuild, snapshot.create, snapshot.restore, packages.install)
+// @Success 200 {array} jobs.Job "Jobs"
+// @Router /jobs [get]
+func (h *JobHandler) HandleListJobs(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.jobs.List(c.Query("type")))
+}
+
+// HandleGetJob handles GET requests to /jobs/{id}
+// @Summary Get a job
+// @Description Get the status and progress of a job, and its result once completed
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job id"
+// @Success 200 {object} jobs.Job "Job"
+// @Failure 404 {object} ErrorResponse "Job not found"
+// @Router /jobs/{id} [get]
+func (h *JobHandler) HandleGetJob(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.jobs.Get(id)
+	if err != nil {
+		h.sendJobError(c, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, job)
+}
+
+// HandleCancelJob handles POST requests to /jobs/{id}/cancel
+// @Summary Cancel a job
+// @Description Cancel a running job and wait for its operation to stop. What it did so far is not undone, except that its output is removed. Operations which can't be interrupted, like snapshots, complete anyway.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job id"
+// @Success 200 {object} jobs.Job "Job cancelled"
+// @Failure 404 {object} ErrorResponse "Job not found"
+// @Failure 409 {object} ErrorResponse "Job not running"
+// @Router /jobs/{id}/cancel [post]
+func (h *JobHandler) HandleCancelJob(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.jobs.Cancel(id)
+	if err != nil {
+		h.sendJobError(c, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, job)
+}
+
+// HandleGetJobOutput handles GET requests to /jobs/{id}/output
+// @Summary Download the output of a job
+// @Description Download the file produced by a completed job, like the archive of an archive job. Outputs are removed when their job is forgotten.
+// @Tags jobs
+// @Produce octet-stream
+// @Param id path string true "Job id"
+// @Param Range header string false "Byte range to download, e.g. bytes=0-1023"
+// @Success 200 {file} file "Output content"
+// @Success 206 {file} file "Partial output content"
+// @Failure 404 {object} ErrorResponse "Job or output not found"
+// @Router /jobs/{id}/output [get]
+func (h *JobHandler) HandleGetJobOutput(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.jobs.Get(id)
+	if err != nil {
+		h.sendJobError(c, err)
+		return
+	}
+	output, err := h.jobs.Output(id)
+	if err != nil {
+		h.sendJobError(c, err)
+		return
+	}
+	file, err := os.Open(output)
+	if err != nil {
+		h.sendJobError(c, jobs.ErrNoOutput)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": job.OutputName}))
+	http.ServeContent(c.Writer, c.Request, job.OutputName, info.ModTime(), file)
+}
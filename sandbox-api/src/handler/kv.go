@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/kv"
+)
+
+// KVHandler handles the scratch key/value store
+type KVHandler struct {
+	*BaseHandler
+	store *kv.Store
+}
+
+// NewKVHandler creates a new key/value handler on the store in KV_DIR
+func NewKVHandler() *KVHandler {
+	return &KVHandler{
+		BaseHandler: NewBaseHandler(),
+		store:       kv.GetStore(),
+	}
+}
+
+// SetKVRequest is the request body for setting a key
+type SetKVRequest struct {
+	// Value is any JSON value: a string, a number, an object...
+	Value json.RawMessage `json:"value" swaggertype:"object" binding:"required"`
+	// TTL is the number of seconds after which the entry expires, never when 0
+	TTL int `json:"ttl" example:"3600" binding:"gte=0"`
+} // @name SetKVRequest
+
+// kvKey returns the key of a request, the rest of the path after /kv/
+func kvKey(c *gin.Context) string {
+	return strings.TrimPrefix(c.Param("key"), "/")
+}
+
+// HandleListKV handles GET requests to /kv
+// @Summary List keys
+// @Description List the keys of the scratch key/value store, sorted, with the size and expiration of their value but not the value itself. Expired keys are not listed.
+// @Tags kv
+// @Produce json
+// @Param prefix query string false "Only list the keys starting with prefix, e.g. build/"
+// @Success 200 {array} kv.Entry "Keys"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /kv [get]
+func (h *KVHandler) HandleListKV(c *gin.Context) {
+	entries, err := h.store.List(c.Query("prefix"))
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, entries)
+}
+
+// HandleGetKV handles GET requests to /kv/{key}
+// @Summary Get a key
+// @Description Get the value of a key of the scratch key/value store. Keys may contain slashes, e.g. /kv/build/token. With raw=true, a string value is returned as plain text and any other value as JSON, for shell scripts to use it as is.
+// @Tags kv
+// @Produce json,plain
+// @Param key path string true "Key"
+// @Param raw query boolean false "Return the value itself rather than the entry"
+// @Success 200 {object} kv.Entry "Entry"
+// @Failure 400 {object} ErrorResponse "Invalid key"
+// @Failure 404 {object} ErrorResponse "Key not found or expired"
+// @Router /kv/{key} [get]
+func (h *KVHandler) HandleGetKV(c *gin.Context) {
+	entry, err := h.store.Get(kvKey(c))
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if c.Query("raw") == "true" {
+		var text string
+		if err := json.Unmarshal(entry.Value, &text); err == nil {
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+			return
+		}
+		c.Data(http.StatusOK, "application/json", entry.Value)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, entry)
+}
+
+// HandleSetKV handles PUT requests to /kv/{key}
+// @Summary Set a key
+// @Description Set the value of a key of the scratch key/value store, replacing its previous value and TTL. Entries are kept on disk in KV_DIR, readable only by the API, and survive restarts until they expire. Values are limited to 1MiB.
+// @Tags kv
+// @Accept json
+// @Produce json
+// @Param key path string true "Key"
+// @Param request body SetKVRequest true "Value and TTL"
+// @Success 200 {object} kv.Entry "Entry"
+// @Failure 400 {object} ErrorResponse "Invalid key or value"
+// @Failure 413 {object} ErrorResponse "Value too large"
+// @Router /kv/{key} [put]
+func (h *KVHandler) HandleSetKV(c *gin.Context) {
+	var req SetKVRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := h.store.Set(kvKey(c), req.Value, time.Duration(req.TTL)*time.Second)
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, entry)
+}
+
+// HandleDeleteKV handles DELETE requests to /kv/{key}
+// @Summary Delete a key
+// @Description Delete a key of the scratch key/value store
+// @Tags kv
+// @Produce json
+// @Param key path string true "Key"
+// @Success 200 {object} SuccessResponse "Key deleted"
+// @Failure 400 {object} ErrorResponse "Invalid key"
+// @Failure 404 {object} ErrorResponse "Key not found or expired"
+// @Router /kv/{key} [delete]
+func (h *KVHandler) HandleDeleteKV(c *gin.Context) {
+	if err := h.store.Delete(kvKey(c)); err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendSuccess(c, "Key deleted successfully")
+}
@@ -0,0 +1,283 @@
+// Package kv is a scratch key/value store for the agents and tools working in the
+// sandbox to pass state between steps, like tokens or intermediate JSON results, without
+// temporary file conventions or environment variables. Entries are JSON values kept on
+// disk, one file per key, and expire after their TTL.
+package kv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+const (
+	// MaxKeyBytes is the maximum size of a key
+	MaxKeyBytes = 512
+	// MaxValueBytes is the maximum size of the JSON encoding of a value
+	MaxValueBytes = 1 << 20
+	// entryExtension is the extension of the files of the entries
+	entryExtension = ".json"
+)
+
+// ErrKeyNotFound is returned for a key which is not set or has expired
+var ErrKeyNotFound = apierror.New(apierror.CodeNotFound, "key not found")
+
+// Entry is a value and when it was set
+type Entry struct {
+	Key string `json:"key" example:"build/token" binding:"required"`
+	// Value is any JSON value, omitted when listing
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+	// Size is the size of the JSON encoding of the value in bytes
+	Size      int       `json:"size" example:"42" binding:"required"`
+	CreatedAt time.Time `json:"createdAt" binding:"required"`
+	UpdatedAt time.Time `json:"updatedAt" binding:"required"`
+	// ExpiresAt is when the entry is deleted, never when not set
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+} // @name KVEntry
+
+// expired reports whether the entry has expired at now
+func (e Entry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// Store keeps the entries in a directory
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// Global store instance
+var (
+	store     *Store
+	storeOnce sync.Once
+)
+
+// GetStore returns the store of the API, in KV_DIR
+func GetStore() *Store {
+	storeOnce.Do(func() {
+		store = NewStore(DirFromEnv())
+	})
+	return store
+}
+
+// NewStore creates a store keeping its entries in dir, created when the first entry is set
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DirFromEnv returns the directory of the entries, read from KV_DIR and defaulting to
+// sandbox-kv in the temp directory
+func DirFromEnv() string {
+	if dir := os.Getenv("KV_DIR"); dir != "" {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(os.TempDir(), "sandbox-kv")
+}
+
+// ValidateKey checks that a key is not empty, not longer than MaxKeyBytes and has no
+// control characters
+func ValidateKey(key string) error {
+	if key == "" {
+		return apierror.New(apierror.CodeInvalidRequest, "key is required")
+	}
+	if len(key) > MaxKeyBytes {
+		return apierror.Newf(apierror.CodeInvalidRequest, "key is longer than %d bytes", MaxKeyBytes)
+	}
+	if strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return apierror.New(apierror.CodeInvalidRequest, "key contains control characters")
+	}
+	return nil
+}
+
+// Set sets the value of a key, replacing the previous one. With a positive ttl, the
+// entry expires after ttl.
+func (s *Store) Set(key string, value json.RawMessage, ttl time.Duration) (Entry, error) {
+	if err := ValidateKey(key); err != nil {
+		return Entry{}, err
+	}
+	if !json.Valid(value) {
+		return Entry{}, apierror.New(apierror.CodeInvalidRequest, "value is not valid JSON")
+	}
+	if len(value) > MaxValueBytes {
+		return Entry{}, apierror.Newf(apierror.CodePayloadTooLarge, "value is larger than %d bytes", MaxValueBytes)
+	}
+	if ttl < 0 {
+		return Entry{}, apierror.New(apierror.CodeInvalidRequest, "ttl must not be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	entry := Entry{Key: key, Value: value, Size: len(value), CreatedAt: now, UpdatedAt: now}
+	if previous, err := s.read(key); err == nil && !previous.expired(now) {
+		entry.CreatedAt = previous.CreatedAt
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		entry.ExpiresAt = &expiresAt
+	}
+	if err := s.write(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Get returns the entry of a key
+func (s *Store) Get(key string) (Entry, error) {
+	if err := ValidateKey(key); err != nil {
+		return Entry{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.read(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	if entry.expired(time.Now()) {
+		s.remove(key)
+		return Entry{}, ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+// Delete deletes the entry of a key
+func (s *Store) Delete(key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.read(key)
+	if err != nil {
+		return err
+	}
+	s.remove(key)
+	if entry.expired(time.Now()) {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// List returns the entries whose key starts with prefix, without their value, sorted by
+// key. Expired entries are deleted.
+func (s *Store) List(prefix string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]Entry, 0, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), entryExtension) {
+			continue
+		}
+		entry, err := readEntry(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			logrus.Debugf("Skipping key/value entry %s: %v", file.Name(), err)
+			continue
+		}
+		if !strings.HasPrefix(entry.Key, prefix) {
+			continue
+		}
+		if entry.expired(now) {
+			s.remove(entry.Key)
+			continue
+		}
+		entry.Value = nil
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// path returns the file of the entry of a key, named after the SHA-256 of the key so
+// that any key is a valid file name
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+entryExtension)
+}
+
+// read reads the entry of a key. s.mu is held by the caller.
+func (s *Store) read(key string) (Entry, error) {
+	return readEntry(s.path(key))
+}
+
+func readEntry(path string) (Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Entry{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// write writes an entry to a temporary file renamed over its file, so that a crash
+// never leaves a partial entry. Entries may hold secrets, only the owner can read them.
+// s.mu is held by the caller.
+func (s *Store) write(entry Entry) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(entry.Key)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// remove removes the file of the entry of a key. s.mu is held by the caller.
+func (s *Store) remove(key string) {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logrus.Warnf("Failed to delete key/value entry %s: %v", key, err)
+	}
+}
@@ -0,0 +1,104 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestStore tests setting, getting, listing and deleting keys
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	if _, err := s.Set("build/token", json.RawMessage(`"s3cr3t"`), 0); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	first, _ := s.Get("build/token")
+	if _, err := s.Set("build/token", json.RawMessage(`"rotated"`), 0); err != nil {
+		t.Fatalf("Failed to replace: %v", err)
+	}
+	if _, err := s.Set("build/result", json.RawMessage(`{"passed": 12}`), 0); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if _, err := s.Set("deploy", json.RawMessage(`true`), 0); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+
+	entry, err := s.Get("build/token")
+	if err != nil || string(entry.Value) != `"rotated"` || !entry.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("Unexpected entry %+v (%v)", entry, err)
+	}
+	// A new store on the same directory reads the entries back
+	entries, err := NewStore(dir).List("build/")
+	if err != nil || len(entries) != 2 || entries[0].Key != "build/result" || entries[1].Key != "build/token" {
+		t.Fatalf("Unexpected entries %+v (%v)", entries, err)
+	}
+	if entries[0].Value != nil || entries[0].Size != len(`{"passed": 12}`) {
+		t.Errorf("Expected the listing to have sizes and no values, got %+v", entries[0])
+	}
+	if info, err := os.Stat(s.path("build/token")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the entries to only be readable by their owner, got %v (%v)", info, err)
+	}
+
+	if err := s.Delete("deploy"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, err := s.Get("deploy"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected the deleted key not to be found, got %v", err)
+	}
+	if err := s.Delete("deploy"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected deleting a missing key to fail, got %v", err)
+	}
+}
+
+// TestStoreTTL tests that entries expire after their TTL
+func TestStoreTTL(t *testing.T) {
+	s := NewStore(t.TempDir())
+	entry, err := s.Set("session", json.RawMessage(`"abc"`), 50*time.Millisecond)
+	if err != nil || entry.ExpiresAt == nil {
+		t.Fatalf("Expected an expiring entry, got %+v (%v)", entry, err)
+	}
+	if _, err := s.Get("session"); err != nil {
+		t.Fatalf("Expected the entry before its TTL, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := s.Get("session"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected the entry to expire, got %v", err)
+	}
+	if entries, _ := s.List(""); len(entries) != 0 {
+		t.Errorf("Expected expired entries not to be listed, got %+v", entries)
+	}
+}
+
+// TestStoreInvalid tests that invalid keys and values are rejected
+func TestStoreInvalid(t *testing.T) {
+	s := NewStore(t.TempDir())
+	tests := []struct {
+		key   string
+		value string
+		code  apierror.Code
+	}{
+		{"", `1`, apierror.CodeInvalidRequest},
+		{"line\nbreak", `1`, apierror.CodeInvalidRequest},
+		{strings.Repeat("k", MaxKeyBytes+1), `1`, apierror.CodeInvalidRequest},
+		{"key", `{"unterminated"`, apierror.CodeInvalidRequest},
+		{"key", `"` + strings.Repeat("v", MaxValueBytes) + `"`, apierror.CodePayloadTooLarge},
+	}
+	for _, tt := range tests {
+		_, err := s.Set(tt.key, json.RawMessage(tt.value), 0)
+		if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != tt.code {
+			t.Errorf("Expected %s for key %.20q, got %v", tt.code, tt.key, err)
+		}
+	}
+	// Keys of any length up to the limit are valid file names
+	if _, err := s.Set(strings.Repeat("k", MaxKeyBytes), json.RawMessage(`1`), 0); err != nil {
+		t.Errorf("Failed to set the longest key: %v", err)
+	}
+}
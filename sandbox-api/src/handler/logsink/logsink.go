@@ -0,0 +1,428 @@
+// Package logsink forwards the output of managed processes to external log sinks:
+// syslog servers, HTTP endpoints and Loki. Output is split into lines, labeled with
+// the sandbox and the process, and sent in batches by one worker per sink.
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Types of sinks
+const (
+	TypeSyslog = "syslog"
+	TypeHTTP   = "http"
+	TypeLoki   = "loki"
+)
+
+// Sources of the sinks of a forwarder
+const (
+	SourceNone = "none"
+	SourceEnv  = "env"
+	SourceAPI  = "api"
+)
+
+const (
+	// queueSize is the number of lines queued for a sink before new ones are dropped
+	queueSize = 10000
+	// maxBatchSize is the largest number of lines sent at once
+	maxBatchSize = 500
+	// flushInterval is how long lines wait to be batched, and partial lines to be completed
+	flushInterval = time.Second
+	// maxLineBytes is the length above which a line without a newline is sent as is
+	maxLineBytes = 16 * 1024
+	// maxAttempts is the number of times a batch is sent before it is dropped
+	maxAttempts = 3
+	// redacted replaces the header values of the returned sinks
+	redacted = "[REDACTED]"
+)
+
+// SinkConfig configures a sink output is forwarded to
+type SinkConfig struct {
+	Name string `json:"name" example:"loki" binding:"required"`
+	Type string `json:"type" example:"loki" enums:"syslog,http,loki" binding:"required"`
+	// URL is udp://, tcp:// or unix:// for syslog, the endpoint lines are POSTed to as
+	// JSON for http, and the push API for loki (/loki/api/v1/push when it has no path)
+	URL string `json:"url" example:"http://loki:3100" binding:"required"`
+	// Headers are sent with the requests of http and loki sinks, e.g. Authorization
+	Headers map[string]string `json:"headers,omitempty"`
+	// Labels are added to the sandbox, process and stream labels of every line
+	Labels map[string]string `json:"labels,omitempty" example:"{\"env\": \"staging\"}"`
+} // @name LogSinkConfig
+
+// Validate checks the type and URL of a sink
+func (c *SinkConfig) Validate() error {
+	if c.Name == "" {
+		return apierror.New(apierror.CodeInvalidRequest, "log sink name is required")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || c.URL == "" {
+		return apierror.Newf(apierror.CodeInvalidRequest, "invalid url of log sink %s", c.Name)
+	}
+	switch c.Type {
+	case TypeSyslog:
+		if u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "unix" {
+			return apierror.Newf(apierror.CodeInvalidRequest, "invalid url of log sink %s: syslog sinks use udp://, tcp:// or unix://", c.Name)
+		}
+	case TypeHTTP, TypeLoki:
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return apierror.Newf(apierror.CodeInvalidRequest, "invalid url of log sink %s: %s sinks use http:// or https://", c.Name, c.Type)
+		}
+	default:
+		return apierror.Newf(apierror.CodeInvalidRequest, "invalid type of log sink %s: must be syslog, http or loki", c.Name)
+	}
+	return nil
+}
+
+// redact returns a copy of the config without its header values
+func (c SinkConfig) redact() SinkConfig {
+	if len(c.Headers) == 0 {
+		return c
+	}
+	headers := make(map[string]string, len(c.Headers))
+	for key := range c.Headers {
+		headers[key] = redacted
+	}
+	c.Headers = headers
+	return c
+}
+
+// SinkStatus is a sink with the counts of the lines it forwarded
+type SinkStatus struct {
+	SinkConfig
+	Sent        int64      `json:"sent" example:"1024" binding:"required"`
+	Dropped     int64      `json:"dropped" example:"0" binding:"required"` // lines dropped because the queue was full or sending failed
+	LastError   string     `json:"lastError,omitempty" example:"connection refused"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+} // @name LogSinkStatus
+
+// Line is a line of output of a process
+type Line struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Sandbox   string            `json:"sandbox"`
+	PID       string            `json:"pid"`
+	Process   string            `json:"process"`
+	Stream    string            `json:"stream"` // stdout or stderr
+	Message   string            `json:"message"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// partialLine is the output of a stream not terminated by a newline yet
+type partialLine struct {
+	line  Line
+	data  []byte
+	since time.Time
+}
+
+// Forwarder forwards the output of processes to the configured sinks
+type Forwarder struct {
+	source  string
+	workers []*worker
+	partial map[string]*partialLine
+	sandbox string
+	mu      sync.Mutex
+	flusher sync.Once
+}
+
+// Global forwarder instance
+var (
+	forwarder     *Forwarder
+	forwarderOnce sync.Once
+)
+
+// GetForwarder returns the forwarder, without sinks until they are configured
+func GetForwarder() *Forwarder {
+	forwarderOnce.Do(func() {
+		forwarder = NewForwarder(lib.SandboxName())
+	})
+	return forwarder
+}
+
+// NewForwarder creates a forwarder without sinks, labeling lines with the sandbox name
+func NewForwarder(sandbox string) *Forwarder {
+	return &Forwarder{
+		source:  SourceNone,
+		partial: make(map[string]*partialLine),
+		sandbox: sandbox,
+	}
+}
+
+// ConfigureFromEnv sets the sinks of the JSON array of LOG_SINKS, if set
+func (f *Forwarder) ConfigureFromEnv() error {
+	value := os.Getenv("LOG_SINKS")
+	if value == "" {
+		return nil
+	}
+	var sinks []SinkConfig
+	if err := json.Unmarshal([]byte(value), &sinks); err != nil {
+		return fmt.Errorf("invalid LOG_SINKS: %w", err)
+	}
+	if err := f.set(sinks, SourceEnv); err != nil {
+		return err
+	}
+	logrus.Infof("Forwarding process output to %d log sinks", len(sinks))
+	return nil
+}
+
+// SetSinks replaces the sinks. Lines queued for the previous sinks are still sent.
+func (f *Forwarder) SetSinks(sinks []SinkConfig) error {
+	return f.set(sinks, SourceAPI)
+}
+
+func (f *Forwarder) set(sinks []SinkConfig, source string) error {
+	names := make(map[string]bool, len(sinks))
+	for i := range sinks {
+		if err := sinks[i].Validate(); err != nil {
+			return err
+		}
+		if names[sinks[i].Name] {
+			return apierror.Newf(apierror.CodeInvalidRequest, "duplicate log sink name %s", sinks[i].Name)
+		}
+		names[sinks[i].Name] = true
+	}
+	workers := make([]*worker, 0, len(sinks))
+	for _, config := range sinks {
+		workers = append(workers, newWorker(config, newSink(config)))
+	}
+
+	f.mu.Lock()
+	previous := f.workers
+	f.workers = workers
+	f.source = source
+	if len(sinks) == 0 {
+		f.source = SourceNone
+		f.partial = make(map[string]*partialLine)
+	}
+	f.mu.Unlock()
+
+	for _, w := range previous {
+		w.stop()
+	}
+	if len(workers) > 0 {
+		f.flusher.Do(func() { go f.flushPartialLines() })
+	}
+	return nil
+}
+
+// Sinks returns the sinks, without their header values, and where they come from:
+// none, env or api
+func (f *Forwarder) Sinks() ([]SinkStatus, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	statuses := make([]SinkStatus, 0, len(f.workers))
+	for _, w := range f.workers {
+		statuses = append(statuses, w.status())
+	}
+	return statuses, f.source
+}
+
+// Write forwards output of a process, the last line being held until it is terminated
+// by a newline or for a second. It never blocks: lines are dropped for the sinks whose
+// queue is full.
+func (f *Forwarder) Write(pid string, name string, stream string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.workers) == 0 {
+		return
+	}
+
+	key := pid + ":" + stream
+	partial := f.partial[key]
+	now := time.Now()
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			if partial == nil {
+				partial = &partialLine{line: Line{PID: pid, Process: name, Stream: stream}, since: now}
+				f.partial[key] = partial
+			}
+			partial.data = append(partial.data, data...)
+			if len(partial.data) >= maxLineBytes {
+				f.emitPartial(key, partial)
+			}
+			return
+		}
+
+		message := data[:i]
+		if partial != nil {
+			message = append(partial.data, message...)
+			delete(f.partial, key)
+			partial = nil
+		}
+		f.emit(Line{Timestamp: now, PID: pid, Process: name, Stream: stream, Message: string(bytes.TrimSuffix(message, []byte("\r")))})
+		data = data[i+1:]
+	}
+}
+
+// emit queues a line for every sink. The mutex must be held.
+func (f *Forwarder) emit(line Line) {
+	line.Sandbox = f.sandbox
+	for _, w := range f.workers {
+		w.enqueue(line)
+	}
+}
+
+// emitPartial sends a partial line as is. The mutex must be held.
+func (f *Forwarder) emitPartial(key string, partial *partialLine) {
+	line := partial.line
+	line.Timestamp = partial.since
+	line.Message = string(partial.data)
+	delete(f.partial, key)
+	f.emit(line)
+}
+
+// flushPartialLines sends the lines left without a newline for longer than flushInterval
+func (f *Forwarder) flushPartialLines() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		f.mu.Lock()
+		for key, partial := range f.partial {
+			if now.Sub(partial.since) >= flushInterval {
+				f.emitPartial(key, partial)
+			}
+		}
+		f.mu.Unlock()
+	}
+}
+
+// Close stops forwarding, waiting until the queued lines are sent or the timeout expires
+func (f *Forwarder) Close(timeout time.Duration) {
+	f.mu.Lock()
+	for key, partial := range f.partial {
+		f.emitPartial(key, partial)
+	}
+	workers := f.workers
+	f.workers = nil
+	f.mu.Unlock()
+
+	deadline := time.After(timeout)
+	for _, w := range workers {
+		w.stop()
+		select {
+		case <-w.done:
+		case <-deadline:
+			logrus.Warnf("Log sink %s did not send its queued lines in time", w.config.Name)
+			return
+		}
+	}
+}
+
+// worker queues and sends the lines of a sink
+type worker struct {
+	config      SinkConfig
+	sink        sink
+	queue       chan Line
+	done        chan struct{}
+	stopOnce    sync.Once
+	sent        atomic.Int64
+	dropped     atomic.Int64
+	errMu       sync.Mutex
+	failing     bool
+	lastError   string
+	lastErrorAt *time.Time
+}
+
+func newWorker(config SinkConfig, sink sink) *worker {
+	w := &worker{config: config, sink: sink, queue: make(chan Line, queueSize), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// enqueue queues a line, dropping it when the queue is full
+func (w *worker) enqueue(line Line) {
+	select {
+	case w.queue <- line:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// stop stops the worker once the queued lines are sent
+func (w *worker) stop() {
+	w.stopOnce.Do(func() { close(w.queue) })
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+	defer func() { _ = w.sink.Close() }()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]Line, 0, maxBatchSize)
+	for {
+		select {
+		case line, ok := <-w.queue:
+			if !ok {
+				w.send(batch)
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) == maxBatchSize {
+				w.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.send(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// send sends a batch, retrying with a growing delay before dropping it
+func (w *worker) send(batch []Line) {
+	if len(batch) == 0 {
+		return
+	}
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = w.sink.Send(batch); err == nil {
+			w.sent.Add(int64(len(batch)))
+			w.errMu.Lock()
+			w.failing = false
+			w.errMu.Unlock()
+			return
+		}
+		if attempt < maxAttempts {
+			time.Sleep(time.Duration(attempt) * flushInterval)
+		}
+	}
+
+	w.dropped.Add(int64(len(batch)))
+	now := time.Now()
+	w.errMu.Lock()
+	wasFailing := w.failing
+	w.failing, w.lastError, w.lastErrorAt = true, err.Error(), &now
+	w.errMu.Unlock()
+	// Only the first failure is logged until the sink recovers
+	if !wasFailing {
+		logrus.Warnf("Failed to forward logs to sink %s, dropping %d lines: %v", w.config.Name, len(batch), err)
+	}
+}
+
+// status returns the config and counts of the sink
+func (w *worker) status() SinkStatus {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	return SinkStatus{
+		SinkConfig:  w.config.redact(),
+		Sent:        w.sent.Load(),
+		Dropped:     w.dropped.Load(),
+		LastError:   w.lastError,
+		LastErrorAt: w.lastErrorAt,
+	}
+}
@@ -0,0 +1,172 @@
+package logsink
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver collects the bodies POSTed to a test server
+type receiver struct {
+	mu     sync.Mutex
+	paths  []string
+	bodies [][]byte
+	got    chan struct{}
+}
+
+func newReceiver(t *testing.T) (*receiver, *httptest.Server) {
+	r := &receiver{got: make(chan struct{}, 100)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body json.RawMessage
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		r.mu.Lock()
+		r.paths = append(r.paths, req.URL.Path+" "+req.Header.Get("Authorization"))
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+		r.got <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *receiver) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the sink to receive lines")
+	}
+}
+
+// TestForwarderHTTPSink tests that output is split into labeled lines, partial lines
+// being completed by the next write
+func TestForwarderHTTPSink(t *testing.T) {
+	r, server := newReceiver(t)
+	f := NewForwarder("my-sandbox")
+	sink := SinkConfig{Name: "http", Type: TypeHTTP, URL: server.URL + "/logs", Headers: map[string]string{"Authorization": "Bearer secret"}, Labels: map[string]string{"env": "test"}}
+	if err := f.SetSinks([]SinkConfig{sink}); err != nil {
+		t.Fatalf("Failed to set sinks: %v", err)
+	}
+
+	f.Write("42", "web", "stdout", []byte("first\nsec"))
+	f.Write("42", "web", "stdout", []byte("ond\r\n"))
+	f.Write("42", "web", "stderr", []byte("oops\n"))
+	r.wait(t)
+
+	var lines []Line
+	r.mu.Lock()
+	_ = json.Unmarshal(r.bodies[0], &lines)
+	path := r.paths[0]
+	r.mu.Unlock()
+	if path != "/logs Bearer secret" {
+		t.Errorf("Expected the lines to be POSTed with the headers, got %q", path)
+	}
+	if len(lines) != 3 || lines[0].Message != "first" || lines[1].Message != "second" || lines[2].Stream != "stderr" {
+		t.Fatalf("Unexpected lines: %+v", lines)
+	}
+	if lines[0].Sandbox != "my-sandbox" || lines[0].Process != "web" || lines[0].PID != "42" || lines[0].Labels["env"] != "test" {
+		t.Errorf("Expected labeled lines, got %+v", lines[0])
+	}
+
+	sinks, source := f.Sinks()
+	if source != SourceAPI || len(sinks) != 1 || sinks[0].Headers["Authorization"] != redacted {
+		t.Errorf("Expected the sink with redacted headers, got %+v (%s)", sinks, source)
+	}
+	f.Close(5 * time.Second)
+	if sinks, _ := f.Sinks(); len(sinks) != 0 || sinks == nil {
+		t.Errorf("Expected no sinks once closed, got %+v", sinks)
+	}
+}
+
+// TestLokiSink tests that lines are pushed as one Loki stream per process and stream
+func TestLokiSink(t *testing.T) {
+	r, server := newReceiver(t)
+	f := NewForwarder("my-sandbox")
+	if err := f.SetSinks([]SinkConfig{{Name: "loki", Type: TypeLoki, URL: server.URL}}); err != nil {
+		t.Fatalf("Failed to set sinks: %v", err)
+	}
+	defer f.Close(time.Second)
+
+	f.Write("42", "web", "stdout", []byte("a\nb\n"))
+	f.Write("43", "worker", "stdout", []byte("c\n"))
+	r.wait(t)
+
+	var push struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	r.mu.Lock()
+	_ = json.Unmarshal(r.bodies[0], &push)
+	path := r.paths[0]
+	r.mu.Unlock()
+	if path != "/loki/api/v1/push " {
+		t.Errorf("Expected the push API path, got %q", path)
+	}
+	if len(push.Streams) != 2 || len(push.Streams[0].Values) != 2 || push.Streams[1].Values[0][1] != "c" {
+		t.Fatalf("Unexpected streams: %+v", push.Streams)
+	}
+	labels := push.Streams[0].Stream
+	if labels["sandbox"] != "my-sandbox" || labels["process"] != "web" || labels["stream"] != "stdout" {
+		t.Errorf("Unexpected stream labels: %+v", labels)
+	}
+}
+
+// TestSyslogSink tests that lines are sent as RFC 5424 messages
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	f := NewForwarder("my-sandbox")
+	if err := f.SetSinks([]SinkConfig{{Name: "syslog", Type: TypeSyslog, URL: "udp://" + conn.LocalAddr().String()}}); err != nil {
+		t.Fatalf("Failed to set sinks: %v", err)
+	}
+	defer f.Close(time.Second)
+	f.Write("42", "my web", "stderr", []byte("failed \"badly\"\n"))
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to receive a message: %v", err)
+	}
+	message := string(buf[:n])
+	if !strings.HasPrefix(message, "<11>1 ") || !strings.Contains(message, " my-sandbox my_web 42 - [labels@32473 process=\"my web\" sandbox=\"my-sandbox\" stream=\"stderr\"] failed \"badly\"") {
+		t.Errorf("Unexpected syslog message: %q", message)
+	}
+}
+
+// TestSetSinksValidation tests that invalid sinks are rejected, keeping the current ones
+func TestSetSinksValidation(t *testing.T) {
+	f := NewForwarder("my-sandbox")
+	invalid := [][]SinkConfig{
+		{{Name: "", Type: TypeHTTP, URL: "http://localhost"}},
+		{{Name: "a", Type: "kafka", URL: "http://localhost"}},
+		{{Name: "a", Type: TypeSyslog, URL: "http://localhost"}},
+		{{Name: "a", Type: TypeLoki, URL: "udp://localhost"}},
+		{{Name: "a", Type: TypeHTTP, URL: "http://localhost"}, {Name: "a", Type: TypeHTTP, URL: "http://localhost"}},
+	}
+	for _, sinks := range invalid {
+		if err := f.SetSinks(sinks); err == nil {
+			t.Errorf("Expected an error for %+v", sinks)
+		}
+	}
+	if sinks, source := f.Sinks(); len(sinks) != 0 || source != SourceNone {
+		t.Errorf("Expected no sinks, got %+v (%s)", sinks, source)
+	}
+
+	t.Setenv("LOG_SINKS", `[{"name":"local","type":"syslog","url":"udp://127.0.0.1:514"}]`)
+	if err := f.ConfigureFromEnv(); err != nil {
+		t.Fatalf("Failed to configure from env: %v", err)
+	}
+	defer f.Close(time.Second)
+	if sinks, source := f.Sinks(); len(sinks) != 1 || source != SourceEnv {
+		t.Errorf("Expected the sink of LOG_SINKS, got %+v (%s)", sinks, source)
+	}
+}
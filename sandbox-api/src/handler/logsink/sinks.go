@@ -0,0 +1,259 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds the sending of a batch
+const sendTimeout = 10 * time.Second
+
+// sink sends batches of lines to an external system. Batches are sent one at a time.
+type sink interface {
+	Send(lines []Line) error
+	Close() error
+}
+
+// newSink returns the sink of a validated config
+func newSink(config SinkConfig) sink {
+	switch config.Type {
+	case TypeSyslog:
+		return &syslogSink{config: config}
+	case TypeLoki:
+		return &lokiSink{httpSink{config: config, client: &http.Client{Timeout: sendTimeout}}}
+	default:
+		return &httpSink{config: config, client: &http.Client{Timeout: sendTimeout}}
+	}
+}
+
+// labels returns the labels of a line: sandbox, process and stream, and the labels of
+// the sink
+func labels(config SinkConfig, line Line) map[string]string {
+	labels := make(map[string]string, len(config.Labels)+3)
+	for key, value := range config.Labels {
+		labels[key] = value
+	}
+	labels["sandbox"] = line.Sandbox
+	labels["process"] = line.Process
+	labels["stream"] = line.Stream
+	return labels
+}
+
+// syslogSink sends lines as RFC 5424 messages, over UDP, TCP or a unix socket. Messages
+// sent over stream connections are terminated by a newline.
+type syslogSink struct {
+	config SinkConfig
+	conn   net.Conn
+	framed bool
+}
+
+// Syslog severities of the streams, with the user-level facility
+const (
+	syslogPriorityStdout = 1*8 + 6 // user.info
+	syslogPriorityStderr = 1*8 + 3 // user.err
+)
+
+func (s *syslogSink) dial() error {
+	u, _ := url.Parse(s.config.URL)
+	var err error
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		if path == "" {
+			path = u.Host
+		}
+		// Local syslog daemons listen on datagram sockets, others on stream sockets
+		s.conn, err = net.DialTimeout("unixgram", path, sendTimeout)
+		s.framed = false
+		if err != nil {
+			s.conn, err = net.DialTimeout("unix", path, sendTimeout)
+			s.framed = true
+		}
+	default:
+		s.conn, err = net.DialTimeout(u.Scheme, u.Host, sendTimeout)
+		s.framed = u.Scheme == "tcp"
+	}
+	return err
+}
+
+func (s *syslogSink) Send(lines []Line) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+
+	for _, line := range lines {
+		message := syslogMessage(s.config, line)
+		if s.framed {
+			message += "\n"
+		}
+		if _, err := io.WriteString(s.conn, message); err != nil {
+			// Reconnect on the next batch
+			_ = s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// syslogMessage formats a line as an RFC 5424 message, its labels being structured data
+func syslogMessage(config SinkConfig, line Line) string {
+	priority := syslogPriorityStdout
+	if line.Stream == "stderr" {
+		priority = syslogPriorityStderr
+	}
+
+	lineLabels := labels(config, line)
+	keys := make([]string, 0, len(lineLabels))
+	for key := range lineLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var data strings.Builder
+	data.WriteString("[labels@32473")
+	for _, key := range keys {
+		fmt.Fprintf(&data, " %s=\"%s\"", syslogHeader(key, 32), syslogParamEscaper.Replace(lineLabels[key]))
+	}
+	data.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %s - %s %s",
+		priority,
+		line.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeader(line.Sandbox, 255),
+		syslogHeader(line.Process, 48),
+		syslogHeader(line.PID, 128),
+		data.String(),
+		line.Message,
+	)
+}
+
+// syslogParamEscaper escapes the values of structured data parameters
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeader makes a header field printable and without spaces, "-" when empty
+func syslogHeader(value string, maxLength int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > maxLength {
+		value = value[:maxLength]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// httpSink POSTs lines to an endpoint as a JSON array
+type httpSink struct {
+	config SinkConfig
+	client *http.Client
+}
+
+func (s *httpSink) Send(lines []Line) error {
+	labeled := make([]Line, len(lines))
+	for i, line := range lines {
+		line.Labels = s.config.Labels
+		labeled[i] = line
+	}
+	body, err := json.Marshal(labeled)
+	if err != nil {
+		return err
+	}
+	return s.post(s.config.URL, body)
+}
+
+// post POSTs a JSON body with the headers of the sink
+func (s *httpSink) post(endpoint string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// lokiSink pushes lines to the Loki push API, one stream per label set
+type lokiSink struct {
+	httpSink
+}
+
+// lokiStream is a stream of the Loki push API, its values being timestamp in
+// nanoseconds and line pairs
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) Send(lines []Line) error {
+	streams := make([]*lokiStream, 0)
+	byKey := make(map[string]*lokiStream)
+	for _, line := range lines {
+		key := line.Process + "\x00" + line.Stream
+		stream, exists := byKey[key]
+		if !exists {
+			stream = &lokiStream{Stream: labels(s.config, line), Values: make([][2]string, 0)}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(line.Timestamp.UnixNano(), 10), line.Message})
+	}
+
+	body, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return err
+	}
+	return s.post(s.pushURL(), body)
+}
+
+// pushURL returns the URL of the sink, with the path of the push API when it has none
+func (s *lokiSink) pushURL() string {
+	u, _ := url.Parse(s.config.URL)
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/loki/api/v1/push"
+	}
+	return u.String()
+}
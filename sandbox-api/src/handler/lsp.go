@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/lsp"
+	"github.com/blaxel-ai/sandbox-api/src/lib/cors"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+)
+
+// lspWriteTimeout bounds the time spent writing a language server message to a client
+const lspWriteTimeout = 10 * time.Second
+
+// LSPHandler bridges language servers running in the sandbox to WebSocket clients
+type LSPHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+	upgrader   websocket.Upgrader
+}
+
+// NewLSPHandler creates a new language server handler resolving workspaces like the
+// filesystem handler
+func NewLSPHandler(fsHandler *FileSystemHandler) *LSPHandler {
+	return &LSPHandler{
+		BaseHandler: NewBaseHandler(),
+		FileSystem:  fsHandler,
+		upgrader: websocket.Upgrader{
+			// Same allowed origins as the CORS middleware of the REST API
+			CheckOrigin:       cors.GetPolicy().CheckOrigin,
+			EnableCompression: true,
+		},
+	}
+}
+
+// LSPListResponse represents the languages with a language server and the running ones
+type LSPListResponse struct {
+	Languages []lsp.Language    `json:"languages" binding:"required"`
+	Sessions  []lsp.SessionInfo `json:"sessions" binding:"required"`
+} // @name LSPListResponse
+
+// HandleListLanguageServers handles GET requests to /lsp
+// @Summary List language servers
+// @Description List the languages with a language server, whether it is installed, and the language servers running for connected clients
+// @Tags lsp
+// @Produce json
+// @Success 200 {object} LSPListResponse "Language servers"
+// @Router /lsp [get]
+func (h *LSPHandler) HandleListLanguageServers(c *gin.Context) {
+	manager := lsp.GetManager()
+	h.SendJSON(c, http.StatusOK, LSPListResponse{
+		Languages: manager.Languages(),
+		Sessions:  manager.Sessions(),
+	})
+}
+
+// HandleLanguageServer handles WebSocket connections to /lsp/{language}
+// @Summary Connect to a language server
+// @Description Start the language server of a language in a workspace and bridge it over a WebSocket connection: each text message is one JSON-RPC message of the Language Server Protocol, without the Content-Length header. The language server runs as long as the connection is open, and is stopped when the client disconnects.
+// @Description
+// @Description Supported languages are go (gopls), typescript and javascript (typescript-language-server), and python (pyright-langserver). The command of a language can be set with LSP_<LANGUAGE>_COMMAND.
+// @Tags lsp
+// @Param language path string true "Language of the server" example(go)
+// @Param workspace query string false "Workspace directory the server runs in, defaults to the working directory"
+// @Success 101 "Switching protocols"
+// @Failure 400 {object} ErrorResponse "Invalid workspace"
+// @Failure 422 {object} ErrorResponse "Language server unavailable"
+// @Router /lsp/{language} [get]
+func (h *LSPHandler) HandleLanguageServer(c *gin.Context) {
+	workspace := c.Query("workspace")
+	if workspace == "" {
+		workspace = "."
+	}
+	workspace, err := h.FileSystem.fs.GetAbsolutePath(workspace)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if isDir, err := h.FileSystem.DirectoryExists(workspace); err != nil || !isDir {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("workspace %s is not a directory", workspace))
+		return
+	}
+
+	session, err := lsp.GetManager().Start(c.Param("language"), workspace)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer session.Close()
+
+	wsConn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already replied with an error status
+		logging.FromContext(c.Request.Context()).Errorf("Failed to upgrade language server connection: %v", err)
+		return
+	}
+	defer func() { _ = wsConn.Close() }()
+
+	// Messages of the server are forwarded until it exits, which closes the connection
+	go func() {
+		defer func() {
+			_ = wsConn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "language server exited"),
+				time.Now().Add(lspWriteTimeout))
+			_ = wsConn.Close()
+		}()
+		for {
+			message, err := session.Read()
+			if err != nil {
+				if err != io.EOF {
+					logging.FromContext(c.Request.Context()).Warnf("Failed to read from language server %s: %v", session.Language, err)
+				}
+				return
+			}
+			_ = wsConn.SetWriteDeadline(time.Now().Add(lspWriteTimeout))
+			if err := wsConn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		messageType, message, err := wsConn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		if err := session.Write(message); err != nil {
+			logging.FromContext(c.Request.Context()).Warnf("Failed to write to language server %s: %v", session.Language, err)
+			return
+		}
+	}
+}
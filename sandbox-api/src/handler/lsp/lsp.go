@@ -0,0 +1,298 @@
+package lsp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// stopTimeout is how long a language server has to exit once its input is closed
+	// before it is killed
+	stopTimeout = 5 * time.Second
+	// maxMessageSize bounds the size of a message read from a language server
+	maxMessageSize = 64 * 1024 * 1024
+)
+
+// defaultServers are the commands of the supported language servers, run over stdio
+var defaultServers = map[string][]string{
+	"go":         {"gopls"},
+	"typescript": {"typescript-language-server", "--stdio"},
+	"javascript": {"typescript-language-server", "--stdio"},
+	"python":     {"pyright-langserver", "--stdio"},
+}
+
+// Language is a language with a language server
+type Language struct {
+	Language  string   `json:"language" example:"go" binding:"required"`
+	Command   []string `json:"command" example:"gopls" binding:"required"`
+	Installed bool     `json:"installed" example:"true" binding:"required"`
+} // @name LSPLanguage
+
+// SessionInfo describes a running language server
+type SessionInfo struct {
+	ID        string    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	Language  string    `json:"language" example:"go" binding:"required"`
+	Workspace string    `json:"workspace" example:"/home/user/app" binding:"required"`
+	PID       int       `json:"pid" example:"1234" binding:"required"`
+	StartedAt time.Time `json:"startedAt" binding:"required"`
+} // @name LSPSession
+
+// Session is a language server started for a client. Messages are the JSON-RPC
+// payloads, without the Content-Length framing of the LSP base protocol.
+type Session struct {
+	SessionInfo
+	stdin      io.WriteCloser
+	stdout     *bufio.Reader
+	stdoutPipe *io.PipeReader
+	writeMu    sync.Mutex
+	done       chan struct{}
+	once       sync.Once
+}
+
+// Manager starts language servers and keeps track of the running ones
+type Manager struct {
+	servers  map[string][]string
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+var (
+	manager     *Manager
+	managerOnce sync.Once
+)
+
+// GetManager returns the language server manager. The command of a language can be
+// overridden with LSP_<LANGUAGE>_COMMAND, which also adds languages.
+func GetManager() *Manager {
+	managerOnce.Do(func() {
+		servers := make(map[string][]string, len(defaultServers))
+		for language, command := range defaultServers {
+			servers[language] = command
+		}
+		for _, env := range os.Environ() {
+			name, value, _ := strings.Cut(env, "=")
+			if !strings.HasPrefix(name, "LSP_") || !strings.HasSuffix(name, "_COMMAND") {
+				continue
+			}
+			language := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(name, "LSP_"), "_COMMAND"))
+			if command := strings.Fields(value); language != "" && len(command) > 0 {
+				servers[language] = command
+			} else {
+				logrus.Warnf("Invalid %s value '%s', ignoring it", name, value)
+			}
+		}
+		manager = NewManager(servers)
+	})
+	return manager
+}
+
+// NewManager creates a manager of language servers run with the given commands
+func NewManager(servers map[string][]string) *Manager {
+	return &Manager{
+		servers:  servers,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Languages returns the languages with a language server, and whether it is installed
+func (m *Manager) Languages() []Language {
+	languages := make([]Language, 0, len(m.servers))
+	for language, command := range m.servers {
+		_, err := exec.LookPath(command[0])
+		languages = append(languages, Language{Language: language, Command: command, Installed: err == nil})
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Language < languages[j].Language })
+	return languages
+}
+
+// Sessions returns the running language servers
+func (m *Manager) Sessions() []SessionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := make([]SessionInfo, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session.SessionInfo)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt.Before(sessions[j].StartedAt) })
+	return sessions
+}
+
+// Start starts the language server of a language in a workspace directory
+func (m *Manager) Start(language, workspace string) (*Session, error) {
+	command, exists := m.servers[language]
+	if !exists {
+		return nil, fmt.Errorf("no language server for language '%s'", language)
+	}
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return nil, fmt.Errorf("language server %s is not installed", command[0])
+	}
+
+	cmd := exec.Command(path, command[1:]...)
+	cmd.Dir = workspace
+	// Own process group, so that the processes the server spawns are stopped with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// Output goes through pipes closed once the server exited and its output was read, so
+	// that no message is lost when it exits
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start language server %s: %w", command[0], err)
+	}
+
+	session := &Session{
+		SessionInfo: SessionInfo{
+			ID:        uuid.New().String(),
+			Language:  language,
+			Workspace: workspace,
+			PID:       cmd.Process.Pid,
+			StartedAt: time.Now(),
+		},
+		stdin:      stdin,
+		stdout:     bufio.NewReader(stdout),
+		stdoutPipe: stdout,
+		done:       make(chan struct{}),
+	}
+
+	// Language servers log to stderr
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logrus.Debugf("[lsp %s %d] %s", language, session.PID, scanner.Text())
+		}
+		_, _ = io.Copy(io.Discard, stderr)
+	}()
+	go func() {
+		_ = cmd.Wait()
+		_ = stdoutWriter.Close()
+		_ = stderrWriter.Close()
+		m.remove(session.ID)
+		close(session.done)
+		logrus.Infof("Language server %s (pid %d) for %s exited", language, session.PID, workspace)
+	}()
+
+	m.mu.Lock()
+	m.sessions[session.ID] = session
+	m.mu.Unlock()
+	logrus.Infof("Language server %s (pid %d) started for %s", language, session.PID, workspace)
+	return session, nil
+}
+
+// remove forgets a session which exited
+func (m *Manager) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+}
+
+// StopAll stops every running language server
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+// Write sends a message to the language server
+func (s *Session) Write(message []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return writeMessage(s.stdin, message)
+}
+
+// Read returns the next message of the language server. It returns io.EOF once the
+// server exited or the session is closed.
+func (s *Session) Read() ([]byte, error) {
+	message, err := readMessage(s.stdout)
+	if errors.Is(err, io.ErrClosedPipe) {
+		return nil, io.EOF
+	}
+	return message, err
+}
+
+// Done is closed once the language server exited
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the language server. Closing its input lets it exit by itself, it is
+// killed with its process group if it is still running after the stop timeout. Its
+// remaining output is discarded.
+func (s *Session) Close() {
+	s.once.Do(func() {
+		_ = s.stdin.Close()
+		_ = s.stdoutPipe.Close()
+		select {
+		case <-s.done:
+		case <-time.After(stopTimeout):
+			_ = syscall.Kill(-s.PID, syscall.SIGKILL)
+			<-s.done
+		}
+	})
+}
+
+// writeMessage writes a message with the framing of the LSP base protocol
+func writeMessage(w io.Writer, message []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(message)); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// readMessage reads a message framed by the LSP base protocol
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if len(header) == 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("invalid message header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header '%s'", header.Get("Content-Length"))
+	}
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d bytes", length, maxMessageSize)
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,76 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMessageFraming tests writing and reading messages framed by the LSP base protocol
+func TestMessageFraming(t *testing.T) {
+	var buf bytes.Buffer
+	messages := []string{`{"jsonrpc":"2.0","id":1,"method":"initialize"}`, `{"jsonrpc":"2.0","method":"exit"}`}
+	for _, message := range messages {
+		if err := writeMessage(&buf, []byte(message)); err != nil {
+			t.Fatalf("Failed to write message: %v", err)
+		}
+	}
+
+	reader := bufio.NewReader(&buf)
+	for _, expected := range messages {
+		message, err := readMessage(reader)
+		if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if string(message) != expected {
+			t.Errorf("Expected %s, got %s", expected, message)
+		}
+	}
+	if _, err := readMessage(reader); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+
+	invalid := bufio.NewReader(strings.NewReader("Content-Type: application/json\r\n\r\n{}"))
+	if _, err := readMessage(invalid); err == nil {
+		t.Error("Expected an error for a message without Content-Length")
+	}
+}
+
+// TestSession tests exchanging messages with a language server and stopping it, with
+// cat standing in for a server echoing its input
+func TestSession(t *testing.T) {
+	manager := NewManager(map[string][]string{"echo": {"cat"}})
+
+	session, err := manager.Start("echo", t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	if sessions := manager.Sessions(); len(sessions) != 1 || sessions[0].Language != "echo" {
+		t.Errorf("Expected the session to be listed, got %+v", sessions)
+	}
+
+	if err := session.Write([]byte(`{"id":1}`)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	message, err := session.Read()
+	if err != nil || string(message) != `{"id":1}` {
+		t.Fatalf("Expected the message back, got %s (%v)", message, err)
+	}
+
+	session.Close()
+	select {
+	case <-session.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the server to exit")
+	}
+	if sessions := manager.Sessions(); len(sessions) != 0 {
+		t.Errorf("Expected no session after close, got %+v", sessions)
+	}
+
+	if _, err := manager.Start("unknown", t.TempDir()); err == nil {
+		t.Error("Expected an error for an unknown language")
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// errMultipartUnavailable is returned when the directory of multipart uploads could not be created
+var errMultipartUnavailable = apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available")
+
+// InitiateMultipartUpload starts a multipart upload of the file at path, created with
+// the octal permissions, 0644 when empty
+func (h *FileSystemHandler) InitiateMultipartUpload(path string, permissions string) (*filesystem.MultipartUpload, error) {
+	if h.multipartManager == nil {
+		return nil, errMultipartUnavailable
+	}
+
+	path, err := lib.FormatPath(path)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeInvalidRequest, err)
+	}
+	// Get absolute path for final destination
+	absPath, err := h.fs.GetAbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mode os.FileMode = 0644
+	if permissions != "" {
+		permInt, err := strconv.ParseUint(permissions, 8, 32)
+		if err != nil {
+			return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid permissions format: %w", err)
+		}
+		mode = os.FileMode(permInt)
+	}
+
+	upload, err := h.multipartManager.InitiateUpload(absPath, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate upload: %w", err)
+	}
+	return upload, nil
+}
+
+// UploadMultipartPart writes a part of a multipart upload, from offset to continue a
+// partial part
+func (h *FileSystemHandler) UploadMultipartPart(uploadID string, partNumber int, offset int64, reader io.Reader) (*filesystem.UploadedPart, error) {
+	if h.multipartManager == nil {
+		return nil, errMultipartUnavailable
+	}
+
+	part, err := h.multipartManager.UploadPart(uploadID, partNumber, offset, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload part: %w", err)
+	}
+	return part, nil
+}
+
+// CompleteMultipartUpload assembles the parts of a multipart upload into its file,
+// giving what it creates to the "user[:group]" runAs, RUN_AS when empty. It returns
+// the path of the file.
+func (h *FileSystemHandler) CompleteMultipartUpload(uploadID string, parts []MultipartPartInfo, checksum string, runAs string) (string, error) {
+	if h.multipartManager == nil {
+		return "", errMultipartUnavailable
+	}
+	if len(parts) == 0 {
+		return "", apierror.New(apierror.CodeInvalidRequest, "at least one part is required")
+	}
+
+	// Get upload metadata to get the path
+	upload, err := h.multipartManager.GetUpload(uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	// Convert MultipartPartInfo to UploadedPart for the manager
+	uploaded := make([]filesystem.UploadedPart, len(parts))
+	for i, p := range parts {
+		uploaded[i] = filesystem.UploadedPart{
+			PartNumber: p.PartNumber,
+			ETag:       p.ETag,
+		}
+	}
+
+	owner, err := h.newFileOwner(runAs)
+	if err != nil {
+		return "", err
+	}
+	created := owner.track(upload.Path)
+	if err := h.multipartManager.CompleteUpload(uploadID, uploaded, checksum); err != nil {
+		return "", fmt.Errorf("failed to complete upload: %w", err)
+	}
+	created()
+	return upload.Path, nil
+}
+
+// AbortMultipartUpload cancels a multipart upload and removes its parts
+func (h *FileSystemHandler) AbortMultipartUpload(uploadID string) error {
+	if h.multipartManager == nil {
+		return errMultipartUnavailable
+	}
+	return h.multipartManager.AbortUpload(uploadID)
+}
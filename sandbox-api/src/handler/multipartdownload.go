@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// MultipartDownloadInitiateRequest represents the request body for initiating a multipart download
+type MultipartDownloadInitiateRequest struct {
+	Path string `json:"path" example:"/tmp/largefile.dat" binding:"required"`
+	// PartSize is the size of the parts in bytes, at least 65536, 8388608 by default. It
+	// grows when the file would have more than 10000 parts.
+	PartSize int64 `json:"partSize" example:"8388608"`
+} // @name MultipartDownloadInitiateRequest
+
+// HandleInitiateMultipartDownload initiates a multipart download
+// @Summary Initiate multipart download
+// @Description Split a file in parts which can be downloaded in parallel, and retried independently, from /filesystem-multipart/download/{downloadId}/part. Returns the size, offset and sha256 of each part and the sha256 of the whole file. Parts fail with 412 once the file has changed.
+// @Tags filesystem
+// @Accept json
+// @Produce json
+// @Param request body MultipartDownloadInitiateRequest true "File to download"
+// @Success 200 {object} filesystem.MultipartDownload "Download session created"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "File not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
+// @Router /filesystem-multipart/download/initiate [post]
+func (h *FileSystemHandler) HandleInitiateMultipartDownload(c *gin.Context) {
+	if h.multipartManager == nil {
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
+		return
+	}
+
+	var request MultipartDownloadInitiateRequest
+	if err := h.BindJSON(c, &request); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	path, err := lib.FormatPath(request.Path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	absPath, err := h.fs.GetAbsolutePath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	download, err := h.multipartManager.InitiateDownload(absPath, request.PartSize)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("failed to initiate download: %w", err))
+		return
+	}
+	h.SendJSON(c, http.StatusOK, download)
+}
+
+// HandleDownloadPart streams a single part of a multipart download
+// @Summary Download part
+// @Description Stream a single part of a multipart download. The Range header is supported within the part, to resume it. The ETag is the quoted sha256 of the part.
+// @Tags filesystem
+// @Produce octet-stream
+// @Param downloadId path string true "Download ID"
+// @Param partNumber query int true "Part number (1 to partCount)"
+// @Param Range header string false "Byte range within the part, e.g. bytes=1024-"
+// @Success 200 {file} file "Part content"
+// @Success 206 {file} file "Partial part content"
+// @Header 200 {string} ETag "Quoted sha256 of the part content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 404 {object} ErrorResponse "Download not found"
+// @Failure 412 {object} ErrorResponse "File changed since the download was initiated"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
+// @Router /filesystem-multipart/download/{downloadId}/part [get]
+func (h *FileSystemHandler) HandleDownloadPart(c *gin.Context) {
+	if h.multipartManager == nil {
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
+		return
+	}
+
+	downloadID := c.Param("downloadId")
+	partNumberStr := c.Query("partNumber")
+	if partNumberStr == "" {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("partNumber is required"))
+		return
+	}
+	partNumber, err := strconv.Atoi(partNumberStr)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid partNumber: %w", err))
+		return
+	}
+
+	file, reader, part, err := h.multipartManager.OpenDownloadPart(downloadID, partNumber)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("ETag", `"`+part.Checksum+`"`)
+	c.Header("X-Part-Number", strconv.Itoa(part.PartNumber))
+	c.Header("X-Part-Offset", strconv.FormatInt(part.Offset, 10))
+	// ServeContent sets Content-Length and answers Range requests within the part with
+	// 206, If-Range being checked against the ETag
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, reader)
+}
+
+// HandleAbortMultipartDownload aborts a multipart download
+// @Summary Abort multipart download
+// @Description Forget a multipart download
+// @Tags filesystem
+// @Produce json
+// @Param downloadId path string true "Download ID"
+// @Success 200 {object} SuccessResponse "Download aborted"
+// @Failure 404 {object} ErrorResponse "Download not found"
+// @Failure 503 {object} ErrorResponse "Multipart upload not available"
+// @Router /filesystem-multipart/download/{downloadId} [delete]
+func (h *FileSystemHandler) HandleAbortMultipartDownload(c *gin.Context) {
+	if h.multipartManager == nil {
+		h.SendError(c, http.StatusServiceUnavailable, apierror.New(apierror.CodeMultipartUnavailable, "multipart upload not available"))
+		return
+	}
+
+	if err := h.multipartManager.AbortDownload(c.Param("downloadId")); err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	h.SendSuccess(c, "Multipart download aborted successfully")
+}
@@ -2,7 +2,9 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -32,6 +34,13 @@ type PortMonitorRequest struct {
 	Callback string `json:"callback" example:"http://localhost:3000/callback"` // URL to call when a new port is detected
 } // @name PortMonitorRequest
 
+// PortEvent is a port opened or closed by a process
+type PortEvent struct {
+	PID   int               `json:"pid" example:"1234"`
+	Event string            `json:"event" example:"port-open"` // port-open or port-close
+	Port  *network.PortInfo `json:"port"`
+} // @name PortEvent
+
 // GetPortsForPID gets the ports for a process
 func (h *NetworkHandler) GetPortsForPID(pid int) ([]*network.PortInfo, error) {
 	return h.net.GetPortsForPID(pid)
@@ -47,6 +56,19 @@ func (h *NetworkHandler) UnregisterPortOpenCallback(pid int) {
 	h.net.UnregisterPortOpenCallback(pid)
 }
 
+// SubscribePortEvents registers a callback for when a port is opened or closed, until
+// the returned function is called
+func (h *NetworkHandler) SubscribePortEvents(pid int, callback func(PortEvent)) func() {
+	return h.net.SubscribePortEvents(pid, func(pid int, event string, port *network.PortInfo) {
+		callback(PortEvent{PID: pid, Event: event, Port: port})
+	})
+}
+
+// DoHTTPRequest performs an HTTP request from inside the sandbox
+func (h *NetworkHandler) DoHTTPRequest(ctx context.Context, req network.HTTPRequest) (*network.HTTPResponse, error) {
+	return network.DoHTTPRequest(ctx, req)
+}
+
 // HandleGetPorts handles GET requests to /network/process/{pid}/ports
 // @Summary Get open ports for a process
 // @Description Get a list of all open ports for a process
@@ -168,3 +190,38 @@ func (h *NetworkHandler) HandleStopMonitoringPorts(c *gin.Context) {
 
 	h.SendSuccess(c, "Port monitoring stopped")
 }
+
+// HandleHTTPRequest handles POST requests to /http-request
+// @Summary Perform an HTTP request from the sandbox
+// @Description Perform an HTTP request from inside the sandbox, e.g. to a service started with /process, and return its status, headers and body. Error statuses of the target are returned as successful responses. Bodies that are not valid UTF-8 are base64 encoded, and bodies above 10MB are truncated.
+// @Tags network
+// @Accept json
+// @Produce json
+// @Param request body network.HTTPRequest true "HTTP request"
+// @Success 200 {object} network.HTTPResponse "Response of the target"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 502 {object} ErrorResponse "Request failed"
+// @Failure 504 {object} ErrorResponse "Request timed out"
+// @Router /http-request [post]
+func (h *NetworkHandler) HandleHTTPRequest(c *gin.Context) {
+	var req network.HTTPRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := h.DoHTTPRequest(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, network.ErrHTTPRequestTimeout):
+			h.SendError(c, http.StatusGatewayTimeout, err)
+		case errors.Is(err, network.ErrHTTPRequestFailed):
+			h.SendError(c, http.StatusBadGateway, err)
+		default:
+			h.SendError(c, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, resp)
+}
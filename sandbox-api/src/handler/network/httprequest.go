@@ -0,0 +1,177 @@
+package network
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultHTTPRequestTimeout is the timeout of requests that don't set one
+	DefaultHTTPRequestTimeout = 30 * time.Second
+	// MaxHTTPRequestTimeout is the longest timeout a request can set
+	MaxHTTPRequestTimeout = 5 * time.Minute
+	// DefaultMaxRedirects is the number of redirects followed by requests that don't set one
+	DefaultMaxRedirects = 10
+	// MaxHTTPResponseBodySize is the size above which response bodies are truncated
+	MaxHTTPResponseBodySize = 10 * 1024 * 1024
+)
+
+// Body encodings of HTTP requests and responses
+const (
+	BodyEncodingText   = "text"
+	BodyEncodingBase64 = "base64"
+)
+
+var (
+	// ErrHTTPRequestFailed is returned when a request can't be sent or its response can't be read
+	ErrHTTPRequestFailed = errors.New("request failed")
+	// ErrHTTPRequestTimeout is returned when a request doesn't complete within its timeout
+	ErrHTTPRequestTimeout = errors.New("request timed out")
+)
+
+// HTTPRequest is an HTTP request performed from inside the sandbox
+type HTTPRequest struct {
+	Method          string            `json:"method" example:"GET"`
+	URL             string            `json:"url" example:"http://localhost:3000/api/health" binding:"required"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	BodyEncoding    string            `json:"bodyEncoding" example:"text"`    // text (default) or base64
+	Timeout         int               `json:"timeout" example:"30"`           // in seconds, 30 by default
+	FollowRedirects *bool             `json:"followRedirects" example:"true"` // true by default
+	MaxRedirects    int               `json:"maxRedirects" example:"10"`      // 10 by default
+} // @name HTTPRequest
+
+// HTTPResponse is the response of an HTTP request performed from inside the sandbox.
+// Bodies that are not valid UTF-8 are base64 encoded.
+type HTTPResponse struct {
+	Status       int                 `json:"status" example:"200"`
+	StatusText   string              `json:"statusText" example:"200 OK"`
+	URL          string              `json:"url" example:"http://localhost:3000/api/health"` // final URL, after redirects
+	Headers      map[string][]string `json:"headers"`
+	Body         string              `json:"body"`
+	BodyEncoding string              `json:"bodyEncoding" example:"text"` // text or base64
+	Truncated    bool                `json:"truncated" example:"false"`   // true if the body exceeded 10MB
+	DurationMs   int64               `json:"durationMs" example:"42"`
+} // @name HTTPResponse
+
+// DoHTTPRequest performs an HTTP request and returns its response. Error statuses
+// are returned as responses, errors are only returned for invalid requests and
+// transport failures.
+func DoHTTPRequest(ctx context.Context, req HTTPRequest) (*HTTPResponse, error) {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid URL '%s', only absolute http and https URLs are supported", req.URL)
+	}
+
+	body, err := decodeBody(req.Body, req.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := DefaultHTTPRequestTimeout
+	if req.Timeout > 0 {
+		timeout = min(time.Duration(req.Timeout)*time.Second, MaxHTTPRequestTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, target.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	if host := httpReq.Header.Get("Host"); host != "" {
+		httpReq.Host = host
+	}
+
+	client := &http.Client{CheckRedirect: checkRedirect(req.FollowRedirects, req.MaxRedirects)}
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w after %s", ErrHTTPRequestTimeout, timeout)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrHTTPRequestFailed, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, MaxHTTPResponseBodySize+1))
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w after %s", ErrHTTPRequestTimeout, timeout)
+		}
+		return nil, fmt.Errorf("%w: failed to read response body: %w", ErrHTTPRequestFailed, err)
+	}
+
+	response := &HTTPResponse{
+		Status:     resp.StatusCode,
+		StatusText: resp.Status,
+		URL:        resp.Request.URL.String(),
+		Headers:    resp.Header,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if len(content) > MaxHTTPResponseBodySize {
+		content = content[:MaxHTTPResponseBodySize]
+		response.Truncated = true
+	}
+	if utf8.Valid(content) {
+		response.Body = string(content)
+		response.BodyEncoding = BodyEncodingText
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(content)
+		response.BodyEncoding = BodyEncodingBase64
+	}
+
+	return response, nil
+}
+
+// decodeBody returns the raw content of a request body
+func decodeBody(body string, encoding string) (string, error) {
+	switch encoding {
+	case "", BodyEncodingText:
+		return body, nil
+	case BodyEncodingBase64:
+		content, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 body: %w", err)
+		}
+		return string(content), nil
+	default:
+		return "", fmt.Errorf("invalid body encoding '%s', expected %s or %s", encoding, BodyEncodingText, BodyEncodingBase64)
+	}
+}
+
+// checkRedirect returns the redirect policy of a request. When redirects are not
+// followed, the redirect response itself is returned.
+func checkRedirect(followRedirects *bool, maxRedirects int) func(*http.Request, []*http.Request) error {
+	if followRedirects != nil && !*followRedirects {
+		return func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestDoHTTPRequest tests HTTP requests performed from the sandbox
+func TestDoHTTPRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Custom", r.Header.Get("X-Custom"))
+			_, _ = w.Write(body)
+		case "/binary":
+			_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+		case "/redirect":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/slow":
+			time.Sleep(2 * time.Second)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	resp, err := DoHTTPRequest(ctx, HTTPRequest{
+		Method:       "post",
+		URL:          server.URL + "/echo",
+		Headers:      map[string]string{"X-Custom": "value"},
+		Body:         base64.StdEncoding.EncodeToString([]byte("hello")),
+		BodyEncoding: BodyEncodingBase64,
+	})
+	if err != nil {
+		t.Fatalf("Failed to perform request: %v", err)
+	}
+	if resp.Status != http.StatusOK || resp.Body != "hello" || resp.BodyEncoding != BodyEncodingText {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if resp.Headers["X-Method"][0] != http.MethodPost || resp.Headers["X-Custom"][0] != "value" {
+		t.Errorf("Expected method and headers to be sent, got %v", resp.Headers)
+	}
+
+	resp, err = DoHTTPRequest(ctx, HTTPRequest{URL: server.URL + "/binary"})
+	if err != nil {
+		t.Fatalf("Failed to perform request: %v", err)
+	}
+	if resp.BodyEncoding != BodyEncodingBase64 || resp.Body != base64.StdEncoding.EncodeToString([]byte{0xff, 0x00, 0xfe}) {
+		t.Errorf("Expected binary body to be base64 encoded, got %+v", resp)
+	}
+
+	resp, err = DoHTTPRequest(ctx, HTTPRequest{URL: server.URL + "/missing"})
+	if err != nil || resp.Status != http.StatusNotFound {
+		t.Errorf("Expected 404 response, got %+v (%v)", resp, err)
+	}
+
+	resp, err = DoHTTPRequest(ctx, HTTPRequest{URL: server.URL + "/redirect"})
+	if err != nil || resp.Status != http.StatusOK || resp.URL != server.URL+"/echo" {
+		t.Errorf("Expected redirect to be followed, got %+v (%v)", resp, err)
+	}
+	followRedirects := false
+	resp, err = DoHTTPRequest(ctx, HTTPRequest{URL: server.URL + "/redirect", FollowRedirects: &followRedirects})
+	if err != nil || resp.Status != http.StatusFound || resp.Headers["Location"][0] != "/echo" {
+		t.Errorf("Expected redirect response, got %+v (%v)", resp, err)
+	}
+
+	if _, err := DoHTTPRequest(ctx, HTTPRequest{URL: server.URL + "/slow", Timeout: 1}); !errors.Is(err, ErrHTTPRequestTimeout) {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+
+	for _, req := range []HTTPRequest{{URL: "localhost:3000"}, {URL: "ftp://localhost"}, {URL: server.URL, Body: "!", BodyEncoding: BodyEncodingBase64}} {
+		if _, err := DoHTTPRequest(ctx, req); err == nil || errors.Is(err, ErrHTTPRequestFailed) {
+			t.Errorf("Expected validation error for %+v, got %v", req, err)
+		}
+	}
+}
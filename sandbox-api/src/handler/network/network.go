@@ -28,14 +28,24 @@ type PortInfo struct {
 // PortOpenCallback is a function that gets called when a process opens a new port
 type PortOpenCallback func(pid int, port *PortInfo)
 
+// Port events passed to a PortEventCallback
+const (
+	PortOpened = "port-open"
+	PortClosed = "port-close"
+)
+
+// PortEventCallback is a function that gets called when a process opens or closes a port
+type PortEventCallback func(pid int, event string, port *PortInfo)
+
 // Network provides functionality for monitoring network connections
 type Network struct {
-	portsByPID     map[int]map[int]*PortInfo  // PID -> Port -> PortInfo
-	callbacks      map[int][]PortOpenCallback // PID -> list of callbacks
-	monitoredPIDs  map[int]bool
-	stopMonitoring chan bool
-	isMonitoring   bool
-	mutex          sync.RWMutex
+	portsByPID       map[int]map[int]*PortInfo         // PID -> Port -> PortInfo
+	callbacks        map[int][]PortOpenCallback        // PID -> list of callbacks
+	subscribers      map[int]map[int]PortEventCallback // PID -> subscription ID -> callback
+	nextSubscriberID int
+	monitoredPIDs    map[int]bool
+	isMonitoring     bool
+	mutex            sync.RWMutex
 }
 
 // Global process manager instance
@@ -47,11 +57,11 @@ var (
 func GetNetwork() *Network {
 	networkOnce.Do(func() {
 		network = &Network{
-			portsByPID:     make(map[int]map[int]*PortInfo),
-			callbacks:      make(map[int][]PortOpenCallback),
-			monitoredPIDs:  make(map[int]bool),
-			stopMonitoring: make(chan bool),
-			isMonitoring:   false,
+			portsByPID:    make(map[int]map[int]*PortInfo),
+			callbacks:     make(map[int][]PortOpenCallback),
+			subscribers:   make(map[int]map[int]PortEventCallback),
+			monitoredPIDs: make(map[int]bool),
+			isMonitoring:  false,
 		}
 	})
 
@@ -92,12 +102,7 @@ func (n *Network) RegisterPortOpenCallback(pid int, callback PortOpenCallback) {
 	}
 
 	n.callbacks[pid] = append(n.callbacks[pid], callback)
-	n.monitoredPIDs[pid] = true
-
-	// Start monitoring if not already doing so
-	if !n.isMonitoring {
-		go n.startMonitoring()
-	}
+	n.monitorPID(pid)
 }
 
 // UnregisterPortOpenCallback removes all callbacks for a specific PID
@@ -106,63 +111,116 @@ func (n *Network) UnregisterPortOpenCallback(pid int) {
 	defer n.mutex.Unlock()
 
 	delete(n.callbacks, pid)
-	delete(n.monitoredPIDs, pid)
+	n.unmonitorPIDIfUnused(pid)
+}
+
+// SubscribePortEvents registers a callback called when the specified PID opens or closes
+// a port. Unlike port open callbacks, subscriptions are removed one by one with the
+// returned function.
+func (n *Network) SubscribePortEvents(pid int, callback PortEventCallback) func() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
 
-	// If no more PIDs to monitor, stop monitoring
-	if len(n.monitoredPIDs) == 0 && n.isMonitoring {
-		n.stopMonitoring <- true
+	if _, exists := n.subscribers[pid]; !exists {
+		n.subscribers[pid] = make(map[int]PortEventCallback)
+	}
+	n.nextSubscriberID++
+	id := n.nextSubscriberID
+	n.subscribers[pid][id] = callback
+	n.monitorPID(pid)
+
+	return func() {
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+
+		delete(n.subscribers[pid], id)
+		if len(n.subscribers[pid]) == 0 {
+			delete(n.subscribers, pid)
+		}
+		n.unmonitorPIDIfUnused(pid)
 	}
 }
 
-// startMonitoring starts a goroutine that periodically checks for new open ports
-func (n *Network) startMonitoring() {
-	n.mutex.Lock()
-	if n.isMonitoring {
-		n.mutex.Unlock()
-		return
+// monitorPID adds a PID to the monitored ones and starts monitoring if not already
+// doing so. The mutex must be held.
+func (n *Network) monitorPID(pid int) {
+	n.monitoredPIDs[pid] = true
+
+	if !n.isMonitoring {
+		n.isMonitoring = true
+		go n.startMonitoring()
 	}
+}
 
-	n.isMonitoring = true
-	n.mutex.Unlock()
+// unmonitorPIDIfUnused stops monitoring a PID without callbacks nor subscribers. The
+// monitoring goroutine exits by itself once no PID is left. The mutex must be held.
+func (n *Network) unmonitorPIDIfUnused(pid int) {
+	if len(n.callbacks[pid]) > 0 || len(n.subscribers[pid]) > 0 {
+		return
+	}
+	delete(n.monitoredPIDs, pid)
+	delete(n.portsByPID, pid)
+}
 
+// startMonitoring periodically checks for opened and closed ports until no PID is monitored
+func (n *Network) startMonitoring() {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
-	for {
-		select {
-		case <-ticker.C:
-			func() {
-				n.mutex.Lock()
-				defer n.mutex.Unlock()
-
-				for pid := range n.monitoredPIDs {
-					oldPorts := n.portsByPID[pid]
-					if err := n.updatePortsForPID(pid); err != nil {
-						logrus.Errorf("Error updating ports for PID %d: %v\n", pid, err)
-						continue
-					}
-
-					// Check for new ports
-					newPorts := n.portsByPID[pid]
-					for portNum, portInfo := range newPorts {
-						if _, exists := oldPorts[portNum]; !exists {
-							// New port detected, trigger callbacks
-							for _, callback := range n.callbacks[pid] {
-								go callback(pid, portInfo)
-							}
-						}
-					}
-				}
-			}()
-		case <-n.stopMonitoring:
-			n.mutex.Lock()
-			n.isMonitoring = false
-			n.mutex.Unlock()
+	for range ticker.C {
+		if !n.checkPorts() {
 			return
 		}
 	}
 }
 
+// checkPorts triggers the callbacks of the ports opened and closed since the last
+// check. It returns false, and marks the monitoring as stopped, when no PID is monitored.
+func (n *Network) checkPorts() bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if len(n.monitoredPIDs) == 0 {
+		n.isMonitoring = false
+		return false
+	}
+
+	for pid := range n.monitoredPIDs {
+		oldPorts := n.portsByPID[pid]
+		if err := n.updatePortsForPID(pid); err != nil {
+			logrus.Errorf("Error updating ports for PID %d: %v\n", pid, err)
+			continue
+		}
+
+		// Check for new ports
+		newPorts := n.portsByPID[pid]
+		for portNum, portInfo := range newPorts {
+			if _, exists := oldPorts[portNum]; !exists {
+				// New port detected, trigger callbacks
+				for _, callback := range n.callbacks[pid] {
+					go callback(pid, portInfo)
+				}
+				n.notifySubscribers(pid, PortOpened, portInfo)
+			}
+		}
+
+		// Check for closed ports
+		for portNum, portInfo := range oldPorts {
+			if _, exists := newPorts[portNum]; !exists {
+				n.notifySubscribers(pid, PortClosed, portInfo)
+			}
+		}
+	}
+	return true
+}
+
+// notifySubscribers calls the subscribers of a PID with a port event. The mutex must be held.
+func (n *Network) notifySubscribers(pid int, event string, port *PortInfo) {
+	for _, callback := range n.subscribers[pid] {
+		go callback(pid, event, port)
+	}
+}
+
 // updatePortsForPID updates the internal cache of ports for a specific PID
 func (n *Network) updatePortsForPID(pid int) error {
 	ports, err := getOpenPortsForPID(pid)
@@ -2,6 +2,8 @@ package network
 
 import (
 	"bufio"
+	"net"
+	"os"
 	"os/exec"
 	"sync"
 	"testing"
@@ -119,6 +121,46 @@ time.sleep(10)
 	network.UnregisterPortOpenCallback(0)
 }
 
+// TestSubscribePortEvents tests that subscribers are notified of opened and closed ports
+func TestSubscribePortEvents(t *testing.T) {
+	network := GetNetwork()
+	pid := os.Getpid()
+
+	if _, err := network.GetPortsForPID(pid); err != nil {
+		t.Skipf("Ports cannot be listed on this system: %v", err)
+	}
+
+	// The port opens after the ports were listed, so it is reported as a new one
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	events := make(chan string, 10)
+	unsubscribe := network.SubscribePortEvents(pid, func(pid int, event string, info *PortInfo) {
+		if info.LocalPort == port {
+			events <- event
+		}
+	})
+	defer unsubscribe()
+
+	expectEvent := func(expected string) {
+		select {
+		case event := <-events:
+			if event != expected {
+				t.Fatalf("Expected %s event, got %s", expected, event)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Timed out waiting for %s event", expected)
+		}
+	}
+
+	expectEvent(PortOpened)
+	_ = listener.Close()
+	expectEvent(PortClosed)
+}
+
 // ExampleNetwork_GetPortsForPID shows how to get open ports for a specific PID
 func ExampleNetwork_GetPortsForPID() {
 	network := GetNetwork()
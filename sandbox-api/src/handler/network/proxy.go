@@ -0,0 +1,70 @@
+package network
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PortRange is an inclusive range of ports
+type PortRange struct {
+	From int
+	To   int
+}
+
+// ParsePortRanges parses a comma separated list of ports and port ranges, e.g. "3000,8000-8100"
+func ParsePortRanges(value string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		fromValue, toValue, isRange := strings.Cut(item, "-")
+		from, err := parsePort(fromValue)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = parsePort(toValue); err != nil {
+				return nil, err
+			}
+			if to < from {
+				return nil, fmt.Errorf("invalid port range '%s'", item)
+			}
+		}
+		ranges = append(ranges, PortRange{From: from, To: to})
+	}
+	return ranges, nil
+}
+
+// parsePort parses a TCP port number
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port '%s'", value)
+	}
+	return port, nil
+}
+
+// PortAllowed reports whether port is in one of the ranges. No ranges allow every port.
+func PortAllowed(ranges []PortRange, port int) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, r := range ranges {
+		if port >= r.From && port <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyAllowedPortsFromEnv returns the ports the proxy may forward to, read from
+// PROXY_ALLOWED_PORTS. No ranges, when unset, allow every port.
+func ProxyAllowedPortsFromEnv() ([]PortRange, error) {
+	return ParsePortRanges(os.Getenv("PROXY_ALLOWED_PORTS"))
+}
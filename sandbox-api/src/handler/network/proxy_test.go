@@ -0,0 +1,29 @@
+package network
+
+import (
+	"testing"
+)
+
+// TestParsePortRanges tests parsing of the proxy port allow-list
+func TestParsePortRanges(t *testing.T) {
+	ranges, err := ParsePortRanges("3000, 5173,8000-8100")
+	if err != nil {
+		t.Fatalf("Failed to parse port ranges: %v", err)
+	}
+	for port, expected := range map[int]bool{3000: true, 5173: true, 8000: true, 8050: true, 8100: true, 3001: false, 8101: false} {
+		if PortAllowed(ranges, port) != expected {
+			t.Errorf("PortAllowed(%d) = %v, expected %v", port, !expected, expected)
+		}
+	}
+
+	ranges, err = ParsePortRanges("")
+	if err != nil || !PortAllowed(ranges, 22) {
+		t.Errorf("Expected every port to be allowed without ranges, got %v (%v)", ranges, err)
+	}
+
+	for _, value := range []string{"abc", "0", "70000", "9000-8000", "3000-"} {
+		if _, err := ParsePortRanges(value); err == nil {
+			t.Errorf("Expected error for %q, but got none", value)
+		}
+	}
+}
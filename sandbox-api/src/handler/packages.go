@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/packages"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+)
+
+// PackageHandler handles the installation of packages
+type PackageHandler struct {
+	*BaseHandler
+	installer *packages.Installer
+}
+
+// NewPackageHandler creates a new package handler
+func NewPackageHandler() *PackageHandler {
+	return &PackageHandler{
+		BaseHandler: NewBaseHandler(),
+		installer:   packages.GetInstaller(),
+	}
+}
+
+// HandleInstallPackages handles POST requests to /packages/install
+// @Summary Install packages
+// @Description Install packages with apt, pip, npm or go, with the flags making them run unattended: apt-get install -y --no-install-recommends (after apt-get update when the package lists are missing), python3 -m pip install, npm install in workingDir or --global, and go install with @latest by default. Each command runs as a process whose logs can be followed from /process/{pid}/logs/stream. apt installs wait for each other rather than corrupting the dpkg database. With async=true, the install runs as a job whose progress is the number of commands done; otherwise it stops if the client disconnects.
+// @Tags packages
+// @Accept json
+// @Produce json
+// @Param request body packages.InstallRequest true "Packages to install"
+// @Param async query boolean false "Run the install as a background job"
+// @Success 200 {object} packages.InstallResult "Packages installed"
+// @Success 202 {object} jobs.Job "Install started as a job"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Install command failed"
+// @Failure 503 {object} ErrorResponse "Package manager not installed"
+// @Router /packages/install [post]
+func (h *PackageHandler) HandleInstallPackages(c *gin.Context) {
+	var req packages.InstallRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if wantsAsync(c) {
+		h.startJob(c, jobs.TypePackagesInstall, req.Manager+": "+strings.Join(req.Packages, " "), func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+			return h.installer.Install(ctx, req, reporter.Progress)
+		})
+		return
+	}
+
+	result, err := h.installer.Install(c.Request.Context(), req, nil)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, result)
+}
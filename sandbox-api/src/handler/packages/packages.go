@@ -0,0 +1,306 @@
+// Package packages installs system and language packages with apt, pip, npm or go, running
+// the install commands as processes with the flags that make them work unattended, so that
+// their output can be followed like the output of any other process.
+package packages
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Package managers
+const (
+	ManagerApt = "apt"
+	ManagerPip = "pip"
+	ManagerNpm = "npm"
+	ManagerGo  = "go"
+)
+
+// maxPackages is the maximum number of packages of an install
+const maxPackages = 100
+
+// packagePattern matches the package specs of all managers, like curl=7.88.1-10, numpy>=2,
+// requests[socks], @types/node@20 or golang.org/x/tools/gopls@latest. Specs cannot start
+// with a dash, so that they are never taken for options.
+var packagePattern = regexp.MustCompile(`^[A-Za-z0-9@._][A-Za-z0-9@._~^+=<>!:/\[\],*-]*$`)
+
+// InstallRequest is the request body for installing packages
+type InstallRequest struct {
+	// Manager is apt, pip, npm or go
+	Manager string `json:"manager" example:"pip" binding:"required,oneof=apt pip npm go"`
+	// Packages are the packages to install, with an optional version in the syntax of the manager
+	Packages []string `json:"packages" example:"requests,numpy>=2" binding:"required,min=1"`
+	// Update refreshes the apt package lists first, which is also done when they are empty
+	Update bool `json:"update,omitempty" example:"false"`
+	// Global installs npm packages globally rather than in workingDir
+	Global bool `json:"global,omitempty" example:"false"`
+	// WorkingDir is the directory of the project for local npm installs
+	WorkingDir string `json:"workingDir,omitempty" example:"/blaxel/app"`
+	// Timeout is the maximum number of seconds of each install command, none when 0
+	Timeout int `json:"timeout,omitempty" example:"600" binding:"gte=0"`
+} // @name InstallPackagesRequest
+
+// Step is a command run to install packages
+type Step struct {
+	Command string `json:"command" example:"python3 -m pip install 'requests'" binding:"required"`
+	// PID is the identifier of the process of the command, to read its logs from /process/{pid}/logs
+	PID      string                  `json:"pid" example:"1234" binding:"required"`
+	Status   constants.ProcessStatus `json:"status" example:"completed" binding:"required"`
+	ExitCode int                     `json:"exitCode" example:"0"`
+	// Duration is the number of seconds the command ran for
+	Duration float64 `json:"duration" example:"4.2"`
+} // @name InstallStep
+
+// InstallResult is the result of an install
+type InstallResult struct {
+	Manager  string   `json:"manager" example:"pip" binding:"required"`
+	Packages []string `json:"packages" example:"requests,numpy>=2" binding:"required"`
+	// Steps are the commands run, in order
+	Steps []Step `json:"steps" binding:"required"`
+} // @name InstallPackagesResult
+
+// Installer runs the install commands with a process manager
+type Installer struct {
+	pm *process.ProcessManager
+	// aptLock is held by the apt installs, which fail or corrupt the dpkg database when run
+	// concurrently
+	aptLock chan struct{}
+	// aptListsDir is the directory of the apt package lists
+	aptListsDir string
+}
+
+// Global installer instance
+var (
+	installer     *Installer
+	installerOnce sync.Once
+)
+
+// GetInstaller returns the installer of the API, running its commands with the process manager
+func GetInstaller() *Installer {
+	installerOnce.Do(func() {
+		installer = NewInstaller(process.GetProcessManager())
+	})
+	return installer
+}
+
+// NewInstaller creates an installer running its commands with pm
+func NewInstaller(pm *process.ProcessManager) *Installer {
+	return &Installer{
+		pm:          pm,
+		aptLock:     make(chan struct{}, 1),
+		aptListsDir: "/var/lib/apt/lists",
+	}
+}
+
+// command is a command to run and the environment making it non-interactive
+type command struct {
+	args []string
+	env  map[string]string
+}
+
+// String returns the command line of the command, its arguments quoted for the shell
+func (c command) String() string {
+	quoted := make([]string, len(c.args))
+	for i, arg := range c.args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Validate checks the manager, packages and working directory of a request
+func (req InstallRequest) Validate() error {
+	switch req.Manager {
+	case ManagerApt, ManagerPip, ManagerNpm, ManagerGo:
+	default:
+		return apierror.Newf(apierror.CodeInvalidRequest, "unknown package manager '%s', expected apt, pip, npm or go", req.Manager)
+	}
+	if len(req.Packages) == 0 {
+		return apierror.New(apierror.CodeInvalidRequest, "packages are required")
+	}
+	if len(req.Packages) > maxPackages {
+		return apierror.Newf(apierror.CodeInvalidRequest, "at most %d packages can be installed at once", maxPackages)
+	}
+	for _, pkg := range req.Packages {
+		if !packagePattern.MatchString(pkg) {
+			return apierror.Newf(apierror.CodeInvalidRequest, "invalid package '%s'", pkg)
+		}
+	}
+	if req.Manager == ManagerNpm && !req.Global && req.WorkingDir == "" {
+		return apierror.New(apierror.CodeInvalidRequest, "workingDir is required for local npm installs, or set global")
+	}
+	if req.Timeout < 0 {
+		return apierror.New(apierror.CodeInvalidRequest, "timeout must not be negative")
+	}
+	return nil
+}
+
+// commands returns the commands installing the packages of a request
+func (i *Installer) commands(req InstallRequest) []command {
+	switch req.Manager {
+	case ManagerApt:
+		env := map[string]string{"DEBIAN_FRONTEND": "noninteractive"}
+		// Wait for the dpkg lock held by apt runs outside of the API rather than failing
+		lock := []string{"-o", "DPkg::Lock::Timeout=300"}
+		var commands []command
+		if req.Update || !i.hasAptLists() {
+			commands = append(commands, command{args: append([]string{"apt-get", "update", "-q"}, lock...), env: env})
+		}
+		args := append([]string{"apt-get", "install", "-y", "-q", "--no-install-recommends"}, lock...)
+		return append(commands, command{args: append(args, req.Packages...), env: env})
+	case ManagerPip:
+		env := map[string]string{
+			"PIP_NO_INPUT":                  "1",
+			"PIP_DISABLE_PIP_VERSION_CHECK": "1",
+			"PIP_PROGRESS_BAR":              "off",
+			"PIP_ROOT_USER_ACTION":          "ignore",
+			// Install in the system Python of the sandbox even when it is externally managed
+			"PIP_BREAK_SYSTEM_PACKAGES": "1",
+		}
+		return []command{{args: append([]string{"python3", "-m", "pip", "install"}, req.Packages...), env: env}}
+	case ManagerNpm:
+		env := map[string]string{
+			"npm_config_yes":             "true",
+			"npm_config_fund":            "false",
+			"npm_config_audit":           "false",
+			"npm_config_update_notifier": "false",
+			"npm_config_progress":        "false",
+		}
+		args := []string{"npm", "install"}
+		if req.Global {
+			args = append(args, "--global")
+		}
+		return []command{{args: append(args, req.Packages...), env: env}}
+	case ManagerGo:
+		// go install takes a single module per command, and a version outside of a module
+		commands := make([]command, 0, len(req.Packages))
+		for _, pkg := range req.Packages {
+			if !strings.Contains(pkg, "@") {
+				pkg += "@latest"
+			}
+			commands = append(commands, command{args: []string{"go", "install", pkg}})
+		}
+		return commands
+	}
+	return nil
+}
+
+// hasAptLists reports whether the apt package lists were downloaded, images usually
+// removing them to be smaller
+func (i *Installer) hasAptLists() bool {
+	lists, _ := filepath.Glob(filepath.Join(i.aptListsDir, "*_Packages*"))
+	return len(lists) > 0
+}
+
+// Install runs the commands installing the packages of a request one after the other,
+// calling progress after each of them, and stops at the first failing one. apt installs
+// wait for each other.
+func (i *Installer) Install(ctx context.Context, req InstallRequest, progress func(done int64, total int64)) (InstallResult, error) {
+	if err := req.Validate(); err != nil {
+		return InstallResult{}, err
+	}
+	commands := i.commands(req)
+	if _, err := exec.LookPath(commands[0].args[0]); err != nil {
+		return InstallResult{}, apierror.Newf(apierror.CodeUnavailable, "%s is not installed in the sandbox", commands[0].args[0])
+	}
+
+	if req.Manager == ManagerApt {
+		select {
+		case i.aptLock <- struct{}{}:
+			defer func() { <-i.aptLock }()
+		case <-ctx.Done():
+			return InstallResult{}, ctx.Err()
+		}
+	}
+
+	result := InstallResult{Manager: req.Manager, Packages: req.Packages, Steps: []Step{}}
+	workingDir := ""
+	if req.Manager == ManagerNpm && !req.Global {
+		workingDir = req.WorkingDir
+	}
+	for n, cmd := range commands {
+		step, err := i.run(ctx, cmd, workingDir, time.Duration(req.Timeout)*time.Second)
+		if step.PID != "" {
+			result.Steps = append(result.Steps, step)
+		}
+		if err != nil {
+			return result, err
+		}
+		if progress != nil {
+			progress(int64(n+1), int64(len(commands)))
+		}
+	}
+	return result, nil
+}
+
+// run runs a command as a process and waits for it to exit, killing it when ctx is done
+func (i *Installer) run(ctx context.Context, cmd command, workingDir string, timeout time.Duration) (Step, error) {
+	started := time.Now()
+	pid, err := i.pm.StartProcessWithRestart(cmd.String(), workingDir, "", cmd.env, lib.RunAs{}, timeout, process.RestartConfig{}, false, false, func(*process.ProcessInfo) {})
+	if err != nil {
+		return Step{}, err
+	}
+	info, exists := i.pm.GetProcessByIdentifier(pid)
+	if !exists {
+		return Step{}, apierror.Newf(apierror.CodeInternal, "process %s of '%s' is gone", pid, cmd)
+	}
+
+	select {
+	case <-info.Done():
+	case <-ctx.Done():
+		_ = i.pm.KillProcess(pid)
+		<-info.Done()
+	}
+	state := info.State()
+	step := Step{
+		Command:  cmd.String(),
+		PID:      pid,
+		Status:   state.Status,
+		ExitCode: state.ExitCode,
+		Duration: time.Since(started).Seconds(),
+	}
+	if ctx.Err() != nil {
+		return step, ctx.Err()
+	}
+	if state.Status != process.StatusCompleted {
+		return step, apierror.Newf(apierror.CodeUnprocessable, "'%s' %s with exit code %d (process %s): %s",
+			cmd, state.Status, state.ExitCode, pid, i.outputTail(pid))
+	}
+	return step, nil
+}
+
+// outputTail returns the last lines of the output of a process, its errors when it wrote any
+func (i *Installer) outputTail(pid string) string {
+	logs, err := i.pm.GetProcessOutput(pid)
+	if err != nil {
+		return ""
+	}
+	output := logs.Stderr
+	if strings.TrimSpace(output) == "" {
+		output = logs.Logs
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shellQuote quotes an argument for the shell running the processes
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%_+=:,./-", r))
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
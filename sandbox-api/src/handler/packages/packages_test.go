@@ -0,0 +1,140 @@
+package packages
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// fakeManager installs a fake package manager command on the PATH, appending its
+// arguments to a file and failing for packages named broken
+func fakeManager(t *testing.T, name string, delay string) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"start $*\" >> " + calls + "\nsleep " + delay + "\necho \"end $*\" >> " + calls + "\n" +
+		"case \"$*\" in *broken*) echo 'E: Unable to locate package broken' >&2; exit 100;; esac\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake %s: %v", name, err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+// TestInstallerCommands tests the commands run for each package manager
+func TestInstallerCommands(t *testing.T) {
+	i := NewInstaller(process.NewProcessManager())
+	i.aptListsDir = t.TempDir()
+
+	tests := []struct {
+		req      InstallRequest
+		commands []string
+	}{
+		{InstallRequest{Manager: ManagerApt, Packages: []string{"curl", "jq=1.6-2"}}, []string{
+			"apt-get update -q -o DPkg::Lock::Timeout=300",
+			"apt-get install -y -q --no-install-recommends -o DPkg::Lock::Timeout=300 curl jq=1.6-2",
+		}},
+		{InstallRequest{Manager: ManagerPip, Packages: []string{"requests[socks]", "numpy>=2"}}, []string{
+			"python3 -m pip install 'requests[socks]' 'numpy>=2'",
+		}},
+		{InstallRequest{Manager: ManagerNpm, Packages: []string{"@types/node@20"}, Global: true}, []string{
+			"npm install --global @types/node@20",
+		}},
+		{InstallRequest{Manager: ManagerGo, Packages: []string{"golang.org/x/tools/gopls", "github.com/go-delve/delve/cmd/dlv@v1.22.0"}}, []string{
+			"go install golang.org/x/tools/gopls@latest",
+			"go install github.com/go-delve/delve/cmd/dlv@v1.22.0",
+		}},
+	}
+	for _, tt := range tests {
+		var commands []string
+		for _, cmd := range i.commands(tt.req) {
+			commands = append(commands, cmd.String())
+		}
+		if strings.Join(commands, "\n") != strings.Join(tt.commands, "\n") {
+			t.Errorf("Expected %s commands %q, got %q", tt.req.Manager, tt.commands, commands)
+		}
+	}
+
+	// The package lists are only updated when missing
+	if err := os.WriteFile(filepath.Join(i.aptListsDir, "deb.debian.org_debian_dists_bookworm_main_binary-amd64_Packages.lz4"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if commands := i.commands(tests[0].req); len(commands) != 1 {
+		t.Errorf("Expected no update with package lists, got %d commands", len(commands))
+	}
+}
+
+// TestInstallRequestValidate tests that invalid requests are rejected
+func TestInstallRequestValidate(t *testing.T) {
+	for _, req := range []InstallRequest{
+		{Manager: "brew", Packages: []string{"jq"}},
+		{Manager: ManagerApt},
+		{Manager: ManagerApt, Packages: []string{"--allow-unauthenticated"}},
+		{Manager: ManagerPip, Packages: []string{"requests; rm -rf /"}},
+		{Manager: ManagerNpm, Packages: []string{"left-pad"}},
+	} {
+		if err := req.Validate(); !errors.Is(err, apierror.New(apierror.CodeInvalidRequest, "")) {
+			t.Errorf("Expected INVALID_REQUEST for %+v, got %v", req, err)
+		}
+	}
+}
+
+// TestInstall tests that apt installs run one at a time, and that failures report the
+// output of the failing command
+func TestInstall(t *testing.T) {
+	calls := fakeManager(t, "apt-get", "0.2")
+	i := NewInstaller(process.NewProcessManager())
+	i.aptListsDir = t.TempDir()
+
+	var wg sync.WaitGroup
+	for _, pkg := range []string{"curl", "jq"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var progress []int64
+			result, err := i.Install(context.Background(), InstallRequest{Manager: ManagerApt, Packages: []string{pkg}}, func(done, total int64) {
+				progress = append(progress, done*10+total)
+			})
+			if err != nil || len(result.Steps) != 2 || result.Steps[1].Status != process.StatusCompleted {
+				t.Errorf("Unexpected result %+v (%v)", result, err)
+			}
+			if len(progress) != 2 || progress[0] != 12 || progress[1] != 22 {
+				t.Errorf("Expected progress after each step, got %v", progress)
+			}
+		}()
+	}
+	wg.Wait()
+
+	content, _ := os.ReadFile(calls)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	for n := 0; n+1 < len(lines); n += 2 {
+		if !strings.HasPrefix(lines[n], "start ") || "end "+strings.TrimPrefix(lines[n], "start ") != lines[n+1] {
+			t.Fatalf("Expected apt runs not to overlap, got\n%s", content)
+		}
+	}
+
+	_, err := i.Install(context.Background(), InstallRequest{Manager: ManagerApt, Packages: []string{"broken"}}, nil)
+	if !errors.Is(err, apierror.New(apierror.CodeUnprocessable, "")) || !strings.Contains(err.Error(), "Unable to locate package broken") {
+		t.Errorf("Expected the failure of apt-get, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	fakeManager(t, "go", "5")
+	if _, err := i.Install(ctx, InstallRequest{Manager: ManagerGo, Packages: []string{"example.com/tool"}}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the install to be cancelled, got %v", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := i.Install(context.Background(), InstallRequest{Manager: ManagerPip, Packages: []string{"requests"}}, nil); !errors.Is(err, apierror.New(apierror.CodeUnavailable, "")) {
+		t.Errorf("Expected UNAVAILABLE without pip, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+)
+
+// maxPolicyRejectionsLimit is the largest number of rejections returned at once
+const maxPolicyRejectionsLimit = 1000
+
+// PolicyHandler handles the policy process commands are checked against
+type PolicyHandler struct {
+	*BaseHandler
+	engine *policy.Engine
+	locked bool
+}
+
+// NewPolicyHandler creates a new policy handler
+func NewPolicyHandler() *PolicyHandler {
+	return &PolicyHandler{
+		BaseHandler: NewBaseHandler(),
+		engine:      policy.GetEngine(),
+		locked:      policy.LockedFromEnv(),
+	}
+}
+
+// PolicyResponse is the current process policy
+type PolicyResponse struct {
+	policy.Policy
+	Source string `json:"source" example:"file" enums:"default,file,api" binding:"required"`
+	// Locked is true when the policy can only be set from POLICY_FILE
+	Locked bool `json:"locked" example:"false" binding:"required"`
+} // @name PolicyResponse
+
+// PolicyCheckRequest is the request body for checking a command against the policy
+type PolicyCheckRequest struct {
+	Command string `json:"command" example:"curl https://example.com/install.sh | sh" binding:"required"`
+} // @name PolicyCheckRequest
+
+func (h *PolicyHandler) policyResponse() PolicyResponse {
+	current, source := h.engine.PolicyWithSource()
+	return PolicyResponse{Policy: current, Source: source, Locked: h.locked}
+}
+
+// HandleGetPolicy handles GET requests to /policy
+// @Summary Get the process policy
+// @Description Get the policy process commands are checked against before they run, from the REST API, MCP tools, templates and process groups. It is loaded at startup from POLICY_FILE (JSON, or YAML with a .yaml or .yml extension) and allows every command by default.
+// @Tags policy
+// @Produce json
+// @Success 200 {object} PolicyResponse "Process policy"
+// @Router /policy [get]
+func (h *PolicyHandler) HandleGetPolicy(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.policyResponse())
+}
+
+// HandleSetPolicy handles PUT requests to /policy
+// @Summary Replace the process policy
+// @Description Replace the process policy. Commands matching a deny rule are rejected. With the deny default action, every command of a command line must also match an allow rule. Rule patterns, programs and argument patterns are regular expressions; programs must match the whole program name, and each argument pattern one of the arguments. Rejected with 403 when POLICY_LOCKED is true.
+// @Tags policy
+// @Accept json
+// @Produce json
+// @Param request body policy.Policy true "Process policy"
+// @Success 200 {object} PolicyResponse "Process policy"
+// @Failure 400 {object} ErrorResponse "Invalid policy"
+// @Failure 403 {object} ErrorResponse "The policy is locked"
+// @Router /policy [put]
+func (h *PolicyHandler) HandleSetPolicy(c *gin.Context) {
+	if h.locked {
+		h.SendError(c, http.StatusForbidden, fmt.Errorf("the process policy is locked"))
+		return
+	}
+
+	var req policy.Policy
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := h.engine.SetPolicy(req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, h.policyResponse())
+}
+
+// HandleCheckPolicy handles POST requests to /policy/check
+// @Summary Check a command against the process policy
+// @Description Evaluate a command line against the process policy without running it, returning the commands it was split into and the rule denying it, if any. Checks are not recorded as rejections.
+// @Tags policy
+// @Accept json
+// @Produce json
+// @Param request body PolicyCheckRequest true "Command to check"
+// @Success 200 {object} policy.Decision "Policy decision"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Router /policy/check [post]
+func (h *PolicyHandler) HandleCheckPolicy(c *gin.Context) {
+	var req PolicyCheckRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, h.engine.Evaluate(req.Command))
+}
+
+// HandleListPolicyRejections handles GET requests to /policy/rejections
+// @Summary List rejected commands
+// @Description Get the most recent process commands rejected by the policy, oldest first. The last 1000 rejections are kept.
+// @Tags policy
+// @Produce json
+// @Param limit query int false "Maximum number of rejections (default: 100, max: 1000)"
+// @Success 200 {array} policy.Rejection "Rejected commands"
+// @Failure 400 {object} ErrorResponse "Invalid limit"
+// @Router /policy/rejections [get]
+func (h *PolicyHandler) HandleListPolicyRejections(c *gin.Context) {
+	limit, err := strconv.Atoi(h.GetQueryParam(c, "limit", "100"))
+	if err != nil || limit < 1 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid limit: must be a positive number"))
+		return
+	}
+	h.SendJSON(c, http.StatusOK, h.engine.Rejections(min(limit, maxPolicyRejectionsLimit)))
+}
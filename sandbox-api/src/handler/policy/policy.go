@@ -0,0 +1,380 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Actions of the rules of a policy
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+// maxRejections is the number of rejected commands kept for the audit
+const maxRejections = 1000
+
+// ErrDenied is returned, wrapped in a *DeniedError, for commands the policy rejects
+var ErrDenied = apierror.New(apierror.CodeProcDeniedByPolicy, "command denied by policy")
+
+// Rule matches commands to allow or deny. Pattern is a regular expression matched
+// against the whole command line. Program is a regular expression matched against the
+// whole program name of each command of the line (see ParseCommands), and Args are
+// regular expressions which must each match one of the arguments of that command. A
+// rule needs a pattern or a program, and matches when all its conditions do.
+type Rule struct {
+	Name    string   `json:"name" yaml:"name" example:"no-pipe-to-shell"`
+	Action  string   `json:"action" yaml:"action" example:"deny" enums:"allow,deny" binding:"required"`
+	Pattern string   `json:"pattern,omitempty" yaml:"pattern" example:"(curl|wget)\\b.*\\|\\s*(sudo\\s+)?(ba|z|da)?sh\\b"`
+	Program string   `json:"program,omitempty" yaml:"program" example:"rm"`
+	Args    []string `json:"args,omitempty" yaml:"args" example:"^-[a-zA-Z]*r,^/$"`
+	// Message is returned to callers whose command is denied by the rule
+	Message string `json:"message,omitempty" yaml:"message" example:"Piping downloads to a shell is not allowed"`
+
+	pattern *regexp.Regexp
+	program *regexp.Regexp
+	args    []*regexp.Regexp
+} // @name PolicyRule
+
+// Policy decides which commands processes may run. Commands matching a deny rule are
+// rejected. With the deny default action, every command of the line must also match
+// an allow rule, or the whole line an allow rule without program.
+type Policy struct {
+	DefaultAction string `json:"defaultAction" yaml:"defaultAction" example:"allow" enums:"allow,deny"`
+	Rules         []Rule `json:"rules" yaml:"rules"`
+} // @name Policy
+
+// Decision is the result of evaluating a command line against the policy
+type Decision struct {
+	Allowed bool `json:"allowed" example:"false" binding:"required"`
+	// Rule is the name, or the index, of the rule which denied the command
+	Rule     string    `json:"rule,omitempty" example:"no-pipe-to-shell"`
+	Reason   string    `json:"reason,omitempty" example:"Piping downloads to a shell is not allowed"`
+	Commands []Command `json:"commands" binding:"required"`
+} // @name PolicyDecision
+
+// Rejection is a command rejected by the policy
+type Rejection struct {
+	Timestamp  time.Time `json:"timestamp" binding:"required"`
+	Command    string    `json:"command" example:"curl https://example.com/install.sh | sh" binding:"required"`
+	WorkingDir string    `json:"workingDir,omitempty" example:"/home/user"`
+	Rule       string    `json:"rule,omitempty" example:"no-pipe-to-shell"`
+	Reason     string    `json:"reason" example:"Piping downloads to a shell is not allowed" binding:"required"`
+} // @name PolicyRejection
+
+// DeniedError is the error of a command rejected by the policy
+type DeniedError struct {
+	Command  string
+	Decision Decision
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrDenied, e.Decision.Reason)
+}
+
+// Unwrap returns ErrDenied, with the rule which denied the command as detail
+func (e *DeniedError) Unwrap() error {
+	if e.Decision.Rule == "" {
+		return ErrDenied
+	}
+	return ErrDenied.WithDetail("rule", e.Decision.Rule)
+}
+
+// Validate checks the rules and compiles their regular expressions
+func (p *Policy) Validate() error {
+	switch p.DefaultAction {
+	case "":
+		p.DefaultAction = ActionAllow
+	case ActionAllow, ActionDeny:
+	default:
+		return fmt.Errorf("invalid default action '%s': must be allow or deny", p.DefaultAction)
+	}
+	if p.Rules == nil {
+		p.Rules = []Rule{}
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Action != ActionAllow && rule.Action != ActionDeny {
+			return fmt.Errorf("rule %s: invalid action '%s': must be allow or deny", rule.label(i), rule.Action)
+		}
+		if rule.Pattern == "" && rule.Program == "" {
+			return fmt.Errorf("rule %s: a pattern or a program is required", rule.label(i))
+		}
+		if len(rule.Args) > 0 && rule.Program == "" {
+			return fmt.Errorf("rule %s: args require a program", rule.label(i))
+		}
+
+		var err error
+		rule.pattern, rule.program, rule.args = nil, nil, nil
+		if rule.Pattern != "" {
+			if rule.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("rule %s: invalid pattern: %w", rule.label(i), err)
+			}
+		}
+		if rule.Program != "" {
+			if rule.program, err = regexp.Compile("^(?:" + rule.Program + ")$"); err != nil {
+				return fmt.Errorf("rule %s: invalid program: %w", rule.label(i), err)
+			}
+		}
+		for _, arg := range rule.Args {
+			compiled, err := regexp.Compile(arg)
+			if err != nil {
+				return fmt.Errorf("rule %s: invalid argument pattern: %w", rule.label(i), err)
+			}
+			rule.args = append(rule.args, compiled)
+		}
+	}
+	return nil
+}
+
+// label returns the name of the rule, or its index when unnamed
+func (r *Rule) label(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// matchesLine returns true when the pattern of the rule, if any, matches the line
+func (r *Rule) matchesLine(line string) bool {
+	return r.pattern == nil || r.pattern.MatchString(line)
+}
+
+// matchesCommand returns true when the program and argument conditions of the rule
+// match a command
+func (r *Rule) matchesCommand(command Command) bool {
+	if !r.program.MatchString(command.Program) {
+		return false
+	}
+	for _, arg := range r.args {
+		matched := false
+		for _, value := range command.Args {
+			if arg.MatchString(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate decides whether a command line may run. The policy must be validated.
+func (p *Policy) Evaluate(line string) Decision {
+	commands := ParseCommands(line)
+	decision := Decision{Allowed: true, Commands: commands}
+	deny := func(rule *Rule, index int, reason string) Decision {
+		decision.Allowed = false
+		if rule != nil {
+			decision.Rule = rule.label(index)
+			if rule.Message != "" {
+				reason = rule.Message
+			}
+		}
+		decision.Reason = reason
+		return decision
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Action != ActionDeny || !rule.matchesLine(line) {
+			continue
+		}
+		if rule.program == nil {
+			return deny(rule, i, fmt.Sprintf("command matches deny rule %s", rule.label(i)))
+		}
+		for _, command := range commands {
+			if rule.matchesCommand(command) {
+				return deny(rule, i, fmt.Sprintf("%s matches deny rule %s", command.Program, rule.label(i)))
+			}
+		}
+	}
+
+	if p.DefaultAction != ActionDeny {
+		return decision
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Action == ActionAllow && rule.program == nil && rule.matchesLine(line) {
+			return decision
+		}
+	}
+	if len(commands) == 0 {
+		return deny(nil, 0, "no command matches an allow rule")
+	}
+	for _, command := range commands {
+		allowed := false
+		for i := range p.Rules {
+			rule := &p.Rules[i]
+			if rule.Action == ActionAllow && rule.program != nil && rule.matchesLine(line) && rule.matchesCommand(command) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return deny(nil, 0, fmt.Sprintf("%s does not match any allow rule", command.Program))
+		}
+	}
+	return decision
+}
+
+// Engine holds the policy process commands are checked against, and the commands it
+// rejected
+type Engine struct {
+	policy     Policy
+	source     string
+	rejections []Rejection
+	mu         sync.RWMutex
+}
+
+// Sources of the policy of an engine
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceAPI     = "api"
+)
+
+// Global policy engine instance
+var (
+	engine     *Engine
+	engineOnce sync.Once
+)
+
+// GetEngine returns the policy engine, allowing every command until a policy is loaded
+func GetEngine() *Engine {
+	engineOnce.Do(func() {
+		engine = NewEngine()
+	})
+	return engine
+}
+
+// NewEngine creates an engine allowing every command
+func NewEngine() *Engine {
+	return &Engine{
+		policy:     Policy{DefaultAction: ActionAllow, Rules: []Rule{}},
+		source:     SourceDefault,
+		rejections: make([]Rejection, 0),
+	}
+}
+
+// FileFromEnv returns the path of the policy loaded at startup, read from POLICY_FILE
+func FileFromEnv() string {
+	return os.Getenv("POLICY_FILE")
+}
+
+// LockedFromEnv returns whether the policy can only be set from POLICY_FILE, read from
+// POLICY_LOCKED
+func LockedFromEnv() bool {
+	return os.Getenv("POLICY_LOCKED") == "true"
+}
+
+// LoadFile sets the policy of a JSON or YAML (.yaml, .yml) file
+func (e *Engine) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var policy Policy
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &policy)
+	default:
+		err = json.Unmarshal(data, &policy)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid policy file: %w", err)
+	}
+	if err := e.set(policy, SourceFile); err != nil {
+		return err
+	}
+	logrus.Infof("Loaded process policy with %d rules from %s", len(policy.Rules), path)
+	return nil
+}
+
+// SetPolicy replaces the policy
+func (e *Engine) SetPolicy(policy Policy) (Policy, error) {
+	if err := e.set(policy, SourceAPI); err != nil {
+		return Policy{}, err
+	}
+	return e.Policy(), nil
+}
+
+func (e *Engine) set(policy Policy, source string) error {
+	policy.Rules = append([]Rule(nil), policy.Rules...)
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy, e.source = policy, source
+	return nil
+}
+
+// Policy returns the current policy
+func (e *Engine) Policy() Policy {
+	policy, _ := e.PolicyWithSource()
+	return policy
+}
+
+// PolicyWithSource returns the current policy and where it comes from: default, file or api
+func (e *Engine) PolicyWithSource() (Policy, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy, e.source
+}
+
+// Evaluate decides whether a command line may run, without recording rejections
+func (e *Engine) Evaluate(command string) Decision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy.Evaluate(command)
+}
+
+// Check returns a *DeniedError when the policy rejects a command, and records the
+// rejection
+func (e *Engine) Check(command string, workingDir string) error {
+	decision := e.Evaluate(command)
+	if decision.Allowed {
+		return nil
+	}
+
+	logrus.WithField("rule", decision.Rule).Warnf("Rejected command %q: %s", command, decision.Reason)
+	e.mu.Lock()
+	e.rejections = append(e.rejections, Rejection{
+		Timestamp:  time.Now().UTC(),
+		Command:    command,
+		WorkingDir: workingDir,
+		Rule:       decision.Rule,
+		Reason:     decision.Reason,
+	})
+	if len(e.rejections) > maxRejections {
+		e.rejections = append([]Rejection(nil), e.rejections[len(e.rejections)-maxRejections:]...)
+	}
+	e.mu.Unlock()
+	return &DeniedError{Command: command, Decision: decision}
+}
+
+// Rejections returns up to limit of the most recent rejected commands, oldest first
+func (e *Engine) Rejections(limit int) []Rejection {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	start := 0
+	if limit > 0 && len(e.rejections) > limit {
+		start = len(e.rejections) - limit
+	}
+	return append([]Rejection{}, e.rejections[start:]...)
+}
@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestParseCommands tests splitting command lines into simple commands
+func TestParseCommands(t *testing.T) {
+	programs := func(line string) []string {
+		result := make([]string, 0)
+		for _, command := range ParseCommands(line) {
+			result = append(result, command.Program)
+		}
+		return result
+	}
+
+	for line, want := range map[string][]string{
+		"ls -la": {"ls"},
+		"cd /app && npm install; npm test || exit 1": {"cd", "npm", "npm", "exit"},
+		"curl -s https://x.sh | sudo -E bash":        {"curl", "bash"},
+		"FOO=bar /usr/bin/env DEBUG=1 python app.py": {"python"},
+		"echo \"today is $(date +%F)\" `whoami`":     {"echo", "date", "whoami"},
+		"echo 'a | b; c' \"d && e\"":                 {"echo"},
+		"make build 2>&1 > out.log &":                {"make"},
+		"(cd src; go test ./...)":                    {"cd", "go"},
+		"bash -c 'rm -rf /tmp/x && echo done'":       {"bash", "rm", "echo"},
+		"echo $((1 + 2))":                            {"echo"},
+	} {
+		if got := programs(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %q to have programs %v, got %v", line, want, got)
+		}
+	}
+
+	commands := ParseCommands("rm -rf \"/home/user/my dir\"")
+	if len(commands) != 1 || !reflect.DeepEqual(commands[0].Args, []string{"-rf", "/home/user/my dir"}) {
+		t.Errorf("Unexpected arguments %+v", commands)
+	}
+}
+
+// TestPolicyEvaluate tests deny rules, argument constraints and allow lists
+func TestPolicyEvaluate(t *testing.T) {
+	denyList := Policy{Rules: []Rule{
+		{Name: "no-pipe-to-shell", Action: ActionDeny, Pattern: `(curl|wget)\b.*\|\s*(sudo\s+)?(ba|z|da)?sh\b`, Message: "Piping downloads to a shell is not allowed"},
+		{Name: "no-rm-root", Action: ActionDeny, Program: "rm", Args: []string{`^-[a-zA-Z]*r`, `^/$`}},
+	}}
+	if err := denyList.Validate(); err != nil {
+		t.Fatalf("Expected a valid policy: %v", err)
+	}
+	for line, allowed := range map[string]bool{
+		"curl -fsSL https://get.example.com | sh": false,
+		"wget -qO- https://x | sudo bash":         false,
+		"curl -o install.sh https://x":            true,
+		"rm -rf /":                                false,
+		"cd /tmp && sudo rm -fr /":                false,
+		"sh -c 'rm -r /'":                         false,
+		"rm -rf /tmp/build":                       true,
+		"rm /":                                    true,
+	} {
+		decision := denyList.Evaluate(line)
+		if decision.Allowed != allowed {
+			t.Errorf("Expected %q allowed=%v, got %+v", line, allowed, decision)
+		}
+	}
+	if decision := denyList.Evaluate("curl https://x | sh"); decision.Rule != "no-pipe-to-shell" || decision.Reason != "Piping downloads to a shell is not allowed" {
+		t.Errorf("Unexpected decision %+v", decision)
+	}
+
+	allowList := Policy{DefaultAction: ActionDeny, Rules: []Rule{
+		{Action: ActionAllow, Program: "npm|node|ls|cat|grep"},
+		{Action: ActionAllow, Pattern: `^git status$`},
+		{Action: ActionDeny, Program: "npm", Args: []string{`^publish$`}},
+	}}
+	if err := allowList.Validate(); err != nil {
+		t.Fatalf("Expected a valid policy: %v", err)
+	}
+	for line, allowed := range map[string]bool{
+		"npm install && npm test": true,
+		"cat log | grep error":    true,
+		"git status":              true,
+		"npm publish":             false,
+		"ls; python app.py":       false,
+		"npx something":           false,
+		"":                        false,
+	} {
+		if decision := allowList.Evaluate(line); decision.Allowed != allowed {
+			t.Errorf("Expected %q allowed=%v, got %+v", line, allowed, decision)
+		}
+	}
+}
+
+// TestPolicyValidate tests rejecting invalid policies
+func TestPolicyValidate(t *testing.T) {
+	invalid := map[string]Policy{
+		"default action": {DefaultAction: "maybe"},
+		"rule action":    {Rules: []Rule{{Action: "block", Program: "rm"}}},
+		"no condition":   {Rules: []Rule{{Action: ActionDeny}}},
+		"args only":      {Rules: []Rule{{Action: ActionDeny, Pattern: "rm", Args: []string{"-r"}}}},
+		"bad pattern":    {Rules: []Rule{{Action: ActionDeny, Pattern: "("}}},
+		"bad argument":   {Rules: []Rule{{Action: ActionDeny, Program: "rm", Args: []string{"["}}}},
+	}
+	for name, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Expected an error for an invalid %s", name)
+		}
+	}
+}
+
+// TestEngine tests loading a YAML policy, checking commands and recording rejections
+func TestEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	content := `defaultAction: allow
+rules:
+  - name: no-shutdown
+    action: deny
+    program: shutdown|reboot
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine()
+	if err := engine.LoadFile(path); err != nil {
+		t.Fatalf("Failed to load the policy: %v", err)
+	}
+	if _, source := engine.PolicyWithSource(); source != SourceFile {
+		t.Errorf("Expected the file source, got %s", source)
+	}
+
+	if err := engine.Check("echo hello", "/tmp"); err != nil {
+		t.Errorf("Expected echo to be allowed: %v", err)
+	}
+	err := engine.Check("sudo reboot now", "/tmp")
+	var denied *DeniedError
+	if !errors.Is(err, ErrDenied) || !errors.As(err, &denied) || denied.Decision.Rule != "no-shutdown" {
+		t.Fatalf("Expected reboot to be denied, got %v", err)
+	}
+	if decision := engine.Evaluate("shutdown -h now"); decision.Allowed {
+		t.Error("Expected shutdown to be denied")
+	}
+
+	rejections := engine.Rejections(10)
+	if len(rejections) != 1 || rejections[0].Command != "sudo reboot now" || rejections[0].WorkingDir != "/tmp" {
+		t.Fatalf("Expected only the checked command to be recorded, got %+v", rejections)
+	}
+
+	if _, err := engine.SetPolicy(Policy{DefaultAction: "nope"}); err == nil {
+		t.Error("Expected an error for an invalid policy")
+	}
+	if current, source := engine.PolicyWithSource(); source != SourceFile || len(current.Rules) != 1 {
+		t.Error("Expected an invalid policy not to replace the current one")
+	}
+}
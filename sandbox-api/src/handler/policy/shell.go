@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxShellDepth is how deeply "sh -c" scripts are parsed
+const maxShellDepth = 4
+
+// wrappers are programs running the command given as their arguments, which are
+// skipped along with their options to find the program actually run
+var wrappers = map[string]bool{
+	"builtin": true,
+	"command": true,
+	"env":     true,
+	"exec":    true,
+	"nice":    true,
+	"nohup":   true,
+	"stdbuf":  true,
+	"sudo":    true,
+	"time":    true,
+}
+
+// shells are programs whose -c argument is a command line
+var shells = map[string]bool{
+	"ash":  true,
+	"bash": true,
+	"dash": true,
+	"ksh":  true,
+	"sh":   true,
+	"zsh":  true,
+}
+
+// Command is a simple command of a command line: a program and its arguments
+type Command struct {
+	Program string   `json:"program" example:"rm"`
+	Args    []string `json:"args" example:"-rf,/tmp/build"`
+}
+
+// ParseCommands splits a shell command line into its simple commands: the commands of
+// lists (;, &&, ||, &), pipelines, subshells, command substitutions and "sh -c" scripts.
+// Leading variable assignments and wrappers such as sudo or env are skipped, and the
+// program is the base name of the first remaining word. Quoting is honored but
+// expansions are not performed, so policies are guardrails rather than a security
+// boundary.
+func ParseCommands(line string) []Command {
+	return parseCommands(line, 0)
+}
+
+func parseCommands(line string, depth int) []Command {
+	commands := make([]Command, 0)
+	for _, words := range splitLine(line) {
+		commands = append(commands, newCommands(words, depth)...)
+	}
+	return commands
+}
+
+// newCommands returns the command of the words of a simple command, followed by the
+// commands of its "sh -c" script
+func newCommands(words []string, depth int) []Command {
+	// Skip variable assignments, wrappers and their options
+	i := 0
+	for i < len(words) {
+		word := words[i]
+		if isAssignment(word) || wrappers[filepath.Base(word)] || (i > 0 && strings.HasPrefix(word, "-") && wrappers[filepath.Base(words[i-1])]) {
+			i++
+			continue
+		}
+		break
+	}
+	if i == len(words) {
+		return nil
+	}
+
+	command := Command{Program: filepath.Base(words[i]), Args: words[i+1:]}
+	commands := []Command{command}
+	if shells[command.Program] && depth < maxShellDepth {
+		for j, arg := range command.Args {
+			if strings.HasPrefix(arg, "-") && strings.Contains(arg, "c") && !strings.HasPrefix(arg, "--") && j+1 < len(command.Args) {
+				commands = append(commands, parseCommands(command.Args[j+1], depth+1)...)
+				break
+			}
+		}
+	}
+	return commands
+}
+
+// isAssignment returns true for NAME=value words
+func isAssignment(word string) bool {
+	name, _, found := strings.Cut(word, "=")
+	if !found || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLine splits a command line into the words of its simple commands
+func splitLine(line string) [][]string {
+	var (
+		commands [][]string
+		words    []string
+		word     strings.Builder
+		inWord   bool
+		inSingle bool
+		inDouble bool
+		// quoting of the enclosing levels of the current $() substitution
+		substitutions []bool
+		// whether in a `` substitution, and the quoting around it
+		inBackticks     bool
+		backticksDouble bool
+	)
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		prev := rune(0)
+		if i > 0 {
+			prev = runes[i-1]
+		}
+
+		switch {
+		case inSingle:
+			if r == '\'' {
+				inSingle = false
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\' && next != 0:
+			word.WriteRune(next)
+			inWord = true
+			i++
+		case r == '$' && next == '(' && i+2 < len(runes) && runes[i+2] == '(':
+			// Arithmetic expansions are part of words
+			end := i + 3
+			for end < len(runes) && (runes[end] != ')' || end+1 == len(runes) || runes[end+1] != ')') {
+				end++
+			}
+			end = min(end+2, len(runes))
+			word.WriteString(string(runes[i:end]))
+			inWord = true
+			i = end - 1
+		case r == '$' && next == '(':
+			// Command substitution, its commands are parsed as commands of their own
+			substitutions = append(substitutions, inDouble)
+			inDouble = false
+			endCommand()
+			i++
+		case r == ')' && len(substitutions) > 0:
+			endCommand()
+			inDouble = substitutions[len(substitutions)-1]
+			substitutions = substitutions[:len(substitutions)-1]
+		case r == '`':
+			endCommand()
+			if inBackticks {
+				inDouble = backticksDouble
+			} else {
+				backticksDouble = inDouble
+				inDouble = false
+			}
+			inBackticks = !inBackticks
+		case inDouble:
+			if r == '"' {
+				inDouble = false
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'':
+			inSingle, inWord = true, true
+		case r == '"':
+			inDouble, inWord = true, true
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == '&' && (prev == '>' || prev == '<' || next == '>'):
+			// Redirections such as 2>&1 and &> are part of words
+			word.WriteRune(r)
+			inWord = true
+		case r == ';' || r == '&' || r == '|' || r == '\n' || r == '(' || r == ')' || r == '{' && !inWord || r == '}' && !inWord:
+			endCommand()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands
+}
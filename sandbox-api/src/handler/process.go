@@ -1,10 +1,16 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +18,11 @@ import (
 	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
 	"github.com/blaxel-ai/sandbox-api/src/handler/process"
 	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/service"
 )
 
 var (
@@ -19,6 +30,9 @@ var (
 	processHandlerOnce     sync.Once
 )
 
+// maxWaitTimeout is the maximum number of seconds a wait request can block
+const maxWaitTimeout = 600
+
 // GetProcessHandler returns the singleton process handler instance
 func GetProcessHandler() *ProcessHandler {
 	processHandlerOnce.Do(func() {
@@ -31,43 +45,75 @@ func GetProcessHandler() *ProcessHandler {
 type ProcessHandler struct {
 	*BaseHandler
 	processManager *process.ProcessManager
+	processes      *service.Process
 }
 
 // NewProcessHandler creates a new process handler
 func NewProcessHandler() *ProcessHandler {
+	processManager := process.GetProcessManager()
 	return &ProcessHandler{
 		BaseHandler:    NewBaseHandler(),
-		processManager: process.GetProcessManager(),
+		processManager: processManager,
+		processes:      service.NewProcess(processManager),
 	}
 }
 
 // ProcessRequest is the request body for executing a command
 type ProcessRequest struct {
-	Command           string            `json:"command" example:"ls -la" binding:"required"`
-	Name              string            `json:"name" example:"my-process"`
-	WorkingDir        string            `json:"workingDir" example:"/home/user"`
-	Env               map[string]string `json:"env" example:"{\"PORT\": \"3000\"}"`
-	WaitForCompletion bool              `json:"waitForCompletion" example:"false"`
-	Timeout           int               `json:"timeout" example:"30"`
-	WaitForPorts      []int             `json:"waitForPorts" example:"3000,8080"`
-	RestartOnFailure  bool              `json:"restartOnFailure" example:"true"`
-	MaxRestarts       int               `json:"maxRestarts" example:"3"`
+	Command    string            `json:"command" example:"ls -la" binding:"required"`
+	Name       string            `json:"name" example:"my-process"`
+	WorkingDir string            `json:"workingDir" example:"/home/user"`
+	Env        map[string]string `json:"env" example:"{\"PORT\": \"3000\"}"`
+	// RunAsUser and RunAsGroup are the user and group, by name or id, the process runs as. The RUN_AS user by default.
+	RunAsUser         string `json:"runAsUser" example:"1000"`
+	RunAsGroup        string `json:"runAsGroup" example:"1000"`
+	WaitForCompletion bool   `json:"waitForCompletion" example:"false"`
+	// Timeout is the number of seconds after which the process is killed, with status timedout. 0 for none.
+	Timeout           int    `json:"timeout" example:"30"`
+	WaitForPorts      []int  `json:"waitForPorts" example:"3000,8080"`
+	WaitForLogPattern string `json:"waitForLogPattern" example:"Listening on"`
+	RestartOnFailure  bool   `json:"restartOnFailure" example:"true"`
+	MaxRestarts       int    `json:"maxRestarts" example:"3"`
+	// RestartPolicy overrides restartOnFailure, maxRestarts then defaults to 25
+	RestartPolicy string                 `json:"restartPolicy" example:"on-failure" enums:"never,on-failure,always"`
+	Backoff       *process.BackoffConfig `json:"backoff"`
+	// RestartWindow is the sliding window in seconds maxRestarts applies to, the lifetime of the process when 0
+	RestartWindow int `json:"restartWindow" example:"300"`
+	// LogToFile also writes the output to rotated log files, downloadable from /process/{identifier}/logs/download. Always on when PROCESS_LOG_TO_FILE is set.
+	LogToFile bool `json:"logToFile" example:"false"`
+	// Record records the output to an asciicast v2 file, listed by /recordings, to replay what ran in a terminal. Always on when PROCESS_RECORD is set.
+	Record bool `json:"record" example:"false"`
 } // @name ProcessRequest
 
 // ProcessResponse is the response body for a process
 type ProcessResponse struct {
-	PID              string  `json:"pid" example:"1234" binding:"required"`
-	Name             string  `json:"name" example:"my-process" binding:"required"`
-	Command          string  `json:"command" example:"ls -la" binding:"required"`
-	Status           string  `json:"status" example:"running" enums:"failed,killed,stopped,running,completed" binding:"required"`
-	StartedAt        string  `json:"startedAt" example:"Wed, 01 Jan 2023 12:00:00 GMT" binding:"required"`
-	CompletedAt      *string `json:"completedAt" example:"Wed, 01 Jan 2023 12:01:00 GMT" binding:"required"`
-	ExitCode         int     `json:"exitCode" example:"0" binding:"required"`
-	WorkingDir       string  `json:"workingDir" example:"/home/user" binding:"required"`
-	Logs             *string `json:"logs" example:"logs output" binding:"required"`
-	RestartOnFailure bool    `json:"restartOnFailure" example:"true"`
-	MaxRestarts      int     `json:"maxRestarts" example:"3"`
-	RestartCount     int     `json:"restartCount" example:"2"`
+	PID              string                 `json:"pid" example:"1234" binding:"required"`
+	Name             string                 `json:"name" example:"my-process" binding:"required"`
+	Command          string                 `json:"command" example:"ls -la" binding:"required"`
+	Status           string                 `json:"status" example:"running" enums:"failed,killed,stopped,running,completed,timedout" binding:"required"`
+	StartedAt        string                 `json:"startedAt" example:"Wed, 01 Jan 2023 12:00:00 GMT" binding:"required"`
+	CompletedAt      *string                `json:"completedAt" example:"Wed, 01 Jan 2023 12:01:00 GMT" binding:"required"`
+	ExitCode         int                    `json:"exitCode" example:"0" binding:"required"`
+	WorkingDir       string                 `json:"workingDir" example:"/home/user" binding:"required"`
+	RunAsUser        string                 `json:"runAsUser,omitempty" example:"1000"`
+	RunAsGroup       string                 `json:"runAsGroup,omitempty" example:"1000"`
+	Timeout          int                    `json:"timeout,omitempty" example:"30"`                // seconds after which the process is killed
+	Adopted          bool                   `json:"adopted,omitempty" example:"false"`             // an OS process not started by the API
+	LogToFile        bool                   `json:"logToFile,omitempty" example:"false"`           // output is also written to rotated log files
+	Recording        string                 `json:"recording,omitempty" example:"1234-1700000000"` // id of the recording of the output
+	Logs             *string                `json:"logs" example:"logs output" binding:"required"`
+	RestartOnFailure bool                   `json:"restartOnFailure" example:"true"`
+	MaxRestarts      int                    `json:"maxRestarts" example:"3"`
+	RestartCount     int                    `json:"restartCount" example:"2"`
+	RestartPolicy    string                 `json:"restartPolicy" example:"on-failure" enums:"never,on-failure,always"`
+	Backoff          *process.BackoffConfig `json:"backoff,omitempty"`
+	RestartWindow    int                    `json:"restartWindow,omitempty" example:"300"`
+	RestartDelayMs   int64                  `json:"restartDelayMs,omitempty" example:"4000"`                         // delay before the latest restart
+	NextRestartAt    *string                `json:"nextRestartAt,omitempty" example:"Wed, 01 Jan 2023 12:01:04 GMT"` // set while waiting to restart
+	// Environment is the resolved command line, shell and environment of the process,
+	// secrets redacted. It is only set when getting a single process, and not for the
+	// processes not started by the API once they exited.
+	Environment *process.ProcessEnvironment `json:"environment,omitempty"`
 } // @name ProcessResponse
 
 type ProcessResponseWithLogs struct {
@@ -75,37 +121,97 @@ type ProcessResponseWithLogs struct {
 	Logs string `json:"logs" example:"logs output"`
 }
 
+// ProcessLogFrame is a line of a stream of the output of a process, the output of a
+// single stream written at the same time. Seq is the absolute offset following data: it
+// counts every byte written to the output since the process started, so a client
+// reconnecting with fromOffset set to the seq of the last frame it received resumes
+// without missing nor repeating output.
+type ProcessLogFrame struct {
+	// Stream is stdout or stderr, empty for the messages of the API and the output
+	// restored after an API restart, and for keepalive and last frames without data
+	Stream string    `json:"stream" example:"stdout" enums:"stdout,stderr,"`
+	Data   string    `json:"data" example:"Server listening on :3000\n"`
+	Ts     time.Time `json:"ts" example:"2025-01-01T12:00:00.000Z"`
+	Seq    int64     `json:"seq" example:"1050" binding:"required"`
+	// Missed is the number of bytes dropped from the log buffer before they could be sent
+	Missed int64 `json:"missed,omitempty" example:"0"`
+	// Done is set on the last frame, once the process has terminated
+	Done bool `json:"done,omitempty" example:"false"`
+	// Error is set on the last frame when the stream ends because output was missed with
+	// the disconnect overflow policy
+	Error string `json:"error,omitempty"`
+} // @name ProcessLogFrame
+
+// RemovedProcessesResponse is the response body for a bulk removal of processes
+type RemovedProcessesResponse struct {
+	Removed []string `json:"removed" example:"1234,1235" binding:"required"` // PIDs of the removed processes
+} // @name RemovedProcessesResponse
+
+// ProcessSignalRequest is the request body for sending a signal to a process
+type ProcessSignalRequest struct {
+	// Signal name, with or without the SIG prefix, or number
+	Signal string `json:"signal" example:"SIGHUP" binding:"required"`
+} // @name ProcessSignalRequest
+
 // ProcessKillRequest is the request body for killing a process
 type ProcessKillRequest struct {
 	Signal string `json:"signal" example:"SIGTERM"`
 } // @name ProcessKillRequest
 
-// ExecuteProcess executes a process
-func (h *ProcessHandler) ExecuteProcess(command string, workingDir string, name string, env map[string]string, waitForCompletion bool, timeout int, waitForPorts []int, restartOnFailure bool, maxRestarts int) (ProcessResponse, error) {
-	processInfo, err := h.processManager.ExecuteProcess(command, workingDir, name, env, waitForCompletion, timeout, waitForPorts, restartOnFailure, maxRestarts)
+// StartProcess starts a process, like every API does
+func (h *ProcessHandler) StartProcess(ctx context.Context, req service.StartRequest) (ProcessResponse, error) {
+	processInfo, err := h.processes.Start(ctx, req)
 	if err != nil {
 		return ProcessResponse{}, err
 	}
 
-	completedAt := ""
-	if processInfo.CompletedAt != nil {
-		completedAt = processInfo.CompletedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT")
+	response := newProcessResponse(processInfo)
+	if response.CompletedAt == nil {
+		completedAt := ""
+		response.CompletedAt = &completedAt
+	}
+	return response, nil
+}
+
+// newProcessResponse converts a process to its response body
+func newProcessResponse(p *process.ProcessInfo) ProcessResponse {
+	state := p.State()
+	var completedAtPtr *string
+	if state.CompletedAt != nil {
+		completedAt := state.CompletedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT")
+		completedAtPtr = &completedAt
+	}
+	response := ProcessResponse{
+		PID:              p.PID,
+		Name:             p.Name,
+		Command:          p.Command,
+		Status:           string(state.Status),
+		StartedAt:        p.StartedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT"),
+		CompletedAt:      completedAtPtr,
+		ExitCode:         state.ExitCode,
+		WorkingDir:       p.WorkingDir,
+		RunAsUser:        p.RunAsUser,
+		RunAsGroup:       p.RunAsGroup,
+		Timeout:          p.Timeout,
+		Adopted:          p.Adopted,
+		LogToFile:        p.LogToFile,
+		Recording:        p.Recording,
+		Logs:             p.Logs,
+		RestartOnFailure: p.RestartOnFailure,
+		MaxRestarts:      p.MaxRestarts,
+		RestartCount:     p.RestartCount,
+		RestartPolicy:    string(p.RestartPolicy),
+		Backoff:          p.Backoff,
+		RestartWindow:    int(p.RestartWindow().Seconds()),
 	}
 
-	return ProcessResponse{
-		PID:              processInfo.PID,
-		Name:             processInfo.Name,
-		Command:          processInfo.Command,
-		Status:           string(processInfo.Status),
-		StartedAt:        processInfo.StartedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT"),
-		CompletedAt:      &completedAt,
-		ExitCode:         processInfo.ExitCode,
-		WorkingDir:       processInfo.WorkingDir,
-		Logs:             processInfo.Logs,
-		RestartOnFailure: processInfo.RestartOnFailure,
-		MaxRestarts:      processInfo.MaxRestarts,
-		RestartCount:     processInfo.RestartCount,
-	}, nil
+	restartDelay, nextRestartAt := p.BackoffState()
+	response.RestartDelayMs = restartDelay.Milliseconds()
+	if nextRestartAt != nil {
+		next := nextRestartAt.Format("Mon, 02 Jan 2006 15:04:05 GMT")
+		response.NextRestartAt = &next
+	}
+	return response
 }
 
 // ListProcesses lists all running processes
@@ -113,54 +219,53 @@ func (h *ProcessHandler) ListProcesses() []ProcessResponse {
 	processes := h.processManager.ListProcesses()
 	result := make([]ProcessResponse, 0, len(processes))
 	for _, p := range processes {
-		var completedAtPtr *string
-		if p.CompletedAt != nil {
-			completedAt := p.CompletedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT")
-			completedAtPtr = &completedAt
-		}
-		result = append(result, ProcessResponse{
-			PID:              p.PID,
-			Name:             p.Name,
-			Command:          p.Command,
-			Status:           string(p.Status),
-			StartedAt:        p.StartedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT"),
-			CompletedAt:      completedAtPtr,
-			ExitCode:         p.ExitCode,
-			WorkingDir:       p.WorkingDir,
-			Logs:             p.Logs,
-			RestartOnFailure: p.RestartOnFailure,
-			MaxRestarts:      p.MaxRestarts,
-			RestartCount:     p.RestartCount,
-		})
+		result = append(result, newProcessResponse(p))
 	}
 	return result
 }
 
+// CountProcessesByStatus returns the number of managed processes for each status
+func (h *ProcessHandler) CountProcessesByStatus() map[string]float64 {
+	counts := map[string]float64{
+		string(constants.ProcessStatusRunning):   0,
+		string(constants.ProcessStatusCompleted): 0,
+		string(constants.ProcessStatusFailed):    0,
+		string(constants.ProcessStatusStopped):   0,
+		string(constants.ProcessStatusKilled):    0,
+		string(constants.ProcessStatusTimedOut):  0,
+	}
+	for _, p := range h.processManager.ListProcesses() {
+		counts[string(p.State().Status)]++
+	}
+	return counts
+}
+
 // GetProcess gets a process by identifier (PID or name)
 func (h *ProcessHandler) GetProcess(identifier string) (ProcessResponse, error) {
 	processInfo, exists := h.processManager.GetProcessByIdentifier(identifier)
 	if !exists {
-		return ProcessResponse{}, fmt.Errorf("process not found")
+		return ProcessResponse{}, apierror.New(apierror.CodeProcNotFound, "process not found")
 	}
 
-	completedAt := ""
-	if processInfo.CompletedAt != nil {
-		completedAt = processInfo.CompletedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT")
+	response := newProcessResponse(processInfo)
+	if response.CompletedAt == nil {
+		completedAt := ""
+		response.CompletedAt = &completedAt
 	}
-	return ProcessResponse{
-		PID:              processInfo.PID,
-		Name:             processInfo.Name,
-		Command:          processInfo.Command,
-		Status:           string(processInfo.Status),
-		StartedAt:        processInfo.StartedAt.Format("Mon, 02 Jan 2006 15:04:05 GMT"),
-		CompletedAt:      &completedAt,
-		ExitCode:         processInfo.ExitCode,
-		WorkingDir:       processInfo.WorkingDir,
-		Logs:             processInfo.Logs,
-		RestartOnFailure: processInfo.RestartOnFailure,
-		MaxRestarts:      processInfo.MaxRestarts,
-		RestartCount:     processInfo.RestartCount,
-	}, nil
+	if environment, err := processInfo.Environment(); err == nil {
+		response.Environment = &environment
+	}
+	return response, nil
+}
+
+// GetProcessEnvironment gets the resolved command line, shell and environment of a
+// process, secrets redacted
+func (h *ProcessHandler) GetProcessEnvironment(identifier string) (process.ProcessEnvironment, error) {
+	processInfo, exists := h.processManager.GetProcessByIdentifier(identifier)
+	if !exists {
+		return process.ProcessEnvironment{}, apierror.New(apierror.CodeProcNotFound, "process not found")
+	}
+	return processInfo.Environment()
 }
 
 // GetProcessOutput gets the output of a process
@@ -168,19 +273,57 @@ func (h *ProcessHandler) GetProcessOutput(identifier string) (process.ProcessLog
 	return h.processManager.GetProcessOutput(identifier)
 }
 
+// QueryProcessOutput gets a portion of the output of a process
+func (h *ProcessHandler) QueryProcessOutput(identifier string, query process.LogQuery) (process.ProcessLogs, error) {
+	return h.processManager.QueryProcessOutput(identifier, query)
+}
+
 // StopProcess stops a process
 func (h *ProcessHandler) StopProcess(identifier string) error {
 	return h.processManager.StopProcess(identifier)
 }
 
+// StopProcessWithTimeout stops a process, and kills it when it is still running after the timeout
+func (h *ProcessHandler) StopProcessWithTimeout(identifier string, timeout time.Duration) error {
+	return h.processManager.StopProcessWithTimeout(identifier, timeout)
+}
+
+// SignalProcess sends a signal to a process
+func (h *ProcessHandler) SignalProcess(identifier string, signal syscall.Signal) error {
+	return h.processManager.SignalProcess(identifier, signal)
+}
+
 // KillProcess kills a process
 func (h *ProcessHandler) KillProcess(identifier string) error {
 	return h.processManager.KillProcess(identifier)
 }
 
-// StreamProcessOutput streams the output of a process
-func (h *ProcessHandler) StreamProcessOutput(identifier string, writer io.Writer) error {
-	return h.processManager.StreamProcessOutput(identifier, writer)
+// RemoveProcess removes a terminated process from the process table
+func (h *ProcessHandler) RemoveProcess(identifier string) error {
+	return h.processManager.RemoveProcess(identifier)
+}
+
+// RemoveProcesses removes the terminated processes with the given status, or all of them when empty
+func (h *ProcessHandler) RemoveProcesses(status constants.ProcessStatus) []string {
+	return h.processManager.RemoveProcesses(status)
+}
+
+// StreamProcessOutput streams the output of a process until the returned stream is
+// closed, applying the overflow policy when the writer does not keep up
+func (h *ProcessHandler) StreamProcessOutput(identifier string, writer io.Writer, policy process.OverflowPolicy) (*process.LogStream, error) {
+	return h.processManager.StreamProcessOutput(identifier, writer, policy)
+}
+
+// FollowProcessOutput sends the output of a process from an absolute offset as it is
+// written, until the returned function is called or the process terminates
+func (h *ProcessHandler) FollowProcessOutput(identifier string, stream string, from int64, send func(process.OutputChunk)) (func(), error) {
+	return h.processManager.FollowProcessOutput(identifier, stream, from, send)
+}
+
+// OpenLogFiles returns a reader of the rotated log files of a process, with its size and
+// the name of the process
+func (h *ProcessHandler) OpenLogFiles(identifier string) (io.ReadCloser, int64, string, error) {
+	return h.processManager.OpenLogFiles(identifier)
 }
 
 // RemoveLogWriter removes a log writer from a process
@@ -203,13 +346,15 @@ func (h *ProcessHandler) HandleListProcesses(c *gin.Context) {
 
 // HandleExecuteCommand handles POST requests to /process/
 // @Summary Execute a command
-// @Description Execute a command and return process information
+// @Description Execute a command and return process information. When waitForLogPattern is set, the request returns once the process logs match this regular expression, or fails after timeout seconds (default: 60). With timeout, the process and its children are killed once it has run for that many seconds, and its status is timedout. With restartPolicy, the process is restarted when it fails (on-failure) or whenever it exits (always), after a delay growing with backoff. Stopping it while it waits to restart cancels the restart.
 // @Tags process
 // @Accept json
 // @Produce json
 // @Param request body ProcessRequest true "Process execution request"
 // @Success 200 {object} ProcessResponse "Process information"
 // @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 403 {object} ErrorResponse "Command denied by the process policy"
+// @Failure 409 {object} ErrorResponse "A running process has the same name"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /process [post]
@@ -220,26 +365,24 @@ func (h *ProcessHandler) HandleExecuteCommand(c *gin.Context) {
 		return
 	}
 
-	if req.WorkingDir != "" {
-		formattedWorkingDir, err := lib.FormatPath(req.WorkingDir)
-		if err != nil {
-			h.SendError(c, http.StatusBadRequest, err)
-			return
-		}
-		req.WorkingDir = formattedWorkingDir
-	}
-
-	// If a name is provided, check if a process with that name already exists
-	if req.Name != "" {
-		alreadyExists, err := h.GetProcess(req.Name)
-		if err == nil && alreadyExists.Status == string(constants.ProcessStatusRunning) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("process with name '%s' already exists and is running", req.Name)})
-			return
-		}
-	}
-
-	// Execute the process
-	processInfo, err := h.ExecuteProcess(req.Command, req.WorkingDir, req.Name, req.Env, req.WaitForCompletion, req.Timeout, req.WaitForPorts, req.RestartOnFailure, req.MaxRestarts)
+	processInfo, err := h.StartProcess(c.Request.Context(), service.StartRequest{
+		Command:           req.Command,
+		Name:              req.Name,
+		WorkingDir:        req.WorkingDir,
+		Env:               req.Env,
+		RunAs:             lib.RunAs{User: req.RunAsUser, Group: req.RunAsGroup},
+		WaitForCompletion: req.WaitForCompletion,
+		Timeout:           req.Timeout,
+		WaitForPorts:      req.WaitForPorts,
+		WaitForLogPattern: req.WaitForLogPattern,
+		RestartPolicy:     req.RestartPolicy,
+		RestartOnFailure:  req.RestartOnFailure,
+		MaxRestarts:       req.MaxRestarts,
+		Backoff:           req.Backoff,
+		RestartWindow:     req.RestartWindow,
+		LogToFile:         req.LogToFile,
+		Record:            req.Record,
+	})
 	if err != nil {
 		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
@@ -250,12 +393,19 @@ func (h *ProcessHandler) HandleExecuteCommand(c *gin.Context) {
 
 // HandleGetProcessLogs handles GET requests to /process/{identifier}/logs
 // @Summary Get process logs
-// @Description Get the stdout and stderr output of a process
+// @Description Get the stdout and stderr output of a process. Each buffer is capped by MAX_LOG_BYTES, the truncation fields report dropped output
 // @Tags process
 // @Accept json
 // @Produce json
 // @Param identifier path string true "Process identifier (PID or name)"
+// @Param stream query string false "Only return one stream" Enums(stdout, stderr)
+// @Param tail query int false "Only return the last N lines"
+// @Param since query string false "Only return output written since this time (RFC3339 or unix seconds)"
+// @Param offset query int false "Absolute byte offset to start from, use nextOffset from a previous response"
+// @Param limit query int false "Maximum number of bytes to return"
+// @Param timestamps query boolean false "Also return the output as entries with the time and stream of each write"
 // @Success 200 {object} process.ProcessLogs "Process logs"
+// @Failure 400 {object} ErrorResponse "Invalid query parameters"
 // @Failure 404 {object} ErrorResponse "Process not found"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
 // @Failure 500 {object} ErrorResponse "Internal server error"
@@ -267,7 +417,13 @@ func (h *ProcessHandler) HandleGetProcessLogs(c *gin.Context) {
 		return
 	}
 
-	logs, err := h.GetProcessOutput(identifier)
+	query, err := h.parseLogQuery(c)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	logs, err := h.QueryProcessOutput(identifier, query)
 	if err != nil {
 		h.SendError(c, http.StatusNotFound, err)
 		return
@@ -276,15 +432,65 @@ func (h *ProcessHandler) HandleGetProcessLogs(c *gin.Context) {
 	h.SendJSON(c, http.StatusOK, logs)
 }
 
+// parseLogQuery reads the log filtering query parameters
+func (h *ProcessHandler) parseLogQuery(c *gin.Context) (process.LogQuery, error) {
+	query := process.LogQuery{
+		Stream: c.Query("stream"),
+	}
+	if query.Stream != "" && query.Stream != "stdout" && query.Stream != "stderr" {
+		return query, fmt.Errorf("invalid stream: must be 'stdout' or 'stderr'")
+	}
+
+	var err error
+	if tail := c.Query("tail"); tail != "" {
+		if query.Tail, err = strconv.Atoi(tail); err != nil || query.Tail < 0 {
+			return query, fmt.Errorf("invalid tail: must be a positive number of lines")
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if query.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("invalid offset: must be a positive number of bytes")
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if query.Limit, err = strconv.ParseInt(limit, 10, 64); err != nil || query.Limit < 0 {
+			return query, fmt.Errorf("invalid limit: must be a positive number of bytes")
+		}
+	}
+	if timestamps := c.Query("timestamps"); timestamps != "" {
+		if query.Timestamps, err = strconv.ParseBool(timestamps); err != nil {
+			return query, fmt.Errorf("invalid timestamps: must be true or false")
+		}
+	}
+	if since := c.Query("since"); since != "" {
+		if seconds, err := strconv.ParseInt(since, 10, 64); err == nil {
+			query.Since = time.Unix(seconds, 0)
+		} else if query.Since, err = time.Parse(time.RFC3339Nano, since); err != nil {
+			return query, fmt.Errorf("invalid since: must be an RFC3339 timestamp or unix seconds")
+		}
+	}
+	return query, nil
+}
+
 // HandleGetProcessLogsStream handles GET requests to /process/{identifier}/logs/stream
 // @Summary Stream process logs in real time
-// @Description Streams the stdout and stderr output of a process in real time, one line per log, prefixed with 'stdout:' or 'stderr:'. Closes when the process exits or the client disconnects.
+// @Description Streams the output of a process in real time, one JSON ProcessLogFrame per line with the stream (stdout or stderr), data, time and seq of the output, from the oldest buffered output. Closes when the process exits or the client disconnects. The stream can start at the absolute byte offset fromOffset, or at the last tail lines, of the combined output or of the selected stream: a client reconnecting with fromOffset set to the seq of the last frame it received resumes exactly where it left off. Output dropped from the log buffer before it was sent is reported as missed (drop-oldest), or ends the stream with an error (disconnect). A frame without data is sent every 30 seconds as a keepalive.
+// @Description
+// @Description With format=plain, the legacy stream is sent instead: the buffered output, then one line per log prefixed with 'stdout:' or 'stderr:'. Its output is queued for clients that do not keep up, up to LOG_STREAM_QUEUE_BYTES (default: 1MiB): beyond it, the oldest output is dropped and replaced by a line telling how many bytes were (drop-oldest), or the stream is closed (disconnect).
 // @Tags process
+// @Produce json
 // @Produce plain
 // @Param identifier path string true "Process identifier (PID or name)"
-// @Success 200 {string} string "Stream of process logs, one line per log (prefixed with stdout:/stderr:)"
+// @Param format query string false "json (default) for ProcessLogFrame lines, or plain for the legacy prefixed lines" Enums(json, plain)
+// @Param overflow query string false "What to do when the client does not keep up: drop-oldest or disconnect (default: LOG_STREAM_OVERFLOW, or drop-oldest)"
+// @Param fromOffset query integer false "Absolute byte offset to resume the stream from, the seq of the last frame received"
+// @Param tail query integer false "Start the stream at the last tail lines of the output"
+// @Param stream query string false "Only stream stdout or stderr, offsets then refer to that stream" Enums(stdout, stderr)
+// @Success 200 {object} ProcessLogFrame "Stream of process logs, one JSON frame per line, or one line per log prefixed with stdout:/stderr: with format=plain"
+// @Failure 400 {object} ErrorResponse "Invalid format, overflow policy, offset or tail"
 // @Failure 404 {object} ErrorResponse "Process not found"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Failure 429 {object} ErrorResponse "Too many log streams open"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /process/{identifier}/logs/stream [get]
 func (h *ProcessHandler) HandleGetProcessLogsStream(c *gin.Context) {
@@ -293,6 +499,34 @@ func (h *ProcessHandler) HandleGetProcessLogsStream(c *gin.Context) {
 		h.SendError(c, http.StatusBadRequest, err)
 		return
 	}
+	policy, err := process.ParseOverflowPolicy(c.Query("overflow"))
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	switch c.Query("format") {
+	case "", "json":
+		h.streamProcessFrames(c, identifier, policy)
+	case "plain":
+		if c.Query("fromOffset") != "" || c.Query("tail") != "" || c.Query("stream") != "" {
+			h.SendError(c, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "fromOffset, tail and stream are not supported with format=plain"))
+			return
+		}
+		h.streamProcessOutputPlain(c, identifier, policy)
+	default:
+		h.SendError(c, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "invalid format: must be 'json' or 'plain'"))
+	}
+}
+
+// streamProcessOutputPlain streams the output of a process as lines prefixed with their
+// stream, see HandleGetProcessLogsStream
+func (h *ProcessHandler) streamProcessOutputPlain(c *gin.Context, identifier string, policy process.OverflowPolicy) {
+	release, err := streamlimit.LogStreams().Acquire(c.Request.RemoteAddr)
+	if err != nil {
+		h.SendError(c, http.StatusTooManyRequests, err)
+		return
+	}
+	defer release()
 
 	// Set headers for streaming
 	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -304,40 +538,201 @@ func (h *ProcessHandler) HandleGetProcessLogsStream(c *gin.Context) {
 	// Use the custom ResponseWriter for flushing
 	rw := &ResponseWriter{gin: c}
 
-	err = h.StreamProcessOutput(identifier, rw)
+	metrics.ActiveLogStreams.Inc()
+	defer metrics.ActiveLogStreams.Dec()
+
+	stream, err := h.StreamProcessOutput(identifier, rw, policy)
 	if err != nil {
 		h.SendError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
+	// Detach the writer once the queued output is written
+	defer stream.Close()
 
-	// Wait until the process is done or the client disconnects
-	process, exists := h.processManager.GetProcessByIdentifier(identifier)
+	// Wait until the process is done, the client disconnects or does not keep up
+	processInfo, exists := h.processManager.GetProcessByIdentifier(identifier)
 	if !exists {
 		return
 	}
-	for process.Status == constants.ProcessStatusRunning {
-		time.Sleep(200 * time.Millisecond)
-		// If client disconnects, break
+	select {
+	case <-processInfo.Done():
+	case <-c.Request.Context().Done():
+	case <-stream.Overflowed():
+	}
+}
+
+// logFrameKeepalive is the interval of the keepalive frames of log streams
+const logFrameKeepalive = 30 * time.Second
+
+// streamProcessFrames streams the output of a process as JSON ProcessLogFrame lines,
+// from the fromOffset or tail query parameter, see HandleGetProcessLogsStream
+func (h *ProcessHandler) streamProcessFrames(c *gin.Context, identifier string, policy process.OverflowPolicy) {
+	stream := c.Query("stream")
+	from, err := h.processManager.FollowStart(identifier, stream, c.Query("fromOffset"), c.Query("tail"))
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	release, err := streamlimit.LogStreams().Acquire(c.Request.RemoteAddr)
+	if err != nil {
+		h.SendError(c, http.StatusTooManyRequests, err)
+		return
+	}
+	defer release()
+	metrics.ActiveLogStreams.Inc()
+	defer metrics.ActiveLogStreams.Dec()
+
+	c.Writer.Header().Set("Content-Type", "application/x-ndjson")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Writer.Flush()
+	rw := &ResponseWriter{gin: c}
+	writeFrame := func(frame ProcessLogFrame) error {
+		line, err := json.Marshal(frame)
+		if err != nil {
+			return err
+		}
+		_, err = rw.Write(append(line, '\n'))
+		return err
+	}
+
+	// Frames are written from the goroutine of the follower, which waits for the client
+	var mu sync.Mutex
+	seq := from
+	ended := make(chan struct{})
+	var endOnce sync.Once
+	end := func() { endOnce.Do(func() { close(ended) }) }
+	stop, err := h.FollowProcessOutput(identifier, stream, from, func(chunk process.OutputChunk) {
+		mu.Lock()
+		defer mu.Unlock()
+		frame := ProcessLogFrame{Stream: chunk.Stream, Data: chunk.Logs, Ts: chunk.At, Seq: chunk.NextOffset, Missed: chunk.Missed, Done: chunk.Done}
+		if chunk.Missed > 0 && policy == process.OverflowDisconnect {
+			frame = ProcessLogFrame{Ts: time.Now(), Seq: seq, Missed: chunk.Missed, Error: fmt.Sprintf("%d bytes of output were dropped before they could be sent", chunk.Missed)}
+		}
+		if frame.Ts.IsZero() {
+			frame.Ts = time.Now()
+		}
+		seq = frame.Seq
+		if err := writeFrame(frame); err != nil || frame.Done || frame.Error != "" {
+			end()
+		}
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer stop()
+	defer rw.Close()
+
+	keepalive := time.NewTicker(logFrameKeepalive)
+	defer keepalive.Stop()
+	for {
 		select {
+		case <-ended:
+			return
 		case <-c.Request.Context().Done():
-			h.RemoveLogWriter(identifier, rw)
 			return
-		default:
+		case <-keepalive.C:
+			mu.Lock()
+			err := writeFrame(ProcessLogFrame{Ts: time.Now(), Seq: seq})
+			mu.Unlock()
+			if err != nil {
+				return
+			}
 		}
 	}
-	// Detach the writer
-	h.RemoveLogWriter(identifier, rw)
+}
+
+// HandleDownloadProcessLogs handles GET requests to /process/{identifier}/logs/download
+// @Summary Download process log files
+// @Description Downloads the output written to the log files of a process started with logToFile, or while PROCESS_LOG_TO_FILE is set. Log files are rotated every PROCESS_LOG_FILE_MAX_BYTES (default: 10MiB), keeping PROCESS_LOG_FILE_MAX_FILES rotated files (default: 5): the rotated files and the current one are sent one after the other, oldest first.
+// @Tags process
+// @Produce plain
+// @Param identifier path string true "Process identifier (PID or name)"
+// @Success 200 {string} string "Combined stdout and stderr output of the process"
+// @Failure 404 {object} ErrorResponse "Process not found or not logging to files"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /process/{identifier}/logs/download [get]
+func (h *ProcessHandler) HandleDownloadProcessLogs(c *gin.Context) {
+	identifier, err := h.GetPathParam(c, "identifier")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	reader, size, name, err := h.OpenLogFiles(identifier)
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".log"}))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		logging.FromContext(c.Request.Context()).Warnf("Failed to send log files of process %s: %v", identifier, err)
+	}
+}
+
+// HandleWaitProcess handles GET requests to /process/{identifier}/wait
+// @Summary Wait for a process to complete
+// @Description Blocks until the process completes or the timeout expires, then returns the process information. If the timeout expires first, the process is returned in its current state.
+// @Tags process
+// @Accept json
+// @Produce json
+// @Param identifier path string true "Process identifier (PID or name)"
+// @Param timeout query int false "Maximum time to wait in seconds (default: 30, max: 600)"
+// @Success 200 {object} ProcessResponse "Process information"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 404 {object} ErrorResponse "Process not found"
+// @Router /process/{identifier}/wait [get]
+func (h *ProcessHandler) HandleWaitProcess(c *gin.Context) {
+	identifier, err := h.GetPathParam(c, "identifier")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	timeout, err := strconv.Atoi(h.GetQueryParam(c, "timeout", "30"))
+	if err != nil || timeout < 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid timeout: must be a positive number of seconds"))
+		return
+	}
+	if timeout > maxWaitTimeout {
+		timeout = maxWaitTimeout
+	}
+
+	processInfo, _, err := h.processManager.WaitForProcess(c.Request.Context(), identifier, time.Duration(timeout)*time.Second)
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	response, err := h.GetProcess(processInfo.PID)
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, response)
 }
 
 // HandleStopProcess handles DELETE requests to /process/{identifier}
 // @Summary Stop a process
-// @Description Gracefully stop a running process
+// @Description Gracefully stop a running process by sending SIGTERM to its process group. With stopTimeoutSeconds, the process group is killed with SIGKILL when it is still running after that many seconds.
 // @Tags process
 // @Accept json
 // @Produce json
 // @Param identifier path string true "Process identifier (PID or name)"
+// @Param stopTimeoutSeconds query int false "Seconds to wait for the process to exit before killing it (default: 0, never killed)"
 // @Success 200 {object} SuccessResponse "Process stopped"
+// @Failure 400 {object} ErrorResponse "Invalid stop timeout"
 // @Failure 404 {object} ErrorResponse "Process not found"
+// @Failure 409 {object} ErrorResponse "Process is not running"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /process/{identifier} [delete]
@@ -348,7 +743,13 @@ func (h *ProcessHandler) HandleStopProcess(c *gin.Context) {
 		return
 	}
 
-	err = h.StopProcess(identifier)
+	stopTimeout, err := strconv.Atoi(h.GetQueryParam(c, "stopTimeoutSeconds", "0"))
+	if err != nil || stopTimeout < 0 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid stopTimeoutSeconds: must be a non-negative number"))
+		return
+	}
+
+	err = h.StopProcessWithTimeout(identifier, time.Duration(stopTimeout)*time.Second)
 	if err != nil {
 		h.SendError(c, http.StatusNotFound, err)
 		return
@@ -366,6 +767,7 @@ func (h *ProcessHandler) HandleStopProcess(c *gin.Context) {
 // @Param identifier path string true "Process identifier (PID or name)"
 // @Success 200 {object} SuccessResponse "Process killed"
 // @Failure 404 {object} ErrorResponse "Process not found"
+// @Failure 409 {object} ErrorResponse "Process is not running"
 // @Failure 422 {object} ErrorResponse "Unprocessable entity"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /process/{identifier}/kill [delete]
@@ -385,9 +787,101 @@ func (h *ProcessHandler) HandleKillProcess(c *gin.Context) {
 	h.SendJSON(c, http.StatusOK, gin.H{"message": "Process killed successfully"})
 }
 
+// HandleSignalProcess handles POST requests to /process/{identifier}/signal
+// @Summary Send a signal to a process
+// @Description Send any signal to the process group of a running process, for instance SIGHUP or SIGUSR1 to reload a server. The status of the process is left unchanged, so processes exiting on the signal are restarted according to their restart policy: stop or kill them to terminate them.
+// @Tags process
+// @Accept json
+// @Produce json
+// @Param identifier path string true "Process identifier (PID or name)"
+// @Param request body ProcessSignalRequest true "Signal to send"
+// @Success 200 {object} SuccessResponse "Signal sent"
+// @Failure 400 {object} ErrorResponse "Invalid signal"
+// @Failure 404 {object} ErrorResponse "Process not found"
+// @Failure 409 {object} ErrorResponse "Process is not running"
+// @Router /process/{identifier}/signal [post]
+func (h *ProcessHandler) HandleSignalProcess(c *gin.Context) {
+	identifier, err := h.GetPathParam(c, "identifier")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ProcessSignalRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	signal, err := process.ParseSignal(req.Signal)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.SignalProcess(identifier, signal); err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, gin.H{"message": "Signal sent successfully"})
+}
+
+// HandleRemoveProcessRecord handles DELETE requests to /process/{identifier}/record
+// @Summary Remove a process record
+// @Description Remove a terminated process from the process list, along with its logs. Terminated processes are also removed automatically past PROCESS_RETENTION_MAX_ENTRIES processes (default: 1000) or PROCESS_RETENTION_MAX_AGE seconds (default: 86400).
+// @Tags process
+// @Accept json
+// @Produce json
+// @Param identifier path string true "Process identifier (PID or name)"
+// @Success 200 {object} SuccessResponse "Process record removed"
+// @Failure 404 {object} ErrorResponse "Process not found"
+// @Failure 409 {object} ErrorResponse "Process is still running"
+// @Router /process/{identifier}/record [delete]
+func (h *ProcessHandler) HandleRemoveProcessRecord(c *gin.Context) {
+	identifier, err := h.GetPathParam(c, "identifier")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.RemoveProcess(identifier)
+	if err != nil {
+		if errors.Is(err, process.ErrProcessRunning) {
+			h.SendError(c, http.StatusConflict, err)
+			return
+		}
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, gin.H{"message": "Process record removed successfully"})
+}
+
+// HandleRemoveProcesses handles DELETE requests to /process
+// @Summary Remove process records
+// @Description Remove the terminated processes with the given status from the process list, or every terminated process when no status is given. Running processes are never removed.
+// @Tags process
+// @Accept json
+// @Produce json
+// @Param status query string false "Status of the processes to remove" Enums(completed, failed, stopped, killed, timedout)
+// @Success 200 {object} RemovedProcessesResponse "Removed processes"
+// @Failure 400 {object} ErrorResponse "Invalid status"
+// @Router /process [delete]
+func (h *ProcessHandler) HandleRemoveProcesses(c *gin.Context) {
+	status := constants.ProcessStatus(c.Query("status"))
+	switch status {
+	case "", constants.ProcessStatusCompleted, constants.ProcessStatusFailed, constants.ProcessStatusStopped, constants.ProcessStatusKilled, constants.ProcessStatusTimedOut:
+	default:
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid status '%s': must be completed, failed, stopped, killed or timedout", status))
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, RemovedProcessesResponse{Removed: h.RemoveProcesses(status)})
+}
+
 // HandleGetProcess handles GET requests to /process/:identifier
 // @Summary Get process by identifier
-// @Description Get information about a process by its PID or name
+// @Description Get information about a process by its PID or name, including the resolved command line, shell and environment it was executed with, secrets redacted
 // @Tags process
 // @Accept json
 // @Produce json
@@ -411,6 +905,32 @@ func (h *ProcessHandler) HandleGetProcess(c *gin.Context) {
 	h.SendJSON(c, http.StatusOK, processInfo)
 }
 
+// HandleGetProcessEnv handles GET requests to /process/:identifier/env
+// @Summary Get the environment of a process
+// @Description Get what a process was actually executed with: the resolved path of the shell running its command, its command line, and its final environment, the environment of the API with the variables of the request and of the user it runs as. The values of variables whose name looks secret (token, password, secret, key...) and the passwords of URLs are redacted. The environment of a process adopted or restored after an API restart is only known while it runs.
+// @Tags process
+// @Produce json
+// @Param identifier path string true "Process identifier (PID or name)"
+// @Success 200 {object} process.ProcessEnvironment "Process environment"
+// @Failure 404 {object} ErrorResponse "Process not found"
+// @Failure 409 {object} ErrorResponse "Process not started by the API has exited"
+// @Router /process/{identifier}/env [get]
+func (h *ProcessHandler) HandleGetProcessEnv(c *gin.Context) {
+	identifier, err := h.GetPathParam(c, "identifier")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	environment, err := h.GetProcessEnvironment(identifier)
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, environment)
+}
+
 // ResponseWriter is a custom writer for SSE responses that also flushes after each write
 type ResponseWriter struct {
 	gin    *gin.Context
@@ -0,0 +1,185 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// adoptedPollInterval is the interval between checks of adopted processes
+	adoptedPollInterval = time.Second
+	// clockTicksPerSecond is the unit of process start times in /proc, USER_HZ is 100
+	// on every Linux architecture
+	clockTicksPerSecond = 100
+	// startTimeTolerance is the difference allowed between the recorded start time of
+	// a process and the one of the OS process, to tell it apart from a reused PID
+	startTimeTolerance = 5 * time.Second
+)
+
+// isSameProcess reports whether the OS process pid is running and is the process
+// started at startedAt, not another one that reused its PID
+func isSameProcess(pid int, startedAt time.Time) bool {
+	if pid <= 0 {
+		return false
+	}
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+
+	startTime, zombie, err := processStartTime(pid)
+	if err != nil {
+		// Start times are only available on Linux, trust the PID elsewhere
+		return true
+	}
+	if zombie {
+		return false
+	}
+	diff := startTime.Sub(startedAt)
+	return diff > -startTimeTolerance && diff < startTimeTolerance
+}
+
+// procStat is the part of the stat of an OS process, read from /proc, used to manage it
+type procStat struct {
+	name      string
+	state     string
+	ppid      int
+	pgid      int
+	startTime time.Time
+}
+
+// readProcStat returns the stat of the OS process pid, read from /proc
+func readProcStat(pid int) (procStat, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+	// The command name may contain spaces, the fields start after its closing parenthesis
+	start := strings.IndexByte(string(stat), '(')
+	end := strings.LastIndexByte(string(stat), ')')
+	if start < 0 || end < start {
+		return procStat{}, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return procStat{}, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	result := procStat{name: string(stat[start+1 : end]), state: fields[0]}
+	if result.ppid, err = strconv.Atoi(fields[1]); err != nil {
+		return procStat{}, fmt.Errorf("invalid parent of process %d: %w", pid, err)
+	}
+	if result.pgid, err = strconv.Atoi(fields[2]); err != nil {
+		return procStat{}, fmt.Errorf("invalid process group of process %d: %w", pid, err)
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return procStat{}, fmt.Errorf("invalid start time of process %d: %w", pid, err)
+	}
+
+	bootTime, err := systemBootTime()
+	if err != nil {
+		return procStat{}, err
+	}
+	result.startTime = bootTime.Add(time.Duration(ticks) * time.Second / clockTicksPerSecond)
+	return result, nil
+}
+
+// processStartTime returns when the OS process pid started, read from /proc, and
+// whether it is a zombie
+func processStartTime(pid int) (time.Time, bool, error) {
+	stat, err := readProcStat(pid)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return stat.startTime, stat.state == "Z", nil
+}
+
+// systemBootTime returns when the system booted, read from /proc/stat
+func systemBootTime() (time.Time, error) {
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if value, found := strings.CutPrefix(line, "btime "); found {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid boot time: %w", err)
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("boot time not found")
+}
+
+// adoptedExited reports whether an adopted OS process has exited, and its exit code
+// when known. Orphans are reparented to init, so the exit code is only known when
+// the API itself is init, e.g. in a container, and reaps the process.
+func adoptedExited(pid int) (exited bool, exitCode int, known bool) {
+	var status syscall.WaitStatus
+	waited, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+	if err == nil {
+		if waited == pid {
+			return true, status.ExitStatus(), true
+		}
+		return false, 0, false
+	}
+
+	// Not a child of the API
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return true, 0, false
+	}
+	if _, zombie, err := processStartTime(pid); err == nil && zombie {
+		return true, 0, false
+	}
+	return false, 0, false
+}
+
+// watchAdopted waits for a process adopted after an API restart to exit, and then
+// completes it like the processes started by the API
+func (pm *ProcessManager) watchAdopted(process *ProcessInfo) {
+	ticker := time.NewTicker(adoptedPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		exited, exitCode, known := adoptedExited(process.ProcessPid)
+		if !exited {
+			continue
+		}
+
+		now := time.Now()
+		process.stateMu.Lock()
+		process.CompletedAt = &now
+		unknown := false
+		if process.Status == StatusRunning {
+			switch {
+			case !known:
+				process.Status = StatusCompleted
+				process.ExitCode = -1
+				unknown = true
+			case exitCode == 0:
+				process.Status = StatusCompleted
+				process.ExitCode = 0
+			default:
+				process.Status = StatusFailed
+				process.ExitCode = exitCode
+			}
+		}
+		process.stateMu.Unlock()
+		if unknown {
+			process.logs.WriteString("\n[Process exited, its exit code is unknown]\n")
+		}
+
+		process.logLock.Lock()
+		process.logWriters = nil
+		process.logLock.Unlock()
+
+		process.markDone()
+		pm.persist()
+		return
+	}
+}
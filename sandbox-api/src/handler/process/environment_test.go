@@ -0,0 +1,68 @@
+package process
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestRedactEnv tests that the values of secret variables and the passwords of URLs
+// are redacted
+func TestRedactEnv(t *testing.T) {
+	env := RedactEnv([]string{
+		"PATH=/usr/bin:/bin",
+		"GITHUB_TOKEN=ghp_123",
+		"db_password=hunter2",
+		"OPENAI_API_KEY=sk-123",
+		"DATABASE_URL=postgres://app:hunter2@db:5432/app?sslmode=disable",
+		"REDIS_URL=redis://cache:6379",
+		"EMPTY_SECRET=",
+		"EQUALS=a=b",
+		"=ignored",
+	})
+	expected := map[string]string{
+		"PATH":           "/usr/bin:/bin",
+		"GITHUB_TOKEN":   redacted,
+		"db_password":    redacted,
+		"OPENAI_API_KEY": redacted,
+		"DATABASE_URL":   "postgres://app:[REDACTED]@db:5432/app?sslmode=disable",
+		"REDIS_URL":      "redis://cache:6379",
+		"EMPTY_SECRET":   "",
+		"EQUALS":         "a=b",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %v, got %v", expected, env)
+	}
+}
+
+// TestProcessEnvironment tests the environment of a process started by the API
+func TestProcessEnvironment(t *testing.T) {
+	t.Setenv("SHELL", "")
+	t.Setenv("SHELL_ARGS", "")
+	pm := GetProcessManager()
+
+	pid, err := pm.StartProcess("true", "", map[string]string{"APP_ENV": "test", "APP_SECRET": "s3cr3t"}, false, 0, func(process *ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(pid)
+
+	environment, err := process.Environment()
+	if err != nil {
+		t.Fatalf("Failed to get environment: %v", err)
+	}
+	if environment.Shell == "" || !reflect.DeepEqual(environment.CommandLine, []string{"sh", "-c", "true"}) {
+		t.Errorf("Unexpected command line %q %q", environment.Shell, environment.CommandLine)
+	}
+	if environment.Env["APP_ENV"] != "test" || environment.Env["APP_SECRET"] != redacted || environment.Env["PATH"] == "" {
+		t.Errorf("Unexpected environment %v", environment.Env)
+	}
+	if !reflect.DeepEqual(environment.Custom, []string{"APP_ENV", "APP_SECRET"}) {
+		t.Errorf("Unexpected custom variables %v", environment.Custom)
+	}
+
+	adopted := &ProcessInfo{Status: StatusCompleted, ProcessPid: 1, Adopted: true}
+	if _, err := adopted.Environment(); !errors.Is(err, ErrEnvironmentUnavailable) {
+		t.Errorf("Expected the environment of an exited adopted process to be unavailable, got %v", err)
+	}
+}
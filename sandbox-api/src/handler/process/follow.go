@@ -0,0 +1,174 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// followPollInterval is the interval at which followed output is checked when no write
+// notification arrived, which catches output written without notifying log writers
+const followPollInterval = time.Second
+
+// OutputChunk is a portion of the output of a followed process, written to a single
+// stream. Offsets are absolute: they count every byte written to the stream since the
+// process started.
+type OutputChunk struct {
+	Logs string
+	// Stream is stdout or stderr, empty for the messages of the API, see LogFrame
+	Stream string
+	// At is when the output was written, zero for the last chunk without output
+	At time.Time
+	// Offset is the offset of the first byte of Logs
+	Offset int64
+	// NextOffset is the offset following Logs, to resume following from
+	NextOffset int64
+	// Missed is the number of bytes dropped from the buffer before they could be sent
+	Missed int64
+	// Done is set on the last chunk, sent once the process has terminated
+	Done bool
+}
+
+// outputNotifier is a log writer signaling that output was written
+type outputNotifier chan struct{}
+
+// Write signals the write without blocking the process output
+func (n outputNotifier) Write(p []byte) (int, error) {
+	select {
+	case n <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// FollowStart returns the absolute offset to follow a stream of the output of a process
+// from, given as the fromOffset or tail parameter of a request, or -1 for the oldest
+// buffered output without either. Invalid, out of range and conflicting parameters fail
+// with INVALID_REQUEST.
+func (pm *ProcessManager) FollowStart(identifier string, stream string, fromOffset string, tail string) (int64, error) {
+	if stream != "" && stream != "stdout" && stream != "stderr" {
+		return 0, apierror.New(apierror.CodeInvalidRequest, "invalid stream: must be 'stdout' or 'stderr'")
+	}
+	if fromOffset != "" && tail != "" {
+		return 0, apierror.New(apierror.CodeInvalidRequest, "fromOffset and tail cannot be used together")
+	}
+
+	query := LogQuery{Stream: stream}
+	if tail != "" {
+		lines, err := strconv.Atoi(tail)
+		if err != nil || lines <= 0 {
+			return 0, apierror.New(apierror.CodeInvalidRequest, "invalid tail: must be a positive number of lines")
+		}
+		query.Tail = lines
+	}
+	logs, err := pm.QueryProcessOutput(identifier, query)
+	if err != nil {
+		return 0, err
+	}
+	if tail != "" {
+		return logs.Offset, nil
+	}
+	if fromOffset == "" {
+		return -1, nil
+	}
+
+	from, err := strconv.ParseInt(fromOffset, 10, 64)
+	if err != nil || from < 0 {
+		return 0, apierror.New(apierror.CodeInvalidRequest, "invalid fromOffset: must be a positive number of bytes")
+	}
+	if from > logs.NextOffset {
+		return 0, apierror.Newf(apierror.CodeInvalidRequest, "invalid fromOffset: only %d bytes were written", logs.NextOffset)
+	}
+	return from, nil
+}
+
+// FollowProcessOutput calls send with the output of a stream of a process as it is
+// written, starting at the absolute offset from, or at the oldest buffered byte when
+// from is negative. The output is sent in chunks of a single stream, split where it
+// changes, see LogBuffer.QueryFrames. The last chunk is marked done once the process has
+// terminated. The returned function stops following the output.
+func (pm *ProcessManager) FollowProcessOutput(identifier string, stream string, from int64, send func(OutputChunk)) (func(), error) {
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return nil, apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+
+	var buffer *LogBuffer
+	switch stream {
+	case "":
+		buffer = process.logs
+	case "stdout":
+		buffer = process.stdout
+	case "stderr":
+		buffer = process.stderr
+	default:
+		return nil, fmt.Errorf("invalid stream '%s', expected 'stdout' or 'stderr'", stream)
+	}
+	if from < 0 {
+		from = buffer.DroppedBytes()
+	}
+
+	notifier := make(outputNotifier, 1)
+	process.logLock.Lock()
+	process.logWriters = append(process.logWriters, notifier)
+	process.logLock.Unlock()
+
+	stop := make(chan struct{})
+	go func() {
+		defer func() { _ = pm.RemoveLogWriter(process.PID, notifier) }()
+
+		ticker := time.NewTicker(followPollInterval)
+		defer ticker.Stop()
+
+		next := from
+		for {
+			// Read the remaining output after the process terminated, so that none is lost
+			done := isTerminated(process)
+
+			frames, offset, nextOffset := buffer.QueryFrames(LogQuery{Offset: next})
+			missed := max(offset-next, 0)
+			for i, frame := range frames {
+				chunk := OutputChunk{
+					Logs:       frame.Data,
+					Stream:     frame.Stream,
+					At:         frame.At,
+					Offset:     frame.Offset,
+					NextOffset: frame.NextOffset,
+					Done:       done && i == len(frames)-1,
+				}
+				if stream != "" {
+					chunk.Stream = stream
+				}
+				if i == 0 {
+					chunk.Missed = missed
+				}
+				send(chunk)
+			}
+			if len(frames) == 0 && done {
+				send(OutputChunk{Offset: offset, NextOffset: nextOffset, Missed: missed, Done: true})
+			}
+			if len(frames) > 0 || done {
+				next = nextOffset
+			}
+			if done {
+				return
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-notifier:
+			case <-ticker.C:
+			case <-process.Done():
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+	}, nil
+}
@@ -0,0 +1,220 @@
+package process
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// safeBuffer is a buffer written by the output goroutines of a process and read by tests
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// followAll follows the output of a process from an offset until it terminates
+func followAll(t *testing.T, pm *ProcessManager, pid string, from int64) []OutputChunk {
+	t.Helper()
+	chunks := make(chan OutputChunk, 100)
+	stop, err := pm.FollowProcessOutput(pid, "", from, func(chunk OutputChunk) { chunks <- chunk })
+	if err != nil {
+		t.Fatalf("Failed to follow output: %v", err)
+	}
+	defer stop()
+
+	received := make([]OutputChunk, 0)
+	for {
+		select {
+		case chunk := <-chunks:
+			received = append(received, chunk)
+			if chunk.Done {
+				return received
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out following output, received %+v", received)
+		}
+	}
+}
+
+// TestFollowProcessOutput tests that followed output is sent in order and can be
+// resumed from an offset
+func TestFollowProcessOutput(t *testing.T) {
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcessWithName("for i in 1 2 3; do echo line-$i; sleep 0.2; done; echo failed >&2", "", "follow", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+
+	chunks := followAll(t, pm, pid, -1)
+	var output strings.Builder
+	next := int64(0)
+	for _, chunk := range chunks {
+		if chunk.Offset != next || chunk.Missed != 0 {
+			t.Errorf("Expected contiguous chunks, got chunk at %d after %d (missed %d)", chunk.Offset, next, chunk.Missed)
+		}
+		// Chunks are written to a single stream
+		if expected := map[bool]string{true: "stderr", false: "stdout"}[strings.Contains(chunk.Logs, "failed")]; chunk.Logs != "" && (chunk.Stream != expected || chunk.At.IsZero()) {
+			t.Errorf("Expected a chunk of %s, got %+v", expected, chunk)
+		}
+		output.WriteString(chunk.Logs)
+		next = chunk.NextOffset
+	}
+	if output.String() != "line-1\nline-2\nline-3\nfailed\n" {
+		t.Errorf("Unexpected followed output: %q", output.String())
+	}
+
+	// Resuming after the first line only sends the rest of the output
+	resumed := followAll(t, pm, pid, int64(len("line-1\n")))
+	output.Reset()
+	for _, chunk := range resumed {
+		output.WriteString(chunk.Logs)
+	}
+	if output.String() != "line-2\nline-3\nfailed\n" || resumed[len(resumed)-1].NextOffset != next {
+		t.Errorf("Unexpected resumed output: %q", output.String())
+	}
+
+	if _, err := pm.FollowProcessOutput(pid, "invalid", -1, func(OutputChunk) {}); err == nil {
+		t.Error("Expected an error for an invalid stream")
+	}
+}
+
+// chunksOutput concatenates the output of chunks, checking that they are contiguous from
+// the offset from
+func chunksOutput(t *testing.T, chunks []OutputChunk, from int64) string {
+	t.Helper()
+	var output strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Offset != from || chunk.Missed != 0 {
+			t.Errorf("Expected a chunk at %d, got one at %d (missed %d)", from, chunk.Offset, chunk.Missed)
+		}
+		output.WriteString(chunk.Logs)
+		from = chunk.NextOffset
+	}
+	return output.String()
+}
+
+// TestFollowStart tests resolving the offset a followed stream starts at from the
+// fromOffset and tail parameters, and that a stream resumed from the last offset
+// received neither repeats nor skips output
+func TestFollowStart(t *testing.T) {
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcessWithName("echo line-1; sleep 0.3; echo line-2; sleep 0.3; echo line-3", "", "resume", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+
+	// Stop following after the first chunk, then resume from its next offset
+	first := make(chan OutputChunk, 100)
+	stop, err := pm.FollowProcessOutput(pid, "", -1, func(chunk OutputChunk) { first <- chunk })
+	if err != nil {
+		t.Fatalf("Failed to follow output: %v", err)
+	}
+	var received OutputChunk
+	select {
+	case received = <-first:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the first chunk")
+	}
+	stop()
+	from, err := pm.FollowStart(pid, "", strconv.FormatInt(received.NextOffset, 10), "")
+	if err != nil || from != received.NextOffset {
+		t.Fatalf("Expected to resume from %d, got %d (%v)", received.NextOffset, from, err)
+	}
+	resumed := chunksOutput(t, followAll(t, pm, pid, from), from)
+	if output := received.Logs + resumed; output != "line-1\nline-2\nline-3\n" {
+		t.Errorf("Expected the resumed stream to continue the first chunk, got %q then %q", received.Logs, resumed)
+	}
+
+	// Without parameters the stream starts at the oldest buffered output
+	if from, err := pm.FollowStart(pid, "", "", ""); err != nil || from != -1 {
+		t.Errorf("Expected -1 without parameters, got %d (%v)", from, err)
+	}
+	from, err = pm.FollowStart(pid, "stdout", "", "1")
+	if err != nil || from != int64(len("line-1\nline-2\n")) {
+		t.Errorf("Expected the tail to start at the last line, got %d (%v)", from, err)
+	}
+	if output := chunksOutput(t, followAll(t, pm, pid, from), from); output != "line-3\n" {
+		t.Errorf("Expected the last line from the tail, got %q", output)
+	}
+	// Resuming at the end of the output only sends the done chunk
+	end := strconv.Itoa(len("line-1\nline-2\nline-3\n"))
+	if from, err = pm.FollowStart(pid, "", end, ""); err != nil {
+		t.Fatalf("Expected to resume at the end of the output, got %v", err)
+	}
+	if output := chunksOutput(t, followAll(t, pm, pid, from), from); output != "" {
+		t.Errorf("Expected no output after the end, got %q", output)
+	}
+
+	for name, params := range map[string][3]string{
+		"negative offset":     {"", "-1", ""},
+		"invalid offset":      {"", "abc", ""},
+		"offset past the end": {"", "1000", ""},
+		"offset and tail":     {"", "0", "1"},
+		"zero tail":           {"", "", "0"},
+		"invalid tail":        {"", "", "last"},
+		"invalid stream":      {"both", "", ""},
+		"stream past the end": {"stderr", "1", ""},
+	} {
+		_, err := pm.FollowStart(pid, params[0], params[1], params[2])
+		if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeInvalidRequest {
+			t.Errorf("%s: expected INVALID_REQUEST, got %v", name, err)
+		}
+	}
+	_, err = pm.FollowStart("missing", "", "", "")
+	if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeProcNotFound {
+		t.Errorf("Expected PROC_NOT_FOUND for an unknown process, got %v", err)
+	}
+}
+
+// TestStreamProcessOutput tests that a streamed writer receives the output and is
+// detached once closed, the process being done signaled without polling its status
+func TestStreamProcessOutput(t *testing.T) {
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcessWithName("sleep 0.2; echo streamed", "", "stream", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(pid)
+
+	var output safeBuffer
+	stream, err := pm.StreamProcessOutput(pid, &output, OverflowDropOldest)
+	if err != nil {
+		t.Fatalf("Failed to stream output: %v", err)
+	}
+	if !process.hasLogWriter(stream) {
+		t.Error("Expected the stream to be attached")
+	}
+
+	select {
+	case <-process.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the process to be done")
+	}
+	stream.Close()
+	if !strings.Contains(output.String(), "streamed") {
+		t.Errorf("Expected the streamed output, got %q", output.String())
+	}
+	if process.hasLogWriter(stream) {
+		t.Error("Expected the stream to be detached")
+	}
+}
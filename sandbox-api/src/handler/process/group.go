@@ -0,0 +1,368 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Process group statuses
+const (
+	GroupStatusStarting = "starting"
+	GroupStatusRunning  = "running"
+	GroupStatusFailed   = "failed"
+	GroupStatusStopped  = "stopped"
+)
+
+// Process group member statuses
+const (
+	MemberStatusPending  = "pending"
+	MemberStatusStarting = "starting"
+	MemberStatusReady    = "ready"
+	MemberStatusFailed   = "failed"
+	MemberStatusSkipped  = "skipped"
+)
+
+// GroupProcessSpec describes a process of a group
+type GroupProcessSpec struct {
+	Name             string              `json:"name" example:"db" binding:"required"`
+	Command          string              `json:"command" example:"postgres -D /var/lib/postgresql/data" binding:"required"`
+	WorkingDir       string              `json:"workingDir" example:"/home/user"`
+	Env              map[string]string   `json:"env" example:"{\"PGPORT\": \"5432\"}"`
+	RunAsUser        string              `json:"runAsUser" example:"postgres"`
+	RunAsGroup       string              `json:"runAsGroup" example:"postgres"`
+	RestartOnFailure bool                `json:"restartOnFailure" example:"false"`
+	MaxRestarts      int                 `json:"maxRestarts" example:"0"`
+	RestartPolicy    string              `json:"restartPolicy" example:"on-failure" enums:"never,on-failure,always"`
+	Backoff          *BackoffConfig      `json:"backoff"`
+	RestartWindow    int                 `json:"restartWindow" example:"300"`
+	LogToFile        bool                `json:"logToFile" example:"false"`
+	Record           bool                `json:"record" example:"false"`
+	DependsOn        []string            `json:"dependsOn" example:"db"`
+	ReadyWhen        *ReadinessCondition `json:"readyWhen"`
+} // @name GroupProcessSpec
+
+// GroupMemberInfo is the state of a process of a group
+type GroupMemberInfo struct {
+	Name          string `json:"name" example:"db" binding:"required"`
+	ProcessName   string `json:"processName" example:"stack-db" binding:"required"`
+	PID           string `json:"pid" example:"1234"`
+	Status        string `json:"status" example:"ready" enums:"pending,starting,ready,failed,skipped" binding:"required"`
+	ProcessStatus string `json:"processStatus,omitempty" example:"running"`
+	Error         string `json:"error,omitempty" example:"process db-1234 exited before becoming ready"`
+} // @name ProcessGroupMember
+
+// GroupInfo is the state of a process group
+type GroupInfo struct {
+	Name      string            `json:"name" example:"stack" binding:"required"`
+	Status    string            `json:"status" example:"running" enums:"starting,running,failed,stopped" binding:"required"`
+	CreatedAt time.Time         `json:"createdAt" binding:"required"`
+	Processes []GroupMemberInfo `json:"processes" binding:"required"`
+} // @name ProcessGroup
+
+// groupMember tracks a process of a group during and after startup
+type groupMember struct {
+	spec    GroupProcessSpec
+	pid     string
+	status  string
+	err     string
+	settled chan struct{}
+}
+
+// ProcessGroup is a set of processes started together, in order and according to their dependencies
+type ProcessGroup struct {
+	manager   *ProcessManager
+	name      string
+	createdAt time.Time
+	members   []*groupMember
+	stopped   bool
+	cancel    context.CancelFunc
+	settled   chan struct{}
+	mu        sync.RWMutex
+}
+
+// Settled returns a channel that is closed once every process of the group is ready or has failed
+func (g *ProcessGroup) Settled() <-chan struct{} {
+	return g.settled
+}
+
+// groupProcessName returns the name of the process of a group member
+func groupProcessName(group string, member string) string {
+	return group + "-" + member
+}
+
+// restartConfig returns the restart configuration of a group process
+func (spec GroupProcessSpec) restartConfig() (RestartConfig, error) {
+	return NewRestartConfig(spec.RestartPolicy, spec.RestartOnFailure, spec.MaxRestarts, spec.Backoff, spec.RestartWindow)
+}
+
+// validateGroup checks a group definition before starting it
+func validateGroup(name string, specs []GroupProcessSpec) error {
+	if name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	if len(specs) == 0 {
+		return fmt.Errorf("group %s has no processes", name)
+	}
+
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return fmt.Errorf("every process of group %s must have a name", name)
+		}
+		if spec.Command == "" {
+			return fmt.Errorf("process %s has no command", spec.Name)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("process name %s is used more than once", spec.Name)
+		}
+		// Dependencies must be declared earlier in the list, which also rules out cycles
+		for _, dependency := range spec.DependsOn {
+			if !seen[dependency] {
+				return fmt.Errorf("process %s depends on %s, which must be declared before it", spec.Name, dependency)
+			}
+		}
+		if _, err := spec.restartConfig(); err != nil {
+			return fmt.Errorf("process %s: %w", spec.Name, err)
+		}
+		if spec.ReadyWhen != nil {
+			if err := spec.ReadyWhen.Validate(); err != nil {
+				return fmt.Errorf("process %s: %w", spec.Name, err)
+			}
+		}
+		seen[spec.Name] = true
+	}
+	return nil
+}
+
+// StartGroup starts the processes of a group. Each process is started once the
+// processes it depends on are ready; a process whose dependency failed is skipped.
+// It returns as soon as the startup is scheduled, use Settled to wait for it.
+func (pm *ProcessManager) StartGroup(name string, specs []GroupProcessSpec) (*ProcessGroup, error) {
+	if err := validateGroup(name, specs); err != nil {
+		return nil, err
+	}
+	// Reject the whole group rather than failing its denied processes
+	for _, spec := range specs {
+		if err := policy.GetEngine().Check(spec.Command, spec.WorkingDir); err != nil {
+			return nil, fmt.Errorf("process %s: %w", spec.Name, err)
+		}
+	}
+
+	pm.groupsMu.Lock()
+	if existing, exists := pm.groups[name]; exists {
+		status := existing.Info().Status
+		if status == GroupStatusStarting || status == GroupStatusRunning {
+			pm.groupsMu.Unlock()
+			return nil, fmt.Errorf("group %s is already %s", name, status)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	group := &ProcessGroup{
+		manager:   pm,
+		name:      name,
+		createdAt: time.Now(),
+		members:   make([]*groupMember, 0, len(specs)),
+		cancel:    cancel,
+		settled:   make(chan struct{}),
+	}
+	membersByName := make(map[string]*groupMember, len(specs))
+	for _, spec := range specs {
+		member := &groupMember{
+			spec:    spec,
+			status:  MemberStatusPending,
+			settled: make(chan struct{}),
+		}
+		group.members = append(group.members, member)
+		membersByName[spec.Name] = member
+	}
+	pm.groups[name] = group
+	pm.groupsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, member := range group.members {
+		wg.Add(1)
+		go func(member *groupMember) {
+			defer wg.Done()
+			defer close(member.settled)
+			pm.startGroupMember(ctx, group, member, membersByName)
+		}(member)
+	}
+	go func() {
+		wg.Wait()
+		close(group.settled)
+	}()
+
+	return group, nil
+}
+
+// startGroupMember waits for the dependencies of a member, then starts it and waits until it is ready
+func (pm *ProcessManager) startGroupMember(ctx context.Context, group *ProcessGroup, member *groupMember, membersByName map[string]*groupMember) {
+	for _, dependencyName := range member.spec.DependsOn {
+		dependency := membersByName[dependencyName]
+		select {
+		case <-dependency.settled:
+		case <-ctx.Done():
+			group.setMemberStatus(member, MemberStatusSkipped, "group was stopped")
+			return
+		}
+		if group.memberStatus(dependency) != MemberStatusReady {
+			group.setMemberStatus(member, MemberStatusSkipped, fmt.Sprintf("dependency %s is not ready", dependencyName))
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		group.setMemberStatus(member, MemberStatusSkipped, "group was stopped")
+		return
+	}
+
+	spec := member.spec
+	group.setMemberStatus(member, MemberStatusStarting, "")
+	restart, _ := spec.restartConfig() // validated when the group was started
+	pid, err := pm.StartProcessWithRestart(spec.Command, spec.WorkingDir, groupProcessName(group.name, spec.Name), spec.Env, lib.RunAs{User: spec.RunAsUser, Group: spec.RunAsGroup}, 0, restart, spec.LogToFile, spec.Record, func(*ProcessInfo) {})
+	if err != nil {
+		group.setMemberStatus(member, MemberStatusFailed, err.Error())
+		return
+	}
+	group.mu.Lock()
+	member.pid = pid
+	stopped := group.stopped
+	group.mu.Unlock()
+
+	// The group was stopped while this process was starting
+	if stopped {
+		_ = pm.StopProcess(pid)
+		group.setMemberStatus(member, MemberStatusSkipped, "group was stopped")
+		return
+	}
+
+	if spec.ReadyWhen != nil && !spec.ReadyWhen.IsEmpty() {
+		if err := pm.WaitForReady(ctx, pid, *spec.ReadyWhen); err != nil {
+			group.setMemberStatus(member, MemberStatusFailed, err.Error())
+			return
+		}
+	}
+	group.setMemberStatus(member, MemberStatusReady, "")
+}
+
+// setMemberStatus updates the status of a member
+func (g *ProcessGroup) setMemberStatus(member *groupMember, status string, errMsg string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	member.status = status
+	member.err = errMsg
+}
+
+// memberStatus returns the status of a member
+func (g *ProcessGroup) memberStatus(member *groupMember) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return member.status
+}
+
+// Info returns the current state of the group
+func (g *ProcessGroup) Info() GroupInfo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	info := GroupInfo{
+		Name:      g.name,
+		Status:    GroupStatusRunning,
+		CreatedAt: g.createdAt,
+		Processes: make([]GroupMemberInfo, 0, len(g.members)),
+	}
+
+	for _, member := range g.members {
+		memberInfo := GroupMemberInfo{
+			Name:        member.spec.Name,
+			ProcessName: groupProcessName(g.name, member.spec.Name),
+			PID:         member.pid,
+			Status:      member.status,
+			Error:       member.err,
+		}
+		if member.pid != "" {
+			if process, exists := g.manager.GetProcessByIdentifier(member.pid); exists {
+				memberInfo.ProcessStatus = string(process.State().Status)
+			}
+		}
+		info.Processes = append(info.Processes, memberInfo)
+
+		switch member.status {
+		case MemberStatusFailed, MemberStatusSkipped:
+			if info.Status != GroupStatusStarting {
+				info.Status = GroupStatusFailed
+			}
+		case MemberStatusPending, MemberStatusStarting:
+			info.Status = GroupStatusStarting
+		}
+	}
+
+	if g.stopped {
+		info.Status = GroupStatusStopped
+	}
+	return info
+}
+
+// GetGroup returns a process group by name
+func (pm *ProcessManager) GetGroup(name string) (*ProcessGroup, bool) {
+	pm.groupsMu.RLock()
+	defer pm.groupsMu.RUnlock()
+	group, exists := pm.groups[name]
+	return group, exists
+}
+
+// ListGroups returns all process groups
+func (pm *ProcessManager) ListGroups() []*ProcessGroup {
+	pm.groupsMu.RLock()
+	defer pm.groupsMu.RUnlock()
+
+	groups := make([]*ProcessGroup, 0, len(pm.groups))
+	for _, group := range pm.groups {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// StopGroup cancels the startup of a group and gracefully stops its running processes,
+// in the reverse order of their declaration. When force is true, processes are killed instead.
+func (pm *ProcessManager) StopGroup(name string, force bool) error {
+	group, exists := pm.GetGroup(name)
+	if !exists {
+		return apierror.Newf(apierror.CodeProcGroupNotFound, "process group %s not found", name)
+	}
+
+	group.cancel()
+
+	group.mu.Lock()
+	group.stopped = true
+	pids := make([]string, 0, len(group.members))
+	for i := len(group.members) - 1; i >= 0; i-- {
+		if pid := group.members[i].pid; pid != "" {
+			pids = append(pids, pid)
+		}
+	}
+	group.mu.Unlock()
+
+	for _, pid := range pids {
+		process, exists := pm.GetProcessByIdentifier(pid)
+		if !exists || process.State().Status != StatusRunning {
+			continue
+		}
+		var err error
+		if force {
+			err = pm.KillProcess(pid)
+		} else {
+			err = pm.StopProcess(pid)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stop process %s of group %s: %w", pid, name, err)
+		}
+	}
+	return nil
+}
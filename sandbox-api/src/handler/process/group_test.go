@@ -0,0 +1,76 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+// TestProcessGroup tests dependency ordering and readiness of process groups
+func TestProcessGroup(t *testing.T) {
+	pm := GetProcessManager()
+
+	t.Run("StartsDependenciesFirst", func(t *testing.T) {
+		group, err := pm.StartGroup("test-group-order", []GroupProcessSpec{
+			{Name: "db", Command: "sleep 0.2; echo database ready; sleep 5", ReadyWhen: &ReadinessCondition{LogPattern: "database ready", Timeout: 5}},
+			{Name: "app", Command: "echo started; sleep 5", DependsOn: []string{"db"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to start group: %v", err)
+		}
+		defer func() { _ = pm.StopGroup("test-group-order", true) }()
+
+		select {
+		case <-group.Settled():
+		case <-time.After(10 * time.Second):
+			t.Fatal("Group did not settle in time")
+		}
+
+		info := group.Info()
+		if info.Status != GroupStatusRunning {
+			t.Fatalf("Expected group to be running, got %s (%+v)", info.Status, info.Processes)
+		}
+
+		db, _ := pm.GetProcessByIdentifier(info.Processes[0].PID)
+		app, _ := pm.GetProcessByIdentifier(info.Processes[1].PID)
+		if !app.StartedAt.After(db.StartedAt.Add(200 * time.Millisecond)) {
+			t.Errorf("Expected app to start after db was ready")
+		}
+	})
+
+	t.Run("SkipsWhenDependencyFails", func(t *testing.T) {
+		group, err := pm.StartGroup("test-group-failure", []GroupProcessSpec{
+			{Name: "db", Command: "exit 1", ReadyWhen: &ReadinessCondition{LogPattern: "ready", Timeout: 5}},
+			{Name: "app", Command: "echo started", DependsOn: []string{"db"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to start group: %v", err)
+		}
+
+		select {
+		case <-group.Settled():
+		case <-time.After(10 * time.Second):
+			t.Fatal("Group did not settle in time")
+		}
+
+		info := group.Info()
+		if info.Status != GroupStatusFailed {
+			t.Errorf("Expected group to have failed, got %s", info.Status)
+		}
+		if info.Processes[0].Status != MemberStatusFailed {
+			t.Errorf("Expected db to have failed, got %s", info.Processes[0].Status)
+		}
+		if info.Processes[1].Status != MemberStatusSkipped || info.Processes[1].PID != "" {
+			t.Errorf("Expected app to be skipped, got %s", info.Processes[1].Status)
+		}
+	})
+
+	t.Run("InvalidDefinition", func(t *testing.T) {
+		_, err := pm.StartGroup("test-group-invalid", []GroupProcessSpec{
+			{Name: "app", Command: "true", DependsOn: []string{"db"}},
+			{Name: "db", Command: "true"},
+		})
+		if err == nil {
+			t.Error("Expected error for a dependency declared later, but got none")
+		}
+	})
+}
@@ -0,0 +1,432 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxLogBytes is the default size cap of each process output buffer
+const DefaultMaxLogBytes = 10 * 1024 * 1024
+
+// logChunkInterval is the resolution of the write timestamps kept by a LogBuffer.
+// Writes closer than this to the previous timestamp share it, which bounds the number
+// of timestamps kept for processes writing many small chunks.
+const logChunkInterval = time.Millisecond
+
+// LogQuery selects a portion of the process output.
+// Offsets are absolute: they count every byte written since the process started,
+// including the ones dropped from the buffer.
+type LogQuery struct {
+	// Stream restricts the output to "stdout" or "stderr", empty for all streams
+	Stream string
+	// Tail only returns the last Tail lines, 0 for all lines
+	Tail int
+	// Since only returns output written at or after this time (zero for all)
+	Since time.Time
+	// Offset is the absolute byte offset to start reading from
+	Offset int64
+	// Limit is the maximum number of bytes to return, 0 for no limit
+	Limit int64
+	// Timestamps also returns the output as timestamped entries, see ProcessLogs.Entries.
+	// It is ignored by LogBuffer.
+	Timestamps bool
+}
+
+// logChunk records when the byte at offset was written
+type logChunk struct {
+	offset int64
+	at     time.Time
+}
+
+// logSegment records the stream of the bytes written from offset, empty for the
+// bytes written without one
+type logSegment struct {
+	offset int64
+	stream string
+}
+
+// LogFrame is a portion of the output of a single stream, written at the same time to
+// the resolution of the buffer. Offsets are absolute, like the ones of LogQuery.
+type LogFrame struct {
+	// Stream is the stream the output was written to, empty for the messages of the API
+	// and the output restored after an API restart
+	Stream     string
+	Data       string
+	At         time.Time
+	Offset     int64
+	NextOffset int64
+}
+
+// LogBuffer is a size-capped ring buffer holding process output.
+// Once the cap is reached the oldest bytes are dropped, and optionally
+// appended to a spill file on disk so they are not lost.
+type LogBuffer struct {
+	mu       sync.Mutex
+	data     []byte
+	start    int
+	maxBytes int
+	dropped  int64
+	chunks   []logChunk
+	segments []logSegment
+	// epoch is when the buffer was created, write timestamps are measured from it on
+	// the monotonic clock
+	epoch     time.Time
+	spillPath string
+	spillFile *os.File
+}
+
+// NewLogBuffer creates a log buffer holding at most maxBytes bytes (unbounded if maxBytes <= 0).
+// When spillPath is not empty, dropped bytes are appended to that file.
+func NewLogBuffer(maxBytes int, spillPath string) *LogBuffer {
+	return &LogBuffer{
+		maxBytes:  maxBytes,
+		spillPath: spillPath,
+		epoch:     time.Now(),
+	}
+}
+
+// Write appends p to the buffer, dropping the oldest bytes when the cap is exceeded
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	return b.WriteStream("", p)
+}
+
+// WriteStream appends p written to a stream to the buffer, like Write, recording the
+// stream of the bytes for QueryFrames
+func (b *LogBuffer) WriteStream(stream string, p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(p) > 0 {
+		b.recordSegment(stream)
+	}
+	return b.write(p)
+}
+
+// write appends p to the buffer, b.mu is held
+func (b *LogBuffer) write(p []byte) (int, error) {
+	n := len(p)
+	if n == 0 {
+		return 0, nil
+	}
+	b.recordChunk(b.now())
+	defer b.pruneChunks()
+
+	if b.maxBytes <= 0 {
+		b.data = append(b.data, p...)
+		return n, nil
+	}
+
+	// The new data alone fills the buffer: drop everything before its tail
+	if len(p) >= b.maxBytes {
+		b.evict(len(b.data))
+		b.evictBytes(p[:len(p)-b.maxBytes])
+		b.data = append(make([]byte, 0, b.maxBytes), p[len(p)-b.maxBytes:]...)
+		b.start = 0
+		return n, nil
+	}
+
+	// Grow the buffer until it reaches the cap
+	if room := b.maxBytes - len(b.data); room > 0 {
+		if len(p) <= room {
+			b.data = append(b.data, p...)
+			return n, nil
+		}
+		b.data = append(b.data, p[:room]...)
+		p = p[room:]
+	}
+
+	// The buffer is full: overwrite the oldest bytes
+	b.evict(len(p))
+	for len(p) > 0 {
+		copied := copy(b.data[b.start:], p)
+		p = p[copied:]
+		b.start = (b.start + copied) % b.maxBytes
+	}
+	return n, nil
+}
+
+// now returns the current time measured from the creation of the buffer on the
+// monotonic clock, so that the timestamps of writes never go backwards when the wall
+// clock is adjusted
+func (b *LogBuffer) now() time.Time {
+	return b.epoch.Add(time.Since(b.epoch)).Round(0)
+}
+
+// recordChunk records the time of a write starting at the current end of the buffer
+func (b *LogBuffer) recordChunk(now time.Time) {
+	if len(b.chunks) > 0 && now.Sub(b.chunks[len(b.chunks)-1].at) < logChunkInterval {
+		return
+	}
+	b.chunks = append(b.chunks, logChunk{offset: b.dropped + int64(len(b.data)), at: now})
+}
+
+// recordSegment records the stream of a write starting at the current end of the buffer
+func (b *LogBuffer) recordSegment(stream string) {
+	if len(b.segments) > 0 && b.segments[len(b.segments)-1].stream == stream {
+		return
+	}
+	b.segments = append(b.segments, logSegment{offset: b.dropped + int64(len(b.data)), stream: stream})
+}
+
+// pruneChunks forgets the timestamps and streams of chunks entirely dropped from the
+// buffer
+func (b *LogBuffer) pruneChunks() {
+	i := 0
+	for i+1 < len(b.chunks) && b.chunks[i+1].offset <= b.dropped {
+		i++
+	}
+	if i > 0 {
+		b.chunks = append(b.chunks[:0], b.chunks[i:]...)
+	}
+	i = 0
+	for i+1 < len(b.segments) && b.segments[i+1].offset <= b.dropped {
+		i++
+	}
+	if i > 0 {
+		b.segments = append(b.segments[:0], b.segments[i:]...)
+	}
+}
+
+// WriteString appends s to the buffer
+func (b *LogBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// String returns the buffered content, oldest bytes first
+func (b *LogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return string(b.ordered())
+}
+
+// ordered returns the buffered bytes, oldest first
+func (b *LogBuffer) ordered() []byte {
+	if b.start == 0 {
+		return b.data
+	}
+	content := make([]byte, 0, len(b.data))
+	content = append(content, b.data[b.start:]...)
+	return append(content, b.data[:b.start]...)
+}
+
+// Query returns the buffered content selected by query, along with the absolute
+// offsets of its first byte and of the byte following it. The Stream field of
+// the query is ignored.
+func (b *LogBuffer) Query(query LogQuery) (string, int64, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	content, from, to := b.query(query)
+	return string(content), from, to
+}
+
+// QueryFrames returns the buffered content selected by query like Query, split into
+// frames of the bytes of a stream written at the same time, along with the absolute
+// offsets of the content
+func (b *LogBuffer) QueryFrames(query LogQuery) ([]LogFrame, int64, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	content, from, to := b.query(query)
+	frames := []LogFrame{}
+	chunk, segment := 0, 0
+	for offset := from; offset < to; {
+		// The chunk and segment of the byte at offset, and the offset where either ends
+		for chunk+1 < len(b.chunks) && b.chunks[chunk+1].offset <= offset {
+			chunk++
+		}
+		for segment+1 < len(b.segments) && b.segments[segment+1].offset <= offset {
+			segment++
+		}
+		frame := LogFrame{Offset: offset, NextOffset: to}
+		if chunk < len(b.chunks) {
+			frame.At = b.chunks[chunk].at
+			if chunk+1 < len(b.chunks) {
+				frame.NextOffset = min(frame.NextOffset, b.chunks[chunk+1].offset)
+			}
+		}
+		if segment < len(b.segments) {
+			frame.Stream = b.segments[segment].stream
+			if segment+1 < len(b.segments) {
+				frame.NextOffset = min(frame.NextOffset, b.segments[segment+1].offset)
+			}
+		}
+		frame.Data = string(content[frame.Offset-from : frame.NextOffset-from])
+		frames = append(frames, frame)
+		offset = frame.NextOffset
+	}
+	return frames, from, to
+}
+
+// query returns the buffered content selected by query and its absolute offsets, b.mu
+// is held
+func (b *LogBuffer) query(query LogQuery) ([]byte, int64, int64) {
+	content := b.ordered()
+	base := b.dropped
+	from, to := base, base+int64(len(content))
+
+	if !query.Since.IsZero() {
+		// A chunk covers writes up to logChunkInterval after its timestamp
+		from = to
+		for _, chunk := range b.chunks {
+			if chunk.at.Add(logChunkInterval).After(query.Since) {
+				from = max(chunk.offset, base)
+				break
+			}
+		}
+	}
+
+	if query.Offset > from {
+		from = min(query.Offset, to)
+	}
+
+	if query.Tail > 0 {
+		from += int64(tailOffset(content[from-base:to-base], query.Tail))
+	}
+
+	if query.Limit > 0 && to-from > query.Limit {
+		to = from + query.Limit
+	}
+
+	return content[from-base : to-base], from, to
+}
+
+// tailOffset returns the index in content where its last n lines start
+func tailOffset(content []byte, n int) int {
+	end := len(content)
+	// A trailing newline terminates the last line, it does not start a new one
+	if end > 0 && content[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if content[i] == '\n' {
+			n--
+			if n == 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// Len returns the number of buffered bytes
+func (b *LogBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.data)
+}
+
+// DroppedBytes returns the number of bytes dropped from the buffer since it was created
+func (b *LogBuffer) DroppedBytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// SpillPath returns the file holding the dropped bytes, or an empty string if nothing was spilled
+func (b *LogBuffer) SpillPath() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dropped == 0 {
+		return ""
+	}
+	return b.spillPath
+}
+
+// Close closes the spill file if one is open. Later writes reopen it if needed.
+func (b *LogBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.spillFile == nil {
+		return nil
+	}
+	err := b.spillFile.Close()
+	b.spillFile = nil
+	return err
+}
+
+// evict drops the n oldest bytes of a full buffer without moving the others.
+// The caller is responsible for overwriting or resetting the evicted region.
+func (b *LogBuffer) evict(n int) {
+	if n == 0 {
+		return
+	}
+	end := b.start + n
+	if end <= len(b.data) {
+		b.evictBytes(b.data[b.start:end])
+		return
+	}
+	b.evictBytes(b.data[b.start:])
+	b.evictBytes(b.data[:end-len(b.data)])
+}
+
+// evictBytes accounts for dropped bytes and spills them to disk if enabled
+func (b *LogBuffer) evictBytes(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	b.dropped += int64(len(p))
+	if b.spillPath == "" {
+		return
+	}
+
+	if b.spillFile == nil {
+		if err := os.MkdirAll(filepath.Dir(b.spillPath), 0755); err != nil {
+			logrus.Warnf("Failed to create process logs directory, disabling spill: %v", err)
+			b.spillPath = ""
+			return
+		}
+		f, err := os.OpenFile(b.spillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logrus.Warnf("Failed to open process log file %s, disabling spill: %v", b.spillPath, err)
+			b.spillPath = ""
+			return
+		}
+		b.spillFile = f
+	}
+	if _, err := b.spillFile.Write(p); err != nil {
+		logrus.Warnf("Failed to write process log file %s: %v", b.spillPath, err)
+	}
+}
+
+// maxLogBytes returns the size cap of process output buffers, read from MAX_LOG_BYTES
+func maxLogBytes() int {
+	value := os.Getenv("MAX_LOG_BYTES")
+	if value == "" {
+		return DefaultMaxLogBytes
+	}
+	maxBytes, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.Warnf("Invalid MAX_LOG_BYTES value '%s', using default of %d bytes", value, DefaultMaxLogBytes)
+		return DefaultMaxLogBytes
+	}
+	return maxBytes
+}
+
+// initLogBuffers sets up the output buffers of a started process.
+// When PROCESS_LOGS_DIR is set, output dropped from the combined logs is
+// spilled to a file in that directory. A process logging to files gets its
+// rotated log file there too.
+func (p *ProcessInfo) initLogBuffers() {
+	maxBytes := maxLogBytes()
+
+	spillPath := ""
+	if logsDir := os.Getenv("PROCESS_LOGS_DIR"); logsDir != "" {
+		spillPath = filepath.Join(logsDir, fmt.Sprintf("%s-%d.log", p.PID, p.StartedAt.Unix()))
+	}
+
+	p.stdout = NewLogBuffer(maxBytes, "")
+	p.stderr = NewLogBuffer(maxBytes, "")
+	p.logs = NewLogBuffer(maxBytes, spillPath)
+	if p.LogToFile {
+		p.logFile = NewLogFile(filepath.Join(logFilesDir(), fmt.Sprintf("%s-%d.output.log", p.PID, p.StartedAt.Unix())), logFileMaxBytes(), logFileMaxFiles())
+	}
+}
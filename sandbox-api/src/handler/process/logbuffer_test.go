@@ -0,0 +1,232 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLogBuffer tests the size-capped log buffer
+func TestLogBuffer(t *testing.T) {
+	t.Run("BelowCap", func(t *testing.T) {
+		b := NewLogBuffer(16, "")
+		_, _ = b.WriteString("hello ")
+		_, _ = b.WriteString("world")
+
+		if b.String() != "hello world" {
+			t.Errorf("Expected 'hello world', got %q", b.String())
+		}
+		if b.DroppedBytes() != 0 {
+			t.Errorf("Expected no dropped bytes, got %d", b.DroppedBytes())
+		}
+	})
+
+	t.Run("DropsOldestBytes", func(t *testing.T) {
+		b := NewLogBuffer(8, "")
+		for _, chunk := range []string{"abc", "def", "ghi", "jkl"} {
+			_, _ = b.WriteString(chunk)
+		}
+
+		if b.String() != "efghijkl" {
+			t.Errorf("Expected 'efghijkl', got %q", b.String())
+		}
+		if b.Len() != 8 {
+			t.Errorf("Expected length 8, got %d", b.Len())
+		}
+		if b.DroppedBytes() != 4 {
+			t.Errorf("Expected 4 dropped bytes, got %d", b.DroppedBytes())
+		}
+	})
+
+	t.Run("WriteLargerThanCap", func(t *testing.T) {
+		b := NewLogBuffer(4, "")
+		_, _ = b.WriteString("ab")
+		_, _ = b.WriteString("0123456789")
+
+		if b.String() != "6789" {
+			t.Errorf("Expected '6789', got %q", b.String())
+		}
+		if b.DroppedBytes() != 8 {
+			t.Errorf("Expected 8 dropped bytes, got %d", b.DroppedBytes())
+		}
+	})
+
+	t.Run("Unbounded", func(t *testing.T) {
+		b := NewLogBuffer(0, "")
+		content := strings.Repeat("x", 1024)
+		_, _ = b.WriteString(content)
+
+		if b.String() != content || b.DroppedBytes() != 0 {
+			t.Errorf("Expected unbounded buffer to keep all content")
+		}
+	})
+
+	t.Run("SpillToDisk", func(t *testing.T) {
+		spillPath := filepath.Join(t.TempDir(), "logs", "spill.log")
+		b := NewLogBuffer(4, spillPath)
+		for _, chunk := range []string{"abc", "def", "ghi"} {
+			_, _ = b.WriteString(chunk)
+		}
+		if err := b.Close(); err != nil {
+			t.Fatalf("Failed to close buffer: %v", err)
+		}
+
+		if b.SpillPath() != spillPath {
+			t.Errorf("Expected spill path %s, got %s", spillPath, b.SpillPath())
+		}
+		spilled, err := os.ReadFile(spillPath)
+		if err != nil {
+			t.Fatalf("Failed to read spill file: %v", err)
+		}
+		if string(spilled)+b.String() != "abcdefghi" {
+			t.Errorf("Expected spilled and buffered content to add up, got %q + %q", string(spilled), b.String())
+		}
+	})
+}
+
+// TestLogBufferQuery tests tail, since, offset and limit filtering
+func TestLogBufferQuery(t *testing.T) {
+	b := NewLogBuffer(15, "")
+	_, _ = b.WriteString("one\ntwo\n")
+	// Make the first write look old
+	b.chunks[0].at = time.Now().Add(-time.Hour)
+	_, _ = b.WriteString("three\nfour\n")
+
+	testCases := []struct {
+		name     string
+		query    LogQuery
+		expected string
+		from     int64
+		to       int64
+	}{
+		// "one\n" was dropped by the 15 bytes cap
+		{name: "All", query: LogQuery{}, expected: "two\nthree\nfour\n", from: 4, to: 19},
+		{name: "Tail", query: LogQuery{Tail: 2}, expected: "three\nfour\n", from: 8, to: 19},
+		{name: "TailMoreThanAvailable", query: LogQuery{Tail: 10}, expected: "two\nthree\nfour\n", from: 4, to: 19},
+		{name: "Since", query: LogQuery{Since: time.Now().Add(-time.Minute)}, expected: "three\nfour\n", from: 8, to: 19},
+		{name: "Offset", query: LogQuery{Offset: 14}, expected: "four\n", from: 14, to: 19},
+		{name: "OffsetBeforeBuffer", query: LogQuery{Offset: 1}, expected: "two\nthree\nfour\n", from: 4, to: 19},
+		{name: "OffsetAtEnd", query: LogQuery{Offset: 19}, expected: "", from: 19, to: 19},
+		{name: "Limit", query: LogQuery{Offset: 8, Limit: 6}, expected: "three\n", from: 8, to: 14},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, from, to := b.Query(tc.query)
+			if content != tc.expected {
+				t.Errorf("Expected content %q, got %q", tc.expected, content)
+			}
+			if from != tc.from || to != tc.to {
+				t.Errorf("Expected offsets [%d, %d), got [%d, %d)", tc.from, tc.to, from, to)
+			}
+		})
+	}
+}
+
+// TestLogBufferQueryFrames tests splitting the content into frames by stream and time
+func TestLogBufferQueryFrames(t *testing.T) {
+	b := NewLogBuffer(20, "")
+	_, _ = b.WriteStream("stdout", []byte("one\n"))
+	_, _ = b.WriteStream("stderr", []byte("err: two\n"))
+	// Make the writes so far look old, the next ones start a new chunk
+	b.chunks[0].at = time.Now().Add(-time.Hour)
+	_, _ = b.WriteStream("stderr", []byte("three\n"))
+	_, _ = b.WriteString("[api]\n")
+
+	frames, from, to := b.QueryFrames(LogQuery{})
+	// "one\n" and "e" were dropped by the 20 bytes cap
+	expected := []LogFrame{
+		{Stream: "stderr", Data: "rr: two\n", Offset: 5, NextOffset: 13},
+		{Stream: "stderr", Data: "three\n", Offset: 13, NextOffset: 19},
+		{Stream: "", Data: "[api]\n", Offset: 19, NextOffset: 25},
+	}
+	if from != 5 || to != 25 || len(frames) != len(expected) {
+		t.Fatalf("Unexpected frames [%d, %d) %+v", from, to, frames)
+	}
+	for i, frame := range frames {
+		if frame.At.IsZero() {
+			t.Errorf("Expected frame %d to have a time", i)
+		}
+		frame.At = time.Time{}
+		if frame != expected[i] {
+			t.Errorf("Expected frame %+v, got %+v", expected[i], frame)
+		}
+	}
+
+	if frames, _, _ := b.QueryFrames(LogQuery{Offset: 15}); len(frames) != 2 || frames[0].Data != "ree\n" || frames[0].Stream != "stderr" {
+		t.Errorf("Unexpected frames from an offset %+v", frames)
+	}
+}
+
+// TestProcessLogsTruncation tests that truncation is reported in the process logs
+func TestProcessLogsTruncation(t *testing.T) {
+	t.Setenv("MAX_LOG_BYTES", "64")
+
+	pm := GetProcessManager()
+	completed := make(chan struct{})
+	pid, err := pm.StartProcess("for i in $(seq 1 50); do echo line-$i; done", "", nil, false, 0, func(p *ProcessInfo) {
+		close(completed)
+	})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	<-completed
+
+	logs, err := pm.GetProcessOutput(pid)
+	if err != nil {
+		t.Fatalf("Failed to get process output: %v", err)
+	}
+	if !logs.Truncated {
+		t.Error("Expected logs to be truncated")
+	}
+	if len(logs.Logs) != 64 {
+		t.Errorf("Expected 64 bytes of logs, got %d", len(logs.Logs))
+	}
+	if !strings.HasSuffix(logs.Logs, "line-50\n") {
+		t.Errorf("Expected logs to end with the latest line, got %q", logs.Logs)
+	}
+}
+
+// TestProcessLogEntries tests the timestamped entries of the process logs
+func TestProcessLogEntries(t *testing.T) {
+	pm := GetProcessManager()
+	completed := make(chan struct{})
+	pid, err := pm.StartProcess("echo one; sleep 0.1; echo two >&2; sleep 0.1; echo three", "", nil, false, 0, func(p *ProcessInfo) {
+		close(completed)
+	})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	<-completed
+
+	logs, err := pm.QueryProcessOutput(pid, LogQuery{Timestamps: true})
+	if err != nil {
+		t.Fatalf("Failed to get process output: %v", err)
+	}
+	expected := []ProcessLogEntry{{Stream: "stdout", Text: "one\n"}, {Stream: "stderr", Text: "two\n"}, {Stream: "stdout", Text: "three\n"}}
+	if len(logs.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), logs.Entries)
+	}
+	for i, entry := range logs.Entries {
+		if entry.Stream != expected[i].Stream || entry.Text != expected[i].Text {
+			t.Errorf("Expected entry %+v, got %+v", expected[i], entry)
+		}
+		if i > 0 && !entry.Ts.After(logs.Entries[i-1].Ts) {
+			t.Errorf("Expected entry %d to be written after the previous one, got %v then %v", i, logs.Entries[i-1].Ts, entry.Ts)
+		}
+	}
+
+	logs, err = pm.QueryProcessOutput(pid, LogQuery{Stream: "stderr", Timestamps: true})
+	if err != nil {
+		t.Fatalf("Failed to get process output: %v", err)
+	}
+	if len(logs.Entries) != 1 || logs.Entries[0].Stream != "stderr" || logs.Entries[0].Text != "two\n" {
+		t.Errorf("Unexpected stderr entries %+v", logs.Entries)
+	}
+
+	if logs, _ := pm.GetProcessOutput(pid); logs.Entries != nil {
+		t.Errorf("Expected no entries unless timestamps are requested, got %+v", logs.Entries)
+	}
+}
@@ -0,0 +1,291 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Defaults of the log files of processes logging to files
+const (
+	DefaultLogFileMaxBytes = 10 * 1024 * 1024
+	DefaultLogFileMaxFiles = 5
+)
+
+// LogFile is a log file rotated once it reaches its size cap: the current file is
+// renamed with the suffix .1, the previous .1 to .2 and so on, up to maxFiles rotated
+// files, older ones being removed. The file is opened on the first write.
+type LogFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	disabled bool
+}
+
+// NewLogFile returns a log file at path rotated every maxBytes, keeping maxFiles
+// rotated files
+func NewLogFile(path string, maxBytes int64, maxFiles int) *LogFile {
+	return &LogFile{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+// Path returns the path of the current file
+func (f *LogFile) Path() string {
+	return f.path
+}
+
+// Write appends p to the current file, rotating it first if p does not fit. Writing is
+// disabled, with a warning, if the file cannot be opened.
+func (f *LogFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.disabled {
+		return len(p), nil
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			logrus.Warnf("Failed to open process log file %s, disabling it: %v", f.path, err)
+			f.disabled = true
+			return len(p), nil
+		}
+	}
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			logrus.Warnf("Failed to rotate process log file %s: %v", f.path, err)
+		}
+		if f.file == nil {
+			f.disabled = true
+			return len(p), nil
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// open opens the current file for appending, creating it and its directory if needed
+func (f *LogFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files, moves the current file to .1 and opens a new one.
+// The current file is reopened even if shifting fails, writes then keep appending to it.
+func (f *LogFile) rotate() error {
+	_ = f.file.Close()
+	f.file = nil
+
+	err := f.shift()
+	if openErr := f.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// shift renames the rotated files and the current file, dropping the oldest file
+func (f *LogFile) shift() error {
+	if f.maxFiles == 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	_ = os.Remove(f.rotatedPath(f.maxFiles))
+	for i := f.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(f.rotatedPath(i), f.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(f.path, f.rotatedPath(1))
+}
+
+// rotatedPath returns the path of the n-th rotated file, 1 being the most recent
+func (f *LogFile) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// files returns the paths of the existing files, oldest first
+func (f *LogFile) files() []string {
+	paths := make([]string, 0, f.maxFiles+1)
+	for i := f.maxFiles; i > 0; i-- {
+		if _, err := os.Stat(f.rotatedPath(i)); err == nil {
+			paths = append(paths, f.rotatedPath(i))
+		}
+	}
+	if _, err := os.Stat(f.path); err == nil {
+		paths = append(paths, f.path)
+	}
+	return paths
+}
+
+// Open returns a reader of the content of the rotated files and the current file, oldest
+// first, along with its size. The content is the one written when Open is called.
+func (f *LogFile) Open() (io.ReadCloser, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	files := make([]*os.File, 0)
+	readers := make([]io.Reader, 0)
+	size := int64(0)
+	for _, path := range f.files() {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			closeFiles(files)
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			closeFiles(files)
+			return nil, 0, err
+		}
+		files = append(files, file)
+		readers = append(readers, io.LimitReader(file, info.Size()))
+		size += info.Size()
+	}
+	return &multiFileReader{Reader: io.MultiReader(readers...), files: files}, size, nil
+}
+
+// Close closes the current file. Later writes reopen it.
+func (f *LogFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Remove closes and removes the current and rotated files
+func (f *LogFile) Remove() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		_ = f.file.Close()
+		f.file = nil
+	}
+	f.disabled = true
+	for _, path := range f.files() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// multiFileReader reads files one after the other and closes them all
+type multiFileReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (r *multiFileReader) Close() error {
+	closeFiles(r.files)
+	return nil
+}
+
+// closeFiles closes files, ignoring errors
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		_ = file.Close()
+	}
+}
+
+// OpenLogFiles returns a reader of the rotated log files of a process, oldest first,
+// along with its size and the name of the process
+func (pm *ProcessManager) OpenLogFiles(identifier string) (io.ReadCloser, int64, string, error) {
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return nil, 0, "", apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+	if process.logFile == nil {
+		return nil, 0, "", apierror.Newf(apierror.CodeNotFound, "process with Identifier %s does not log to files, start it with logToFile", identifier)
+	}
+	reader, size, err := process.logFile.Open()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return reader, size, process.Name, nil
+}
+
+// logToFileFromEnv returns whether every process logs to files, read from PROCESS_LOG_TO_FILE
+func logToFileFromEnv() bool {
+	value := os.Getenv("PROCESS_LOG_TO_FILE")
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Invalid PROCESS_LOG_TO_FILE value '%s', processes only log to files when asked to", value)
+		return false
+	}
+	return enabled
+}
+
+// logFilesDir returns the directory of the log files of processes, read from
+// PROCESS_LOGS_DIR and defaulting to sandbox-process-logs in the temp directory
+func logFilesDir() string {
+	if dir := os.Getenv("PROCESS_LOGS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "sandbox-process-logs")
+}
+
+// logFileMaxBytes returns the size log files are rotated at, read from PROCESS_LOG_FILE_MAX_BYTES
+func logFileMaxBytes() int64 {
+	value := os.Getenv("PROCESS_LOG_FILE_MAX_BYTES")
+	if value == "" {
+		return DefaultLogFileMaxBytes
+	}
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		logrus.Warnf("Invalid PROCESS_LOG_FILE_MAX_BYTES value '%s', using default of %d bytes", value, DefaultLogFileMaxBytes)
+		return DefaultLogFileMaxBytes
+	}
+	return maxBytes
+}
+
+// logFileMaxFiles returns the number of rotated log files kept, read from PROCESS_LOG_FILE_MAX_FILES
+func logFileMaxFiles() int {
+	value := os.Getenv("PROCESS_LOG_FILE_MAX_FILES")
+	if value == "" {
+		return DefaultLogFileMaxFiles
+	}
+	maxFiles, err := strconv.Atoi(value)
+	if err != nil || maxFiles < 0 {
+		logrus.Warnf("Invalid PROCESS_LOG_FILE_MAX_FILES value '%s', using default of %d files", value, DefaultLogFileMaxFiles)
+		return DefaultLogFileMaxFiles
+	}
+	return maxFiles
+}
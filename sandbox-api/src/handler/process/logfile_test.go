@@ -0,0 +1,119 @@
+package process
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// readLogFile reads the content of a log file and its rotated files
+func readLogFile(t *testing.T, f *LogFile) string {
+	t.Helper()
+	reader, size, err := f.Open()
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if int64(len(content)) != size {
+		t.Errorf("Expected %d bytes, read %d", size, len(content))
+	}
+	return string(content)
+}
+
+// TestLogFileRotation tests that log files are rotated at their size cap, only the
+// most recent rotated files being kept
+func TestLogFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "process.log")
+	f := NewLogFile(path, 8, 2)
+
+	if content := readLogFile(t, f); content != "" {
+		t.Errorf("Expected no content before the first write, got %q", content)
+	}
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	if content := readLogFile(t, f); content != "bbbb\ncccc\ndddd\n" {
+		t.Errorf("Expected the oldest file to be dropped, got %q", content)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 rotated files, got %v", err)
+	}
+	if rotated, _ := os.ReadFile(path + ".1"); string(rotated) != "cccc\n" {
+		t.Errorf("Expected the previous file to be rotated to .1, got %q", rotated)
+	}
+
+	// Writes after closing reopen the current file
+	if err := f.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if _, err := f.Write([]byte("e\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if content := readLogFile(t, f); content != "bbbb\ncccc\ndddd\ne\n" {
+		t.Errorf("Expected the write to be appended, got %q", content)
+	}
+
+	if err := f.Remove(); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 0 {
+		t.Errorf("Expected the log files to be removed, %d remain", len(entries))
+	}
+}
+
+// TestProcessLogToFile tests that the output of a process logging to files can be read
+// back, and that its files are removed along with the process
+func TestProcessLogToFile(t *testing.T) {
+	logsDir := t.TempDir()
+	t.Setenv("PROCESS_LOGS_DIR", logsDir)
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcessWithRestart("echo out; echo err >&2", "", "log-to-file", nil, lib.RunAs{}, 0, RestartConfig{}, true, false, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(pid)
+	select {
+	case <-process.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the process to be done")
+	}
+
+	reader, size, name, err := pm.OpenLogFiles("log-to-file")
+	if err != nil {
+		t.Fatalf("Failed to open log files: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if name != "log-to-file" || int64(len(content)) != size || len(content) != len("out\nerr\n") {
+		t.Errorf("Unexpected log files of %s: %q (%d bytes)", name, content, size)
+	}
+
+	other, err := pm.StartProcessWithName("true", "", "no-log-file", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if _, _, _, err := pm.OpenLogFiles(other); !errors.Is(err, apierror.New(apierror.CodeNotFound, "")) {
+		t.Errorf("Expected NOT_FOUND for a process not logging to files, got %v", err)
+	}
+
+	if err := pm.RemoveProcess(pid); err != nil {
+		t.Fatalf("Failed to remove process: %v", err)
+	}
+	if entries, _ := os.ReadDir(logsDir); len(entries) != 0 {
+		t.Errorf("Expected the log files to be removed with the process, %d remain", len(entries))
+	}
+}
@@ -0,0 +1,211 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// OverflowPolicy is what a log stream does when its client does not keep up with the
+// output of the process
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest drops the oldest queued output, replaced by a line telling
+	// how many bytes were dropped
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowDisconnect ends the stream
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// DefaultLogStreamQueueBytes is the output queued for a client when
+// LOG_STREAM_QUEUE_BYTES is unset
+const DefaultLogStreamQueueBytes = 1024 * 1024
+
+// errLogStreamClosed is returned by writes to a closed log stream
+var errLogStreamClosed = errors.New("log stream closed")
+
+// ParseOverflowPolicy returns the overflow policy of a name, the one set with
+// LOG_STREAM_OVERFLOW when empty, drop-oldest by default
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	if name == "" {
+		name = os.Getenv("LOG_STREAM_OVERFLOW")
+		if name == "" {
+			return OverflowDropOldest, nil
+		}
+	}
+	switch policy := OverflowPolicy(name); policy {
+	case OverflowDropOldest, OverflowDisconnect:
+		return policy, nil
+	}
+	return "", apierror.Newf(apierror.CodeInvalidRequest, "invalid overflow policy '%s', expected '%s' or '%s'", name, OverflowDropOldest, OverflowDisconnect)
+}
+
+// logStreamQueueBytesFromEnv returns the output queued for a client, read from
+// LOG_STREAM_QUEUE_BYTES
+func logStreamQueueBytesFromEnv() int {
+	value := os.Getenv("LOG_STREAM_QUEUE_BYTES")
+	if value == "" {
+		return DefaultLogStreamQueueBytes
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logrus.Warnf("Invalid LOG_STREAM_QUEUE_BYTES value '%s', using default of %d bytes", value, DefaultLogStreamQueueBytes)
+		return DefaultLogStreamQueueBytes
+	}
+	return n
+}
+
+// LogStream is a log writer of a process writing to a client from its own goroutine,
+// so that a slow client does not hold up the capture of the output. At most maxBytes
+// of output are queued for the client, beyond which the overflow policy applies.
+type LogStream struct {
+	w        io.Writer
+	policy   OverflowPolicy
+	maxBytes int
+	process  *ProcessInfo
+
+	mu      sync.Mutex
+	chunks  [][]byte
+	size    int
+	dropped int64
+	closed  bool
+
+	wake           chan struct{}
+	overflowed     chan struct{}
+	overflowedOnce sync.Once
+	done           chan struct{}
+}
+
+// newLogStream starts writing the output queued for w
+func newLogStream(process *ProcessInfo, w io.Writer, policy OverflowPolicy, maxBytes int) *LogStream {
+	s := &LogStream{
+		w:          w,
+		policy:     policy,
+		maxBytes:   maxBytes,
+		process:    process,
+		wake:       make(chan struct{}, 1),
+		overflowed: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues output for the client without blocking
+func (s *LogStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errLogStreamClosed
+	}
+	n := len(p)
+	if s.size+len(p) > s.maxBytes {
+		if s.policy == OverflowDisconnect {
+			s.discard()
+			s.overflowedOnce.Do(func() { close(s.overflowed) })
+			return 0, errLogStreamClosed
+		}
+		for len(s.chunks) > 0 && s.size+len(p) > s.maxBytes {
+			s.size -= len(s.chunks[0])
+			s.dropped += int64(len(s.chunks[0]))
+			s.chunks = s.chunks[1:]
+		}
+		// Only the end of output larger than the whole queue is kept
+		if len(p) > s.maxBytes {
+			s.dropped += int64(len(p) - s.maxBytes)
+			p = p[len(p)-s.maxBytes:]
+		}
+	}
+	// Writers reuse their buffer
+	s.chunks = append(s.chunks, append([]byte(nil), p...))
+	s.size += len(p)
+	s.signal()
+	return n, nil
+}
+
+// Overflowed returns a channel closed when the stream ended because its client did
+// not keep up, with the disconnect policy
+func (s *LogStream) Overflowed() <-chan struct{} {
+	return s.overflowed
+}
+
+// Close detaches the stream from the process, and waits for the queued output to be
+// written to the client
+func (s *LogStream) Close() {
+	s.process.logLock.Lock()
+	for i, writer := range s.process.logWriters {
+		if writer == s {
+			s.process.logWriters = append(s.process.logWriters[:i], s.process.logWriters[i+1:]...)
+			break
+		}
+	}
+	s.process.logLock.Unlock()
+
+	s.mu.Lock()
+	s.closed = true
+	s.signal()
+	s.mu.Unlock()
+	<-s.done
+}
+
+// signal wakes the writing goroutine up, s.mu is held
+func (s *LogStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// discard closes the stream dropping the queued output, s.mu is held
+func (s *LogStream) discard() {
+	s.closed = true
+	s.chunks = nil
+	s.size = 0
+	s.dropped = 0
+	s.signal()
+}
+
+// run writes the queued output to the client until the stream is closed and drained,
+// or a write fails
+func (s *LogStream) run() {
+	defer close(s.done)
+
+	for {
+		s.mu.Lock()
+		for len(s.chunks) == 0 && s.dropped == 0 && !s.closed {
+			s.mu.Unlock()
+			<-s.wake
+			s.mu.Lock()
+		}
+		chunks, dropped := s.chunks, s.dropped
+		s.chunks, s.size, s.dropped = nil, 0, 0
+		s.mu.Unlock()
+		if len(chunks) == 0 && dropped == 0 {
+			return
+		}
+
+		if dropped > 0 {
+			chunks = append([][]byte{[]byte(fmt.Sprintf("\n[%d bytes dropped, the client is not keeping up]\n", dropped))}, chunks...)
+		}
+		for _, chunk := range chunks {
+			if _, err := s.w.Write(chunk); err != nil {
+				s.mu.Lock()
+				s.discard()
+				s.mu.Unlock()
+				return
+			}
+		}
+		if f, ok := s.w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+// blockingWriter is a client not reading its output until released, after signaling
+// the first write
+type blockingWriter struct {
+	safeBuffer
+	writing chan struct{}
+	release chan struct{}
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{writing: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.writing <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return w.safeBuffer.Write(p)
+}
+
+// blockStream starts a stream whose client is stuck writing its first output
+func blockStream(t *testing.T, policy OverflowPolicy) (*LogStream, *blockingWriter) {
+	w := newBlockingWriter()
+	stream := newLogStream(&ProcessInfo{}, w, policy, 10)
+	if _, err := stream.Write([]byte("aaaa\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.writing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the output to be written")
+	}
+	return stream, w
+}
+
+// TestLogStreamDropOldest tests that the oldest output is dropped for a slow client,
+// without blocking the writes
+func TestLogStreamDropOldest(t *testing.T) {
+	stream, w := blockStream(t, OverflowDropOldest)
+
+	for _, output := range []string{"bbbbbbbb\n", "cccc\n"} {
+		if n, err := stream.Write([]byte(output)); err != nil || n != len(output) {
+			t.Fatalf("Expected the write to succeed, got %d (%v)", n, err)
+		}
+	}
+	select {
+	case <-stream.Overflowed():
+		t.Error("Expected the stream not to overflow")
+	default:
+	}
+
+	close(w.release)
+	stream.Close()
+	expected := "aaaa\n\n[9 bytes dropped, the client is not keeping up]\ncccc\n"
+	if w.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.String())
+	}
+}
+
+// TestLogStreamDisconnect tests that the stream of a slow client ends on overflow
+func TestLogStreamDisconnect(t *testing.T) {
+	stream, w := blockStream(t, OverflowDisconnect)
+
+	if _, err := stream.Write([]byte("bbbbbbbb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Write([]byte("cccc\n")); err == nil {
+		t.Error("Expected the write to fail on overflow")
+	}
+	select {
+	case <-stream.Overflowed():
+	default:
+		t.Error("Expected the stream to overflow")
+	}
+
+	close(w.release)
+	stream.Close()
+	if w.String() != "aaaa\n" {
+		t.Errorf("Expected the queued output to be dropped, got %q", w.String())
+	}
+}
+
+// TestParseOverflowPolicy tests the default and invalid overflow policies
+func TestParseOverflowPolicy(t *testing.T) {
+	t.Setenv("LOG_STREAM_OVERFLOW", "disconnect")
+	if policy, err := ParseOverflowPolicy(""); err != nil || policy != OverflowDisconnect {
+		t.Errorf("Expected the policy of LOG_STREAM_OVERFLOW, got %q (%v)", policy, err)
+	}
+	if policy, err := ParseOverflowPolicy("drop-oldest"); err != nil || policy != OverflowDropOldest {
+		t.Errorf("Expected drop-oldest, got %q (%v)", policy, err)
+	}
+	if _, err := ParseOverflowPolicy("block"); err == nil {
+		t.Error("Expected an error for an invalid policy")
+	}
+}
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
@@ -9,10 +10,16 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
 )
 
 // Define process status constants
@@ -22,20 +29,58 @@ const (
 	StatusStopped   = constants.ProcessStatusStopped
 	StatusRunning   = constants.ProcessStatusRunning
 	StatusCompleted = constants.ProcessStatusCompleted
+	StatusTimedOut  = constants.ProcessStatusTimedOut
 )
 
 // ProcessManager manages the running processes
 type ProcessManager struct {
-	processes map[string]*ProcessInfo
-	mu        sync.RWMutex
+	processes  map[string]*ProcessInfo
+	mu         sync.RWMutex
+	groups     map[string]*ProcessGroup
+	groupsMu   sync.RWMutex
+	persistDir string
+	persistMu  sync.Mutex
+	listeners  []OutputListener
+	listenerMu sync.RWMutex
+	startup    StartupCommand
+	// startupCommands are the processes of the startup group
+	startupCommands []GroupProcessSpec
+	startupMu       sync.Mutex
 }
 
+// OutputListener is called with the output of every managed process, as it is read
+// from its stdout or stderr. It must not block.
+type OutputListener func(process *ProcessInfo, stream string, data []byte)
+
 type ProcessLogs struct {
 	Stdout string `json:"stdout" example:"stdout output" binding:"required"`
 	Stderr string `json:"stderr" example:"stderr output" binding:"required"`
 	Logs   string `json:"logs" example:"logs output" binding:"required"`
+	// Truncated is true when older output was dropped from any of the buffers
+	Truncated bool `json:"truncated" example:"false"`
+	// DroppedBytes is the number of bytes dropped from the combined logs
+	DroppedBytes int64 `json:"droppedBytes" example:"0"`
+	// LogFile is the file holding the dropped combined logs, when spilling to disk is enabled
+	LogFile string `json:"logFile,omitempty" example:"/var/log/sandbox/1234-1700000000.log"`
+	// Offset is the absolute byte offset of the returned output (combined logs, or the selected stream)
+	Offset int64 `json:"offset" example:"0"`
+	// NextOffset is the offset to request to only get newer output
+	NextOffset int64 `json:"nextOffset" example:"1024"`
+	// Entries is the returned output (combined logs, or the selected stream) split at
+	// each write, with its time and stream, when timestamps are requested
+	Entries []ProcessLogEntry `json:"entries,omitempty"`
 } // @name ProcessLogs
 
+// ProcessLogEntry is output of a process written at once to a stream
+type ProcessLogEntry struct {
+	// Ts is when the output was written, to the millisecond, never before the previous entry
+	Ts time.Time `json:"ts" example:"2024-01-01T00:00:00.123Z" binding:"required"`
+	// Stream is stdout or stderr, empty for the messages of the API and the output
+	// restored after an API restart
+	Stream string `json:"stream" example:"stdout" binding:"required"`
+	Text   string `json:"text" example:"Server listening on port 3000\n" binding:"required"`
+} // @name ProcessLogEntry
+
 // ProcessInfo stores information about a running process
 type ProcessInfo struct {
 	PID              string                  `json:"pid"`
@@ -47,23 +92,176 @@ type ProcessInfo struct {
 	ExitCode         int                     `json:"exitCode"`
 	Status           constants.ProcessStatus `json:"status"`
 	WorkingDir       string                  `json:"workingDir"`
+	RunAsUser        string                  `json:"runAsUser,omitempty"`
+	RunAsGroup       string                  `json:"runAsGroup,omitempty"`
+	Timeout          int                     `json:"timeout"`             // seconds after which each run is killed, 0 for none
+	Adopted          bool                    `json:"adopted,omitempty"`   // an OS process not started by the API
+	LogToFile        bool                    `json:"logToFile,omitempty"` // output is also written to rotated log files
+	Record           bool                    `json:"record,omitempty"`    // output is recorded to an asciicast file
+	Recording        string                  `json:"recording,omitempty"` // id of the recording of the output
 	Logs             *string                 `json:"logs"`
 	RestartOnFailure bool                    `json:"restartOnFailure"`
 	MaxRestarts      int                     `json:"maxRestarts"`
 	RestartCount     int                     `json:"restartCount"`
-	stdout           *strings.Builder
-	stderr           *strings.Builder
-	logs             *strings.Builder
+	RestartPolicy    RestartPolicy           `json:"restartPolicy"`
+	Backoff          *BackoffConfig          `json:"backoff"`
+	restartWindow    time.Duration
+	restartTimes     []time.Time
+	restartDelay     time.Duration
+	nextRestartAt    *time.Time
+	restartCancel    chan struct{}
+	restartMu        sync.Mutex
+	stateMu          sync.RWMutex // guards Status, ExitCode and CompletedAt once the process runs
+	timedOut         atomic.Bool  // the timeout of the current run expired
+	env              map[string]string
+	execPath         string   // resolved executable of the last run
+	execArgs         []string // arguments of the last run
+	execEnv          []string // final environment of the last run
+	stdout           *LogBuffer
+	stderr           *LogBuffer
+	logs             *LogBuffer
+	logFile          *LogFile
+	savedLogFiles    map[string]string // output files saved by SaveState, for restored processes
+	recorder         *Recorder
 	stdoutPipe       io.ReadCloser
 	stderrPipe       io.ReadCloser
 	logWriters       []io.Writer
 	logLock          sync.RWMutex
+	done             chan struct{}
+	doneOnce         sync.Once
+}
+
+// ProcessEvent is the data of the process events published to the event bus
+type ProcessEvent struct {
+	PID          string                  `json:"pid" example:"1234" binding:"required"`
+	Name         string                  `json:"name" example:"my-process" binding:"required"`
+	Command      string                  `json:"command" example:"npm start" binding:"required"`
+	Status       constants.ProcessStatus `json:"status" example:"running" binding:"required"`
+	ExitCode     int                     `json:"exitCode" example:"0"`
+	RestartCount int                     `json:"restartCount" example:"0"`
+	ProcessPid   int                     `json:"osPid" example:"1234"` // PID of the OS process of the current run
+} // @name ProcessEvent
+
+// ProcessState is the status of a process, with its exit code and completion time
+// once it has exited
+type ProcessState struct {
+	Status      constants.ProcessStatus
+	ExitCode    int
+	CompletedAt *time.Time
+}
+
+// State returns the status, exit code and completion time of the process. They are
+// updated when the process exits, so they must be read through State while it runs.
+func (p *ProcessInfo) State() ProcessState {
+	p.stateMu.RLock()
+	defer p.stateMu.RUnlock()
+	return ProcessState{Status: p.Status, ExitCode: p.ExitCode, CompletedAt: p.CompletedAt}
+}
+
+// setStatus sets the status of the process
+func (p *ProcessInfo) setStatus(status constants.ProcessStatus) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.Status = status
+}
+
+// setExited records the exit of the current run of the process, with the error
+// returned by its command. A process whose timeout expired is marked as timed out,
+// and a process stopped or killed keeps its status, so neither is restarted.
+func (p *ProcessInfo) setExited(err error, completedAt time.Time) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	p.CompletedAt = &completedAt
+	switch {
+	case p.timedOut.Load():
+		p.Status = StatusTimedOut
+	case p.Status == StatusStopped || p.Status == StatusKilled:
+	case err != nil:
+		p.Status = StatusFailed
+	default:
+		p.Status = StatusCompleted
+	}
+
+	p.ExitCode = 0
+	if err != nil {
+		p.ExitCode = 1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			p.ExitCode = exitErr.ExitCode()
+		}
+	}
+}
+
+// publishEvent publishes a process event to the event bus
+func (p *ProcessInfo) publishEvent(eventType string) {
+	state := p.State()
+	events.Publish(eventType, p.PID, ProcessEvent{
+		PID:          p.PID,
+		Name:         p.Name,
+		Command:      p.Command,
+		Status:       state.Status,
+		ExitCode:     state.ExitCode,
+		RestartCount: p.RestartCount,
+		ProcessPid:   p.ProcessPid,
+	})
+}
+
+// Done returns a channel that is closed once the process has reached a terminal
+// state and will not be restarted anymore
+func (p *ProcessInfo) Done() <-chan struct{} {
+	return p.done
+}
+
+// markDone closes the done channel, it is safe to call multiple times
+func (p *ProcessInfo) markDone() {
+	p.doneOnce.Do(func() {
+		// The recording is complete once the process is done
+		if p.recorder != nil {
+			_ = p.recorder.Close()
+		}
+		close(p.done)
+		if p.logs != nil {
+			_ = p.logs.Close()
+		}
+		if p.logFile != nil {
+			_ = p.logFile.Close()
+		}
+	})
+}
+
+// hasLogWriter reports whether w is attached to the output of the process
+func (p *ProcessInfo) hasLogWriter(w io.Writer) bool {
+	p.logLock.RLock()
+	defer p.logLock.RUnlock()
+	for _, writer := range p.logWriters {
+		if writer == w {
+			return true
+		}
+	}
+	return false
 }
 
 // NewProcessManager creates a new process manager
 func NewProcessManager() *ProcessManager {
 	return &ProcessManager{
 		processes: make(map[string]*ProcessInfo),
+		groups:    make(map[string]*ProcessGroup),
+	}
+}
+
+// AddOutputListener registers a listener called with the output of every process
+func (pm *ProcessManager) AddOutputListener(listener OutputListener) {
+	pm.listenerMu.Lock()
+	defer pm.listenerMu.Unlock()
+	pm.listeners = append(pm.listeners, listener)
+}
+
+// notifyOutput calls the output listeners with output of a process
+func (pm *ProcessManager) notifyOutput(process *ProcessInfo, stream string, data []byte) {
+	pm.listenerMu.RLock()
+	defer pm.listenerMu.RUnlock()
+	for _, listener := range pm.listeners {
+		listener(process, stream, data)
 	}
 }
 
@@ -87,74 +285,54 @@ func (pm *ProcessManager) StartProcess(command string, workingDir string, env ma
 }
 
 func (pm *ProcessManager) StartProcessWithName(command string, workingDir string, name string, env map[string]string, restartOnFailure bool, maxRestarts int, callback func(process *ProcessInfo)) (string, error) {
-	// Always use shell to execute commands
-	// This ensures shell built-ins (cd, export, alias) work properly
-	// Use SHELL and SHELL_ARGS environment variables if set
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "sh"
-	}
-
-	shellArgs := os.Getenv("SHELL_ARGS")
-	if shellArgs == "" {
-		shellArgs = "-c"
+	restart := RestartConfig{Policy: RestartPolicyNever, MaxRestarts: maxRestarts}
+	if restartOnFailure {
+		restart.Policy = RestartPolicyOnFailure
 	}
+	return pm.StartProcessWithRestart(command, workingDir, name, env, lib.RunAs{}, 0, restart, false, false, callback)
+}
 
-	// Build command arguments
-	cmdArgs := []string{}
-	if shellArgs != "" {
-		cmdArgs = append(cmdArgs, strings.Fields(shellArgs)...)
+// StartProcessWithRestart starts a named process restarted according to a restart configuration.
+// The process runs as runAs, or as the default RUN_AS user when empty. With a timeout, its
+// process group is killed once the timeout expires and it is not restarted. With logToFile,
+// or when PROCESS_LOG_TO_FILE is set, its output is also written to rotated log files. With
+// record, or when PROCESS_RECORD is set, its output is recorded to an asciicast file.
+func (pm *ProcessManager) StartProcessWithRestart(command string, workingDir string, name string, env map[string]string, runAs lib.RunAs, timeout time.Duration, restart RestartConfig, logToFile bool, record bool, callback func(process *ProcessInfo)) (string, error) {
+	// Reject commands denied by the process policy before anything else
+	if err := policy.GetEngine().Check(command, workingDir); err != nil {
+		return "", err
 	}
-	cmdArgs = append(cmdArgs, command)
 
-	cmd := exec.Command(shell, cmdArgs...)
-
-	if workingDir != "" {
-		// Check if the working directory exists
-		if _, err := os.Stat(workingDir); os.IsNotExist(err) {
-			return "", fmt.Errorf("could not execute command '%s' because folder '%s' does not exist", command, workingDir)
-		} else if err != nil {
-			return "", fmt.Errorf("could not access working directory '%s': %w", workingDir, err)
+	var process *ProcessInfo
+	cmd, cancelTimeout := shellCommand(command, timeout, func() {
+		process.timedOut.Store(true)
+	})
+	started := false
+	defer func() {
+		if !started {
+			cancelTimeout()
 		}
-		cmd.Dir = workingDir
-	}
-
-	// Set up process group to ensure all child processes can be killed together
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-	}
-
-	// Start with system environment
-	systemEnv := os.Environ()
+	}()
 
-	// Create a map to track which env vars we're overriding
-	envOverrides := make(map[string]bool)
-	for k := range env {
-		envOverrides[k] = true
+	// Processes run in the default working directory of the sandbox unless given one,
+	// relative working directories resolve against it
+	workingDir, err := lib.ResolveWorkingDir(workingDir)
+	if err != nil {
+		return "", err
 	}
-
-	// Build the final environment
-	finalEnv := make([]string, 0, len(systemEnv)+len(env))
-
-	// Add system environment variables that are not being overridden
-	for _, envVar := range systemEnv {
-		// Find the key part (everything before the first '=')
-		idx := strings.IndexByte(envVar, '=')
-		if idx > 0 {
-			key := envVar[:idx]
-			if !envOverrides[key] {
-				finalEnv = append(finalEnv, envVar)
-			}
-		}
+	// Check if the working directory exists
+	if _, err := os.Stat(workingDir); os.IsNotExist(err) {
+		return "", fmt.Errorf("could not execute command '%s' because folder '%s' does not exist", command, workingDir)
+	} else if err != nil {
+		return "", fmt.Errorf("could not access working directory '%s': %w", workingDir, err)
 	}
+	cmd.Dir = workingDir
 
-	// Add all custom environment variables
-	for k, v := range env {
-		finalEnv = append(finalEnv, k+"="+v)
+	runAs = runAs.OrDefault()
+	if err := configureCommand(cmd, env, runAs); err != nil {
+		return "", err
 	}
 
-	cmd.Env = finalEnv
-
 	// Set up stdout and stderr pipes
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -167,32 +345,38 @@ func (pm *ProcessManager) StartProcessWithName(command string, workingDir string
 	}
 
 	// Ensure maxRestarts doesn't exceed the limit
-	if maxRestarts > 25 {
-		maxRestarts = 25
+	maxRestarts := min(restart.MaxRestarts, maxRestartsLimit)
+	if restart.Policy == "" {
+		restart.Policy = RestartPolicyNever
 	}
 
-	// Set up stdout and stderr capture
-	stdout := &strings.Builder{}
-	stderr := &strings.Builder{}
-	logs := &strings.Builder{}
-	process := &ProcessInfo{
+	process = &ProcessInfo{
 		Name:             name,
 		Command:          command,
 		StartedAt:        time.Now(),
 		CompletedAt:      nil,
 		Status:           StatusRunning,
 		WorkingDir:       workingDir,
-		RestartOnFailure: restartOnFailure,
+		RunAsUser:        runAs.User,
+		RunAsGroup:       runAs.Group,
+		Timeout:          int(timeout.Seconds()),
+		LogToFile:        logToFile || logToFileFromEnv(),
+		Record:           record || recordFromEnv(),
+		RestartOnFailure: restart.Policy != RestartPolicyNever,
 		MaxRestarts:      maxRestarts,
 		RestartCount:     0,
-		stdout:           stdout,
-		stderr:           stderr,
-		logs:             logs,
+		RestartPolicy:    restart.Policy,
+		Backoff:          restart.Backoff,
+		restartWindow:    restart.Window,
+		env:              env,
 		stdoutPipe:       stdoutPipe,
 		stderrPipe:       stderrPipe,
 		logWriters:       make([]io.Writer, 0),
+		done:             make(chan struct{}),
 	}
 
+	process.recordExec(cmd)
+
 	// Start the process FIRST, before launching reader goroutines.
 	// This is the standard Go pattern for exec.Cmd with pipes.
 	//
@@ -207,14 +391,26 @@ func (pm *ProcessManager) StartProcessWithName(command string, workingDir string
 	// - Starting readers AFTER cmd.Start() ensures the pipes are connected
 	//   and output is buffered by the kernel until we read it.
 	if err := cmd.Start(); err != nil {
+		if !runAs.IsZero() {
+			return "", fmt.Errorf("could not start process as '%s' in '%s': %w", runAs, workingDir, err)
+		}
 		return "", err
 	}
-	process.PID = fmt.Sprintf("%d", cmd.Process.Pid)
+	started = true
+	// The OS PID may belong to a restored process, or an older one that exited
+	pm.mu.RLock()
+	process.PID = pm.uniquePIDLocked(strconv.Itoa(cmd.Process.Pid))
+	pm.mu.RUnlock()
 	process.ProcessPid = cmd.Process.Pid
+	// Set up stdout and stderr capture
+	process.initLogBuffers()
+	process.initRecorder()
 	// Store process in memory
 	pm.mu.Lock()
 	pm.processes[process.PID] = process
 	pm.mu.Unlock()
+	pm.persist()
+	process.publishEvent(events.ProcessStarted)
 
 	// WaitGroup to ensure stdout/stderr goroutines finish before marking process complete
 	var outputWg sync.WaitGroup
@@ -230,7 +426,14 @@ func (pm *ProcessManager) StartProcessWithName(command string, workingDir string
 				data := buf[:n]
 				process.logLock.Lock()
 				process.stdout.Write(data)
-				process.logs.Write(data)
+				process.logs.WriteStream(logStreamStdout, data)
+				if process.logFile != nil {
+					_, _ = process.logFile.Write(data)
+				}
+				if process.recorder != nil {
+					process.recorder.Output(logStreamStdout, data)
+				}
+				pm.notifyOutput(process, logStreamStdout, data)
 				// Send to any attached log writers, prefix with stdout:
 				for _, w := range process.logWriters {
 					fullMsg := append([]byte("stdout:"), data...)
@@ -257,7 +460,14 @@ func (pm *ProcessManager) StartProcessWithName(command string, workingDir string
 				data := buf[:n]
 				process.logLock.Lock()
 				process.stderr.Write(data)
-				process.logs.Write(data)
+				process.logs.WriteStream(logStreamStderr, data)
+				if process.logFile != nil {
+					_, _ = process.logFile.Write(data)
+				}
+				if process.recorder != nil {
+					process.recorder.Output(logStreamStderr, data)
+				}
+				pm.notifyOutput(process, logStreamStderr, data)
 				// Send to any attached log writers, prefix with stderr:
 				for _, w := range process.logWriters {
 					fullMsg := append([]byte("stderr:"), data...)
@@ -281,6 +491,7 @@ func (pm *ProcessManager) StartProcessWithName(command string, workingDir string
 		// commands. Deferring Wait until after the readers complete removes that race.
 		outputWg.Wait()
 		err := cmd.Wait()
+		cancelTimeout()
 		now := time.Now()
 
 		// IMPORTANT: Release process resources immediately after Wait() to close pidfd
@@ -289,78 +500,15 @@ func (pm *ProcessManager) StartProcessWithName(command string, workingDir string
 			_ = cmd.Process.Release()
 		}
 
-		process.CompletedAt = &now
-
-		// Determine exit status and create appropriate message
-		if err != nil {
-			if process.Status != StatusStopped && process.Status != StatusKilled {
-				process.Status = StatusFailed
-			}
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				process.ExitCode = exitErr.ExitCode()
-			} else {
-				process.ExitCode = 1
-			}
-		} else {
-			process.Status = StatusCompleted
-			process.ExitCode = 0
-		}
+		process.setExited(err, now)
 
 		// Update process in memory
 		pm.mu.Lock()
 		pm.processes[process.PID] = process
 		pm.mu.Unlock()
+		pm.persist()
 
-		// Check if we should restart on failure
-		if process.Status == StatusFailed && process.RestartOnFailure && process.RestartCount < process.MaxRestarts {
-			// Log the failure and restart attempt
-			restartMsg := fmt.Sprintf("\n[Process failed with exit code %d. Attempting restart %d/%d...]\n",
-				process.ExitCode, process.RestartCount+1, process.MaxRestarts)
-
-			process.stdout.WriteString(restartMsg)
-			process.logs.WriteString(restartMsg)
-
-			// Notify log writers about the restart
-			process.logLock.RLock()
-			for _, w := range process.logWriters {
-				_, _ = w.Write([]byte(restartMsg))
-				if f, ok := w.(interface{ Flush() }); ok {
-					f.Flush()
-				}
-			}
-			process.logLock.RUnlock()
-
-			// Increment restart count
-			process.RestartCount++
-
-			// Small delay before restart to avoid rapid restart loops
-			time.Sleep(1 * time.Second)
-
-			// Restart the process with updated restart count
-			// The PID remains the same across restarts for user transparency
-			_, restartErr := pm.restartProcess(process, callback)
-			if restartErr != nil {
-				// If restart fails, log the error and call the callback
-				errorMsg := fmt.Sprintf("\n[Failed to restart process: %v]\n", restartErr)
-				process.stdout.WriteString(errorMsg)
-				process.logs.WriteString(errorMsg)
-
-				// Clean up resources
-				process.logLock.Lock()
-				process.logWriters = nil // Clear all log writers
-				process.logLock.Unlock()
-
-				callback(process)
-			}
-			// If restart succeeds, the callback will be called when that process completes
-		} else {
-			// Clean up resources
-			process.logLock.Lock()
-			process.logWriters = nil // Clear all log writers
-			process.logLock.Unlock()
-
-			callback(process)
-		}
+		pm.handleExit(process, callback)
 	}()
 
 	return process.PID, nil
@@ -371,26 +519,16 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 	command := oldProcess.Command
 	workingDir := oldProcess.WorkingDir
 
-	// Always use shell to execute commands (same as StartProcessWithName)
-	// This ensures shell built-ins (cd, export, exit, alias) work properly
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "sh"
-	}
-
-	shellArgs := os.Getenv("SHELL_ARGS")
-	if shellArgs == "" {
-		shellArgs = "-c"
-	}
-
-	// Build command arguments
-	cmdArgs := []string{}
-	if shellArgs != "" {
-		cmdArgs = append(cmdArgs, strings.Fields(shellArgs)...)
-	}
-	cmdArgs = append(cmdArgs, command)
-
-	cmd := exec.Command(shell, cmdArgs...)
+	// Each run gets the timeout of the original process
+	cmd, cancelTimeout := shellCommand(command, time.Duration(oldProcess.Timeout)*time.Second, func() {
+		oldProcess.timedOut.Store(true)
+	})
+	started := false
+	defer func() {
+		if !started {
+			cancelTimeout()
+		}
+	}()
 
 	if workingDir != "" {
 		// Check if the working directory exists
@@ -402,14 +540,12 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 		cmd.Dir = workingDir
 	}
 
-	// Set up process group to ensure all child processes can be killed together
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
+	// Use the same environment and user as the original process
+	runAs := lib.RunAs{User: oldProcess.RunAsUser, Group: oldProcess.RunAsGroup}
+	if err := configureCommand(cmd, oldProcess.env, runAs); err != nil {
+		return "", err
 	}
 
-	// Use the same environment as the original process
-	cmd.Env = os.Environ()
-
 	// Set up stdout and stderr pipes
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -422,17 +558,22 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 	}
 
 	// Keep the existing process info but reset status
+	oldProcess.stateMu.Lock()
 	oldProcess.Status = StatusRunning
 	oldProcess.StartedAt = time.Now()
 	oldProcess.CompletedAt = nil
 	oldProcess.ExitCode = 0
+	oldProcess.stateMu.Unlock()
+	oldProcess.timedOut.Store(false)
 	oldProcess.stdoutPipe = stdoutPipe
 	oldProcess.stderrPipe = stderrPipe
+	oldProcess.recordExec(cmd)
 
 	// Start the process
 	if err := cmd.Start(); err != nil {
 		return "", err
 	}
+	started = true
 
 	// Update only the OS process PID for kill/stop operations
 	// Keep the user-facing PID (oldProcess.PID) unchanged for transparency
@@ -442,6 +583,8 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 	pm.mu.Lock()
 	pm.processes[oldProcess.PID] = oldProcess
 	pm.mu.Unlock()
+	pm.persist()
+	oldProcess.publishEvent(events.ProcessRestarted)
 
 	// WaitGroup to ensure stdout/stderr goroutines finish before marking process complete
 	var outputWg sync.WaitGroup
@@ -457,7 +600,14 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 				data := buf[:n]
 				oldProcess.logLock.Lock()
 				oldProcess.stdout.Write(data)
-				oldProcess.logs.Write(data)
+				oldProcess.logs.WriteStream(logStreamStdout, data)
+				if oldProcess.logFile != nil {
+					_, _ = oldProcess.logFile.Write(data)
+				}
+				if oldProcess.recorder != nil {
+					oldProcess.recorder.Output(logStreamStdout, data)
+				}
+				pm.notifyOutput(oldProcess, logStreamStdout, data)
 				// Send to any attached log writers, prefix with stdout:
 				for _, w := range oldProcess.logWriters {
 					fullMsg := append([]byte("stdout:"), data...)
@@ -484,7 +634,14 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 				data := buf[:n]
 				oldProcess.logLock.Lock()
 				oldProcess.stderr.Write(data)
-				oldProcess.logs.Write(data)
+				oldProcess.logs.WriteStream(logStreamStderr, data)
+				if oldProcess.logFile != nil {
+					_, _ = oldProcess.logFile.Write(data)
+				}
+				if oldProcess.recorder != nil {
+					oldProcess.recorder.Output(logStreamStderr, data)
+				}
+				pm.notifyOutput(oldProcess, logStreamStderr, data)
 				// Send to any attached log writers, prefix with stderr:
 				for _, w := range oldProcess.logWriters {
 					fullMsg := append([]byte("stderr:"), data...)
@@ -507,6 +664,7 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 		// buffered output on very fast exits.
 		outputWg.Wait()
 		err := cmd.Wait()
+		cancelTimeout()
 		now := time.Now()
 
 		// IMPORTANT: Release process resources immediately after Wait() to close pidfd
@@ -515,81 +673,190 @@ func (pm *ProcessManager) restartProcess(oldProcess *ProcessInfo, callback func(
 			_ = cmd.Process.Release()
 		}
 
-		oldProcess.CompletedAt = &now
-
-		// Determine exit status
-		if err != nil {
-			if oldProcess.Status != StatusStopped && oldProcess.Status != StatusKilled {
-				oldProcess.Status = StatusFailed
-			}
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				oldProcess.ExitCode = exitErr.ExitCode()
-			} else {
-				oldProcess.ExitCode = 1
-			}
-		} else {
-			oldProcess.Status = StatusCompleted
-			oldProcess.ExitCode = 0
-		}
+		oldProcess.setExited(err, now)
 
 		// Update process in memory (PID stays the same, just updating the entry)
 		pm.mu.Lock()
 		pm.processes[oldProcess.PID] = oldProcess
 		pm.mu.Unlock()
+		pm.persist()
 
-		// Check if we should restart again on failure
-		if oldProcess.Status == StatusFailed && oldProcess.RestartOnFailure && oldProcess.RestartCount < oldProcess.MaxRestarts {
-			// Log the failure and restart attempt
-			restartMsg := fmt.Sprintf("\n[Process failed with exit code %d. Attempting restart %d/%d...]\n",
-				oldProcess.ExitCode, oldProcess.RestartCount+1, oldProcess.MaxRestarts)
-
-			oldProcess.stdout.WriteString(restartMsg)
-			oldProcess.logs.WriteString(restartMsg)
-
-			// Notify log writers about the restart
-			oldProcess.logLock.RLock()
-			for _, w := range oldProcess.logWriters {
-				_, _ = w.Write([]byte(restartMsg))
-				if f, ok := w.(interface{ Flush() }); ok {
-					f.Flush()
-				}
-			}
-			oldProcess.logLock.RUnlock()
+		pm.handleExit(oldProcess, callback)
+	}()
 
-			// Increment restart count
-			oldProcess.RestartCount++
+	return oldProcess.PID, nil
+}
+
+// handleExit restarts an exited process according to its restart policy, after its
+// backoff delay, or completes it
+func (pm *ProcessManager) handleExit(process *ProcessInfo, callback func(process *ProcessInfo)) {
+	now := time.Now()
+	if !process.shouldRestart(now) {
+		pm.completeProcess(process, callback)
+		return
+	}
 
-			// Small delay before restart to avoid rapid restart loops
-			time.Sleep(1 * time.Second)
+	delay := process.nextRestartDelay(now.Sub(process.StartedAt))
+	attempt := process.recentRestarts(now) + 1
+
+	// Log the exit and restart attempt
+	state := process.State()
+	var restartMsg string
+	if state.Status == StatusFailed {
+		restartMsg = fmt.Sprintf("\n[Process failed with exit code %d. Attempting restart %d/%d in %s...]\n",
+			state.ExitCode, attempt, process.MaxRestarts, delay)
+	} else {
+		restartMsg = fmt.Sprintf("\n[Process exited with code %d. Attempting restart %d/%d in %s...]\n",
+			state.ExitCode, attempt, process.MaxRestarts, delay)
+	}
 
-			// Restart the process recursively
-			// The PID remains the same across restarts for user transparency
-			_, restartErr := pm.restartProcess(oldProcess, callback)
-			if restartErr != nil {
-				// If restart fails, log the error and call the callback
-				errorMsg := fmt.Sprintf("\n[Failed to restart process: %v]\n", restartErr)
-				oldProcess.stdout.WriteString(errorMsg)
-				oldProcess.logs.WriteString(errorMsg)
+	logging.ForProcess(process.PID).Infof("Process %s exited with code %d, restarting in %s (attempt %d/%d)",
+		process.PID, state.ExitCode, delay, attempt, process.MaxRestarts)
+	process.stdout.WriteString(restartMsg)
+	process.logs.WriteStream(logStreamStdout, []byte(restartMsg))
 
-				// Clean up resources
-				oldProcess.logLock.Lock()
-				oldProcess.logWriters = nil
-				oldProcess.logLock.Unlock()
+	// Notify log writers about the restart
+	process.logLock.RLock()
+	for _, w := range process.logWriters {
+		_, _ = w.Write([]byte(restartMsg))
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+	process.logLock.RUnlock()
+
+	// Wait for the backoff delay, unless the process is stopped in the meantime
+	if !process.waitRestart(delay) {
+		pm.completeProcess(process, callback)
+		return
+	}
+	process.RestartCount++
+	process.restartTimes = append(process.restartTimes, time.Now())
+
+	// The PID remains the same across restarts for user transparency
+	_, restartErr := pm.restartProcess(process, callback)
+	if restartErr != nil {
+		// If restart fails, log the error and call the callback
+		errorMsg := fmt.Sprintf("\n[Failed to restart process: %v]\n", restartErr)
+		process.stdout.WriteString(errorMsg)
+		process.logs.WriteStream(logStreamStdout, []byte(errorMsg))
+		pm.completeProcess(process, callback)
+	}
+	// If restart succeeds, the callback will be called when that process completes
+}
+
+// completeProcess releases the resources of a process that will not be restarted
+// and calls its callback
+func (pm *ProcessManager) completeProcess(process *ProcessInfo, callback func(process *ProcessInfo)) {
+	// Clean up resources
+	process.logLock.Lock()
+	process.logWriters = nil // Clear all log writers
+	process.logLock.Unlock()
+
+	state := process.State()
+	logging.ForProcess(process.PID).WithField("exitCode", state.ExitCode).Infof("Process %s terminated (status: %s)", process.PID, state.Status)
+	process.markDone()
+	process.publishEvent(events.ProcessExited)
+	callback(process)
+}
+
+// shellCommand returns the command running a command line in the shell, SHELL with
+// SHELL_ARGS when set, so that built-ins (cd, export, alias) work. With a timeout,
+// onTimeout is called and the whole process group killed once it expires. The returned
+// function releases the timeout and must be called once the command has exited, or
+// failed to start.
+func shellCommand(command string, timeout time.Duration, onTimeout func()) (*exec.Cmd, context.CancelFunc) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "sh"
+	}
+	shellArgs := os.Getenv("SHELL_ARGS")
+	if shellArgs == "" {
+		shellArgs = "-c"
+	}
+	cmdArgs := append(strings.Fields(shellArgs), command)
+
+	if timeout <= 0 {
+		return exec.Command(shell, cmdArgs...), func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	cmd := exec.CommandContext(ctx, shell, cmdArgs...)
+	cmd.Cancel = func() error {
+		onTimeout()
+		// The process leads its own group (Setpgid), kill its children along with it
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd, cancel
+}
+
+// configureCommand sets up the process group, environment and user of a command. Processes
+// running as another user get its HOME, USER and LOGNAME unless set in env.
+func configureCommand(cmd *exec.Cmd, env map[string]string, runAs lib.RunAs) error {
+	// Set up process group to ensure all child processes can be killed together
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	credential, err := runAs.Resolve()
+	if err != nil {
+		return fmt.Errorf("could not run as '%s': %w", runAs, err)
+	}
+	if credential != nil {
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    credential.UID,
+			Gid:    credential.GID,
+			Groups: credential.Groups,
+		}
+		userEnv := credential.Env()
+		for k, v := range env {
+			userEnv[k] = v
+		}
+		env = userEnv
+	}
+
+	cmd.Env = buildEnv(env)
+	return nil
+}
+
+// buildEnv returns the environment of a process, the system environment overridden
+// by the custom variables
+func buildEnv(env map[string]string) []string {
+	// Start with system environment
+	systemEnv := os.Environ()
+
+	// Build the final environment
+	finalEnv := make([]string, 0, len(systemEnv)+len(env))
 
-				callback(oldProcess)
+	// Add system environment variables that are not being overridden
+	for _, envVar := range systemEnv {
+		// Find the key part (everything before the first '=')
+		idx := strings.IndexByte(envVar, '=')
+		if idx > 0 {
+			if _, overridden := env[envVar[:idx]]; !overridden {
+				finalEnv = append(finalEnv, envVar)
 			}
-			// If restart succeeds, the callback will be called when that process completes
-		} else {
-			// Clean up resources
-			oldProcess.logLock.Lock()
-			oldProcess.logWriters = nil
-			oldProcess.logLock.Unlock()
-
-			callback(oldProcess)
 		}
-	}()
+	}
 
-	return oldProcess.PID, nil
+	// Add all custom environment variables
+	for k, v := range env {
+		finalEnv = append(finalEnv, k+"="+v)
+	}
+	return finalEnv
+}
+
+// uniquePIDLocked returns pid when no process of the table has it, or pid followed by
+// the first free "-<n>" suffix otherwise. The mutex must be held.
+func (pm *ProcessManager) uniquePIDLocked(pid string) string {
+	if _, exists := pm.processes[pid]; !exists {
+		return pid
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", pid, n)
+		if _, exists := pm.processes[candidate]; !exists {
+			return candidate
+		}
+	}
 }
 
 // GetProcessByIdentifier returns a process by either PID or name
@@ -597,29 +864,15 @@ func (pm *ProcessManager) GetProcessByIdentifier(identifier string) (*ProcessInf
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	// Try to convert identifier to int (PID)
-	if _, err := strconv.Atoi(identifier); err == nil {
-		// If conversion successful, try to get process by PID
-		process, exists := pm.processes[identifier]
-		if !exists {
-			return nil, false
-		}
-
-		// If the process is running, try to get additional information from the OS
-		if process.Status == StatusRunning {
-			pidInt, err := strconv.Atoi(process.PID)
-			if err == nil {
-				// Store the OS process PID for kill/stop operations
-				process.ProcessPid = pidInt
-			}
-		}
+	// PIDs are usually the OS PID, with a suffix when it was reused
+	if process, exists := pm.processes[identifier]; exists {
 		// Acquire logLock to safely read logs (they're written under this lock)
-		process.logLock.RLock()
+		process.logLock.Lock()
 		if process.logs != nil && process.logs.Len() > 0 {
 			logs := process.logs.String()
 			process.Logs = &logs
 		}
-		process.logLock.RUnlock()
+		process.logLock.Unlock()
 		return process, true
 	}
 	// Search by name - find the most recent process with this name
@@ -634,18 +887,46 @@ func (pm *ProcessManager) GetProcessByIdentifier(identifier string) (*ProcessInf
 
 	if latestProcess != nil {
 		// Acquire logLock to safely read logs (they're written under this lock)
-		latestProcess.logLock.RLock()
+		latestProcess.logLock.Lock()
 		if latestProcess.logs != nil {
 			logs := latestProcess.logs.String()
 			latestProcess.Logs = &logs
 		}
-		latestProcess.logLock.RUnlock()
+		latestProcess.logLock.Unlock()
 		return latestProcess, true
 	}
 
 	return nil, false
 }
 
+// WaitForProcess blocks until the process reaches a terminal state or the timeout expires.
+// It returns the process in its latest known state along with whether it has completed.
+func (pm *ProcessManager) WaitForProcess(ctx context.Context, identifier string, timeout time.Duration) (*ProcessInfo, bool, error) {
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return nil, false, apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	completed := false
+	select {
+	case <-process.Done():
+		completed = true
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	// Refresh to pick up the final logs
+	process, exists = pm.GetProcessByIdentifier(process.PID)
+	if !exists {
+		return nil, false, apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+	return process, completed, nil
+}
+
 // ListProcesses returns information about all processes
 func (pm *ProcessManager) ListProcesses() []*ProcessInfo {
 	pm.mu.RLock()
@@ -662,15 +943,21 @@ func (pm *ProcessManager) ListProcesses() []*ProcessInfo {
 func (pm *ProcessManager) StopProcess(identifier string) error {
 	process, exists := pm.GetProcessByIdentifier(identifier)
 	if !exists {
-		return fmt.Errorf("process with Identifier %s not found", identifier)
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+
+	// A process waiting to restart is stopped by cancelling the restart
+	if process.cancelRestart(StatusStopped) {
+		pm.persist()
+		return nil
 	}
 
-	if process.Status != StatusRunning {
-		return fmt.Errorf("process with Identifier %s is not running", identifier)
+	if process.State().Status != StatusRunning {
+		return apierror.Newf(apierror.CodeProcNotRunning, "process with Identifier %s is not running", identifier)
 	}
 
 	if process.ProcessPid == 0 {
-		return fmt.Errorf("process with Identifier %s has no OS process", identifier)
+		return apierror.Newf(apierror.CodeProcNotRunning, "process with Identifier %s has no OS process", identifier)
 	}
 
 	// Notify log writers about termination
@@ -699,7 +986,8 @@ func (pm *ProcessManager) StopProcess(identifier string) error {
 		}
 	}
 
-	process.Status = StatusStopped
+	process.setStatus(StatusStopped)
+	pm.persist()
 	return nil
 }
 
@@ -707,11 +995,16 @@ func (pm *ProcessManager) StopProcess(identifier string) error {
 func (pm *ProcessManager) KillProcess(identifier string) error {
 	process, exists := pm.GetProcessByIdentifier(identifier)
 	if !exists {
-		return fmt.Errorf("process with Identifier %s not found", identifier)
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+
+	if process.cancelRestart(StatusKilled) {
+		pm.persist()
+		return nil
 	}
 
 	if process.ProcessPid == 0 {
-		return fmt.Errorf("process with Identifier %s has no OS process", identifier)
+		return apierror.Newf(apierror.CodeProcNotRunning, "process with Identifier %s has no OS process", identifier)
 	}
 
 	// Notify log writers about forceful termination
@@ -743,36 +1036,90 @@ func (pm *ProcessManager) KillProcess(identifier string) error {
 	}
 
 	// Remove the process from memory
-	process.Status = StatusKilled
+	process.setStatus(StatusKilled)
+	pm.persist()
 	return nil
 }
 
 // GetProcessOutput returns the stdout and stderr output of a process
 func (pm *ProcessManager) GetProcessOutput(identifier string) (ProcessLogs, error) {
+	return pm.QueryProcessOutput(identifier, LogQuery{})
+}
+
+// QueryProcessOutput returns the portion of the output of a process selected by query.
+// When a stream is selected, only that stream is returned and the offsets refer to it.
+// Otherwise offset and limit apply to the combined logs, while tail and since apply to every stream.
+func (pm *ProcessManager) QueryProcessOutput(identifier string, query LogQuery) (ProcessLogs, error) {
 	process, exists := pm.GetProcessByIdentifier(identifier)
 	if !exists {
-		return ProcessLogs{}, fmt.Errorf("process with PID %s not found", identifier)
+		return ProcessLogs{}, apierror.Newf(apierror.CodeProcNotFound, "process with PID %s not found", identifier)
 	}
 
-	return ProcessLogs{
-		Stdout: process.stdout.String(),
-		Stderr: process.stderr.String(),
-		Logs:   process.logs.String(),
-	}, nil
+	droppedBytes := process.logs.DroppedBytes()
+	logs := ProcessLogs{
+		Truncated:    droppedBytes > 0 || process.stdout.DroppedBytes() > 0 || process.stderr.DroppedBytes() > 0,
+		DroppedBytes: droppedBytes,
+		LogFile:      process.logs.SpillPath(),
+	}
+
+	switch query.Stream {
+	case "":
+		// Offsets of the combined logs do not match the ones of each stream
+		streamQuery := query
+		streamQuery.Offset, streamQuery.Limit = 0, 0
+		logs.Stdout, _, _ = process.stdout.Query(streamQuery)
+		logs.Stderr, _, _ = process.stderr.Query(streamQuery)
+		logs.Logs, logs.Offset, logs.NextOffset = process.logs.Query(query)
+	case "stdout":
+		logs.Stdout, logs.Offset, logs.NextOffset = process.stdout.Query(query)
+	case "stderr":
+		logs.Stderr, logs.Offset, logs.NextOffset = process.stderr.Query(query)
+	default:
+		return ProcessLogs{}, fmt.Errorf("invalid stream '%s', expected 'stdout' or 'stderr'", query.Stream)
+	}
+	if query.Timestamps {
+		logs.Entries = process.logEntries(query)
+	}
+	return logs, nil
 }
 
-func (pm *ProcessManager) StreamProcessOutput(identifier string, w io.Writer) error {
+// logEntries returns the output of a process selected by query as timestamped entries
+func (p *ProcessInfo) logEntries(query LogQuery) []ProcessLogEntry {
+	buffer := p.logs
+	switch query.Stream {
+	case "stdout":
+		buffer = p.stdout
+	case "stderr":
+		buffer = p.stderr
+	}
+	frames, _, _ := buffer.QueryFrames(query)
+	entries := make([]ProcessLogEntry, 0, len(frames))
+	for _, frame := range frames {
+		entry := ProcessLogEntry{Ts: frame.At, Stream: frame.Stream, Text: frame.Data}
+		if query.Stream != "" {
+			entry.Stream = query.Stream
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// StreamProcessOutput writes the buffered output of a process to w, then the output
+// written afterwards until the returned stream is closed. The output is queued for w,
+// applying the overflow policy when w does not keep up, see LogStream. A keepalive line
+// is written every 30 seconds until the process is done.
+func (pm *ProcessManager) StreamProcessOutput(identifier string, w io.Writer, policy OverflowPolicy) (*LogStream, error) {
 	process, exists := pm.GetProcessByIdentifier(identifier)
 	if !exists {
-		return fmt.Errorf("process with Identifier %s not found", identifier)
+		return nil, apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
 	}
 
-	// Write current content first
-	_, _ = w.Write([]byte(process.logs.String()))
-
-	// Attach writer for future output
+	// Write current content first, the buffered output being attached at once so that
+	// none is missed or sent twice
+	stream := newLogStream(process, w, policy, logStreamQueueBytesFromEnv())
 	process.logLock.Lock()
-	process.logWriters = append(process.logWriters, w)
+	_, _ = stream.Write([]byte(process.logs.String()))
+	process.logWriters = append(process.logWriters, stream)
 	process.logLock.Unlock()
 
 	// Start keepalive goroutine to prevent connection timeout
@@ -780,29 +1127,29 @@ func (pm *ProcessManager) StreamProcessOutput(identifier string, w io.Writer) er
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			process, exists = pm.GetProcessByIdentifier(identifier)
-			// Check if process is still running
-			if !exists || process.Status != StatusRunning {
+		for {
+			select {
+			case <-process.Done():
+				return
+			case <-ticker.C:
+			}
+			if !process.hasLogWriter(stream) {
 				return
 			}
 			// Send keepalive message only to this specific writer
 			keepaliveMsg := []byte("[keepalive]\n")
-			_, _ = w.Write(keepaliveMsg)
-			if f, ok := w.(interface{ Flush() }); ok {
-				f.Flush()
-			}
+			_, _ = stream.Write(keepaliveMsg)
 		}
 	}()
 
-	return nil
+	return stream, nil
 }
 
 // RemoveLogWriter removes a writer from a process's log writers list
 func (pm *ProcessManager) RemoveLogWriter(identifier string, w io.Writer) error {
 	process, exists := pm.GetProcessByIdentifier(identifier)
 	if !exists {
-		return fmt.Errorf("process with Identifier %s not found", identifier)
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
 	}
 
 	process.logLock.Lock()
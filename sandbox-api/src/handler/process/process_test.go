@@ -1,9 +1,13 @@
 package process
 
 import (
+	"context"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
 )
 
 // TestProcessManagerIntegration tests the complete functionality of the process manager
@@ -668,3 +672,146 @@ func TestProcessRestartOnFailure(t *testing.T) {
 		}
 	})
 }
+
+// TestWaitForProcess tests blocking until a process completes
+func TestWaitForProcess(t *testing.T) {
+	pm := GetProcessManager()
+
+	t.Run("CompletesBeforeTimeout", func(t *testing.T) {
+		pid, err := pm.StartProcess("sleep 0.2; echo done", "", nil, false, 0, func(process *ProcessInfo) {})
+		if err != nil {
+			t.Fatalf("Error starting process: %v", err)
+		}
+
+		process, completed, err := pm.WaitForProcess(context.Background(), pid, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Error waiting for process: %v", err)
+		}
+		if !completed {
+			t.Fatal("Expected process to complete before timeout")
+		}
+		if process.Status != StatusCompleted {
+			t.Errorf("Expected process to be completed, got status: %s", process.Status)
+		}
+		if process.Logs == nil || !strings.Contains(*process.Logs, "done") {
+			t.Error("Expected logs to contain process output")
+		}
+	})
+
+	t.Run("TimeoutReturnsRunningProcess", func(t *testing.T) {
+		pid, err := pm.StartProcess("sleep 5", "", nil, false, 0, func(process *ProcessInfo) {})
+		if err != nil {
+			t.Fatalf("Error starting process: %v", err)
+		}
+		defer func() { _ = pm.KillProcess(pid) }()
+
+		process, completed, err := pm.WaitForProcess(context.Background(), pid, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Error waiting for process: %v", err)
+		}
+		if completed {
+			t.Error("Expected wait to time out")
+		}
+		if process.Status != StatusRunning {
+			t.Errorf("Expected process to still be running, got status: %s", process.Status)
+		}
+	})
+
+	t.Run("UnknownProcess", func(t *testing.T) {
+		if _, _, err := pm.WaitForProcess(context.Background(), "does-not-exist", time.Second); err == nil {
+			t.Error("Expected error for unknown process, but got none")
+		}
+	})
+}
+
+// TestProcessTimeout tests killing a process and its children once its timeout expires
+func TestProcessTimeout(t *testing.T) {
+	pm := GetProcessManager()
+
+	start := time.Now()
+	processInfo, err := pm.ExecuteProcess("sleep 30 & echo started; wait", "", "", nil, lib.RunAs{}, true, 1, nil, "", RestartConfig{Policy: RestartPolicyOnFailure, MaxRestarts: 3}, false, false)
+	if err != nil {
+		t.Fatalf("Error executing process: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the process to be killed after 1 second, took %s", elapsed)
+	}
+	if processInfo.Status != StatusTimedOut || processInfo.Timeout != 1 || processInfo.RestartCount != 0 {
+		t.Errorf("Expected a timed out process which is not restarted, got status %s, timeout %d, %d restarts", processInfo.Status, processInfo.Timeout, processInfo.RestartCount)
+	}
+	if processInfo.Logs == nil || !strings.Contains(*processInfo.Logs, "started") {
+		t.Error("Expected the logs of the timed out process")
+	}
+
+	// Processes completing in time are not affected
+	processInfo, err = pm.ExecuteProcess("echo quick", "", "", nil, lib.RunAs{}, true, 5, nil, "", RestartConfig{}, false, false)
+	if err != nil || processInfo.Status != StatusCompleted {
+		t.Errorf("Expected the process to complete, got %v (%v)", processInfo, err)
+	}
+}
+
+// TestParseSignal tests parsing signal names and numbers
+func TestParseSignal(t *testing.T) {
+	for value, want := range map[string]syscall.Signal{
+		"SIGHUP": syscall.SIGHUP,
+		"usr1":   syscall.SIGUSR1,
+		" Term ": syscall.SIGTERM,
+		"9":      syscall.SIGKILL,
+		"40":     syscall.Signal(40),
+	} {
+		if signal, err := ParseSignal(value); err != nil || signal != want {
+			t.Errorf("Expected %q to be %v, got %v (%v)", value, want, signal, err)
+		}
+	}
+	for _, value := range []string{"", "0", "65", "-1", "SIGNOPE"} {
+		if _, err := ParseSignal(value); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
+
+// TestSignalProcess tests sending signals to processes, and killing processes ignoring SIGTERM
+func TestSignalProcess(t *testing.T) {
+	pm := GetProcessManager()
+
+	script := "trap 'echo reloaded' HUP; trap '' TERM; echo ready; while true; do sleep 0.1; done"
+	processInfo, err := pm.ExecuteProcess(script, "", "", nil, lib.RunAs{}, false, 0, nil, "ready", RestartConfig{}, false, false)
+	if err != nil {
+		t.Fatalf("Error executing process: %v", err)
+	}
+
+	if err := pm.SignalProcess(processInfo.PID, syscall.SIGHUP); err != nil {
+		t.Fatalf("Error signaling process: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs, err := pm.GetProcessOutput(processInfo.PID)
+		if err == nil && strings.Contains(logs.Stdout, "reloaded") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the process to handle SIGHUP")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if process, _ := pm.GetProcessByIdentifier(processInfo.PID); process.Status != StatusRunning {
+		t.Errorf("Expected the process to keep running, got %s", process.Status)
+	}
+
+	if err := pm.StopProcessWithTimeout(processInfo.PID, time.Second); err != nil {
+		t.Fatalf("Error stopping process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(processInfo.PID)
+	select {
+	case <-process.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the process ignoring SIGTERM to be killed")
+	}
+	if process.Status != StatusKilled {
+		t.Errorf("Expected the process to be killed, got %s", process.Status)
+	}
+
+	if err := pm.SignalProcess(processInfo.PID, syscall.SIGHUP); err == nil {
+		t.Error("Expected an error signaling a terminated process")
+	}
+}
@@ -0,0 +1,127 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// DefaultReadinessTimeout is the number of seconds to wait for a readiness condition when none is given
+const DefaultReadinessTimeout = 60
+
+// readinessPollInterval is the delay between two readiness checks
+const readinessPollInterval = 100 * time.Millisecond
+
+// ReadinessCondition describes when a started process is considered ready.
+// All the given conditions must be met.
+type ReadinessCondition struct {
+	Ports      []int  `json:"ports" example:"5432"`
+	LogPattern string `json:"logPattern" example:"ready to accept connections"`
+	Timeout    int    `json:"timeout" example:"60"`
+} // @name ReadinessCondition
+
+// IsEmpty returns true when the condition has nothing to wait for
+func (c ReadinessCondition) IsEmpty() bool {
+	return len(c.Ports) == 0 && c.LogPattern == ""
+}
+
+// Validate checks the ports and compiles the log pattern of the condition
+func (c ReadinessCondition) Validate() error {
+	for _, port := range c.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if c.LogPattern != "" {
+		if _, err := regexp.Compile(c.LogPattern); err != nil {
+			return fmt.Errorf("invalid log pattern: %w", err)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: must be a positive number of seconds")
+	}
+	return nil
+}
+
+// WaitForReady blocks until the process meets the readiness condition.
+// It fails if the process exits first, or if the condition timeout or ctx expires.
+// Ports are considered open once a TCP connection to localhost succeeds.
+func (pm *ProcessManager) WaitForReady(ctx context.Context, identifier string, condition ReadinessCondition) error {
+	if err := condition.Validate(); err != nil {
+		return err
+	}
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+
+	timeout := condition.Timeout
+	if timeout == 0 {
+		timeout = DefaultReadinessTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var pattern *regexp.Regexp
+	if condition.LogPattern != "" {
+		pattern = regexp.MustCompile(condition.LogPattern)
+	}
+	pendingPorts := append([]int(nil), condition.Ports...)
+	var scanned int64
+	exited := false
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if pattern != nil {
+			content, from, _ := process.logs.Query(LogQuery{Offset: scanned})
+			if pattern.MatchString(content) {
+				pattern = nil
+			} else if i := strings.LastIndexByte(content, '\n'); i >= 0 {
+				// Only rescan the last incomplete line on the next check
+				scanned = from + int64(i) + 1
+			}
+		}
+
+		remaining := pendingPorts[:0]
+		for _, port := range pendingPorts {
+			if !isPortOpen(port) {
+				remaining = append(remaining, port)
+			}
+		}
+		pendingPorts = remaining
+
+		if pattern == nil && len(pendingPorts) == 0 {
+			return nil
+		}
+		if exited {
+			return fmt.Errorf("process %s exited before becoming ready", identifier)
+		}
+
+		select {
+		case <-process.Done():
+			// Check the final output once more before giving up
+			exited = true
+		case <-ctx.Done():
+			return fmt.Errorf("process %s did not become ready within %d seconds", identifier, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// isPortOpen returns true if a TCP connection to the local port succeeds
+func isPortOpen(port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)), readinessPollInterval)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
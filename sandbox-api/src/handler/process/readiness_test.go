@@ -0,0 +1,56 @@
+package process
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// TestWaitForReady tests log pattern and port readiness conditions
+func TestWaitForReady(t *testing.T) {
+	pm := GetProcessManager()
+
+	t.Run("LogPattern", func(t *testing.T) {
+		processInfo, err := pm.ExecuteProcess("sleep 0.2; echo 'Listening on 8080'; sleep 5", "", "", nil, lib.RunAs{}, false, 5, nil, `Listening on \d+`, RestartConfig{}, false, false)
+		if err != nil {
+			t.Fatalf("Failed to execute process: %v", err)
+		}
+		defer func() { _ = pm.KillProcess(processInfo.PID) }()
+
+		if processInfo.Status != StatusRunning {
+			t.Errorf("Expected process to still be running, got %s", processInfo.Status)
+		}
+		if processInfo.Logs == nil || !strings.Contains(*processInfo.Logs, "Listening on 8080") {
+			t.Errorf("Expected logs to contain the pattern, got %v", processInfo.Logs)
+		}
+	})
+
+	t.Run("ExitsBeforeReady", func(t *testing.T) {
+		_, err := pm.ExecuteProcess("echo starting", "", "", nil, lib.RunAs{}, false, 5, nil, "ready", RestartConfig{}, false, false)
+		if err == nil {
+			t.Error("Expected error when the process exits before matching, but got none")
+		}
+	})
+
+	t.Run("Port", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer func() { _ = listener.Close() }()
+		port := listener.Addr().(*net.TCPAddr).Port
+
+		pid, err := pm.StartProcess("sleep 5", "", nil, false, 0, func(*ProcessInfo) {})
+		if err != nil {
+			t.Fatalf("Failed to start process: %v", err)
+		}
+		defer func() { _ = pm.KillProcess(pid) }()
+
+		if err := pm.WaitForReady(context.Background(), pid, ReadinessCondition{Ports: []int{port}, Timeout: 5}); err != nil {
+			t.Errorf("Expected port %d to be detected as open: %v", port, err)
+		}
+	})
+}
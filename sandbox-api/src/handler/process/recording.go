@@ -0,0 +1,302 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Recordings replay the output of processes in a terminal, in the asciicast v2 format of
+// asciinema: a JSON header line, then one [seconds, "o", data] line per output chunk.
+
+// Default size of the terminal of the recordings of processes not setting COLUMNS and LINES
+const (
+	DefaultRecordingWidth  = 80
+	DefaultRecordingHeight = 24
+)
+
+// recordingExtension is the extension of the recording files
+const recordingExtension = ".cast"
+
+// recordingIDPattern matches the ids of the recordings, <pid>-<start unix time>
+var recordingIDPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// ErrRecordingNotFound is returned for an unknown recording
+var ErrRecordingNotFound = apierror.New(apierror.CodeNotFound, "recording not found")
+
+// Recording describes the recording of a process
+type Recording struct {
+	// ID is the name of the recording file, without its .cast extension
+	ID         string    `json:"id" example:"1234-1700000000" binding:"required"`
+	ProcessPID string    `json:"processPid" example:"1234" binding:"required"`
+	Name       string    `json:"name" example:"my-process" binding:"required"`
+	Command    string    `json:"command" example:"npm test" binding:"required"`
+	StartedAt  time.Time `json:"startedAt" binding:"required"`
+	// Size is the size of the recording file in bytes, growing while the process runs
+	Size int64 `json:"size" example:"4096" binding:"required"`
+} // @name Recording
+
+// recordingHeader is the header line of an asciicast v2 file
+type recordingHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes the output of a process to an asciicast v2 file
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	start   time.Time
+	pending map[string][]byte
+}
+
+// NewRecorder creates the recording file of a process at path, with the size of the
+// terminal of its environment
+func NewRecorder(path string, p *ProcessInfo) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{file: file, start: p.StartedAt, pending: make(map[string][]byte)}
+
+	header := recordingHeader{
+		Version:   2,
+		Width:     envInt(p.env, "COLUMNS", DefaultRecordingWidth),
+		Height:    envInt(p.env, "LINES", DefaultRecordingHeight),
+		Timestamp: p.StartedAt.Unix(),
+		Command:   p.Command,
+		Title:     p.Name,
+		Env:       map[string]string{"SHELL": "/bin/sh", "TERM": "xterm-256color"},
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err := r.writeLine(line); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output records output read from a stream of the process. Processes write to pipes
+// rather than a terminal, so newlines are recorded as the CRLF a terminal would print.
+func (r *Recorder) Output(stream string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Multi-byte characters split across reads are recorded once complete
+	data = append(r.pending[stream], data...)
+	data, r.pending[stream] = splitIncompleteRune(data)
+	r.event(data)
+}
+
+// Close records what is left of the output and closes the file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for stream, data := range r.pending {
+		r.event(data)
+		delete(r.pending, stream)
+	}
+	return r.file.Close()
+}
+
+// event writes an output event with the time elapsed since the start of the process.
+// r.mu is held by the caller.
+func (r *Recorder) event(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	data = bytes.ReplaceAll(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+	elapsed := time.Since(r.start).Seconds()
+	line, err := json.Marshal([]any{float64(int64(elapsed*1e6)) / 1e6, "o", string(data)})
+	if err != nil {
+		return
+	}
+	// Written unbuffered for the recording to be downloadable while the process runs
+	if err := r.writeLine(line); err != nil {
+		logrus.Warnf("Failed to write recording %s: %v", r.file.Name(), err)
+	}
+}
+
+func (r *Recorder) writeLine(line []byte) error {
+	_, err := r.file.Write(append(line, '\n'))
+	return err
+}
+
+// splitIncompleteRune splits p before a multi-byte character it ends in the middle of
+func splitIncompleteRune(p []byte) ([]byte, []byte) {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return p[:i], append([]byte(nil), p[i:]...)
+			}
+			break
+		}
+	}
+	return p, nil
+}
+
+// envInt returns the positive integer value of an environment variable of a process, or
+// defaultValue
+func envInt(env map[string]string, name string, defaultValue int) int {
+	value, err := strconv.Atoi(env[name])
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// recordFromEnv returns whether every process is recorded, read from PROCESS_RECORD
+func recordFromEnv() bool {
+	value := os.Getenv("PROCESS_RECORD")
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Invalid PROCESS_RECORD value '%s', processes are only recorded when asked to", value)
+		return false
+	}
+	return enabled
+}
+
+// RecordingsDir returns the directory of the recordings of processes, read from
+// RECORDINGS_DIR and defaulting to sandbox-recordings in the temp directory
+func RecordingsDir() string {
+	if dir := os.Getenv("RECORDINGS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "sandbox-recordings")
+}
+
+// recordingID returns the id of the recording of a process
+func recordingID(p *ProcessInfo) string {
+	return fmt.Sprintf("%s-%d", p.PID, p.StartedAt.Unix())
+}
+
+// initRecorder starts recording a process asked to be recorded. A recording which can't
+// be created is logged, the process running without it.
+func (p *ProcessInfo) initRecorder() {
+	if !p.Record {
+		return
+	}
+	id := recordingID(p)
+	recorder, err := NewRecorder(recordingPath(id), p)
+	if err != nil {
+		logrus.Warnf("Failed to record process %s: %v", p.PID, err)
+		return
+	}
+	p.recorder = recorder
+	p.Recording = id
+}
+
+// ListRecordings returns the recordings of the processes, oldest first
+func ListRecordings() ([]Recording, error) {
+	entries, err := os.ReadDir(RecordingsDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Recording{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := make([]Recording, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), recordingExtension)
+		if !ok || !recordingIDPattern.MatchString(id) {
+			continue
+		}
+		recording, err := readRecording(id)
+		if err != nil {
+			logrus.Debugf("Skipping recording %s: %v", entry.Name(), err)
+			continue
+		}
+		recordings = append(recordings, recording)
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt.Before(recordings[j].StartedAt)
+	})
+	return recordings, nil
+}
+
+// OpenRecording opens the file of a recording
+func OpenRecording(id string) (*os.File, Recording, error) {
+	if !recordingIDPattern.MatchString(id) {
+		return nil, Recording{}, ErrRecordingNotFound
+	}
+	recording, err := readRecording(id)
+	if err != nil {
+		return nil, Recording{}, err
+	}
+	file, err := os.Open(recordingPath(id))
+	if err != nil {
+		return nil, Recording{}, err
+	}
+	return file, recording, nil
+}
+
+func recordingPath(id string) string {
+	return filepath.Join(RecordingsDir(), id+recordingExtension)
+}
+
+// readRecording describes a recording from its file name and header
+func readRecording(id string) (Recording, error) {
+	file, err := os.Open(recordingPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Recording{}, ErrRecordingNotFound
+	}
+	if err != nil {
+		return Recording{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Recording{}, err
+	}
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return Recording{}, fmt.Errorf("reading header: %w", err)
+	}
+	var header recordingHeader
+	if err := json.Unmarshal(line, &header); err != nil || header.Version != 2 {
+		return Recording{}, fmt.Errorf("invalid asciicast v2 header")
+	}
+	pid, _, _ := strings.Cut(id, "-")
+	return Recording{
+		ID:         id,
+		ProcessPID: pid,
+		Name:       header.Title,
+		Command:    header.Command,
+		StartedAt:  time.Unix(header.Timestamp, 0).UTC(),
+		Size:       info.Size(),
+	}, nil
+}
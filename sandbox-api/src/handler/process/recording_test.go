@@ -0,0 +1,89 @@
+package process
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// TestRecorder tests that the output is recorded as asciicast v2 events, with complete
+// characters and terminal newlines
+func TestRecorder(t *testing.T) {
+	t.Setenv("RECORDINGS_DIR", t.TempDir())
+	process := &ProcessInfo{PID: "42", Name: "build", Command: "make", StartedAt: time.Now(), Record: true, env: map[string]string{"COLUMNS": "120"}}
+	process.initRecorder()
+	if process.recorder == nil || process.Recording == "" {
+		t.Fatal("Expected the process to be recorded")
+	}
+
+	euro := []byte("€")
+	process.recorder.Output(logStreamStdout, append([]byte("price: "), euro[:1]...))
+	process.recorder.Output(logStreamStderr, []byte("warning\n"))
+	process.recorder.Output(logStreamStdout, append(euro[1:], '\n'))
+	if err := process.recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	file, recording, err := OpenRecording(process.Recording)
+	if err != nil {
+		t.Fatalf("Failed to open recording: %v", err)
+	}
+	defer file.Close()
+	if recording.ProcessPID != "42" || recording.Name != "build" || recording.Command != "make" {
+		t.Errorf("Unexpected recording %+v", recording)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	var header recordingHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Version != 2 || header.Width != 120 || header.Height != DefaultRecordingHeight {
+		t.Errorf("Unexpected header %s (%v)", scanner.Text(), err)
+	}
+	var output []string
+	for scanner.Scan() {
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 || event[1] != "o" {
+			t.Fatalf("Unexpected event %s (%v)", scanner.Text(), err)
+		}
+		output = append(output, event[2].(string))
+	}
+	if got := strings.Join(output, "|"); got != "price: |warning\r\n|€\r\n" {
+		t.Errorf("Unexpected output %q", got)
+	}
+}
+
+// TestProcessRecord tests that a process started with record is listed in the recordings
+func TestProcessRecord(t *testing.T) {
+	t.Setenv("RECORDINGS_DIR", t.TempDir())
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcessWithRestart("echo recorded", "", "recorded", nil, lib.RunAs{}, 0, RestartConfig{}, false, true, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(pid)
+	select {
+	case <-process.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the process to be done")
+	}
+
+	recordings, err := ListRecordings()
+	if err != nil || len(recordings) != 1 || recordings[0].ID != process.Recording || recordings[0].ProcessPID != pid {
+		t.Fatalf("Expected the recording of %s, got %+v (%v)", pid, recordings, err)
+	}
+	content, _ := os.ReadFile(recordingPath(process.Recording))
+	if !strings.Contains(string(content), `"recorded\r\n"`) {
+		t.Errorf("Expected the output to be recorded, got %s", content)
+	}
+
+	if _, _, err := OpenRecording("../" + process.Recording); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("Expected an invalid id not to be found, got %v", err)
+	}
+}
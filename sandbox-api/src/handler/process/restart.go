@@ -0,0 +1,218 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+)
+
+// RestartPolicy tells when an exited process is restarted
+type RestartPolicy string
+
+// Restart policies
+const (
+	RestartPolicyNever     RestartPolicy = "never"
+	RestartPolicyOnFailure RestartPolicy = "on-failure"
+	RestartPolicyAlways    RestartPolicy = "always"
+)
+
+const (
+	// maxRestartsLimit is the largest number of restarts of a process, within the restart
+	// window when one is set
+	maxRestartsLimit = 25
+	// defaultRestartDelay is the delay before restarts when no backoff is configured
+	defaultRestartDelay = time.Second
+	// Defaults of the backoff fields left to zero
+	defaultBackoffInitialMs  = 1000
+	defaultBackoffMultiplier = 2
+	defaultBackoffMaxMs      = 60000
+)
+
+// BackoffConfig is the exponential backoff between restarts of a process
+type BackoffConfig struct {
+	InitialMs  int     `json:"initialMs" example:"1000"` // delay before the first restart (default: 1000)
+	Multiplier float64 `json:"multiplier" example:"2"`   // factor applied to the delay on each restart (default: 2)
+	MaxMs      int     `json:"maxMs" example:"60000"`    // largest delay between restarts (default: 60000)
+} // @name ProcessBackoff
+
+// RestartConfig tells how an exited process is restarted
+type RestartConfig struct {
+	Policy RestartPolicy
+	// MaxRestarts is the number of restarts allowed, within Window when set
+	MaxRestarts int
+	// Backoff is the backoff between restarts, restarts are delayed by one second when nil
+	Backoff *BackoffConfig
+	// Window is the sliding window MaxRestarts applies to, zero for the lifetime of the process
+	Window time.Duration
+}
+
+// NewRestartConfig validates restart settings and fills in their defaults. The policy
+// defaults to on-failure when restartOnFailure is set, and to never otherwise. When a
+// policy is given, maxRestarts defaults to the limit of 25 restarts.
+func NewRestartConfig(policy string, restartOnFailure bool, maxRestarts int, backoff *BackoffConfig, windowSeconds int) (RestartConfig, error) {
+	config := RestartConfig{
+		Policy:      RestartPolicy(policy),
+		MaxRestarts: maxRestarts,
+		Window:      time.Duration(windowSeconds) * time.Second,
+	}
+
+	switch config.Policy {
+	case "":
+		config.Policy = RestartPolicyNever
+		if restartOnFailure {
+			config.Policy = RestartPolicyOnFailure
+		}
+	case RestartPolicyNever, RestartPolicyOnFailure, RestartPolicyAlways:
+		if maxRestarts == 0 {
+			config.MaxRestarts = maxRestartsLimit
+		}
+	default:
+		return config, fmt.Errorf("invalid restartPolicy '%s': must be never, on-failure or always", policy)
+	}
+	if maxRestarts < 0 {
+		return config, fmt.Errorf("invalid maxRestarts: must not be negative")
+	}
+	if windowSeconds < 0 {
+		return config, fmt.Errorf("invalid restartWindow: must not be negative")
+	}
+
+	if backoff != nil {
+		filled := *backoff
+		if filled.InitialMs < 0 || filled.MaxMs < 0 || filled.Multiplier < 0 {
+			return config, fmt.Errorf("invalid backoff: values must not be negative")
+		}
+		if filled.InitialMs == 0 {
+			filled.InitialMs = defaultBackoffInitialMs
+		}
+		if filled.Multiplier == 0 {
+			filled.Multiplier = defaultBackoffMultiplier
+		}
+		if filled.MaxMs == 0 {
+			filled.MaxMs = max(defaultBackoffMaxMs, filled.InitialMs)
+		}
+		if filled.Multiplier < 1 {
+			return config, fmt.Errorf("invalid backoff: multiplier must be at least 1")
+		}
+		if filled.MaxMs < filled.InitialMs {
+			return config, fmt.Errorf("invalid backoff: maxMs must be at least initialMs")
+		}
+		config.Backoff = &filled
+	}
+	return config, nil
+}
+
+// shouldRestart reports whether an exited process is restarted by its policy at now
+func (p *ProcessInfo) shouldRestart(now time.Time) bool {
+	status := p.State().Status
+	switch p.RestartPolicy {
+	case RestartPolicyAlways:
+		if status != StatusFailed && status != StatusCompleted {
+			return false
+		}
+	case RestartPolicyOnFailure:
+		if status != StatusFailed {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return p.recentRestarts(now) < p.MaxRestarts
+}
+
+// recentRestarts returns the number of restarts of a process within its restart window
+// at now, or since it was started when no window is set. Older restarts are forgotten.
+func (p *ProcessInfo) recentRestarts(now time.Time) int {
+	if p.restartWindow == 0 {
+		return p.RestartCount
+	}
+	recent := p.restartTimes[:0]
+	for _, restartedAt := range p.restartTimes {
+		if now.Sub(restartedAt) < p.restartWindow {
+			recent = append(recent, restartedAt)
+		}
+	}
+	p.restartTimes = recent
+	return len(recent)
+}
+
+// nextRestartDelay returns the delay before the next restart of a process whose last
+// run lasted ranFor. The backoff is reset after a run lasting longer than the restart
+// window, or than the largest delay when no window is set.
+func (p *ProcessInfo) nextRestartDelay(ranFor time.Duration) time.Duration {
+	if p.Backoff == nil {
+		return defaultRestartDelay
+	}
+
+	initial := time.Duration(p.Backoff.InitialMs) * time.Millisecond
+	maxDelay := time.Duration(p.Backoff.MaxMs) * time.Millisecond
+	resetAfter := p.restartWindow
+	if resetAfter == 0 {
+		resetAfter = maxDelay
+	}
+
+	previous := p.restartDelay
+	if previous == 0 || ranFor >= resetAfter {
+		return initial
+	}
+	return min(time.Duration(float64(previous)*p.Backoff.Multiplier), maxDelay)
+}
+
+// RestartWindow returns the sliding window the restart limit of a process applies to,
+// zero for its lifetime
+func (p *ProcessInfo) RestartWindow() time.Duration {
+	return p.restartWindow
+}
+
+// BackoffState returns the delay before the latest restart of a process, and when it
+// restarts next while it is waiting to
+func (p *ProcessInfo) BackoffState() (time.Duration, *time.Time) {
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+	return p.restartDelay, p.nextRestartAt
+}
+
+// waitRestart waits for delay before a restart. It returns false when the restart was
+// cancelled by stopping or killing the process in the meantime.
+func (p *ProcessInfo) waitRestart(delay time.Duration) bool {
+	cancel := make(chan struct{})
+	nextRestartAt := time.Now().Add(delay)
+
+	p.restartMu.Lock()
+	p.restartCancel = cancel
+	p.nextRestartAt = &nextRestartAt
+	p.restartDelay = delay
+	p.restartMu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	restart := true
+	select {
+	case <-timer.C:
+	case <-cancel:
+		restart = false
+	}
+
+	p.restartMu.Lock()
+	p.restartCancel = nil
+	p.nextRestartAt = nil
+	p.restartMu.Unlock()
+	return restart
+}
+
+// cancelRestart cancels the pending restart of a process, setting its status. It
+// returns false when no restart is pending.
+func (p *ProcessInfo) cancelRestart(status constants.ProcessStatus) bool {
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+
+	if p.restartCancel == nil {
+		return false
+	}
+	p.setStatus(status)
+	close(p.restartCancel)
+	p.restartCancel = nil
+	return true
+}
@@ -0,0 +1,164 @@
+package process
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// TestNewRestartConfig tests the validation and defaults of restart settings
+func TestNewRestartConfig(t *testing.T) {
+	config, err := NewRestartConfig("", true, 3, nil, 0)
+	if err != nil || config.Policy != RestartPolicyOnFailure || config.MaxRestarts != 3 || config.Backoff != nil {
+		t.Errorf("Unexpected legacy config %+v (%v)", config, err)
+	}
+	config, err = NewRestartConfig("", false, 0, nil, 0)
+	if err != nil || config.Policy != RestartPolicyNever {
+		t.Errorf("Unexpected default config %+v (%v)", config, err)
+	}
+
+	config, err = NewRestartConfig("always", false, 0, &BackoffConfig{InitialMs: 500}, 60)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if config.MaxRestarts != maxRestartsLimit || config.Window != time.Minute {
+		t.Errorf("Unexpected config %+v", config)
+	}
+	if config.Backoff.InitialMs != 500 || config.Backoff.Multiplier != 2 || config.Backoff.MaxMs != 60000 {
+		t.Errorf("Expected backoff defaults to be filled in, got %+v", config.Backoff)
+	}
+
+	invalid := []struct {
+		policy  string
+		backoff *BackoffConfig
+		window  int
+	}{
+		{"sometimes", nil, 0},
+		{"always", nil, -1},
+		{"always", &BackoffConfig{Multiplier: 0.5}, 0},
+		{"always", &BackoffConfig{InitialMs: 2000, MaxMs: 1000}, 0},
+		{"always", &BackoffConfig{InitialMs: -1}, 0},
+	}
+	for _, tc := range invalid {
+		if _, err := NewRestartConfig(tc.policy, false, 0, tc.backoff, tc.window); err == nil {
+			t.Errorf("Expected error for %+v, but got none", tc)
+		}
+	}
+}
+
+// TestRestartBackoff tests the delays between restarts and the restart window
+func TestRestartBackoff(t *testing.T) {
+	process := &ProcessInfo{
+		RestartPolicy: RestartPolicyOnFailure,
+		Status:        StatusFailed,
+		MaxRestarts:   2,
+		Backoff:       &BackoffConfig{InitialMs: 100, Multiplier: 3, MaxMs: 500},
+		restartWindow: time.Minute,
+	}
+
+	for _, expected := range []time.Duration{100, 300, 500, 500} {
+		delay := process.nextRestartDelay(time.Second)
+		if delay != expected*time.Millisecond {
+			t.Errorf("Expected delay of %dms, got %s", expected, delay)
+		}
+		process.restartDelay = delay
+	}
+	if delay := process.nextRestartDelay(2 * time.Minute); delay != 100*time.Millisecond {
+		t.Errorf("Expected backoff to be reset after a long run, got %s", delay)
+	}
+
+	now := time.Now()
+	process.restartTimes = []time.Time{now.Add(-2 * time.Minute), now.Add(-30 * time.Second)}
+	if !process.shouldRestart(now) {
+		t.Error("Expected restarts outside of the window to be forgotten")
+	}
+	process.restartTimes = append(process.restartTimes, now.Add(-time.Second))
+	if process.shouldRestart(now) {
+		t.Error("Expected no restart past max restarts within the window")
+	}
+
+	process.Status = StatusCompleted
+	process.restartTimes = nil
+	if process.shouldRestart(now) {
+		t.Error("Expected no restart of a completed process with the on-failure policy")
+	}
+	process.RestartPolicy = RestartPolicyAlways
+	if !process.shouldRestart(now) {
+		t.Error("Expected restart of a completed process with the always policy")
+	}
+	process.Status = StatusStopped
+	if process.shouldRestart(now) {
+		t.Error("Expected no restart of a stopped process")
+	}
+}
+
+// TestRestartPolicyAlways tests that processes are restarted after a successful exit
+func TestRestartPolicyAlways(t *testing.T) {
+	pm := NewProcessManager()
+	restart, err := NewRestartConfig("always", false, 2, &BackoffConfig{InitialMs: 50, MaxMs: 100}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	pid, err := pm.StartProcessWithRestart("echo run", "", "always", nil, lib.RunAs{}, 0, restart, false, false, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(pid)
+	select {
+	case <-process.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for process to complete with restarts")
+	}
+
+	if process.Status != StatusCompleted || process.RestartCount != 2 {
+		t.Errorf("Expected completed process with 2 restarts, got %s with %d", process.Status, process.RestartCount)
+	}
+	if delay, next := process.BackoffState(); delay != 100*time.Millisecond || next != nil {
+		t.Errorf("Expected last delay of 100ms and no pending restart, got %s and %v", delay, next)
+	}
+	logs, _ := pm.GetProcessOutput(pid)
+	if strings.Count(logs.Stdout, "run\n") != 3 || !strings.Contains(logs.Stdout, "Process exited with code 0. Attempting restart 2/2 in 100ms") {
+		t.Errorf("Unexpected output %q", logs.Stdout)
+	}
+}
+
+// TestStopDuringBackoff tests that stopping a process waiting to restart cancels the restart
+func TestStopDuringBackoff(t *testing.T) {
+	pm := NewProcessManager()
+	restart, err := NewRestartConfig("on-failure", false, 0, &BackoffConfig{InitialMs: 10000}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	pid, err := pm.StartProcessWithRestart("exit 1", "", "backoff", nil, lib.RunAs{}, 0, restart, false, false, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	process, _ := pm.GetProcessByIdentifier(pid)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, next := process.BackoffState(); next != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for process to wait for its restart")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := pm.StopProcess(pid); err != nil {
+		t.Fatalf("Failed to stop process: %v", err)
+	}
+	select {
+	case <-process.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected stop to cancel the pending restart")
+	}
+	if process.Status != StatusStopped || process.RestartCount != 0 {
+		t.Errorf("Expected stopped process without restarts, got %s with %d", process.Status, process.RestartCount)
+	}
+}
@@ -0,0 +1,190 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+)
+
+const (
+	// DefaultRetentionMaxEntries is the number of processes kept when PROCESS_RETENTION_MAX_ENTRIES is unset
+	DefaultRetentionMaxEntries = 1000
+	// DefaultRetentionMaxAge is how long terminated processes are kept when PROCESS_RETENTION_MAX_AGE is unset
+	DefaultRetentionMaxAge = 24 * time.Hour
+	// retentionInterval is the interval between two garbage collections of the process table
+	retentionInterval = time.Minute
+)
+
+// ErrProcessRunning is returned when removing a process that has not terminated yet
+var ErrProcessRunning = apierror.New(apierror.CodeProcRunning, "process is still running")
+
+// RetentionPolicy limits the processes kept in the process table. Only processes that
+// have terminated and will not be restarted are ever removed.
+type RetentionPolicy struct {
+	// MaxEntries is the number of processes above which the oldest terminated ones are
+	// removed, zero for no limit
+	MaxEntries int
+	// MaxAge is how long terminated processes are kept, zero for no limit
+	MaxAge time.Duration
+}
+
+// RetentionPolicyFromEnv returns the retention policy read from PROCESS_RETENTION_MAX_ENTRIES
+// and PROCESS_RETENTION_MAX_AGE (in seconds). Zero disables a limit.
+func RetentionPolicyFromEnv() RetentionPolicy {
+	policy := RetentionPolicy{
+		MaxEntries: DefaultRetentionMaxEntries,
+		MaxAge:     DefaultRetentionMaxAge,
+	}
+
+	if value := os.Getenv("PROCESS_RETENTION_MAX_ENTRIES"); value != "" {
+		maxEntries, err := strconv.Atoi(value)
+		if err != nil || maxEntries < 0 {
+			logrus.Warnf("Invalid PROCESS_RETENTION_MAX_ENTRIES value '%s', using default of %d processes", value, DefaultRetentionMaxEntries)
+		} else {
+			policy.MaxEntries = maxEntries
+		}
+	}
+	if value := os.Getenv("PROCESS_RETENTION_MAX_AGE"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			logrus.Warnf("Invalid PROCESS_RETENTION_MAX_AGE value '%s', using default of %s", value, DefaultRetentionMaxAge)
+		} else {
+			policy.MaxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	return policy
+}
+
+// isTerminated reports whether a process has terminated and will not be restarted
+func isTerminated(process *ProcessInfo) bool {
+	select {
+	case <-process.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// terminatedAt returns when a terminated process exited
+func terminatedAt(process *ProcessInfo) time.Time {
+	if completedAt := process.State().CompletedAt; completedAt != nil {
+		return *completedAt
+	}
+	return process.StartedAt
+}
+
+// removeLocked removes a process from the table along with its spilled logs and log
+// files. The mutex must be held.
+func (pm *ProcessManager) removeLocked(process *ProcessInfo) {
+	delete(pm.processes, process.PID)
+	if process.logFile != nil {
+		if err := process.logFile.Remove(); err != nil {
+			logging.ForProcess(process.PID).Warnf("Failed to remove log files of process %s: %v", process.PID, err)
+		}
+	}
+	if process.logs == nil {
+		return
+	}
+	if spillPath := process.logs.SpillPath(); spillPath != "" {
+		if err := os.Remove(spillPath); err != nil && !os.IsNotExist(err) {
+			logging.ForProcess(process.PID).Warnf("Failed to remove log file of process %s: %v", process.PID, err)
+		}
+	}
+}
+
+// RemoveProcess removes a terminated process from the process table
+func (pm *ProcessManager) RemoveProcess(identifier string) error {
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+	if !isTerminated(process) {
+		return fmt.Errorf("cannot remove process with Identifier %s: %w", identifier, ErrProcessRunning)
+	}
+
+	pm.mu.Lock()
+	pm.removeLocked(process)
+	pm.mu.Unlock()
+
+	pm.persist()
+	return nil
+}
+
+// RemoveProcesses removes the terminated processes with the given status from the
+// process table, or every terminated process when status is empty. It returns the
+// PIDs of the removed processes.
+func (pm *ProcessManager) RemoveProcesses(status constants.ProcessStatus) []string {
+	pm.mu.Lock()
+	removed := make([]string, 0)
+	for _, process := range pm.processes {
+		if !isTerminated(process) || (status != "" && process.State().Status != status) {
+			continue
+		}
+		pm.removeLocked(process)
+		removed = append(removed, process.PID)
+	}
+	pm.mu.Unlock()
+
+	if len(removed) > 0 {
+		pm.persist()
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// CollectGarbage removes the terminated processes exceeding the retention policy at
+// now, oldest first. It returns the PIDs of the removed processes.
+func (pm *ProcessManager) CollectGarbage(policy RetentionPolicy, now time.Time) []string {
+	pm.mu.Lock()
+	terminated := make([]*ProcessInfo, 0)
+	for _, process := range pm.processes {
+		if isTerminated(process) {
+			terminated = append(terminated, process)
+		}
+	}
+	sort.Slice(terminated, func(i, j int) bool {
+		return terminatedAt(terminated[i]).Before(terminatedAt(terminated[j]))
+	})
+
+	removed := make([]string, 0)
+	for _, process := range terminated {
+		expired := policy.MaxAge > 0 && now.Sub(terminatedAt(process)) > policy.MaxAge
+		overflow := policy.MaxEntries > 0 && len(pm.processes) > policy.MaxEntries
+		if !expired && !overflow {
+			continue
+		}
+		pm.removeLocked(process)
+		removed = append(removed, process.PID)
+	}
+	pm.mu.Unlock()
+
+	if len(removed) > 0 {
+		logrus.Debugf("Removed %d terminated processes from the process table", len(removed))
+		pm.persist()
+	}
+	return removed
+}
+
+// StartRetention periodically removes the terminated processes exceeding the
+// retention policy, for the lifetime of the API
+func (pm *ProcessManager) StartRetention(policy RetentionPolicy) {
+	if policy.MaxEntries == 0 && policy.MaxAge == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			pm.CollectGarbage(policy, now)
+		}
+	}()
+}
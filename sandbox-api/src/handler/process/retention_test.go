@@ -0,0 +1,102 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// startAndWait starts a process and waits for it to terminate
+func startAndWait(t *testing.T, pm *ProcessManager, command string, name string) string {
+	t.Helper()
+	pid, err := pm.StartProcessWithName(command, "", name, nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if _, completed, err := pm.WaitForProcess(context.Background(), pid, 5*time.Second); err != nil || !completed {
+		t.Fatalf("Process did not complete: %v", err)
+	}
+	return pid
+}
+
+// TestRemoveProcess tests the explicit removal of process records
+func TestRemoveProcess(t *testing.T) {
+	pm := NewProcessManager()
+
+	donePID := startAndWait(t, pm, "true", "remove-done")
+	failedPID := startAndWait(t, pm, "false", "remove-failed")
+	runningPID, err := pm.StartProcessWithName("sleep 30", "", "remove-running", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() { _ = pm.KillProcess(runningPID) }()
+
+	if err := pm.RemoveProcess(runningPID); !errors.Is(err, ErrProcessRunning) {
+		t.Errorf("Expected ErrProcessRunning for a running process, got %v", err)
+	}
+	if err := pm.RemoveProcess("remove-done"); err != nil {
+		t.Fatalf("Failed to remove process: %v", err)
+	}
+	if _, exists := pm.GetProcessByIdentifier(donePID); exists {
+		t.Error("Expected removed process to be gone")
+	}
+	if err := pm.RemoveProcess(donePID); err == nil || errors.Is(err, ErrProcessRunning) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	startAndWait(t, pm, "true", "remove-done-again")
+	removed := pm.RemoveProcesses(StatusFailed)
+	if len(removed) != 1 || removed[0] != failedPID {
+		t.Errorf("Expected only the failed process to be removed, got %v", removed)
+	}
+	removed = pm.RemoveProcesses("")
+	if len(removed) != 1 {
+		t.Errorf("Expected the remaining terminated process to be removed, got %v", removed)
+	}
+	if _, exists := pm.GetProcessByIdentifier(runningPID); !exists {
+		t.Error("Expected running process to be kept")
+	}
+}
+
+// TestCollectGarbage tests the retention policy of terminated processes
+func TestCollectGarbage(t *testing.T) {
+	pm := NewProcessManager()
+
+	oldest := startAndWait(t, pm, "true", "gc-1")
+	middle := startAndWait(t, pm, "true", "gc-2")
+	newest := startAndWait(t, pm, "true", "gc-3")
+	runningPID, err := pm.StartProcessWithName("sleep 30", "", "gc-running", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() { _ = pm.KillProcess(runningPID) }()
+
+	// Age the processes so the completion order is deterministic
+	now := time.Now()
+	for i, pid := range []string{oldest, middle, newest} {
+		process, _ := pm.GetProcessByIdentifier(pid)
+		completedAt := now.Add(time.Duration(i-3) * time.Hour)
+		process.stateMu.Lock()
+		process.CompletedAt = &completedAt
+		process.stateMu.Unlock()
+	}
+
+	removed := pm.CollectGarbage(RetentionPolicy{MaxEntries: 3}, now)
+	if len(removed) != 1 || removed[0] != oldest {
+		t.Errorf("Expected the oldest process to be removed past max entries, got %v", removed)
+	}
+
+	removed = pm.CollectGarbage(RetentionPolicy{MaxAge: 90 * time.Minute}, now)
+	if len(removed) != 1 || removed[0] != middle {
+		t.Errorf("Expected the expired process to be removed, got %v", removed)
+	}
+
+	removed = pm.CollectGarbage(RetentionPolicy{MaxEntries: 1, MaxAge: time.Minute}, now)
+	if len(removed) != 1 || removed[0] != newest {
+		t.Errorf("Expected the last terminated process to be removed, got %v", removed)
+	}
+	if _, exists := pm.GetProcessByIdentifier(runningPID); !exists {
+		t.Error("Expected running process to be kept")
+	}
+}
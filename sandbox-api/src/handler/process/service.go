@@ -10,20 +10,36 @@ import (
 	"time"
 
 	"github.com/blaxel-ai/sandbox-api/src/handler/network"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
 )
 
-// ExecuteProcess executes a process with the given parameters
+// timeoutGrace is how long waiting for completion lasts past the timeout of a process,
+// for it to be killed and its output collected
+const timeoutGrace = 5 * time.Second
+
+// ExecuteProcess executes a process with the given parameters. With a timeout, the process
+// is killed, with status timedout, once it has run for timeout seconds. Waiting for it to
+// be ready also fails after timeout seconds. With logToFile, its output is also written to
+// rotated log files, and with record it is recorded to an asciicast file.
 func (pm *ProcessManager) ExecuteProcess(
 	command string,
 	workingDir string,
 	name string,
 	env map[string]string,
+	runAs lib.RunAs,
 	waitForCompletion bool,
 	timeout int,
 	waitForPorts []int,
-	restartOnFailure bool,
-	maxRestarts int,
+	waitForLogPattern string,
+	restart RestartConfig,
+	logToFile bool,
+	record bool,
 ) (*ProcessInfo, error) {
+	logPatternCondition := ReadinessCondition{LogPattern: waitForLogPattern, Timeout: max(timeout, 0)}
+	if err := logPatternCondition.Validate(); err != nil {
+		return nil, err
+	}
+
 	portCh := make(chan int)
 	completionCh := make(chan string)
 
@@ -48,11 +64,12 @@ func (pm *ProcessManager) ExecuteProcess(
 		}
 	}()
 
-	// Create a context with the specified timeout
+	// Create a context with the specified timeout, plus the time for a timed out process
+	// to be killed
 	var ctx context.Context
 	var cancel context.CancelFunc
 	if timeout > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second+timeoutGrace)
 		defer cancel()
 	} else {
 		ctx = context.Background()
@@ -75,13 +92,10 @@ func (pm *ProcessManager) ExecuteProcess(
 	}
 
 	// Start the process
-	var pid string
-	var err error
-	if name != "" {
-		pid, err = pm.StartProcessWithName(command, workingDir, name, env, restartOnFailure, maxRestarts, callback)
-	} else {
-		pid, err = pm.StartProcess(command, workingDir, env, restartOnFailure, maxRestarts, callback)
+	if name == "" {
+		name = GenerateRandomName(8)
 	}
+	pid, err := pm.StartProcessWithRestart(command, workingDir, name, env, runAs, time.Duration(max(timeout, 0))*time.Second, restart, logToFile, record, callback)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start process: %w", err)
 	}
@@ -106,7 +120,11 @@ func (pm *ProcessManager) ExecuteProcess(
 		} else {
 			n := network.GetNetwork()
 			ports := make([]int, 0, len(waitForPorts))
+			// Ports are opened by the OS process, whose PID may differ from the process one
 			pidInt, _ := strconv.Atoi(pid)
+			if info, exists := pm.GetProcessByIdentifier(pid); exists {
+				pidInt = info.ProcessPid
+			}
 			n.RegisterPortOpenCallback(pidInt, func(pid int, port *network.PortInfo) {
 				if slices.Contains(waitForPorts, port.LocalPort) {
 					ports = append(ports, port.LocalPort)
@@ -133,6 +151,13 @@ func (pm *ProcessManager) ExecuteProcess(
 		}
 	}
 
+	// Wait for the logs to match the pattern if requested
+	if waitForLogPattern != "" {
+		if err := pm.WaitForReady(ctx, pid, logPatternCondition); err != nil {
+			return nil, err
+		}
+	}
+
 	// Wait for completion if requested
 	if waitForCompletion {
 		select {
@@ -144,7 +169,7 @@ func (pm *ProcessManager) ExecuteProcess(
 			pid = receivedPID // Update pid to the received PID
 			break
 		case <-ctx.Done():
-			return nil, fmt.Errorf("process timed out after %d seconds", timeout)
+			return nil, fmt.Errorf("process did not exit %s after timing out", timeoutGrace)
 		}
 	}
 
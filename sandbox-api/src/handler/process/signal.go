@@ -0,0 +1,129 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+)
+
+// maxSignal is the largest signal number, including real-time signals
+const maxSignal = 64
+
+// signals are the signals which can be sent to processes by name
+var signals = map[string]syscall.Signal{
+	"SIGABRT":   syscall.SIGABRT,
+	"SIGALRM":   syscall.SIGALRM,
+	"SIGBUS":    syscall.SIGBUS,
+	"SIGCHLD":   syscall.SIGCHLD,
+	"SIGCONT":   syscall.SIGCONT,
+	"SIGFPE":    syscall.SIGFPE,
+	"SIGHUP":    syscall.SIGHUP,
+	"SIGILL":    syscall.SIGILL,
+	"SIGINT":    syscall.SIGINT,
+	"SIGIO":     syscall.SIGIO,
+	"SIGKILL":   syscall.SIGKILL,
+	"SIGPIPE":   syscall.SIGPIPE,
+	"SIGPROF":   syscall.SIGPROF,
+	"SIGQUIT":   syscall.SIGQUIT,
+	"SIGSEGV":   syscall.SIGSEGV,
+	"SIGSTOP":   syscall.SIGSTOP,
+	"SIGSYS":    syscall.SIGSYS,
+	"SIGTERM":   syscall.SIGTERM,
+	"SIGTRAP":   syscall.SIGTRAP,
+	"SIGTSTP":   syscall.SIGTSTP,
+	"SIGTTIN":   syscall.SIGTTIN,
+	"SIGTTOU":   syscall.SIGTTOU,
+	"SIGURG":    syscall.SIGURG,
+	"SIGUSR1":   syscall.SIGUSR1,
+	"SIGUSR2":   syscall.SIGUSR2,
+	"SIGVTALRM": syscall.SIGVTALRM,
+	"SIGWINCH":  syscall.SIGWINCH,
+	"SIGXCPU":   syscall.SIGXCPU,
+	"SIGXFSZ":   syscall.SIGXFSZ,
+}
+
+// ParseSignal returns the signal of a name, with or without the SIG prefix and in any
+// case (SIGHUP, hup), or of a number (1)
+func ParseSignal(value string) (syscall.Signal, error) {
+	value = strings.TrimSpace(value)
+	if number, err := strconv.Atoi(value); err == nil {
+		if number < 1 || number > maxSignal {
+			return 0, fmt.Errorf("invalid signal %d: must be between 1 and %d", number, maxSignal)
+		}
+		return syscall.Signal(number), nil
+	}
+
+	name := strings.ToUpper(value)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	signal, ok := signals[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal '%s'", value)
+	}
+	return signal, nil
+}
+
+// SignalProcess sends a signal to the process group of a running process. Its status is
+// left unchanged, so processes exiting on the signal are restarted according to their
+// restart policy: use StopProcess or KillProcess to terminate them.
+func (pm *ProcessManager) SignalProcess(identifier string, signal syscall.Signal) error {
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+	if process.State().Status != StatusRunning {
+		return apierror.Newf(apierror.CodeProcNotRunning, "process with Identifier %s is not running", identifier)
+	}
+	if process.ProcessPid == 0 {
+		return apierror.Newf(apierror.CodeProcNotRunning, "process with Identifier %s has no OS process", identifier)
+	}
+
+	pid := process.ProcessPid
+	if err := syscall.Kill(-pid, signal); err != nil {
+		// Fall back to signaling just the process when it has no process group
+		if err := syscall.Kill(pid, signal); err != nil {
+			return fmt.Errorf("failed to send %s to process with Identifier %s: %w", signal, identifier, err)
+		}
+	}
+	logging.ForProcess(process.PID).Infof("Sent %s to process %s", signal, process.PID)
+	return nil
+}
+
+// StopProcessWithTimeout gracefully stops a process like StopProcess, then kills its
+// process group when it is still running after the timeout. The process is killed in
+// the background, without timeout it is only stopped.
+func (pm *ProcessManager) StopProcessWithTimeout(identifier string, timeout time.Duration) error {
+	process, exists := pm.GetProcessByIdentifier(identifier)
+	if !exists {
+		return apierror.Newf(apierror.CodeProcNotFound, "process with Identifier %s not found", identifier)
+	}
+	// Processes waiting to restart have no OS process left to kill
+	running := process.State().Status == StatusRunning
+
+	if err := pm.StopProcess(identifier); err != nil {
+		return err
+	}
+	if timeout <= 0 || !running {
+		return nil
+	}
+
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-process.Done():
+		case <-timer.C:
+			logging.ForProcess(process.PID).Warnf("Process %s did not stop within %s, killing it", process.PID, timeout)
+			if err := pm.KillProcess(process.PID); err != nil {
+				logging.ForProcess(process.PID).Warnf("Failed to kill process %s: %v", process.PID, err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,286 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+)
+
+// StartupProcessName is the name of the managed process running the startup command
+const StartupProcessName = "startup"
+
+// StartupGroupName is the name of the process group running the startup commands, its
+// processes being named startup-<name>
+const StartupGroupName = "startup"
+
+// startupStopTimeout is how long the process of the previous startup command is given
+// to exit when the command is replaced, before being killed
+const startupStopTimeout = 10 * time.Second
+
+// StartupCommand is the command run when the sandbox starts, given with the -c flag or
+// set with the API
+type StartupCommand struct {
+	Command       string            `json:"command" example:"npm start"`
+	WorkingDir    string            `json:"workingDir" example:"/home/user/app"`
+	Env           map[string]string `json:"env" example:"{\"PORT\": \"3000\"}"`
+	RestartPolicy string            `json:"restartPolicy" example:"on-failure" enums:"never,on-failure,always"`
+	MaxRestarts   int               `json:"maxRestarts" example:"0"`
+} // @name StartupCommand
+
+// StartupCommand returns the startup command and its process, nil when it has none
+func (pm *ProcessManager) StartupCommand() (StartupCommand, *ProcessInfo) {
+	pm.startupMu.Lock()
+	startup := pm.startup
+	pm.startupMu.Unlock()
+
+	if startup.Command == "" {
+		return startup, nil
+	}
+	process, exists := pm.GetProcessByIdentifier(StartupProcessName)
+	if !exists {
+		return startup, nil
+	}
+	return startup, process
+}
+
+// InitStartupCommand runs the startup command the API was started with. The startup
+// process restored from the previous run of the API is kept when it still runs, and the
+// command is the same or none was given.
+func (pm *ProcessManager) InitStartupCommand(startup StartupCommand) (*ProcessInfo, error) {
+	if previous := pm.runningStartupProcess(); previous != nil && (startup.Command == "" || startup.Command == previous.Command) {
+		pm.startupMu.Lock()
+		pm.startup = StartupCommand{
+			Command:       previous.Command,
+			WorkingDir:    previous.WorkingDir,
+			Env:           previous.env,
+			RestartPolicy: string(previous.RestartPolicy),
+			MaxRestarts:   previous.MaxRestarts,
+		}
+		pm.startupMu.Unlock()
+		logging.ForProcess(previous.PID).Infof("Keeping startup process %s of the previous run", previous.PID)
+		return previous, nil
+	}
+	if startup.Command == "" {
+		return nil, nil
+	}
+	return pm.SetStartupCommand(startup)
+}
+
+// SetStartupCommand replaces the startup command: the process of the previous one is
+// stopped, and killed if still running after startupStopTimeout, then the new one is
+// started as the startup process. An empty command only stops the previous one.
+func (pm *ProcessManager) SetStartupCommand(startup StartupCommand) (*ProcessInfo, error) {
+	restart, err := NewRestartConfig(startup.RestartPolicy, false, startup.MaxRestarts, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.startupMu.Lock()
+	defer pm.startupMu.Unlock()
+
+	if previous, exists := pm.GetProcessByIdentifier(StartupProcessName); exists {
+		pm.stopStartupProcess(previous)
+	}
+	pm.startup = StartupCommand{}
+	if startup.Command == "" {
+		return nil, nil
+	}
+
+	pid, err := pm.StartProcessWithRestart(startup.Command, startup.WorkingDir, StartupProcessName, startup.Env, lib.RunAs{}, 0, restart, false, false, func(process *ProcessInfo) {})
+	if err != nil {
+		return nil, err
+	}
+	pm.startup = startup
+	process, _ := pm.GetProcessByIdentifier(pid)
+	return process, nil
+}
+
+// runningStartupProcess returns the startup process when it is running
+func (pm *ProcessManager) runningStartupProcess() *ProcessInfo {
+	process, exists := pm.GetProcessByIdentifier(StartupProcessName)
+	if !exists || process.State().Status != StatusRunning {
+		return nil
+	}
+	return process
+}
+
+// stopStartupProcess stops the startup process, or cancels its pending restart, killing
+// it after startupStopTimeout
+func (pm *ProcessManager) stopStartupProcess(process *ProcessInfo) {
+	if process.cancelRestart(StatusStopped) {
+		pm.persist()
+		return
+	}
+	if process.State().Status != StatusRunning {
+		return
+	}
+
+	log := logging.ForProcess(process.PID)
+	if err := pm.StopProcess(process.PID); err != nil && process.State().Status == StatusRunning {
+		log.Warnf("Failed to stop startup process %s: %v", process.PID, err)
+		return
+	}
+	select {
+	case <-process.Done():
+	case <-time.After(startupStopTimeout):
+		if err := pm.KillProcess(process.PID); err != nil {
+			log.Warnf("Failed to kill startup process %s: %v", process.PID, err)
+		}
+	}
+}
+
+// StartupCommandsFromEnv returns the startup commands of STARTUP_COMMANDS, a JSON array
+// of processes, else of the JSON or YAML (.yaml, .yml) file of STARTUP_FILE. It returns
+// nil when neither is set.
+func StartupCommandsFromEnv() ([]GroupProcessSpec, error) {
+	if value := os.Getenv("STARTUP_COMMANDS"); value != "" {
+		specs, err := parseStartupCommands([]byte(value), false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STARTUP_COMMANDS: %w", err)
+		}
+		return specs, nil
+	}
+
+	path := os.Getenv("STARTUP_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	specs, err := parseStartupCommands(data, ext == ".yaml" || ext == ".yml")
+	if err != nil {
+		return nil, fmt.Errorf("invalid startup file %s: %w", path, err)
+	}
+	return specs, nil
+}
+
+// parseStartupCommands parses a list of processes. YAML is converted to JSON first so
+// that its keys are the JSON field names.
+func parseStartupCommands(data []byte, isYAML bool) ([]GroupProcessSpec, error) {
+	if isYAML {
+		var value any
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		converted, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+	var specs []GroupProcessSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// StartupCommands returns the startup commands and their process group, nil when they
+// are not running from this run of the API
+func (pm *ProcessManager) StartupCommands() ([]GroupProcessSpec, *ProcessGroup) {
+	pm.startupMu.Lock()
+	specs := pm.startupCommands
+	pm.startupMu.Unlock()
+
+	if len(specs) == 0 {
+		return []GroupProcessSpec{}, nil
+	}
+	group, exists := pm.GetGroup(StartupGroupName)
+	if !exists {
+		return specs, nil
+	}
+	return specs, group
+}
+
+// InitStartupCommands starts the startup commands the API was started with, as the
+// startup process group. They are not started again when processes of the startup
+// group of the previous run of the API are still running.
+func (pm *ProcessManager) InitStartupCommands(specs []GroupProcessSpec) (*ProcessGroup, error) {
+	for _, spec := range specs {
+		process, exists := pm.GetProcessByIdentifier(groupProcessName(StartupGroupName, spec.Name))
+		if exists && process.State().Status == StatusRunning {
+			pm.startupMu.Lock()
+			pm.startupCommands = specs
+			pm.startupMu.Unlock()
+			logrus.Infof("Keeping the startup processes of the previous run")
+			return nil, nil
+		}
+	}
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	return pm.SetStartupCommands(specs)
+}
+
+// SetStartupCommands replaces the startup commands: the processes of the previous ones
+// are stopped, and killed if still running after startupStopTimeout, then the new ones
+// are started as the startup process group, in order and according to their
+// dependencies. An empty list only stops the previous ones.
+func (pm *ProcessManager) SetStartupCommands(specs []GroupProcessSpec) (*ProcessGroup, error) {
+	if len(specs) > 0 {
+		if err := validateGroup(StartupGroupName, specs); err != nil {
+			return nil, err
+		}
+		for _, spec := range specs {
+			if err := policy.GetEngine().Check(spec.Command, spec.WorkingDir); err != nil {
+				return nil, fmt.Errorf("process %s: %w", spec.Name, err)
+			}
+		}
+	}
+
+	pm.startupMu.Lock()
+	defer pm.startupMu.Unlock()
+
+	pm.stopStartupGroup(specs)
+	pm.startupCommands = nil
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	group, err := pm.StartGroup(StartupGroupName, specs)
+	if err != nil {
+		return nil, err
+	}
+	pm.startupCommands = specs
+	return group, nil
+}
+
+// stopStartupGroup stops the processes of the startup group, and the ones of the
+// previous run of the API kept by InitStartupCommands
+func (pm *ProcessManager) stopStartupGroup(specs []GroupProcessSpec) {
+	pids := make([]string, 0)
+	if group, exists := pm.GetGroup(StartupGroupName); exists {
+		if err := pm.StopGroup(StartupGroupName, false); err != nil {
+			logrus.Warnf("Failed to stop the startup process group: %v", err)
+		}
+		for _, member := range group.Info().Processes {
+			if member.PID != "" {
+				pids = append(pids, member.PID)
+			}
+		}
+	}
+	for _, spec := range append(pm.startupCommands, specs...) {
+		if process, exists := pm.GetProcessByIdentifier(groupProcessName(StartupGroupName, spec.Name)); exists {
+			pids = append(pids, process.PID)
+		}
+	}
+
+	for _, pid := range pids {
+		if process, exists := pm.GetProcessByIdentifier(pid); exists {
+			pm.stopStartupProcess(process)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStartupCommand tests that replacing the startup command stops the process of the
+// previous one, and that the startup process is kept when initialized with its command
+func TestStartupCommand(t *testing.T) {
+	pm := NewProcessManager()
+
+	first, err := pm.InitStartupCommand(StartupCommand{Command: "sleep 30"})
+	if err != nil {
+		t.Fatalf("Failed to start the startup command: %v", err)
+	}
+	if first.Name != StartupProcessName || first.Status != StatusRunning {
+		t.Fatalf("Expected a running startup process, got %s (%s)", first.Name, first.Status)
+	}
+
+	kept, err := pm.InitStartupCommand(StartupCommand{Command: "sleep 30"})
+	if err != nil || kept.PID != first.PID {
+		t.Fatalf("Expected the running startup process to be kept, got %v (%v)", kept, err)
+	}
+
+	second, err := pm.SetStartupCommand(StartupCommand{Command: "sleep 30", RestartPolicy: "on-failure", MaxRestarts: 2})
+	if err != nil {
+		t.Fatalf("Failed to replace the startup command: %v", err)
+	}
+	select {
+	case <-first.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the previous startup process to be stopped")
+	}
+	startup, process := pm.StartupCommand()
+	if process == nil || process.PID != second.PID || startup.MaxRestarts != 2 || process.RestartPolicy != RestartPolicyOnFailure {
+		t.Fatalf("Expected the new startup command and process, got %+v and %v", startup, process)
+	}
+
+	if _, err := pm.SetStartupCommand(StartupCommand{Command: "sleep 30", RestartPolicy: "sometimes"}); err == nil {
+		t.Error("Expected an error for an invalid restart policy")
+	}
+	if _, err := pm.SetStartupCommand(StartupCommand{}); err != nil {
+		t.Fatalf("Failed to clear the startup command: %v", err)
+	}
+	select {
+	case <-second.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the startup process to be stopped")
+	}
+	if startup, process := pm.StartupCommand(); startup.Command != "" || process != nil {
+		t.Errorf("Expected no startup command, got %+v and %v", startup, process)
+	}
+}
+
+// TestStartupCommandsFromEnv tests loading the startup commands from JSON and YAML
+func TestStartupCommandsFromEnv(t *testing.T) {
+	t.Setenv("STARTUP_COMMANDS", `[{"name":"db","command":"postgres"},{"name":"api","command":"npm start","dependsOn":["db"]}]`)
+	specs, err := StartupCommandsFromEnv()
+	if err != nil || len(specs) != 2 || specs[1].DependsOn[0] != "db" {
+		t.Fatalf("Unexpected startup commands of STARTUP_COMMANDS: %+v (%v)", specs, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "startup.yaml")
+	yamlFile := "- name: db\n  command: postgres\n  readyWhen:\n    ports: [5432]\n- name: api\n  command: npm start\n  workingDir: /app\n  dependsOn: [db]\n  restartPolicy: always\n"
+	if err := os.WriteFile(path, []byte(yamlFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("STARTUP_COMMANDS", "")
+	t.Setenv("STARTUP_FILE", path)
+	specs, err = StartupCommandsFromEnv()
+	if err != nil || len(specs) != 2 || specs[0].ReadyWhen.Ports[0] != 5432 || specs[1].WorkingDir != "/app" || specs[1].RestartPolicy != "always" {
+		t.Fatalf("Unexpected startup commands of STARTUP_FILE: %+v (%v)", specs, err)
+	}
+
+	t.Setenv("STARTUP_COMMANDS", `{"name":"db"}`)
+	if _, err := StartupCommandsFromEnv(); err == nil {
+		t.Error("Expected an error for a STARTUP_COMMANDS value which is not an array")
+	}
+}
+
+// TestStartupCommands tests that the startup commands start in the order of their
+// dependencies, and that replacing them stops their processes
+func TestStartupCommands(t *testing.T) {
+	pm := NewProcessManager()
+
+	if _, err := pm.SetStartupCommands([]GroupProcessSpec{{Name: "web", Command: "sleep 30", DependsOn: []string{"db"}}}); err == nil {
+		t.Fatal("Expected an error for an unknown dependency")
+	}
+
+	group, err := pm.InitStartupCommands([]GroupProcessSpec{
+		{Name: "db", Command: "echo ready; sleep 30", ReadyWhen: &ReadinessCondition{LogPattern: "ready", Timeout: 5}},
+		{Name: "web", Command: "sleep 30", DependsOn: []string{"db"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start the startup commands: %v", err)
+	}
+	select {
+	case <-group.Settled():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the startup commands")
+	}
+	info := group.Info()
+	if info.Name != StartupGroupName || info.Status != GroupStatusRunning || info.Processes[1].ProcessName != "startup-web" {
+		t.Fatalf("Expected the running startup group, got %+v", info)
+	}
+	web, _ := pm.GetProcessByIdentifier("startup-web")
+
+	if _, err := pm.SetStartupCommands(nil); err != nil {
+		t.Fatalf("Failed to clear the startup commands: %v", err)
+	}
+	select {
+	case <-web.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the startup processes to be stopped")
+	}
+	if commands, group := pm.StartupCommands(); len(commands) != 0 || group != nil {
+		t.Errorf("Expected no startup commands, got %+v", commands)
+	}
+}
@@ -0,0 +1,322 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+)
+
+// stateFileName is the file of the state directory holding the process table
+const stateFileName = "processes.json"
+
+// Streams of the output of a process, as named in saved log files
+const (
+	logStreamLogs   = "logs"
+	logStreamStdout = "stdout"
+	logStreamStderr = "stderr"
+)
+
+// processRecord is the saved state of a process
+type processRecord struct {
+	PID              string            `json:"pid"`
+	Name             string            `json:"name"`
+	Command          string            `json:"command"`
+	ProcessPid       int               `json:"processPid"`
+	StartedAt        time.Time         `json:"startedAt"`
+	CompletedAt      *time.Time        `json:"completedAt"`
+	ExitCode         int               `json:"exitCode"`
+	Status           string            `json:"status"`
+	WorkingDir       string            `json:"workingDir"`
+	RunAsUser        string            `json:"runAsUser,omitempty"`
+	RunAsGroup       string            `json:"runAsGroup,omitempty"`
+	Timeout          int               `json:"timeout,omitempty"`
+	Adopted          bool              `json:"adopted,omitempty"`
+	RestartOnFailure bool              `json:"restartOnFailure"`
+	MaxRestarts      int               `json:"maxRestarts"`
+	RestartCount     int               `json:"restartCount"`
+	RestartPolicy    string            `json:"restartPolicy"`
+	LogFile          string            `json:"logFile,omitempty"` // current rotated log file of a process logging to files
+	LogFiles         map[string]string `json:"logFiles"`
+}
+
+// processState is the saved process table
+type processState struct {
+	SavedAt   time.Time       `json:"savedAt"`
+	Processes []processRecord `json:"processes"`
+}
+
+// StateDirFromEnv returns the directory the process table is saved to on shutdown,
+// read from STATE_DIR and defaulting to sandbox-api-state in the temp directory
+func StateDirFromEnv() string {
+	if dir := os.Getenv("STATE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "sandbox-api-state")
+}
+
+// TerminateAll gracefully stops the process groups, in the reverse order of their
+// processes, and then every other running process. Processes still running when ctx
+// is done are killed.
+func (pm *ProcessManager) TerminateAll(ctx context.Context) {
+	running := make([]*ProcessInfo, 0)
+	for _, process := range pm.ListProcesses() {
+		if process.State().Status == StatusRunning {
+			running = append(running, process)
+		}
+	}
+
+	for _, group := range pm.ListGroups() {
+		if err := pm.StopGroup(group.name, false); err != nil {
+			logrus.Warnf("Failed to stop process group %s: %v", group.name, err)
+		}
+	}
+	for _, process := range running {
+		if process.State().Status != StatusRunning {
+			continue
+		}
+		if err := pm.StopProcess(process.PID); err != nil {
+			logging.ForProcess(process.PID).Warnf("Failed to stop process %s: %v", process.PID, err)
+		}
+	}
+
+	for _, process := range running {
+		select {
+		case <-process.Done():
+		case <-ctx.Done():
+			if err := pm.KillProcess(process.PID); err != nil {
+				logging.ForProcess(process.PID).Warnf("Failed to kill process %s: %v", process.PID, err)
+			}
+		}
+	}
+}
+
+// PersistTo saves the process table to dir now and whenever a process starts, stops
+// or exits, so that it survives crashes of the API. Output is only saved by SaveState.
+func (pm *ProcessManager) PersistTo(dir string) error {
+	pm.persistMu.Lock()
+	pm.persistDir = dir
+	pm.persistMu.Unlock()
+
+	return pm.writeTable(dir, pm.records(""))
+}
+
+// persist saves the process table if persistence is enabled. Failures are logged.
+func (pm *ProcessManager) persist() {
+	pm.persistMu.Lock()
+	defer pm.persistMu.Unlock()
+
+	if pm.persistDir == "" {
+		return
+	}
+	if err := pm.writeTable(pm.persistDir, pm.records("")); err != nil {
+		logrus.Warnf("Failed to persist process table: %v", err)
+	}
+}
+
+// SaveState flushes the output of every process to log files and saves the process
+// table to dir, so that it can be restored with LoadState on the next start. The
+// table is not persisted on changes anymore afterwards, so the saved output is kept.
+func (pm *ProcessManager) SaveState(dir string) error {
+	pm.persistMu.Lock()
+	defer pm.persistMu.Unlock()
+	pm.persistDir = ""
+
+	logsDir := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return pm.writeTable(dir, pm.records(logsDir))
+}
+
+// records returns the saved state of every process. When logsDir is not empty, the
+// output of the processes is written to files in that directory.
+func (pm *ProcessManager) records(logsDir string) []processRecord {
+	records := make([]processRecord, 0)
+	for _, process := range pm.ListProcesses() {
+		state := process.State()
+		record := processRecord{
+			PID:              process.PID,
+			Name:             process.Name,
+			Command:          process.Command,
+			ProcessPid:       process.ProcessPid,
+			StartedAt:        process.StartedAt,
+			CompletedAt:      state.CompletedAt,
+			ExitCode:         state.ExitCode,
+			Status:           string(state.Status),
+			WorkingDir:       process.WorkingDir,
+			RunAsUser:        process.RunAsUser,
+			RunAsGroup:       process.RunAsGroup,
+			Timeout:          process.Timeout,
+			Adopted:          process.Adopted,
+			RestartOnFailure: process.RestartOnFailure,
+			MaxRestarts:      process.MaxRestarts,
+			RestartCount:     process.RestartCount,
+			RestartPolicy:    string(process.RestartPolicy),
+			LogFiles:         make(map[string]string),
+		}
+		// Restored processes keep the output they were restored from until saved again
+		if logsDir == "" {
+			maps.Copy(record.LogFiles, process.savedLogFiles)
+		}
+		if process.logFile != nil {
+			record.LogFile = process.logFile.Path()
+		}
+
+		if logsDir != "" {
+			process.logLock.RLock()
+			buffers := map[string]*LogBuffer{logStreamLogs: process.logs, logStreamStdout: process.stdout, logStreamStderr: process.stderr}
+			for stream, buffer := range buffers {
+				if buffer == nil || buffer.Len() == 0 {
+					continue
+				}
+				path := filepath.Join(logsDir, fmt.Sprintf("%s-%d.%s", process.PID, process.StartedAt.Unix(), stream))
+				if err := os.WriteFile(path, []byte(buffer.String()), 0644); err != nil {
+					logging.ForProcess(process.PID).Warnf("Failed to save %s of process %s: %v", stream, process.PID, err)
+					continue
+				}
+				record.LogFiles[stream] = path
+			}
+			process.logLock.RUnlock()
+		}
+
+		records = append(records, record)
+	}
+	return records
+}
+
+// writeTable writes the process table to dir
+func (pm *ProcessManager) writeTable(dir string, records []processRecord) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(processState{SavedAt: time.Now(), Processes: records}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal process table: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated table
+	statePath := filepath.Join(dir, stateFileName)
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write process table: %w", err)
+	}
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		return fmt.Errorf("failed to write process table: %w", err)
+	}
+	return nil
+}
+
+// LoadState restores the process table saved to dir, along with the output of the
+// processes saved by SaveState. Processes that are still running are adopted again,
+// the ones that were running when the table was saved but have exited since are
+// restored as stopped.
+func (pm *ProcessManager) LoadState(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, stateFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read process table: %w", err)
+	}
+
+	var state processState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse process table: %w", err)
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	for _, record := range state.Processes {
+		if existing, exists := pm.processes[record.PID]; exists {
+			if existing.StartedAt.Equal(record.StartedAt) {
+				continue
+			}
+			// A new process reused the PID of the saved one
+			record.PID = pm.uniquePIDLocked(record.PID)
+		}
+		process := restoreProcess(record)
+		pm.processes[process.PID] = process
+
+		if process.Status == StatusRunning {
+			if isSameProcess(process.ProcessPid, process.StartedAt) {
+				process.logs.WriteString("\n[Process adopted after an API restart, its output is not captured anymore]\n")
+				go pm.watchAdopted(process)
+				continue
+			}
+			savedAt := state.SavedAt
+			process.Status = StatusStopped
+			process.CompletedAt = &savedAt
+			process.logs.WriteString("\n[Process was running when the API shut down]\n")
+		}
+		process.markDone()
+	}
+	return nil
+}
+
+// restoreProcess recreates a process from its saved state
+func restoreProcess(record processRecord) *ProcessInfo {
+	process := &ProcessInfo{
+		PID:              record.PID,
+		Name:             record.Name,
+		Command:          record.Command,
+		ProcessPid:       record.ProcessPid,
+		StartedAt:        record.StartedAt,
+		CompletedAt:      record.CompletedAt,
+		ExitCode:         record.ExitCode,
+		Status:           StatusStopped,
+		WorkingDir:       record.WorkingDir,
+		RunAsUser:        record.RunAsUser,
+		RunAsGroup:       record.RunAsGroup,
+		Timeout:          record.Timeout,
+		Adopted:          record.Adopted,
+		RestartOnFailure: record.RestartOnFailure,
+		MaxRestarts:      record.MaxRestarts,
+		RestartCount:     record.RestartCount,
+		RestartPolicy:    RestartPolicy(record.RestartPolicy),
+		logWriters:       make([]io.Writer, 0),
+		done:             make(chan struct{}),
+		savedLogFiles:    record.LogFiles,
+	}
+	if record.Status != "" {
+		process.Status = constants.ProcessStatus(record.Status)
+	}
+	if process.RestartPolicy == "" {
+		process.RestartPolicy = RestartPolicyNever
+	}
+
+	if record.LogFile != "" {
+		process.LogToFile = true
+		process.logFile = NewLogFile(record.LogFile, logFileMaxBytes(), logFileMaxFiles())
+	}
+
+	maxBytes := maxLogBytes()
+	process.logs = NewLogBuffer(maxBytes, "")
+	process.stdout = NewLogBuffer(maxBytes, "")
+	process.stderr = NewLogBuffer(maxBytes, "")
+	buffers := map[string]*LogBuffer{logStreamLogs: process.logs, logStreamStdout: process.stdout, logStreamStderr: process.stderr}
+	for stream, path := range record.LogFiles {
+		buffer, exists := buffers[stream]
+		if !exists {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			logging.ForProcess(record.PID).Warnf("Failed to restore %s of process %s: %v", stream, record.PID, err)
+			continue
+		}
+		_, _ = buffer.Write(content)
+	}
+	return process
+}
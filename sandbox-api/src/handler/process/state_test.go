@@ -0,0 +1,205 @@
+package process
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSaveAndLoadState tests that the process table and output survive a restart
+func TestSaveAndLoadState(t *testing.T) {
+	pm := NewProcessManager()
+	dir := t.TempDir()
+
+	donePID, err := pm.StartProcessWithName("echo saved output", "", "state-done", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if _, completed, err := pm.WaitForProcess(context.Background(), donePID, 5*time.Second); err != nil || !completed {
+		t.Fatalf("Process did not complete: %v", err)
+	}
+	runningPID, err := pm.StartProcessWithName("sleep 5", "", "state-running", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+
+	if err := pm.SaveState(dir); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	// Exit before the restart, so it is not adopted
+	if err := pm.KillProcess(runningPID); err != nil {
+		t.Fatalf("Failed to kill process: %v", err)
+	}
+	if running, _ := pm.GetProcessByIdentifier(runningPID); running != nil {
+		<-running.Done()
+	}
+
+	restored := NewProcessManager()
+	if err := restored.LoadState(dir); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+
+	process, exists := restored.GetProcessByIdentifier("state-done")
+	if !exists {
+		t.Fatal("Expected completed process to be restored")
+	}
+	if process.PID != donePID || process.Status != StatusCompleted || process.Command != "echo saved output" {
+		t.Errorf("Unexpected restored process: %+v", process)
+	}
+	logs, err := restored.GetProcessOutput(donePID)
+	if err != nil || logs.Stdout != "saved output\n" || logs.Logs != "saved output\n" {
+		t.Errorf("Expected output to be restored, got %+v (%v)", logs, err)
+	}
+
+	process, exists = restored.GetProcessByIdentifier(runningPID)
+	if !exists || process.Status != StatusStopped || process.CompletedAt == nil {
+		t.Fatalf("Expected running process to be restored as stopped, got %+v", process)
+	}
+	if !strings.Contains(*process.Logs, "running when the API shut down") {
+		t.Errorf("Expected a shutdown notice in the logs, got %q", *process.Logs)
+	}
+	select {
+	case <-process.Done():
+	default:
+		t.Error("Expected restored process to be done")
+	}
+}
+
+// TestPersistAndAdopt tests that the persisted table allows to adopt running processes
+func TestPersistAndAdopt(t *testing.T) {
+	pm := NewProcessManager()
+	dir := t.TempDir()
+	if err := pm.PersistTo(dir); err != nil {
+		t.Fatalf("Failed to persist process table: %v", err)
+	}
+
+	pid, err := pm.StartProcessWithName("sleep 30", "", "adopted", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() { _ = pm.KillProcess(pid) }()
+
+	// Restore the table without a shutdown, as after a crash
+	restored := NewProcessManager()
+	if err := restored.LoadState(dir); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	process, exists := restored.GetProcessByIdentifier("adopted")
+	if !exists || process.Status != StatusRunning {
+		t.Fatalf("Expected running process to be adopted, got %+v", process)
+	}
+
+	if err := restored.KillProcess(pid); err != nil {
+		t.Fatalf("Failed to kill adopted process: %v", err)
+	}
+	select {
+	case <-process.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected adopted process exit to be detected")
+	}
+	if process.Status != StatusKilled || process.CompletedAt == nil {
+		t.Errorf("Expected adopted process to be killed, got status %s", process.Status)
+	}
+}
+
+// TestIsSameProcess tests that reused PIDs are not adopted
+func TestIsSameProcess(t *testing.T) {
+	pid := os.Getpid()
+	startTime, _, err := processStartTime(pid)
+	if err != nil {
+		t.Skipf("Process start times are not available: %v", err)
+	}
+	if !isSameProcess(pid, startTime) {
+		t.Error("Expected the test process to match its start time")
+	}
+	if isSameProcess(pid, startTime.Add(-time.Hour)) {
+		t.Error("Expected a different start time not to match")
+	}
+	if isSameProcess(0, startTime) {
+		t.Error("Expected PID 0 not to match")
+	}
+}
+
+// TestTerminateAll tests that shutdown terminates the running processes
+func TestTerminateAll(t *testing.T) {
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcess("sleep 30", "", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pm.TerminateAll(ctx)
+
+	process, _ := pm.GetProcessByIdentifier(pid)
+	select {
+	case <-process.Done():
+	default:
+		t.Fatalf("Expected process to be terminated, got status %s", process.Status)
+	}
+	if process.Status != StatusStopped {
+		t.Errorf("Expected process to be stopped, got %s", process.Status)
+	}
+}
+
+// TestLoadStateTwice tests that restored processes keep their output and PID across
+// several restarts, and are not replaced by new processes reusing their PID
+func TestLoadStateTwice(t *testing.T) {
+	pm := NewProcessManager()
+	dir := t.TempDir()
+
+	donePID, err := pm.StartProcessWithName("echo saved output", "", "state-twice", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if _, completed, err := pm.WaitForProcess(context.Background(), donePID, 5*time.Second); err != nil || !completed {
+		t.Fatalf("Process did not complete: %v", err)
+	}
+	if err := pm.SaveState(dir); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	// The table persisted after the first restart still points to the saved output
+	restored := NewProcessManager()
+	for range 2 {
+		if err := restored.LoadState(dir); err != nil {
+			t.Fatalf("Failed to load state: %v", err)
+		}
+	}
+	if processes := restored.ListProcesses(); len(processes) != 1 {
+		t.Fatalf("Expected loading twice to restore the process once, got %d processes", len(processes))
+	}
+	if err := restored.PersistTo(dir); err != nil {
+		t.Fatalf("Failed to persist process table: %v", err)
+	}
+
+	again := NewProcessManager()
+	if err := again.LoadState(dir); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	logs, err := again.GetProcessOutput(donePID)
+	if err != nil || logs.Stdout != "saved output\n" {
+		t.Errorf("Expected output to be restored after a second restart, got %+v (%v)", logs, err)
+	}
+
+	// A new process already holds the PID of the saved one
+	reused := NewProcessManager()
+	reused.processes[donePID] = &ProcessInfo{PID: donePID, Name: "new", StartedAt: time.Now(), done: make(chan struct{})}
+	if err := reused.LoadState(dir); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if process, _ := reused.GetProcessByIdentifier(donePID); process.Name != "new" {
+		t.Errorf("Expected the new process to keep its PID, got %+v", process)
+	}
+	process, exists := reused.GetProcessByIdentifier("state-twice")
+	if !exists || process.PID != donePID+"-2" {
+		t.Fatalf("Expected the saved process to be restored with another PID, got %+v", process)
+	}
+	if logs, err := reused.GetProcessOutput(process.PID); err != nil || logs.Stdout != "saved output\n" {
+		t.Errorf("Expected output to be restored, got %+v (%v)", logs, err)
+	}
+}
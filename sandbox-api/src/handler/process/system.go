@@ -0,0 +1,220 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+)
+
+// kernelThreadsParent is the PID of kthreadd, the parent of every kernel thread
+const kernelThreadsParent = 2
+
+var (
+	// ErrProcessManaged is returned when adopting an OS process which is already managed
+	ErrProcessManaged = apierror.New(apierror.CodeProcAlreadyManaged, "process is already managed")
+	// ErrSystemProcessNotFound is returned when adopting an OS process which does not exist
+	ErrSystemProcessNotFound = apierror.New(apierror.CodeProcNotFound, "OS process not found")
+)
+
+// SystemProcess is an OS process of the sandbox
+type SystemProcess struct {
+	PID     int    `json:"pid" example:"4321" binding:"required"`
+	PPID    int    `json:"ppid" example:"1" binding:"required"`
+	PGID    int    `json:"pgid" example:"4321" binding:"required"`
+	Name    string `json:"name" example:"nginx" binding:"required"`
+	Command string `json:"command" example:"nginx: master process /usr/sbin/nginx" binding:"required"`
+	// State is the state letter of the process: R running, S sleeping, Z zombie...
+	State     string    `json:"state" example:"S" binding:"required"`
+	User      string    `json:"user" example:"root" binding:"required"`
+	StartedAt time.Time `json:"startedAt" binding:"required"`
+	// ManagedBy is the identifier of the managed process this process is, or descends from
+	ManagedBy string `json:"managedBy,omitempty" example:"1234"`
+	// Orphaned is true for unmanaged processes reparented to init or to the API, such as
+	// daemons that double-forked out of a managed process
+	Orphaned bool `json:"orphaned" example:"true" binding:"required"`
+} // @name SystemProcess
+
+// ListSystemProcesses returns the OS processes of the sandbox, read from /proc, except
+// kernel threads and the API itself, sorted by PID
+func (pm *ProcessManager) ListSystemProcesses() ([]SystemProcess, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OS processes: %w", err)
+	}
+
+	self := os.Getpid()
+	stats := make(map[int]procStat)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		// Processes may exit while being listed
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		stats[pid] = stat
+	}
+
+	// Managed processes run in process groups of their own
+	managed := make(map[int]string)
+	for _, process := range pm.ListProcesses() {
+		if process.State().Status == StatusRunning && process.ProcessPid > 0 {
+			managed[process.ProcessPid] = process.PID
+		}
+	}
+	managedBy := func(pid int) string {
+		// Walk up the ancestors, bounded in case of a cycle from PID reuse
+		for i := 0; i < len(stats) && pid > 1; i++ {
+			stat, exists := stats[pid]
+			if !exists {
+				break
+			}
+			if identifier, exists := managed[pid]; exists {
+				return identifier
+			}
+			if identifier, exists := managed[stat.pgid]; exists {
+				return identifier
+			}
+			pid = stat.ppid
+		}
+		return ""
+	}
+
+	users := make(map[string]string)
+	processes := make([]SystemProcess, 0, len(stats))
+	for pid, stat := range stats {
+		if pid == self || pid == kernelThreadsParent || stat.ppid == kernelThreadsParent {
+			continue
+		}
+		process := SystemProcess{
+			PID:       pid,
+			PPID:      stat.ppid,
+			PGID:      stat.pgid,
+			Name:      stat.name,
+			Command:   processCommandLine(pid, stat.name),
+			State:     stat.state,
+			User:      processUser(pid, users),
+			StartedAt: stat.startTime,
+			ManagedBy: managedBy(pid),
+		}
+		process.Orphaned = process.ManagedBy == "" && pid != 1 && (stat.ppid == 1 || stat.ppid == self)
+		processes = append(processes, process)
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
+
+// AdoptProcess manages a running OS process which was not started by the API, so
+// that it can be stopped, killed and waited for like the processes it started. Its
+// output is not captured, and it is not restarted when it exits.
+func (pm *ProcessManager) AdoptProcess(pid int, name string) (*ProcessInfo, error) {
+	if pid == os.Getpid() {
+		return nil, fmt.Errorf("cannot adopt the sandbox API itself")
+	}
+
+	processes, err := pm.ListSystemProcesses()
+	if err != nil {
+		return nil, err
+	}
+	var system *SystemProcess
+	for i := range processes {
+		if processes[i].PID == pid {
+			system = &processes[i]
+			break
+		}
+	}
+	if system == nil || system.State == "Z" {
+		return nil, fmt.Errorf("%w: %d", ErrSystemProcessNotFound, pid)
+	}
+	if system.ManagedBy == strconv.Itoa(pid) {
+		return nil, fmt.Errorf("%w: %d", ErrProcessManaged, pid)
+	}
+	if system.ManagedBy != "" {
+		return nil, fmt.Errorf("%w: process %d belongs to process %s", ErrProcessManaged, pid, system.ManagedBy)
+	}
+
+	workingDir, _ := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	// The PID may belong to the record of a process which exited
+	pm.mu.RLock()
+	identifier := pm.uniquePIDLocked(strconv.Itoa(pid))
+	pm.mu.RUnlock()
+	process := &ProcessInfo{
+		PID:           identifier,
+		Name:          name,
+		Command:       system.Command,
+		ProcessPid:    pid,
+		StartedAt:     system.StartedAt,
+		Status:        StatusRunning,
+		WorkingDir:    workingDir,
+		RunAsUser:     system.User,
+		RestartPolicy: RestartPolicyNever,
+		Adopted:       true,
+		logWriters:    make([]io.Writer, 0),
+		done:          make(chan struct{}),
+	}
+	process.initLogBuffers()
+	process.logs.WriteString("[Process adopted, its output is not captured]\n")
+
+	pm.mu.Lock()
+	if _, exists := pm.processes[process.PID]; exists {
+		pm.mu.Unlock()
+		return nil, fmt.Errorf("%w: a process with PID %s is already recorded, remove its record first", ErrProcessManaged, process.PID)
+	}
+	pm.processes[process.PID] = process
+	pm.mu.Unlock()
+
+	logging.ForProcess(process.PID).Infof("Adopted OS process %d (%s)", pid, system.Name)
+	pm.persist()
+	go pm.watchAdopted(process)
+	return process, nil
+}
+
+// processCommandLine returns the command line of the OS process pid, or its name in
+// brackets when it has none
+func processCommandLine(pid int, name string) string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil || len(cmdline) == 0 {
+		return "[" + name + "]"
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+}
+
+// processUser returns the name, or the id, of the real user of the OS process pid,
+// caching names in users
+func processUser(pid int, users map[string]string) string {
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		value, found := strings.CutPrefix(line, "Uid:")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return ""
+		}
+		uid := fields[0]
+		if name, cached := users[uid]; cached {
+			return name
+		}
+		name := uid
+		if u, err := user.LookupId(uid); err == nil {
+			name = u.Username
+		}
+		users[uid] = name
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAdoptProcess tests listing a daemon escaping its managed process and adopting it
+func TestAdoptProcess(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc unavailable")
+	}
+	pm := NewProcessManager()
+
+	// The shell starts a daemon in a session of its own and exits
+	parentPID, err := pm.StartProcessWithName("setsid sleep 37 > /dev/null 2>&1 & echo $!", "", "daemonize", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if _, completed, err := pm.WaitForProcess(context.Background(), parentPID, 5*time.Second); err != nil || !completed {
+		t.Fatalf("Expected the parent to complete (%v)", err)
+	}
+	logs, err := pm.GetProcessOutput(parentPID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(logs.Stdout))
+	if err != nil {
+		t.Fatalf("Unexpected daemon PID %q", logs.Stdout)
+	}
+	t.Cleanup(func() { _ = pm.KillProcess(strconv.Itoa(pid)) })
+
+	// The daemon may not have started its session and run sleep yet
+	var daemon *SystemProcess
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		processes, err := pm.ListSystemProcesses()
+		if err != nil {
+			t.Fatalf("Failed to list OS processes: %v", err)
+		}
+		for i := range processes {
+			if processes[i].PID == os.Getpid() {
+				t.Fatal("Expected the API itself not to be listed")
+			}
+			if processes[i].PID == pid {
+				daemon = &processes[i]
+			}
+		}
+		if daemon != nil && daemon.Command == "sleep 37" && daemon.PGID == pid {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if daemon == nil || daemon.ManagedBy != "" || daemon.Command != "sleep 37" || daemon.PGID != pid {
+		t.Fatalf("Expected an unmanaged daemon, got %+v", daemon)
+	}
+
+	adopted, err := pm.AdoptProcess(pid, "daemon")
+	if err != nil {
+		t.Fatalf("Failed to adopt process: %v", err)
+	}
+	if !adopted.Adopted || adopted.Status != StatusRunning || adopted.PID != strconv.Itoa(pid) {
+		t.Errorf("Unexpected adopted process %+v", adopted)
+	}
+	if _, err := pm.AdoptProcess(pid, ""); !errors.Is(err, ErrProcessManaged) {
+		t.Errorf("Expected adopting twice to fail, got %v", err)
+	}
+	if _, err := pm.AdoptProcess(999999999, ""); !errors.Is(err, ErrSystemProcessNotFound) {
+		t.Errorf("Expected adopting a missing process to fail, got %v", err)
+	}
+
+	// Adopted processes are managed like the others
+	if err := pm.KillProcess("daemon"); err != nil {
+		t.Fatalf("Failed to kill adopted process: %v", err)
+	}
+	select {
+	case <-adopted.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the adopted process to be done once killed")
+	}
+	if adopted.Status != StatusKilled {
+		t.Errorf("Expected the adopted process to be killed, got %s", adopted.Status)
+	}
+}
+
+// TestAdoptManagedProcess tests refusing to adopt processes started by the API
+func TestAdoptManagedProcess(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc unavailable")
+	}
+	pm := NewProcessManager()
+
+	pid, err := pm.StartProcessWithName("sleep 38 & wait", "", "managed", nil, false, 0, func(*ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	t.Cleanup(func() { _ = pm.KillProcess(pid) })
+
+	var child *SystemProcess
+	deadline := time.Now().Add(5 * time.Second)
+	for child == nil && time.Now().Before(deadline) {
+		processes, err := pm.ListSystemProcesses()
+		if err != nil {
+			t.Fatalf("Failed to list OS processes: %v", err)
+		}
+		for i := range processes {
+			if processes[i].Command == "sleep 38" {
+				child = &processes[i]
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if child == nil || child.ManagedBy != pid || child.Orphaned {
+		t.Fatalf("Expected the child to be managed by %s, got %+v", pid, child)
+	}
+	if _, err := pm.AdoptProcess(child.PID, ""); !errors.Is(err, ErrProcessManaged) {
+		t.Errorf("Expected adopting a managed process to fail, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Template sources
+const (
+	TemplateSourceFile = "file"
+	TemplateSourceAPI  = "api"
+)
+
+// templatePlaceholder matches the {{parameter}} references of a template
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// TemplateParameter is a parameter of a process template
+type TemplateParameter struct {
+	Name        string `json:"name" example:"port" binding:"required"`
+	Description string `json:"description,omitempty" example:"Port the dev server listens on"`
+	Default     string `json:"default,omitempty" example:"3000"`
+	Required    bool   `json:"required,omitempty" example:"false"`
+} // @name ProcessTemplateParameter
+
+// Template is a named command preset. The command, working directory, environment values
+// and readiness log pattern may reference parameters as {{name}}, substituted when the
+// template is instantiated.
+type Template struct {
+	Name          string              `json:"name" example:"dev-server" binding:"required"`
+	Description   string              `json:"description,omitempty" example:"Next.js dev server"`
+	Command       string              `json:"command" example:"npm run dev -- --port {{port}}" binding:"required"`
+	WorkingDir    string              `json:"workingDir,omitempty" example:"/home/user/app"`
+	Env           map[string]string   `json:"env,omitempty" example:"{\"PORT\": \"{{port}}\"}"`
+	Parameters    []TemplateParameter `json:"parameters,omitempty"`
+	ReadyWhen     *ReadinessCondition `json:"readyWhen,omitempty"`
+	RestartPolicy string              `json:"restartPolicy,omitempty" example:"on-failure" enums:"never,on-failure,always"`
+	MaxRestarts   int                 `json:"maxRestarts,omitempty" example:"3"`
+	Source        string              `json:"source" example:"api" enums:"file,api"`
+	CreatedAt     time.Time           `json:"createdAt"`
+} // @name ProcessTemplate
+
+// Validate checks that the template has a name and a command, that its parameters are
+// unique and that it only references declared parameters
+func (t Template) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if t.Command == "" {
+		return fmt.Errorf("template command is required")
+	}
+	declared := make(map[string]bool, len(t.Parameters))
+	for _, parameter := range t.Parameters {
+		if !templatePlaceholder.MatchString("{{" + parameter.Name + "}}") {
+			return fmt.Errorf("invalid parameter name '%s'", parameter.Name)
+		}
+		if declared[parameter.Name] {
+			return fmt.Errorf("duplicate parameter '%s'", parameter.Name)
+		}
+		declared[parameter.Name] = true
+	}
+	for _, text := range t.templatedFields() {
+		for _, match := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+			if !declared[match[1]] {
+				return fmt.Errorf("undeclared parameter '%s' referenced", match[1])
+			}
+		}
+	}
+	if t.ReadyWhen != nil {
+		// The log pattern is validated once rendered, parameters may not form a valid regex
+		ports := ReadinessCondition{Ports: t.ReadyWhen.Ports, Timeout: t.ReadyWhen.Timeout}
+		if err := ports.Validate(); err != nil {
+			return err
+		}
+	}
+	_, err := NewRestartConfig(t.RestartPolicy, false, t.MaxRestarts, nil, 0)
+	return err
+}
+
+// templatedFields returns the fields of the template which may reference parameters
+func (t Template) templatedFields() []string {
+	fields := []string{t.Command, t.WorkingDir}
+	for _, value := range t.Env {
+		fields = append(fields, value)
+	}
+	if t.ReadyWhen != nil {
+		fields = append(fields, t.ReadyWhen.LogPattern)
+	}
+	return fields
+}
+
+// Render returns the template with its parameters substituted by the given values, or
+// their defaults. It fails on unknown parameters and missing required ones.
+func (t Template) Render(values map[string]string) (Template, error) {
+	resolved := make(map[string]string, len(t.Parameters))
+	for _, parameter := range t.Parameters {
+		value, given := values[parameter.Name]
+		if !given {
+			if parameter.Required {
+				return Template{}, fmt.Errorf("missing required parameter '%s'", parameter.Name)
+			}
+			value = parameter.Default
+		}
+		resolved[parameter.Name] = value
+	}
+	for name := range values {
+		if _, declared := resolved[name]; !declared {
+			return Template{}, fmt.Errorf("unknown parameter '%s' for template %s", name, t.Name)
+		}
+	}
+
+	substitute := func(text string) string {
+		return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+			return resolved[templatePlaceholder.FindStringSubmatch(placeholder)[1]]
+		})
+	}
+	rendered := t
+	rendered.Command = substitute(t.Command)
+	rendered.WorkingDir = substitute(t.WorkingDir)
+	if t.Env != nil {
+		rendered.Env = make(map[string]string, len(t.Env))
+		for key, value := range t.Env {
+			rendered.Env[key] = substitute(value)
+		}
+	}
+	if t.ReadyWhen != nil {
+		readyWhen := *t.ReadyWhen
+		readyWhen.LogPattern = substitute(readyWhen.LogPattern)
+		if err := readyWhen.Validate(); err != nil {
+			return Template{}, err
+		}
+		rendered.ReadyWhen = &readyWhen
+	}
+	return rendered, nil
+}
+
+// TemplateRegistry holds the process templates of the sandbox
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]Template
+}
+
+var (
+	templateRegistry     *TemplateRegistry
+	templateRegistryOnce sync.Once
+)
+
+// GetTemplateRegistry returns the template registry, with the templates of the JSON file
+// set with PROCESS_TEMPLATES_FILE preloaded, so that sandbox images can ship them
+func GetTemplateRegistry() *TemplateRegistry {
+	templateRegistryOnce.Do(func() {
+		templateRegistry = NewTemplateRegistry()
+		if path := os.Getenv("PROCESS_TEMPLATES_FILE"); path != "" {
+			if err := templateRegistry.LoadFile(path); err != nil {
+				logrus.Warnf("Failed to load process templates from %s: %v", path, err)
+			}
+		}
+	})
+	return templateRegistry
+}
+
+// NewTemplateRegistry creates an empty template registry
+func NewTemplateRegistry() *TemplateRegistry {
+	return &TemplateRegistry{templates: make(map[string]Template)}
+}
+
+// LoadFile registers the templates of a JSON file holding an array of templates
+func (r *TemplateRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var templates []Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("invalid templates file: %w", err)
+	}
+	for _, template := range templates {
+		template.Source = TemplateSourceFile
+		if _, err := r.Register(template); err != nil {
+			return fmt.Errorf("template %s: %w", template.Name, err)
+		}
+	}
+	logrus.Infof("Loaded %d process templates from %s", len(templates), path)
+	return nil
+}
+
+// Register adds a template, replacing the template with the same name
+func (r *TemplateRegistry) Register(template Template) (Template, error) {
+	template.Name = strings.TrimSpace(template.Name)
+	if err := template.Validate(); err != nil {
+		return Template{}, err
+	}
+	if template.Source == "" {
+		template.Source = TemplateSourceAPI
+	}
+	template.CreatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[template.Name] = template
+	return template, nil
+}
+
+// Get returns a template by name
+func (r *TemplateRegistry) Get(name string) (Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	template, exists := r.templates[name]
+	return template, exists
+}
+
+// List returns the templates sorted by name
+func (r *TemplateRegistry) List() []Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	templates := make([]Template, 0, len(r.templates))
+	for _, template := range r.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// Delete removes a template
+func (r *TemplateRegistry) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.templates[name]; !exists {
+		return apierror.Newf(apierror.CodeProcTemplateNotFound, "process template %s not found", name)
+	}
+	delete(r.templates, name)
+	return nil
+}
@@ -0,0 +1,97 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTemplateRender tests substituting the parameters of a template
+func TestTemplateRender(t *testing.T) {
+	template := Template{
+		Name:       "dev-server",
+		Command:    "npm run dev -- --port {{port}} --host {{ host }}",
+		WorkingDir: "/home/user/{{app}}",
+		Env:        map[string]string{"PORT": "{{port}}"},
+		Parameters: []TemplateParameter{
+			{Name: "port", Default: "3000"},
+			{Name: "host", Default: "0.0.0.0"},
+			{Name: "app", Required: true},
+		},
+		ReadyWhen: &ReadinessCondition{Ports: []int{3000}, LogPattern: "listening on {{port}}"},
+	}
+	if err := template.Validate(); err != nil {
+		t.Fatalf("Expected the template to be valid: %v", err)
+	}
+
+	rendered, err := template.Render(map[string]string{"port": "4000", "app": "web"})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if rendered.Command != "npm run dev -- --port 4000 --host 0.0.0.0" {
+		t.Errorf("Unexpected command %q", rendered.Command)
+	}
+	if rendered.WorkingDir != "/home/user/web" || rendered.Env["PORT"] != "4000" || rendered.ReadyWhen.LogPattern != "listening on 4000" {
+		t.Errorf("Unexpected rendered template %+v", rendered)
+	}
+	if template.Env["PORT"] != "{{port}}" || template.ReadyWhen.LogPattern != "listening on {{port}}" {
+		t.Error("Expected the template not to be modified")
+	}
+
+	if _, err := template.Render(map[string]string{"port": "4000"}); err == nil {
+		t.Error("Expected an error for a missing required parameter")
+	}
+	if _, err := template.Render(map[string]string{"app": "web", "other": "x"}); err == nil {
+		t.Error("Expected an error for an unknown parameter")
+	}
+}
+
+// TestTemplateValidate tests rejecting invalid templates
+func TestTemplateValidate(t *testing.T) {
+	invalid := map[string]Template{
+		"no command":           {Name: "a"},
+		"undeclared parameter": {Name: "a", Command: "echo {{missing}}"},
+		"duplicate parameter":  {Name: "a", Command: "echo", Parameters: []TemplateParameter{{Name: "x"}, {Name: "x"}}},
+		"invalid parameter":    {Name: "a", Command: "echo", Parameters: []TemplateParameter{{Name: "not valid"}}},
+		"invalid port":         {Name: "a", Command: "echo", ReadyWhen: &ReadinessCondition{Ports: []int{70000}}},
+		"invalid policy":       {Name: "a", Command: "echo", RestartPolicy: "sometimes"},
+	}
+	for name, template := range invalid {
+		if err := template.Validate(); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+// TestTemplateRegistry tests registering templates and loading them from a file
+func TestTemplateRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	content := `[{"name": "worker", "command": "python worker.py --queue {{queue}}", "parameters": [{"name": "queue", "default": "default"}]}]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewTemplateRegistry()
+	if err := registry.LoadFile(path); err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	worker, exists := registry.Get("worker")
+	if !exists || worker.Source != TemplateSourceFile {
+		t.Fatalf("Expected the file template to be registered, got %+v", worker)
+	}
+
+	if _, err := registry.Register(Template{Name: "build", Command: "make"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	templates := registry.List()
+	if len(templates) != 2 || templates[0].Name != "build" || templates[0].Source != TemplateSourceAPI {
+		t.Fatalf("Unexpected templates %+v", templates)
+	}
+
+	if err := registry.Delete("build"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := registry.Delete("build"); err == nil {
+		t.Error("Expected an error deleting a missing template")
+	}
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Actions of bulk process operations
+const (
+	ProcessBulkActionStop   = "stop"
+	ProcessBulkActionKill   = "kill"
+	ProcessBulkActionDelete = "delete"
+)
+
+// ProcessBulkFilter selects the processes of a bulk operation, every condition must match.
+// At least one condition is required.
+type ProcessBulkFilter struct {
+	Status     string `json:"status" example:"running" enums:"failed,killed,stopped,running,completed,timedout"`
+	NamePrefix string `json:"namePrefix" example:"worker-"`
+} // @name ProcessBulkFilter
+
+// ProcessBulkRequest is the request body for applying an action to several processes,
+// given by identifiers or selected by a filter
+type ProcessBulkRequest struct {
+	// Action is stop, kill, or delete to remove the records of terminated processes
+	Action      string             `json:"action" example:"stop" enums:"stop,kill,delete" binding:"required"`
+	Identifiers []string           `json:"identifiers" example:"1234,my-process"`
+	Filter      *ProcessBulkFilter `json:"filter"`
+	// StopTimeoutSeconds is the number of seconds after which stopped processes are killed, 0 to never kill them
+	StopTimeoutSeconds int `json:"stopTimeoutSeconds" example:"10"`
+} // @name ProcessBulkRequest
+
+// ProcessBulkResult is the result of a bulk operation for one process
+type ProcessBulkResult struct {
+	Identifier string `json:"identifier" example:"worker-1" binding:"required"`
+	PID        string `json:"pid,omitempty" example:"1234"`
+	Success    bool   `json:"success" example:"true" binding:"required"`
+	Error      string `json:"error,omitempty" example:"process with Identifier 1234 is not running"`
+} // @name ProcessBulkResult
+
+// ProcessBulkResponse is the response body of a bulk operation
+type ProcessBulkResponse struct {
+	Results   []ProcessBulkResult `json:"results" binding:"required"`
+	Succeeded int                 `json:"succeeded" example:"20" binding:"required"`
+	Failed    int                 `json:"failed" example:"0" binding:"required"`
+} // @name ProcessBulkResponse
+
+// BulkProcesses applies an action to the processes given by identifiers, or to the
+// processes matching the filter, and returns the result for each of them
+func (h *ProcessHandler) BulkProcesses(req ProcessBulkRequest) (ProcessBulkResponse, error) {
+	var apply func(identifier string) error
+	switch req.Action {
+	case ProcessBulkActionStop:
+		if req.StopTimeoutSeconds < 0 {
+			return ProcessBulkResponse{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid stopTimeoutSeconds: must be a non-negative number")
+		}
+		timeout := time.Duration(req.StopTimeoutSeconds) * time.Second
+		apply = func(identifier string) error { return h.StopProcessWithTimeout(identifier, timeout) }
+	case ProcessBulkActionKill:
+		apply = h.KillProcess
+	case ProcessBulkActionDelete:
+		apply = h.RemoveProcess
+	default:
+		return ProcessBulkResponse{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid action '%s': must be stop, kill or delete", req.Action)
+	}
+
+	identifiers, err := h.bulkIdentifiers(req)
+	if err != nil {
+		return ProcessBulkResponse{}, err
+	}
+
+	response := ProcessBulkResponse{Results: make([]ProcessBulkResult, 0, len(identifiers))}
+	for _, identifier := range identifiers {
+		result := ProcessBulkResult{Identifier: identifier}
+		if processInfo, exists := h.processManager.GetProcessByIdentifier(identifier); exists {
+			result.PID = processInfo.PID
+		}
+		if err := apply(identifier); err != nil {
+			result.Error = err.Error()
+			response.Failed++
+		} else {
+			result.Success = true
+			response.Succeeded++
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
+// bulkIdentifiers returns the identifiers of the processes of a bulk operation, without
+// duplicates. Processes selected by the filter are identified by their PID, oldest first.
+func (h *ProcessHandler) bulkIdentifiers(req ProcessBulkRequest) ([]string, error) {
+	if len(req.Identifiers) > 0 && req.Filter != nil {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "identifiers and filter cannot both be set")
+	}
+	if len(req.Identifiers) == 0 && req.Filter == nil {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "either identifiers or a filter is required")
+	}
+
+	identifiers := make([]string, 0)
+	if req.Filter == nil {
+		seen := make(map[string]bool)
+		for _, identifier := range req.Identifiers {
+			if identifier == "" || seen[identifier] {
+				continue
+			}
+			seen[identifier] = true
+			identifiers = append(identifiers, identifier)
+		}
+		if len(identifiers) == 0 {
+			return nil, apierror.Newf(apierror.CodeInvalidRequest, "identifiers cannot be empty")
+		}
+		return identifiers, nil
+	}
+
+	// An empty filter would select every process
+	if req.Filter.Status == "" && req.Filter.NamePrefix == "" {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "filter requires a status or a namePrefix")
+	}
+
+	status := constants.ProcessStatus(req.Filter.Status)
+	switch status {
+	case "", constants.ProcessStatusRunning, constants.ProcessStatusCompleted, constants.ProcessStatusFailed, constants.ProcessStatusStopped, constants.ProcessStatusKilled, constants.ProcessStatusTimedOut:
+	default:
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid status '%s': must be running, completed, failed, stopped, killed or timedout", status)
+	}
+
+	processes := h.processManager.ListProcesses()
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].StartedAt.Before(processes[j].StartedAt)
+	})
+	for _, p := range processes {
+		if status != "" && p.State().Status != status {
+			continue
+		}
+		if !strings.HasPrefix(p.Name, req.Filter.NamePrefix) {
+			continue
+		}
+		identifiers = append(identifiers, p.PID)
+	}
+	return identifiers, nil
+}
+
+// HandleBulkProcesses handles POST requests to /process/bulk
+// @Summary Apply an action to several processes
+// @Description Stop, kill or delete the records of the processes given by identifiers, or of the processes matching a filter, and return the result for each of them. The filter needs a status or a namePrefix, and processes matching it are identified by their PID. Stopped processes are killed when still running after stopTimeoutSeconds, and only terminated processes can be deleted.
+// @Tags process
+// @Accept json
+// @Produce json
+// @Param request body ProcessBulkRequest true "Bulk operation"
+// @Success 200 {object} ProcessBulkResponse "Result for each process"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Router /process/bulk [post]
+func (h *ProcessHandler) HandleBulkProcesses(c *gin.Context) {
+	var req ProcessBulkRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	response, err := h.BulkProcesses(req)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, response)
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/service"
+)
+
+// newTestProcessHandler returns a process handler with its own process manager
+func newTestProcessHandler() *ProcessHandler {
+	processManager := process.NewProcessManager()
+	return &ProcessHandler{
+		BaseHandler:    NewBaseHandler(),
+		processManager: processManager,
+		processes:      service.NewProcess(processManager),
+	}
+}
+
+// startTestProcess starts a named process, waiting for it to complete when wait is set
+func startTestProcess(t *testing.T, h *ProcessHandler, command string, name string, wait bool) string {
+	t.Helper()
+	pid, err := h.processManager.StartProcessWithName(command, "", name, nil, false, 0, func(*process.ProcessInfo) {})
+	if err != nil {
+		t.Fatalf("Failed to start process %s: %v", name, err)
+	}
+	t.Cleanup(func() { _ = h.processManager.KillProcess(pid) })
+	if wait {
+		info, _ := h.processManager.GetProcessByIdentifier(pid)
+		select {
+		case <-info.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Process %s did not complete", name)
+		}
+	}
+	return pid
+}
+
+// resultPIDs returns the PIDs of the results of a bulk operation
+func resultPIDs(response ProcessBulkResponse) []string {
+	pids := make([]string, 0, len(response.Results))
+	for _, result := range response.Results {
+		pids = append(pids, result.PID)
+	}
+	return pids
+}
+
+// TestBulkProcesses tests selecting the processes of bulk operations
+func TestBulkProcesses(t *testing.T) {
+	h := newTestProcessHandler()
+	worker1 := startTestProcess(t, h, "sleep 30", "worker-1", false)
+	worker2 := startTestProcess(t, h, "sleep 30", "worker-2", false)
+	other := startTestProcess(t, h, "sleep 30", "other", false)
+	done := startTestProcess(t, h, "true", "worker-done", true)
+
+	t.Run("EmptyFilter", func(t *testing.T) {
+		for _, req := range []ProcessBulkRequest{
+			{Action: ProcessBulkActionKill, Filter: &ProcessBulkFilter{}},
+			{Action: ProcessBulkActionKill, Identifiers: []string{""}},
+		} {
+			_, err := h.BulkProcesses(req)
+			if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeInvalidRequest {
+				t.Errorf("Expected an INVALID_REQUEST error, got %v", err)
+			}
+		}
+		for _, pid := range []string{worker1, worker2, other} {
+			if info, _ := h.processManager.GetProcessByIdentifier(pid); info.State().Status != process.StatusRunning {
+				t.Errorf("Expected process %s to keep running, got %s", pid, info.State().Status)
+			}
+		}
+	})
+
+	t.Run("StatusFilter", func(t *testing.T) {
+		response, err := h.BulkProcesses(ProcessBulkRequest{
+			Action: ProcessBulkActionDelete,
+			Filter: &ProcessBulkFilter{Status: string(process.StatusCompleted)},
+		})
+		if err != nil {
+			t.Fatalf("Failed to delete processes: %v", err)
+		}
+		if pids := resultPIDs(response); !slices.Equal(pids, []string{done}) || response.Succeeded != 1 {
+			t.Errorf("Expected the completed process to be deleted, got %+v", response)
+		}
+		if _, exists := h.processManager.GetProcessByIdentifier(done); exists {
+			t.Errorf("Expected process %s to be removed", done)
+		}
+	})
+
+	t.Run("NameFilter", func(t *testing.T) {
+		response, err := h.BulkProcesses(ProcessBulkRequest{
+			Action: ProcessBulkActionKill,
+			Filter: &ProcessBulkFilter{NamePrefix: "worker-"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to kill processes: %v", err)
+		}
+		if pids := resultPIDs(response); !slices.Equal(pids, []string{worker1, worker2}) || response.Succeeded != 2 {
+			t.Errorf("Expected the workers to be killed, got %+v", response)
+		}
+		if info, _ := h.processManager.GetProcessByIdentifier(other); info.State().Status != process.StatusRunning {
+			t.Errorf("Expected process %s to keep running, got %s", other, info.State().Status)
+		}
+	})
+
+	t.Run("PartialFailure", func(t *testing.T) {
+		// Running processes cannot be deleted, and unknown ones fail on their own
+		response, err := h.BulkProcesses(ProcessBulkRequest{
+			Action:      ProcessBulkActionDelete,
+			Identifiers: []string{"missing", other, "missing"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to delete processes: %v", err)
+		}
+		if len(response.Results) != 2 || response.Failed != 2 || response.Succeeded != 0 {
+			t.Fatalf("Expected 2 failed results, got %+v", response)
+		}
+
+		response, err = h.BulkProcesses(ProcessBulkRequest{
+			Action:      ProcessBulkActionKill,
+			Identifiers: []string{other, "missing"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to kill processes: %v", err)
+		}
+		if response.Succeeded != 1 || response.Failed != 1 {
+			t.Fatalf("Expected 1 succeeded and 1 failed result, got %+v", response)
+		}
+		if !response.Results[0].Success || response.Results[0].PID != other {
+			t.Errorf("Expected process %s to be killed, got %+v", other, response.Results[0])
+		}
+		if response.Results[1].Success || response.Results[1].Error == "" {
+			t.Errorf("Expected the unknown process to fail, got %+v", response.Results[1])
+		}
+	})
+}
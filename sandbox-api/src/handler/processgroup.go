@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/policy"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// ProcessGroupRequest is the request body for starting a process group
+type ProcessGroupRequest struct {
+	Name         string                     `json:"name" example:"stack" binding:"required"`
+	Processes    []process.GroupProcessSpec `json:"processes" binding:"required"`
+	WaitForReady bool                       `json:"waitForReady" example:"true"`
+	Timeout      int                        `json:"timeout" example:"120"`
+} // @name ProcessGroupRequest
+
+// HandleStartProcessGroup handles POST requests to /process-group
+// @Summary Start a process group
+// @Description Start an ordered list of processes. Each process starts once the processes listed in dependsOn are ready, and is ready once its readyWhen condition (open ports, log pattern) is met. When waitForReady is set, the request blocks until every process is ready or has failed, up to timeout seconds (default: 30, max: 600).
+// @Tags process-group
+// @Accept json
+// @Produce json
+// @Param request body ProcessGroupRequest true "Process group definition"
+// @Success 200 {object} process.GroupInfo "Process group"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 403 {object} ErrorResponse "Command denied by the process policy"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /process-group [post]
+func (h *ProcessHandler) HandleStartProcessGroup(c *gin.Context) {
+	var req ProcessGroupRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	for i := range req.Processes {
+		if req.Processes[i].WorkingDir == "" {
+			continue
+		}
+		formattedWorkingDir, err := lib.FormatPath(req.Processes[i].WorkingDir)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		req.Processes[i].WorkingDir = formattedWorkingDir
+	}
+
+	group, err := h.processManager.StartGroup(req.Name, req.Processes)
+	if errors.Is(err, policy.ErrDenied) {
+		h.SendError(c, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if req.WaitForReady {
+		timeout := req.Timeout
+		if timeout <= 0 {
+			timeout = 30
+		}
+		if timeout > maxWaitTimeout {
+			timeout = maxWaitTimeout
+		}
+		timer := time.NewTimer(time.Duration(timeout) * time.Second)
+		defer timer.Stop()
+
+		select {
+		case <-group.Settled():
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+
+	h.SendJSON(c, http.StatusOK, group.Info())
+}
+
+// HandleListProcessGroups handles GET requests to /process-group
+// @Summary List process groups
+// @Description Get the state of all process groups
+// @Tags process-group
+// @Accept json
+// @Produce json
+// @Success 200 {array} process.GroupInfo "Process groups"
+// @Router /process-group [get]
+func (h *ProcessHandler) HandleListProcessGroups(c *gin.Context) {
+	groups := h.processManager.ListGroups()
+	result := make([]process.GroupInfo, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, group.Info())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	h.SendJSON(c, http.StatusOK, result)
+}
+
+// HandleGetProcessGroup handles GET requests to /process-group/{name}
+// @Summary Get a process group
+// @Description Get the state of a process group and of its processes
+// @Tags process-group
+// @Accept json
+// @Produce json
+// @Param name path string true "Process group name"
+// @Success 200 {object} process.GroupInfo "Process group"
+// @Failure 404 {object} ErrorResponse "Process group not found"
+// @Router /process-group/{name} [get]
+func (h *ProcessHandler) HandleGetProcessGroup(c *gin.Context) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	group, exists := h.processManager.GetGroup(name)
+	if !exists {
+		h.SendError(c, http.StatusNotFound, apierror.Newf(apierror.CodeProcGroupNotFound, "process group %s not found", name))
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, group.Info())
+}
+
+// HandleStopProcessGroup handles DELETE requests to /process-group/{name}
+// @Summary Stop a process group
+// @Description Gracefully stop the running processes of a group, in the reverse order of their declaration
+// @Tags process-group
+// @Accept json
+// @Produce json
+// @Param name path string true "Process group name"
+// @Success 200 {object} SuccessResponse "Process group stopped"
+// @Failure 404 {object} ErrorResponse "Process group not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /process-group/{name} [delete]
+func (h *ProcessHandler) HandleStopProcessGroup(c *gin.Context) {
+	h.stopProcessGroup(c, false)
+}
+
+// HandleKillProcessGroup handles DELETE requests to /process-group/{name}/kill
+// @Summary Kill a process group
+// @Description Forcefully kill the running processes of a group
+// @Tags process-group
+// @Accept json
+// @Produce json
+// @Param name path string true "Process group name"
+// @Success 200 {object} SuccessResponse "Process group killed"
+// @Failure 404 {object} ErrorResponse "Process group not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /process-group/{name}/kill [delete]
+func (h *ProcessHandler) HandleKillProcessGroup(c *gin.Context) {
+	h.stopProcessGroup(c, true)
+}
+
+// stopProcessGroup stops or kills the process group named in the path
+func (h *ProcessHandler) stopProcessGroup(c *gin.Context, force bool) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, exists := h.processManager.GetGroup(name); !exists {
+		h.SendError(c, http.StatusNotFound, apierror.Newf(apierror.CodeProcGroupNotFound, "process group %s not found", name))
+		return
+	}
+
+	if err := h.processManager.StopGroup(name, force); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if force {
+		h.SendJSON(c, http.StatusOK, gin.H{"message": "Process group killed successfully"})
+		return
+	}
+	h.SendJSON(c, http.StatusOK, gin.H{"message": "Process group stopped successfully"})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+)
+
+// ProcessAdoptRequest is the request body for adopting an OS process
+type ProcessAdoptRequest struct {
+	// Name of the adopted process, to use as its identifier
+	Name string `json:"name" example:"nginx"`
+} // @name ProcessAdoptRequest
+
+// HandleListSystemProcesses handles GET requests to /process/system
+// @Summary List OS processes
+// @Description Get the OS processes of the sandbox, read from /proc, except kernel threads and the API itself. Processes started by the API, or descending from one, have the identifier of that process as managedBy. Unmanaged processes reparented to init or to the API, such as daemons that double-forked out of a managed process, are orphaned and can be adopted.
+// @Tags process
+// @Produce json
+// @Param orphaned query bool false "Only list orphaned processes"
+// @Success 200 {array} process.SystemProcess "OS processes"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /process/system [get]
+func (h *ProcessHandler) HandleListSystemProcesses(c *gin.Context) {
+	processes, err := h.processManager.ListSystemProcesses()
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if h.GetQueryParam(c, "orphaned", "false") == "true" {
+		orphaned := make([]process.SystemProcess, 0)
+		for _, p := range processes {
+			if p.Orphaned {
+				orphaned = append(orphaned, p)
+			}
+		}
+		processes = orphaned
+	}
+	h.SendJSON(c, http.StatusOK, processes)
+}
+
+// HandleAdoptProcess handles POST requests to /process/system/{pid}/adopt
+// @Summary Adopt an OS process
+// @Description Manage a running OS process which was not started by the API, so that it can be stopped, killed, signaled and waited for, and its ports looked up, using its PID or name as identifier. Its output is not captured, and it is not restarted when it exits.
+// @Tags process
+// @Accept json
+// @Produce json
+// @Param pid path int true "OS process PID"
+// @Param request body ProcessAdoptRequest false "Adopted process"
+// @Success 200 {object} ProcessResponse "Adopted process"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 404 {object} ErrorResponse "OS process not found"
+// @Failure 409 {object} ErrorResponse "Process is already managed"
+// @Router /process/system/{pid}/adopt [post]
+func (h *ProcessHandler) HandleAdoptProcess(c *gin.Context) {
+	pidParam, err := h.GetPathParam(c, "pid")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	pid, err := strconv.Atoi(pidParam)
+	if err != nil || pid < 1 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid pid: must be a positive number"))
+		return
+	}
+
+	var req ProcessAdoptRequest
+	// The body is optional, adopted processes are identified by their PID
+	if c.Request.ContentLength != 0 {
+		if err := h.BindJSON(c, &req); err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	processInfo, err := h.processManager.AdoptProcess(pid, req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, process.ErrSystemProcessNotFound):
+			h.SendError(c, http.StatusNotFound, err)
+		case errors.Is(err, process.ErrProcessManaged):
+			h.SendError(c, http.StatusConflict, err)
+		default:
+			h.SendError(c, http.StatusBadRequest, err)
+		}
+		return
+	}
+	// Fetch the process again to include its logs
+	if current, exists := h.processManager.GetProcessByIdentifier(processInfo.PID); exists {
+		processInfo = current
+	}
+	h.SendJSON(c, http.StatusOK, newProcessResponse(processInfo))
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/service"
+)
+
+// ProcessFromTemplateRequest is the request body for starting a process from a template
+type ProcessFromTemplateRequest struct {
+	Parameters map[string]string `json:"parameters" example:"{\"port\": \"3000\"}"`
+	// Name of the process, the template name when empty
+	Name string `json:"name" example:"dev-server"`
+	// Env is merged over the environment of the template
+	Env          map[string]string `json:"env" example:"{\"DEBUG\": \"1\"}"`
+	WorkingDir   string            `json:"workingDir" example:"/home/user/app"`
+	RunAsUser    string            `json:"runAsUser" example:"1000"`
+	RunAsGroup   string            `json:"runAsGroup" example:"1000"`
+	WaitForReady bool              `json:"waitForReady" example:"true"`
+} // @name ProcessFromTemplateRequest
+
+// HandleListProcessTemplates handles GET requests to /process-templates
+// @Summary List process templates
+// @Description Get the registered process templates, including the ones loaded from PROCESS_TEMPLATES_FILE
+// @Tags process-template
+// @Produce json
+// @Success 200 {array} process.Template "Process templates"
+// @Router /process-templates [get]
+func (h *ProcessHandler) HandleListProcessTemplates(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, process.GetTemplateRegistry().List())
+}
+
+// HandleRegisterProcessTemplate handles POST requests to /process-templates
+// @Summary Register a process template
+// @Description Register a named command preset, replacing the template with the same name. The command, working directory, environment values and readiness log pattern may reference the declared parameters as {{name}}.
+// @Tags process-template
+// @Accept json
+// @Produce json
+// @Param request body process.Template true "Process template"
+// @Success 200 {object} process.Template "Registered template"
+// @Failure 400 {object} ErrorResponse "Invalid template"
+// @Router /process-templates [post]
+func (h *ProcessHandler) HandleRegisterProcessTemplate(c *gin.Context) {
+	var template process.Template
+	if err := h.BindJSON(c, &template); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	template.Source = process.TemplateSourceAPI
+
+	registered, err := process.GetTemplateRegistry().Register(template)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, registered)
+}
+
+// HandleGetProcessTemplate handles GET requests to /process-templates/{name}
+// @Summary Get a process template
+// @Description Get a process template by name
+// @Tags process-template
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} process.Template "Process template"
+// @Failure 404 {object} ErrorResponse "Template not found"
+// @Router /process-templates/{name} [get]
+func (h *ProcessHandler) HandleGetProcessTemplate(c *gin.Context) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	template, exists := process.GetTemplateRegistry().Get(name)
+	if !exists {
+		h.SendError(c, http.StatusNotFound, apierror.Newf(apierror.CodeProcTemplateNotFound, "process template %s not found", name))
+		return
+	}
+	h.SendJSON(c, http.StatusOK, template)
+}
+
+// HandleDeleteProcessTemplate handles DELETE requests to /process-templates/{name}
+// @Summary Delete a process template
+// @Description Delete a process template by name. Processes started from it are not affected.
+// @Tags process-template
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} SuccessResponse "Template deleted"
+// @Failure 404 {object} ErrorResponse "Template not found"
+// @Router /process-templates/{name} [delete]
+func (h *ProcessHandler) HandleDeleteProcessTemplate(c *gin.Context) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := process.GetTemplateRegistry().Delete(name); err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, gin.H{"message": "Process template deleted successfully"})
+}
+
+// HandleStartProcessFromTemplate handles POST requests to /process/from-template/{name}
+// @Summary Start a process from a template
+// @Description Start a process from a registered template, substituting its parameters with the given values or their defaults. When waitForReady is set and the template has a readyWhen condition, the request blocks until the process is ready.
+// @Tags process-template
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param request body ProcessFromTemplateRequest false "Template parameters and overrides"
+// @Success 200 {object} ProcessResponse "Process information"
+// @Failure 400 {object} ErrorResponse "Invalid parameters"
+// @Failure 403 {object} ErrorResponse "Command denied by the process policy"
+// @Failure 404 {object} ErrorResponse "Template not found"
+// @Failure 409 {object} ErrorResponse "A running process has the same name"
+// @Failure 422 {object} ErrorResponse "Process failed to start or to become ready"
+// @Router /process/from-template/{name} [post]
+func (h *ProcessHandler) HandleStartProcessFromTemplate(c *gin.Context) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ProcessFromTemplateRequest
+	// The body is optional, templates without required parameters start as is
+	if c.Request.ContentLength != 0 {
+		if err := h.BindJSON(c, &req); err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	template, exists := process.GetTemplateRegistry().Get(name)
+	if !exists {
+		h.SendError(c, http.StatusNotFound, apierror.Newf(apierror.CodeProcTemplateNotFound, "process template %s not found", name))
+		return
+	}
+	rendered, err := template.Render(req.Parameters)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	workingDir := rendered.WorkingDir
+	if req.WorkingDir != "" {
+		workingDir = req.WorkingDir
+	}
+	env := make(map[string]string, len(rendered.Env)+len(req.Env))
+	for key, value := range rendered.Env {
+		env[key] = value
+	}
+	for key, value := range req.Env {
+		env[key] = value
+	}
+	processName := req.Name
+	if processName == "" {
+		processName = template.Name
+	}
+
+	processInfo, err := h.StartProcess(c.Request.Context(), service.StartRequest{
+		Command:       rendered.Command,
+		Name:          processName,
+		WorkingDir:    workingDir,
+		Env:           env,
+		RunAs:         lib.RunAs{User: req.RunAsUser, Group: req.RunAsGroup},
+		RestartPolicy: rendered.RestartPolicy,
+		MaxRestarts:   rendered.MaxRestarts,
+	})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if req.WaitForReady && rendered.ReadyWhen != nil && !rendered.ReadyWhen.IsEmpty() {
+		if err := h.processManager.WaitForReady(c.Request.Context(), processInfo.PID, *rendered.ReadyWhen); err != nil {
+			h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("process %s is not ready: %w", processInfo.PID, err))
+			return
+		}
+		// Report the state of the process once ready
+		if ready, err := h.GetProcess(processInfo.PID); err == nil {
+			processInfo = ready
+		}
+	}
+
+	h.SendJSON(c, http.StatusOK, processInfo)
+}
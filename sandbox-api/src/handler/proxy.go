@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/network"
+)
+
+// ProxyHandler forwards requests to services listening inside the sandbox
+type ProxyHandler struct {
+	*BaseHandler
+	allowedPorts []network.PortRange
+	disabled     bool
+}
+
+// NewProxyHandler creates a new proxy handler. The ports it forwards to are restricted
+// by PROXY_ALLOWED_PORTS (e.g. "3000,5173,8000-8100"), every port is allowed when unset.
+func NewProxyHandler() *ProxyHandler {
+	allowedPorts, err := network.ProxyAllowedPortsFromEnv()
+	if err != nil {
+		// Fail closed rather than exposing every port because of a typo
+		logrus.Errorf("Invalid PROXY_ALLOWED_PORTS, the proxy is disabled: %v", err)
+	}
+
+	return &ProxyHandler{
+		BaseHandler:  NewBaseHandler(),
+		allowedPorts: allowedPorts,
+		disabled:     err != nil,
+	}
+}
+
+// portAllowed reports whether requests may be forwarded to port
+func (h *ProxyHandler) portAllowed(port int) bool {
+	return !h.disabled && network.PortAllowed(h.allowedPorts, port)
+}
+
+// HandleProxy handles requests to /proxy/{port}/{path}
+// @Summary Proxy a request to a sandbox service
+// @Description Forward a request of any method to the service listening on the given port inside the sandbox, e.g. a dev server started with /process. WebSocket upgrades are forwarded as well. The service receives the request path without the /proxy/{port} prefix, which is passed in the X-Forwarded-Prefix header. Ports can be restricted with the PROXY_ALLOWED_PORTS environment variable.
+// @Tags proxy
+// @Param port path integer true "Port of the service"
+// @Param path path string true "Path forwarded to the service"
+// @Success 200 {string} string "Response of the service"
+// @Failure 400 {object} ErrorResponse "Invalid port"
+// @Failure 403 {object} ErrorResponse "Port not allowed"
+// @Failure 502 {object} ErrorResponse "Service unreachable"
+// @Router /proxy/{port}/{path} [get]
+func (h *ProxyHandler) HandleProxy(c *gin.Context) {
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil || port < 1 || port > 65535 {
+		h.SendError(c, http.StatusBadRequest, fmt.Errorf("invalid port '%s'", c.Param("port")))
+		return
+	}
+	if !h.portAllowed(port) {
+		h.SendError(c, http.StatusForbidden, fmt.Errorf("port %d is not allowed", port))
+		return
+	}
+
+	prefix := "/proxy/" + c.Param("port")
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.URL.Path = stripProxyPrefix(r.In.URL.Path, prefix)
+			r.Out.URL.RawPath = stripProxyPrefix(r.In.URL.RawPath, prefix)
+			r.SetXForwarded()
+			r.Out.Header.Set("X-Forwarded-Prefix", prefix)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Headers set by the API middlewares give way to the ones of the service
+			for key := range resp.Header {
+				c.Writer.Header().Del(key)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logrus.Debugf("Proxy error for port %d: %v", port, err)
+			h.SendError(c, http.StatusBadGateway, fmt.Errorf("service on port %d is unreachable: %w", port, err))
+		},
+	}
+
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// stripProxyPrefix removes the /proxy/{port} prefix from a request path
+func stripProxyPrefix(path string, prefix string) string {
+	if path == "" {
+		return ""
+	}
+	path = strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
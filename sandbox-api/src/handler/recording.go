@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+)
+
+// RecordingHandler handles the recordings of the output of processes
+type RecordingHandler struct {
+	*BaseHandler
+}
+
+// NewRecordingHandler creates a new recording handler
+func NewRecordingHandler() *RecordingHandler {
+	return &RecordingHandler{
+		BaseHandler: NewBaseHandler(),
+	}
+}
+
+// HandleListRecordings handles GET requests to /recordings
+// @Summary List recordings
+// @Description List the recordings of the processes started with record, or while PROCESS_RECORD is set, oldest first. Recordings are kept in RECORDINGS_DIR when their process is removed and across restarts of the API.
+// @Tags recordings
+// @Produce json
+// @Success 200 {array} process.Recording "Recordings"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /recordings [get]
+func (h *RecordingHandler) HandleListRecordings(c *gin.Context) {
+	recordings, err := process.ListRecordings()
+	if err != nil {
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, recordings)
+}
+
+// HandleDownloadRecording handles GET requests to /recordings/{id}
+// @Summary Download a recording
+// @Description Download a recording as an asciicast v2 file, to replay the output of the process with asciinema play or the asciinema player. The recording of a running process is downloaded up to its latest output.
+// @Tags recordings
+// @Produce application/x-asciicast
+// @Param id path string true "Recording id"
+// @Success 200 {file} file "Asciicast v2 recording"
+// @Failure 404 {object} ErrorResponse "Recording not found"
+// @Router /recordings/{id} [get]
+func (h *RecordingHandler) HandleDownloadRecording(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	file, recording, err := process.OpenRecording(id)
+	if err != nil {
+		if errors.Is(err, process.ErrRecordingNotFound) {
+			h.SendError(c, http.StatusNotFound, err)
+			return
+		}
+		h.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": recording.ID + ".cast"}))
+	http.ServeContent(c.Writer, c.Request, "", recording.StartedAt, file)
+}
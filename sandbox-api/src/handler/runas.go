@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// RunAsHeader is the request header setting the "user[:group]" owning the files
+// created by filesystem writes, RUN_AS by default
+const RunAsHeader = "X-Run-As"
+
+// fileOwner gives the files and directories created by a request to its run-as user
+type fileOwner struct {
+	fs         *filesystem.Filesystem
+	runAs      lib.RunAs
+	credential *lib.Credential
+}
+
+// fileOwner returns the owner of the files created by the request, from the X-Run-As
+// header or RUN_AS. It sends a 400 error and returns false when the user is unknown.
+func (h *FileSystemHandler) fileOwner(c *gin.Context) (*fileOwner, bool) {
+	owner, err := h.newFileOwner(c.GetHeader(RunAsHeader))
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return owner, true
+}
+
+// newFileOwner returns the owner of the files created for the "user[:group]" runAs,
+// RUN_AS when empty
+func (h *FileSystemHandler) newFileOwner(runAs string) (*fileOwner, error) {
+	parsed := lib.ParseRunAs(runAs)
+	credential, err := h.files.Owner(parsed)
+	if err != nil {
+		return nil, err
+	}
+	return &fileOwner{fs: h.fs, runAs: parsed, credential: credential}, nil
+}
+
+// track records which part of a path does not exist yet. The returned function, to
+// call once the path is written, gives what was created to the owner: the path and the
+// missing parent directories created along with it.
+func (o *fileOwner) track(path string) func() {
+	if o == nil {
+		return func() {}
+	}
+	return o.fs.TrackCreated(path, o.credential)
+}
+
+// giveAll gives path and everything under it to the owner
+func (o *fileOwner) giveAll(path string) {
+	if o == nil {
+		return
+	}
+	o.fs.GiveAll(path, o.credential)
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/handler/scaffold"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// ScaffoldHandler handles the creation of projects from templates
+type ScaffoldHandler struct {
+	*BaseHandler
+	FileSystem     *FileSystemHandler
+	registry       *scaffold.Registry
+	processManager *process.ProcessManager
+}
+
+// NewScaffoldHandler creates a new scaffold handler writing projects like the filesystem handler
+func NewScaffoldHandler(fsHandler *FileSystemHandler) *ScaffoldHandler {
+	return &ScaffoldHandler{
+		BaseHandler:    NewBaseHandler(),
+		FileSystem:     fsHandler,
+		registry:       scaffold.GetRegistry(),
+		processManager: process.GetProcessManager(),
+	}
+}
+
+// ScaffoldRequest is the request body for creating a project from a template
+type ScaffoldRequest struct {
+	Template string `json:"template" example:"fastapi" binding:"required"`
+	// Path is the directory of the project, created when missing
+	Path string `json:"path" example:"/blaxel/api" binding:"required"`
+	// Variables are the values of the parameters of the template
+	Variables map[string]string `json:"variables,omitempty" example:"{\"name\": \"orders\"}"`
+	// Overwrite writes the project in a directory which is not empty, replacing its files
+	Overwrite bool `json:"overwrite,omitempty" example:"false"`
+	// SkipCommands only writes the files, without running the commands of the template
+	SkipCommands bool `json:"skipCommands,omitempty" example:"false"`
+	// Timeout is the maximum number of seconds of each command, none when 0
+	Timeout int `json:"timeout,omitempty" example:"600" binding:"gte=0"`
+} // @name ScaffoldRequest
+
+// ScaffoldCommand is a command run in a created project
+type ScaffoldCommand struct {
+	Command string `json:"command" example:"go mod tidy" binding:"required"`
+	// PID is the identifier of the process of the command, to read its logs from /process/{pid}/logs
+	PID      string `json:"pid" example:"1234" binding:"required"`
+	Status   string `json:"status" example:"completed" binding:"required"`
+	ExitCode int    `json:"exitCode" example:"0"`
+} // @name ScaffoldCommand
+
+// ScaffoldResponse is the project created from a template
+type ScaffoldResponse struct {
+	Template string `json:"template" example:"fastapi" binding:"required"`
+	Path     string `json:"path" example:"/blaxel/api" binding:"required"`
+	// Tree lists the project directory once the files of the template are written, before
+	// its commands ran
+	Tree     *filesystem.Directory `json:"tree" binding:"required"`
+	Commands []ScaffoldCommand     `json:"commands" binding:"required"`
+} // @name ScaffoldResponse
+
+// HandleListScaffoldTemplates handles GET requests to /scaffold/templates
+// @Summary List scaffold templates
+// @Description List the project templates, sorted by name: the built-in next-app, fastapi and go-module templates, and the ones of the JSON file set with SCAFFOLD_TEMPLATES_FILE
+// @Tags scaffold
+// @Produce json
+// @Success 200 {array} scaffold.Template "Templates"
+// @Router /scaffold/templates [get]
+func (h *ScaffoldHandler) HandleListScaffoldTemplates(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.registry.List())
+}
+
+// HandleScaffold handles POST requests to /scaffold
+// @Summary Create a project from a template
+// @Description Create a project skeleton from a template in one call: its files are written with the variables substituted, then its commands, like installing dependencies, are run in order in the project directory as processes. The directory must be empty unless overwrite is set.
+// @Tags scaffold
+// @Accept json
+// @Produce json
+// @Param X-Run-As header string false "User[:group] owning the files and running the commands, RUN_AS by default"
+// @Param request body ScaffoldRequest true "Template, directory and variables"
+// @Success 200 {object} ScaffoldResponse "Project created"
+// @Failure 400 {object} ErrorResponse "Invalid request or variables"
+// @Failure 404 {object} ErrorResponse "Template not found"
+// @Failure 409 {object} ErrorResponse "Directory not empty"
+// @Failure 422 {object} ErrorResponse "Command failed"
+// @Router /scaffold [post]
+func (h *ScaffoldHandler) HandleScaffold(c *gin.Context) {
+	var req ScaffoldRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	root, err := lib.FormatPath(req.Path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	template, err := h.registry.Get(req.Template)
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	files, commands, err := template.Render(req.Variables)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.Overwrite {
+		if isDir, _ := h.FileSystem.DirectoryExists(root); isDir {
+			dir, err := h.FileSystem.ListDirectory(root)
+			if err != nil {
+				h.SendError(c, http.StatusUnprocessableEntity, err)
+				return
+			}
+			if !dir.IsEmpty() {
+				h.SendError(c, http.StatusConflict, apierror.Newf(apierror.CodeConflict, "directory %s is not empty, set overwrite to write the project in it", root))
+				return
+			}
+		}
+	}
+
+	runAs := lib.ParseRunAs(c.GetHeader(RunAsHeader))
+	if root, err = h.FileSystem.WriteTree(root, files, lockHolder(c), runAs); err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	// List the project before its commands add dependencies, build outputs...
+	tree, err := h.FileSystem.fs.ListDirectoryRecursive(root, filesystem.TreeOptions{IncludeHidden: true})
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("error getting the project tree: %w", err))
+		return
+	}
+	response := ScaffoldResponse{Template: template.Name, Path: root, Tree: tree, Commands: []ScaffoldCommand{}}
+	if !req.SkipCommands {
+		runAs := lib.ParseRunAs(c.GetHeader(RunAsHeader))
+		for _, command := range commands {
+			info, err := h.processManager.ExecuteProcess(command, root, "", nil, runAs, true, req.Timeout, nil, "", process.RestartConfig{}, false, false)
+			if err != nil {
+				h.SendError(c, http.StatusUnprocessableEntity, fmt.Errorf("project created in %s but '%s' could not run: %w", root, command, err))
+				return
+			}
+			state := info.State()
+			response.Commands = append(response.Commands, ScaffoldCommand{
+				Command:  command,
+				PID:      info.PID,
+				Status:   string(state.Status),
+				ExitCode: state.ExitCode,
+			})
+			if state.Status != process.StatusCompleted {
+				h.SendError(c, http.StatusUnprocessableEntity, apierror.Newf(apierror.CodeUnprocessable,
+					"project created in %s but '%s' %s with exit code %d (process %s): %s",
+					root, command, state.Status, state.ExitCode, info.PID, logTail(info.Logs, 5)))
+				return
+			}
+		}
+	}
+
+	h.SendJSON(c, http.StatusOK, response)
+}
+
+// logTail returns the last lines of the logs of a process
+func logTail(logs *string, lines int) string {
+	if logs == nil {
+		return ""
+	}
+	all := strings.Split(strings.TrimSpace(*logs), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -0,0 +1,212 @@
+// Package scaffold holds the project templates materialized by /scaffold: the files of a
+// project skeleton and the commands to run in it once created, like installing its
+// dependencies, both referencing the template parameters as {{name}}.
+package scaffold
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Template sources
+const (
+	TemplateSourceBuiltin = "builtin"
+	TemplateSourceFile    = "file"
+)
+
+// ErrTemplateNotFound is returned for an unknown template
+var ErrTemplateNotFound = apierror.New(apierror.CodeNotFound, "scaffold template not found")
+
+// placeholder matches the {{parameter}} references of a template, like process templates
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Template is a project skeleton
+type Template struct {
+	Name        string                      `json:"name" example:"fastapi" binding:"required"`
+	Description string                      `json:"description,omitempty" example:"FastAPI service served by uvicorn"`
+	Parameters  []process.TemplateParameter `json:"parameters,omitempty"`
+	// Files maps the paths of the files, relative to the project directory, to their content
+	Files map[string]string `json:"files" binding:"required"`
+	// Commands are run in order in the project directory once its files are written
+	Commands []string `json:"commands,omitempty" example:"python3 -m venv .venv"`
+	Source   string   `json:"source" example:"builtin" enums:"builtin,file"`
+} // @name ScaffoldTemplate
+
+// Validate checks that the template has a name and files with relative paths, and that it
+// only references declared parameters
+func (t Template) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if len(t.Files) == 0 {
+		return fmt.Errorf("template files are required")
+	}
+	declared := make(map[string]bool, len(t.Parameters))
+	for _, parameter := range t.Parameters {
+		if !placeholder.MatchString("{{" + parameter.Name + "}}") {
+			return fmt.Errorf("invalid parameter name '%s'", parameter.Name)
+		}
+		if declared[parameter.Name] {
+			return fmt.Errorf("duplicate parameter '%s'", parameter.Name)
+		}
+		declared[parameter.Name] = true
+	}
+	fields := append([]string{}, t.Commands...)
+	for path, content := range t.Files {
+		fields = append(fields, path, content)
+	}
+	for _, text := range fields {
+		for _, match := range placeholder.FindAllStringSubmatch(text, -1) {
+			if !declared[match[1]] {
+				return fmt.Errorf("undeclared parameter '%s' referenced", match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// Render returns the files and commands of the template with its parameters substituted
+// by the given values, or their defaults. It fails on unknown parameters, missing required
+// ones and file paths leaving the project directory.
+func (t Template) Render(values map[string]string) (map[string]string, []string, error) {
+	resolved := make(map[string]string, len(t.Parameters))
+	for _, parameter := range t.Parameters {
+		value, given := values[parameter.Name]
+		if !given {
+			if parameter.Required {
+				return nil, nil, apierror.Newf(apierror.CodeInvalidRequest, "missing required variable '%s'", parameter.Name)
+			}
+			value = parameter.Default
+		}
+		resolved[parameter.Name] = value
+	}
+	for name := range values {
+		if _, declared := resolved[name]; !declared {
+			return nil, nil, apierror.Newf(apierror.CodeInvalidRequest, "unknown variable '%s' for template %s", name, t.Name)
+		}
+	}
+
+	substitute := func(text string) string {
+		return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+			return resolved[placeholder.FindStringSubmatch(match)[1]]
+		})
+	}
+	files := make(map[string]string, len(t.Files))
+	for path, content := range t.Files {
+		rendered := filepath.Clean(substitute(path))
+		if filepath.IsAbs(rendered) || rendered == "." || rendered == ".." || strings.HasPrefix(rendered, "../") {
+			return nil, nil, apierror.Newf(apierror.CodeInvalidRequest, "file '%s' is outside of the project directory", rendered)
+		}
+		files[rendered] = substitute(content)
+	}
+	commands := make([]string, len(t.Commands))
+	for i, command := range t.Commands {
+		commands[i] = substitute(command)
+	}
+	return files, commands, nil
+}
+
+// Registry holds the project templates
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string]Template
+}
+
+var (
+	registry     *Registry
+	registryOnce sync.Once
+)
+
+// GetRegistry returns the template registry, with the built-in templates and the ones of
+// the JSON file set with SCAFFOLD_TEMPLATES_FILE, so that sandbox images can ship their own
+func GetRegistry() *Registry {
+	registryOnce.Do(func() {
+		registry = NewRegistry()
+		if path := os.Getenv("SCAFFOLD_TEMPLATES_FILE"); path != "" {
+			if err := registry.LoadFile(path); err != nil {
+				logrus.Warnf("Failed to load scaffold templates from %s: %v", path, err)
+			}
+		}
+	})
+	return registry
+}
+
+// NewRegistry creates a registry with the built-in templates
+func NewRegistry() *Registry {
+	r := &Registry{templates: make(map[string]Template)}
+	for _, template := range builtinTemplates {
+		template.Source = TemplateSourceBuiltin
+		if err := r.register(template); err != nil {
+			panic(fmt.Sprintf("invalid built-in scaffold template %s: %v", template.Name, err))
+		}
+	}
+	return r
+}
+
+// LoadFile registers the templates of a JSON file holding an array of templates, replacing
+// the built-in templates with the same name
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var templates []Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("invalid templates file: %w", err)
+	}
+	for _, template := range templates {
+		template.Source = TemplateSourceFile
+		if err := r.register(template); err != nil {
+			return fmt.Errorf("template %s: %w", template.Name, err)
+		}
+	}
+	logrus.Infof("Loaded %d scaffold templates from %s", len(templates), path)
+	return nil
+}
+
+func (r *Registry) register(template Template) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[template.Name] = template
+	return nil
+}
+
+// Get returns a template by name
+func (r *Registry) Get(name string) (Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	template, exists := r.templates[name]
+	if !exists {
+		return Template{}, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+// List returns the templates sorted by name
+func (r *Registry) List() []Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	templates := make([]Template, 0, len(r.templates))
+	for _, template := range r.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
@@ -0,0 +1,95 @@
+package scaffold
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestBuiltinTemplates tests that the built-in templates render with their defaults
+func TestBuiltinTemplates(t *testing.T) {
+	r := NewRegistry()
+	names := []string{}
+	for _, template := range r.List() {
+		names = append(names, template.Name)
+		files, _, err := template.Render(nil)
+		if err != nil {
+			t.Errorf("Failed to render %s: %v", template.Name, err)
+		}
+		for path, content := range files {
+			if strings.Contains(content, "{{") {
+				t.Errorf("Expected every placeholder of %s/%s to be substituted", template.Name, path)
+			}
+		}
+	}
+	if strings.Join(names, ",") != "fastapi,go-module,next-app" {
+		t.Errorf("Unexpected built-in templates %v", names)
+	}
+
+	template, _ := r.Get("go-module")
+	files, commands, err := template.Render(map[string]string{"module": "github.com/acme/tool"})
+	if err != nil || files["go.mod"] != "module github.com/acme/tool\n\ngo 1.22\n" || commands[0] != "go mod tidy" {
+		t.Errorf("Unexpected rendering %q, %q (%v)", files["go.mod"], commands, err)
+	}
+}
+
+// TestTemplateRender tests that variables are checked and files stay in the project
+func TestTemplateRender(t *testing.T) {
+	template := Template{
+		Name:       "lib",
+		Parameters: []process.TemplateParameter{{Name: "package", Required: true}},
+		Files:      map[string]string{"{{package}}/__init__.py": "NAME = '{{package}}'\n"},
+		Commands:   []string{"python3 -m compileall {{package}}"},
+	}
+	if err := template.Validate(); err != nil {
+		t.Fatalf("Expected a valid template, got %v", err)
+	}
+	files, commands, err := template.Render(map[string]string{"package": "orders"})
+	if err != nil || files["orders/__init__.py"] != "NAME = 'orders'\n" || commands[0] != "python3 -m compileall orders" {
+		t.Errorf("Unexpected rendering %v, %v (%v)", files, commands, err)
+	}
+
+	for _, values := range []map[string]string{
+		nil,
+		{"package": "orders", "other": "x"},
+		{"package": "../../etc"},
+		{"package": "/etc"},
+	} {
+		if _, _, err := template.Render(values); !errors.Is(err, apierror.New(apierror.CodeInvalidRequest, "")) {
+			t.Errorf("Expected INVALID_REQUEST for %v, got %v", values, err)
+		}
+	}
+
+	template.Files["README.md"] = "{{undeclared}}"
+	if err := template.Validate(); err == nil {
+		t.Error("Expected undeclared parameters to be rejected")
+	}
+}
+
+// TestRegistryLoadFile tests that the templates of a file replace the built-in ones
+func TestRegistryLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	content := `[{"name": "go-module", "files": {"go.mod": "module internal/app\n"}}, {"name": "empty", "files": {".keep": ""}}]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	if err := r.LoadFile(path); err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	template, err := r.Get("go-module")
+	if err != nil || template.Source != TemplateSourceFile || len(template.Commands) != 0 {
+		t.Errorf("Expected the built-in template to be replaced, got %+v (%v)", template, err)
+	}
+	if len(r.List()) != 4 {
+		t.Errorf("Expected 4 templates, got %d", len(r.List()))
+	}
+	if _, err := r.Get("missing"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected ErrTemplateNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package scaffold
+
+import "github.com/blaxel-ai/sandbox-api/src/handler/process"
+
+// builtinTemplates are the templates available in every sandbox
+var builtinTemplates = []Template{
+	{
+		Name:        "next-app",
+		Description: "Next.js app with the App Router and TypeScript",
+		Parameters: []process.TemplateParameter{
+			{Name: "name", Description: "Name of the npm package", Default: "my-app"},
+		},
+		Files: map[string]string{
+			"package.json": `{
+  "name": "{{name}}",
+  "version": "0.1.0",
+  "private": true,
+  "scripts": {
+    "dev": "next dev",
+    "build": "next build",
+    "start": "next start"
+  },
+  "dependencies": {
+    "next": "^14.2.0",
+    "react": "^18.3.0",
+    "react-dom": "^18.3.0"
+  },
+  "devDependencies": {
+    "@types/node": "^20",
+    "@types/react": "^18",
+    "@types/react-dom": "^18",
+    "typescript": "^5"
+  }
+}
+`,
+			"tsconfig.json": `{
+  "compilerOptions": {
+    "target": "ES2017",
+    "lib": ["dom", "dom.iterable", "esnext"],
+    "allowJs": true,
+    "skipLibCheck": true,
+    "strict": true,
+    "noEmit": true,
+    "esModuleInterop": true,
+    "module": "esnext",
+    "moduleResolution": "bundler",
+    "resolveJsonModule": true,
+    "isolatedModules": true,
+    "jsx": "preserve",
+    "incremental": true,
+    "plugins": [{ "name": "next" }],
+    "paths": { "@/*": ["./*"] }
+  },
+  "include": ["next-env.d.ts", "**/*.ts", "**/*.tsx", ".next/types/**/*.ts"],
+  "exclude": ["node_modules"]
+}
+`,
+			"next.config.mjs": `/** @type {import('next').NextConfig} */
+const nextConfig = {};
+
+export default nextConfig;
+`,
+			"app/layout.tsx": `export const metadata = {
+  title: "{{name}}",
+};
+
+export default function RootLayout({ children }: { children: React.ReactNode }) {
+  return (
+    <html lang="en">
+      <body>{children}</body>
+    </html>
+  );
+}
+`,
+			"app/page.tsx": `export default function Home() {
+  return (
+    <main>
+      <h1>{{name}}</h1>
+    </main>
+  );
+}
+`,
+			".gitignore": "node_modules/\n.next/\nout/\nnext-env.d.ts\n*.tsbuildinfo\n.env*.local\n",
+		},
+		Commands: []string{"npm install --no-audit --no-fund"},
+	},
+	{
+		Name:        "fastapi",
+		Description: "FastAPI service served by uvicorn, with its dependencies in a virtual environment",
+		Parameters: []process.TemplateParameter{
+			{Name: "name", Description: "Title of the API", Default: "app"},
+		},
+		Files: map[string]string{
+			"requirements.txt": "fastapi>=0.110\nuvicorn[standard]>=0.29\n",
+			"main.py": `from fastapi import FastAPI
+
+app = FastAPI(title="{{name}}")
+
+
+@app.get("/")
+def read_root():
+    return {"name": "{{name}}"}
+
+
+@app.get("/health")
+def health():
+    return {"status": "ok"}
+`,
+			"README.md":  "# {{name}}\n\nRun the API with:\n\n    .venv/bin/uvicorn main:app --reload --host 0.0.0.0 --port 8000\n",
+			".gitignore": ".venv/\n__pycache__/\n*.pyc\n",
+		},
+		Commands: []string{
+			"python3 -m venv .venv",
+			".venv/bin/pip install --disable-pip-version-check -r requirements.txt",
+		},
+	},
+	{
+		Name:        "go-module",
+		Description: "Go module with a main package",
+		Parameters: []process.TemplateParameter{
+			{Name: "module", Description: "Module path", Default: "example.com/app"},
+			{Name: "goVersion", Description: "Minimum Go version of the module", Default: "1.22"},
+		},
+		Files: map[string]string{
+			"go.mod": "module {{module}}\n\ngo {{goVersion}}\n",
+			"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello from {{module}}")
+}
+`,
+			".gitignore": "/bin/\n*.test\n*.out\n",
+		},
+		Commands: []string{"go mod tidy"},
+	},
+}
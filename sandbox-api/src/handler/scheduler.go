@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/scheduler"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// SchedulerHandler handles scheduled process operations
+type SchedulerHandler struct {
+	*BaseHandler
+	scheduler *scheduler.Scheduler
+}
+
+// NewSchedulerHandler creates a new scheduler handler
+func NewSchedulerHandler() *SchedulerHandler {
+	return &SchedulerHandler{
+		BaseHandler: NewBaseHandler(),
+		scheduler:   scheduler.GetScheduler(),
+	}
+}
+
+// ScheduleRequest is the request body for creating a schedule
+type ScheduleRequest struct {
+	Name     string                `json:"name" example:"nightly-tests" binding:"required"`
+	Cron     string                `json:"cron" example:"0 3 * * *"`
+	Interval int                   `json:"interval" example:"3600"`
+	Process  scheduler.ProcessSpec `json:"process" binding:"required"`
+} // @name ScheduleRequest
+
+// HandleCreateSchedule handles POST requests to /schedules
+// @Summary Create a schedule
+// @Description Run a process periodically, either on a cron expression (5 fields, or descriptors such as @hourly) or every interval seconds. The process is named after the schedule, a run is skipped while the previous one is still running, and runs longer than the process timeout are killed.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param request body ScheduleRequest true "Schedule definition"
+// @Success 200 {object} scheduler.ScheduleInfo "Schedule"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /schedules [post]
+func (h *SchedulerHandler) HandleCreateSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Process.WorkingDir != "" {
+		formattedWorkingDir, err := lib.FormatPath(req.Process.WorkingDir)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		req.Process.WorkingDir = formattedWorkingDir
+	}
+
+	schedule, err := h.scheduler.CreateSchedule(req.Name, req.Cron, req.Interval, req.Process)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, schedule)
+}
+
+// HandleListSchedules handles GET requests to /schedules
+// @Summary List schedules
+// @Description Get all schedules along with their run history
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Success 200 {array} scheduler.ScheduleInfo "Schedules"
+// @Router /schedules [get]
+func (h *SchedulerHandler) HandleListSchedules(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.scheduler.ListSchedules())
+}
+
+// HandleGetSchedule handles GET requests to /schedules/{name}
+// @Summary Get a schedule
+// @Description Get a schedule along with its run history (latest runs last)
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param name path string true "Schedule name"
+// @Success 200 {object} scheduler.ScheduleInfo "Schedule"
+// @Failure 404 {object} ErrorResponse "Schedule not found"
+// @Router /schedules/{name} [get]
+func (h *SchedulerHandler) HandleGetSchedule(c *gin.Context) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	schedule, exists := h.scheduler.GetSchedule(name)
+	if !exists {
+		h.SendError(c, http.StatusNotFound, fmt.Errorf("schedule %s not found", name))
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, schedule)
+}
+
+// HandleDeleteSchedule handles DELETE requests to /schedules/{name}
+// @Summary Delete a schedule
+// @Description Delete a schedule. A run in progress is not stopped.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param name path string true "Schedule name"
+// @Success 200 {object} SuccessResponse "Schedule deleted"
+// @Failure 404 {object} ErrorResponse "Schedule not found"
+// @Router /schedules/{name} [delete]
+func (h *SchedulerHandler) HandleDeleteSchedule(c *gin.Context) {
+	name, err := h.GetPathParam(c, "name")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.scheduler.DeleteSchedule(name); err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, gin.H{"message": "Schedule deleted successfully"})
+}
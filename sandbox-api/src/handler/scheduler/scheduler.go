@@ -0,0 +1,271 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+)
+
+// maxRunHistory is the number of runs kept per schedule
+const maxRunHistory = 50
+
+// Run statuses in addition to the process statuses
+const (
+	RunStatusSkipped = "skipped"
+	RunStatusTimeout = "timeout"
+)
+
+// ProcessSpec describes the process started by a schedule
+type ProcessSpec struct {
+	Command    string            `json:"command" example:"npm test" binding:"required"`
+	WorkingDir string            `json:"workingDir" example:"/home/user/app"`
+	Env        map[string]string `json:"env" example:"{\"CI\": \"true\"}"`
+	Timeout    int               `json:"timeout" example:"300"`
+} // @name ScheduleProcess
+
+// Run records one execution of a schedule
+type Run struct {
+	PID         string     `json:"pid,omitempty" example:"1234"`
+	StartedAt   time.Time  `json:"startedAt" binding:"required"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Status      string     `json:"status" example:"completed" enums:"running,completed,failed,stopped,killed,skipped,timeout" binding:"required"`
+	ExitCode    int        `json:"exitCode" example:"0"`
+	Error       string     `json:"error,omitempty" example:"previous run is still running"`
+} // @name ScheduleRun
+
+// ScheduleInfo is the state of a schedule
+type ScheduleInfo struct {
+	Name      string      `json:"name" example:"nightly-tests" binding:"required"`
+	Cron      string      `json:"cron,omitempty" example:"0 3 * * *"`
+	Interval  int         `json:"interval,omitempty" example:"3600"`
+	Process   ProcessSpec `json:"process" binding:"required"`
+	CreatedAt time.Time   `json:"createdAt" binding:"required"`
+	NextRunAt *time.Time  `json:"nextRunAt,omitempty"`
+	Runs      []Run       `json:"runs" binding:"required"`
+} // @name Schedule
+
+// schedule is a registered schedule and its run history
+type schedule struct {
+	info    ScheduleInfo
+	entryID cron.EntryID
+	mu      sync.Mutex
+}
+
+// Scheduler runs processes periodically, on a cron expression or a fixed interval
+type Scheduler struct {
+	cron           *cron.Cron
+	processManager *process.ProcessManager
+	schedules      map[string]*schedule
+	mu             sync.RWMutex
+}
+
+// Global scheduler instance
+var (
+	scheduler     *Scheduler
+	schedulerOnce sync.Once
+)
+
+// GetScheduler returns the singleton scheduler instance
+func GetScheduler() *Scheduler {
+	schedulerOnce.Do(func() {
+		scheduler = NewScheduler(process.GetProcessManager())
+	})
+	return scheduler
+}
+
+// NewScheduler creates a new scheduler starting processes with the given process manager
+func NewScheduler(processManager *process.ProcessManager) *Scheduler {
+	s := &Scheduler{
+		cron:           cron.New(),
+		processManager: processManager,
+		schedules:      make(map[string]*schedule),
+	}
+	s.cron.Start()
+	return s
+}
+
+// CreateSchedule registers a schedule. Exactly one of cronExpr (standard 5 fields cron
+// expression or descriptor such as @hourly) and interval (in seconds) must be given.
+func (s *Scheduler) CreateSchedule(name string, cronExpr string, interval int, spec ProcessSpec) (ScheduleInfo, error) {
+	if name == "" {
+		return ScheduleInfo{}, fmt.Errorf("schedule name is required")
+	}
+	if spec.Command == "" {
+		return ScheduleInfo{}, fmt.Errorf("process command is required")
+	}
+	if spec.Timeout < 0 {
+		return ScheduleInfo{}, fmt.Errorf("invalid timeout: must be a positive number of seconds")
+	}
+
+	var cronSchedule cron.Schedule
+	switch {
+	case cronExpr != "" && interval != 0:
+		return ScheduleInfo{}, fmt.Errorf("cron and interval are mutually exclusive")
+	case cronExpr != "":
+		parsed, err := cron.ParseStandard(cronExpr)
+		if err != nil {
+			return ScheduleInfo{}, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		cronSchedule = parsed
+	case interval > 0:
+		cronSchedule = cron.Every(time.Duration(interval) * time.Second)
+	default:
+		return ScheduleInfo{}, fmt.Errorf("either cron or a positive interval is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.schedules[name]; exists {
+		return ScheduleInfo{}, fmt.Errorf("schedule %s already exists", name)
+	}
+
+	sched := &schedule{
+		info: ScheduleInfo{
+			Name:      name,
+			Cron:      cronExpr,
+			Interval:  interval,
+			Process:   spec,
+			CreatedAt: time.Now(),
+			Runs:      []Run{},
+		},
+	}
+	sched.entryID = s.cron.Schedule(cronSchedule, cron.FuncJob(func() {
+		s.run(sched)
+	}))
+	s.schedules[name] = sched
+
+	return s.snapshot(sched), nil
+}
+
+// GetSchedule returns a schedule by name
+func (s *Scheduler) GetSchedule(name string) (ScheduleInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sched, exists := s.schedules[name]
+	if !exists {
+		return ScheduleInfo{}, false
+	}
+	return s.snapshot(sched), true
+}
+
+// ListSchedules returns all schedules, oldest first
+func (s *Scheduler) ListSchedules() []ScheduleInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := make([]ScheduleInfo, 0, len(s.schedules))
+	for _, sched := range s.schedules {
+		schedules = append(schedules, s.snapshot(sched))
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules
+}
+
+// DeleteSchedule removes a schedule. A run in progress is not stopped.
+func (s *Scheduler) DeleteSchedule(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, exists := s.schedules[name]
+	if !exists {
+		return fmt.Errorf("schedule %s not found", name)
+	}
+	s.cron.Remove(sched.entryID)
+	delete(s.schedules, name)
+	return nil
+}
+
+// snapshot returns a copy of the schedule state, the caller must hold s.mu
+func (s *Scheduler) snapshot(sched *schedule) ScheduleInfo {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	info := sched.info
+	info.Runs = make([]Run, len(sched.info.Runs))
+	copy(info.Runs, sched.info.Runs)
+	if next := s.cron.Entry(sched.entryID).Next; !next.IsZero() {
+		info.NextRunAt = &next
+	}
+	return info
+}
+
+// run starts the process of a schedule, unless the previous run is still going
+func (s *Scheduler) run(sched *schedule) {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	for _, previous := range sched.info.Runs {
+		if previous.Status == string(constants.ProcessStatusRunning) {
+			s.appendRun(sched, Run{
+				StartedAt: time.Now(),
+				Status:    RunStatusSkipped,
+				Error:     "previous run is still running",
+			})
+			return
+		}
+	}
+
+	spec := sched.info.Process
+	run := Run{
+		StartedAt: time.Now(),
+		Status:    string(constants.ProcessStatusRunning),
+	}
+
+	// Runs longer than the timeout are killed by the process manager
+	timeout := time.Duration(spec.Timeout) * time.Second
+	restart := process.RestartConfig{Policy: process.RestartPolicyNever}
+	pid, err := s.processManager.StartProcessWithRestart(spec.Command, spec.WorkingDir, sched.info.Name, spec.Env, lib.RunAs{}, timeout, restart, false, false, func(p *process.ProcessInfo) {
+		sched.mu.Lock()
+		defer sched.mu.Unlock()
+
+		// Look the run up by PID, older runs may have been dropped from the history
+		for i := len(sched.info.Runs) - 1; i >= 0; i-- {
+			r := &sched.info.Runs[i]
+			if r.PID != p.PID || r.Status != string(constants.ProcessStatusRunning) {
+				continue
+			}
+			state := p.State()
+			r.CompletedAt = state.CompletedAt
+			r.ExitCode = state.ExitCode
+			r.Status = string(state.Status)
+			if state.Status == constants.ProcessStatusTimedOut {
+				r.Status = RunStatusTimeout
+				r.Error = fmt.Sprintf("killed after %d seconds", spec.Timeout)
+			}
+			break
+		}
+	})
+	if err != nil {
+		now := time.Now()
+		run.CompletedAt = &now
+		run.Status = string(constants.ProcessStatusFailed)
+		run.Error = err.Error()
+		s.appendRun(sched, run)
+		logrus.Warnf("Schedule %s failed to start its process: %v", sched.info.Name, err)
+		return
+	}
+
+	run.PID = pid
+	s.appendRun(sched, run)
+}
+
+// appendRun adds a run to the history, dropping the oldest ones past maxRunHistory.
+// The caller must hold sched.mu.
+func (s *Scheduler) appendRun(sched *schedule, run Run) {
+	sched.info.Runs = append(sched.info.Runs, run)
+	if len(sched.info.Runs) > maxRunHistory {
+		sched.info.Runs = sched.info.Runs[len(sched.info.Runs)-maxRunHistory:]
+	}
+}
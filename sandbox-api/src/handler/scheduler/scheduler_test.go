@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+)
+
+// waitForRuns polls a schedule until it has at least n runs
+func waitForRuns(t *testing.T, s *Scheduler, name string, n int, timeout time.Duration) ScheduleInfo {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		info, exists := s.GetSchedule(name)
+		if !exists {
+			t.Fatalf("Schedule %s not found", name)
+		}
+		if len(info.Runs) >= n {
+			return info
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Schedule %s did not reach %d runs within %s", name, n, timeout)
+	return ScheduleInfo{}
+}
+
+// TestScheduler tests interval schedules, run history and timeouts
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(process.GetProcessManager())
+
+	t.Run("Validation", func(t *testing.T) {
+		if _, err := s.CreateSchedule("invalid-cron", "not a cron", 0, ProcessSpec{Command: "true"}); err == nil {
+			t.Error("Expected error for an invalid cron expression, but got none")
+		}
+		if _, err := s.CreateSchedule("both", "* * * * *", 10, ProcessSpec{Command: "true"}); err == nil {
+			t.Error("Expected error when both cron and interval are set, but got none")
+		}
+		if _, err := s.CreateSchedule("neither", "", 0, ProcessSpec{Command: "true"}); err == nil {
+			t.Error("Expected error when neither cron nor interval is set, but got none")
+		}
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		info, err := s.CreateSchedule("test-interval", "", 1, ProcessSpec{Command: "echo tick"})
+		if err != nil {
+			t.Fatalf("Failed to create schedule: %v", err)
+		}
+		if info.NextRunAt == nil {
+			t.Error("Expected next run time to be set")
+		}
+		if _, err := s.CreateSchedule("test-interval", "", 1, ProcessSpec{Command: "echo tick"}); err == nil {
+			t.Error("Expected error for a duplicate schedule name, but got none")
+		}
+
+		info = waitForRuns(t, s, "test-interval", 2, 5*time.Second)
+		if info.Runs[0].PID == "" || info.Runs[0].Status == RunStatusSkipped {
+			t.Errorf("Expected first run to have started a process, got %+v", info.Runs[0])
+		}
+
+		if err := s.DeleteSchedule("test-interval"); err != nil {
+			t.Fatalf("Failed to delete schedule: %v", err)
+		}
+		if _, exists := s.GetSchedule("test-interval"); exists {
+			t.Error("Expected schedule to be deleted")
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		if _, err := s.CreateSchedule("test-timeout", "", 1, ProcessSpec{Command: "sleep 30", Timeout: 2}); err != nil {
+			t.Fatalf("Failed to create schedule: %v", err)
+		}
+		defer func() { _ = s.DeleteSchedule("test-timeout") }()
+
+		// The second tick happens while the first run is still going
+		info := waitForRuns(t, s, "test-timeout", 2, 5*time.Second)
+		if info.Runs[1].Status != RunStatusSkipped {
+			t.Errorf("Expected second run to be skipped, got %s", info.Runs[1].Status)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			info, _ = s.GetSchedule("test-timeout")
+			if info.Runs[0].Status == RunStatusTimeout {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Errorf("Expected first run to time out, got %s", info.Runs[0].Status)
+	})
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+)
+
+// SnapshotHandler handles directory snapshots
+type SnapshotHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+	snapshots  *filesystem.SnapshotManager
+}
+
+// NewSnapshotHandler creates a new snapshot handler resolving paths like the filesystem handler.
+// Snapshots are stored in SNAPSHOTS_DIR and survive restarts of the API.
+func NewSnapshotHandler(fsHandler *FileSystemHandler) *SnapshotHandler {
+	snapshots := filesystem.NewSnapshotManager(filesystem.SnapshotsDirFromEnv())
+	if err := snapshots.LoadSnapshots(); err != nil {
+		logrus.Warnf("Failed to load snapshots: %v", err)
+	}
+
+	return &SnapshotHandler{
+		BaseHandler: NewBaseHandler(),
+		FileSystem:  fsHandler,
+		snapshots:   snapshots,
+	}
+}
+
+// CreateSnapshotRequest is the request body for creating a snapshot
+type CreateSnapshotRequest struct {
+	Path string `json:"path" example:"/home/user/app" binding:"required"`
+	Name string `json:"name" example:"before-refactor"`
+} // @name CreateSnapshotRequest
+
+// sendSnapshotError sends a 404 for unknown snapshots and a 422 otherwise
+func (h *SnapshotHandler) sendSnapshotError(c *gin.Context, err error) {
+	if errors.Is(err, filesystem.ErrSnapshotNotFound) {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	h.SendError(c, http.StatusUnprocessableEntity, err)
+}
+
+// HandleCreateSnapshot handles POST requests to /snapshots
+// @Summary Create a snapshot
+// @Description Capture a point-in-time copy of a directory to restore it later, e.g. before a risky change. Files unchanged since the previous snapshot of the same directory are hard links to it, so only changed files take up space.
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Param request body CreateSnapshotRequest true "Snapshot request"
+// @Param async query boolean false "Create the snapshot in a background job, whose result is the snapshot"
+// @Success 200 {object} filesystem.Snapshot "Snapshot"
+// @Success 202 {object} jobs.Job "Job started (async)"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /snapshots [post]
+func (h *SnapshotHandler) HandleCreateSnapshot(c *gin.Context) {
+	var req CreateSnapshotRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	absPath, err := h.FileSystem.fs.GetAbsolutePath(path)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if wantsAsync(c) {
+		h.startJob(c, jobs.TypeSnapshotCreate, absPath, func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+			return h.snapshots.CreateSnapshot(absPath, req.Name)
+		})
+		return
+	}
+
+	snapshot, err := h.snapshots.CreateSnapshot(absPath, req.Name)
+	if err != nil {
+		h.SendError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, snapshot)
+}
+
+// HandleListSnapshots handles GET requests to /snapshots
+// @Summary List snapshots
+// @Description Get all snapshots, oldest first
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Success 200 {array} filesystem.Snapshot "Snapshots"
+// @Router /snapshots [get]
+func (h *SnapshotHandler) HandleListSnapshots(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.snapshots.ListSnapshots())
+}
+
+// HandleGetSnapshot handles GET requests to /snapshots/{id}
+// @Summary Get a snapshot
+// @Description Get a snapshot by id
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Param id path string true "Snapshot id"
+// @Success 200 {object} filesystem.Snapshot "Snapshot"
+// @Failure 404 {object} ErrorResponse "Snapshot not found"
+// @Router /snapshots/{id} [get]
+func (h *SnapshotHandler) HandleGetSnapshot(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	snapshot, err := h.snapshots.GetSnapshot(id)
+	if err != nil {
+		h.sendSnapshotError(c, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, snapshot)
+}
+
+// HandleRestoreSnapshot handles POST requests to /snapshots/{id}/restore
+// @Summary Restore a snapshot
+// @Description Bring the snapshotted directory back to its captured state. Entries created since the snapshot are deleted and changed or deleted files are restored. The snapshot is kept and can be restored again.
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Param id path string true "Snapshot id"
+// @Param async query boolean false "Restore the snapshot in a background job, whose result is the snapshot"
+// @Success 200 {object} SuccessResponse "Snapshot restored"
+// @Success 202 {object} jobs.Job "Job started (async)"
+// @Failure 404 {object} ErrorResponse "Snapshot not found"
+// @Failure 422 {object} ErrorResponse "Unprocessable entity"
+// @Router /snapshots/{id}/restore [post]
+func (h *SnapshotHandler) HandleRestoreSnapshot(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Unknown snapshots fail the request rather than the job
+	snapshot, err := h.snapshots.GetSnapshot(id)
+	if err != nil {
+		h.sendSnapshotError(c, err)
+		return
+	}
+	// Restoring rewrites the whole snapshotted directory
+	if !h.FileSystem.checkLocks(c, snapshot.Path) {
+		return
+	}
+
+	if wantsAsync(c) {
+		h.startJob(c, jobs.TypeSnapshotRestore, snapshot.Path, func(ctx context.Context, reporter *jobs.Reporter) (any, error) {
+			return h.snapshots.RestoreSnapshot(id)
+		})
+		return
+	}
+
+	snapshot, err = h.snapshots.RestoreSnapshot(id)
+	if err != nil {
+		h.sendSnapshotError(c, err)
+		return
+	}
+
+	h.SendSuccessWithPath(c, snapshot.Path, "Snapshot restored successfully")
+}
+
+// HandleDeleteSnapshot handles DELETE requests to /snapshots/{id}
+// @Summary Delete a snapshot
+// @Description Delete a snapshot and free its storage. Other snapshots sharing its files are not affected.
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Param id path string true "Snapshot id"
+// @Success 200 {object} SuccessResponse "Snapshot deleted"
+// @Failure 404 {object} ErrorResponse "Snapshot not found"
+// @Router /snapshots/{id} [delete]
+func (h *SnapshotHandler) HandleDeleteSnapshot(c *gin.Context) {
+	id, err := h.GetPathParam(c, "id")
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.snapshots.DeleteSnapshot(id); err != nil {
+		h.sendSnapshotError(c, err)
+		return
+	}
+
+	h.SendJSON(c, http.StatusOK, gin.H{"message": "Snapshot deleted successfully"})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRestoreSnapshotLocks tests that restoring a snapshot is rejected while its
+// directory is locked by another holder
+func TestRestoreSnapshotLocks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SNAPSHOTS_DIR", t.TempDir())
+	h := NewSnapshotHandler(NewFileSystemHandler())
+	router := gin.New()
+	router.POST("/snapshots/:id/restore", h.HandleRestoreSnapshot)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	snapshot, err := h.snapshots.CreateSnapshot(dir, "before")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("package app\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	lockPath(t, path, "agent-1")
+
+	for _, url := range []string{"/snapshots/" + snapshot.ID + "/restore", "/snapshots/" + snapshot.ID + "/restore?async=true"} {
+		w := serveJSON(router, http.MethodPost, url, nil, "agent-2")
+		if w.Code != http.StatusLocked {
+			t.Errorf("Expected status 423 for %s, got %d: %s", url, w.Code, w.Body.String())
+		}
+	}
+	assertContent(t, path, "package app\n")
+
+	w := serveJSON(router, http.MethodPost, "/snapshots/"+snapshot.ID+"/restore", nil, "agent-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	assertContent(t, path, "package main\n")
+}
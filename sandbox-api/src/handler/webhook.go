@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/network"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/handler/webhook"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+)
+
+// webhookWatchDebounce is the delay the changes of a watched directory are merged over
+// into one filesystem.changed event
+const webhookWatchDebounce = time.Second
+
+// WebhookHandler handles the webhooks sandbox lifecycle events are delivered to
+type WebhookHandler struct {
+	*BaseHandler
+	FileSystem *FileSystemHandler
+	Network    *NetworkHandler
+	webhooks   *webhook.Registry
+
+	mu sync.Mutex
+	// watches are the directories watched for the webhooks of filesystem.changed events
+	watches map[string]*directoryWatch
+	// portSubscriptions are the port events subscriptions of the running processes, by
+	// OS PID, while a webhook is registered for port.opened events
+	portSubscriptions map[int]func()
+	syncPorts         chan struct{}
+}
+
+// directoryWatch is a directory watched for the webhooks registered for its changes
+type directoryWatch struct {
+	webhooks int
+	stop     func()
+}
+
+// NewWebhookHandler creates a new webhook handler, watching directories like the
+// filesystem handler and ports like the network handler
+func NewWebhookHandler(fsHandler *FileSystemHandler, networkHandler *NetworkHandler) *WebhookHandler {
+	h := &WebhookHandler{
+		BaseHandler:       NewBaseHandler(),
+		FileSystem:        fsHandler,
+		Network:           networkHandler,
+		webhooks:          webhook.GetRegistry(),
+		watches:           make(map[string]*directoryWatch),
+		portSubscriptions: make(map[int]func()),
+		syncPorts:         make(chan struct{}, 1),
+	}
+	// Follow the ports of the processes started once a webhook wants port.opened events
+	events.GetBus().Subscribe(func(event events.Event) {
+		switch event.Type {
+		case events.ProcessStarted, events.ProcessRestarted, events.ProcessExited:
+			h.requestPortSync()
+		}
+	})
+	go h.portSyncLoop()
+	return h
+}
+
+// CreateWebhookRequest is the request body for registering a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url" example:"https://example.com/hooks/sandbox" binding:"required"`
+	// Events are the types of events delivered, every type when empty
+	Events []string `json:"events" example:"process.exited,port.opened"`
+	// Path is the directory whose changes are delivered as filesystem.changed events
+	Path string `json:"path" example:"/home/user/app"`
+	// Secret signs the deliveries, generated when empty
+	Secret string `json:"secret" example:"my-secret"`
+} // @name CreateWebhookRequest
+
+// HandleListWebhooks handles GET requests to /webhooks
+// @Summary List webhooks
+// @Description List the webhooks events are delivered to, with their delivery counts
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} webhook.Webhook "Webhooks"
+// @Router /webhooks [get]
+func (h *WebhookHandler) HandleListWebhooks(c *gin.Context) {
+	h.SendJSON(c, http.StatusOK, h.webhooks.List())
+}
+
+// HandleCreateWebhook handles POST requests to /webhooks
+// @Summary Register a webhook
+// @Description Register a callback URL sandbox lifecycle events are POSTed to: processes started, exited or restarted, ports opened by processes, changes of the directory of path, snapshots created, and the sandbox becoming idle or active again (see GET /activity). Every delivery carries the X-Sandbox-Event, X-Sandbox-Delivery and X-Sandbox-Timestamp headers and an X-Sandbox-Signature header of the form sha256=<hex HMAC-SHA256 of timestamp + "." + body, keyed by the secret>. Deliveries not answered with a 2xx status are retried 4 times, 1s, 2s, 4s then 8s later. The secret is only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Webhook request"
+// @Success 200 {object} webhook.Webhook "Webhook, with its secret"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 422 {object} ErrorResponse "Path cannot be watched"
+// @Router /webhooks [post]
+func (h *WebhookHandler) HandleCreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := h.BindJSON(c, &req); err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	path := ""
+	if req.Path != "" {
+		formatted, err := lib.FormatPath(req.Path)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		path, err = h.FileSystem.fs.GetAbsolutePath(formatted)
+		if err != nil {
+			h.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	created, err := h.webhooks.Create(req.URL, req.Events, path, req.Secret)
+	if err != nil {
+		h.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if created.Wants(events.FilesystemChanged) {
+		if err := h.watch(path); err != nil {
+			_, _ = h.webhooks.Delete(created.ID)
+			h.SendError(c, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+	h.requestPortSync()
+	h.SendJSON(c, http.StatusOK, created)
+}
+
+// HandleGetWebhook handles GET requests to /webhooks/{id}
+// @Summary Get a webhook
+// @Description Get a webhook and its delivery counts
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} webhook.Webhook "Webhook"
+// @Failure 404 {object} ErrorResponse "Webhook not found"
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) HandleGetWebhook(c *gin.Context) {
+	found, err := h.webhooks.Get(c.Param("id"))
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	h.SendJSON(c, http.StatusOK, found)
+}
+
+// HandleDeleteWebhook handles DELETE requests to /webhooks/{id}
+// @Summary Delete a webhook
+// @Description Stop delivering events to a webhook. The deliveries still being retried are given up.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} webhook.Webhook "Deleted webhook"
+// @Failure 404 {object} ErrorResponse "Webhook not found"
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) HandleDeleteWebhook(c *gin.Context) {
+	deleted, err := h.webhooks.Delete(c.Param("id"))
+	if err != nil {
+		h.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	if deleted.Wants(events.FilesystemChanged) {
+		h.unwatch(deleted.Path)
+	}
+	h.requestPortSync()
+	h.SendJSON(c, http.StatusOK, deleted)
+}
+
+// watch watches a directory for a webhook, publishing its changes as
+// filesystem.changed events. Webhooks of the same directory share its watch.
+func (h *WebhookHandler) watch(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if w, exists := h.watches[path]; exists {
+		w.webhooks++
+		return nil
+	}
+	stop, err := h.FileSystem.WatchDirectoryBatched(path, true, nil, true, webhookWatchDebounce, func(changes []FileEvent) {
+		events.Publish(events.FilesystemChanged, path, changes)
+	})
+	if err != nil {
+		return err
+	}
+	h.watches[path] = &directoryWatch{webhooks: 1, stop: stop}
+	return nil
+}
+
+// unwatch stops the watch of a directory once no webhook wants its changes
+func (h *WebhookHandler) unwatch(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, exists := h.watches[path]
+	if !exists {
+		return
+	}
+	w.webhooks--
+	if w.webhooks == 0 {
+		w.stop()
+		delete(h.watches, path)
+	}
+}
+
+// requestPortSync schedules an update of the port subscriptions without waiting for it
+func (h *WebhookHandler) requestPortSync() {
+	select {
+	case h.syncPorts <- struct{}{}:
+	default:
+	}
+}
+
+func (h *WebhookHandler) portSyncLoop() {
+	for range h.syncPorts {
+		h.syncPortSubscriptions()
+	}
+}
+
+// syncPortSubscriptions subscribes to the port events of the running processes while a
+// webhook wants port.opened events, and unsubscribes from the others
+func (h *WebhookHandler) syncPortSubscriptions() {
+	running := make(map[int]bool)
+	if h.webhooks.Wants(events.PortOpened) {
+		for _, p := range process.GetProcessManager().ListProcesses() {
+			if p.State().Status == process.StatusRunning && p.ProcessPid != 0 {
+				running[p.ProcessPid] = true
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for pid, unsubscribe := range h.portSubscriptions {
+		if !running[pid] {
+			unsubscribe()
+			delete(h.portSubscriptions, pid)
+		}
+	}
+	for pid := range running {
+		if _, exists := h.portSubscriptions[pid]; exists {
+			continue
+		}
+		h.portSubscriptions[pid] = h.Network.SubscribePortEvents(pid, func(event PortEvent) {
+			if event.Event != network.PortOpened || event.Port == nil {
+				return
+			}
+			events.Publish(events.PortOpened, strconv.Itoa(event.Port.LocalPort), event)
+		})
+	}
+	logrus.Debugf("Following the ports of %d processes for webhooks", len(h.portSubscriptions))
+}
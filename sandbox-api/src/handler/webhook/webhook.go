@@ -0,0 +1,383 @@
+// Package webhook delivers the events of the event bus to the callback URLs registered
+// for them. Deliveries are signed with the secret of their webhook and retried with a
+// growing delay until the callback answers with a 2xx status.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+)
+
+// Headers of the deliveries
+const (
+	HeaderEvent     = "X-Sandbox-Event"
+	HeaderDelivery  = "X-Sandbox-Delivery"
+	HeaderTimestamp = "X-Sandbox-Timestamp"
+	HeaderSignature = "X-Sandbox-Signature"
+)
+
+const (
+	// queueSize is the number of events queued for a webhook before new ones are dropped
+	queueSize = 1000
+	// maxAttempts is the number of times a delivery is sent before it is dropped
+	maxAttempts = 5
+	// defaultRetryDelay is the delay before the first retry, doubled for each retry
+	defaultRetryDelay = time.Second
+	// deliveryTimeout bounds each attempt of a delivery
+	deliveryTimeout = 10 * time.Second
+)
+
+// ErrWebhookNotFound is returned for an unknown webhook id
+var ErrWebhookNotFound = apierror.New(apierror.CodeNotFound, "webhook not found")
+
+// Webhook is a callback URL events are delivered to
+type Webhook struct {
+	ID  string `json:"id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	URL string `json:"url" example:"https://example.com/hooks/sandbox" binding:"required"`
+	// Events are the types of events delivered, every type when empty
+	Events []string `json:"events" example:"process.exited,port.opened" binding:"required"`
+	// Path is the directory watched for filesystem.changed events
+	Path      string    `json:"path,omitempty" example:"/home/user/app"`
+	CreatedAt time.Time `json:"createdAt" binding:"required"`
+	// Secret signs the deliveries, it is only returned when the webhook is created
+	Secret         string     `json:"secret,omitempty" example:"3f7a..."`
+	Delivered      int64      `json:"delivered" example:"12" binding:"required"`
+	Failed         int64      `json:"failed" example:"0" binding:"required"` // deliveries dropped after their last attempt, or because the queue was full
+	LastError      string     `json:"lastError,omitempty" example:"https://example.com/hooks/sandbox answered 500"`
+	LastDeliveryAt *time.Time `json:"lastDeliveryAt,omitempty"`
+} // @name Webhook
+
+// Sign returns the signature of a delivery: the hex-encoded HMAC-SHA256, keyed by the
+// secret of the webhook, of the timestamp header, a dot and the body
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Wants reports whether events of a type are delivered to the webhook. Filesystem
+// events are only delivered to the webhooks watching a directory.
+func (w Webhook) Wants(eventType string) bool {
+	if eventType == events.FilesystemChanged && w.Path == "" {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds the webhooks and delivers them the events of a bus
+type Registry struct {
+	mu          sync.RWMutex
+	webhooks    map[string]*hook
+	client      *http.Client
+	retryDelay  time.Duration
+	unsubscribe func()
+}
+
+// Global registry instance
+var (
+	registry     *Registry
+	registryOnce sync.Once
+)
+
+// GetRegistry returns the registry of the webhooks, delivering the events of the global bus
+func GetRegistry() *Registry {
+	registryOnce.Do(func() {
+		registry = NewRegistry(events.GetBus())
+	})
+	return registry
+}
+
+// NewRegistry creates a registry without webhooks delivering the events of bus
+func NewRegistry(bus *events.Bus) *Registry {
+	r := &Registry{
+		webhooks:   make(map[string]*hook),
+		client:     &http.Client{Timeout: deliveryTimeout},
+		retryDelay: defaultRetryDelay,
+	}
+	r.unsubscribe = bus.Subscribe(r.dispatch)
+	return r
+}
+
+// Create registers a webhook for the given types of events, every type when empty. A
+// secret is generated when none is given; it is returned along with the webhook.
+func (r *Registry) Create(callbackURL string, eventTypes []string, path string, secret string) (Webhook, error) {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, apierror.New(apierror.CodeInvalidRequest, "invalid webhook url: must be an http:// or https:// URL")
+	}
+	types := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		if !events.IsType(eventType) {
+			return Webhook{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid event type %s: must be one of %s", eventType, strings.Join(events.Types, ", "))
+		}
+		types = append(types, eventType)
+	}
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return Webhook{}, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(random)
+	}
+
+	h := &hook{
+		webhook: Webhook{ID: uuid.New().String(), URL: callbackURL, Events: types, Path: path, CreatedAt: time.Now()},
+		secret:  secret,
+		client:  r.client,
+		delay:   r.retryDelay,
+		queue:   make(chan events.Event, queueSize),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+
+	r.mu.Lock()
+	r.webhooks[h.webhook.ID] = h
+	r.mu.Unlock()
+
+	created := h.status()
+	created.Secret = secret
+	return created, nil
+}
+
+// List returns the webhooks, oldest first
+func (r *Registry) List() []Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhooks := make([]Webhook, 0, len(r.webhooks))
+	for _, h := range r.webhooks {
+		webhooks = append(webhooks, h.status())
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks
+}
+
+// Get returns a webhook
+func (r *Registry) Get(id string) (Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, exists := r.webhooks[id]
+	if !exists {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return h.status(), nil
+}
+
+// Delete removes a webhook. The deliveries in progress are given up.
+func (r *Registry) Delete(id string) (Webhook, error) {
+	r.mu.Lock()
+	h, exists := r.webhooks[id]
+	delete(r.webhooks, id)
+	r.mu.Unlock()
+
+	if !exists {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	h.stop()
+	return h.status(), nil
+}
+
+// Wants reports whether a webhook is registered for a type of events
+func (r *Registry) Wants(eventType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, h := range r.webhooks {
+		if h.wants(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops delivering events and removes the webhooks
+func (r *Registry) Close() {
+	r.unsubscribe()
+	r.mu.Lock()
+	webhooks := r.webhooks
+	r.webhooks = make(map[string]*hook)
+	r.mu.Unlock()
+	for _, h := range webhooks {
+		h.stop()
+	}
+}
+
+// dispatch queues an event for the webhooks registered for it. The filesystem events
+// are only queued for the webhooks watching their directory.
+func (r *Registry) dispatch(event events.Event) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, h := range r.webhooks {
+		if !h.wants(event.Type) {
+			continue
+		}
+		if event.Type == events.FilesystemChanged && h.webhook.Path != event.Subject {
+			continue
+		}
+		h.enqueue(event)
+	}
+}
+
+// hook is a registered webhook and its delivery queue
+type hook struct {
+	webhook  Webhook
+	secret   string
+	client   *http.Client
+	delay    time.Duration
+	queue    chan events.Event
+	done     chan struct{}
+	stopOnce sync.Once
+
+	delivered atomic.Int64
+	failed    atomic.Int64
+	mu        sync.Mutex
+	lastError string
+	lastAt    *time.Time
+}
+
+func (h *hook) wants(eventType string) bool {
+	return h.webhook.Wants(eventType)
+}
+
+// enqueue queues an event, dropping it when the queue is full
+func (h *hook) enqueue(event events.Event) {
+	select {
+	case h.queue <- event:
+	default:
+		h.failed.Add(1)
+		h.setError(fmt.Sprintf("event %s dropped, the delivery queue is full", event.ID))
+	}
+}
+
+func (h *hook) stop() {
+	h.stopOnce.Do(func() { close(h.done) })
+}
+
+// run delivers the queued events in order until the webhook is removed
+func (h *hook) run() {
+	for {
+		select {
+		case <-h.done:
+			return
+		case event := <-h.queue:
+			h.deliver(event)
+		}
+	}
+}
+
+// deliver sends an event, retrying with a doubling delay
+func (h *hook) deliver(event events.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		h.failed.Add(1)
+		h.setError(err.Error())
+		return
+	}
+
+	delay := h.delay
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = h.send(event, body); err == nil {
+			h.delivered.Add(1)
+			now := time.Now()
+			h.mu.Lock()
+			h.lastAt = &now
+			h.mu.Unlock()
+			return
+		}
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-h.done:
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	h.failed.Add(1)
+	h.setError(err.Error())
+	logrus.Warnf("Failed to deliver event %s to webhook %s after %d attempts: %v", event.ID, h.webhook.ID, maxAttempts, err)
+}
+
+// send makes one attempt of a delivery
+func (h *hook) send(event events.Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event.Type)
+	req.Header.Set(HeaderDelivery, event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(h.secret, timestamp, body))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %d", h.webhook.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (h *hook) setError(message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastError = message
+}
+
+// status returns the webhook with its delivery counts, without its secret
+func (h *hook) status() Webhook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	webhook := h.webhook
+	webhook.Events = append([]string{}, h.webhook.Events...)
+	webhook.Delivered = h.delivered.Load()
+	webhook.Failed = h.failed.Load()
+	webhook.LastError = h.lastError
+	webhook.LastDeliveryAt = h.lastAt
+	return webhook
+}
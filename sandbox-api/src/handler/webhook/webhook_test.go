@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+)
+
+// delivery is a request received by a test callback
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// newCallback returns a server answering with the statuses of answers in turn, then 200
+func newCallback(t *testing.T, answers ...int) (chan delivery, *httptest.Server) {
+	deliveries := make(chan delivery, 100)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		deliveries <- delivery{header: req.Header.Clone(), body: body}
+		if call := int(calls.Add(1)) - 1; call < len(answers) {
+			w.WriteHeader(answers[call])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return deliveries, server
+}
+
+func waitDelivery(t *testing.T, deliveries chan delivery) delivery {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a delivery")
+		return delivery{}
+	}
+}
+
+// TestWebhookDeliverySignature tests that events are delivered signed with the secret
+// of the webhook, and only the types of events it is registered for
+func TestWebhookDeliverySignature(t *testing.T) {
+	deliveries, server := newCallback(t)
+	bus := events.NewBus()
+	r := NewRegistry(bus)
+	defer r.Close()
+
+	webhook, err := r.Create(server.URL, []string{events.ProcessExited}, "", "s3cret")
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	if webhook.Secret != "s3cret" {
+		t.Errorf("Expected the secret to be returned on creation, got %q", webhook.Secret)
+	}
+
+	bus.Publish(events.ProcessStarted, "1", nil)
+	event := bus.Publish(events.ProcessExited, "1", map[string]int{"exitCode": 0})
+	d := waitDelivery(t, deliveries)
+
+	if d.header.Get(HeaderEvent) != events.ProcessExited || d.header.Get(HeaderDelivery) != event.ID {
+		t.Errorf("Expected a delivery of event %s, got headers %v", event.ID, d.header)
+	}
+	expected := Sign("s3cret", d.header.Get(HeaderTimestamp), d.body)
+	if d.header.Get(HeaderSignature) != expected {
+		t.Errorf("Expected signature %s, got %s", expected, d.header.Get(HeaderSignature))
+	}
+
+	listed, err := r.Get(webhook.ID)
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if listed.Secret != "" {
+		t.Error("Expected the secret not to be returned once created")
+	}
+	select {
+	case d := <-deliveries:
+		t.Errorf("Expected a single delivery, got %s", d.header.Get(HeaderEvent))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestWebhookRetry tests that failed deliveries are retried until the callback succeeds
+func TestWebhookRetry(t *testing.T) {
+	deliveries, server := newCallback(t, http.StatusInternalServerError, http.StatusBadGateway)
+	bus := events.NewBus()
+	r := NewRegistry(bus)
+	r.retryDelay = 10 * time.Millisecond
+	defer r.Close()
+
+	webhook, err := r.Create(server.URL, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	if len(webhook.Secret) != 64 {
+		t.Errorf("Expected a generated secret, got %q", webhook.Secret)
+	}
+
+	bus.Publish(events.SnapshotCreated, "snap", nil)
+	first := waitDelivery(t, deliveries)
+	waitDelivery(t, deliveries)
+	last := waitDelivery(t, deliveries)
+	if first.header.Get(HeaderDelivery) != last.header.Get(HeaderDelivery) {
+		t.Error("Expected retries to keep the delivery id")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ := r.Get(webhook.ID); status.Delivered == 1 {
+			if status.Failed != 0 {
+				t.Errorf("Expected no failed delivery, got %d", status.Failed)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Expected the delivery to be counted")
+}
+
+// TestWebhookFilesystemPath tests that filesystem events are only delivered to the
+// webhooks watching their directory
+func TestWebhookFilesystemPath(t *testing.T) {
+	deliveries, server := newCallback(t)
+	bus := events.NewBus()
+	r := NewRegistry(bus)
+	defer r.Close()
+
+	if _, err := r.Create(server.URL, []string{events.FilesystemChanged}, "/app", ""); err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	if !r.Wants(events.FilesystemChanged) || r.Wants(events.PortOpened) {
+		t.Error("Expected the registry to only want filesystem events")
+	}
+
+	bus.Publish(events.FilesystemChanged, "/other", nil)
+	bus.Publish(events.FilesystemChanged, "/app", nil)
+	d := waitDelivery(t, deliveries)
+	if string(d.body) == "" || d.header.Get(HeaderEvent) != events.FilesystemChanged {
+		t.Fatalf("Unexpected delivery: %v", d.header)
+	}
+	select {
+	case <-deliveries:
+		t.Error("Expected the event of another directory not to be delivered")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestWebhookValidation tests that invalid webhooks are rejected
+func TestWebhookValidation(t *testing.T) {
+	r := NewRegistry(events.NewBus())
+	defer r.Close()
+
+	if _, err := r.Create("ftp://example.com", nil, "", ""); err == nil {
+		t.Error("Expected an error for a non-http url")
+	}
+	if _, err := r.Create("http://example.com", []string{"process.paused"}, "", ""); err == nil {
+		t.Error("Expected an error for an unknown event type")
+	}
+	if _, err := r.Delete("missing"); err != ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+	if len(r.List()) != 0 {
+		t.Error("Expected no webhooks")
+	}
+}
@@ -0,0 +1,251 @@
+// Package activity tracks the last activity of the sandbox: API calls, output of the
+// managed processes and clients connected to the ports they listen on. Once nothing
+// happened for the idle timeout, a sandbox.idle event is published for platform
+// schedulers to suspend the sandbox, and a sandbox.active event on the next activity.
+package activity
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+)
+
+// Sources of activity
+const (
+	SourceAPI     = "api"
+	SourceProcess = "process"
+	SourceNetwork = "network"
+)
+
+// Sources lists the sources of activity
+var Sources = []string{SourceAPI, SourceProcess, SourceNetwork}
+
+// defaultIdleTimeout is the idle timeout without IDLE_TIMEOUT
+const defaultIdleTimeout = 10 * time.Minute
+
+// defaultCheckInterval is the interval between checks of the connections and the idle timeout
+const defaultCheckInterval = 5 * time.Second
+
+// Status is the activity of the sandbox
+type Status struct {
+	// LastActivityAt is the time of the last activity, or of the start of the API
+	LastActivityAt time.Time `json:"lastActivityAt" binding:"required"`
+	// LastActivitySource is the source of the last activity, empty when nothing happened since the start of the API
+	LastActivitySource string `json:"lastActivitySource,omitempty" example:"api" enums:"api,process,network"`
+	// Sources are the times of the last activity of each source
+	Sources map[string]time.Time `json:"sources" binding:"required"`
+	// IdleSeconds is the time elapsed since the last activity
+	IdleSeconds int64 `json:"idleSeconds" example:"42" binding:"required"`
+	// IdleTimeout is the time without activity after which the sandbox is idle, in seconds, 0 when disabled
+	IdleTimeout int  `json:"idleTimeout" example:"600" binding:"required"`
+	Idle        bool `json:"idle" example:"false" binding:"required"`
+} // @name ActivityStatus
+
+// Tracker records the last activity of each source and publishes the idle and active events
+type Tracker struct {
+	bus     *events.Bus
+	sandbox string
+	started time.Time
+	// last are the last activities of the sources, in unix nanoseconds
+	last map[string]*atomic.Int64
+	idle atomic.Bool
+
+	mu           sync.Mutex
+	timeout      time.Duration
+	ignoredPorts map[int]bool
+	interval     time.Duration
+	stop         chan struct{}
+}
+
+// Global tracker instance
+var (
+	tracker     *Tracker
+	trackerOnce sync.Once
+)
+
+// GetTracker returns the activity tracker of the sandbox, publishing to the global bus
+func GetTracker() *Tracker {
+	trackerOnce.Do(func() {
+		tracker = NewTracker(events.GetBus(), lib.SandboxName(), IdleTimeoutFromEnv())
+	})
+	return tracker
+}
+
+// NewTracker creates a tracker publishing the idle events of a sandbox to bus once
+// inactive for timeout, never when 0
+func NewTracker(bus *events.Bus, sandbox string, timeout time.Duration) *Tracker {
+	t := &Tracker{
+		bus:          bus,
+		sandbox:      sandbox,
+		started:      time.Now(),
+		last:         make(map[string]*atomic.Int64, len(Sources)),
+		timeout:      timeout,
+		ignoredPorts: make(map[int]bool),
+		interval:     defaultCheckInterval,
+	}
+	for _, source := range Sources {
+		t.last[source] = &atomic.Int64{}
+	}
+	return t
+}
+
+// IdleTimeoutFromEnv returns the idle timeout, read in seconds from IDLE_TIMEOUT. 0
+// disables the idle events.
+func IdleTimeoutFromEnv() time.Duration {
+	value := os.Getenv("IDLE_TIMEOUT")
+	if value == "" {
+		return defaultIdleTimeout
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		logrus.Warnf("Invalid IDLE_TIMEOUT value '%s', using default of %s", value, defaultIdleTimeout)
+		return defaultIdleTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Touch records an activity of a source. An idle sandbox becomes active again.
+func (t *Tracker) Touch(source string) {
+	last, exists := t.last[source]
+	if !exists {
+		return
+	}
+	last.Store(time.Now().UnixNano())
+	if t.idle.CompareAndSwap(true, false) {
+		logrus.Infof("Sandbox active again (%s)", source)
+		t.bus.Publish(events.SandboxActive, t.sandbox, t.Status())
+	}
+}
+
+// IgnorePort excludes the clients connected to a port from the activity, like the
+// ones of the API whose requests are recorded by themselves
+func (t *Tracker) IgnorePort(port int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ignoredPorts[port] = true
+}
+
+// SetIdleTimeout changes the time without activity after which the sandbox is idle, 0
+// disabling the idle events
+func (t *Tracker) SetIdleTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	t.timeout = timeout
+	t.mu.Unlock()
+	// The idle event is published again once the new timeout elapsed
+	t.idle.Store(false)
+	t.check()
+}
+
+// Status returns the activity of the sandbox
+func (t *Tracker) Status() Status {
+	t.mu.Lock()
+	timeout := t.timeout
+	t.mu.Unlock()
+
+	status := Status{
+		LastActivityAt: t.started,
+		Sources:        make(map[string]time.Time),
+		IdleTimeout:    int(timeout / time.Second),
+		Idle:           t.idle.Load(),
+	}
+	for _, source := range Sources {
+		nanos := t.last[source].Load()
+		if nanos == 0 {
+			continue
+		}
+		at := time.Unix(0, nanos)
+		status.Sources[source] = at
+		if at.After(status.LastActivityAt) {
+			status.LastActivityAt = at
+			status.LastActivitySource = source
+		}
+	}
+	status.IdleSeconds = int64(time.Since(status.LastActivityAt) / time.Second)
+	return status
+}
+
+// Start checks the connections and the idle timeout periodically until Stop is called
+func (t *Tracker) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		return
+	}
+	t.stop = make(chan struct{})
+	go t.run(t.stop, t.interval)
+}
+
+// Stop stops the periodic checks
+func (t *Tracker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
+
+func (t *Tracker) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.checkConnections()
+			t.check()
+		}
+	}
+}
+
+// checkConnections records a network activity while clients are connected to the
+// ports the processes of the sandbox listen on
+func (t *Tracker) checkConnections() {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	t.mu.Lock()
+	ignored := make(map[int]bool, len(t.ignoredPorts))
+	for port := range t.ignoredPorts {
+		ignored[port] = true
+	}
+	t.mu.Unlock()
+
+	sockets, err := readSockets()
+	if err != nil {
+		logrus.Debugf("Failed to read sockets: %v", err)
+		return
+	}
+	if externalConnections(sockets, ignored) > 0 {
+		t.Touch(SourceNetwork)
+	}
+}
+
+// check publishes the idle event once the idle timeout elapsed since the last activity
+func (t *Tracker) check() {
+	t.mu.Lock()
+	timeout := t.timeout
+	t.mu.Unlock()
+	if timeout <= 0 || t.idle.Load() {
+		return
+	}
+
+	status := t.Status()
+	if time.Since(status.LastActivityAt) < timeout {
+		return
+	}
+	if t.idle.CompareAndSwap(false, true) {
+		status.Idle = true
+		logrus.Infof("Sandbox idle for %s", time.Since(status.LastActivityAt).Round(time.Second))
+		t.bus.Publish(events.SandboxIdle, t.sandbox, status)
+	}
+}
@@ -0,0 +1,88 @@
+package activity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/events"
+)
+
+// TestTrackerIdle tests that the idle event is published once when the timeout elapsed
+// without activity, and the active event on the next activity
+func TestTrackerIdle(t *testing.T) {
+	bus := events.NewBus()
+	var received []events.Event
+	bus.Subscribe(func(event events.Event) {
+		received = append(received, event)
+	})
+	tracker := NewTracker(bus, "my-sandbox", 50*time.Millisecond)
+
+	tracker.Touch(SourceAPI)
+	tracker.check()
+	if len(received) != 0 {
+		t.Fatalf("Expected no event before the timeout, got %+v", received)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	tracker.check()
+	tracker.check()
+	if len(received) != 1 || received[0].Type != events.SandboxIdle || received[0].Subject != "my-sandbox" {
+		t.Fatalf("Expected a single idle event, got %+v", received)
+	}
+	status := received[0].Data.(Status)
+	if !status.Idle || status.LastActivitySource != SourceAPI || status.IdleTimeout != 0 {
+		t.Errorf("Unexpected idle status: %+v", status)
+	}
+
+	tracker.Touch(SourceProcess)
+	tracker.Touch(SourceProcess)
+	if len(received) != 2 || received[1].Type != events.SandboxActive {
+		t.Fatalf("Expected an active event, got %+v", received)
+	}
+	if status := tracker.Status(); status.Idle || status.LastActivitySource != SourceProcess || len(status.Sources) != 2 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	tracker.SetIdleTimeout(0)
+	time.Sleep(60 * time.Millisecond)
+	tracker.check()
+	if len(received) != 2 {
+		t.Errorf("Expected no idle event with the timeout disabled, got %+v", received)
+	}
+}
+
+// TestExternalConnections tests that only the connections of clients outside the
+// sandbox to listened ports count as activity
+func TestExternalConnections(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:0BB8 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 3 1 0000000000000000 20 4 30 10 -1
+   3: 0200000A:1F90 0300000A:D432 01 00000000:00000000 00:00000000 00000000     0        0 4 1 0000000000000000 20 4 30 10 -1
+   4: 0200000A:D433 0400000A:01BB 01 00000000:00000000 00:00000000 00000000     0        0 5 1 0000000000000000 20 4 30 10 -1
+`
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0000000000000000FFFF00000200000A:0BB8 0000000000000000FFFF00000500000A:D434 01 00000000:00000000 00:00000000 00000000     0        0 6 1 0000000000000000 20 4 30 10 -1
+`
+	sockets, err := parseSockets(strings.NewReader(tcp))
+	if err != nil {
+		t.Fatalf("Failed to parse sockets: %v", err)
+	}
+	sockets6, err := parseSockets(strings.NewReader(tcp6))
+	if err != nil {
+		t.Fatalf("Failed to parse sockets: %v", err)
+	}
+	sockets = append(sockets, sockets6...)
+	if len(sockets) != 6 || sockets[3].remoteIP.String() != "10.0.0.3" || sockets[0].localPort != 3000 {
+		t.Fatalf("Unexpected sockets: %+v", sockets)
+	}
+
+	// 10.0.0.3 on 8080 and 10.0.0.5 on 3000, not the loopback client nor the outgoing connection
+	if count := externalConnections(sockets, map[int]bool{}); count != 2 {
+		t.Errorf("Expected 2 external connections, got %d", count)
+	}
+	if count := externalConnections(sockets, map[int]bool{8080: true}); count != 1 {
+		t.Errorf("Expected 1 external connection without the ignored port, got %d", count)
+	}
+}
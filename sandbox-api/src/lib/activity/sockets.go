@@ -0,0 +1,111 @@
+package activity
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// States of the sockets of /proc/net/tcp
+const (
+	socketEstablished = "01"
+	socketListen      = "0A"
+)
+
+// socket is a TCP socket of /proc/net/tcp or /proc/net/tcp6
+type socket struct {
+	localPort  int
+	remoteIP   net.IP
+	remotePort int
+	state      string
+}
+
+// readSockets returns the TCP sockets of the sandbox
+func readSockets() ([]socket, error) {
+	sockets := make([]socket, 0)
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		parsed, err := parseSockets(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		sockets = append(sockets, parsed...)
+	}
+	return sockets, nil
+}
+
+// parseSockets parses the sockets of a /proc/net/tcp file
+func parseSockets(r io.Reader) ([]socket, error) {
+	sockets := make([]socket, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		_, localPort, err := parseSocketAddress(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		remoteIP, remotePort, err := parseSocketAddress(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, socket{localPort: localPort, remoteIP: remoteIP, remotePort: remotePort, state: fields[3]})
+	}
+	return sockets, scanner.Err()
+}
+
+// parseSocketAddress parses an address of /proc/net/tcp: the hex IP, as 32-bit words
+// in host byte order, a colon and the hex port
+func parseSocketAddress(address string) (net.IP, int, error) {
+	ipHex, portHex, found := strings.Cut(address, ":")
+	if !found {
+		return nil, 0, fmt.Errorf("invalid address %q", address)
+	}
+	port, err := strconv.ParseInt(portHex, 16, 32)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid address %q", address)
+	}
+	raw, err := hex.DecodeString(ipHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", address)
+	}
+	// Words are written little-endian on the architectures the sandbox runs on
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return net.IP(raw), int(port), nil
+}
+
+// externalConnections counts the connections of clients outside the sandbox to the
+// ports listened on, except the ignored ones
+func externalConnections(sockets []socket, ignored map[int]bool) int {
+	listening := make(map[int]bool)
+	for _, s := range sockets {
+		if s.state == socketListen && !ignored[s.localPort] {
+			listening[s.localPort] = true
+		}
+	}
+
+	count := 0
+	for _, s := range sockets {
+		if s.state == socketEstablished && listening[s.localPort] && !s.remoteIP.IsLoopback() {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,303 @@
+// Package apierror defines the error model shared by the REST, WebSocket and MCP
+// layers: errors carry a stable code clients can rely on instead of matching messages,
+// and optional details.
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"syscall"
+)
+
+// Code identifies a kind of error. Codes are stable, messages are not.
+type Code string
+
+// Generic codes, used for errors without a more specific code
+const (
+	CodeInvalidRequest      Code = "INVALID_REQUEST"
+	CodeForbidden           Code = "FORBIDDEN"
+	CodeNotFound            Code = "NOT_FOUND"
+	CodeConflict            Code = "CONFLICT"
+	CodePreconditionFailed  Code = "PRECONDITION_FAILED"
+	CodePayloadTooLarge     Code = "PAYLOAD_TOO_LARGE"
+	CodeUnprocessable       Code = "UNPROCESSABLE"
+	CodeTooManyRequests     Code = "TOO_MANY_REQUESTS"
+	CodeInternal            Code = "INTERNAL"
+	CodeBadGateway          Code = "BAD_GATEWAY"
+	CodeUnavailable         Code = "UNAVAILABLE"
+	CodeTimeout             Code = "TIMEOUT"
+	CodeInsufficientStorage Code = "INSUFFICIENT_STORAGE"
+)
+
+// Filesystem codes
+const (
+	CodeFSNotFound           Code = "FS_NOT_FOUND"
+	CodeFSAlreadyExists      Code = "FS_ALREADY_EXISTS"
+	CodeFSNotADirectory      Code = "FS_NOT_A_DIRECTORY"
+	CodeFSIsADirectory       Code = "FS_IS_A_DIRECTORY"
+	CodeFSPermissionDenied   Code = "FS_PERMISSION_DENIED"
+	CodeFSOutsideRoot        Code = "FS_OUTSIDE_ROOT"
+	CodeFSPreconditionFailed Code = "FS_PRECONDITION_FAILED"
+	CodeFSQuotaExceeded      Code = "FS_QUOTA_EXCEEDED"
+	CodeFSSnapshotNotFound   Code = "FS_SNAPSHOT_NOT_FOUND"
+	CodeFSLocked             Code = "FS_LOCKED"
+	CodeFSLockNotFound       Code = "FS_LOCK_NOT_FOUND"
+)
+
+// Stream codes
+const (
+	CodeStreamLimitExceeded Code = "STREAM_LIMIT_EXCEEDED"
+	CodeStreamOverflow      Code = "STREAM_OVERFLOW"
+)
+
+// Multipart upload codes
+const (
+	CodeMultipartUnavailable       Code = "MULTIPART_UNAVAILABLE"
+	CodeMultipartUploadNotFound    Code = "MULTIPART_UPLOAD_NOT_FOUND"
+	CodeMultipartDownloadNotFound  Code = "MULTIPART_DOWNLOAD_NOT_FOUND"
+	CodeMultipartUploadBusy        Code = "MULTIPART_UPLOAD_BUSY"
+	CodeMultipartInvalidPartNumber Code = "MULTIPART_INVALID_PART_NUMBER"
+	CodeMultipartPartNotFound      Code = "MULTIPART_PART_NOT_FOUND"
+	CodeMultipartETagMismatch      Code = "MULTIPART_ETAG_MISMATCH"
+	CodeMultipartInvalidOffset     Code = "MULTIPART_INVALID_OFFSET"
+	CodeMultipartPartIncomplete    Code = "MULTIPART_PART_INCOMPLETE"
+	CodeMultipartChecksumMismatch  Code = "MULTIPART_CHECKSUM_MISMATCH"
+)
+
+// Process codes
+const (
+	CodeProcNotFound         Code = "PROC_NOT_FOUND"
+	CodeProcNameConflict     Code = "PROC_NAME_CONFLICT"
+	CodeProcNotRunning       Code = "PROC_NOT_RUNNING"
+	CodeProcRunning          Code = "PROC_RUNNING"
+	CodeProcAlreadyManaged   Code = "PROC_ALREADY_MANAGED"
+	CodeProcDeniedByPolicy   Code = "PROC_DENIED_BY_POLICY"
+	CodeProcGroupNotFound    Code = "PROC_GROUP_NOT_FOUND"
+	CodeProcTemplateNotFound Code = "PROC_TEMPLATE_NOT_FOUND"
+)
+
+// statuses are the HTTP statuses of the codes
+var statuses = map[Code]int{
+	CodeInvalidRequest:      http.StatusBadRequest,
+	CodeForbidden:           http.StatusForbidden,
+	CodeNotFound:            http.StatusNotFound,
+	CodeConflict:            http.StatusConflict,
+	CodePreconditionFailed:  http.StatusPreconditionFailed,
+	CodePayloadTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeUnprocessable:       http.StatusUnprocessableEntity,
+	CodeTooManyRequests:     http.StatusTooManyRequests,
+	CodeInternal:            http.StatusInternalServerError,
+	CodeBadGateway:          http.StatusBadGateway,
+	CodeUnavailable:         http.StatusServiceUnavailable,
+	CodeTimeout:             http.StatusGatewayTimeout,
+	CodeInsufficientStorage: http.StatusInsufficientStorage,
+
+	CodeFSNotFound:           http.StatusNotFound,
+	CodeFSAlreadyExists:      http.StatusConflict,
+	CodeFSNotADirectory:      http.StatusBadRequest,
+	CodeFSIsADirectory:       http.StatusBadRequest,
+	CodeFSPermissionDenied:   http.StatusForbidden,
+	CodeFSOutsideRoot:        http.StatusBadRequest,
+	CodeFSPreconditionFailed: http.StatusPreconditionFailed,
+	CodeFSQuotaExceeded:      http.StatusInsufficientStorage,
+	CodeFSSnapshotNotFound:   http.StatusNotFound,
+	CodeFSLocked:             http.StatusLocked,
+	CodeFSLockNotFound:       http.StatusNotFound,
+
+	CodeStreamLimitExceeded: http.StatusTooManyRequests,
+	CodeStreamOverflow:      http.StatusTooManyRequests,
+
+	CodeMultipartUnavailable:       http.StatusServiceUnavailable,
+	CodeMultipartUploadNotFound:    http.StatusNotFound,
+	CodeMultipartDownloadNotFound:  http.StatusNotFound,
+	CodeMultipartUploadBusy:        http.StatusConflict,
+	CodeMultipartInvalidPartNumber: http.StatusBadRequest,
+	CodeMultipartPartNotFound:      http.StatusBadRequest,
+	CodeMultipartETagMismatch:      http.StatusBadRequest,
+	CodeMultipartInvalidOffset:     http.StatusBadRequest,
+	CodeMultipartPartIncomplete:    http.StatusBadRequest,
+	CodeMultipartChecksumMismatch:  http.StatusBadRequest,
+
+	CodeProcNotFound:         http.StatusNotFound,
+	CodeProcNameConflict:     http.StatusConflict,
+	CodeProcNotRunning:       http.StatusConflict,
+	CodeProcRunning:          http.StatusConflict,
+	CodeProcAlreadyManaged:   http.StatusConflict,
+	CodeProcDeniedByPolicy:   http.StatusForbidden,
+	CodeProcGroupNotFound:    http.StatusNotFound,
+	CodeProcTemplateNotFound: http.StatusNotFound,
+}
+
+// Status returns the HTTP status of the code, 500 for unknown codes
+func (c Code) Status() int {
+	if status, exists := statuses[c]; exists {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeForStatus returns the generic code of an HTTP error status
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusBadGateway:
+		return CodeBadGateway
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return CodeTimeout
+	case http.StatusInsufficientStorage:
+		return CodeInsufficientStorage
+	}
+	return CodeInternal
+}
+
+// Error is an error with a code, and details such as the name of the entity involved.
+// Errors match the errors of the same code with errors.Is.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]any
+	Err     error
+}
+
+// New returns an error with a code
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Newf returns an error with a code and a formatted message, wrapping the error of a
+// %w verb
+func Newf(code Code, format string, args ...any) *Error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Code: code, Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Wrap returns an error with a code, with the message of err
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail returns a copy of the error with a detail added
+func (e *Error) WithDetail(key string, value any) *Error {
+	details := make(map[string]any, len(e.Details)+1)
+	for k, v := range e.Details {
+		details[k] = v
+	}
+	details[key] = value
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// From returns the first *Error of the chain of err. Errors of the filesystem and
+// deadlines without one get the code of their kind, and nil is returned for other
+// errors.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	code := Code("")
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		code = CodeFSNotFound
+	case errors.Is(err, fs.ErrExist):
+		code = CodeFSAlreadyExists
+	case errors.Is(err, fs.ErrPermission):
+		code = CodeFSPermissionDenied
+	case errors.Is(err, syscall.ENOTDIR):
+		code = CodeFSNotADirectory
+	case errors.Is(err, syscall.EISDIR):
+		code = CodeFSIsADirectory
+	case errors.Is(err, syscall.ENOSPC):
+		code = CodeInsufficientStorage
+	case errors.Is(err, context.DeadlineExceeded):
+		code = CodeTimeout
+	default:
+		return nil
+	}
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+// HTTPStatus returns the status to answer err with, given the status chosen by the
+// handler. Errors with a code are answered with the status of their code, and the
+// errors of the filesystem and deadlines with the status of their kind instead of the
+// generic 422 and 500 statuses.
+func HTTPStatus(err error, status int) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code.Status()
+	}
+	if status == http.StatusUnprocessableEntity || status == http.StatusInternalServerError {
+		if classified := From(err); classified != nil {
+			return classified.Code.Status()
+		}
+	}
+	return status
+}
+
+// Body is the error returned to clients. Error is the message, named so for
+// compatibility with the errors returned before codes were introduced.
+type Body struct {
+	Error   string         `json:"error" example:"process with Identifier my-process not found" binding:"required"`
+	Code    Code           `json:"code" example:"PROC_NOT_FOUND" binding:"required"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// NewBody returns the body of an error, with the code of the error or else fallback
+func NewBody(err error, fallback Code) Body {
+	body := Body{Error: err.Error(), Code: fallback}
+	if apiErr := From(err); apiErr != nil {
+		body.Code = apiErr.Code
+		body.Details = apiErr.Details
+	}
+	return body
+}
+
+// JSON returns the body of an error encoded as JSON, with the code of the error or else
+// fallback
+func JSON(err error, fallback Code) string {
+	data, marshalErr := json.Marshal(NewBody(err, fallback))
+	if marshalErr != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -0,0 +1,103 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// TestFrom tests finding the code of wrapped and classified errors
+func TestFrom(t *testing.T) {
+	_, statErr := os.Stat("/nonexistent/file")
+
+	tests := []struct {
+		name string
+		err  error
+		code Code
+	}{
+		{"coded", New(CodeProcNotFound, "process not found"), CodeProcNotFound},
+		{"wrapped", fmt.Errorf("failed: %w", New(CodeFSQuotaExceeded, "quota exceeded")), CodeFSQuotaExceeded},
+		{"not exist", statErr, CodeFSNotFound},
+		{"permission", fmt.Errorf("write: %w", os.ErrPermission), CodeFSPermissionDenied},
+		{"deadline", context.DeadlineExceeded, CodeTimeout},
+		{"other", errors.New("boom"), ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err)
+			if tt.code == "" {
+				if got != nil {
+					t.Errorf("Expected no code, got %s", got.Code)
+				}
+				return
+			}
+			if got == nil || got.Code != tt.code {
+				t.Errorf("Expected code %s, got %+v", tt.code, got)
+			}
+		})
+	}
+}
+
+// TestErrorIs tests matching errors by code, through wrapping and details
+func TestErrorIs(t *testing.T) {
+	sentinel := New(CodeProcRunning, "process is still running")
+	err := fmt.Errorf("%w: 1234", sentinel.WithDetail("pid", "1234"))
+	if !errors.Is(err, sentinel) {
+		t.Error("Expected the error to match its sentinel")
+	}
+	if errors.Is(err, New(CodeProcNotFound, "process not found")) {
+		t.Error("Expected the error not to match another code")
+	}
+	if sentinel.Details != nil {
+		t.Error("Expected WithDetail to leave the sentinel unchanged")
+	}
+
+	wrapped := Newf(CodeFSNotFound, "read %s: %w", "a.txt", os.ErrNotExist)
+	if !errors.Is(wrapped, os.ErrNotExist) || wrapped.Error() != "read a.txt: file does not exist" {
+		t.Errorf("Expected Newf to wrap the %%w error, got %q", wrapped)
+	}
+}
+
+// TestHTTPStatus tests the status of coded, classified and other errors
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   int
+	}{
+		{"coded", New(CodeMultipartUploadNotFound, "upload not found"), http.StatusInternalServerError, http.StatusNotFound},
+		{"coded overrides", New(CodeProcNotRunning, "not running"), http.StatusNotFound, http.StatusConflict},
+		{"classified generic", os.ErrNotExist, http.StatusUnprocessableEntity, http.StatusNotFound},
+		{"classified specific", os.ErrNotExist, http.StatusBadRequest, http.StatusBadRequest},
+		{"other", errors.New("boom"), http.StatusInternalServerError, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(tt.err, tt.status); got != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestJSON tests encoding errors with their code and details
+func TestJSON(t *testing.T) {
+	err := fmt.Errorf("complete: %w", Newf(CodeMultipartETagMismatch, "etag mismatch for part %d", 2).WithDetail("partNumber", 2))
+	var body Body
+	if jsonErr := json.Unmarshal([]byte(JSON(err, CodeInternal)), &body); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if body.Code != CodeMultipartETagMismatch || body.Error != "complete: etag mismatch for part 2" || body.Details["partNumber"] != float64(2) {
+		t.Errorf("Unexpected body %+v", body)
+	}
+
+	if body := NewBody(errors.New("boom"), CodeInternal); body.Code != CodeInternal || body.Details != nil {
+		t.Errorf("Expected the fallback code, got %+v", body)
+	}
+}
@@ -43,6 +43,18 @@ func IsEnabled() bool {
 	return os.Getenv("RELACE_API_KEY") != "" || os.Getenv("MORPH_API_KEY") != ""
 }
 
+// Providers returns the fastapply providers configured, in the order NewClient picks them
+func Providers() []Provider {
+	providers := make([]Provider, 0, 2)
+	if os.Getenv("RELACE_API_KEY") != "" {
+		providers = append(providers, ProviderRelace)
+	}
+	if os.Getenv("MORPH_API_KEY") != "" {
+		providers = append(providers, ProviderMorph)
+	}
+	return providers
+}
+
 // NewClient creates a new code editing client based on environment variables
 // It checks for RELACE_API_KEY first, then falls back to MORPH_API_KEY
 func NewClient() (Client, error) {
@@ -0,0 +1,109 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContextLines is the number of unchanged lines around the changes of a hunk
+const diffContextLines = 3
+
+// DiffHunk is a group of changed lines of a file with the unchanged lines around them.
+// Lines are prefixed with ' ' when unchanged, '-' when removed and '+' when added.
+type DiffHunk struct {
+	OldStart int      `json:"oldStart" example:"1"`
+	OldLines int      `json:"oldLines" example:"3"`
+	NewStart int      `json:"newStart" example:"1"`
+	NewLines int      `json:"newLines" example:"3"`
+	Lines    []string `json:"lines" example:"-function hello() {,+function hello(world) {"`
+} // @name DiffHunk
+
+// Diff returns the unified diff of the edit of a file from original to updated, and
+// its hunks. Both are empty when the content is unchanged.
+func Diff(path, original, updated string) (string, []DiffHunk) {
+	name := strings.TrimPrefix(path, "/")
+	return Unified("a/"+name, "b/"+name, original, updated, diffContextLines)
+}
+
+// Unified returns the unified diff from original, labeled from, to updated, labeled to,
+// with context unchanged lines around the changes, and its hunks. Both are empty when the
+// content is unchanged.
+func Unified(from, to, original, updated string, context int) (string, []DiffHunk) {
+	oldLines := splitLines(original)
+	newLines := splitLines(updated)
+	matcher := difflib.NewMatcher(oldLines, newLines)
+
+	hunks := make([]DiffHunk, 0)
+	var unified strings.Builder
+	for _, group := range matcher.GetGroupedOpCodes(context) {
+		first, last := group[0], group[len(group)-1]
+		hunk := DiffHunk{
+			OldStart: hunkStart(first.I1, last.I2),
+			OldLines: last.I2 - first.I1,
+			NewStart: hunkStart(first.J1, last.J2),
+			NewLines: last.J2 - first.J1,
+			Lines:    make([]string, 0),
+		}
+		for _, op := range group {
+			switch op.Tag {
+			case 'e':
+				hunk.Lines = appendPrefixed(hunk.Lines, " ", oldLines[op.I1:op.I2])
+			case 'd':
+				hunk.Lines = appendPrefixed(hunk.Lines, "-", oldLines[op.I1:op.I2])
+			case 'i':
+				hunk.Lines = appendPrefixed(hunk.Lines, "+", newLines[op.J1:op.J2])
+			case 'r':
+				hunk.Lines = appendPrefixed(hunk.Lines, "-", oldLines[op.I1:op.I2])
+				hunk.Lines = appendPrefixed(hunk.Lines, "+", newLines[op.J1:op.J2])
+			}
+		}
+		hunks = append(hunks, hunk)
+
+		if unified.Len() == 0 {
+			fmt.Fprintf(&unified, "--- %s\n+++ %s\n", from, to)
+		}
+		fmt.Fprintf(&unified, "@@ -%d,%d +%d,%d @@\n", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+		for _, line := range hunk.Lines {
+			unified.WriteString(line)
+			unified.WriteString("\n")
+		}
+	}
+	return unified.String(), hunks
+}
+
+// noNewlineMarker follows the last line of a content not ending with a newline
+const noNewlineMarker = "\n\\ No newline at end of file"
+
+// splitLines splits content into lines without their line ending. The last line is
+// followed by noNewlineMarker when it has no line ending, so that it differs from the
+// same line with one, as in unified diffs.
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	if !strings.HasSuffix(content, "\n") {
+		lines := strings.Split(content, "\n")
+		lines[len(lines)-1] += noNewlineMarker
+		return lines
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// hunkStart returns the 1-based first line of a hunk range, or the line before it when
+// the range is empty, as in unified diffs
+func hunkStart(start, end int) int {
+	if start == end {
+		return start
+	}
+	return start + 1
+}
+
+// appendPrefixed appends lines with a prefix
+func appendPrefixed(lines []string, prefix string, added []string) []string {
+	for _, line := range added {
+		lines = append(lines, prefix+line)
+	}
+	return lines
+}
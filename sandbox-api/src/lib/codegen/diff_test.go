@@ -0,0 +1,68 @@
+package codegen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDiff tests the unified diff and hunks of an edit
+func TestDiff(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	updated := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\n"
+
+	diff, hunks := Diff("src/main.txt", original, updated)
+
+	expected := "--- a/src/main.txt\n+++ b/src/main.txt\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -7,3 +7,4 @@\n g\n h\n i\n+j\n"
+	if diff != expected {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+	if !reflect.DeepEqual(hunks[0], DiffHunk{OldStart: 1, OldLines: 5, NewStart: 1, NewLines: 5, Lines: []string{" a", "-b", "+B", " c", " d", " e"}}) {
+		t.Errorf("Unexpected first hunk: %+v", hunks[0])
+	}
+
+	// A new file is a single hunk of added lines
+	_, hunks = Diff("new.txt", "", "x\ny\n")
+	if len(hunks) != 1 || hunks[0].OldStart != 0 || hunks[0].OldLines != 0 || hunks[0].NewStart != 1 || hunks[0].NewLines != 2 {
+		t.Errorf("Unexpected hunks for a new file: %+v", hunks)
+	}
+
+	if diff, hunks := Diff("same.txt", original, original); diff != "" || len(hunks) != 0 {
+		t.Errorf("Expected no diff for unchanged content, got %q", diff)
+	}
+
+	// A missing newline at the end of the content is a change
+	diff, _ = Unified("/old.txt", "/new.txt", "x\ny", "x\ny\n", 0)
+	if expected := "--- /old.txt\n+++ /new.txt\n@@ -2,1 +2,1 @@\n-y\n\\ No newline at end of file\n+y\n"; diff != expected {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+}
+
+// TestPreviewStore tests that previews are applied once and expire
+func TestPreviewStore(t *testing.T) {
+	store := &PreviewStore{previews: make(map[string]*Preview)}
+
+	preview := &Preview{Path: "a.txt", Updated: "x"}
+	store.Add(preview)
+	if preview.ID == "" {
+		t.Fatal("Expected an ID to be assigned")
+	}
+	if taken, ok := store.Take(preview.ID); !ok || taken.Updated != "x" {
+		t.Errorf("Expected the preview, got %+v", taken)
+	}
+	if _, ok := store.Take(preview.ID); ok {
+		t.Error("Expected a preview to be taken once")
+	}
+
+	expired := &Preview{Path: "b.txt"}
+	store.Add(expired)
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	if _, ok := store.Take(expired.ID); ok {
+		t.Error("Expected an expired preview not to be returned")
+	}
+}
@@ -0,0 +1,73 @@
+package codegen
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PreviewTTL is how long a previewed edit can be applied
+const PreviewTTL = 15 * time.Minute
+
+// Preview is an edit computed by the provider but not written yet
+type Preview struct {
+	ID        string
+	Path      string
+	Exists    bool // whether the file existed when the edit was previewed
+	Original  string
+	Updated   string
+	Provider  string
+	ExpiresAt time.Time
+}
+
+// PreviewStore keeps previewed edits until they are applied or expire
+type PreviewStore struct {
+	mu       sync.Mutex
+	previews map[string]*Preview
+}
+
+var (
+	previewStore     *PreviewStore
+	previewStoreOnce sync.Once
+)
+
+// GetPreviewStore returns the store of previewed edits
+func GetPreviewStore() *PreviewStore {
+	previewStoreOnce.Do(func() {
+		previewStore = &PreviewStore{previews: make(map[string]*Preview)}
+	})
+	return previewStore
+}
+
+// Add stores a previewed edit, assigning its ID and expiration
+func (s *PreviewStore) Add(preview *Preview) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range s.previews {
+		if now.After(existing.ExpiresAt) {
+			delete(s.previews, id)
+		}
+	}
+	preview.ID = uuid.New().String()
+	preview.ExpiresAt = now.Add(PreviewTTL)
+	s.previews[preview.ID] = preview
+}
+
+// Take removes a previewed edit from the store and returns it, unless it has expired
+func (s *PreviewStore) Take(id string) (*Preview, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	preview, exists := s.previews[id]
+	if !exists {
+		return nil, false
+	}
+	delete(s.previews, id)
+	if time.Now().After(preview.ExpiresAt) {
+		return nil, false
+	}
+	return preview, true
+}
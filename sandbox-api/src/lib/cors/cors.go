@@ -0,0 +1,182 @@
+// Package cors decides which browser origins may call the API: the CORS headers of the
+// REST routes and the origin check of the WebSocket endpoints share the same allowed
+// origins, set with CORS_ALLOWED_ORIGINS or the API.
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Sources of the allowed origins
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceAPI     = "api"
+)
+
+// AnyOrigin allows every origin
+const AnyOrigin = "*"
+
+// Policy holds the origins allowed to call the API from a browser
+type Policy struct {
+	mu      sync.RWMutex
+	origins []string
+	source  string
+}
+
+// Global policy instance
+var (
+	policy     *Policy
+	policyOnce sync.Once
+)
+
+// GetPolicy returns the CORS policy of the API, with the origins of CORS_ALLOWED_ORIGINS
+func GetPolicy() *Policy {
+	policyOnce.Do(func() {
+		policy = NewPolicy()
+		origins, source := AllowedOriginsFromEnv()
+		policy.set(origins, source)
+	})
+	return policy
+}
+
+// NewPolicy creates a policy allowing every origin
+func NewPolicy() *Policy {
+	return &Policy{origins: []string{AnyOrigin}, source: SourceDefault}
+}
+
+// AllowedOriginsFromEnv returns the comma-separated origins of CORS_ALLOWED_ORIGINS,
+// every origin when not set
+func AllowedOriginsFromEnv() ([]string, string) {
+	value := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if value == "" {
+		return []string{AnyOrigin}, SourceDefault
+	}
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	normalized, err := normalizeOrigins(origins)
+	if err != nil {
+		logrus.Warnf("Invalid CORS_ALLOWED_ORIGINS value '%s', allowing every origin: %v", value, err)
+		return []string{AnyOrigin}, SourceDefault
+	}
+	return normalized, SourceEnv
+}
+
+// AllowedOrigins returns the allowed origins and where they come from
+func (p *Policy) AllowedOrigins() ([]string, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string{}, p.origins...), p.source
+}
+
+// SetAllowedOrigins replaces the allowed origins: "*" for every origin, origins like
+// https://app.example.com, or patterns like https://*.example.com for its subdomains.
+// An empty list only allows clients without an origin, which are not browsers.
+func (p *Policy) SetAllowedOrigins(origins []string) error {
+	normalized, err := normalizeOrigins(origins)
+	if err != nil {
+		return err
+	}
+	p.set(normalized, SourceAPI)
+	return nil
+}
+
+func (p *Policy) set(origins []string, source string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.origins = origins
+	p.source = source
+}
+
+// AllowsAny tells whether every origin is allowed
+func (p *Policy) AllowsAny() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, allowed := range p.origins {
+		if allowed == AnyOrigin {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed tells whether a browser of an origin may call the API
+func (p *Policy) Allowed(origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, allowed := range p.origins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin is the origin check of the WebSocket upgraders. Clients without an
+// origin, which are not browsers, and pages served by the sandbox itself are always
+// allowed; other browsers only from an allowed origin.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if p.Allowed(origin) {
+		return true
+	}
+	logrus.Warnf("Rejected WebSocket upgrade of %s from origin %s", r.URL.Path, origin)
+	return false
+}
+
+// matchOrigin matches an origin against an allowed origin or pattern
+func matchOrigin(allowed, origin string) bool {
+	if allowed == AnyOrigin || allowed == origin {
+		return true
+	}
+	scheme, host, found := strings.Cut(allowed, "://*.")
+	if !found {
+		return false
+	}
+	prefix := scheme + "://"
+	if !strings.HasPrefix(origin, prefix) {
+		return false
+	}
+	// The subdomain must not be empty nor hide a port or path
+	subdomain, matched := strings.CutSuffix(strings.TrimPrefix(origin, prefix), "."+host)
+	return matched && subdomain != "" && !strings.ContainsAny(subdomain, ":/")
+}
+
+// normalizeOrigins validates origins and patterns, lowercasing them and removing their
+// trailing slash
+func normalizeOrigins(origins []string) ([]string, error) {
+	normalized := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		if origin == AnyOrigin {
+			normalized = append(normalized, origin)
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil || strings.Contains(u.Host, "*") {
+			return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid origin %q: expected *, scheme://host[:port] or scheme://*.domain", origin)
+		}
+		normalized = append(normalized, origin)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,108 @@
+package cors
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAllowed tests the matching of origins against exact origins and subdomain patterns
+func TestAllowed(t *testing.T) {
+	policy := NewPolicy()
+	if !policy.Allowed("https://anything.test") || !policy.AllowsAny() {
+		t.Fatalf("Expected every origin to be allowed by default")
+	}
+
+	if err := policy.SetAllowedOrigins([]string{"https://App.example.com/", "https://*.example.org", "http://localhost:3000"}); err != nil {
+		t.Fatalf("Failed to set the allowed origins: %v", err)
+	}
+	origins, source := policy.AllowedOrigins()
+	if source != SourceAPI || len(origins) != 3 || origins[0] != "https://app.example.com" {
+		t.Fatalf("Unexpected allowed origins: %v (%s)", origins, source)
+	}
+
+	tests := map[string]bool{
+		"https://app.example.com":       true,
+		"https://APP.example.com":       true,
+		"http://app.example.com":        false,
+		"https://other.example.com":     false,
+		"https://a.example.org":         true,
+		"https://a.b.example.org":       true,
+		"https://example.org":           false,
+		"https://evil.com/.example.org": false,
+		"https://a.example.org:8443":    false,
+		"https://example.org.evil.com":  false,
+		"http://localhost:3000":         true,
+		"http://localhost:3001":         false,
+	}
+	for origin, expected := range tests {
+		if allowed := policy.Allowed(origin); allowed != expected {
+			t.Errorf("Allowed(%q) = %v, expected %v", origin, allowed, expected)
+		}
+	}
+	if policy.AllowsAny() {
+		t.Errorf("Expected not every origin to be allowed")
+	}
+}
+
+// TestSetAllowedOriginsValidation tests that invalid origins are rejected, keeping the
+// previous ones
+func TestSetAllowedOriginsValidation(t *testing.T) {
+	policy := NewPolicy()
+	for _, origin := range []string{"example.com", "ftp://example.com", "https://example.com/app", "https://*", "https://a.*.example.com", "https://user@example.com"} {
+		if err := policy.SetAllowedOrigins([]string{origin}); err == nil {
+			t.Errorf("Expected origin %q to be rejected", origin)
+		}
+	}
+	if origins, source := policy.AllowedOrigins(); source != SourceDefault || len(origins) != 1 || origins[0] != AnyOrigin {
+		t.Errorf("Expected the default origins to be kept, got %v (%s)", origins, source)
+	}
+
+	if err := policy.SetAllowedOrigins(nil); err != nil {
+		t.Fatalf("Failed to clear the allowed origins: %v", err)
+	}
+	if policy.Allowed("https://app.example.com") {
+		t.Errorf("Expected no origin to be allowed")
+	}
+}
+
+// TestAllowedOriginsFromEnv tests the parsing of CORS_ALLOWED_ORIGINS
+func TestAllowedOriginsFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://app.example.com, ,https://*.example.org ")
+	origins, source := AllowedOriginsFromEnv()
+	if source != SourceEnv || len(origins) != 2 || origins[1] != "https://*.example.org" {
+		t.Errorf("Unexpected origins: %v (%s)", origins, source)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "not an origin")
+	origins, source = AllowedOriginsFromEnv()
+	if source != SourceDefault || len(origins) != 1 || origins[0] != AnyOrigin {
+		t.Errorf("Expected every origin for an invalid value, got %v (%s)", origins, source)
+	}
+}
+
+// TestCheckOrigin tests the origin check of WebSocket upgrades
+func TestCheckOrigin(t *testing.T) {
+	policy := NewPolicy()
+	if err := policy.SetAllowedOrigins([]string{"https://app.example.com"}); err != nil {
+		t.Fatalf("Failed to set the allowed origins: %v", err)
+	}
+
+	tests := []struct {
+		origin   string
+		expected bool
+	}{
+		{"", true},
+		{"http://sandbox.internal:8080", true},
+		{"https://app.example.com", true},
+		{"https://evil.com", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://sandbox.internal:8080/ws", nil)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		if allowed := policy.CheckOrigin(req); allowed != test.expected {
+			t.Errorf("CheckOrigin with origin %q = %v, expected %v", test.origin, allowed, test.expected)
+		}
+	}
+}
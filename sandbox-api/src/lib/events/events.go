@@ -0,0 +1,107 @@
+// Package events is the bus sandbox lifecycle events are published to, like processes
+// starting and exiting, ports opening or snapshots being created, for subscribers
+// such as webhooks to be notified of them.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of events
+const (
+	ProcessStarted    = "process.started"
+	ProcessExited     = "process.exited"
+	ProcessRestarted  = "process.restarted"
+	PortOpened        = "port.opened"
+	FilesystemChanged = "filesystem.changed"
+	SnapshotCreated   = "snapshot.created"
+	SandboxIdle       = "sandbox.idle"
+	SandboxActive     = "sandbox.active"
+)
+
+// Types lists the types of events
+var Types = []string{ProcessStarted, ProcessExited, ProcessRestarted, PortOpened, FilesystemChanged, SnapshotCreated, SandboxIdle, SandboxActive}
+
+// IsType reports whether t is a type of events
+func IsType(t string) bool {
+	for _, eventType := range Types {
+		if eventType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a sandbox lifecycle event
+type Event struct {
+	ID        string    `json:"id" example:"5f0c6d1e-8a4b-4b8e-9a1f-2c3d4e5f6a7b" binding:"required"`
+	Type      string    `json:"type" example:"process.exited" enums:"process.started,process.exited,process.restarted,port.opened,filesystem.changed,snapshot.created,sandbox.idle,sandbox.active" binding:"required"`
+	Timestamp time.Time `json:"timestamp" binding:"required"`
+	// Subject is what the event is about: the PID of a process, the port opened, the
+	// watched directory, the ID of a snapshot or the name of the sandbox
+	Subject string `json:"subject" example:"1234" binding:"required"`
+	Data    any    `json:"data"`
+} // @name Event
+
+// Bus calls its subscribers with the published events
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(event Event)
+	nextID      int
+}
+
+// Global bus instance
+var (
+	bus     *Bus
+	busOnce sync.Once
+)
+
+// GetBus returns the bus events are published to
+func GetBus() *Bus {
+	busOnce.Do(func() {
+		bus = NewBus()
+	})
+	return bus
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]func(event Event))}
+}
+
+// Publish publishes an event to the subscribers of the bus
+func Publish(eventType string, subject string, data any) Event {
+	return GetBus().Publish(eventType, subject, data)
+}
+
+// Publish calls the subscribers with an event, in the goroutine of the caller.
+// Subscribers must not block.
+func (b *Bus) Publish(eventType string, subject string, data any) Event {
+	event := Event{ID: uuid.New().String(), Type: eventType, Timestamp: time.Now(), Subject: subject, Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, subscriber := range b.subscribers {
+		subscriber(event)
+	}
+	return event
+}
+
+// Subscribe registers a subscriber called with every event, until the returned
+// function is called
+func (b *Bus) Subscribe(subscriber func(event Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subscribers[id] = subscriber
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
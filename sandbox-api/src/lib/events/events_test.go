@@ -0,0 +1,23 @@
+package events
+
+import "testing"
+
+// TestBusSubscribe tests that subscribers get the published events until they unsubscribe
+func TestBusSubscribe(t *testing.T) {
+	bus := NewBus()
+	var received []Event
+	unsubscribe := bus.Subscribe(func(event Event) {
+		received = append(received, event)
+	})
+
+	published := bus.Publish(ProcessStarted, "42", nil)
+	unsubscribe()
+	bus.Publish(ProcessExited, "42", nil)
+
+	if len(received) != 1 || received[0].ID != published.ID || received[0].Subject != "42" {
+		t.Fatalf("Expected only the event published while subscribed, got %+v", received)
+	}
+	if !IsType(SnapshotCreated) || IsType("process.paused") {
+		t.Error("Unexpected event types")
+	}
+}
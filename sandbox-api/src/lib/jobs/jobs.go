@@ -0,0 +1,382 @@
+// Package jobs runs long operations in the background, so that the requests starting
+// them return at once with the id of a job. Jobs report their progress while they run,
+// can be cancelled, and are kept for a while once finished for their result to be read.
+package jobs
+
+import (
+	"context"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Statuses of a job
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Types of the jobs started by the API
+const (
+	TypeArchive         = "archive"
+	TypeSync            = "sync"
+	TypeExport          = "export"
+	TypeIndexRebuild    = "index.rebuild"
+	TypeSnapshotCreate  = "snapshot.create"
+	TypeSnapshotRestore = "snapshot.restore"
+	TypePackagesInstall = "packages.install"
+)
+
+// maxFinishedJobs is the number of finished jobs kept, the oldest ones being forgotten
+// along with their output
+const maxFinishedJobs = 100
+
+var (
+	// ErrJobNotFound is returned for an unknown job id
+	ErrJobNotFound = apierror.New(apierror.CodeNotFound, "job not found")
+	// ErrJobFinished is returned when cancelling a job which is no longer running
+	ErrJobFinished = apierror.New(apierror.CodeConflict, "job is not running")
+	// ErrNoOutput is returned for the output of a job which has none, or not yet
+	ErrNoOutput = apierror.New(apierror.CodeNotFound, "job has no output")
+)
+
+// Job is a long operation running in the background
+type Job struct {
+	ID   string `json:"id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	Type string `json:"type" example:"archive" enums:"archive,sync,export,index.rebuild,snapshot.create,snapshot.restore,packages.install" binding:"required"`
+	// Subject is what the job works on, like the path of the archived directory
+	Subject string `json:"subject" example:"/home/user/app" binding:"required"`
+	Status  string `json:"status" example:"running" enums:"running,completed,failed,cancelled" binding:"required"`
+	// Progress is the estimated percentage of the work done
+	Progress float64 `json:"progress" example:"42.5" binding:"required"`
+	// Result is the result of the operation once completed, like the response of the
+	// synchronous request
+	Result any `json:"result,omitempty"`
+	// OutputName and OutputSize are the name and size of the file produced by the job,
+	// downloaded from GET /jobs/{id}/output
+	OutputName  string        `json:"outputName,omitempty" example:"app.tar.gz"`
+	OutputSize  int64         `json:"outputSize,omitempty" example:"1048576"`
+	Error       string        `json:"error,omitempty" example:"open /home/user/app: no such file or directory"`
+	Code        apierror.Code `json:"code,omitempty" example:"FS_NOT_FOUND"`
+	CreatedAt   time.Time     `json:"createdAt" binding:"required"`
+	CompletedAt *time.Time    `json:"completedAt,omitempty"`
+} // @name Job
+
+// Func is the operation of a job. It stops early when ctx is cancelled, and reports its
+// progress and output with reporter.
+type Func func(ctx context.Context, reporter *Reporter) (any, error)
+
+// Manager runs the jobs and keeps their status
+type Manager struct {
+	mu        sync.RWMutex
+	jobs      map[string]*job
+	listeners map[int]func(Job)
+	nextID    int
+}
+
+// Global manager instance
+var (
+	manager     *Manager
+	managerOnce sync.Once
+)
+
+// GetManager returns the manager of the jobs of the API
+func GetManager() *Manager {
+	managerOnce.Do(func() {
+		manager = NewManager()
+	})
+	return manager
+}
+
+// NewManager creates a manager without jobs
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*job), listeners: make(map[int]func(Job))}
+}
+
+// Start runs fn in the background as a job of a type, and returns the job, running
+func (m *Manager) Start(jobType string, subject string, fn Func) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		job:    Job{ID: uuid.New().String(), Type: jobType, Subject: subject, Status: StatusRunning, CreatedAt: time.Now()},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	j.reporter = &Reporter{job: j, notify: m.notify}
+
+	m.mu.Lock()
+	m.jobs[j.job.ID] = j
+	m.mu.Unlock()
+	m.forgetFinished()
+
+	started := j.status()
+	m.notify(started)
+	go m.run(ctx, j, fn)
+	return started
+}
+
+// run runs the operation of a job and records its outcome
+func (m *Manager) run(ctx context.Context, j *job, fn Func) {
+	defer j.cancel()
+	result, err := fn(ctx, j.reporter)
+
+	j.mu.Lock()
+	now := time.Now()
+	j.job.CompletedAt = &now
+	switch {
+	case err != nil && ctx.Err() != nil:
+		j.job.Status = StatusCancelled
+	case err != nil:
+		j.job.Status = StatusFailed
+		j.job.Error = err.Error()
+		if apiErr := apierror.From(err); apiErr != nil {
+			j.job.Code = apiErr.Code
+		}
+	default:
+		j.job.Status = StatusCompleted
+		j.job.Progress = 100
+		j.job.Result = result
+	}
+	j.mu.Unlock()
+	if err != nil {
+		j.removeOutput()
+	}
+	close(j.done)
+
+	if err != nil && ctx.Err() == nil {
+		logrus.Warnf("Job %s (%s %s) failed: %v", j.job.ID, j.job.Type, j.job.Subject, err)
+	}
+	m.notify(j.status())
+}
+
+// List returns the jobs of a type, every job when jobType is empty, oldest first
+func (m *Manager) List(jobType string) []Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if jobType == "" || j.job.Type == jobType {
+			jobs = append(jobs, j.status())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Get returns a job
+func (m *Manager) Get(id string) (Job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return j.status(), nil
+}
+
+// Cancel cancels a running job and returns it once its operation has stopped
+func (m *Manager) Cancel(id string) (Job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if j.status().Status != StatusRunning {
+		return Job{}, ErrJobFinished
+	}
+	j.cancel()
+	<-j.done
+	return j.status(), nil
+}
+
+// Wait waits for a job to finish and returns it, or fails when ctx is done first
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	select {
+	case <-j.done:
+		return j.status(), nil
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+}
+
+// Output returns the path of the file produced by a completed job
+func (m *Manager) Output(id string) (string, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return "", err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.job.Status != StatusCompleted || j.output == "" {
+		return "", ErrNoOutput
+	}
+	return j.output, nil
+}
+
+// Subscribe calls fn with every job when it starts, makes progress and finishes, until
+// the returned function is called. fn is called by the goroutine of the job, which it
+// holds up until it returns.
+func (m *Manager) Subscribe(fn func(Job)) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextID
+	m.nextID++
+	m.listeners[id] = fn
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.listeners, id)
+	}
+}
+
+func (m *Manager) notify(job Job) {
+	m.mu.RLock()
+	listeners := make([]func(Job), 0, len(m.listeners))
+	for _, fn := range m.listeners {
+		listeners = append(listeners, fn)
+	}
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(job)
+	}
+}
+
+func (m *Manager) get(id string) (*job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	j, exists := m.jobs[id]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	return j, nil
+}
+
+// forgetFinished forgets the oldest finished jobs beyond maxFinishedJobs and removes
+// their output
+func (m *Manager) forgetFinished() {
+	m.mu.Lock()
+	var finished []*job
+	for _, j := range m.jobs {
+		if j.status().Status != StatusRunning {
+			finished = append(finished, j)
+		}
+	}
+	sort.Slice(finished, func(i, k int) bool {
+		return finished[i].job.CreatedAt.Before(finished[k].job.CreatedAt)
+	})
+	var forgotten []*job
+	if len(finished) > maxFinishedJobs {
+		forgotten = finished[:len(finished)-maxFinishedJobs]
+	}
+	for _, j := range forgotten {
+		delete(m.jobs, j.job.ID)
+	}
+	m.mu.Unlock()
+
+	for _, j := range forgotten {
+		j.removeOutput()
+	}
+}
+
+// job is a job and the means to stop it
+type job struct {
+	mu       sync.Mutex
+	job      Job
+	output   string
+	cancel   context.CancelFunc
+	done     chan struct{}
+	reporter *Reporter
+}
+
+func (j *job) status() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.job
+}
+
+func (j *job) removeOutput() {
+	j.mu.Lock()
+	output := j.output
+	j.mu.Unlock()
+	if output != "" {
+		_ = os.Remove(output)
+	}
+	j.mu.Lock()
+	j.output = ""
+	j.job.OutputName = ""
+	j.job.OutputSize = 0
+	j.mu.Unlock()
+}
+
+// Reporter reports the progress and output of a job
+type Reporter struct {
+	job    *job
+	notify func(Job)
+}
+
+// Progress sets the progress of the job to done out of total units of work. The
+// subscribers are notified when the progress gains a percent.
+func (r *Reporter) Progress(done int64, total int64) {
+	if r == nil || total <= 0 {
+		return
+	}
+	progress := min(100, float64(done)*100/float64(total))
+
+	r.job.mu.Lock()
+	if r.job.job.Status != StatusRunning {
+		r.job.mu.Unlock()
+		return
+	}
+	previous := r.job.job.Progress
+	r.job.job.Progress = progress
+	job := r.job.job
+	r.job.mu.Unlock()
+
+	if int(progress) != int(previous) {
+		r.notify(job)
+	}
+}
+
+// SetOutput sets the file produced by the job, downloaded as name. The file is removed
+// when the job fails or is forgotten.
+func (r *Reporter) SetOutput(path string, name string, size int64) {
+	if r == nil {
+		return
+	}
+	r.job.mu.Lock()
+	defer r.job.mu.Unlock()
+	r.job.output = path
+	r.job.job.OutputName = name
+	r.job.job.OutputSize = size
+}
+
+// contextWriter fails the writes once its context is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *contextWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+// Writer returns a writer to w failing once ctx is done, so that the operations writing
+// their output stop when their job is cancelled
+func Writer(ctx context.Context, w io.Writer) io.Writer {
+	return &contextWriter{ctx: ctx, w: w}
+}
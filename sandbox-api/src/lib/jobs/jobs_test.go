@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to wait for job %s: %v", id, err)
+	}
+	return job
+}
+
+// TestJobCompletes tests that a job records its result and output once completed
+func TestJobCompletes(t *testing.T) {
+	m := NewManager()
+	output := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(output, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := m.Start(TypeArchive, "/app", func(ctx context.Context, reporter *Reporter) (any, error) {
+		reporter.Progress(1, 2)
+		reporter.SetOutput(output, "app.tar.gz", 7)
+		return "done", nil
+	})
+	if job.Status != StatusRunning || job.Type != TypeArchive || job.Subject != "/app" {
+		t.Errorf("Unexpected started job %+v", job)
+	}
+
+	job = wait(t, m, job.ID)
+	if job.Status != StatusCompleted || job.Progress != 100 || job.Result != "done" || job.CompletedAt == nil {
+		t.Errorf("Unexpected completed job %+v", job)
+	}
+	if job.OutputName != "app.tar.gz" || job.OutputSize != 7 {
+		t.Errorf("Unexpected output %s (%d bytes)", job.OutputName, job.OutputSize)
+	}
+	if path, err := m.Output(job.ID); err != nil || path != output {
+		t.Errorf("Expected output %s, got %s (%v)", output, path, err)
+	}
+	if jobs := m.List(TypeSync); len(jobs) != 0 {
+		t.Errorf("Expected no sync job, got %v", jobs)
+	}
+	if jobs := m.List(""); len(jobs) != 1 {
+		t.Errorf("Expected one job, got %v", jobs)
+	}
+}
+
+// TestJobFails tests that a failed job records its error and removes its output
+func TestJobFails(t *testing.T) {
+	m := NewManager()
+	output := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(output, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := m.Start(TypeArchive, "/missing", func(ctx context.Context, reporter *Reporter) (any, error) {
+		reporter.SetOutput(output, "missing.tar.gz", 7)
+		return nil, &os.PathError{Op: "open", Path: "/missing", Err: os.ErrNotExist}
+	})
+	job = wait(t, m, job.ID)
+	if job.Status != StatusFailed || job.Code != apierror.CodeFSNotFound || job.Error == "" {
+		t.Errorf("Unexpected failed job %+v", job)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected the output to be removed, got %v", err)
+	}
+	if _, err := m.Output(job.ID); !errors.Is(err, ErrNoOutput) {
+		t.Errorf("Expected no output, got %v", err)
+	}
+}
+
+// TestJobCancel tests that cancelling a job stops its operation
+func TestJobCancel(t *testing.T) {
+	m := NewManager()
+	started := make(chan struct{})
+	job := m.Start(TypeSync, "/app", func(ctx context.Context, reporter *Reporter) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	job, err := m.Cancel(job.ID)
+	if err != nil || job.Status != StatusCancelled || job.Error != "" {
+		t.Errorf("Unexpected cancelled job %+v (%v)", job, err)
+	}
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Expected cancelling a finished job to fail, got %v", err)
+	}
+	if _, err := m.Cancel("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected an unknown job not to be found, got %v", err)
+	}
+}
+
+// TestSubscribe tests that the subscribers are notified of the start, progress and end
+// of the jobs
+func TestSubscribe(t *testing.T) {
+	m := NewManager()
+	var mu sync.Mutex
+	var events []Job
+	unsubscribe := m.Subscribe(func(job Job) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, job)
+	})
+
+	job := m.Start(TypeIndexRebuild, "/", func(ctx context.Context, reporter *Reporter) (any, error) {
+		for i := int64(0); i <= 1000; i++ {
+			reporter.Progress(i, 1000)
+		}
+		return nil, nil
+	})
+	wait(t, m, job.ID)
+	unsubscribe()
+	m.Start(TypeIndexRebuild, "/", func(ctx context.Context, reporter *Reporter) (any, error) {
+		return nil, nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Started, each percent and completed
+	if len(events) != 102 {
+		t.Fatalf("Expected 102 events, got %d", len(events))
+	}
+	if events[0].Status != StatusRunning || events[50].Progress != 50 || events[101].Status != StatusCompleted {
+		t.Errorf("Unexpected events %+v, %+v, %+v", events[0], events[50], events[101])
+	}
+}
+
+// TestWriter tests that the writes fail once the context is cancelled
+func TestWriter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var written []byte
+	w := Writer(ctx, writerFunc(func(p []byte) (int, error) {
+		written = append(written, p...)
+		return len(p), nil
+	}))
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := w.Write([]byte("b")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the write to fail, got %v", err)
+	}
+	if string(written) != "a" {
+		t.Errorf("Unexpected content %q", written)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ResourceLimits are the resources available to the sandbox
+type ResourceLimits struct {
+	// CPUs is the number of CPUs of the machine
+	CPUs int `json:"cpus" example:"4" binding:"required"`
+	// CPULimit is the number of CPUs the cgroup of the sandbox may use, when limited
+	CPULimit float64 `json:"cpuLimit,omitempty" example:"2"`
+	// MemoryBytes is the memory the cgroup of the sandbox may use, when limited
+	MemoryBytes uint64 `json:"memoryBytes,omitempty" example:"4294967296"`
+	// OpenFiles is the maximum number of files a process may open
+	OpenFiles uint64 `json:"openFiles,omitempty" example:"1048576"`
+} // @name ResourceLimits
+
+// cgroupDir is the directory of the cgroup of the API, mounted at the root of the cgroup
+// filesystem in containers
+const cgroupDir = "/sys/fs/cgroup"
+
+// ReadResourceLimits returns the resources available to the sandbox, from the cgroup of
+// the API (v2, else v1) and its limit of open files
+func ReadResourceLimits() ResourceLimits {
+	limits := ResourceLimits{CPUs: runtime.NumCPU()}
+
+	if data, err := os.ReadFile(cgroupDir + "/cpu.max"); err == nil {
+		limits.CPULimit = parseCPUMax(string(data))
+	} else {
+		quota, errQuota := os.ReadFile(cgroupDir + "/cpu/cpu.cfs_quota_us")
+		period, errPeriod := os.ReadFile(cgroupDir + "/cpu/cpu.cfs_period_us")
+		if errQuota == nil && errPeriod == nil {
+			limits.CPULimit = parseCPUMax(strings.TrimSpace(string(quota)) + " " + strings.TrimSpace(string(period)))
+		}
+	}
+
+	if data, err := os.ReadFile(cgroupDir + "/memory.max"); err == nil {
+		limits.MemoryBytes = parseMemoryMax(string(data))
+	} else if data, err := os.ReadFile(cgroupDir + "/memory/memory.limit_in_bytes"); err == nil {
+		limits.MemoryBytes = parseMemoryMax(string(data))
+	}
+
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err == nil {
+		limits.OpenFiles = uint64(rlimit.Cur)
+	}
+	return limits
+}
+
+// parseCPUMax returns the CPUs of a cgroup v2 cpu.max ("<quota> <period>"), 0 when
+// unlimited. A negative quota is the cgroup v1 way of being unlimited.
+func parseCPUMax(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, errQuota := strconv.ParseFloat(fields[0], 64)
+	period, errPeriod := strconv.ParseFloat(fields[1], 64)
+	if errQuota != nil || errPeriod != nil || quota <= 0 || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
+
+// parseMemoryMax returns the bytes of a cgroup memory limit, 0 when unlimited. Cgroup
+// v1 has no "max" and reports a huge page-aligned value instead.
+func parseMemoryMax(value string) uint64 {
+	value = strings.TrimSpace(value)
+	if value == "max" {
+		return 0
+	}
+	bytes, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || bytes >= 1<<62 {
+		return 0
+	}
+	return bytes
+}
@@ -0,0 +1,33 @@
+package lib
+
+import "testing"
+
+// TestParseCgroupLimits tests parsing the CPU and memory limits of cgroups v1 and v2
+func TestParseCgroupLimits(t *testing.T) {
+	for value, want := range map[string]float64{
+		"200000 100000\n": 2,
+		"50000 100000":    0.5,
+		"max 100000\n":    0,
+		"-1 100000":       0,
+		"":                0,
+	} {
+		if got := parseCPUMax(value); got != want {
+			t.Errorf("Expected %q to be %v CPUs, got %v", value, want, got)
+		}
+	}
+
+	for value, want := range map[string]uint64{
+		"4294967296\n":          4294967296,
+		"max\n":                 0,
+		"9223372036854771712\n": 0,
+		"invalid":               0,
+	} {
+		if got := parseMemoryMax(value); got != want {
+			t.Errorf("Expected %q to be %d bytes, got %d", value, want, got)
+		}
+	}
+
+	if limits := ReadResourceLimits(); limits.CPUs < 1 {
+		t.Errorf("Expected at least one CPU, got %+v", limits)
+	}
+}
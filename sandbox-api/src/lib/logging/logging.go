@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, returned in every
+// response so that client logs can be correlated to the logs of the API
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length above which request IDs sent by clients are replaced
+const maxRequestIDLength = 128
+
+// Fields of the log entries correlating them to an operation
+const (
+	FieldRequestID       = "requestId"
+	FieldWSMessageID     = "wsMessageId"
+	FieldMCPInvocationID = "mcpInvocationId"
+	FieldProcessPID      = "pid"
+)
+
+// fieldsKey is the context key of the log fields of an operation
+type fieldsKey struct{}
+
+// Setup configures the standard logger. Entries are written as JSON objects, or as
+// plain text when LOG_FORMAT is set to text.
+func Setup() {
+	switch format := os.Getenv("LOG_FORMAT"); format {
+	case "", "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{
+			DisableColors: true,
+		})
+	default:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+		logrus.Warnf("Invalid LOG_FORMAT value '%s', using json", format)
+	}
+	logrus.SetLevel(logrus.DebugLevel)
+}
+
+// NewID returns a new random ID for a request or an invocation
+func NewID() string {
+	return uuid.NewString()
+}
+
+// ValidRequestID reports whether an ID sent by a client can be used as a request ID
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// WithField returns a copy of ctx whose log entries have the field set to value
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	parent, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	fields := make(logrus.Fields, len(parent)+1)
+	for k, v := range parent {
+		fields[k] = v
+	}
+	fields[key] = value
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// WithRequestID returns a copy of ctx whose log entries have the request ID set
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return WithField(ctx, FieldRequestID, id)
+}
+
+// RequestID returns the ID of the request of ctx, if any
+func RequestID(ctx context.Context) string {
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	id, _ := fields[FieldRequestID].(string)
+	return id
+}
+
+// FromContext returns a log entry with the fields of ctx
+func FromContext(ctx context.Context) *logrus.Entry {
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return logrus.WithFields(fields)
+}
+
+// ForProcess returns a log entry for a managed process
+func ForProcess(pid string) *logrus.Entry {
+	return logrus.WithField(FieldProcessPID, pid)
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestValidRequestID tests which request IDs sent by clients are kept
+func TestValidRequestID(t *testing.T) {
+	cases := map[string]bool{
+		"":                                      false,
+		"4bf92f35-77b3-4da6-a3ce-929d0e0e4736":  true,
+		"client-req_42":                         true,
+		"with space":                            false,
+		"line\nbreak":                           false,
+		strings.Repeat("a", maxRequestIDLength): true,
+		strings.Repeat("a", maxRequestIDLength+1): false,
+	}
+	for id, expected := range cases {
+		if got := ValidRequestID(id); got != expected {
+			t.Errorf("ValidRequestID(%q) = %v, expected %v", id, got, expected)
+		}
+	}
+}
+
+// TestContextFields tests that log fields are inherited without altering the parent
+func TestContextFields(t *testing.T) {
+	parent := WithRequestID(context.Background(), "req-1")
+	child := WithField(parent, FieldWSMessageID, "msg-1")
+
+	if id := RequestID(child); id != "req-1" {
+		t.Errorf("Expected request ID req-1, got %q", id)
+	}
+	if data := FromContext(parent).Data; len(data) != 1 {
+		t.Errorf("Expected the parent to keep only the request ID, got %v", data)
+	}
+	data := FromContext(child).Data
+	if data[FieldRequestID] != "req-1" || data[FieldWSMessageID] != "msg-1" {
+		t.Errorf("Expected the child to have both fields, got %v", data)
+	}
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("Expected no request ID, got %q", id)
+	}
+}
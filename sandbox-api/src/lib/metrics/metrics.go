@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds all the sandbox metrics exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RequestDuration tracks HTTP request latencies per route
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sandbox_http_request_duration_seconds",
+		Help:    "HTTP request latencies in seconds",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// ActiveWatchers tracks the number of open filesystem watch streams
+	ActiveWatchers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sandbox_filesystem_watchers",
+		Help: "Number of active filesystem watchers",
+	})
+
+	// WatchedDirectories reports the directories watched with inotify by filesystem
+	// watches, and the budget they share
+	WatchedDirectories = NewLabeledGaugeFunc("sandbox_filesystem_watched_directories", "Number of directories watched with inotify by filesystem watches, and their budget", "state")
+
+	// PolledDirectoryTrees reports the directory trees polled by filesystem watches
+	// beyond the inotify watch budget
+	PolledDirectoryTrees = NewLabeledGaugeFunc("sandbox_filesystem_polled_directory_trees", "Number of directory trees polled by filesystem watches beyond the inotify watch budget", "")
+
+	// ActiveLogStreams tracks the number of open process log streams
+	ActiveLogStreams = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sandbox_process_log_streams",
+		Help: "Number of active process log streams",
+	})
+
+	// ActiveWebSocketConnections tracks the number of open /ws connections
+	ActiveWebSocketConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sandbox_websocket_connections",
+		Help: "Number of active WebSocket connections",
+	})
+
+	// StreamLimits reports the number of log streams and filesystem watches which can
+	// be open at once
+	StreamLimits = NewLabeledGaugeFunc("sandbox_stream_limit", "Number of streams which can be open at once by kind, zero for no limit", "kind")
+
+	// Processes reports the number of managed processes by status
+	Processes = NewLabeledGaugeFunc("sandbox_processes", "Number of managed processes by status", "status")
+
+	// MultipartUploads reports the number of multipart uploads in flight
+	MultipartUploads = NewLabeledGaugeFunc("sandbox_multipart_uploads", "Number of multipart uploads in flight", "")
+)
+
+func init() {
+	Registry.MustRegister(
+		RequestDuration,
+		ActiveWatchers,
+		WatchedDirectories,
+		PolledDirectoryTrees,
+		ActiveLogStreams,
+		ActiveWebSocketConnections,
+		StreamLimits,
+		Processes,
+		MultipartUploads,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns the HTTP handler serving metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// LabeledGaugeFunc is a gauge whose values are computed at scrape time.
+// The source function returns one value per label value; when the gauge has no
+// label, the value stored under the empty key is reported.
+type LabeledGaugeFunc struct {
+	desc  *prometheus.Desc
+	label string
+	mu    sync.RWMutex
+	fn    func() map[string]float64
+}
+
+// NewLabeledGaugeFunc creates a gauge computed at scrape time, label may be empty
+func NewLabeledGaugeFunc(name, help, label string) *LabeledGaugeFunc {
+	var labels []string
+	if label != "" {
+		labels = []string{label}
+	}
+	return &LabeledGaugeFunc{
+		desc:  prometheus.NewDesc(name, help, labels, nil),
+		label: label,
+	}
+}
+
+// SetSource sets the function used to compute the gauge values
+func (g *LabeledGaugeFunc) SetSource(fn func() map[string]float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.fn = fn
+}
+
+// Describe implements prometheus.Collector
+func (g *LabeledGaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect implements prometheus.Collector
+func (g *LabeledGaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	g.mu.RLock()
+	fn := g.fn
+	g.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	for labelValue, value := range fn() {
+		if g.label == "" {
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value, labelValue)
+	}
+}
@@ -0,0 +1,402 @@
+// Package objectstore is a client of S3-compatible object storage: Amazon S3, Google
+// Cloud Storage through its XML API with HMAC keys, and the likes of MinIO or R2.
+// Requests are signed with AWS Signature Version 4.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of a client. Endpoint defaults to the endpoint of the
+// scheme of the location, and Region to us-east-1 for S3 and auto for GCS.
+type Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle addresses buckets as the first segment of the path rather than as a
+	// subdomain of the endpoint, as most S3-compatible services require
+	PathStyle bool
+}
+
+// Location is a bucket and the prefix of the keys of objects in it
+type Location struct {
+	Scheme string
+	Bucket string
+	Prefix string
+}
+
+// ParseLocation parses a location like s3://bucket/prefix or gs://bucket/prefix
+func ParseLocation(location string) (Location, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid location '%s': %w", location, err)
+	}
+	if u.Scheme != "s3" && u.Scheme != "gs" {
+		return Location{}, fmt.Errorf("invalid location '%s': the scheme must be s3 or gs", location)
+	}
+	if u.Host == "" {
+		return Location{}, fmt.Errorf("invalid location '%s': the bucket is missing", location)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return Location{Scheme: u.Scheme, Bucket: u.Host, Prefix: prefix}, nil
+}
+
+// String returns the location as a URL
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Prefix
+}
+
+// ConfigFromEnv returns the configuration of a client for a location, read from the
+// variables of prefix: <prefix>_ENDPOINT, <prefix>_REGION, <prefix>_ACCESS_KEY_ID and
+// <prefix>_SECRET_ACCESS_KEY. The credentials default to AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func ConfigFromEnv(prefix string, location Location) Config {
+	config := Config{
+		Endpoint:        os.Getenv(prefix + "_ENDPOINT"),
+		Region:          os.Getenv(prefix + "_REGION"),
+		AccessKeyID:     os.Getenv(prefix + "_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv(prefix + "_SECRET_ACCESS_KEY"),
+	}
+	if config.AccessKeyID == "" {
+		config.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		config.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		config.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_REGION")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+		if location.Scheme == "gs" {
+			config.Region = "auto"
+		}
+	}
+	switch {
+	case config.Endpoint != "":
+		// Custom endpoints are S3-compatible services, most of which only support paths
+		config.PathStyle = true
+	case location.Scheme == "gs":
+		config.Endpoint = "https://storage.googleapis.com"
+		config.PathStyle = true
+	default:
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	return config
+}
+
+// Object describes an object
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+}
+
+// Client is a client of the objects of a bucket
+type Client struct {
+	config Config
+	bucket string
+	http   *http.Client
+}
+
+// New returns a client of the objects of bucket
+func New(config Config, bucket string) *Client {
+	return &Client{config: config, bucket: bucket, http: http.DefaultClient}
+}
+
+// Bucket returns the bucket of the client
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
+// Get returns the content of an object. It fails with fs.ErrNotExist when the object
+// doesn't exist.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil, -1)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	return resp.Body, objectFromHeaders(key, resp.Header), nil
+}
+
+// Head returns the metadata of an object. It fails with fs.ErrNotExist when the object
+// doesn't exist.
+func (c *Client) Head(ctx context.Context, key string) (Object, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, -1)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+	return objectFromHeaders(key, resp.Header), nil
+}
+
+// Put writes an object of size bytes, read from body
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, body, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// partSize is the size of the parts of multipart uploads. S3 requires parts of at least
+// 5 MiB but the last one, and at most 10000 parts, which caps uploads at 80 GiB.
+const partSize = 8 << 20
+
+// initiateResult is the response of a CreateMultipartUpload request
+type initiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completedPart is a part of a CompleteMultipartUpload request
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// completeRequest is the body of a CompleteMultipartUpload request
+type completeRequest struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// Upload writes an object read from body, whose size doesn't need to be known: the
+// content is uploaded in parts as it is read, and with a single request when it fits
+// in a part. A failed multipart upload is aborted.
+func (c *Client) Upload(ctx context.Context, key string, body io.Reader) error {
+	part := make([]byte, partSize)
+	n, err := io.ReadFull(body, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return c.Put(ctx, key, bytes.NewReader(part[:n]), int64(n))
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, -1)
+	if err != nil {
+		return err
+	}
+	var initiated initiateResult
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("invalid multipart upload response: %v", err)
+	}
+
+	if err := c.uploadParts(ctx, key, initiated.UploadID, body, part); err != nil {
+		// The parts uploaded are kept, and billed, until the upload is aborted
+		if resp, abortErr := c.do(context.WithoutCancel(ctx), http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, -1); abortErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts uploads first then the rest of body as the parts of a multipart upload,
+// and completes it
+func (c *Client) uploadParts(ctx context.Context, key string, uploadID string, body io.Reader, first []byte) error {
+	var parts []completedPart
+	part := first
+	for number := 1; ; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := c.do(ctx, http.MethodPut, key, query, bytes.NewReader(part), int64(len(part)))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		n, err := io.ReadFull(body, first)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		part = first[:n]
+	}
+
+	complete, err := xml.Marshal(completeRequest{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(complete), int64(len(complete)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Completions can fail after a 200 status, with an error in the body
+	var failure errorResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&failure); err == nil && failure.Code != "" {
+		return fmt.Errorf("POST %s: %s", c.bucket+"/"+key, failure.Message)
+	}
+	return nil
+}
+
+// Delete deletes an object. Deleting an object which doesn't exist succeeds.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, -1)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the response of a ListObjectsV2 request
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List lists the objects whose key starts with prefix. With a delimiter, the keys
+// containing it after the prefix are grouped into the returned common prefixes. At most
+// limit objects and prefixes are listed, all of them when limit is 0.
+func (c *Client) List(ctx context.Context, prefix string, delimiter string, limit int) ([]Object, []string, error) {
+	var objects []Object
+	var prefixes []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if limit > 0 {
+			query.Set("max-keys", strconv.Itoa(limit-len(objects)-len(prefixes)))
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, "", query, nil, -1)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid list response: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, LastModified: content.LastModified, ETag: content.ETag})
+		}
+		for _, common := range result.CommonPrefixes {
+			prefixes = append(prefixes, common.Prefix)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" || (limit > 0 && len(objects)+len(prefixes) >= limit) {
+			return objects, prefixes, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends a request for an object, or for the bucket when key is empty. The body of a
+// successful response is to be closed by the caller.
+func (c *Client) do(ctx context.Context, method string, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query), body)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		// The payload of uploads is streamed rather than hashed beforehand
+		payloadHash = unsignedPayload
+	}
+	c.sign(req, payloadHash, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var failure errorResponse
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	message := failure.Message
+	if message == "" {
+		message = resp.Status
+	}
+	err = fmt.Errorf("%s %s: %s", method, c.bucket+"/"+key, message)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %w", fs.ErrPermission, err)
+	default:
+		return nil, err
+	}
+}
+
+// objectURL returns the URL of an object, or of the bucket when key is empty
+func (c *Client) objectURL(key string, query url.Values) string {
+	endpoint := strings.TrimSuffix(c.config.Endpoint, "/")
+	var u string
+	if c.config.PathStyle {
+		u = endpoint + "/" + uriEncode(c.bucket, false) + "/" + uriEncode(key, true)
+	} else {
+		scheme, host, _ := strings.Cut(endpoint, "://")
+		u = scheme + "://" + c.bucket + "." + host + "/" + uriEncode(key, true)
+	}
+	if len(query) > 0 {
+		u += "?" + canonicalQuery(query)
+	}
+	return u
+}
+
+// objectFromHeaders returns the metadata of an object from the headers of a response
+func objectFromHeaders(key string, header http.Header) Object {
+	object := Object{Key: key, ETag: header.Get("ETag")}
+	object.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	object.LastModified, _ = http.ParseTime(header.Get("Last-Modified"))
+	return object
+}
+
+// IsNotExist reports whether err is the failure of a request for a missing object
+func IsNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,185 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RunAs is the user, and optionally the group, processes run as and written files are
+// owned by. Both accept a name or a numeric id. Without a group, the primary group of
+// the user is used.
+type RunAs struct {
+	User  string
+	Group string
+}
+
+// Credential is a RunAs resolved against the user database
+type Credential struct {
+	UID      uint32
+	GID      uint32
+	Groups   []uint32
+	Username string
+	Home     string
+}
+
+// ParseRunAs parses a "user[:group]" specification, as used by the RUN_AS environment
+// variable and the X-Run-As header
+func ParseRunAs(spec string) RunAs {
+	userName, group, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	return RunAs{User: strings.TrimSpace(userName), Group: strings.TrimSpace(group)}
+}
+
+// DefaultRunAs returns the user processes run as and written files are owned by when
+// none is given, read from RUN_AS. It is empty, meaning the user of the server, by default.
+func DefaultRunAs() RunAs {
+	return ParseRunAs(os.Getenv("RUN_AS"))
+}
+
+// IsZero returns true when no user nor group is set
+func (r RunAs) IsZero() bool {
+	return r.User == "" && r.Group == ""
+}
+
+// OrDefault returns the RunAs, or the default one when empty
+func (r RunAs) OrDefault() RunAs {
+	if r.IsZero() {
+		return DefaultRunAs()
+	}
+	return r
+}
+
+// String returns the "user[:group]" specification of the RunAs
+func (r RunAs) String() string {
+	if r.Group == "" {
+		return r.User
+	}
+	return r.User + ":" + r.Group
+}
+
+// Resolve looks up the user and group ids. It returns nil when the RunAs is empty. A
+// numeric user missing from the user database is allowed, with the same group id and
+// no supplementary groups unless a group is given.
+func (r RunAs) Resolve() (*Credential, error) {
+	if r.IsZero() {
+		return nil, nil
+	}
+
+	credential := &Credential{}
+	if r.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("could not look up the current user: %w", err)
+		}
+		if err := credential.setUser(current); err != nil {
+			return nil, err
+		}
+	} else if u, err := lookupUser(r.User); err == nil {
+		if err := credential.setUser(u); err != nil {
+			return nil, err
+		}
+	} else if uid, parseErr := strconv.ParseUint(r.User, 10, 32); parseErr == nil {
+		credential.UID, credential.GID = uint32(uid), uint32(uid)
+		credential.Username = r.User
+		credential.Home = "/"
+	} else {
+		return nil, err
+	}
+
+	if r.Group != "" {
+		gid, err := lookupGroup(r.Group)
+		if err != nil {
+			return nil, err
+		}
+		credential.GID = gid
+		if r.User == "" {
+			// Only the group changes, do not keep the groups of the server user
+			credential.Groups = nil
+		}
+	}
+	return credential, nil
+}
+
+// setUser fills the credential from a user of the user database
+func (c *Credential) setUser(u *user.User) error {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid uid '%s' for user '%s'", u.Uid, u.Username)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid gid '%s' for user '%s'", u.Gid, u.Username)
+	}
+	c.UID, c.GID = uint32(uid), uint32(gid)
+	c.Username, c.Home = u.Username, u.HomeDir
+
+	// Supplementary groups are best effort, some user databases cannot list them
+	groupIds, err := u.GroupIds()
+	if err != nil {
+		return nil
+	}
+	for _, id := range groupIds {
+		if groupId, err := strconv.ParseUint(id, 10, 32); err == nil {
+			c.Groups = append(c.Groups, uint32(groupId))
+		}
+	}
+	return nil
+}
+
+// Env returns the HOME, USER and LOGNAME variables of the user, to set on processes
+// running as it
+func (c *Credential) Env() map[string]string {
+	env := map[string]string{"USER": c.Username, "LOGNAME": c.Username}
+	if c.Home != "" {
+		env["HOME"] = c.Home
+	}
+	return env
+}
+
+// Chown gives a path to the user and group, without following symlinks
+func (c *Credential) Chown(path string) error {
+	return os.Lchown(path, int(c.UID), int(c.GID))
+}
+
+// ChownAll gives a path and everything under it to the user and group
+func (c *Credential) ChownAll(path string) error {
+	return filepath.Walk(path, func(walkPath string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return c.Chown(walkPath)
+	})
+}
+
+// lookupUser looks up a user by name, then by id
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, parseErr := strconv.ParseUint(name, 10, 32); parseErr == nil {
+		if u, idErr := user.LookupId(name); idErr == nil {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown user '%s'", name)
+}
+
+// lookupGroup returns the id of a group given by name or id
+func lookupGroup(name string) (uint32, error) {
+	if g, err := user.LookupGroup(name); err == nil {
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid gid '%s' for group '%s'", g.Gid, name)
+		}
+		return uint32(gid), nil
+	}
+	gid, err := strconv.ParseUint(name, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown group '%s'", name)
+	}
+	return uint32(gid), nil
+}
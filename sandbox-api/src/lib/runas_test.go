@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestRunAs tests parsing and resolving the user processes run as
+func TestRunAs(t *testing.T) {
+	if runAs := ParseRunAs(" app : staff "); runAs.User != "app" || runAs.Group != "staff" || runAs.String() != "app:staff" {
+		t.Errorf("Unexpected run as %+v", runAs)
+	}
+	if credential, err := ParseRunAs("").Resolve(); credential != nil || err != nil {
+		t.Errorf("Expected no credential for an empty run as, got %+v (%v)", credential, err)
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skip("Current user unavailable")
+	}
+	credential, err := RunAs{User: current.Username}.Resolve()
+	if err != nil || credential.Username != current.Username || credential.Home != current.HomeDir {
+		t.Fatalf("Unexpected credential %+v for %s (%v)", credential, current.Username, err)
+	}
+	if env := credential.Env(); env["USER"] != current.Username || env["HOME"] != current.HomeDir {
+		t.Errorf("Unexpected environment %v", env)
+	}
+
+	// Numeric ids need not exist in the user database
+	credential, err = RunAs{User: "424242", Group: "434343"}.Resolve()
+	if err != nil || credential.UID != 424242 || credential.GID != 434343 || len(credential.Groups) != 0 {
+		t.Errorf("Unexpected numeric credential %+v (%v)", credential, err)
+	}
+
+	for _, runAs := range []RunAs{{User: "no-such-user-x"}, {User: current.Username, Group: "no-such-group-x"}} {
+		if _, err := runAs.Resolve(); err == nil {
+			t.Errorf("Expected an error resolving %s", runAs)
+		}
+	}
+}
+
+// TestCredentialChownAll tests giving a tree to a user
+func TestCredentialChownAll(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing owners requires root")
+	}
+	dir := filepath.Join(t.TempDir(), "tree")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := RunAs{User: "424242", Group: "434343"}.Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := credential.ChownAll(dir); err != nil {
+		t.Fatalf("Failed to change owners: %v", err)
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "sub"), filepath.Join(dir, "sub", "file")} {
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat := info.Sys().(*syscall.Stat_t)
+		if stat.Uid != 424242 || stat.Gid != 434343 {
+			t.Errorf("Expected %s to be owned by 424242:434343, got %d:%d", path, stat.Uid, stat.Gid)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package lib
+
+import "os"
+
+// SandboxName returns the name of the sandbox, read from BL_NAME and defaulting to the
+// hostname
+func SandboxName() string {
+	if name := os.Getenv("BL_NAME"); name != "" {
+		return name
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSocketMode is the permissions of the unix socket of the API without SOCKET_MODE:
+// the user of the API and its group may connect
+const DefaultSocketMode os.FileMode = 0660
+
+// SocketModeFromEnv returns the permissions of the unix socket of the API, read in octal
+// from SOCKET_MODE
+func SocketModeFromEnv() os.FileMode {
+	value := os.Getenv("SOCKET_MODE")
+	if value == "" {
+		return DefaultSocketMode
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		logrus.Warnf("Invalid SOCKET_MODE value '%s', using default of %o", value, DefaultSocketMode)
+		return DefaultSocketMode
+	}
+	return os.FileMode(mode)
+}
+
+// ListenUnix listens on a unix socket with the given permissions, creating its
+// directory. The socket left by a previous run is removed, unless something still
+// listens on it.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
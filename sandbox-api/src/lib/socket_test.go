@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestListenUnix tests listening on a unix socket, replacing the one left by a
+// previous run but not one still in use
+func TestListenUnix(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, more than some temporary directories
+	dir, err := os.MkdirTemp("", "sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "run", "api.sock")
+
+	listener, err := ListenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected a socket with mode 0600, got %v (%v)", info, err)
+	}
+	go func(listener net.Listener) {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+		}
+	}(listener)
+	if _, err := ListenUnix(path, 0600); err == nil {
+		t.Fatalf("Expected a socket in use not to be replaced")
+	}
+
+	// Leave the socket file behind like a crashed run
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	replaced, err := ListenUnix(path, 0660)
+	if err != nil {
+		t.Fatalf("Failed to replace the stale socket: %v", err)
+	}
+	replaced.Close()
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(file, 0600); err == nil {
+		t.Errorf("Expected a regular file not to be replaced")
+	}
+}
+
+// TestSocketModeFromEnv tests parsing SOCKET_MODE in octal
+func TestSocketModeFromEnv(t *testing.T) {
+	for value, want := range map[string]os.FileMode{"": DefaultSocketMode, "600": 0600, "0666": 0666, "abc": DefaultSocketMode, "1777": DefaultSocketMode} {
+		t.Setenv("SOCKET_MODE", value)
+		if got := SocketModeFromEnv(); got != want {
+			t.Errorf("SOCKET_MODE=%q: expected %o, got %o", value, want, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package streamlimit caps the long-lived streams clients can open, like process log
+// streams and filesystem watches, each holding goroutines and timers for as long as
+// it is open.
+package streamlimit
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+const (
+	// DefaultMaxLogStreams is the number of log streams open at once when
+	// LOG_STREAMS_MAX is unset
+	DefaultMaxLogStreams = 512
+	// DefaultMaxLogStreamsPerConnection is the number of log streams open at once on a
+	// connection when LOG_STREAMS_MAX_PER_CONNECTION is unset
+	DefaultMaxLogStreamsPerConnection = 64
+	// DefaultMaxWatchers is the number of filesystem watches open at once when
+	// WATCHERS_MAX is unset
+	DefaultMaxWatchers = 256
+	// DefaultMaxWatchersPerConnection is the number of filesystem watches open at once
+	// on a connection when WATCHERS_MAX_PER_CONNECTION is unset
+	DefaultMaxWatchersPerConnection = 32
+)
+
+// Limiter caps the number of streams of a kind open at once, in total and on each
+// connection. A limit of zero disables it.
+type Limiter struct {
+	kind          string
+	max           int
+	perConnection int
+
+	mu           sync.Mutex
+	active       int
+	byConnection map[string]int
+}
+
+// NewLimiter returns a limiter of the streams of a kind, the kind being named in errors
+func NewLimiter(kind string, max, perConnection int) *Limiter {
+	return &Limiter{
+		kind:          kind,
+		max:           max,
+		perConnection: perConnection,
+		byConnection:  make(map[string]int),
+	}
+}
+
+// Global limiters of the log streams and filesystem watches
+var (
+	logStreams     *Limiter
+	logStreamsOnce sync.Once
+	watchers       *Limiter
+	watchersOnce   sync.Once
+)
+
+// LogStreams returns the limiter of the process log streams, configured with
+// LOG_STREAMS_MAX and LOG_STREAMS_MAX_PER_CONNECTION
+func LogStreams() *Limiter {
+	logStreamsOnce.Do(func() {
+		logStreams = NewLimiter("log streams",
+			intFromEnv("LOG_STREAMS_MAX", DefaultMaxLogStreams),
+			intFromEnv("LOG_STREAMS_MAX_PER_CONNECTION", DefaultMaxLogStreamsPerConnection))
+	})
+	return logStreams
+}
+
+// Watchers returns the limiter of the filesystem watches, configured with WATCHERS_MAX
+// and WATCHERS_MAX_PER_CONNECTION
+func Watchers() *Limiter {
+	watchersOnce.Do(func() {
+		watchers = NewLimiter("filesystem watches",
+			intFromEnv("WATCHERS_MAX", DefaultMaxWatchers),
+			intFromEnv("WATCHERS_MAX_PER_CONNECTION", DefaultMaxWatchersPerConnection))
+	})
+	return watchers
+}
+
+// intFromEnv returns the non-negative integer of an environment variable, or fallback
+// when it is unset or invalid
+func intFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		logrus.Warnf("Invalid %s value '%s', using default of %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// Acquire takes a slot for a stream of a connection, identified by its remote address.
+// It fails with STREAM_LIMIT_EXCEEDED when a limit is reached, otherwise the returned
+// function releases the slot once the stream is closed. Releasing twice is a no-op.
+func (l *Limiter) Acquire(connection string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.active >= l.max {
+		return nil, apierror.Newf(apierror.CodeStreamLimitExceeded, "too many %s open, at most %d", l.kind, l.max).
+			WithDetail("limit", l.max)
+	}
+	if l.perConnection > 0 && l.byConnection[connection] >= l.perConnection {
+		return nil, apierror.Newf(apierror.CodeStreamLimitExceeded, "too many %s open on this connection, at most %d", l.kind, l.perConnection).
+			WithDetail("limit", l.perConnection)
+	}
+	l.active++
+	l.byConnection[connection]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { l.release(connection) })
+	}, nil
+}
+
+// release frees the slot of a stream of a connection
+func (l *Limiter) release(connection string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	if l.byConnection[connection]--; l.byConnection[connection] <= 0 {
+		delete(l.byConnection, connection)
+	}
+}
+
+// Active returns the number of streams open
+func (l *Limiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// Max returns the number of streams which can be open at once, zero for no limit
+func (l *Limiter) Max() int {
+	return l.max
+}
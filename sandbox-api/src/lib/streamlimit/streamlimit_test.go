@@ -0,0 +1,50 @@
+package streamlimit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestLimiter tests the total and per connection limits
+func TestLimiter(t *testing.T) {
+	l := NewLimiter("streams", 3, 2)
+	exceeded := apierror.New(apierror.CodeStreamLimitExceeded, "")
+
+	first, err := l.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire("a"); !errors.Is(err, exceeded) {
+		t.Errorf("Expected the connection limit to be reached, got %v", err)
+	}
+	if _, err := l.Acquire("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire("c"); !errors.Is(err, exceeded) {
+		t.Errorf("Expected the total limit to be reached, got %v", err)
+	}
+
+	first()
+	first()
+	if l.Active() != 2 {
+		t.Errorf("Expected 2 streams after releasing one twice, got %d", l.Active())
+	}
+	if _, err := l.Acquire("c"); err != nil {
+		t.Errorf("Expected a released slot to be reused, got %v", err)
+	}
+}
+
+// TestLimiterUnlimited tests that zero limits disable the limiter
+func TestLimiterUnlimited(t *testing.T) {
+	l := NewLimiter("streams", 0, 0)
+	for i := 0; i < 100; i++ {
+		if _, err := l.Acquire("a"); err != nil {
+			t.Fatalf("Expected no limit, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package tlsconfig sets up the TLS termination of the API server, for sandboxes
+// reachable without a gateway terminating it: a certificate and key read from files,
+// reloaded when they change, or a self-signed certificate generated at startup, and
+// optionally the verification of client certificates (mTLS).
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Client certificate modes
+const (
+	// ClientAuthNone does not ask clients for a certificate
+	ClientAuthNone = "none"
+	// ClientAuthOptional verifies the certificates of the clients sending one
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects the clients without a certificate signed by the client CA
+	ClientAuthRequire = "require"
+)
+
+// selfSignedValidity is the validity of the generated self-signed certificates
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// Config is how the server terminates TLS. It is disabled without a certificate and
+// key nor SelfSigned.
+type Config struct {
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool
+	// ClientCAFile is the PEM bundle of the CAs client certificates are verified against
+	ClientCAFile string
+	// ClientAuth is ClientAuthNone, ClientAuthOptional or ClientAuthRequire. It defaults
+	// to ClientAuthRequire with a client CA.
+	ClientAuth string
+}
+
+// ConfigFromEnv returns the TLS configuration of TLS_CERT_FILE and TLS_KEY_FILE, or
+// TLS_SELF_SIGNED, and of TLS_CLIENT_CA_FILE and TLS_CLIENT_AUTH for client certificates
+func ConfigFromEnv() Config {
+	config := Config{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		ClientAuth:   strings.ToLower(os.Getenv("TLS_CLIENT_AUTH")),
+	}
+	if value := os.Getenv("TLS_SELF_SIGNED"); value != "" {
+		config.SelfSigned = value == "true"
+		if value != "true" && value != "false" {
+			logrus.Warnf("Invalid TLS_SELF_SIGNED value '%s', using default of false", value)
+		}
+	}
+	return config
+}
+
+// Enabled tells whether the server terminates TLS
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.SelfSigned
+}
+
+// Validate checks that the configuration is consistent
+func (c Config) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("both a certificate and a key file are required")
+	}
+	if c.SelfSigned && c.CertFile != "" {
+		return fmt.Errorf("a self-signed certificate cannot be used with a certificate file")
+	}
+	switch c.ClientAuthMode() {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if c.ClientCAFile == "" {
+			return fmt.Errorf("client certificates cannot be verified without a client CA file")
+		}
+	default:
+		return fmt.Errorf("invalid client auth %q, expected none, optional or require", c.ClientAuth)
+	}
+	if !c.Enabled() && c.ClientCAFile != "" {
+		return fmt.Errorf("client certificates require TLS")
+	}
+	return nil
+}
+
+// ClientAuthMode returns the client certificate mode, with its default
+func (c Config) ClientAuthMode() string {
+	if c.ClientAuth != "" {
+		return c.ClientAuth
+	}
+	if c.ClientCAFile != "" {
+		return ClientAuthRequire
+	}
+	return ClientAuthNone
+}
+
+// ServerConfig returns the TLS configuration of the server. Certificate files are read
+// again on handshakes once modified, so that they can be renewed without a restart.
+func (c Config) ServerConfig() (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if c.SelfSigned {
+		cert, err := SelfSignedCertificate(selfSignedHosts())
+		if err != nil {
+			return nil, err
+		}
+		fingerprint := sha256.Sum256(cert.Certificate[0])
+		logrus.Infof("Generated a self-signed TLS certificate (SHA-256 fingerprint %s)", hex.EncodeToString(fingerprint[:]))
+		config.Certificates = []tls.Certificate{cert}
+	} else {
+		loader := &certificateLoader{certFile: c.CertFile, keyFile: c.KeyFile}
+		if _, err := loader.load(); err != nil {
+			return nil, err
+		}
+		config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return loader.load()
+		}
+	}
+
+	switch c.ClientAuthMode() {
+	case ClientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		data, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in client CA file %s", c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+// certificateLoader reads a certificate and its key, again once a file is modified
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// load returns the certificate, read again when a file was modified since. The
+// previous certificate is kept when the new files cannot be loaded, like while they
+// are being written.
+func (l *certificateLoader) load() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	modTime, err := latestModTime(l.certFile, l.keyFile)
+	if err != nil && l.cert == nil {
+		return nil, err
+	}
+	if l.cert != nil && (err != nil || !modTime.After(l.modTime)) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		if l.cert != nil {
+			logrus.Warnf("Failed to reload TLS certificate, keeping the previous one: %v", err)
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if l.cert != nil {
+		logrus.Infof("Reloaded TLS certificate from %s", l.certFile)
+	}
+	l.cert = &cert
+	l.modTime = modTime
+	return l.cert, nil
+}
+
+// latestModTime returns the latest modification time of files
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// selfSignedHosts returns the names and addresses the sandbox is reached with
+func selfSignedHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	if addresses, err := net.InterfaceAddrs(); err == nil {
+		for _, address := range addresses {
+			if ipNet, ok := address.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	return hosts
+}
+
+// SelfSignedCertificate generates a self-signed ECDSA certificate valid for hosts,
+// names or IP addresses
+func SelfSignedCertificate(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"Sandbox API"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,216 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestValidate tests the rejection of inconsistent configurations
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"disabled", Config{}, true},
+		{"files", Config{CertFile: "cert.pem", KeyFile: "key.pem"}, true},
+		{"self-signed", Config{SelfSigned: true}, true},
+		{"mtls", Config{SelfSigned: true, ClientCAFile: "ca.pem"}, true},
+		{"optional", Config{SelfSigned: true, ClientCAFile: "ca.pem", ClientAuth: ClientAuthOptional}, true},
+		{"no key", Config{CertFile: "cert.pem"}, false},
+		{"both", Config{CertFile: "cert.pem", KeyFile: "key.pem", SelfSigned: true}, false},
+		{"no ca", Config{SelfSigned: true, ClientAuth: ClientAuthRequire}, false},
+		{"invalid mode", Config{SelfSigned: true, ClientAuth: "always"}, false},
+		{"no tls", Config{ClientCAFile: "ca.pem"}, false},
+	}
+	for _, test := range tests {
+		if err := test.config.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid=%v, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+// TestSelfSigned tests serving with a self-signed certificate
+func TestSelfSigned(t *testing.T) {
+	config, err := Config{SelfSigned: true}.ServerConfig()
+	if err != nil {
+		t.Fatalf("Failed to create the TLS configuration: %v", err)
+	}
+	server := startServer(t, config)
+
+	cert := config.Certificates[0].Leaf
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to call the server: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+// TestClientCertificates tests that clients without a certificate signed by the client
+// CA are rejected
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", ca.Raw)
+
+	config, err := Config{SelfSigned: true, ClientCAFile: caFile}.ServerConfig()
+	if err != nil {
+		t.Fatalf("Failed to create the TLS configuration: %v", err)
+	}
+	server := startServer(t, config)
+	pool := x509.NewCertPool()
+	pool.AddCert(config.Certificates[0].Leaf)
+
+	get := func(certificates []tls.Certificate) error {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certificates}}
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get(nil); err == nil {
+		t.Errorf("Expected a client without a certificate to be rejected")
+	}
+	otherCA, otherKey := newCA(t)
+	if err := get([]tls.Certificate{newClientCertificate(t, otherCA, otherKey)}); err == nil {
+		t.Errorf("Expected a client with a certificate of another CA to be rejected")
+	}
+	if err := get([]tls.Certificate{newClientCertificate(t, ca, caKey)}); err != nil {
+		t.Errorf("Expected a client with a certificate of the client CA to be accepted, got %v", err)
+	}
+}
+
+// TestCertificateReload tests that modified certificate files are read again
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, "first.test")
+
+	loader := &certificateLoader{certFile: certFile, keyFile: keyFile}
+	cert, err := loader.load()
+	if err != nil {
+		t.Fatalf("Failed to load the certificate: %v", err)
+	}
+	if cert.Leaf.Subject.CommonName != "first.test" {
+		t.Fatalf("Unexpected certificate %s", cert.Leaf.Subject.CommonName)
+	}
+
+	// Invalid files keep the previous certificate
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(certFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(certFile, later, later)
+	if cert, err := loader.load(); err != nil || cert.Leaf.Subject.CommonName != "first.test" {
+		t.Fatalf("Expected the previous certificate to be kept, got %v", err)
+	}
+
+	writeCertificate(t, certFile, keyFile, "second.test")
+	later = later.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	if cert, err := loader.load(); err != nil || cert.Leaf.Subject.CommonName != "second.test" {
+		t.Fatalf("Expected the new certificate, got %v", err)
+	}
+}
+
+func startServer(t *testing.T, config *tls.Config) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+func newClientCertificate(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeCertificate(t *testing.T, certFile, keyFile, host string) {
+	t.Helper()
+	cert, err := SelfSignedCertificate([]string{host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, certFile, "CERTIFICATE", cert.Certificate[0])
+	writePEM(t, keyFile, "PRIVATE KEY", key)
+}
+
+func writePEM(t *testing.T, path, blockType string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
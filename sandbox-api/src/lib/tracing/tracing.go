@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by the sandbox API
+const instrumentationName = "github.com/blaxel-ai/sandbox-api"
+
+// defaultServiceName is the service name of the spans when OTEL_SERVICE_NAME is unset
+const defaultServiceName = "sandbox-api"
+
+// Attributes of the sandbox spans
+const (
+	AttrWSOperation       = attribute.Key("sandbox.ws.operation")
+	AttrWSMessageID       = attribute.Key("sandbox.ws.message_id")
+	AttrMCPTool           = attribute.Key("sandbox.mcp.tool")
+	AttrGRPCMethod        = attribute.Key("sandbox.grpc.method")
+	AttrProcessPID        = attribute.Key("sandbox.process.pid")
+	AttrProcessIdentifier = attribute.Key("sandbox.process.identifier")
+	AttrFilePath          = attribute.Key("sandbox.file.path")
+)
+
+// Enabled reports whether spans are exported, which is the case when an OTLP endpoint
+// is configured with OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init sets up the export of spans over OTLP/HTTP, configured by the standard OTEL_*
+// environment variables. When no endpoint is configured, spans are not recorded. The
+// returned function flushes and stops the export.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	// Propagate trace context from and to callers even when spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over the default name
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(defaultServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	// The sampler is read from OTEL_TRACES_SAMPLER, sampling every trace by default
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer starts a span for an incoming request, as a child of the trace context
+// propagated in its headers
+func StartServer(ctx context.Context, carrier propagation.TextMapCarrier, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// End records the error of an operation, if any, and ends its span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Annotate adds attributes to the span in ctx, if any
+func Annotate(ctx context.Context, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
+
+// ArgumentAttributes returns the attributes of the file path and process found in the
+// arguments of an operation
+func ArgumentAttributes(args map[string]interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 2)
+	if path, ok := args["path"].(string); ok && path != "" {
+		attrs = append(attrs, AttrFilePath.String(path))
+	}
+	switch pid := args["pid"].(type) {
+	case string:
+		attrs = append(attrs, AttrProcessPID.String(pid))
+	case float64:
+		attrs = append(attrs, AttrProcessPID.String(fmt.Sprintf("%d", int(pid))))
+	}
+	if identifier, ok := args["identifier"].(string); ok && identifier != "" {
+		attrs = append(attrs, AttrProcessIdentifier.String(identifier))
+	}
+	return attrs
+}
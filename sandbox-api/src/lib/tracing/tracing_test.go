@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestArgumentAttributes tests the attributes read from the arguments of an operation
+func TestArgumentAttributes(t *testing.T) {
+	attrs := ArgumentAttributes(map[string]interface{}{
+		"path":       "/tmp/file.txt",
+		"pid":        float64(42),
+		"identifier": "server",
+		"content":    "ignored",
+	})
+
+	expected := map[attribute.Key]string{
+		AttrFilePath:          "/tmp/file.txt",
+		AttrProcessPID:        "42",
+		AttrProcessIdentifier: "server",
+	}
+	if len(attrs) != len(expected) {
+		t.Fatalf("Expected %d attributes, got %v", len(expected), attrs)
+	}
+	for _, attr := range attrs {
+		if expected[attr.Key] != attr.Value.AsString() {
+			t.Errorf("Expected %s to be %q, got %q", attr.Key, expected[attr.Key], attr.Value.AsString())
+		}
+	}
+
+	if attrs := ArgumentAttributes(map[string]interface{}{"path": ""}); len(attrs) != 0 {
+		t.Errorf("Expected no attributes for empty arguments, got %v", attrs)
+	}
+}
+
+// TestStartServer tests that server spans continue the trace propagated by the caller
+func TestStartServer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	if _, err := Init(context.Background()); err != nil {
+		t.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	header := http.Header{}
+	header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	ctx, span := StartServer(context.Background(), propagation.HeaderCarrier(header), "ws exec", AttrWSOperation.String("exec"))
+	_, child := Start(ctx, "process.execute")
+	End(child, errors.New("boom"))
+	End(span, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for _, ended := range spans {
+		if ended.SpanContext().TraceID().String() != traceID {
+			t.Errorf("Expected span %s to continue trace %s, got %s", ended.Name(), traceID, ended.SpanContext().TraceID())
+		}
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected failed span to have error status, got %v", spans[0].Status())
+	}
+	if spans[1].Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected server span to be a child of the remote span, got %s", spans[1].Parent().SpanID())
+	}
+}
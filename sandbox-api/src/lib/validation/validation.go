@@ -0,0 +1,143 @@
+// Package validation decodes and validates the JSON requests of the REST and WebSocket
+// APIs with the binding tags of their structs, so that both reject invalid requests
+// the same way: an INVALID_REQUEST error listing the invalid fields in its details.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// DetailFields is the detail of validation errors listing the invalid fields
+const DetailFields = "fields"
+
+// FieldError is an invalid field of a request
+type FieldError struct {
+	// Field is the JSON path of the field, like filter.status
+	Field   string `json:"field" example:"path"`
+	Rule    string `json:"rule" example:"required"`
+	Message string `json:"message" example:"path is required"`
+} // @name FieldError
+
+func init() {
+	// Name the fields of validation errors like in the JSON requests
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// Decode decodes a JSON request into obj and validates it
+func Decode(data []byte, obj interface{}) error {
+	if err := json.Unmarshal(data, obj); err != nil {
+		return Error(err)
+	}
+	return Validate(obj)
+}
+
+// Validate validates a request with the binding tags of its struct
+func Validate(obj interface{}) error {
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return Error(err)
+	}
+	return nil
+}
+
+// Error converts an error decoding or validating a request to an INVALID_REQUEST error,
+// with the invalid fields in its details
+func Error(err error) error {
+	var fields []FieldError
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, newFieldError(fieldErr))
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		fields = append(fields, FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be %s", typeErr.Field, jsonType(typeErr.Type)),
+		})
+	case errors.Is(err, io.EOF):
+		return apierror.New(apierror.CodeInvalidRequest, "invalid request: empty body")
+	default:
+		return apierror.Newf(apierror.CodeInvalidRequest, "invalid request: %w", err)
+	}
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.Message)
+	}
+	apiErr := apierror.Newf(apierror.CodeInvalidRequest, "invalid request: %s", strings.Join(messages, ", "))
+	apiErr.Err = err
+	return apiErr.WithDetail(DetailFields, fields)
+}
+
+// newFieldError describes a field failing a validation rule
+func newFieldError(err validator.FieldError) FieldError {
+	// The namespace starts with the name of the request struct
+	field := err.Namespace()
+	if _, path, ok := strings.Cut(field, "."); ok {
+		field = path
+	}
+
+	var message string
+	switch err.Tag() {
+	case "required":
+		message = fmt.Sprintf("%s is required", field)
+	case "oneof":
+		message = fmt.Sprintf("%s must be one of %s", field, strings.Join(strings.Fields(err.Param()), ", "))
+	case "min", "gte":
+		message = fmt.Sprintf("%s must be at least %s", field, err.Param())
+	case "max", "lte":
+		message = fmt.Sprintf("%s must be at most %s", field, err.Param())
+	case "gt":
+		message = fmt.Sprintf("%s must be greater than %s", field, err.Param())
+	case "lt":
+		message = fmt.Sprintf("%s must be less than %s", field, err.Param())
+	default:
+		message = fmt.Sprintf("%s is invalid (%s)", field, err.Tag())
+	}
+	return FieldError{Field: field, Rule: err.Tag(), Message: message}
+}
+
+// jsonType names the JSON type of a Go type
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+type testFilter struct {
+	Status string `json:"status" binding:"omitempty,oneof=running stopped"`
+}
+
+type testRequest struct {
+	Path    string      `json:"path" binding:"required"`
+	Timeout int         `json:"timeout" binding:"gte=0"`
+	LastSeq *int64      `json:"lastSeq" binding:"omitempty,gte=0"`
+	Filter  *testFilter `json:"filter"`
+}
+
+// TestDecode tests that invalid requests fail with the invalid fields, named like in
+// the JSON request, in the details of an INVALID_REQUEST error
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		fields []FieldError
+	}{
+		{"valid", `{"path": "/tmp", "lastSeq": 0}`, nil},
+		{"missing", `{"timeout": -1}`, []FieldError{
+			{Field: "path", Rule: "required", Message: "path is required"},
+			{Field: "timeout", Rule: "gte", Message: "timeout must be at least 0"},
+		}},
+		{"nested", `{"path": "/tmp", "filter": {"status": "done"}}`, []FieldError{
+			{Field: "filter.status", Rule: "oneof", Message: "filter.status must be one of running, stopped"},
+		}},
+		{"optional", `{"path": "/tmp", "lastSeq": -1}`, []FieldError{
+			{Field: "lastSeq", Rule: "gte", Message: "lastSeq must be at least 0"},
+		}},
+		{"type", `{"path": "/tmp", "timeout": "10"}`, []FieldError{
+			{Field: "timeout", Rule: "type", Message: "timeout must be an integer"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req testRequest
+			err := Decode([]byte(tt.data), &req)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("Expected a valid request, got %v", err)
+				}
+				return
+			}
+			apiErr := apierror.From(err)
+			if apiErr == nil || apiErr.Code != apierror.CodeInvalidRequest {
+				t.Fatalf("Expected an INVALID_REQUEST error, got %v", err)
+			}
+			fields, _ := apiErr.Details[DetailFields].([]FieldError)
+			if len(fields) != len(tt.fields) {
+				t.Fatalf("Expected fields %v, got %v", tt.fields, apiErr.Details)
+			}
+			for i := range fields {
+				if fields[i] != tt.fields[i] {
+					t.Errorf("Expected %+v, got %+v", tt.fields[i], fields[i])
+				}
+			}
+		})
+	}
+
+	var req testRequest
+	if err := Decode([]byte(`{"path":`), &req); apierror.From(err) == nil {
+		t.Errorf("Expected malformed JSON to be an INVALID_REQUEST error, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package lib
+
+import "runtime/debug"
+
+// Version is the version of the API, set at build time with
+// -ldflags "-X github.com/blaxel-ai/sandbox-api/src/lib.Version=..."
+var Version = "0.0.1"
+
+// Revision returns the commit the API was built from, empty when built outside of a
+// git checkout
+func Revision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sources of the default working directory
+const (
+	WorkingDirSourceEnv    = "env"
+	WorkingDirSourceCwd    = "cwd"
+	WorkingDirSourceConfig = "config"
+)
+
+var (
+	workingDirMu     sync.RWMutex
+	workingDir       string
+	workingDirSource string
+)
+
+// WorkingDir returns the default working directory of the sandbox, which relative
+// filesystem paths and processes started without a working directory resolve against.
+// It is the WORKDIR environment variable, else the directory the server started in,
+// until changed with SetWorkingDir.
+func WorkingDir() string {
+	dir, _ := WorkingDirWithSource()
+	return dir
+}
+
+// WorkingDirWithSource returns the default working directory and where it comes from:
+// env, cwd or config
+func WorkingDirWithSource() (string, string) {
+	workingDirMu.RLock()
+	dir, source := workingDir, workingDirSource
+	workingDirMu.RUnlock()
+	if dir != "" {
+		return dir, source
+	}
+
+	workingDirMu.Lock()
+	defer workingDirMu.Unlock()
+	if workingDir == "" {
+		workingDir, workingDirSource = os.Getenv("WORKDIR"), WorkingDirSourceEnv
+		if workingDir == "" {
+			workingDirSource = WorkingDirSourceCwd
+			if cwd, err := os.Getwd(); err == nil {
+				workingDir = cwd
+			} else {
+				workingDir = "/"
+			}
+		}
+	}
+	return workingDir, workingDirSource
+}
+
+// SetWorkingDir changes the default working directory. A relative path resolves against
+// the current one. The directory must exist.
+func SetWorkingDir(path string) (string, error) {
+	resolved, err := ResolveWorkingDir(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("could not access working directory '%s': %w", resolved, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("working directory '%s' is not a directory", resolved)
+	}
+
+	workingDirMu.Lock()
+	defer workingDirMu.Unlock()
+	workingDir, workingDirSource = resolved, WorkingDirSourceConfig
+	return resolved, nil
+}
+
+// ResolveWorkingDir returns the absolute working directory of a path: the default
+// working directory when empty, the path joined to it when relative. "~" expands to the
+// home directory.
+func ResolveWorkingDir(path string) (string, error) {
+	path, err := FormatPath(path)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	return filepath.Join(WorkingDir(), path), nil
+}
@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWorkingDir tests resolving paths against the default working directory and changing it
+func TestWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetWorkingDir(dir); err != nil {
+		t.Fatalf("Failed to set the working directory: %v", err)
+	}
+	if workingDir, source := WorkingDirWithSource(); workingDir != dir || source != WorkingDirSourceConfig {
+		t.Fatalf("Expected %s from config, got %s from %s", dir, workingDir, source)
+	}
+
+	for path, want := range map[string]string{
+		"":          dir,
+		"src":       filepath.Join(dir, "src"),
+		"../other":  filepath.Join(filepath.Dir(dir), "other"),
+		"/absolute": "/absolute",
+	} {
+		if got, err := ResolveWorkingDir(path); err != nil || got != want {
+			t.Errorf("Expected %q to resolve to %s, got %s (%v)", path, want, got, err)
+		}
+	}
+
+	// Relative to the current working directory
+	if resolved, err := SetWorkingDir("app"); err != nil || resolved != filepath.Join(dir, "app") {
+		t.Fatalf("Expected the app directory, got %s (%v)", resolved, err)
+	}
+	if _, err := SetWorkingDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+	if WorkingDir() != filepath.Join(dir, "app") {
+		t.Errorf("Expected a failed change to keep the working directory, got %s", WorkingDir())
+	}
+}
@@ -4,14 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/handler/index"
 	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
 	"github.com/blaxel-ai/sandbox-api/src/lib/codegen"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sirupsen/logrus"
@@ -102,7 +104,7 @@ func (s *Server) registerCodegenTools() error {
 	// Codebase search tool
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "codegenCodebaseSearch",
-		Description: "Find snippets of code from the codebase most relevant to the search query. This is a semantic search tool.",
+		Description: "Find the files of the codebase most relevant to the search query, with the functions, classes and types they declare matching it. Searches the workspace index, which is kept up to date as files change.",
 	}, LogToolCall("codegenCodebaseSearch", s.handleCodebaseSearch))
 
 	// Grep search tool
@@ -213,7 +215,7 @@ func (s *Server) handleFileSearch(ctx context.Context, req *mcp.CallToolRequest,
 		cleanSearchDir := filepath.Clean(searchDir)
 		cleanWorkingDir := filepath.Clean(workingDir)
 		if !strings.HasPrefix(cleanSearchDir, cleanWorkingDir) {
-			return nil, CodegenOutput{}, fmt.Errorf("directory must be within workspace")
+			return nil, CodegenOutput{}, apierror.New(apierror.CodeFSOutsideRoot, "directory must be within workspace")
 		}
 
 		// Check if the directory exists
@@ -222,7 +224,7 @@ func (s *Server) handleFileSearch(ctx context.Context, req *mcp.CallToolRequest,
 			return nil, CodegenOutput{}, fmt.Errorf("failed to check directory: %w", err)
 		}
 		if !dirExists {
-			return nil, CodegenOutput{}, fmt.Errorf("directory not found: %s", *args.Directory)
+			return nil, CodegenOutput{}, apierror.Newf(apierror.CodeFSNotFound, "directory not found: %s", *args.Directory)
 		}
 
 		searchDir = cleanSearchDir
@@ -235,7 +237,7 @@ func (s *Server) handleFileSearch(ctx context.Context, req *mcp.CallToolRequest,
 
 		if !info.IsDir() {
 			filename := strings.ToLower(info.Name())
-			if s.fuzzyMatch(filename, query) {
+			if filesystem.FuzzyMatch(filename, query) {
 				matches = append(matches, path)
 				if len(matches) >= 10 {
 					return filepath.SkipAll
@@ -255,16 +257,57 @@ func (s *Server) handleFileSearch(ctx context.Context, req *mcp.CallToolRequest,
 	}, nil
 }
 
-// handleCodebaseSearch implements semantic search across the codebase
+// codebaseSearchLimit is the number of files returned by a codebase search
+const codebaseSearchLimit = 20
+
+// handleCodebaseSearch searches the workspace index for the files most relevant to the query
 func (s *Server) handleCodebaseSearch(ctx context.Context, req *mcp.CallToolRequest, args CodebaseSearchInput) (*mcp.CallToolResult, CodegenOutput, error) {
-	// Simplified implementation - in production, use proper semantic search
-	results := []string{}
+	workspaceIndex := handler.GetWorkspaceIndex()
+	if !workspaceIndex.Ready() {
+		workspaceIndex.Start()
+		return nil, CodegenOutput{}, apierror.New(apierror.CodeUnavailable, "the workspace index is being built, retry in a few seconds")
+	}
+
+	results := []index.Result{}
+	for _, result := range workspaceIndex.Search(args.Query, index.SearchOptions{}) {
+		if len(results) == codebaseSearchLimit {
+			break
+		}
+		if matchesDirectories(workspaceIndex.Root(), result.File, args.TargetDirectories) {
+			results = append(results, result)
+		}
+	}
 	return nil, CodegenOutput{
 		Success: true,
 		Data:    map[string]interface{}{"results": results, "query": args.Query},
 	}, nil
 }
 
+// matchesDirectories reports whether a file is in one of the directories matched by the
+// glob patterns, relative to the workspace root. Any file matches without patterns.
+func matchesDirectories(root, file string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(file))
+	if err != nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.Trim(filepath.Clean(pattern), "/")
+		// A directory matches along with its subdirectories
+		for dir := rel; ; dir = filepath.Dir(dir) {
+			if matched, _ := filepath.Match(pattern, dir); matched {
+				return true
+			}
+			if dir == "." || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+	return false
+}
+
 // handleGrepSearch implements regex search functionality
 func (s *Server) handleGrepSearch(ctx context.Context, req *mcp.CallToolRequest, args GrepSearchInput) (*mcp.CallToolResult, CodegenOutput, error) {
 	cmd := exec.Command("rg", "--json")
@@ -308,7 +351,7 @@ func (s *Server) handleReadFileRange(ctx context.Context, req *mcp.CallToolReque
 
 	lines := strings.Split(string(file.Content), "\n")
 	if args.StartLineOneIndexed < 1 || args.EndLineOneIndexedInclusive > len(lines) {
-		return nil, CodegenOutput{}, fmt.Errorf("invalid line range")
+		return nil, CodegenOutput{}, apierror.New(apierror.CodeInvalidRequest, "invalid line range")
 	}
 
 	selectedLines := lines[args.StartLineOneIndexed-1 : args.EndLineOneIndexedInclusive]
@@ -360,7 +403,7 @@ func (s *Server) handleRerank(ctx context.Context, req *mcp.CallToolRequest, arg
 	// Format the path
 	directory, err := lib.FormatPath(directory)
 	if err != nil {
-		return nil, CodegenOutput{}, fmt.Errorf("invalid path: %w", err)
+		return nil, CodegenOutput{}, apierror.Newf(apierror.CodeInvalidRequest, "invalid path: %w", err)
 	}
 
 	scoreThreshold := 0.5
@@ -384,7 +427,7 @@ func (s *Server) handleRerank(ctx context.Context, req *mcp.CallToolRequest, arg
 		return nil, CodegenOutput{}, fmt.Errorf("failed to check directory: %w", err)
 	}
 	if !isDir {
-		return nil, CodegenOutput{}, fmt.Errorf("path is not a directory: %s", directory)
+		return nil, CodegenOutput{}, apierror.Newf(apierror.CodeFSNotADirectory, "path is not a directory: %s", directory)
 	}
 
 	// Create a client that supports reranking
@@ -394,40 +437,19 @@ func (s *Server) handleRerank(ctx context.Context, req *mcp.CallToolRequest, arg
 	}
 
 	// Check if the client supports reranking
-	reranker, ok := client.(codegen.CodeReranker)
-	if !ok {
+	if _, ok := client.(codegen.CodeReranker); !ok {
 		return nil, CodegenOutput{}, fmt.Errorf("current provider (%s) does not support reranking", client.ProviderName())
 	}
 
-	// Collect documents from the directory
-	documents, err := s.collectDocumentsFromDirectory(directory, filePattern)
+	// Rank the files matched by the workspace index, or every file of the directory
+	filteredFiles, err := s.handlers.Codegen.RerankFiles(ctx, directory, handler.RerankingRequest{
+		Query:          args.Query,
+		ScoreThreshold: scoreThreshold,
+		TokenLimit:     tokenLimit,
+		FilePattern:    filePattern,
+	}, func([]codegen.RankedFile) {})
 	if err != nil {
-		return nil, CodegenOutput{}, fmt.Errorf("failed to collect documents: %w", err)
-	}
-
-	if len(documents) == 0 {
-		return nil, CodegenOutput{
-			Success: true,
-			Message: "No files found matching criteria",
-			Data: map[string]interface{}{
-				"files": []codegen.RankedFile{},
-			},
-		}, nil
-	}
-
-	// Perform reranking
-	logrus.Infof("Performing code reranking on %d files using %s", len(documents), client.ProviderName())
-	rankedFiles, err := reranker.RerankCode(documents, args.Query, tokenLimit)
-	if err != nil {
-		return nil, CodegenOutput{}, fmt.Errorf("failed to rerank documents: %w", err)
-	}
-
-	// Filter by score threshold
-	filteredFiles := make([]codegen.RankedFile, 0)
-	for _, file := range rankedFiles {
-		if file.Score >= scoreThreshold {
-			filteredFiles = append(filteredFiles, file)
-		}
+		return nil, CodegenOutput{}, err
 	}
 
 	return nil, CodegenOutput{
@@ -439,114 +461,6 @@ func (s *Server) handleRerank(ctx context.Context, req *mcp.CallToolRequest, arg
 	}, nil
 }
 
-// collectDocumentsFromDirectory walks a directory and collects all eligible code files
-func (s *Server) collectDocumentsFromDirectory(directory, filePattern string) ([]codegen.CodebaseDocument, error) {
-	// Compile regex pattern if provided
-	var fileRegex *regexp.Regexp
-	if filePattern != "" {
-		var err error
-		fileRegex, err = regexp.Compile(filePattern)
-		if err != nil {
-			return nil, fmt.Errorf("invalid file pattern regex: %w", err)
-		}
-	}
-
-	documents := []codegen.CodebaseDocument{}
-
-	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip directories
-		if d.IsDir() {
-			return nil
-		}
-
-		fileSplittered := strings.Split(path, "/")
-		filename := fileSplittered[len(fileSplittered)-1]
-		// Skip hidden files and common directories to ignore
-		if shouldSkipFile(path) {
-			return nil
-		}
-
-		// Apply file pattern filter if provided
-		if fileRegex != nil && !fileRegex.MatchString(path) && !fileRegex.MatchString(filename) {
-			return nil
-		}
-
-		// Read file content
-		content, err := os.ReadFile(path)
-		if err != nil {
-			// Skip files we can't read
-			return nil
-		}
-
-		// Skip binary files
-		if isBinaryFile(content) {
-			return nil
-		}
-
-		// Add to documents
-		documents = append(documents, codegen.CodebaseDocument{
-			Path:    path,
-			Content: string(content),
-		})
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
-	}
-
-	return documents, nil
-}
-
-// shouldSkipFile determines if a file should be skipped during directory traversal
-func shouldSkipFile(path string) bool {
-	// Skip hidden files and directories
-	base := filepath.Base(path)
-	if len(base) > 0 && base[0] == '.' {
-		return true
-	}
-
-	// Skip common directories to ignore
-	skipDirs := []string{"node_modules", "vendor", ".git", "dist", "build", "target", "__pycache__", ".venv"}
-	for _, dir := range skipDirs {
-		if filepath.Base(filepath.Dir(path)) == dir {
-			return true
-		}
-	}
-
-	return false
-}
-
-// isBinaryFile checks if a file is binary by looking for null bytes
-func isBinaryFile(content []byte) bool {
-	return false
-}
-
-// fuzzyMatch checks if query characters appear in order in the text
-func (s *Server) fuzzyMatch(text, query string) bool {
-	textIdx := 0
-	for _, char := range query {
-		found := false
-		for textIdx < len(text) {
-			if rune(text[textIdx]) == char {
-				found = true
-				textIdx++
-				break
-			}
-			textIdx++
-		}
-		if !found {
-			return false
-		}
-	}
-	return true
-}
-
 // CreateJSONResponse is a helper to create JSON responses (kept for compatibility)
 func CreateJSONResponse(data interface{}) (*mcp.CallToolResult, error) {
 	jsonData, err := json.Marshal(data)
@@ -3,10 +3,10 @@ package mcp
 import (
 	"context"
 	"fmt"
-	"os"
-	"strconv"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/blaxel-ai/sandbox-api/src/service"
 )
 
 // Filesystem tool input/output types
@@ -46,7 +46,7 @@ type WriteFileOutput struct {
 
 type DeleteFileInput struct {
 	Path        string `json:"path" jsonschema:"Path to the file or directory"`
-	IsDirectory *bool  `json:"isDirectory,omitempty" jsonschema:"Whether the path refers to a directory"`
+	IsDirectory *bool  `json:"isDirectory,omitempty" jsonschema:"Deprecated: directories are detected from the path"`
 	Recursive   *bool  `json:"recursive,omitempty" jsonschema:"Whether to perform the operation recursively"`
 }
 
@@ -101,45 +101,33 @@ func (s *Server) registerFileSystemTools() error {
 		Name:        "fsWriteFile",
 		Description: "Create or update a file",
 	}, LogToolCall("fsWriteFile", func(ctx context.Context, req *mcp.CallToolRequest, input WriteFileInput) (*mcp.CallToolResult, WriteFileOutput, error) {
-		// Parse permissions or use default
-		var permissions os.FileMode = 0644
-		if input.Permissions != nil && *input.Permissions != "" {
-			permInt, err := strconv.ParseUint(*input.Permissions, 8, 32)
-			if err == nil {
-				permissions = os.FileMode(permInt)
-			}
+		isDirectory := input.IsDirectory != nil && *input.IsDirectory
+		permissions := ""
+		if input.Permissions != nil {
+			permissions = *input.Permissions
 		}
-
-		isDirectory := false
-		if input.IsDirectory != nil {
-			isDirectory = *input.IsDirectory
+		mode, err := service.ParsePermissions(permissions, isDirectory)
+		if err != nil {
+			return nil, WriteFileOutput{}, err
+		}
+		var content []byte
+		if input.Content != nil && !isDirectory {
+			content = []byte(*input.Content)
 		}
 
+		result, err := s.handlers.FileSystem.Write(service.WriteRequest{
+			Path:        input.Path,
+			Content:     content,
+			Permissions: mode,
+			IsDirectory: isDirectory,
+		})
+		if err != nil {
+			return nil, WriteFileOutput{}, err
+		}
 		if isDirectory {
-			// Create directory
-			err := s.handlers.FileSystem.CreateDirectory(input.Path, permissions)
-			if err != nil {
-				return nil, WriteFileOutput{}, fmt.Errorf("failed to create directory: %w", err)
-			}
-			return nil, WriteFileOutput{
-				Path:    input.Path,
-				Message: "Directory created successfully",
-			}, nil
-		} else {
-			// Create or update file
-			content := ""
-			if input.Content != nil {
-				content = *input.Content
-			}
-			err := s.handlers.FileSystem.WriteFile(input.Path, []byte(content), permissions)
-			if err != nil {
-				return nil, WriteFileOutput{}, fmt.Errorf("failed to write file: %w", err)
-			}
-			return nil, WriteFileOutput{
-				Path:    input.Path,
-				Message: "File created/updated successfully",
-			}, nil
+			return nil, WriteFileOutput{Path: result.Path, Message: "Directory created successfully"}, nil
 		}
+		return nil, WriteFileOutput{Path: result.Path, Message: "File created/updated successfully"}, nil
 	}))
 
 	// Delete file or directory
@@ -147,45 +135,15 @@ func (s *Server) registerFileSystemTools() error {
 		Name:        "fsDeleteFileOrDirectory",
 		Description: "Delete a file or directory",
 	}, LogToolCall("fsDeleteFileOrDirectory", func(ctx context.Context, req *mcp.CallToolRequest, input DeleteFileInput) (*mcp.CallToolResult, DeleteFileOutput, error) {
-		// Check if it's a directory (or use the hint from input)
-		isDir := false
-		if input.IsDirectory != nil {
-			isDir = *input.IsDirectory
-		} else {
-			// Auto-detect if not specified
-			var err error
-			isDir, err = s.handlers.FileSystem.DirectoryExists(input.Path)
-			if err != nil {
-				return nil, DeleteFileOutput{}, fmt.Errorf("failed to check if path is a directory: %w", err)
-			}
-		}
-
-		recursive := false
-		if input.Recursive != nil {
-			recursive = *input.Recursive
+		// Directories are detected from the path, whatever isDirectory says
+		isDir, err := s.handlers.FileSystem.Delete(input.Path, input.Recursive != nil && *input.Recursive, "")
+		if err != nil {
+			return nil, DeleteFileOutput{}, err
 		}
-
 		if isDir {
-			// Delete directory
-			err := s.handlers.FileSystem.DeleteDirectory(input.Path, recursive)
-			if err != nil {
-				return nil, DeleteFileOutput{}, fmt.Errorf("failed to delete directory: %w", err)
-			}
-			return nil, DeleteFileOutput{
-				Path:    input.Path,
-				Message: "Directory deleted successfully",
-			}, nil
-		} else {
-			// Delete file
-			err := s.handlers.FileSystem.DeleteFile(input.Path)
-			if err != nil {
-				return nil, DeleteFileOutput{}, fmt.Errorf("failed to delete file: %w", err)
-			}
-			return nil, DeleteFileOutput{
-				Path:    input.Path,
-				Message: "File deleted successfully",
-			}, nil
+			return nil, DeleteFileOutput{Path: input.Path, Message: "Directory deleted successfully"}, nil
 		}
+		return nil, DeleteFileOutput{Path: input.Path, Message: "File deleted successfully"}, nil
 	}))
 
 	return nil
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/git"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Git tool input/output types
+type GitStatusInput struct {
+	Path string `json:"path" jsonschema:"Path of the repository (relative to workspace root or absolute)"`
+}
+
+type GitStatusOutput struct {
+	Status *git.Status `json:"status"`
+}
+
+type GitDiffInput struct {
+	Path   string   `json:"path" jsonschema:"Path of the repository (relative to workspace root or absolute)"`
+	Staged *bool    `json:"staged,omitempty" jsonschema:"Diff the staged changes instead of the working tree (default: false)"`
+	Paths  []string `json:"paths,omitempty" jsonschema:"Restrict the diff to these paths"`
+}
+
+type GitDiffOutput struct {
+	Diff string `json:"diff"`
+}
+
+type GitCommitInput struct {
+	Path        string   `json:"path" jsonschema:"Path of the repository (relative to workspace root or absolute)"`
+	Message     string   `json:"message" jsonschema:"The commit message"`
+	Paths       []string `json:"paths,omitempty" jsonschema:"Paths to stage before committing"`
+	All         *bool    `json:"all,omitempty" jsonschema:"Commit every change of tracked files (default: false)"`
+	AuthorName  *string  `json:"authorName,omitempty" jsonschema:"Author name of the commit"`
+	AuthorEmail *string  `json:"authorEmail,omitempty" jsonschema:"Author email of the commit"`
+}
+
+type GitCommitOutput struct {
+	Hash string `json:"hash"`
+}
+
+func (s *Server) registerGitTools() error {
+	// Repository status
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "gitStatus",
+		Description: "Get the current branch, upstream tracking information and changed files of a git repository",
+	}, LogToolCall("gitStatus", func(ctx context.Context, req *mcp.CallToolRequest, input GitStatusInput) (*mcp.CallToolResult, GitStatusOutput, error) {
+		status, err := s.handlers.Git.GetStatus(ctx, input.Path)
+		if err != nil {
+			return nil, GitStatusOutput{}, fmt.Errorf("failed to get git status: %w", err)
+		}
+		return nil, GitStatusOutput{Status: status}, nil
+	}))
+
+	// Repository diff
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "gitDiff",
+		Description: "Get the unified diff of the working tree, or of the staged changes, of a git repository",
+	}, LogToolCall("gitDiff", func(ctx context.Context, req *mcp.CallToolRequest, input GitDiffInput) (*mcp.CallToolResult, GitDiffOutput, error) {
+		staged := false
+		if input.Staged != nil {
+			staged = *input.Staged
+		}
+
+		diff, err := s.handlers.Git.GetDiff(ctx, input.Path, staged, input.Paths)
+		if err != nil {
+			return nil, GitDiffOutput{}, fmt.Errorf("failed to get git diff: %w", err)
+		}
+		return nil, GitDiffOutput{Diff: diff}, nil
+	}))
+
+	// Commit changes
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "gitCommit",
+		Description: "Stage the given paths and commit the staged changes of a git repository",
+	}, LogToolCall("gitCommit", func(ctx context.Context, req *mcp.CallToolRequest, input GitCommitInput) (*mcp.CallToolResult, GitCommitOutput, error) {
+		commit := handler.GitCommitRequest{Message: input.Message}
+		if input.All != nil {
+			commit.All = *input.All
+		}
+		if input.AuthorName != nil {
+			commit.AuthorName = *input.AuthorName
+		}
+		if input.AuthorEmail != nil {
+			commit.AuthorEmail = *input.AuthorEmail
+		}
+
+		hash, err := s.handlers.Git.Commit(ctx, input.Path, input.Paths, commit)
+		if err != nil {
+			return nil, GitCommitOutput{}, fmt.Errorf("failed to commit: %w", err)
+		}
+		return nil, GitCommitOutput{Hash: hash}, nil
+	}))
+
+	return nil
+}
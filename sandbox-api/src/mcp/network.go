@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/network"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Network tool input types
+type HTTPRequestInput struct {
+	URL             string            `json:"url" jsonschema:"Absolute http or https URL to request, e.g. http://localhost:3000/api"`
+	Method          *string           `json:"method,omitempty" jsonschema:"HTTP method (default: GET)"`
+	Headers         map[string]string `json:"headers,omitempty" jsonschema:"Request headers"`
+	Body            *string           `json:"body,omitempty" jsonschema:"Request body"`
+	BodyEncoding    *string           `json:"bodyEncoding,omitempty" jsonschema:"Encoding of the body, text or base64 (default: text)"`
+	Timeout         *int              `json:"timeout,omitempty" jsonschema:"Timeout in seconds (default: 30, max: 300)"`
+	FollowRedirects *bool             `json:"followRedirects,omitempty" jsonschema:"Follow redirects (default: true)"`
+	MaxRedirects    *int              `json:"maxRedirects,omitempty" jsonschema:"Maximum number of redirects to follow (default: 10)"`
+}
+
+func (s *Server) registerNetworkTools() error {
+	// HTTP request
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "httpRequest",
+		Description: "Perform an HTTP request from inside the sandbox and return its status, headers and body. Bodies that are not valid UTF-8 are returned base64 encoded.",
+	}, LogToolCall("httpRequest", func(ctx context.Context, req *mcp.CallToolRequest, input HTTPRequestInput) (*mcp.CallToolResult, network.HTTPResponse, error) {
+		request := network.HTTPRequest{
+			URL:             input.URL,
+			Headers:         input.Headers,
+			FollowRedirects: input.FollowRedirects,
+		}
+		if input.Method != nil {
+			request.Method = *input.Method
+		}
+		if input.Body != nil {
+			request.Body = *input.Body
+		}
+		if input.BodyEncoding != nil {
+			request.BodyEncoding = *input.BodyEncoding
+		}
+		if input.Timeout != nil {
+			request.Timeout = *input.Timeout
+		}
+		if input.MaxRedirects != nil {
+			request.MaxRedirects = *input.MaxRedirects
+		}
+
+		resp, err := s.handlers.Network.DoHTTPRequest(ctx, request)
+		if err != nil {
+			return nil, network.HTTPResponse{}, fmt.Errorf("failed to perform HTTP request: %w", err)
+		}
+		return nil, *resp, nil
+	}))
+
+	return nil
+}
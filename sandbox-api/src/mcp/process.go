@@ -3,8 +3,12 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/service"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -16,16 +20,24 @@ type ListProcessesOutput struct {
 }
 
 type ProcessExecuteInput struct {
-	Command           string            `json:"command" jsonschema:"The command to execute"`
-	Name              *string           `json:"name,omitempty" jsonschema:"Technical name for the process"`
-	WorkingDir        *string           `json:"workingDir,omitempty" jsonschema:"The working directory for the command (default: /)"`
-	Env               map[string]string `json:"env,omitempty" jsonschema:"Environment variables to set for the command"`
-	WaitForCompletion *bool             `json:"waitForCompletion,omitempty" jsonschema:"Whether to wait for the command to complete before returning"`
-	Timeout           *int              `json:"timeout,omitempty" jsonschema:"Timeout in seconds for the command (default: 30)"`
-	WaitForPorts      []int             `json:"waitForPorts,omitempty" jsonschema:"List of ports to wait for before returning"`
-	IncludeLogs       *bool             `json:"includeLogs,omitempty" jsonschema:"Whether to include logs in the response"`
-	RestartOnFailure  *bool             `json:"restartOnFailure,omitempty" jsonschema:"Whether to restart the process on failure (default: false)"`
-	MaxRestarts       *int              `json:"maxRestarts,omitempty" jsonschema:"Maximum number of restarts (default: 0)"`
+	Command           string                 `json:"command" jsonschema:"The command to execute"`
+	Name              *string                `json:"name,omitempty" jsonschema:"Technical name for the process"`
+	WorkingDir        *string                `json:"workingDir,omitempty" jsonschema:"The working directory for the command, relative paths resolve against the sandbox working directory (default: the sandbox working directory)"`
+	Env               map[string]string      `json:"env,omitempty" jsonschema:"Environment variables to set for the command"`
+	RunAsUser         *string                `json:"runAsUser,omitempty" jsonschema:"User, by name or id, to run the command as (default: the RUN_AS user of the sandbox)"`
+	RunAsGroup        *string                `json:"runAsGroup,omitempty" jsonschema:"Group, by name or id, to run the command as (default: the primary group of the user)"`
+	WaitForCompletion *bool                  `json:"waitForCompletion,omitempty" jsonschema:"Whether to wait for the command to complete before returning"`
+	Timeout           *int                   `json:"timeout,omitempty" jsonschema:"Timeout in seconds after which the command is killed (default: 30 when waiting for completion, none otherwise)"`
+	WaitForPorts      []int                  `json:"waitForPorts,omitempty" jsonschema:"List of ports to wait for before returning"`
+	WaitForLogPattern *string                `json:"waitForLogPattern,omitempty" jsonschema:"Regular expression to wait for in the process logs before returning"`
+	IncludeLogs       *bool                  `json:"includeLogs,omitempty" jsonschema:"Whether to include logs in the response"`
+	RestartOnFailure  *bool                  `json:"restartOnFailure,omitempty" jsonschema:"Whether to restart the process on failure (default: false)"`
+	MaxRestarts       *int                   `json:"maxRestarts,omitempty" jsonschema:"Maximum number of restarts (default: 0, or 25 with restartPolicy)"`
+	RestartPolicy     *string                `json:"restartPolicy,omitempty" jsonschema:"When to restart the process: never, on-failure or always (default: on-failure with restartOnFailure, never otherwise)"`
+	Backoff           *process.BackoffConfig `json:"backoff,omitempty" jsonschema:"Exponential backoff between restarts (default: 1 second between restarts)"`
+	RestartWindow     *int                   `json:"restartWindow,omitempty" jsonschema:"Sliding window in seconds maxRestarts applies to (default: 0, the lifetime of the process)"`
+	LogToFile         *bool                  `json:"logToFile,omitempty" jsonschema:"Whether to also write the output to rotated log files (default: false, or true when PROCESS_LOG_TO_FILE is set)"`
+	Record            *bool                  `json:"record,omitempty" jsonschema:"Whether to record the output to an asciicast file listed by /recordings (default: false, or true when PROCESS_RECORD is set)"`
 }
 
 type ProcessExecuteOutput struct {
@@ -37,6 +49,16 @@ type ProcessIdentifierInput struct {
 	Identifier string `json:"identifier" jsonschema:"Process identifier (PID or name)"`
 }
 
+type ProcessStopInput struct {
+	Identifier         string `json:"identifier" jsonschema:"Process identifier (PID or name)"`
+	StopTimeoutSeconds *int   `json:"stopTimeoutSeconds,omitempty" jsonschema:"Seconds to wait for the process to exit before killing it (default: 0, never killed)"`
+}
+
+type ProcessSignalInput struct {
+	Identifier string `json:"identifier" jsonschema:"Process identifier (PID or name)"`
+	Signal     string `json:"signal" jsonschema:"Signal name, with or without the SIG prefix, or number (e.g. SIGHUP, USR1, 15)"`
+}
+
 type ProcessInfoOutput struct {
 	Process handler.ProcessResponse `json:"process"`
 }
@@ -61,67 +83,58 @@ func (s *Server) registerProcessTools() error {
 		Name:        "processExecute",
 		Description: "Execute a command",
 	}, LogToolCall("processExecute", func(ctx context.Context, req *mcp.CallToolRequest, input ProcessExecuteInput) (*mcp.CallToolResult, ProcessExecuteOutput, error) {
-		// Apply defaults for optional fields
-		name := ""
+		start := service.StartRequest{
+			Command:      input.Command,
+			Env:          input.Env,
+			WaitForPorts: input.WaitForPorts,
+			Backoff:      input.Backoff,
+			LogToFile:    input.LogToFile != nil && *input.LogToFile,
+			Record:       input.Record != nil && *input.Record,
+		}
 		if input.Name != nil {
-			name = *input.Name
+			start.Name = *input.Name
 		}
-
-		workingDir := "/"
+		// Empty runs in the default working directory of the sandbox
 		if input.WorkingDir != nil {
-			workingDir = *input.WorkingDir
+			start.WorkingDir = *input.WorkingDir
 		}
-
-		env := input.Env
-		if env == nil {
-			env = map[string]string{}
+		if input.RunAsUser != nil {
+			start.RunAs.User = *input.RunAsUser
+		}
+		if input.RunAsGroup != nil {
+			start.RunAs.Group = *input.RunAsGroup
 		}
-
-		waitForCompletion := false
 		if input.WaitForCompletion != nil {
-			waitForCompletion = *input.WaitForCompletion
+			start.WaitForCompletion = *input.WaitForCompletion
 		}
-
-		timeout := 30
-		if input.Timeout != nil {
-			timeout = *input.Timeout
+		if start.WaitForCompletion {
+			start.Timeout = 30
 		}
-
-		waitForPorts := input.WaitForPorts
-		if waitForPorts == nil {
-			waitForPorts = []int{}
+		if input.Timeout != nil {
+			start.Timeout = *input.Timeout
 		}
-
-		includeLogs := false
-		if input.IncludeLogs != nil {
-			includeLogs = *input.IncludeLogs
+		if input.WaitForLogPattern != nil {
+			start.WaitForLogPattern = *input.WaitForLogPattern
 		}
-
-		restartOnFailure := false
 		if input.RestartOnFailure != nil {
-			restartOnFailure = *input.RestartOnFailure
+			start.RestartOnFailure = *input.RestartOnFailure
 		}
-
-		maxRestarts := 0
 		if input.MaxRestarts != nil {
-			maxRestarts = *input.MaxRestarts
-		}
-		processInfo, err := s.handlers.Process.ExecuteProcess(
-			input.Command,
-			workingDir,
-			name,
-			env,
-			waitForCompletion,
-			timeout,
-			waitForPorts,
-			restartOnFailure,
-			maxRestarts,
-		)
+			start.MaxRestarts = *input.MaxRestarts
+		}
+		if input.RestartPolicy != nil {
+			start.RestartPolicy = *input.RestartPolicy
+		}
+		if input.RestartWindow != nil {
+			start.RestartWindow = *input.RestartWindow
+		}
+
+		processInfo, err := s.handlers.Process.StartProcess(ctx, start)
 		if err != nil {
 			return nil, ProcessExecuteOutput{}, err
 		}
 
-		if !includeLogs {
+		if input.IncludeLogs == nil || !*input.IncludeLogs {
 			return nil, ProcessExecuteOutput{Process: processInfo}, nil
 		}
 
@@ -165,14 +178,36 @@ func (s *Server) registerProcessTools() error {
 	// Stop process
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "processStop",
-		Description: "Stop a specific process",
-	}, LogToolCall("processStop", func(ctx context.Context, req *mcp.CallToolRequest, input ProcessIdentifierInput) (*mcp.CallToolResult, map[string]string, error) {
-		if err := s.handlers.Process.StopProcess(input.Identifier); err != nil {
+		Description: "Stop a specific process, killing it when it is still running after stopTimeoutSeconds",
+	}, LogToolCall("processStop", func(ctx context.Context, req *mcp.CallToolRequest, input ProcessStopInput) (*mcp.CallToolResult, map[string]string, error) {
+		stopTimeout := 0
+		if input.StopTimeoutSeconds != nil {
+			stopTimeout = *input.StopTimeoutSeconds
+		}
+		if stopTimeout < 0 {
+			return nil, nil, apierror.New(apierror.CodeInvalidRequest, "invalid stopTimeoutSeconds: must be a non-negative number")
+		}
+		if err := s.handlers.Process.StopProcessWithTimeout(input.Identifier, time.Duration(stopTimeout)*time.Second); err != nil {
 			return nil, nil, fmt.Errorf("failed to stop process: %w", err)
 		}
 		return nil, map[string]string{"status": "stopped"}, nil
 	}))
 
+	// Signal process
+	mcp.AddTool(s.mcpServer, &mcp.Tool{
+		Name:        "processSignal",
+		Description: "Send a signal to a specific process, for instance SIGHUP to reload a server",
+	}, LogToolCall("processSignal", func(ctx context.Context, req *mcp.CallToolRequest, input ProcessSignalInput) (*mcp.CallToolResult, map[string]string, error) {
+		signal, err := process.ParseSignal(input.Signal)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.handlers.Process.SignalProcess(input.Identifier, signal); err != nil {
+			return nil, nil, fmt.Errorf("failed to signal process: %w", err)
+		}
+		return nil, map[string]string{"status": "signaled"}, nil
+	}))
+
 	// Kill process
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "processKill",
@@ -0,0 +1,231 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+)
+
+const (
+	// fileResourceTemplate is the URI template of the files of the sandbox
+	fileResourceTemplate = "file:///{+path}"
+	// maxListedResources is the number of files of the working directory listed as resources
+	maxListedResources = 1000
+	// resourcesPageSize is the number of resources per page of resources/list
+	resourcesPageSize = 100
+	// maxResourceSize is the size of the largest file read as a resource
+	maxResourceSize = 10 * 1024 * 1024
+)
+
+// resourceSubscription is a watch notifying the subscribers of a resource of its changes
+type resourceSubscription struct {
+	subscribers int
+	stop        func()
+}
+
+// registerResources exposes the files of the sandbox as resources. The files of the
+// working directory are listed, and any file or directory can be read and subscribed
+// to by its file:// URI.
+func (s *Server) registerResources() {
+	s.mcpServer.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "file",
+		Title:       "Sandbox file",
+		Description: "A file or directory of the sandbox by absolute path. Directories are read as a JSON listing.",
+		URITemplate: fileResourceTemplate,
+	}, s.readFileResource)
+
+	// Files are not registered one by one, the listing reflects the working directory
+	s.mcpServer.AddReceivingMiddleware(func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if method != "resources/list" {
+				return next(ctx, method, req)
+			}
+			cursor := ""
+			if listReq, ok := req.(*mcp.ListResourcesRequest); ok && listReq.Params != nil {
+				cursor = listReq.Params.Cursor
+			}
+			return s.listFileResources(cursor)
+		}
+	})
+}
+
+// fileURI returns the resource URI of an absolute path
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// filePath returns the absolute path of a file resource URI
+func filePath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" || parsed.Path == "" {
+		return "", fmt.Errorf("invalid file URI: %s", uri)
+	}
+	return filepath.Clean(filepath.FromSlash(parsed.Path)), nil
+}
+
+// listFileResources lists the files of the working directory, paginated by cursor, the
+// offset of the page
+func (s *Server) listFileResources(cursor string) (*mcp.ListResourcesResult, error) {
+	offset := 0
+	if cursor != "" {
+		var err error
+		offset, err = strconv.Atoi(cursor)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid cursor: %s", cursor)
+		}
+	}
+
+	workingDir, err := s.handlers.FileSystem.GetWorkingDirectory()
+	if err != nil {
+		return nil, err
+	}
+	paths, _, err := s.handlers.FileSystem.ListFiles(workingDir, maxListedResources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	result := &mcp.ListResourcesResult{Resources: []*mcp.Resource{}}
+	end := min(offset+resourcesPageSize, len(paths))
+	for _, path := range paths[min(offset, end):end] {
+		resource := &mcp.Resource{
+			URI:      fileURI(path),
+			Name:     filepath.Base(path),
+			Title:    path,
+			MIMEType: mime.TypeByExtension(filepath.Ext(path)),
+		}
+		if info, err := os.Stat(path); err == nil {
+			resource.Size = info.Size()
+		}
+		result.Resources = append(result.Resources, resource)
+	}
+	if end < len(paths) {
+		result.NextCursor = strconv.Itoa(end)
+	}
+	return result, nil
+}
+
+// readFileResource reads a file as text, or as a blob when it is not valid UTF-8, and
+// a directory as the JSON listing of its entries
+func (s *Server) readFileResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	path, err := filePath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		return nil, err
+	}
+
+	if info.IsDir() {
+		dir, err := s.handlers.FileSystem.ListDirectory(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list directory: %w", err)
+		}
+		listing, err := json.Marshal(dir)
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+			{URI: uri, MIMEType: "application/json", Text: string(listing)},
+		}}, nil
+	}
+
+	if info.Size() > maxResourceSize {
+		return nil, fmt.Errorf("file is too large to be read as a resource (%d bytes, limit is %d)", info.Size(), maxResourceSize)
+	}
+	file, err := s.handlers.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	contents := &mcp.ResourceContents{URI: uri, MIMEType: mimeType}
+	if utf8.Valid(file.Content) {
+		contents.Text = string(file.Content)
+		if contents.MIMEType == "" {
+			contents.MIMEType = "text/plain"
+		}
+	} else {
+		contents.Blob = file.Content
+		if contents.MIMEType == "" {
+			contents.MIMEType = "application/octet-stream"
+		}
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+}
+
+// subscribeResource watches a file, or the entries of a directory, to notify the
+// subscribers of the resource of its changes. Subscriptions to a resource share a watch.
+func (s *Server) subscribeResource(ctx context.Context, req *mcp.SubscribeRequest) error {
+	uri := req.Params.URI
+	path, err := filePath(uri)
+	if err != nil {
+		return err
+	}
+
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	if subscription, exists := s.subscriptions[uri]; exists {
+		subscription.subscribers++
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return mcp.ResourceNotFoundError(uri)
+	}
+	isDir := info.IsDir()
+	watched := path
+	if !isDir {
+		watched = filepath.Dir(path)
+	}
+
+	notify := func(event handler.FileEvent) {
+		if !isDir && filepath.Join(event.Path, event.Name) != path && event.From != path {
+			return
+		}
+		if err := s.mcpServer.ResourceUpdated(context.Background(), &mcp.ResourceUpdatedNotificationParams{URI: uri}); err != nil {
+			logrus.Debugf("Failed to notify resource update of %s: %v", uri, err)
+		}
+	}
+	stop, err := s.handlers.FileSystem.WatchDirectory(watched, false, nil, false, notify)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	s.subscriptions[uri] = &resourceSubscription{subscribers: 1, stop: stop}
+	return nil
+}
+
+// unsubscribeResource stops the watch of a resource once it has no subscriber left
+func (s *Server) unsubscribeResource(ctx context.Context, req *mcp.UnsubscribeRequest) error {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	subscription, exists := s.subscriptions[req.Params.URI]
+	if !exists {
+		return nil
+	}
+	subscription.subscribers--
+	if subscription.subscribers == 0 {
+		subscription.stop()
+		delete(s.subscriptions, req.Params.URI)
+	}
+	return nil
+}
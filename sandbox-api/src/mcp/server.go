@@ -2,15 +2,22 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/audit"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
 )
 
 // Server represents the MCP server
@@ -18,6 +25,13 @@ type Server struct {
 	mcpServer *mcp.Server
 	handlers  *Handlers
 	engine    *gin.Engine
+	transport TransportConfig
+
+	subscriptionsMu sync.Mutex
+	subscriptions   map[string]*resourceSubscription
+
+	sessionsMu   sync.Mutex
+	lastActivity map[*mcp.ServerSession]time.Time
 }
 
 // Handlers contains all the handlers used by the MCP server
@@ -25,34 +39,46 @@ type Handlers struct {
 	FileSystem *handler.FileSystemHandler
 	Process    *handler.ProcessHandler
 	Network    *handler.NetworkHandler
+	Git        *handler.GitHandler
+	Codegen    *handler.CodegenHandler
 }
 
 // NewServer creates a new MCP server using the official SDK
 func NewServer(ginEngine *gin.Engine) (*Server, error) {
 	logrus.Info("Creating MCP server")
 
-	// Create MCP server with the official SDK
-	mcpServer := mcp.NewServer(
-		&mcp.Implementation{
-			Name:    "Sandbox API Server",
-			Version: "1.0.0",
-		},
-		nil,
-	)
-
 	// Initialize handlers
+	fsHandler := handler.NewFileSystemHandler()
 	handlers := &Handlers{
-		FileSystem: handler.NewFileSystemHandler(),
+		FileSystem: fsHandler,
 		Process:    handler.NewProcessHandler(),
 		Network:    handler.NewNetworkHandler(),
+		Git:        handler.NewGitHandler(fsHandler),
+		Codegen:    handler.NewCodegenHandler(fsHandler),
 	}
 
 	server := &Server{
-		mcpServer: mcpServer,
-		handlers:  handlers,
-		engine:    ginEngine,
+		handlers:      handlers,
+		engine:        ginEngine,
+		transport:     TransportConfigFromEnv(),
+		subscriptions: make(map[string]*resourceSubscription),
+		lastActivity:  make(map[*mcp.ServerSession]time.Time),
 	}
 
+	// Create MCP server with the official SDK
+	server.mcpServer = mcp.NewServer(
+		&mcp.Implementation{
+			Name:    "Sandbox API Server",
+			Version: "1.0.0",
+		},
+		&mcp.ServerOptions{
+			SubscribeHandler:   server.subscribeResource,
+			UnsubscribeHandler: server.unsubscribeResource,
+		},
+	)
+
+	server.mcpServer.AddReceivingMiddleware(server.trackSessionActivity)
+
 	logrus.Info("Registering tools")
 	// Register all tools
 	if err := server.registerTools(); err != nil {
@@ -61,7 +87,11 @@ func NewServer(ginEngine *gin.Engine) (*Server, error) {
 
 	logrus.Info("Tools registered")
 
-	// Set up HTTP endpoints using the official SDK pattern
+	// Expose the files of the sandbox as resources
+	server.registerResources()
+	logrus.Info("Resources registered")
+
+	// Serve the MCP server over the Streamable HTTP and SSE transports
 	server.setupHTTPEndpoints()
 
 	return server, nil
@@ -73,23 +103,6 @@ func (s *Server) Serve() error {
 	return nil
 }
 
-// setupHTTPEndpoints sets up the HTTP endpoints using the official SDK pattern
-func (s *Server) setupHTTPEndpoints() {
-	// Create the streamable HTTP handler using the official SDK
-	handler := mcp.NewStreamableHTTPHandler(func(req *http.Request) *mcp.Server {
-		// Return the MCP server for each request
-		return s.mcpServer
-	}, nil)
-
-	// Wrap the handler with Gin
-	s.engine.Any("/mcp/*path", gin.WrapH(http.StripPrefix("/mcp", handler)))
-
-	// Also handle the base /mcp endpoint without trailing slash
-	s.engine.Any("/mcp", gin.WrapH(handler))
-
-	logrus.Info("MCP HTTP endpoints configured at /mcp")
-}
-
 // registerTools registers all the tools with the MCP server
 func (s *Server) registerTools() error {
 	// Process tools
@@ -110,24 +123,74 @@ func (s *Server) registerTools() error {
 	}
 	logrus.Info("Codegen tools registered")
 
+	// Git tools
+	if err := s.registerGitTools(); err != nil {
+		return err
+	}
+	logrus.Info("Git tools registered")
+
+	// Network tools
+	if err := s.registerNetworkTools(); err != nil {
+		return err
+	}
+	logrus.Info("Network tools registered")
+
 	return nil
 }
 
-// LogToolCall wraps a tool handler function with logging middleware
+// LogToolCall wraps a tool handler function with logging, tracing and audit middleware
 func LogToolCall[T any, R any](toolName string, handler func(ctx context.Context, req *mcp.CallToolRequest, args T) (*mcp.CallToolResult, R, error)) func(context.Context, *mcp.CallToolRequest, T) (*mcp.CallToolResult, R, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, args T) (*mcp.CallToolResult, R, error) {
 		start := time.Now()
-		logrus.Infof("Tool call started: %s", toolName)
+
+		arguments := audit.SanitizeArguments(args)
+		var header http.Header
+		if req != nil && req.Extra != nil {
+			header = req.Extra.Header
+		}
+
+		// Log the invocation with the ID of the HTTP request carrying it
+		if requestID := header.Get(logging.RequestIDHeader); requestID != "" {
+			ctx = logging.WithRequestID(ctx, requestID)
+		}
+		ctx = logging.WithField(ctx, logging.FieldMCPInvocationID, logging.NewID())
+		logEntry := logging.FromContext(ctx).WithField("tool", toolName)
+		logEntry.Infof("Tool call started: %s", toolName)
+
+		attrs := append(tracing.ArgumentAttributes(arguments), tracing.AttrMCPTool.String(toolName))
+		ctx, span := tracing.StartServer(ctx, propagation.HeaderCarrier(header), "mcp "+toolName, attrs...)
 
 		result, output, err := handler(ctx, req, args)
+		tracing.End(span, err)
 
 		duration := time.Since(start)
+		logEntry = logEntry.WithField("durationMs", duration.Milliseconds())
 		if err != nil {
-			logrus.Errorf("Tool call failed: %s (duration: %v, error: %v)", toolName, duration, err)
+			logEntry.Errorf("Tool call failed: %s (duration: %v, error: %v)", toolName, duration, err)
 		} else {
-			logrus.Infof("Tool call completed: %s (duration: %v)", toolName, duration)
+			logEntry.Infof("Tool call completed: %s (duration: %v)", toolName, duration)
+		}
+
+		entry := audit.Entry{
+			Timestamp:  start.UTC(),
+			Source:     audit.SourceMCP,
+			Operation:  toolName,
+			Arguments:  arguments,
+			Success:    err == nil,
+			DurationMs: duration.Milliseconds(),
 		}
+		if header != nil {
+			entry.Caller = audit.NewCaller(header.Get("X-Forwarded-For"), header)
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		audit.GetLogger().Record(entry)
 
+		// Clients get the message, code and details of errors as JSON, see apierror.Body
+		if err != nil {
+			err = errors.New(apierror.JSON(err, apierror.CodeInternal))
+		}
 		return result, output, err
 	}
 }
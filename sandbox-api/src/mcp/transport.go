@@ -0,0 +1,194 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultPath is the endpoint of the Streamable HTTP transport when MCP_PATH is unset
+	DefaultPath = "/mcp"
+	// DefaultSessionTimeout is the time after which idle sessions are closed when
+	// MCP_SESSION_TIMEOUT is unset
+	DefaultSessionTimeout = time.Hour
+	// sessionSweepInterval is the longest interval at which idle sessions are looked for
+	sessionSweepInterval = time.Minute
+)
+
+// TransportConfig configures the HTTP transports the MCP server is served over
+type TransportConfig struct {
+	// Path is the endpoint of the Streamable HTTP transport
+	Path string
+	// SSEPath is the endpoint of the HTTP+SSE transport, empty when it is disabled
+	SSEPath string
+	// Stateless serves Streamable HTTP requests without sessions
+	Stateless bool
+	// JSONResponse answers Streamable HTTP requests with application/json rather than
+	// an event stream
+	JSONResponse bool
+	// SessionTimeout is the time after which sessions without requests are closed,
+	// zero to keep them open until the client closes them
+	SessionTimeout time.Duration
+}
+
+// TransportConfigFromEnv returns the transport configuration read from MCP_PATH,
+// MCP_SSE_PATH (defaults to the sse endpoint under MCP_PATH, "none" disables the SSE
+// transport), MCP_STATELESS, MCP_JSON_RESPONSE and MCP_SESSION_TIMEOUT (in seconds,
+// zero disables the timeout)
+func TransportConfigFromEnv() TransportConfig {
+	config := TransportConfig{
+		Path:           DefaultPath,
+		SessionTimeout: DefaultSessionTimeout,
+	}
+
+	if value := os.Getenv("MCP_PATH"); value != "" {
+		if path, ok := endpointPath(value); ok {
+			config.Path = path
+		} else {
+			logrus.Warnf("Invalid MCP_PATH value '%s', using default of %s", value, DefaultPath)
+		}
+	}
+	config.SSEPath = config.Path + "/sse"
+	switch value := os.Getenv("MCP_SSE_PATH"); value {
+	case "":
+	case "none":
+		config.SSEPath = ""
+	default:
+		if path, ok := endpointPath(value); ok && path != config.Path {
+			config.SSEPath = path
+		} else {
+			logrus.Warnf("Invalid MCP_SSE_PATH value '%s', using default of %s", value, config.SSEPath)
+		}
+	}
+	config.Stateless = boolFromEnv("MCP_STATELESS")
+	config.JSONResponse = boolFromEnv("MCP_JSON_RESPONSE")
+	if value := os.Getenv("MCP_SESSION_TIMEOUT"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			logrus.Warnf("Invalid MCP_SESSION_TIMEOUT value '%s', using default of %s", value, DefaultSessionTimeout)
+		} else {
+			config.SessionTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+	return config
+}
+
+// endpointPath normalizes an endpoint path, which must be absolute and not the root
+func endpointPath(value string) (string, bool) {
+	path := strings.TrimRight(value, "/")
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, ":*?#") {
+		return "", false
+	}
+	return path, true
+}
+
+// boolFromEnv reads a boolean environment variable, false when unset or invalid
+func boolFromEnv(name string) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Invalid %s value '%s', using default of false", name, value)
+		return false
+	}
+	return enabled
+}
+
+// setupHTTPEndpoints serves the MCP server over Streamable HTTP, and over HTTP+SSE for
+// clients of the previous version of the protocol
+func (s *Server) setupHTTPEndpoints() {
+	getServer := func(req *http.Request) *mcp.Server {
+		return s.mcpServer
+	}
+	streamable := mcp.NewStreamableHTTPHandler(getServer, &mcp.StreamableHTTPOptions{
+		Stateless:    s.transport.Stateless,
+		JSONResponse: s.transport.JSONResponse,
+	})
+
+	var sse http.Handler
+	if s.transport.SSEPath != "" {
+		sse = mcp.NewSSEHandler(getServer, nil)
+	}
+
+	// The SSE endpoint may be under the Streamable HTTP one, so both are routed by path
+	handler := gin.WrapH(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if sse != nil && strings.TrimRight(req.URL.Path, "/") == s.transport.SSEPath {
+			sse.ServeHTTP(w, req)
+			return
+		}
+		streamable.ServeHTTP(w, req)
+	}))
+
+	s.engine.Any(s.transport.Path, handler)
+	s.engine.Any(s.transport.Path+"/*path", handler)
+	logrus.Infof("MCP Streamable HTTP endpoint configured at %s (stateless: %t)", s.transport.Path, s.transport.Stateless)
+
+	if sse != nil {
+		if !strings.HasPrefix(s.transport.SSEPath, s.transport.Path+"/") {
+			s.engine.Any(s.transport.SSEPath, handler)
+		}
+		logrus.Infof("MCP SSE endpoint configured at %s", s.transport.SSEPath)
+	}
+
+	if s.transport.SessionTimeout > 0 {
+		go s.closeIdleSessions(s.transport.SessionTimeout)
+	}
+}
+
+// trackSessionActivity is a receiving middleware recording the time of the last request
+// of each session
+func (s *Server) trackSessionActivity(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if session, ok := req.GetSession().(*mcp.ServerSession); ok && session != nil {
+			s.sessionsMu.Lock()
+			s.lastActivity[session] = time.Now()
+			s.sessionsMu.Unlock()
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// closeIdleSessions periodically closes the sessions without requests for longer than
+// timeout. Sessions are closed by the server as well when their client disconnects.
+func (s *Server) closeIdleSessions(timeout time.Duration) {
+	ticker := time.NewTicker(min(timeout, sessionSweepInterval))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		idle := make([]*mcp.ServerSession, 0)
+
+		s.sessionsMu.Lock()
+		active := make(map[*mcp.ServerSession]time.Time)
+		for session := range s.mcpServer.Sessions() {
+			last, known := s.lastActivity[session]
+			if !known {
+				last = now
+			}
+			if now.Sub(last) > timeout {
+				idle = append(idle, session)
+				continue
+			}
+			active[session] = last
+		}
+		s.lastActivity = active
+		s.sessionsMu.Unlock()
+
+		for _, session := range idle {
+			logrus.Infof("Closing MCP session %s, idle for more than %s", session.ID(), timeout)
+			if err := session.Close(); err != nil {
+				logrus.Debugf("Failed to close MCP session %s: %v", session.ID(), err)
+			}
+		}
+	}
+}
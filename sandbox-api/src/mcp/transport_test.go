@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTransportConfigFromEnv tests the defaults and overrides of the transport configuration
+func TestTransportConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected TransportConfig
+	}{
+		{
+			name:     "defaults",
+			expected: TransportConfig{Path: "/mcp", SSEPath: "/mcp/sse", SessionTimeout: time.Hour},
+		},
+		{
+			name:     "custom paths",
+			env:      map[string]string{"MCP_PATH": "/api/mcp/", "MCP_SSE_PATH": "/sse"},
+			expected: TransportConfig{Path: "/api/mcp", SSEPath: "/sse", SessionTimeout: time.Hour},
+		},
+		{
+			name:     "sse disabled and stateless",
+			env:      map[string]string{"MCP_SSE_PATH": "none", "MCP_STATELESS": "true", "MCP_JSON_RESPONSE": "1", "MCP_SESSION_TIMEOUT": "0"},
+			expected: TransportConfig{Path: "/mcp", Stateless: true, JSONResponse: true},
+		},
+		{
+			name:     "invalid values",
+			env:      map[string]string{"MCP_PATH": "mcp", "MCP_SSE_PATH": "/mcp", "MCP_STATELESS": "maybe", "MCP_SESSION_TIMEOUT": "-1"},
+			expected: TransportConfig{Path: "/mcp", SSEPath: "/mcp/sse", SessionTimeout: time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"MCP_PATH", "MCP_SSE_PATH", "MCP_STATELESS", "MCP_JSON_RESPONSE", "MCP_SESSION_TIMEOUT"} {
+				t.Setenv(name, tt.env[name])
+			}
+			if config := TransportConfigFromEnv(); config != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, config)
+			}
+		})
+	}
+}
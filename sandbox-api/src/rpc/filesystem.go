@@ -0,0 +1,234 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/rpc/sandboxpb"
+	"github.com/blaxel-ai/sandbox-api/src/service"
+)
+
+// watchBufferSize is the number of events of a watch buffered for a client before the
+// watch fails with STREAM_OVERFLOW
+const watchBufferSize = 1024
+
+// filesystemService implements the Filesystem service
+type filesystemService struct {
+	sandboxpb.UnimplementedFilesystemServer
+	handlers *Handlers
+}
+
+// formatPath validates and formats the path of a request
+func formatPath(path string) (string, error) {
+	if path == "" {
+		return "", apierror.Newf(apierror.CodeInvalidRequest, "path is required")
+	}
+	formatted, err := lib.FormatPath(path)
+	if err != nil {
+		return "", apierror.Wrap(apierror.CodeInvalidRequest, err)
+	}
+	return formatted, nil
+}
+
+func (s *filesystemService) ReadFile(ctx context.Context, req *sandboxpb.ReadFileRequest) (*sandboxpb.File, error) {
+	path, err := formatPath(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.handlers.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sandboxpb.File{
+		Path:         file.Path,
+		Name:         filepath.Base(file.Path),
+		Permissions:  fmt.Sprintf("%o", file.Permissions),
+		Size:         file.Size,
+		LastModified: timestamppb.New(file.LastModified),
+		Owner:        file.Owner,
+		Group:        file.Group,
+		Content:      file.Content,
+		Etag:         filesystem.ContentETag(file.Content),
+	}, nil
+}
+
+func (s *filesystemService) WriteFile(ctx context.Context, req *sandboxpb.WriteFileRequest) (*sandboxpb.WriteFileResponse, error) {
+	path, err := formatPath(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := service.ParsePermissions(req.GetPermissions(), false)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.handlers.FileSystem.Write(service.WriteRequest{
+		Path:        path,
+		Content:     req.GetContent(),
+		Permissions: permissions,
+		Append:      req.GetAppend(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sandboxpb.WriteFileResponse{Path: result.Path, Etag: result.ETag}, nil
+}
+
+func (s *filesystemService) ListDirectory(ctx context.Context, req *sandboxpb.ListDirectoryRequest) (*sandboxpb.Directory, error) {
+	path, err := formatPath(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	dir, err := s.handlers.FileSystem.ListDirectory(path)
+	if err != nil {
+		return nil, err
+	}
+	response := &sandboxpb.Directory{
+		Path:           dir.Path,
+		Name:           dir.Name,
+		Files:          make([]*sandboxpb.FileInfo, 0, len(dir.Files)),
+		Subdirectories: make([]*sandboxpb.Subdirectory, 0, len(dir.Subdirectories)),
+	}
+	for _, file := range dir.Files {
+		response.Files = append(response.Files, &sandboxpb.FileInfo{
+			Path:         file.Path,
+			Name:         file.Name,
+			Permissions:  file.Permissions,
+			Size:         file.Size,
+			LastModified: timestamppb.New(file.LastModified),
+			Owner:        file.Owner,
+			Group:        file.Group,
+		})
+	}
+	for _, subdirectory := range dir.Subdirectories {
+		response.Subdirectories = append(response.Subdirectories, &sandboxpb.Subdirectory{
+			Path: subdirectory.Path,
+			Name: subdirectory.Name,
+		})
+	}
+	return response, nil
+}
+
+func (s *filesystemService) CreateDirectory(ctx context.Context, req *sandboxpb.CreateDirectoryRequest) (*sandboxpb.CreateDirectoryResponse, error) {
+	path, err := formatPath(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := service.ParsePermissions(req.GetPermissions(), true)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.handlers.FileSystem.Write(service.WriteRequest{Path: path, Permissions: permissions, IsDirectory: true})
+	if err != nil {
+		return nil, err
+	}
+	return &sandboxpb.CreateDirectoryResponse{Path: result.Path}, nil
+}
+
+func (s *filesystemService) Delete(ctx context.Context, req *sandboxpb.DeleteRequest) (*sandboxpb.DeleteResponse, error) {
+	path, err := formatPath(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.handlers.FileSystem.Delete(path, req.GetRecursive(), ""); err != nil {
+		return nil, err
+	}
+	return &sandboxpb.DeleteResponse{Path: path}, nil
+}
+
+// Watch streams the events of a directory. Events the client does not keep up with
+// are buffered up to watchBufferSize, then the watch fails with STREAM_OVERFLOW.
+func (s *filesystemService) Watch(req *sandboxpb.WatchRequest, stream sandboxpb.Filesystem_WatchServer) error {
+	path, err := formatPath(req.GetPath())
+	if err != nil {
+		return err
+	}
+	isDir, err := s.handlers.FileSystem.DirectoryExists(path)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return apierror.Newf(apierror.CodeFSNotADirectory, "path is not a directory")
+	}
+	debounce, err := handler.WatchDebounce(int(req.GetDebounceMs()), false)
+	if err != nil {
+		return err
+	}
+
+	release, err := streamlimit.Watchers().Acquire(peerAddress(stream.Context()))
+	if err != nil {
+		return err
+	}
+	defer release()
+	metrics.ActiveWatchers.Inc()
+	defer metrics.ActiveWatchers.Dec()
+
+	events := make(chan handler.FileEvent, watchBufferSize)
+	overflow := make(chan struct{})
+	push := func(event handler.FileEvent) {
+		select {
+		case events <- event:
+		default:
+			select {
+			case <-overflow:
+			default:
+				close(overflow)
+			}
+		}
+	}
+	var stop func()
+	if debounce == 0 {
+		stop, err = s.handlers.FileSystem.WatchDirectory(path, req.GetRecursive(), req.GetIgnore(), req.GetGitignore(), push)
+	} else {
+		stop, err = s.handlers.FileSystem.WatchDirectoryBatched(path, req.GetRecursive(), req.GetIgnore(), req.GetGitignore(), debounce, func(batch []handler.FileEvent) {
+			for _, event := range batch {
+				push(event)
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-overflow:
+			return apierror.Newf(apierror.CodeStreamOverflow, "more than %d events were not received in time", watchBufferSize)
+		case event := <-events:
+			if err := stream.Send(newWatchEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newWatchEvent converts a file event to its message
+func newWatchEvent(event handler.FileEvent) *sandboxpb.WatchEvent {
+	message := &sandboxpb.WatchEvent{
+		Op:    event.Op,
+		Name:  event.Name,
+		Path:  event.Path,
+		Size:  event.Size,
+		IsDir: event.IsDir,
+		From:  event.From,
+		To:    event.To,
+	}
+	if event.Error != nil {
+		message.Error = *event.Error
+	}
+	if event.ModTime != nil {
+		message.ModTime = timestamppb.New(*event.ModTime)
+	}
+	return message
+}
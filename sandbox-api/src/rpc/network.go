@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/network"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/rpc/sandboxpb"
+)
+
+// portEventBufferSize is the number of port events buffered for a client before the
+// stream fails with STREAM_OVERFLOW
+const portEventBufferSize = 256
+
+// networkService implements the Network service
+type networkService struct {
+	sandboxpb.UnimplementedNetworkServer
+	handlers *Handlers
+}
+
+func (s *networkService) ListPorts(ctx context.Context, req *sandboxpb.ListPortsRequest) (*sandboxpb.ListPortsResponse, error) {
+	if req.GetPid() <= 0 {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "invalid PID")
+	}
+	ports, err := s.handlers.Network.GetPortsForPID(int(req.GetPid()))
+	if err != nil {
+		return nil, err
+	}
+	response := &sandboxpb.ListPortsResponse{Ports: make([]*sandboxpb.Port, 0, len(ports))}
+	for _, port := range ports {
+		response.Ports = append(response.Ports, newPort(port))
+	}
+	return response, nil
+}
+
+// WatchPorts streams the ports opened and closed by a process, starting with the ports
+// already open as port-open events
+func (s *networkService) WatchPorts(req *sandboxpb.WatchPortsRequest, stream sandboxpb.Network_WatchPortsServer) error {
+	if req.GetPid() <= 0 {
+		return apierror.Newf(apierror.CodeInvalidRequest, "invalid PID")
+	}
+	pid := int(req.GetPid())
+
+	// Listing the ports first caches them, so that only changes are sent as events
+	ports, err := s.handlers.Network.GetPortsForPID(pid)
+	if err != nil {
+		return err
+	}
+
+	events := make(chan handler.PortEvent, portEventBufferSize)
+	overflow := make(chan struct{})
+	stop := s.handlers.Network.SubscribePortEvents(pid, func(event handler.PortEvent) {
+		select {
+		case events <- event:
+		default:
+			select {
+			case <-overflow:
+			default:
+				close(overflow)
+			}
+		}
+	})
+	defer stop()
+
+	for _, port := range ports {
+		if err := stream.Send(&sandboxpb.PortEvent{Pid: req.GetPid(), Event: network.PortOpened, Port: newPort(port)}); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-overflow:
+			return apierror.Newf(apierror.CodeStreamOverflow, "more than %d port events were not received in time", portEventBufferSize)
+		case event := <-events:
+			if err := stream.Send(&sandboxpb.PortEvent{Pid: int32(event.PID), Event: event.Event, Port: newPort(event.Port)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newPort converts a port to its message
+func newPort(port *network.PortInfo) *sandboxpb.Port {
+	if port == nil {
+		return nil
+	}
+	return &sandboxpb.Port{
+		Pid:         int32(port.PID),
+		Protocol:    port.Protocol,
+		LocalAddr:   port.LocalAddr,
+		LocalPort:   int32(port.LocalPort),
+		RemoteAddr:  port.RemoteAddr,
+		RemotePort:  int32(port.RemotePort),
+		State:       port.State,
+		ProcessName: port.ProcessName,
+	}
+}
@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/rpc/sandboxpb"
+	"github.com/blaxel-ai/sandbox-api/src/service"
+)
+
+// processService implements the Process service
+type processService struct {
+	sandboxpb.UnimplementedProcessServer
+	handlers *Handlers
+}
+
+// Start starts a process like POST /process
+func (s *processService) Start(ctx context.Context, req *sandboxpb.StartProcessRequest) (*sandboxpb.ProcessInfo, error) {
+	waitForPorts := make([]int, 0, len(req.GetWaitForPorts()))
+	for _, port := range req.GetWaitForPorts() {
+		waitForPorts = append(waitForPorts, int(port))
+	}
+
+	response, err := s.handlers.Process.StartProcess(ctx, service.StartRequest{
+		Command:           req.GetCommand(),
+		Name:              req.GetName(),
+		WorkingDir:        req.GetWorkingDir(),
+		Env:               req.GetEnv(),
+		RunAs:             lib.RunAs{User: req.GetRunAsUser(), Group: req.GetRunAsGroup()},
+		WaitForCompletion: req.GetWaitForCompletion(),
+		Timeout:           int(req.GetTimeout()),
+		WaitForPorts:      waitForPorts,
+		WaitForLogPattern: req.GetWaitForLogPattern(),
+		RestartPolicy:     req.GetRestartPolicy(),
+		MaxRestarts:       int(req.GetMaxRestarts()),
+		LogToFile:         req.GetLogToFile(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newProcessInfo(response), nil
+}
+
+func (s *processService) List(ctx context.Context, req *sandboxpb.ListProcessesRequest) (*sandboxpb.ListProcessesResponse, error) {
+	processes := s.handlers.Process.ListProcesses()
+	response := &sandboxpb.ListProcessesResponse{Processes: make([]*sandboxpb.ProcessInfo, 0, len(processes))}
+	for _, p := range processes {
+		response.Processes = append(response.Processes, newProcessInfo(p))
+	}
+	return response, nil
+}
+
+func (s *processService) Get(ctx context.Context, req *sandboxpb.ProcessIdentifier) (*sandboxpb.ProcessInfo, error) {
+	if err := requireIdentifier(req.GetIdentifier()); err != nil {
+		return nil, err
+	}
+	response, err := s.handlers.Process.GetProcess(req.GetIdentifier())
+	if err != nil {
+		return nil, err
+	}
+	return newProcessInfo(response), nil
+}
+
+func (s *processService) Stop(ctx context.Context, req *sandboxpb.ProcessIdentifier) (*sandboxpb.ProcessInfo, error) {
+	if err := requireIdentifier(req.GetIdentifier()); err != nil {
+		return nil, err
+	}
+	if err := s.handlers.Process.StopProcess(req.GetIdentifier()); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, req)
+}
+
+func (s *processService) Kill(ctx context.Context, req *sandboxpb.ProcessIdentifier) (*sandboxpb.ProcessInfo, error) {
+	if err := requireIdentifier(req.GetIdentifier()); err != nil {
+		return nil, err
+	}
+	if err := s.handlers.Process.KillProcess(req.GetIdentifier()); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, req)
+}
+
+func (s *processService) GetLogs(ctx context.Context, req *sandboxpb.ProcessIdentifier) (*sandboxpb.ProcessLogs, error) {
+	if err := requireIdentifier(req.GetIdentifier()); err != nil {
+		return nil, err
+	}
+	logs, err := s.handlers.Process.GetProcessOutput(req.GetIdentifier())
+	if err != nil {
+		return nil, err
+	}
+	return &sandboxpb.ProcessLogs{
+		Stdout:       logs.Stdout,
+		Stderr:       logs.Stderr,
+		Logs:         logs.Logs,
+		Truncated:    logs.Truncated,
+		DroppedBytes: logs.DroppedBytes,
+	}, nil
+}
+
+// StreamLogs streams the output of a process as it is written, from the buffered output
+// or the seq of the last chunk received, until the process terminates. Output dropped
+// from the buffer before it was sent is reported in the missed bytes of a chunk.
+func (s *processService) StreamLogs(req *sandboxpb.StreamLogsRequest, stream sandboxpb.Process_StreamLogsServer) error {
+	if err := requireIdentifier(req.GetIdentifier()); err != nil {
+		return err
+	}
+	if req.GetStream() != "" && req.GetStream() != "stdout" && req.GetStream() != "stderr" {
+		return apierror.Newf(apierror.CodeInvalidRequest, "invalid stream: must be 'stdout' or 'stderr'")
+	}
+	from := int64(-1)
+	if req.From != nil {
+		if req.GetFrom() < 0 {
+			return apierror.Newf(apierror.CodeInvalidRequest, "invalid from: must not be negative")
+		}
+		from = req.GetFrom()
+	}
+	if _, err := s.handlers.Process.GetProcess(req.GetIdentifier()); err != nil {
+		return err
+	}
+
+	release, err := streamlimit.LogStreams().Acquire(peerAddress(stream.Context()))
+	if err != nil {
+		return err
+	}
+	defer release()
+	metrics.ActiveLogStreams.Inc()
+	defer metrics.ActiveLogStreams.Dec()
+
+	// Chunks are sent from the goroutine of the follower, which waits for the client
+	ctx := stream.Context()
+	chunks := make(chan process.OutputChunk)
+	stop, err := s.handlers.Process.FollowProcessOutput(req.GetIdentifier(), req.GetStream(), from, func(chunk process.OutputChunk) {
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case chunk := <-chunks:
+			if err := stream.Send(&sandboxpb.LogChunk{Logs: chunk.Logs, Seq: chunk.NextOffset, Missed: chunk.Missed, Done: chunk.Done}); err != nil {
+				return err
+			}
+			if chunk.Done {
+				return nil
+			}
+		}
+	}
+}
+
+// requireIdentifier checks that a process identifier was given
+func requireIdentifier(identifier string) error {
+	if identifier == "" {
+		return apierror.Newf(apierror.CodeInvalidRequest, "identifier is required")
+	}
+	return nil
+}
+
+// newProcessInfo converts a process to its message
+func newProcessInfo(p handler.ProcessResponse) *sandboxpb.ProcessInfo {
+	info := &sandboxpb.ProcessInfo{
+		Pid:           p.PID,
+		Name:          p.Name,
+		Command:       p.Command,
+		Status:        p.Status,
+		StartedAt:     p.StartedAt,
+		ExitCode:      int32(p.ExitCode),
+		WorkingDir:    p.WorkingDir,
+		RunAsUser:     p.RunAsUser,
+		RunAsGroup:    p.RunAsGroup,
+		Timeout:       int32(p.Timeout),
+		RestartPolicy: p.RestartPolicy,
+		MaxRestarts:   int32(p.MaxRestarts),
+		RestartCount:  int32(p.RestartCount),
+	}
+	if p.CompletedAt != nil {
+		info.CompletedAt = *p.CompletedAt
+	}
+	if p.Logs != nil {
+		info.Logs = *p.Logs
+	}
+	return info
+}
@@ -0,0 +1,814 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: sandbox/v1/process.proto
+
+package sandboxpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartProcessRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Command    string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Name       string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	WorkingDir string                 `protobuf:"bytes,3,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	Env        map[string]string      `protobuf:"bytes,4,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// RunAsUser and RunAsGroup are the user and group, by name or id, the process runs as
+	RunAsUser         string `protobuf:"bytes,5,opt,name=run_as_user,json=runAsUser,proto3" json:"run_as_user,omitempty"`
+	RunAsGroup        string `protobuf:"bytes,6,opt,name=run_as_group,json=runAsGroup,proto3" json:"run_as_group,omitempty"`
+	WaitForCompletion bool   `protobuf:"varint,7,opt,name=wait_for_completion,json=waitForCompletion,proto3" json:"wait_for_completion,omitempty"`
+	// Timeout is the number of seconds after which the process is killed, 0 for none
+	Timeout           int32   `protobuf:"varint,8,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	WaitForPorts      []int32 `protobuf:"varint,9,rep,packed,name=wait_for_ports,json=waitForPorts,proto3" json:"wait_for_ports,omitempty"`
+	WaitForLogPattern string  `protobuf:"bytes,10,opt,name=wait_for_log_pattern,json=waitForLogPattern,proto3" json:"wait_for_log_pattern,omitempty"`
+	// RestartPolicy is never, on-failure or always
+	RestartPolicy string `protobuf:"bytes,11,opt,name=restart_policy,json=restartPolicy,proto3" json:"restart_policy,omitempty"`
+	MaxRestarts   int32  `protobuf:"varint,12,opt,name=max_restarts,json=maxRestarts,proto3" json:"max_restarts,omitempty"`
+	LogToFile     bool   `protobuf:"varint,13,opt,name=log_to_file,json=logToFile,proto3" json:"log_to_file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartProcessRequest) Reset() {
+	*x = StartProcessRequest{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartProcessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartProcessRequest) ProtoMessage() {}
+
+func (x *StartProcessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartProcessRequest.ProtoReflect.Descriptor instead.
+func (*StartProcessRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartProcessRequest) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *StartProcessRequest) GetRunAsUser() string {
+	if x != nil {
+		return x.RunAsUser
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetRunAsGroup() string {
+	if x != nil {
+		return x.RunAsGroup
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetWaitForCompletion() bool {
+	if x != nil {
+		return x.WaitForCompletion
+	}
+	return false
+}
+
+func (x *StartProcessRequest) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+func (x *StartProcessRequest) GetWaitForPorts() []int32 {
+	if x != nil {
+		return x.WaitForPorts
+	}
+	return nil
+}
+
+func (x *StartProcessRequest) GetWaitForLogPattern() string {
+	if x != nil {
+		return x.WaitForLogPattern
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetRestartPolicy() string {
+	if x != nil {
+		return x.RestartPolicy
+	}
+	return ""
+}
+
+func (x *StartProcessRequest) GetMaxRestarts() int32 {
+	if x != nil {
+		return x.MaxRestarts
+	}
+	return 0
+}
+
+func (x *StartProcessRequest) GetLogToFile() bool {
+	if x != nil {
+		return x.LogToFile
+	}
+	return false
+}
+
+type ProcessIdentifier struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifier is the PID or the name of the process
+	Identifier    string `protobuf:"bytes,1,opt,name=identifier,proto3" json:"identifier,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessIdentifier) Reset() {
+	*x = ProcessIdentifier{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessIdentifier) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessIdentifier) ProtoMessage() {}
+
+func (x *ProcessIdentifier) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessIdentifier.ProtoReflect.Descriptor instead.
+func (*ProcessIdentifier) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProcessIdentifier) GetIdentifier() string {
+	if x != nil {
+		return x.Identifier
+	}
+	return ""
+}
+
+type ListProcessesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProcessesRequest) Reset() {
+	*x = ListProcessesRequest{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProcessesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProcessesRequest) ProtoMessage() {}
+
+func (x *ListProcessesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProcessesRequest.ProtoReflect.Descriptor instead.
+func (*ListProcessesRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{2}
+}
+
+type ListProcessesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Processes     []*ProcessInfo         `protobuf:"bytes,1,rep,name=processes,proto3" json:"processes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProcessesResponse) Reset() {
+	*x = ListProcessesResponse{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProcessesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProcessesResponse) ProtoMessage() {}
+
+func (x *ListProcessesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProcessesResponse.ProtoReflect.Descriptor instead.
+func (*ListProcessesResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListProcessesResponse) GetProcesses() []*ProcessInfo {
+	if x != nil {
+		return x.Processes
+	}
+	return nil
+}
+
+type ProcessInfo struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Pid     string                 `protobuf:"bytes,1,opt,name=pid,proto3" json:"pid,omitempty"`
+	Name    string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Command string                 `protobuf:"bytes,3,opt,name=command,proto3" json:"command,omitempty"`
+	// Status is running, completed, failed, killed, stopped or timedout
+	Status        string `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	StartedAt     string `protobuf:"bytes,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	CompletedAt   string `protobuf:"bytes,6,opt,name=completed_at,json=completedAt,proto3" json:"completed_at,omitempty"`
+	ExitCode      int32  `protobuf:"varint,7,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	WorkingDir    string `protobuf:"bytes,8,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	RunAsUser     string `protobuf:"bytes,9,opt,name=run_as_user,json=runAsUser,proto3" json:"run_as_user,omitempty"`
+	RunAsGroup    string `protobuf:"bytes,10,opt,name=run_as_group,json=runAsGroup,proto3" json:"run_as_group,omitempty"`
+	Timeout       int32  `protobuf:"varint,11,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	RestartPolicy string `protobuf:"bytes,12,opt,name=restart_policy,json=restartPolicy,proto3" json:"restart_policy,omitempty"`
+	MaxRestarts   int32  `protobuf:"varint,13,opt,name=max_restarts,json=maxRestarts,proto3" json:"max_restarts,omitempty"`
+	RestartCount  int32  `protobuf:"varint,14,opt,name=restart_count,json=restartCount,proto3" json:"restart_count,omitempty"`
+	// Logs is the output of a process started with wait_for_completion
+	Logs          string `protobuf:"bytes,15,opt,name=logs,proto3" json:"logs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessInfo) Reset() {
+	*x = ProcessInfo{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessInfo) ProtoMessage() {}
+
+func (x *ProcessInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessInfo.ProtoReflect.Descriptor instead.
+func (*ProcessInfo) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ProcessInfo) GetPid() string {
+	if x != nil {
+		return x.Pid
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetStartedAt() string {
+	if x != nil {
+		return x.StartedAt
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetCompletedAt() string {
+	if x != nil {
+		return x.CompletedAt
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetRunAsUser() string {
+	if x != nil {
+		return x.RunAsUser
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetRunAsGroup() string {
+	if x != nil {
+		return x.RunAsGroup
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetRestartPolicy() string {
+	if x != nil {
+		return x.RestartPolicy
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetMaxRestarts() int32 {
+	if x != nil {
+		return x.MaxRestarts
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetRestartCount() int32 {
+	if x != nil {
+		return x.RestartCount
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetLogs() string {
+	if x != nil {
+		return x.Logs
+	}
+	return ""
+}
+
+type ProcessLogs struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Stdout string                 `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr string                 `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	Logs   string                 `protobuf:"bytes,3,opt,name=logs,proto3" json:"logs,omitempty"`
+	// Truncated is true when older output was dropped from any of the buffers
+	Truncated     bool  `protobuf:"varint,4,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	DroppedBytes  int64 `protobuf:"varint,5,opt,name=dropped_bytes,json=droppedBytes,proto3" json:"dropped_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessLogs) Reset() {
+	*x = ProcessLogs{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessLogs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessLogs) ProtoMessage() {}
+
+func (x *ProcessLogs) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessLogs.ProtoReflect.Descriptor instead.
+func (*ProcessLogs) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProcessLogs) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *ProcessLogs) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+func (x *ProcessLogs) GetLogs() string {
+	if x != nil {
+		return x.Logs
+	}
+	return ""
+}
+
+func (x *ProcessLogs) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *ProcessLogs) GetDroppedBytes() int64 {
+	if x != nil {
+		return x.DroppedBytes
+	}
+	return 0
+}
+
+type StreamLogsRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Identifier string                 `protobuf:"bytes,1,opt,name=identifier,proto3" json:"identifier,omitempty"`
+	// Stream is stdout or stderr, empty for the combined output
+	Stream string `protobuf:"bytes,2,opt,name=stream,proto3" json:"stream,omitempty"`
+	// From is the seq of the last chunk received, to resume a stream, unset to start
+	// with the buffered output
+	From          *int64 `protobuf:"varint,3,opt,name=from,proto3,oneof" json:"from,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamLogsRequest) Reset() {
+	*x = StreamLogsRequest{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamLogsRequest) ProtoMessage() {}
+
+func (x *StreamLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamLogsRequest.ProtoReflect.Descriptor instead.
+func (*StreamLogsRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamLogsRequest) GetIdentifier() string {
+	if x != nil {
+		return x.Identifier
+	}
+	return ""
+}
+
+func (x *StreamLogsRequest) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *StreamLogsRequest) GetFrom() int64 {
+	if x != nil && x.From != nil {
+		return *x.From
+	}
+	return 0
+}
+
+// LogChunk is output of a process. Seq is the number of bytes written to the stream
+// up to the end of logs. Missed counts the bytes dropped from the log buffer before
+// they could be sent, and done is set on the last chunk, once the process has
+// terminated.
+type LogChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Logs          string                 `protobuf:"bytes,1,opt,name=logs,proto3" json:"logs,omitempty"`
+	Seq           int64                  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Missed        int64                  `protobuf:"varint,3,opt,name=missed,proto3" json:"missed,omitempty"`
+	Done          bool                   `protobuf:"varint,4,opt,name=done,proto3" json:"done,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogChunk) Reset() {
+	*x = LogChunk{}
+	mi := &file_sandbox_v1_process_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogChunk) ProtoMessage() {}
+
+func (x *LogChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_process_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogChunk.ProtoReflect.Descriptor instead.
+func (*LogChunk) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_process_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *LogChunk) GetLogs() string {
+	if x != nil {
+		return x.Logs
+	}
+	return ""
+}
+
+func (x *LogChunk) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *LogChunk) GetMissed() int64 {
+	if x != nil {
+		return x.Missed
+	}
+	return 0
+}
+
+func (x *LogChunk) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+var File_sandbox_v1_process_proto protoreflect.FileDescriptor
+
+const file_sandbox_v1_process_proto_rawDesc = "" +
+	"\n" +
+	"\x18sandbox/v1/process.proto\x12\n" +
+	"sandbox.v1\"\xa5\x04\n" +
+	"\x13StartProcessRequest\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1f\n" +
+	"\vworking_dir\x18\x03 \x01(\tR\n" +
+	"workingDir\x12:\n" +
+	"\x03env\x18\x04 \x03(\v2(.sandbox.v1.StartProcessRequest.EnvEntryR\x03env\x12\x1e\n" +
+	"\vrun_as_user\x18\x05 \x01(\tR\trunAsUser\x12 \n" +
+	"\frun_as_group\x18\x06 \x01(\tR\n" +
+	"runAsGroup\x12.\n" +
+	"\x13wait_for_completion\x18\a \x01(\bR\x11waitForCompletion\x12\x18\n" +
+	"\atimeout\x18\b \x01(\x05R\atimeout\x12$\n" +
+	"\x0ewait_for_ports\x18\t \x03(\x05R\fwaitForPorts\x12/\n" +
+	"\x14wait_for_log_pattern\x18\n" +
+	" \x01(\tR\x11waitForLogPattern\x12%\n" +
+	"\x0erestart_policy\x18\v \x01(\tR\rrestartPolicy\x12!\n" +
+	"\fmax_restarts\x18\f \x01(\x05R\vmaxRestarts\x12\x1e\n" +
+	"\vlog_to_file\x18\r \x01(\bR\tlogToFile\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"3\n" +
+	"\x11ProcessIdentifier\x12\x1e\n" +
+	"\n" +
+	"identifier\x18\x01 \x01(\tR\n" +
+	"identifier\"\x16\n" +
+	"\x14ListProcessesRequest\"N\n" +
+	"\x15ListProcessesResponse\x125\n" +
+	"\tprocesses\x18\x01 \x03(\v2\x17.sandbox.v1.ProcessInfoR\tprocesses\"\xc4\x03\n" +
+	"\vProcessInfo\x12\x10\n" +
+	"\x03pid\x18\x01 \x01(\tR\x03pid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\acommand\x18\x03 \x01(\tR\acommand\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\tR\tstartedAt\x12!\n" +
+	"\fcompleted_at\x18\x06 \x01(\tR\vcompletedAt\x12\x1b\n" +
+	"\texit_code\x18\a \x01(\x05R\bexitCode\x12\x1f\n" +
+	"\vworking_dir\x18\b \x01(\tR\n" +
+	"workingDir\x12\x1e\n" +
+	"\vrun_as_user\x18\t \x01(\tR\trunAsUser\x12 \n" +
+	"\frun_as_group\x18\n" +
+	" \x01(\tR\n" +
+	"runAsGroup\x12\x18\n" +
+	"\atimeout\x18\v \x01(\x05R\atimeout\x12%\n" +
+	"\x0erestart_policy\x18\f \x01(\tR\rrestartPolicy\x12!\n" +
+	"\fmax_restarts\x18\r \x01(\x05R\vmaxRestarts\x12#\n" +
+	"\rrestart_count\x18\x0e \x01(\x05R\frestartCount\x12\x12\n" +
+	"\x04logs\x18\x0f \x01(\tR\x04logs\"\x94\x01\n" +
+	"\vProcessLogs\x12\x16\n" +
+	"\x06stdout\x18\x01 \x01(\tR\x06stdout\x12\x16\n" +
+	"\x06stderr\x18\x02 \x01(\tR\x06stderr\x12\x12\n" +
+	"\x04logs\x18\x03 \x01(\tR\x04logs\x12\x1c\n" +
+	"\ttruncated\x18\x04 \x01(\bR\ttruncated\x12#\n" +
+	"\rdropped_bytes\x18\x05 \x01(\x03R\fdroppedBytes\"m\n" +
+	"\x11StreamLogsRequest\x12\x1e\n" +
+	"\n" +
+	"identifier\x18\x01 \x01(\tR\n" +
+	"identifier\x12\x16\n" +
+	"\x06stream\x18\x02 \x01(\tR\x06stream\x12\x17\n" +
+	"\x04from\x18\x03 \x01(\x03H\x00R\x04from\x88\x01\x01B\a\n" +
+	"\x05_from\"\\\n" +
+	"\bLogChunk\x12\x12\n" +
+	"\x04logs\x18\x01 \x01(\tR\x04logs\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x03R\x03seq\x12\x16\n" +
+	"\x06missed\x18\x03 \x01(\x03R\x06missed\x12\x12\n" +
+	"\x04done\x18\x04 \x01(\bR\x04done2\xe0\x03\n" +
+	"\aProcess\x12A\n" +
+	"\x05Start\x12\x1f.sandbox.v1.StartProcessRequest\x1a\x17.sandbox.v1.ProcessInfo\x12K\n" +
+	"\x04List\x12 .sandbox.v1.ListProcessesRequest\x1a!.sandbox.v1.ListProcessesResponse\x12=\n" +
+	"\x03Get\x12\x1d.sandbox.v1.ProcessIdentifier\x1a\x17.sandbox.v1.ProcessInfo\x12>\n" +
+	"\x04Stop\x12\x1d.sandbox.v1.ProcessIdentifier\x1a\x17.sandbox.v1.ProcessInfo\x12>\n" +
+	"\x04Kill\x12\x1d.sandbox.v1.ProcessIdentifier\x1a\x17.sandbox.v1.ProcessInfo\x12A\n" +
+	"\aGetLogs\x12\x1d.sandbox.v1.ProcessIdentifier\x1a\x17.sandbox.v1.ProcessLogs\x12C\n" +
+	"\n" +
+	"StreamLogs\x12\x1d.sandbox.v1.StreamLogsRequest\x1a\x14.sandbox.v1.LogChunk0\x01B4Z2github.com/blaxel-ai/sandbox-api/src/rpc/sandboxpbb\x06proto3"
+
+var (
+	file_sandbox_v1_process_proto_rawDescOnce sync.Once
+	file_sandbox_v1_process_proto_rawDescData []byte
+)
+
+func file_sandbox_v1_process_proto_rawDescGZIP() []byte {
+	file_sandbox_v1_process_proto_rawDescOnce.Do(func() {
+		file_sandbox_v1_process_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sandbox_v1_process_proto_rawDesc), len(file_sandbox_v1_process_proto_rawDesc)))
+	})
+	return file_sandbox_v1_process_proto_rawDescData
+}
+
+var file_sandbox_v1_process_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_sandbox_v1_process_proto_goTypes = []any{
+	(*StartProcessRequest)(nil),   // 0: sandbox.v1.StartProcessRequest
+	(*ProcessIdentifier)(nil),     // 1: sandbox.v1.ProcessIdentifier
+	(*ListProcessesRequest)(nil),  // 2: sandbox.v1.ListProcessesRequest
+	(*ListProcessesResponse)(nil), // 3: sandbox.v1.ListProcessesResponse
+	(*ProcessInfo)(nil),           // 4: sandbox.v1.ProcessInfo
+	(*ProcessLogs)(nil),           // 5: sandbox.v1.ProcessLogs
+	(*StreamLogsRequest)(nil),     // 6: sandbox.v1.StreamLogsRequest
+	(*LogChunk)(nil),              // 7: sandbox.v1.LogChunk
+	nil,                           // 8: sandbox.v1.StartProcessRequest.EnvEntry
+}
+var file_sandbox_v1_process_proto_depIdxs = []int32{
+	8, // 0: sandbox.v1.StartProcessRequest.env:type_name -> sandbox.v1.StartProcessRequest.EnvEntry
+	4, // 1: sandbox.v1.ListProcessesResponse.processes:type_name -> sandbox.v1.ProcessInfo
+	0, // 2: sandbox.v1.Process.Start:input_type -> sandbox.v1.StartProcessRequest
+	2, // 3: sandbox.v1.Process.List:input_type -> sandbox.v1.ListProcessesRequest
+	1, // 4: sandbox.v1.Process.Get:input_type -> sandbox.v1.ProcessIdentifier
+	1, // 5: sandbox.v1.Process.Stop:input_type -> sandbox.v1.ProcessIdentifier
+	1, // 6: sandbox.v1.Process.Kill:input_type -> sandbox.v1.ProcessIdentifier
+	1, // 7: sandbox.v1.Process.GetLogs:input_type -> sandbox.v1.ProcessIdentifier
+	6, // 8: sandbox.v1.Process.StreamLogs:input_type -> sandbox.v1.StreamLogsRequest
+	4, // 9: sandbox.v1.Process.Start:output_type -> sandbox.v1.ProcessInfo
+	3, // 10: sandbox.v1.Process.List:output_type -> sandbox.v1.ListProcessesResponse
+	4, // 11: sandbox.v1.Process.Get:output_type -> sandbox.v1.ProcessInfo
+	4, // 12: sandbox.v1.Process.Stop:output_type -> sandbox.v1.ProcessInfo
+	4, // 13: sandbox.v1.Process.Kill:output_type -> sandbox.v1.ProcessInfo
+	5, // 14: sandbox.v1.Process.GetLogs:output_type -> sandbox.v1.ProcessLogs
+	7, // 15: sandbox.v1.Process.StreamLogs:output_type -> sandbox.v1.LogChunk
+	9, // [9:16] is the sub-list for method output_type
+	2, // [2:9] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_sandbox_v1_process_proto_init() }
+func file_sandbox_v1_process_proto_init() {
+	if File_sandbox_v1_process_proto != nil {
+		return
+	}
+	file_sandbox_v1_process_proto_msgTypes[6].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sandbox_v1_process_proto_rawDesc), len(file_sandbox_v1_process_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sandbox_v1_process_proto_goTypes,
+		DependencyIndexes: file_sandbox_v1_process_proto_depIdxs,
+		MessageInfos:      file_sandbox_v1_process_proto_msgTypes,
+	}.Build()
+	File_sandbox_v1_process_proto = out.File
+	file_sandbox_v1_process_proto_goTypes = nil
+	file_sandbox_v1_process_proto_depIdxs = nil
+}
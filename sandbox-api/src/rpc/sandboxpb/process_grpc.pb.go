@@ -0,0 +1,375 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sandbox/v1/process.proto
+
+package sandboxpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Process_Start_FullMethodName      = "/sandbox.v1.Process/Start"
+	Process_List_FullMethodName       = "/sandbox.v1.Process/List"
+	Process_Get_FullMethodName        = "/sandbox.v1.Process/Get"
+	Process_Stop_FullMethodName       = "/sandbox.v1.Process/Stop"
+	Process_Kill_FullMethodName       = "/sandbox.v1.Process/Kill"
+	Process_GetLogs_FullMethodName    = "/sandbox.v1.Process/GetLogs"
+	Process_StreamLogs_FullMethodName = "/sandbox.v1.Process/StreamLogs"
+)
+
+// ProcessClient is the client API for Process service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Process runs and manages the processes of the sandbox, like the /process routes of
+// the REST API
+type ProcessClient interface {
+	// Start starts a process, and waits for its completion with wait_for_completion
+	Start(ctx context.Context, in *StartProcessRequest, opts ...grpc.CallOption) (*ProcessInfo, error)
+	// List lists the processes
+	List(ctx context.Context, in *ListProcessesRequest, opts ...grpc.CallOption) (*ListProcessesResponse, error)
+	// Get returns a process by PID or name
+	Get(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessInfo, error)
+	// Stop stops a process gracefully
+	Stop(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessInfo, error)
+	// Kill kills a process
+	Kill(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessInfo, error)
+	// GetLogs returns the buffered output of a process
+	GetLogs(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessLogs, error)
+	// StreamLogs streams the output of a process until it terminates or the call is
+	// cancelled
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogChunk], error)
+}
+
+type processClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProcessClient(cc grpc.ClientConnInterface) ProcessClient {
+	return &processClient{cc}
+}
+
+func (c *processClient) Start(ctx context.Context, in *StartProcessRequest, opts ...grpc.CallOption) (*ProcessInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessInfo)
+	err := c.cc.Invoke(ctx, Process_Start_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processClient) List(ctx context.Context, in *ListProcessesRequest, opts ...grpc.CallOption) (*ListProcessesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProcessesResponse)
+	err := c.cc.Invoke(ctx, Process_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processClient) Get(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessInfo)
+	err := c.cc.Invoke(ctx, Process_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processClient) Stop(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessInfo)
+	err := c.cc.Invoke(ctx, Process_Stop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processClient) Kill(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessInfo)
+	err := c.cc.Invoke(ctx, Process_Kill_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processClient) GetLogs(ctx context.Context, in *ProcessIdentifier, opts ...grpc.CallOption) (*ProcessLogs, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessLogs)
+	err := c.cc.Invoke(ctx, Process_GetLogs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Process_ServiceDesc.Streams[0], Process_StreamLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamLogsRequest, LogChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Process_StreamLogsClient = grpc.ServerStreamingClient[LogChunk]
+
+// ProcessServer is the server API for Process service.
+// All implementations must embed UnimplementedProcessServer
+// for forward compatibility.
+//
+// Process runs and manages the processes of the sandbox, like the /process routes of
+// the REST API
+type ProcessServer interface {
+	// Start starts a process, and waits for its completion with wait_for_completion
+	Start(context.Context, *StartProcessRequest) (*ProcessInfo, error)
+	// List lists the processes
+	List(context.Context, *ListProcessesRequest) (*ListProcessesResponse, error)
+	// Get returns a process by PID or name
+	Get(context.Context, *ProcessIdentifier) (*ProcessInfo, error)
+	// Stop stops a process gracefully
+	Stop(context.Context, *ProcessIdentifier) (*ProcessInfo, error)
+	// Kill kills a process
+	Kill(context.Context, *ProcessIdentifier) (*ProcessInfo, error)
+	// GetLogs returns the buffered output of a process
+	GetLogs(context.Context, *ProcessIdentifier) (*ProcessLogs, error)
+	// StreamLogs streams the output of a process until it terminates or the call is
+	// cancelled
+	StreamLogs(*StreamLogsRequest, grpc.ServerStreamingServer[LogChunk]) error
+	mustEmbedUnimplementedProcessServer()
+}
+
+// UnimplementedProcessServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedProcessServer struct{}
+
+func (UnimplementedProcessServer) Start(context.Context, *StartProcessRequest) (*ProcessInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedProcessServer) List(context.Context, *ListProcessesRequest) (*ListProcessesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedProcessServer) Get(context.Context, *ProcessIdentifier) (*ProcessInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedProcessServer) Stop(context.Context, *ProcessIdentifier) (*ProcessInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedProcessServer) Kill(context.Context, *ProcessIdentifier) (*ProcessInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Kill not implemented")
+}
+func (UnimplementedProcessServer) GetLogs(context.Context, *ProcessIdentifier) (*ProcessLogs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogs not implemented")
+}
+func (UnimplementedProcessServer) StreamLogs(*StreamLogsRequest, grpc.ServerStreamingServer[LogChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedProcessServer) mustEmbedUnimplementedProcessServer() {}
+func (UnimplementedProcessServer) testEmbeddedByValue()                 {}
+
+// UnsafeProcessServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProcessServer will
+// result in compilation errors.
+type UnsafeProcessServer interface {
+	mustEmbedUnimplementedProcessServer()
+}
+
+func RegisterProcessServer(s grpc.ServiceRegistrar, srv ProcessServer) {
+	// If the following call pancis, it indicates UnimplementedProcessServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Process_ServiceDesc, srv)
+}
+
+func _Process_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Process_Start_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessServer).Start(ctx, req.(*StartProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Process_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProcessesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Process_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessServer).List(ctx, req.(*ListProcessesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Process_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Process_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessServer).Get(ctx, req.(*ProcessIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Process_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Process_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessServer).Stop(ctx, req.(*ProcessIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Process_Kill_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessServer).Kill(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Process_Kill_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessServer).Kill(ctx, req.(*ProcessIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Process_GetLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessServer).GetLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Process_GetLogs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessServer).GetLogs(ctx, req.(*ProcessIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Process_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProcessServer).StreamLogs(m, &grpc.GenericServerStream[StreamLogsRequest, LogChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Process_StreamLogsServer = grpc.ServerStreamingServer[LogChunk]
+
+// Process_ServiceDesc is the grpc.ServiceDesc for Process service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Process_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sandbox.v1.Process",
+	HandlerType: (*ProcessServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Start",
+			Handler:    _Process_Start_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Process_List_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Process_Get_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Process_Stop_Handler,
+		},
+		{
+			MethodName: "Kill",
+			Handler:    _Process_Kill_Handler,
+		},
+		{
+			MethodName: "GetLogs",
+			Handler:    _Process_GetLogs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _Process_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sandbox/v1/process.proto",
+}
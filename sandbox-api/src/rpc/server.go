@@ -0,0 +1,301 @@
+// Package rpc serves the core filesystem, process and network operations over gRPC,
+// for SDKs of languages where the REST and WebSocket protocols are awkward and for
+// agent loops calling the API at a high rate. The services are defined in
+// proto/sandbox/v1 and run the same handlers as the REST API.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/audit"
+	"github.com/blaxel-ai/sandbox-api/src/lib/activity"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
+	"github.com/blaxel-ai/sandbox-api/src/rpc/sandboxpb"
+)
+
+// errorDomain is the domain of the ErrorInfo details of the errors, whose reason is
+// the code of the error, see apierror.Code
+const errorDomain = "sandbox-api"
+
+// maxMessageSize is the size of the largest message received, like a file written in
+// a single call
+const maxMessageSize = 64 << 20
+
+// Server serves the gRPC services
+type Server struct {
+	handlers *Handlers
+	grpc     *grpc.Server
+	audit    *audit.Logger
+}
+
+// Handlers contains all the handlers used by the gRPC services
+type Handlers struct {
+	FileSystem *handler.FileSystemHandler
+	Network    *handler.NetworkHandler
+	Process    *handler.ProcessHandler
+}
+
+// PortFromEnv returns the port the gRPC services are served on, read from GRPC_PORT. 0
+// when unset, which disables them.
+func PortFromEnv() int {
+	value := os.Getenv("GRPC_PORT")
+	if value == "" {
+		return 0
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		logrus.Warnf("Invalid GRPC_PORT value '%s', gRPC is disabled", value)
+		return 0
+	}
+	return port
+}
+
+// NewServer creates the gRPC server and registers the services, with the options of
+// the transport such as its TLS credentials
+func NewServer(options ...grpc.ServerOption) *Server {
+	s := &Server{
+		handlers: &Handlers{
+			FileSystem: handler.NewFileSystemHandler(),
+			Network:    handler.NewNetworkHandler(),
+			Process:    handler.NewProcessHandler(),
+		},
+		audit: audit.GetLogger(),
+	}
+	options = append(options,
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
+	s.grpc = grpc.NewServer(options...)
+	sandboxpb.RegisterFilesystemServer(s.grpc, &filesystemService{handlers: s.handlers})
+	sandboxpb.RegisterProcessServer(s.grpc, &processService{handlers: s.handlers})
+	sandboxpb.RegisterNetworkServer(s.grpc, &networkService{handlers: s.handlers})
+	return s
+}
+
+// Serve serves the gRPC services on a listener until Stop is called
+func (s *Server) Serve(listener net.Listener) error {
+	return s.grpc.Serve(listener)
+}
+
+// Stop stops accepting calls and waits for the running ones until ctx is done, then
+// cancels the remaining ones, like streams which never finish by themselves
+func (s *Server) Stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+}
+
+// call is a gRPC call being run, logged and recorded in the audit log once done
+type call struct {
+	ctx       context.Context
+	method    string
+	arguments map[string]interface{}
+	caller    audit.Caller
+	start     time.Time
+}
+
+// startCall identifies a call, by the request ID of its metadata or a new one sent
+// back in its header, and starts its span
+func (s *Server) startCall(ctx context.Context, method string) (*call, func(error)) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := make(http.Header, len(md))
+	for key, values := range md {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	requestID := header.Get(logging.RequestIDHeader)
+	if !logging.ValidRequestID(requestID) {
+		requestID = logging.NewID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(logging.RequestIDHeader), requestID))
+	ctx = logging.WithRequestID(ctx, requestID)
+
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	activity.GetTracker().Touch(activity.SourceAPI)
+	ctx, span := tracing.StartServer(ctx, propagation.HeaderCarrier(header), "grpc "+method, tracing.AttrGRPCMethod.String(method))
+	c := &call{ctx: ctx, method: method, caller: audit.NewCaller(ip, header), start: time.Now()}
+	return c, func(err error) {
+		tracing.Annotate(c.ctx, tracing.ArgumentAttributes(c.arguments)...)
+		tracing.End(span, err)
+		s.endCall(c, err)
+	}
+}
+
+// setArguments records the request of a call, sanitized, for the audit log
+func (c *call) setArguments(req interface{}) {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return
+	}
+	c.arguments = audit.SanitizeJSON(data)
+}
+
+// endCall logs a call and records it in the audit log
+func (s *Server) endCall(c *call, err error) {
+	duration := time.Since(c.start)
+	entry := logging.FromContext(c.ctx).WithFields(logrus.Fields{
+		"method":     c.method,
+		"durationMs": duration.Milliseconds(),
+	})
+	if err != nil {
+		entry.Errorf("gRPC call failed: %s (duration: %v, error: %v)", c.method, duration, err)
+	} else {
+		entry.Infof("gRPC call completed: %s (duration: %v)", c.method, duration)
+	}
+
+	record := audit.Entry{
+		Timestamp:  c.start.UTC(),
+		Source:     audit.SourceGRPC,
+		Operation:  c.method,
+		Arguments:  c.arguments,
+		Caller:     c.caller,
+		Success:    err == nil,
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	s.audit.Record(record)
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	c, end := s.startCall(ctx, info.FullMethod)
+	c.setArguments(req)
+	resp, err := next(c.ctx, req)
+	end(err)
+	return resp, toStatus(err)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	c, end := s.startCall(stream.Context(), info.FullMethod)
+	err := next(srv, &serverStream{ServerStream: stream, call: c})
+	end(err)
+	return toStatus(err)
+}
+
+// serverStream is a server stream with the context of its call, which records the
+// request received for the audit log
+type serverStream struct {
+	grpc.ServerStream
+	call *call
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.call.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.setArguments(m)
+	}
+	return err
+}
+
+// toStatus converts an error to the status of its code, with an ErrorInfo detail
+// whose reason is the code and metadata the details of the error. Errors without a
+// code are internal errors.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	body := apierror.NewBody(err, apierror.CodeInternal)
+	st := status.New(grpcCode(body.Code.Status()), body.Error)
+	info := &errdetails.ErrorInfo{Reason: string(body.Code), Domain: errorDomain}
+	if len(body.Details) > 0 {
+		info.Metadata = make(map[string]string, len(body.Details))
+		for key, value := range body.Details {
+			info.Metadata[key] = fmt.Sprint(value)
+		}
+	}
+	if detailed, detailErr := st.WithDetails(info); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcCode returns the gRPC code matching the HTTP status of an error code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed, http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// peerAddress returns the address of the client of a call, streams being limited per
+// client like WebSocket connections
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/blaxel-ai/sandbox-api/src/rpc/sandboxpb"
+)
+
+// dialTestServer serves the services in memory and returns a client connection
+func dialTestServer(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer()
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { server.Stop(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestFilesystem tests writing, reading, listing and deleting a file
+func TestFilesystem(t *testing.T) {
+	client := sandboxpb.NewFilesystemClient(dialTestServer(t))
+	ctx := context.Background()
+	dir := t.TempDir()
+	target := filepath.Join(dir, "sub", "hello.txt")
+
+	if _, err := client.CreateDirectory(ctx, &sandboxpb.CreateDirectoryRequest{Path: filepath.Join(dir, "sub")}); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	written, err := client.WriteFile(ctx, &sandboxpb.WriteFileRequest{Path: target, Content: []byte("hello")})
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := client.WriteFile(ctx, &sandboxpb.WriteFileRequest{Path: target, Content: []byte(" world"), Append: true}); err != nil {
+		t.Fatalf("Failed to append to file: %v", err)
+	}
+
+	file, err := client.ReadFile(ctx, &sandboxpb.ReadFileRequest{Path: target})
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(file.Content) != "hello world" || file.Name != "hello.txt" || file.Permissions != "644" {
+		t.Errorf("Unexpected file %v", file)
+	}
+	if file.Etag == "" || file.Etag == written.Etag {
+		t.Errorf("Expected the ETag to change with the content, got %q then %q", written.Etag, file.Etag)
+	}
+
+	listing, err := client.ListDirectory(ctx, &sandboxpb.ListDirectoryRequest{Path: dir})
+	if err != nil {
+		t.Fatalf("Failed to list directory: %v", err)
+	}
+	if len(listing.Subdirectories) != 1 || listing.Subdirectories[0].Name != "sub" {
+		t.Errorf("Expected the sub directory, got %v", listing)
+	}
+
+	if _, err := client.Delete(ctx, &sandboxpb.DeleteRequest{Path: filepath.Join(dir, "sub")}); err == nil {
+		t.Errorf("Expected deleting a non-empty directory without recursive to fail")
+	}
+	if _, err := client.Delete(ctx, &sandboxpb.DeleteRequest{Path: filepath.Join(dir, "sub"), Recursive: true}); err != nil {
+		t.Fatalf("Failed to delete directory: %v", err)
+	}
+	if _, err := client.ReadFile(ctx, &sandboxpb.ReadFileRequest{Path: target}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected a deleted file not to be found, got %v", err)
+	}
+}
+
+// TestErrorStatus tests that errors get the status of their code, with the code as the
+// reason of an ErrorInfo detail
+func TestErrorStatus(t *testing.T) {
+	client := sandboxpb.NewFilesystemClient(dialTestServer(t))
+	ctx := context.Background()
+
+	_, err := client.Delete(ctx, &sandboxpb.DeleteRequest{Path: filepath.Join(t.TempDir(), "missing")})
+	st := status.Convert(err)
+	if st.Code() != codes.NotFound {
+		t.Fatalf("Expected NotFound, got %v", err)
+	}
+	var reason string
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			reason = info.Reason
+		}
+	}
+	if reason != "FS_NOT_FOUND" {
+		t.Errorf("Expected the FS_NOT_FOUND reason, got %q", reason)
+	}
+
+	if _, err := client.ReadFile(ctx, &sandboxpb.ReadFileRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a path, got %v", err)
+	}
+}
+
+// TestProcessLogs tests starting a process and streaming its output until it terminates
+func TestProcessLogs(t *testing.T) {
+	client := sandboxpb.NewProcessClient(dialTestServer(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := client.Start(ctx, &sandboxpb.StartProcessRequest{Command: "echo one; sleep 0.2; echo two", Name: "grpc-logs-test"})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if info.Pid == "" || info.Name != "grpc-logs-test" {
+		t.Fatalf("Unexpected process %v", info)
+	}
+
+	stream, err := client.StreamLogs(ctx, &sandboxpb.StreamLogsRequest{Identifier: info.Pid})
+	if err != nil {
+		t.Fatalf("Failed to stream logs: %v", err)
+	}
+	var logs strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Stream ended before the process terminated: %v", err)
+		}
+		logs.WriteString(chunk.Logs)
+		if chunk.Done {
+			break
+		}
+	}
+	if logs.String() != "one\ntwo\n" {
+		t.Errorf("Unexpected logs %q", logs.String())
+	}
+
+	info, err = client.Get(ctx, &sandboxpb.ProcessIdentifier{Identifier: "grpc-logs-test"})
+	if err != nil || info.Status != "completed" {
+		t.Errorf("Expected a completed process, got %v (%v)", info, err)
+	}
+	if _, err := client.Get(ctx, &sandboxpb.ProcessIdentifier{Identifier: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected a missing process not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+// Package service implements the filesystem and process operations shared by the REST,
+// WebSocket, gRPC and MCP APIs, so that an operation behaves the same whatever the
+// transport it is requested with. It depends on none of the transports and can be
+// embedded in other programs.
+//
+// Errors have the code of their apierror: invalid requests fail with INVALID_REQUEST,
+// failures of the filesystem with the code of their kind and other failures with
+// UNPROCESSABLE.
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// Default permissions of the files and directories created
+const (
+	DefaultFilePermissions      os.FileMode = 0644
+	DefaultDirectoryPermissions os.FileMode = 0755
+)
+
+// FileSystem implements the filesystem operations
+type FileSystem struct {
+	fs    *filesystem.Filesystem
+	quota uint64
+}
+
+// NewFileSystem returns the operations on fs. Writes taking the disk usage over quota
+// bytes are rejected, 0 for no quota.
+func NewFileSystem(fs *filesystem.Filesystem, quota uint64) *FileSystem {
+	return &FileSystem{fs: fs, quota: quota}
+}
+
+// WriteRequest describes a write. Permissions only apply to the files and directories
+// created, the defaults being used when 0.
+type WriteRequest struct {
+	Path        string
+	Content     []byte
+	Permissions os.FileMode
+	IsDirectory bool
+	Append      bool
+	// Holder is the lock holder the write is made by, rejected when another holder has
+	// an enforced lock on the path
+	Holder string
+	// RunAs is the user given the files and directories created, RUN_AS when empty
+	RunAs lib.RunAs
+}
+
+// WriteResult is the result of a write. ETag is the ETag of the file once written, empty
+// for directories.
+type WriteResult struct {
+	Path string
+	ETag string
+}
+
+// ParsePermissions parses octal permissions, the default permissions of files or
+// directories when empty
+func ParsePermissions(permissions string, isDirectory bool) (os.FileMode, error) {
+	if permissions == "" {
+		if isDirectory {
+			return DefaultDirectoryPermissions, nil
+		}
+		return DefaultFilePermissions, nil
+	}
+	mode, err := strconv.ParseUint(permissions, 8, 32)
+	if err != nil {
+		return 0, apierror.Newf(apierror.CodeInvalidRequest, "invalid permissions format '%s': %w", permissions, err)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Owner returns the credential of the run-as user given the files created, RUN_AS when
+// runAs is empty, nil when the files are left to the user of the API. It fails with
+// INVALID_REQUEST for an unknown user.
+func (s *FileSystem) Owner(runAs lib.RunAs) (*lib.Credential, error) {
+	credential, err := runAs.OrDefault().Resolve()
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeInvalidRequest, err)
+	}
+	return credential, nil
+}
+
+// Write creates a directory, or writes or appends content to a file. The missing parent
+// directories are created, and given to the run-as user along with the path.
+func (s *FileSystem) Write(req WriteRequest) (WriteResult, error) {
+	path, err := formatPath(req.Path)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	owner, err := s.Owner(req.RunAs)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	permissions := req.Permissions
+	if err := s.fs.CheckLocks(req.Holder, path); err != nil {
+		return WriteResult{}, err
+	}
+	done := s.fs.TrackCreated(path, owner)
+
+	if req.IsDirectory {
+		if permissions == 0 {
+			permissions = DefaultDirectoryPermissions
+		}
+		if err := s.fs.CreateDirectory(path, permissions); err != nil {
+			return WriteResult{}, failed(err, "error creating directory")
+		}
+		done()
+		return WriteResult{Path: path}, nil
+	}
+
+	if permissions == 0 {
+		permissions = DefaultFilePermissions
+	}
+	if err := s.CheckQuota(int64(len(req.Content))); err != nil {
+		return WriteResult{}, err
+	}
+	if req.Append {
+		if err := s.fs.AppendFile(path, req.Content, permissions); err != nil {
+			return WriteResult{}, failed(err, "error appending to file")
+		}
+		done()
+		etag, err := s.fs.GetETag(path)
+		if err != nil {
+			return WriteResult{}, failed(err, "error getting the ETag of the file")
+		}
+		return WriteResult{Path: path, ETag: etag}, nil
+	}
+	if err := s.fs.WriteFile(path, req.Content, permissions); err != nil {
+		return WriteResult{}, failed(err, "error writing file")
+	}
+	done()
+	return WriteResult{Path: path, ETag: filesystem.ContentETag(req.Content)}, nil
+}
+
+// WriteTree writes files, by path relative to the root directory, creating the root and
+// the missing directories. Existing files are replaced. Files locked by another holder
+// than holder are rejected and the files created are given to runAs, see Write.
+func (s *FileSystem) WriteTree(root string, files map[string]string, holder string, runAs lib.RunAs) (string, error) {
+	root, err := formatPath(root)
+	if err != nil {
+		return "", err
+	}
+	owner, err := s.Owner(runAs)
+	if err != nil {
+		return "", err
+	}
+	paths := []string{root}
+	for filePath := range files {
+		paths = append(paths, filepath.Join(root, filePath))
+	}
+	if err := s.fs.CheckLocks(holder, paths...); err != nil {
+		return "", err
+	}
+	var size int64
+	for _, content := range files {
+		size += int64(len(content))
+	}
+	if err := s.CheckQuota(size); err != nil {
+		return "", err
+	}
+
+	done := s.fs.TrackCreated(root, owner)
+	if err := s.fs.CreateDirectory(root, DefaultDirectoryPermissions); err != nil {
+		return "", failed(err, "error creating root directory")
+	}
+	done()
+	for filePath, content := range files {
+		done := s.fs.TrackCreated(filepath.Join(root, filePath), owner)
+		if err := s.fs.WriteFile(filepath.Join(root, filePath), []byte(content), DefaultFilePermissions); err != nil {
+			return "", failed(err, "error writing file %s", filePath)
+		}
+		done()
+	}
+	return root, nil
+}
+
+// Delete deletes a file or a directory, with its content when recursive is set. It
+// returns whether the path was a directory, and fails with FS_NOT_FOUND when it doesn't
+// exist. Paths locked by another holder than holder are rejected, see Write.
+func (s *FileSystem) Delete(path string, recursive bool, holder string) (bool, error) {
+	path, err := formatPath(path)
+	if err != nil {
+		return false, err
+	}
+	if err := s.fs.CheckLocks(holder, path); err != nil {
+		return false, err
+	}
+
+	isDir, err := s.fs.DirectoryExists(path)
+	if err != nil {
+		return false, failed(err, "error checking the path")
+	}
+	if isDir {
+		if err := s.fs.DeleteDirectory(path, recursive); err != nil {
+			return true, failed(err, "error deleting directory")
+		}
+		return true, nil
+	}
+
+	isFile, err := s.fs.FileExists(path)
+	if err != nil {
+		return false, failed(err, "error checking the path")
+	}
+	if !isFile {
+		return false, apierror.New(apierror.CodeFSNotFound, "file or directory not found")
+	}
+	if err := s.fs.DeleteFile(path); err != nil {
+		return false, failed(err, "error deleting file")
+	}
+	return false, nil
+}
+
+// CheckQuota returns filesystem.ErrQuotaExceeded if writing size bytes would take the
+// disk usage over the quota
+func (s *FileSystem) CheckQuota(size int64) error {
+	return s.fs.CheckQuota(s.quota, size)
+}
+
+// formatPath formats the path of a request
+func formatPath(path string) (string, error) {
+	formatted, err := lib.FormatPath(path)
+	if err != nil {
+		return "", apierror.Wrap(apierror.CodeInvalidRequest, err)
+	}
+	return formatted, nil
+}
+
+// failed describes a failed operation, with UNPROCESSABLE as the code of errors having
+// no code of their own
+func failed(err error, format string, args ...any) error {
+	err = fmt.Errorf(format+": %w", append(args, err)...)
+	if apierror.From(err) != nil {
+		return err
+	}
+	return apierror.Wrap(apierror.CodeUnprocessable, err)
+}
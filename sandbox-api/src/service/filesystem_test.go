@@ -0,0 +1,150 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestWrite tests writing, appending to and creating directories with their default
+// permissions
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	files := NewFileSystem(filesystem.NewFilesystem("/"), 0)
+	target := filepath.Join(dir, "sub", "hello.txt")
+
+	written, err := files.Write(WriteRequest{Path: target, Content: []byte("hello")})
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	appended, err := files.Write(WriteRequest{Path: target, Content: []byte(" world"), Append: true})
+	if err != nil {
+		t.Fatalf("Failed to append to file: %v", err)
+	}
+	content, _ := os.ReadFile(target)
+	if string(content) != "hello world" {
+		t.Errorf("Unexpected content %q", content)
+	}
+	if appended.ETag != filesystem.ContentETag(content) || written.ETag == appended.ETag {
+		t.Errorf("Expected the ETags of the content, got %q then %q", written.ETag, appended.ETag)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != DefaultFilePermissions {
+		t.Errorf("Expected permissions %o, got %o", DefaultFilePermissions, info.Mode().Perm())
+	}
+
+	created, err := files.Write(WriteRequest{Path: filepath.Join(dir, "a", "b"), IsDirectory: true})
+	if err != nil || created.ETag != "" {
+		t.Fatalf("Failed to create directory: %+v (%v)", created, err)
+	}
+	if info, err := os.Stat(created.Path); err != nil || info.Mode().Perm() != DefaultDirectoryPermissions {
+		t.Errorf("Expected a directory with permissions %o, got %v (%v)", DefaultDirectoryPermissions, info, err)
+	}
+
+	if _, err := ParsePermissions("9", false); apierror.From(err) == nil || apierror.From(err).Code != apierror.CodeInvalidRequest {
+		t.Errorf("Expected invalid permissions to be an INVALID_REQUEST error, got %v", err)
+	}
+}
+
+// TestWriteTree tests that the root and the parents of the files are created
+func TestWriteTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "project")
+	files := NewFileSystem(filesystem.NewFilesystem("/"), 0)
+
+	if _, err := files.WriteTree(root, map[string]string{"main.go": "package main", "pkg/lib/lib.go": "package lib"}, "", lib.RunAs{}); err != nil {
+		t.Fatalf("Failed to write tree: %v", err)
+	}
+	for path, expected := range map[string]string{"main.go": "package main", "pkg/lib/lib.go": "package lib"} {
+		content, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", path, expected, content, err)
+		}
+	}
+}
+
+// TestWriteRunAs tests that the files and parent directories created are given to the
+// run-as user, whatever API the write comes from
+func TestWriteRunAs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing the owner of files requires root")
+	}
+	dir := t.TempDir()
+	files := NewFileSystem(filesystem.NewFilesystem("/"), 0)
+	runAs := lib.ParseRunAs("1234:1234")
+
+	if _, err := files.Write(WriteRequest{Path: filepath.Join(dir, "sub", "file.txt"), Content: []byte("content"), RunAs: runAs}); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := files.WriteTree(filepath.Join(dir, "project"), map[string]string{"pkg/main.go": "package main"}, "", runAs); err != nil {
+		t.Fatalf("Failed to write tree: %v", err)
+	}
+	for _, path := range []string{"sub", "sub/file.txt", "project", "project/pkg", "project/pkg/main.go"} {
+		info, err := os.Lstat(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 1234 || stat.Gid != 1234 {
+			t.Errorf("Expected %s to be owned by 1234:1234, got %d:%d", path, stat.Uid, stat.Gid)
+		}
+	}
+	if info, _ := os.Lstat(dir); info.Sys().(*syscall.Stat_t).Uid == 1234 {
+		t.Error("Expected the existing parent to keep its owner")
+	}
+
+	_, err := files.Write(WriteRequest{Path: filepath.Join(dir, "other.txt"), RunAs: lib.ParseRunAs("no-such-user")})
+	if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeInvalidRequest {
+		t.Errorf("Expected an unknown user to be an INVALID_REQUEST error, got %v", err)
+	}
+}
+
+// TestDelete tests deleting files and directories, and the codes of the failures
+func TestDelete(t *testing.T) {
+	dir := t.TempDir()
+	files := NewFileSystem(filesystem.NewFilesystem("/"), 0)
+	if _, err := files.WriteTree(dir, map[string]string{"file.txt": "content", "sub/nested.txt": "content"}, "", lib.RunAs{}); err != nil {
+		t.Fatalf("Failed to write tree: %v", err)
+	}
+
+	if isDir, err := files.Delete(filepath.Join(dir, "file.txt"), false, ""); err != nil || isDir {
+		t.Errorf("Expected the file to be deleted, got %v (%v)", isDir, err)
+	}
+	if _, err := files.Delete(filepath.Join(dir, "sub"), false, ""); err == nil {
+		t.Errorf("Expected deleting a non-empty directory without recursive to fail")
+	}
+	if isDir, err := files.Delete(filepath.Join(dir, "sub"), true, ""); err != nil || !isDir {
+		t.Errorf("Expected the directory to be deleted, got %v (%v)", isDir, err)
+	}
+	_, err := files.Delete(filepath.Join(dir, "missing"), false, "")
+	if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeFSNotFound {
+		t.Errorf("Expected FS_NOT_FOUND, got %v", err)
+	}
+}
+
+// TestLockedWrites tests that writes under an enforced lock are rejected for other holders
+func TestLockedWrites(t *testing.T) {
+	dir := t.TempDir()
+	files := NewFileSystem(filesystem.NewFilesystem("/"), 0)
+	if _, err := filesystem.GetLockTable().Acquire(filepath.Join(dir, "src"), "agent-1", time.Minute, true); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "src", "main.go")
+
+	_, err := files.Write(WriteRequest{Path: target, Content: []byte("package main"), Holder: "agent-2"})
+	if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeFSLocked {
+		t.Errorf("Expected FS_LOCKED, got %v", err)
+	}
+	if _, err := files.WriteTree(dir, map[string]string{"src/lib.go": "package main"}, "", lib.RunAs{}); apierror.From(err) == nil {
+		t.Error("Expected a tree write without holder to be rejected")
+	}
+	if _, err := files.Write(WriteRequest{Path: target, Content: []byte("package main"), Holder: "agent-1"}); err != nil {
+		t.Errorf("Expected the holder to write, got %v", err)
+	}
+	if _, err := files.Delete(dir, true, "agent-2"); apierror.From(err) == nil {
+		t.Error("Expected deleting the parent of a locked path to be rejected")
+	}
+}
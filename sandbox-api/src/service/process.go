@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/constants"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
+)
+
+// Process implements the process operations
+type Process struct {
+	manager *process.ProcessManager
+}
+
+// NewProcess returns the operations on the processes of manager
+func NewProcess(manager *process.ProcessManager) *Process {
+	return &Process{manager: manager}
+}
+
+// StartRequest describes a process to start. An empty WorkingDir runs it in the working
+// directory of the sandbox, and the restart fields are those of process.NewRestartConfig.
+type StartRequest struct {
+	Command           string
+	Name              string
+	WorkingDir        string
+	Env               map[string]string
+	RunAs             lib.RunAs
+	WaitForCompletion bool
+	Timeout           int
+	WaitForPorts      []int
+	WaitForLogPattern string
+	RestartPolicy     string
+	RestartOnFailure  bool
+	MaxRestarts       int
+	Backoff           *process.BackoffConfig
+	RestartWindow     int
+	LogToFile         bool
+	Record            bool
+}
+
+// Start starts a process. It fails with PROC_NAME_CONFLICT when a running process has
+// the same name, and with PROC_DENIED_BY_POLICY when the process policy denies the
+// command.
+func (s *Process) Start(ctx context.Context, req StartRequest) (*process.ProcessInfo, error) {
+	if req.Command == "" {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "command is required")
+	}
+	workingDir := req.WorkingDir
+	if workingDir != "" {
+		formatted, err := formatPath(workingDir)
+		if err != nil {
+			return nil, err
+		}
+		workingDir = formatted
+	}
+
+	if req.Name != "" {
+		if existing, exists := s.manager.GetProcessByIdentifier(req.Name); exists && existing.State().Status == constants.ProcessStatusRunning {
+			return nil, apierror.Newf(apierror.CodeProcNameConflict, "process with name '%s' already exists and is running", req.Name)
+		}
+	}
+
+	restart, err := process.NewRestartConfig(req.RestartPolicy, req.RestartOnFailure, req.MaxRestarts, req.Backoff, req.RestartWindow)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	if _, err := req.RunAs.Resolve(); err != nil {
+		return nil, invalid(err)
+	}
+
+	_, span := tracing.Start(ctx, "process.execute",
+		tracing.AttrProcessIdentifier.String(req.Name),
+		attribute.Bool("sandbox.process.wait_for_completion", req.WaitForCompletion),
+	)
+	processInfo, err := s.manager.ExecuteProcess(req.Command, workingDir, req.Name, req.Env, req.RunAs, req.WaitForCompletion, req.Timeout, req.WaitForPorts, req.WaitForLogPattern, restart, req.LogToFile, req.Record)
+	if err != nil {
+		tracing.End(span, err)
+		if apierror.From(err) != nil {
+			return nil, err
+		}
+		return nil, apierror.Wrap(apierror.CodeUnprocessable, err)
+	}
+	span.SetAttributes(tracing.AttrProcessPID.String(processInfo.PID), attribute.String("sandbox.process.status", string(processInfo.State().Status)))
+	tracing.End(span, nil)
+	logging.FromContext(ctx).WithField(logging.FieldProcessPID, processInfo.PID).Infof("Process %s started (status: %s)", processInfo.PID, processInfo.State().Status)
+	return processInfo, nil
+}
+
+// invalid returns err as an INVALID_REQUEST error, unless it has a code
+func invalid(err error) error {
+	if apierror.From(err) != nil {
+		return err
+	}
+	return apierror.Wrap(apierror.CodeInvalidRequest, err)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// TestStart tests that invalid requests fail with INVALID_REQUEST and a process can't
+// be started under the name of a running one
+func TestStart(t *testing.T) {
+	manager := process.NewProcessManager()
+	processes := NewProcess(manager)
+	ctx := context.Background()
+
+	invalid := []StartRequest{
+		{},
+		{Command: "true", RestartPolicy: "sometimes"},
+		{Command: "true", MaxRestarts: -1},
+	}
+	for _, req := range invalid {
+		_, err := processes.Start(ctx, req)
+		if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeInvalidRequest {
+			t.Errorf("Expected INVALID_REQUEST for %+v, got %v", req, err)
+		}
+	}
+
+	_, err := processes.Start(ctx, StartRequest{Command: "sleep 5", Name: "service-start-test"})
+	if err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() { _ = manager.KillProcess("service-start-test") }()
+
+	_, err = processes.Start(ctx, StartRequest{Command: "true", Name: "service-start-test"})
+	if apiErr := apierror.From(err); apiErr == nil || apiErr.Code != apierror.CodeProcNameConflict {
+		t.Errorf("Expected PROC_NAME_CONFLICT, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultChunkSize is the size of the data of a message above which it is split into
+// chunks, when WS_CHUNK_SIZE is unset
+const DefaultChunkSize = 64 * 1024
+
+// Chunk marks a message carrying a part of the data of a response. The data of each
+// chunk is a string, and the JSON encoding of the data of the response is the
+// concatenation of the chunks in index order, ending with the final one. The chunks
+// of a response are sent consecutively, with no other message in between.
+type Chunk struct {
+	Index int  `json:"index"`
+	Final bool `json:"final"`
+}
+
+// ChunkSizeFromEnv returns the chunk size read from WS_CHUNK_SIZE, in bytes. Zero
+// disables chunking.
+func ChunkSizeFromEnv() int {
+	value := os.Getenv("WS_CHUNK_SIZE")
+	if value == "" {
+		return DefaultChunkSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		logrus.Warnf("Invalid WS_CHUNK_SIZE value '%s', using default of %d bytes", value, DefaultChunkSize)
+		return DefaultChunkSize
+	}
+	return size
+}
+
+// splitResponse returns the messages sending a response: the response itself when
+// its data fits in chunkSize bytes, or chunks of its encoded data otherwise
+func splitResponse(msg Response, chunkSize int) ([]Response, error) {
+	if msg.Data == nil {
+		return []Response{msg}, nil
+	}
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 || len(data) <= chunkSize {
+		msg.Data = json.RawMessage(data)
+		return []Response{msg}, nil
+	}
+
+	chunks := make([]Response, 0, len(data)/chunkSize+1)
+	for start := 0; start < len(data); {
+		end := min(start+chunkSize, len(data))
+		// Never split a multi-byte character, each chunk must be valid UTF-8
+		for end < len(data) && end > start+1 && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		chunk := msg
+		chunk.Data = string(data[start:end])
+		chunk.Chunk = &Chunk{Index: len(chunks), Final: end == len(data)}
+		chunks = append(chunks, chunk)
+		start = end
+	}
+	return chunks, nil
+}
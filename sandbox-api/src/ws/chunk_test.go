@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestSplitResponse tests that large data is split into ordered chunks which join back
+// into the encoded data
+func TestSplitResponse(t *testing.T) {
+	data := map[string]interface{}{"content": strings.Repeat("héllo wörld ", 200)}
+
+	messages, err := splitResponse(Response{ID: "1", Operation: "filesystem:get", Success: true, Data: data}, 100)
+	if err != nil {
+		t.Fatalf("Failed to split response: %v", err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(messages))
+	}
+
+	var joined strings.Builder
+	for i, message := range messages {
+		if message.ID != "1" || message.Operation != "filesystem:get" || !message.Success {
+			t.Errorf("Expected chunk %d to keep the response fields, got %+v", i, message)
+		}
+		if message.Chunk == nil || message.Chunk.Index != i || message.Chunk.Final != (i == len(messages)-1) {
+			t.Fatalf("Unexpected chunk marker at %d: %+v", i, message.Chunk)
+		}
+		part := message.Data.(string)
+		if len(part) > 100 || !utf8.ValidString(part) {
+			t.Errorf("Expected chunk %d to be valid UTF-8 of at most 100 bytes, got %d bytes", i, len(part))
+		}
+		joined.WriteString(part)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(joined.String()), &decoded); err != nil {
+		t.Fatalf("Failed to decode joined chunks: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, data) {
+		t.Error("Expected joined chunks to decode to the original data")
+	}
+}
+
+// TestSplitResponseSmall tests that small responses and errors are sent whole
+func TestSplitResponseSmall(t *testing.T) {
+	messages, err := splitResponse(Response{ID: "1", Operation: "filesystem:get", Success: true, Data: []string{"a"}}, 100)
+	if err != nil || len(messages) != 1 || messages[0].Chunk != nil {
+		t.Fatalf("Expected a single message, got %+v (%v)", messages, err)
+	}
+
+	messages, err = splitResponse(Response{ID: "2", Operation: "filesystem:get", Error: strings.Repeat("x", 500)}, 100)
+	if err != nil || len(messages) != 1 || messages[0].Chunk != nil {
+		t.Fatalf("Expected errors to be sent whole, got %d messages (%v)", len(messages), err)
+	}
+
+	messages, err = splitResponse(Response{ID: "3", Operation: "filesystem:get", Data: strings.Repeat("x", 500)}, 0)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Expected chunking to be disabled, got %d messages (%v)", len(messages), err)
+	}
+}
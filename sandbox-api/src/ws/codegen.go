@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
+)
+
+// RerankingRequest is the data of a codegen:reranking operation. Unset score threshold
+// and token limit default to 0.5 and 30000 tokens, like the codegenRerank MCP tool.
+type RerankingRequest struct {
+	Path           string  `json:"path"`
+	Query          string  `json:"query" binding:"required"`
+	ScoreThreshold float64 `json:"scoreThreshold"`
+	TokenLimit     int     `json:"tokenLimit"`
+	FilePattern    string  `json:"filePattern"`
+}
+
+// ScoredFile is a file ranked by a codegen:reranking operation
+type ScoredFile struct {
+	Path  string  `json:"path"`
+	Score float64 `json:"score"`
+}
+
+// RerankingPartial is pushed for a codegen:reranking operation with the files of each
+// ranked batch scoring above the threshold, before the final response
+type RerankingPartial struct {
+	Files []ScoredFile `json:"files"`
+}
+
+// registerCodegenOperations registers the codegen operations
+func (s *Server) registerCodegenOperations() {
+	s.registerOperation("codegen:reranking", s.codegenReranking, operationSpec{
+		description: "Find the files of a directory most relevant to a query, pushing the files of each ranked batch",
+		request:     RerankingRequest{},
+		response:    handler.RerankingResponse{},
+		events:      []eventSpec{{operation: "codegen:reranking", withRequestID: true, data: RerankingPartial{}}},
+	})
+}
+
+// codegenReranking finds the files of a directory most relevant to a query. Files are
+// ranked in batches, and the path and score of the relevant files of each batch are
+// pushed as codegen:reranking responses with the id of the request as soon as they are
+// ranked. The final response is the same as GET /codegen/reranking: the relevant files
+// with their content, from most to least relevant, within the token limit.
+func (s *Server) codegenReranking(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req RerankingRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+
+	files, err := s.handlers.Codegen.RerankFiles(ctx, req.Path, handler.RerankingRequest{
+		Query:          req.Query,
+		ScoreThreshold: req.ScoreThreshold,
+		TokenLimit:     req.TokenLimit,
+		FilePattern:    req.FilePattern,
+	}, func(batch []handler.RankedFile) {
+		if request.ID == "" {
+			return
+		}
+		partial := RerankingPartial{Files: make([]ScoredFile, 0, len(batch))}
+		for _, file := range batch {
+			partial.Files = append(partial.Files, ScoredFile{Path: file.Path, Score: file.Score})
+		}
+		conn.Send(Response{ID: request.ID, Operation: request.Operation, Success: true, Data: partial})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.RerankingResponse{
+		Success: true,
+		Files:   files,
+		Message: fmt.Sprintf("Found %d relevant files", len(files)),
+	}, nil
+}
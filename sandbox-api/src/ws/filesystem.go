@@ -0,0 +1,360 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
+	"github.com/blaxel-ai/sandbox-api/src/service"
+)
+
+// FileReadRequest is the data of a filesystem:read operation. Encoding is utf-8 or
+// base64, by default utf-8 for text files and base64 for binary files.
+type FileReadRequest struct {
+	Path     string `json:"path" binding:"required"`
+	Encoding string `json:"encoding" binding:"omitempty,oneof=utf-8 base64"`
+}
+
+// FileWriteRequest is the data of a filesystem:write operation. Content is encoded with
+// Encoding, utf-8 by default or base64 for binary content. RunAs is the "user[:group]"
+// given the files created, RUN_AS by default.
+type FileWriteRequest struct {
+	Path        string `json:"path" binding:"required"`
+	Content     string `json:"content"`
+	Encoding    string `json:"encoding" binding:"omitempty,oneof=utf-8 base64"`
+	Permissions string `json:"permissions"`
+	Append      bool   `json:"append"`
+	RunAs       string `json:"runAs"`
+}
+
+// FileWriteResponse is the result of a filesystem:write operation
+type FileWriteResponse struct {
+	Path string `json:"path"`
+	ETag string `json:"etag"`
+}
+
+// SearchRequest is the data of a filesystem:search operation, searching the files under
+// the directory Path, the working directory by default. Type is name, the default, or
+// content, see FileSystemHandler.Search.
+type SearchRequest struct {
+	Path       string   `json:"path"`
+	Query      string   `json:"query" binding:"required"`
+	Type       string   `json:"type" binding:"omitempty,oneof=name content"`
+	Include    []string `json:"include"`
+	Exclude    []string `json:"exclude"`
+	MaxResults int      `json:"maxResults" binding:"gte=0"`
+}
+
+// LinkRequest is the data of a filesystem:link operation, creating a symbolic link at
+// Path pointing to Target, or a hard link to the file at Target with Hardlink set.
+// RunAs is the "user[:group]" given the link, RUN_AS by default.
+type LinkRequest struct {
+	Path     string `json:"path" binding:"required"`
+	Target   string `json:"target" binding:"required"`
+	Hardlink bool   `json:"hardlink"`
+	RunAs    string `json:"runAs"`
+}
+
+// PermissionsRequest is the data of a filesystem:permissions operation, changing the
+// mode, owner and group of Path like POST /filesystem/{path}/permissions
+type PermissionsRequest struct {
+	Path string `json:"path" binding:"required"`
+	handler.PermissionsRequest
+}
+
+// PathResponse is the result of the operations changing a path
+type PathResponse struct {
+	Path string `json:"path"`
+}
+
+// WatchStartRequest is the data of a filesystem:watch:start operation. Path is a
+// directory, or a file whose events include its content or diff with Content set to full
+// or diff, see FileSystemHandler.WatchFile.
+type WatchStartRequest struct {
+	Path      string   `json:"path" binding:"required"`
+	Content   string   `json:"content" binding:"omitempty,oneof=full diff"`
+	Recursive bool     `json:"recursive"`
+	Ignore    []string `json:"ignore"`
+	Gitignore bool     `json:"gitignore"`
+	// DebounceMs merges the events of a path received within the delay, see
+	// FileSystemHandler.WatchDirectoryBatched. Batch pushes them as a single
+	// filesystem:watch:batch message.
+	DebounceMs int  `json:"debounceMs" binding:"gte=0"`
+	Batch      bool `json:"batch"`
+}
+
+// WatchStartResponse is the result of a filesystem:watch:start operation
+type WatchStartResponse struct {
+	SubscriptionID string `json:"subscriptionId"`
+	Path           string `json:"path"`
+}
+
+// WatchStopRequest is the data of a filesystem:watch:stop operation
+type WatchStopRequest struct {
+	SubscriptionID string `json:"subscriptionId" binding:"required"`
+}
+
+// WatchEvent is a file event pushed for a watch subscription
+type WatchEvent struct {
+	SubscriptionID string `json:"subscriptionId"`
+	handler.FileEvent
+}
+
+// WatchBatch is a batch of file events pushed for a watch subscription with batch set
+type WatchBatch struct {
+	SubscriptionID string              `json:"subscriptionId"`
+	Events         []handler.FileEvent `json:"events"`
+}
+
+// registerFileSystemOperations registers the filesystem operations
+func (s *Server) registerFileSystemOperations() {
+	s.registerOperation("filesystem:read", s.fileRead, operationSpec{
+		description: "Read the content and metadata of a file",
+		request:     FileReadRequest{},
+		response:    filesystem.FileWithContentByte{},
+	})
+	s.registerOperation("filesystem:write", s.fileWrite, operationSpec{
+		description: "Write or append to a file",
+		request:     FileWriteRequest{},
+		response:    FileWriteResponse{},
+	})
+	s.registerOperation("filesystem:search", s.fileSearch, operationSpec{
+		description: "Search the files under a directory by name or content",
+		request:     SearchRequest{},
+		response:    filesystem.SearchResult{},
+	})
+	s.registerOperation("filesystem:link", s.fileLink, operationSpec{
+		description: "Create a symbolic link or a hard link",
+		request:     LinkRequest{},
+		response:    PathResponse{},
+	})
+	s.registerOperation("filesystem:permissions", s.filePermissions, operationSpec{
+		description: "Change the mode, owner and group of a file or directory",
+		request:     PermissionsRequest{},
+		response:    PathResponse{},
+	})
+	s.registerOperation("filesystem:watch:start", s.watchStart, operationSpec{
+		description: "Watch a directory or a file, pushing its file events until the subscription is stopped",
+		request:     WatchStartRequest{},
+		response:    WatchStartResponse{},
+		events: []eventSpec{
+			{operation: "filesystem:watch:event", data: WatchEvent{}},
+			{operation: "filesystem:watch:batch", data: WatchBatch{}},
+		},
+	})
+	s.registerOperation("filesystem:watch:stop", s.watchStop, operationSpec{
+		description: "Stop a watch subscription",
+		request:     WatchStopRequest{},
+		response:    WatchStopRequest{},
+	})
+}
+
+// fileRead returns the content and metadata of a file
+func (s *Server) fileRead(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req FileReadRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if _, err := filesystem.ContentEncoding(req.Encoding, nil); err != nil {
+		return nil, err
+	}
+
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.handlers.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file.Encoding = req.Encoding
+	return file, nil
+}
+
+// fileWrite writes or appends to a file, created with the octal permissions, 0644 when
+// empty
+func (s *Server) fileWrite(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req FileWriteRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	content, err := filesystem.DecodeContent(req.Content, req.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := service.ParsePermissions(req.Permissions, false)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.handlers.FileSystem.Write(service.WriteRequest{
+		Path:        req.Path,
+		Content:     content,
+		Permissions: permissions,
+		Append:      req.Append,
+		RunAs:       lib.ParseRunAs(req.RunAs),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FileWriteResponse{Path: result.Path, ETag: result.ETag}, nil
+}
+
+// fileSearch searches the files under a directory
+func (s *Server) fileSearch(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req SearchRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return s.handlers.FileSystem.Search(path, filesystem.SearchOptions{
+		Query:      req.Query,
+		Type:       req.Type,
+		Include:    req.Include,
+		Exclude:    req.Exclude,
+		MaxResults: req.MaxResults,
+	})
+}
+
+// fileLink creates a symbolic link or a hard link
+func (s *Server) fileLink(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req LinkRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.handlers.FileSystem.CreateLink(path, req.Target, req.Hardlink, "", lib.ParseRunAs(req.RunAs)); err != nil {
+		return nil, err
+	}
+	return PathResponse{Path: path}, nil
+}
+
+// filePermissions changes the mode, owner and group of a file or directory
+func (s *Server) filePermissions(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req PermissionsRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.handlers.FileSystem.SetPermissions(path, req.PermissionsRequest, ""); err != nil {
+		return nil, err
+	}
+	return PathResponse{Path: path}, nil
+}
+
+// watchStart subscribes the connection to the events of a directory or a file. Any
+// number of watches can be active on a connection, their events are pushed as
+// filesystem:watch:event messages tagged with the subscription id.
+func (s *Server) watchStart(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req WatchStartRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+
+	path, err := lib.FormatPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	isDir, err := s.handlers.FileSystem.DirectoryExists(path)
+	if err != nil {
+		return nil, err
+	}
+	isFile := false
+	if !isDir && !req.Recursive {
+		if isFile, err = s.handlers.FileSystem.FileExists(path); err != nil {
+			return nil, err
+		}
+	}
+	if !isDir && req.Recursive {
+		return nil, apierror.Newf(apierror.CodeFSNotADirectory, "path is not a directory")
+	}
+	if !isDir && !isFile {
+		return nil, apierror.Newf(apierror.CodeFSNotFound, "path not found")
+	}
+	if isDir && req.Content != "" {
+		return nil, apierror.New(apierror.CodeInvalidRequest, "content is only supported when watching a file")
+	}
+
+	debounce, err := handler.WatchDebounce(req.DebounceMs, req.Batch)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := streamlimit.Watchers().Acquire(conn.remoteAddr())
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptionID := uuid.New().String()
+	sendEvent := func(event handler.FileEvent) {
+		conn.Send(Response{
+			Operation: "filesystem:watch:event",
+			Success:   true,
+			Data:      WatchEvent{SubscriptionID: subscriptionID, FileEvent: event},
+		})
+	}
+	sendBatch := func(events []handler.FileEvent) {
+		if !req.Batch {
+			for _, event := range events {
+				sendEvent(event)
+			}
+			return
+		}
+		conn.Send(Response{
+			Operation: "filesystem:watch:batch",
+			Success:   true,
+			Data:      WatchBatch{SubscriptionID: subscriptionID, Events: events},
+		})
+	}
+	var stop func()
+	switch {
+	case isFile && debounce == 0:
+		stop, err = s.handlers.FileSystem.WatchFile(path, req.Content, sendEvent)
+	case isFile:
+		stop, err = s.handlers.FileSystem.WatchFileBatched(path, req.Content, debounce, sendBatch)
+	case debounce == 0:
+		stop, err = s.handlers.FileSystem.WatchDirectory(path, req.Recursive, req.Ignore, req.Gitignore, sendEvent)
+	default:
+		stop, err = s.handlers.FileSystem.WatchDirectoryBatched(path, req.Recursive, req.Ignore, req.Gitignore, debounce, sendBatch)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	metrics.ActiveWatchers.Inc()
+	conn.AddCleanup(subscriptionID, func() {
+		stop()
+		release()
+		metrics.ActiveWatchers.Dec()
+	})
+
+	return WatchStartResponse{SubscriptionID: subscriptionID, Path: path}, nil
+}
+
+// watchStop ends a watch subscription of the connection
+func (s *Server) watchStop(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req WatchStopRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if !conn.RemoveCleanup(req.SubscriptionID) {
+		return nil, apierror.Newf(apierror.CodeNotFound, "subscription %s not found", req.SubscriptionID)
+	}
+	return WatchStopRequest{SubscriptionID: req.SubscriptionID}, nil
+}
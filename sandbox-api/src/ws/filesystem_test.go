@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+)
+
+// TestFileReadWriteEncoding tests that binary content round-trips with the base64
+// encoding
+func TestFileReadWriteEncoding(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 1})
+	target := filepath.Join(t.TempDir(), "data.bin")
+	binary := []byte{0x00, 0xff, 0xfe, 'a', 0x80}
+
+	resp := roundTrip(t, conn, Request{ID: "1", Operation: "filesystem:write"}, FileWriteRequest{Path: target, Content: base64.StdEncoding.EncodeToString(binary), Encoding: "base64"}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to write file: %+v", resp)
+	}
+	if content, err := os.ReadFile(target); err != nil || string(content) != string(binary) {
+		t.Fatalf("Unexpected written file %q (%v)", content, err)
+	}
+
+	resp = roundTrip(t, conn, Request{ID: "2", Operation: "filesystem:read"}, FileReadRequest{Path: target}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to read file: %+v", resp)
+	}
+	file := resp.Data.(map[string]interface{})
+	if file["encoding"] != "base64" || file["content"] != base64.StdEncoding.EncodeToString(binary) {
+		t.Errorf("Expected the binary content in base64, got %v", file)
+	}
+
+	resp = roundTrip(t, conn, Request{ID: "3", Operation: "filesystem:write"}, FileWriteRequest{Path: target, Content: "text", Append: true}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to append to file: %+v", resp)
+	}
+	resp = roundTrip(t, conn, Request{ID: "4", Operation: "filesystem:read"}, FileReadRequest{Path: target, Encoding: "utf-16"}, nil)
+	if resp.Success || resp.Code != "INVALID_REQUEST" {
+		t.Errorf("Expected an invalid encoding to fail, got %+v", resp)
+	}
+}
+
+// TestFileSearch tests searching files by content, and the code of a missing directory
+func TestFileSearch(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 1})
+	dir := t.TempDir()
+	target := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(target, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := roundTrip(t, conn, Request{ID: "1", Operation: "filesystem:search"}, SearchRequest{Path: dir, Query: "func main", Type: "content"}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to search: %+v", resp)
+	}
+	matches := resp.Data.(map[string]interface{})["matches"].([]interface{})
+	if len(matches) != 1 || matches[0].(map[string]interface{})["path"] != target {
+		t.Errorf("Expected a match in %s, got %v", target, matches)
+	}
+	resp = roundTrip(t, conn, Request{ID: "2", Operation: "filesystem:search"}, SearchRequest{Path: filepath.Join(dir, "missing"), Query: "main"}, nil)
+	if resp.Success || resp.Code != "FS_NOT_FOUND" {
+		t.Errorf("Expected searching a missing directory to fail with FS_NOT_FOUND, got %+v", resp)
+	}
+}
+
+// TestFileLinkPermissions tests creating links and changing permissions
+func TestFileLinkPermissions(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 1})
+	dir := t.TempDir()
+	target := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(target, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "links", "main.go")
+	resp := roundTrip(t, conn, Request{ID: "1", Operation: "filesystem:link"}, LinkRequest{Path: link, Target: target}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to create symlink: %+v", resp)
+	}
+	if dest, err := os.Readlink(link); err != nil || dest != target {
+		t.Errorf("Expected a symlink to %s, got %q (%v)", target, dest, err)
+	}
+
+	resp = roundTrip(t, conn, Request{ID: "2", Operation: "filesystem:permissions"}, PermissionsRequest{Path: target, PermissionsRequest: handler.PermissionsRequest{Mode: "0600"}}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to change permissions: %+v", resp)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v (%v)", info, err)
+	}
+	resp = roundTrip(t, conn, Request{ID: "3", Operation: "filesystem:permissions"}, PermissionsRequest{Path: target}, nil)
+	if resp.Success || resp.Code != "INVALID_REQUEST" {
+		t.Errorf("Expected a request changing nothing to fail with INVALID_REQUEST, got %+v", resp)
+	}
+}
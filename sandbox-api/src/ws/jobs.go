@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/jobs"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
+)
+
+// JobEventsRequest is the data of a jobs:events operation, the jobs to follow: those of
+// a type, a single job, or every job when both are empty
+type JobEventsRequest struct {
+	Type  string `json:"type,omitempty" binding:"omitempty,oneof=archive sync export index.rebuild snapshot.create snapshot.restore packages.install"`
+	JobID string `json:"jobId,omitempty"`
+}
+
+// JobEventsResponse is the first response of a jobs:events operation, with the followed
+// jobs known when it started
+type JobEventsResponse struct {
+	Jobs []jobs.Job `json:"jobs"`
+}
+
+// JobEventsStopRequest is the data of a jobs:events:stop operation, the id of the
+// jobs:events request to stop
+type JobEventsStopRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// registerJobOperations registers the job operations
+func (s *Server) registerJobOperations() {
+	s.registerOperation("jobs:events", s.jobEvents, operationSpec{
+		description: "List the background jobs, then push every job when it starts, makes progress and finishes",
+		request:     JobEventsRequest{},
+		response:    JobEventsResponse{},
+		events:      []eventSpec{{operation: "jobs:events", withRequestID: true, data: jobs.Job{}}},
+	})
+	s.registerOperation("jobs:events:stop", s.jobEventsStop, operationSpec{
+		description: "Stop following the background jobs",
+		request:     JobEventsStopRequest{},
+		response:    JobEventsStopRequest{},
+	})
+}
+
+// jobEventsKey is the cleanup key of the job events followed by a request
+func jobEventsKey(id string) string {
+	return "jobs:events:" + id
+}
+
+// jobEvents streams the updates of the background jobs. After the first response
+// listing the followed jobs, every update is sent as a jobs:events response with the id
+// of the request, until it is stopped with jobs:events:stop or the connection closes.
+func (s *Server) jobEvents(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req JobEventsRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if request.ID == "" {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "id is required to receive job events")
+	}
+	key := jobEventsKey(request.ID)
+	if conn.hasCleanup(key) {
+		return nil, apierror.Newf(apierror.CodeConflict, "job events are already followed for id %s", request.ID)
+	}
+
+	manager := jobs.GetManager()
+	followed := func(job jobs.Job) bool {
+		return (req.Type == "" || job.Type == req.Type) && (req.JobID == "" || job.ID == req.JobID)
+	}
+	// Subscribing first, so that no update is missed between the listing and the events
+	stop := manager.Subscribe(func(job jobs.Job) {
+		if !followed(job) {
+			return
+		}
+		conn.Send(Response{
+			ID:        request.ID,
+			Operation: request.Operation,
+			Success:   true,
+			Data:      job,
+		})
+	})
+	conn.AddCleanup(key, stop)
+
+	response := JobEventsResponse{Jobs: []jobs.Job{}}
+	for _, job := range manager.List(req.Type) {
+		if followed(job) {
+			response.Jobs = append(response.Jobs, job)
+		}
+	}
+	return response, nil
+}
+
+// jobEventsStop stops following the job events of a request of the connection
+func (s *Server) jobEventsStop(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req JobEventsStopRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if !conn.RemoveCleanup(jobEventsKey(req.ID)) {
+		return nil, apierror.Newf(apierror.CodeNotFound, "job events %s not found", req.ID)
+	}
+	return req, nil
+}
@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
+)
+
+// MultipartInitiateRequest is the data of a filesystem:multipart:initiate operation
+type MultipartInitiateRequest struct {
+	Path        string `json:"path" binding:"required"`
+	Permissions string `json:"permissions"`
+}
+
+// MultipartUploadPartRequest is the data of a filesystem:multipart:uploadPart
+// operation, followed by a binary message with the content of the part. Offset
+// continues a partial part from the bytes already received.
+type MultipartUploadPartRequest struct {
+	UploadID   string `json:"uploadId" binding:"required"`
+	PartNumber int    `json:"partNumber"`
+	Offset     int64  `json:"offset" binding:"gte=0"`
+}
+
+// MultipartCompleteRequest is the data of a filesystem:multipart:complete operation
+type MultipartCompleteRequest struct {
+	UploadID string                      `json:"uploadId" binding:"required"`
+	Parts    []handler.MultipartPartInfo `json:"parts"`
+	Checksum string                      `json:"checksum"`
+	RunAs    string                      `json:"runAs"`
+}
+
+// MultipartCompleteResponse is the result of a filesystem:multipart:complete operation
+type MultipartCompleteResponse struct {
+	UploadID string `json:"uploadId"`
+	Path     string `json:"path"`
+}
+
+// MultipartAbortRequest is the data of a filesystem:multipart:abort operation
+type MultipartAbortRequest struct {
+	UploadID string `json:"uploadId" binding:"required"`
+}
+
+// registerMultipartOperations registers the multipart upload operations, for clients
+// which do not use the REST API
+func (s *Server) registerMultipartOperations() {
+	s.registerOperation("filesystem:multipart:initiate", s.multipartInitiate, operationSpec{
+		description: "Start a multipart upload",
+		request:     MultipartInitiateRequest{},
+		response:    handler.MultipartInitiateResponse{},
+	})
+	s.registerBinaryOperation("filesystem:multipart:uploadPart", s.multipartUploadPart, operationSpec{
+		description: "Upload a part of a multipart upload, sent as the following binary message",
+		request:     MultipartUploadPartRequest{},
+		response:    filesystem.UploadedPart{},
+	})
+	s.registerOperation("filesystem:multipart:complete", s.multipartComplete, operationSpec{
+		description: "Assemble the parts of a multipart upload into its file",
+		request:     MultipartCompleteRequest{},
+		response:    MultipartCompleteResponse{},
+	})
+	s.registerOperation("filesystem:multipart:abort", s.multipartAbort, operationSpec{
+		description: "Cancel a multipart upload",
+		request:     MultipartAbortRequest{},
+		response:    MultipartAbortRequest{},
+	})
+}
+
+// multipartInitiate starts a multipart upload
+func (s *Server) multipartInitiate(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req MultipartInitiateRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+
+	upload, err := s.handlers.FileSystem.InitiateMultipartUpload(req.Path, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	return handler.MultipartInitiateResponse{UploadID: upload.UploadID, Path: upload.Path}, nil
+}
+
+// multipartUploadPart writes a part of a multipart upload, its content being the
+// binary message following the request
+func (s *Server) multipartUploadPart(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req MultipartUploadPartRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+
+	return s.handlers.FileSystem.UploadMultipartPart(req.UploadID, req.PartNumber, req.Offset, bytes.NewReader(request.Binary))
+}
+
+// multipartComplete assembles the parts of a multipart upload into its file
+func (s *Server) multipartComplete(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req MultipartCompleteRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+
+	path, err := s.handlers.FileSystem.CompleteMultipartUpload(req.UploadID, req.Parts, req.Checksum, req.RunAs)
+	if err != nil {
+		return nil, err
+	}
+	return MultipartCompleteResponse{UploadID: req.UploadID, Path: path}, nil
+}
+
+// multipartAbort cancels a multipart upload
+func (s *Server) multipartAbort(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req MultipartAbortRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if err := s.handlers.FileSystem.AbortMultipartUpload(req.UploadID); err != nil {
+		return nil, err
+	}
+	return MultipartAbortRequest{UploadID: req.UploadID}, nil
+}
@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+)
+
+// roundTrip sends a request, followed by a binary message when binary is not nil, and
+// reads its response
+func roundTrip(t *testing.T, conn *websocket.Conn, req Request, data interface{}, binary []byte) Response {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Data = raw
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if binary != nil {
+		if err := conn.WriteMessage(websocket.BinaryMessage, binary); err != nil {
+			t.Fatalf("Failed to send binary message: %v", err)
+		}
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var resp Response
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp
+}
+
+// TestMultipartUploadOverWebSocket tests uploading a file with parts sent as binary
+// messages
+func TestMultipartUploadOverWebSocket(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 1})
+	target := filepath.Join(t.TempDir(), "out.txt")
+
+	resp := roundTrip(t, conn, Request{ID: "1", Operation: "filesystem:multipart:initiate"}, MultipartInitiateRequest{Path: target}, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to initiate upload: %+v", resp)
+	}
+	uploadID := resp.Data.(map[string]interface{})["uploadId"].(string)
+
+	var parts []map[string]interface{}
+	for i, content := range []string{"hello ", "world"} {
+		resp = roundTrip(t, conn, Request{ID: "2", Operation: "filesystem:multipart:uploadPart"}, MultipartUploadPartRequest{UploadID: uploadID, PartNumber: i + 1}, []byte(content))
+		if !resp.Success {
+			t.Fatalf("Failed to upload part %d: %+v", i+1, resp)
+		}
+		parts = append(parts, resp.Data.(map[string]interface{}))
+	}
+
+	complete := map[string]interface{}{"uploadId": uploadID, "parts": parts}
+	resp = roundTrip(t, conn, Request{ID: "3", Operation: "filesystem:multipart:complete"}, complete, nil)
+	if !resp.Success {
+		t.Fatalf("Failed to complete upload: %+v", resp)
+	}
+	if content, err := os.ReadFile(target); err != nil || string(content) != "hello world" {
+		t.Errorf("Unexpected assembled file %q (%v)", content, err)
+	}
+}
+
+// TestMissingBinaryMessage tests that a request following a binary operation instead
+// of its binary message is still run
+func TestMissingBinaryMessage(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 1})
+
+	data, _ := json.Marshal(MultipartUploadPartRequest{UploadID: "missing", PartNumber: 1})
+	for _, req := range []Request{{ID: "1", Operation: "filesystem:multipart:uploadPart", Data: data}, {ID: "2", Operation: "test:fast"}} {
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var resp Response
+	if err := conn.ReadJSON(&resp); err != nil || resp.ID != "1" || resp.Success || resp.Code != apierror.CodeInvalidRequest {
+		t.Errorf("Expected the binary operation to fail, got %+v (%v)", resp, err)
+	}
+	resp = Response{}
+	if err := conn.ReadJSON(&resp); err != nil || resp.ID != "2" || resp.Data != "fast" {
+		t.Errorf("Expected the next request to run, got %+v (%v)", resp, err)
+	}
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	resp = Response{}
+	if err := conn.ReadJSON(&resp); err != nil || resp.Success || resp.Code != apierror.CodeInvalidRequest {
+		t.Errorf("Expected an unexpected binary message to fail, got %+v (%v)", resp, err)
+	}
+}
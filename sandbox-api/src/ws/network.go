@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/network"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
+)
+
+// PortsMonitorRequest is the data of a network:ports:monitor operation
+type PortsMonitorRequest struct {
+	PID int `json:"pid" binding:"required,gt=0"`
+}
+
+// PortsMonitorResponse is the first response of a network:ports:monitor operation,
+// with the ports open when the monitoring started
+type PortsMonitorResponse struct {
+	PID   int                 `json:"pid"`
+	Ports []*network.PortInfo `json:"ports"`
+}
+
+// PortsMonitorStopRequest is the data of a network:ports:monitor:stop operation,
+// the id of the network:ports:monitor request to stop
+type PortsMonitorStopRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// registerNetworkOperations registers the network operations
+func (s *Server) registerNetworkOperations() {
+	s.registerOperation("network:ports:monitor", s.portsMonitor, operationSpec{
+		description: "List the ports open by a process, then push the ports it opens and closes",
+		request:     PortsMonitorRequest{},
+		response:    PortsMonitorResponse{},
+		events:      []eventSpec{{operation: "network:ports:monitor", withRequestID: true, data: handler.PortEvent{}}},
+	})
+	s.registerOperation("network:ports:monitor:stop", s.portsMonitorStop, operationSpec{
+		description: "Stop a port monitor",
+		request:     PortsMonitorStopRequest{},
+		response:    PortsMonitorStopRequest{},
+	})
+}
+
+// portsMonitorKey is the cleanup key of the port monitor started by a request
+func portsMonitorKey(id string) string {
+	return "network:ports:monitor:" + id
+}
+
+// portsMonitor streams the ports opened and closed by a process. After the first
+// response listing the open ports, every port-open and port-close event is sent as a
+// network:ports:monitor response with the id of the request, until the monitor is
+// stopped with network:ports:monitor:stop or the connection closes. Unlike the HTTP
+// callback of /network/process/{pid}/monitor, this works for clients behind NAT.
+func (s *Server) portsMonitor(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req PortsMonitorRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if request.ID == "" {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "id is required to receive port events")
+	}
+	key := portsMonitorKey(request.ID)
+	if conn.hasCleanup(key) {
+		return nil, apierror.Newf(apierror.CodeConflict, "a port monitor is already running for id %s", request.ID)
+	}
+
+	// Listing the ports first caches them, so that only changes are sent as events
+	ports, err := s.handlers.Network.GetPortsForPID(req.PID)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := s.handlers.Network.SubscribePortEvents(req.PID, func(event handler.PortEvent) {
+		conn.Send(Response{
+			ID:        request.ID,
+			Operation: request.Operation,
+			Success:   true,
+			Data:      event,
+		})
+	})
+	conn.AddCleanup(key, stop)
+
+	return PortsMonitorResponse{PID: req.PID, Ports: ports}, nil
+}
+
+// portsMonitorStop stops a port monitor of the connection
+func (s *Server) portsMonitorStop(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req PortsMonitorStopRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if !conn.RemoveCleanup(portsMonitorKey(req.ID)) {
+		return nil, apierror.Newf(apierror.CodeNotFound, "port monitor %s not found", req.ID)
+	}
+	return req, nil
+}
@@ -0,0 +1,94 @@
+package ws
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxConcurrency is the number of operations run at once on a connection
+	// when WS_MAX_CONCURRENCY is unset
+	DefaultMaxConcurrency = 8
+	// DefaultOperationTimeout bounds the time an operation runs when
+	// WS_OPERATION_TIMEOUT is unset
+	DefaultOperationTimeout = 5 * time.Minute
+	// maxQueuedOperations is the number of operations waiting for a worker above which
+	// new requests are rejected
+	maxQueuedOperations = 256
+)
+
+// PoolConfig configures the workers running the operations of each connection
+type PoolConfig struct {
+	// MaxConcurrency is the number of operations run at once on a connection
+	MaxConcurrency int
+	// OperationTimeout bounds the time an operation runs, zero for no limit
+	OperationTimeout time.Duration
+}
+
+// PoolConfigFromEnv returns the worker configuration read from WS_MAX_CONCURRENCY and
+// WS_OPERATION_TIMEOUT (in seconds, zero disables the timeout)
+func PoolConfigFromEnv() PoolConfig {
+	config := PoolConfig{
+		MaxConcurrency:   DefaultMaxConcurrency,
+		OperationTimeout: DefaultOperationTimeout,
+	}
+
+	if value := os.Getenv("WS_MAX_CONCURRENCY"); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency <= 0 {
+			logrus.Warnf("Invalid WS_MAX_CONCURRENCY value '%s', using default of %d operations", value, DefaultMaxConcurrency)
+		} else {
+			config.MaxConcurrency = concurrency
+		}
+	}
+	if value := os.Getenv("WS_OPERATION_TIMEOUT"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			logrus.Warnf("Invalid WS_OPERATION_TIMEOUT value '%s', using default of %s", value, DefaultOperationTimeout)
+		} else {
+			config.OperationTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+	return config
+}
+
+// startWorkers starts the workers running the requests queued on the connection, at
+// most n at once. Requests still queued when the connection closes are dropped.
+func (c *Connection) startWorkers(n int, run func(req Request)) {
+	c.requests = make(chan Request, maxQueuedOperations)
+	for i := 0; i < n; i++ {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			for req := range c.requests {
+				if c.ctx.Err() != nil {
+					continue
+				}
+				run(req)
+			}
+		}()
+	}
+}
+
+// enqueue queues a request for the workers. It returns false when too many requests
+// are already waiting.
+func (c *Connection) enqueue(req Request) bool {
+	select {
+	case c.requests <- req:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopWorkers stops accepting requests and waits for the running ones to return
+func (c *Connection) stopWorkers() {
+	if c.requests == nil {
+		return
+	}
+	close(c.requests)
+	c.workers.Wait()
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// dialTestServer starts a WebSocket server with a slow and a fast operation, and
+// connects to it
+func dialTestServer(t *testing.T, pool PoolConfig) *websocket.Conn {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	server := NewServer(engine)
+	server.pool = pool
+	server.registerOperation("test:slow", func(ctx context.Context, conn *Connection, req Request) (interface{}, error) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+		}
+		return "slow", nil
+	}, operationSpec{description: "Test operation answering after 2s"})
+	server.registerOperation("test:fast", func(ctx context.Context, conn *Connection, req Request) (interface{}, error) {
+		return "fast", nil
+	}, operationSpec{description: "Test operation answering at once"})
+
+	httpServer := httptest.NewServer(engine)
+	t.Cleanup(httpServer.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// TestConcurrentOperations tests that a slow operation does not hold up the next ones
+func TestConcurrentOperations(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 4})
+
+	for _, req := range []Request{{ID: "1", Operation: "test:slow"}, {ID: "2", Operation: "test:fast"}} {
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var resp Response
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if resp.ID != "2" || resp.Data != "fast" {
+		t.Errorf("Expected the fast operation to answer first, got %+v", resp)
+	}
+}
+
+// TestOperationTimeout tests that operations running past the timeout are answered
+// with an error
+func TestOperationTimeout(t *testing.T) {
+	conn := dialTestServer(t, PoolConfig{MaxConcurrency: 1, OperationTimeout: 100 * time.Millisecond})
+
+	if err := conn.WriteJSON(Request{ID: "1", Operation: "test:slow"}); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var resp Response
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if resp.ID != "1" || resp.Success || !strings.Contains(resp.Error, "timed out") {
+		t.Errorf("Expected a timeout error, got %+v", resp)
+	}
+}
@@ -0,0 +1,192 @@
+package ws
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/process"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/streamlimit"
+	"github.com/blaxel-ai/sandbox-api/src/lib/validation"
+)
+
+// LogsStreamStartRequest is the data of a process:logs:stream:start operation. To
+// resume a stream after a disconnection, LastSeq is set to the seq of the last output
+// received. Overflow is what to do when output is dropped from the log buffer before
+// the client received it: drop-oldest reports it as missed, disconnect ends the stream
+// with a STREAM_OVERFLOW error.
+type LogsStreamStartRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+	Stream     string `json:"stream" binding:"omitempty,oneof=stdout stderr"` // stdout or stderr, empty for the combined output
+	LastSeq    *int64 `json:"lastSeq" binding:"omitempty,gte=0"`
+	Overflow   string `json:"overflow" binding:"omitempty,oneof=drop-oldest disconnect"`
+}
+
+// LogsStreamStartResponse is the first response of a process:logs:stream:start
+// operation
+type LogsStreamStartResponse struct {
+	Identifier string `json:"identifier"`
+	PID        string `json:"pid"`
+}
+
+// LogsStreamEvent is output of a process pushed for a process:logs:stream:start
+// operation, written to a single stream. Seq is the number of bytes written to the
+// stream up to the end of Logs, it increases with each event. Missed counts the bytes
+// dropped from the log buffer before they could be sent, and Done is set on the last
+// event, once the process has terminated.
+type LogsStreamEvent struct {
+	Logs string `json:"logs"`
+	// Stream is stdout or stderr, empty for the messages of the API and the last event
+	Stream string `json:"stream,omitempty"`
+	// Ts is when the output was written, or when the event was sent for the last event
+	Ts     time.Time `json:"ts"`
+	Seq    int64     `json:"seq"`
+	Missed int64     `json:"missed,omitempty"`
+	Done   bool      `json:"done,omitempty"`
+}
+
+// LogsStreamStopRequest is the data of a process:logs:stream:stop operation, the id
+// of the process:logs:stream:start request to stop
+type LogsStreamStopRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// registerProcessOperations registers the process operations
+func (s *Server) registerProcessOperations() {
+	s.registerOperation("process:logs:stream:start", s.logsStreamStart, operationSpec{
+		description: "Push the output of a process as it is written, until it terminates or the stream is stopped",
+		request:     LogsStreamStartRequest{},
+		response:    LogsStreamStartResponse{},
+		events:      []eventSpec{{operation: "process:logs:stream:start", withRequestID: true, data: LogsStreamEvent{}}},
+	})
+	s.registerOperation("process:logs:stream:stop", s.logsStreamStop, operationSpec{
+		description: "Stop a log stream",
+		request:     LogsStreamStopRequest{},
+		response:    LogsStreamStopRequest{},
+	})
+	s.registerOperation("process:bulk", s.processBulk, operationSpec{
+		description: "Stop, kill or delete several processes",
+		request:     handler.ProcessBulkRequest{},
+		response:    handler.ProcessBulkResponse{},
+	})
+}
+
+// logsStreamKey is the cleanup key of the log stream started by a request
+func logsStreamKey(id string) string {
+	return "process:logs:stream:" + id
+}
+
+// logsStreamStart streams the output of a process. The buffered output and then every
+// new output are sent as process:logs:stream:start responses with the id of the
+// request, until the process terminates, the stream is stopped with
+// process:logs:stream:stop or the connection closes. A stream resumed with lastSeq
+// only sends the output written after it.
+func (s *Server) logsStreamStart(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req LogsStreamStartRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if request.ID == "" {
+		return nil, apierror.Newf(apierror.CodeInvalidRequest, "id is required to receive logs")
+	}
+	policy, err := process.ParseOverflowPolicy(req.Overflow)
+	if err != nil {
+		return nil, err
+	}
+	key := logsStreamKey(request.ID)
+	if conn.hasCleanup(key) {
+		return nil, apierror.Newf(apierror.CodeConflict, "a log stream is already running for id %s", request.ID)
+	}
+
+	processInfo, err := s.handlers.Process.GetProcess(req.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	from := int64(-1)
+	if req.LastSeq != nil {
+		from = *req.LastSeq
+	}
+
+	release, err := streamlimit.LogStreams().Acquire(conn.remoteAddr())
+	if err != nil {
+		return nil, err
+	}
+	metrics.ActiveLogStreams.Inc()
+	closed := func() {
+		release()
+		metrics.ActiveLogStreams.Dec()
+	}
+
+	// The stream of a terminated process may end before it is registered
+	var mu sync.Mutex
+	ended := false
+	stop, err := s.handlers.Process.FollowProcessOutput(req.Identifier, req.Stream, from, func(chunk process.OutputChunk) {
+		mu.Lock()
+		if ended {
+			mu.Unlock()
+			return
+		}
+		ended = chunk.Done || (chunk.Missed > 0 && policy == process.OverflowDisconnect)
+		mu.Unlock()
+
+		if !chunk.Done && ended {
+			conn.Send(errorResponse(request, apierror.Newf(apierror.CodeStreamOverflow, "%d bytes of output were dropped before they could be sent", chunk.Missed)))
+		} else {
+			ts := chunk.At
+			if ts.IsZero() {
+				ts = time.Now()
+			}
+			conn.Send(Response{
+				ID:        request.ID,
+				Operation: request.Operation,
+				Success:   true,
+				Data:      LogsStreamEvent{Logs: chunk.Logs, Stream: chunk.Stream, Ts: ts, Seq: chunk.NextOffset, Missed: chunk.Missed, Done: chunk.Done},
+			})
+		}
+		if ended {
+			conn.RemoveCleanup(key)
+		}
+	})
+	if err != nil {
+		closed()
+		return nil, err
+	}
+	mu.Lock()
+	if ended {
+		stop()
+		closed()
+	} else {
+		conn.AddCleanup(key, func() {
+			stop()
+			closed()
+		})
+	}
+	mu.Unlock()
+
+	return LogsStreamStartResponse{Identifier: req.Identifier, PID: processInfo.PID}, nil
+}
+
+// logsStreamStop stops a log stream of the connection
+func (s *Server) logsStreamStop(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req LogsStreamStopRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	if !conn.RemoveCleanup(logsStreamKey(req.ID)) {
+		return nil, apierror.Newf(apierror.CodeNotFound, "log stream %s not found", req.ID)
+	}
+	return req, nil
+}
+
+// processBulk stops, kills or deletes several processes, like POST /process/bulk
+func (s *Server) processBulk(ctx context.Context, conn *Connection, request Request) (interface{}, error) {
+	var req handler.ProcessBulkRequest
+	if err := validation.Decode(request.Data, &req); err != nil {
+		return nil, err
+	}
+	return s.handlers.Process.BulkProcesses(req)
+}
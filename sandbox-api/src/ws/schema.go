@@ -0,0 +1,248 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler/filesystem"
+)
+
+// operationSpec describes an operation for the schema of the protocol. Request and
+// response are values of the types of the data of the request and of the response.
+type operationSpec struct {
+	description string
+	request     interface{}
+	response    interface{}
+	events      []eventSpec
+}
+
+// eventSpec describes the messages an operation pushes after its response
+type eventSpec struct {
+	operation string
+	// withRequestID is set for messages sent with the id of the request, the others
+	// being identified by their data, like the subscription of a watch
+	withRequestID bool
+	data          interface{}
+}
+
+// JSONSchema is the subset of JSON Schema describing the messages of the protocol
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+// Schema describes the protocol of the /ws endpoint: the envelopes of the messages and
+// the data of each operation, with the types they reference defined in Definitions
+type Schema struct {
+	Request     *JSONSchema            `json:"request"`
+	Response    *JSONSchema            `json:"response"`
+	Operations  []OperationSchema      `json:"operations"`
+	Definitions map[string]*JSONSchema `json:"$defs"`
+}
+
+// OperationSchema describes an operation. Binary is set for the operations whose
+// request is followed by a binary message.
+type OperationSchema struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Binary      bool          `json:"binary,omitempty"`
+	Request     *JSONSchema   `json:"request,omitempty"`
+	Response    *JSONSchema   `json:"response,omitempty"`
+	Events      []EventSchema `json:"events,omitempty"`
+}
+
+// EventSchema describes the messages pushed by an operation after its response. They
+// are sent with the id of the request when WithRequestID is set.
+type EventSchema struct {
+	Operation     string      `json:"operation"`
+	WithRequestID bool        `json:"withRequestId"`
+	Data          *JSONSchema `json:"data"`
+}
+
+// substituteTypes are the types encoded with a MarshalJSON method, described by the
+// types they are encoded as
+var substituteTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(filesystem.FileByte{}):            reflect.TypeOf(filesystem.File{}),
+	reflect.TypeOf(filesystem.FileWithContentByte{}): reflect.TypeOf(filesystem.FileWithContent{}),
+}
+
+// HandleSchema serves the schema of the protocol, for SDK generators
+func (s *Server) HandleSchema(c *gin.Context) {
+	s.schemaOnce.Do(func() {
+		s.schema = s.buildSchema()
+	})
+	c.JSON(http.StatusOK, s.schema)
+}
+
+// buildSchema generates the schema of the registered operations
+func (s *Server) buildSchema() *Schema {
+	builder := &schemaBuilder{definitions: make(map[string]*JSONSchema), names: make(map[reflect.Type]string)}
+	schema := &Schema{
+		Request:    builder.schemaOf(Request{}),
+		Response:   builder.schemaOf(Response{}),
+		Operations: make([]OperationSchema, 0, len(s.specs)),
+	}
+	for name, spec := range s.specs {
+		operation := OperationSchema{
+			Name:        name,
+			Description: spec.description,
+			Binary:      s.binary[name],
+			Request:     builder.schemaOf(spec.request),
+			Response:    builder.schemaOf(spec.response),
+		}
+		for _, event := range spec.events {
+			operation.Events = append(operation.Events, EventSchema{
+				Operation:     event.operation,
+				WithRequestID: event.withRequestID,
+				Data:          builder.schemaOf(event.data),
+			})
+		}
+		schema.Operations = append(schema.Operations, operation)
+	}
+	sort.Slice(schema.Operations, func(i, j int) bool {
+		return schema.Operations[i].Name < schema.Operations[j].Name
+	})
+	schema.Definitions = builder.definitions
+	return schema
+}
+
+// schemaBuilder generates the JSON schemas of Go types from their JSON encoding. Named
+// structs are defined once and referenced.
+type schemaBuilder struct {
+	definitions map[string]*JSONSchema
+	names       map[reflect.Type]string
+}
+
+// schemaOf returns the schema of the type of a value, nil without a value
+func (b *schemaBuilder) schemaOf(value interface{}) *JSONSchema {
+	if value == nil {
+		return nil
+	}
+	return b.schemaFor(reflect.TypeOf(value))
+}
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if substitute, ok := substituteTypes[t]; ok {
+		t = substitute
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &JSONSchema{}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &JSONSchema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return &JSONSchema{Ref: "#/$defs/" + b.define(t)}
+	default:
+		// Interfaces hold any value
+		return &JSONSchema{}
+	}
+}
+
+// define defines a named struct, under the name of its type or, when another package
+// has a type of the same name, prefixed with its package
+func (b *schemaBuilder) define(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := b.definitions[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	b.names[t] = name
+	// Defined before its fields so that recursive types reference it
+	b.definitions[name] = &JSONSchema{}
+	*b.definitions[name] = *b.structSchema(t)
+	return name
+}
+
+// structSchema returns the schema of the JSON object of a struct, with the rules of
+// the binding tags its requests are validated with: required fields, and the values of
+// a field listed by oneof or enums.
+func (b *schemaBuilder) structSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	b.addFields(schema, t)
+	return schema
+}
+
+func (b *schemaBuilder) addFields(schema *JSONSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			// The fields of embedded structs are promoted
+			if substitute, ok := substituteTypes[fieldType]; ok {
+				fieldType = substitute
+			}
+			b.addFields(schema, fieldType)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schemaFor(field.Type)
+		if enums := field.Tag.Get("enums"); enums != "" && property.Ref == "" {
+			property.Enum = strings.Split(enums, ",")
+		}
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			switch {
+			case rule == "required":
+				schema.Required = append(schema.Required, name)
+			case strings.HasPrefix(rule, "oneof=") && property.Ref == "":
+				property.Enum = strings.Fields(strings.TrimPrefix(rule, "oneof="))
+			}
+		}
+		schema.Properties[name] = property
+	}
+}
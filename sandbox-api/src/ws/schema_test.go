@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestSchema tests that /ws/schema describes every operation, with the references of
+// their data defined
+func TestSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	server := NewServer(engine)
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ws/schema", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	var schema Schema
+	if err := json.Unmarshal(recorder.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("Failed to decode schema: %v", err)
+	}
+
+	if len(schema.Operations) != len(server.operations) {
+		t.Errorf("Expected %d operations, got %d", len(server.operations), len(schema.Operations))
+	}
+	operations := make(map[string]OperationSchema)
+	for _, operation := range schema.Operations {
+		if operation.Description == "" || operation.Request == nil || operation.Response == nil {
+			t.Errorf("Operation %s is not fully described: %+v", operation.Name, operation)
+		}
+		operations[operation.Name] = operation
+	}
+
+	// Every reference is defined
+	var check func(where string, s *JSONSchema)
+	check = func(where string, s *JSONSchema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			if _, ok := schema.Definitions[strings.TrimPrefix(s.Ref, "#/$defs/")]; !ok {
+				t.Errorf("%s references undefined %s", where, s.Ref)
+			}
+		}
+		for _, property := range s.Properties {
+			check(where, property)
+		}
+		check(where, s.Items)
+		check(where, s.AdditionalProperties)
+	}
+	for name, definition := range schema.Definitions {
+		check(name, definition)
+	}
+
+	read := schema.Definitions["FileReadRequest"]
+	if read == nil || len(read.Required) != 1 || read.Required[0] != "path" {
+		t.Errorf("Expected path to be required to read a file, got %+v", read)
+	}
+	// Files are described by their JSON encoding
+	file := schema.Definitions["FileWithContent"]
+	if file == nil || file.Properties["content"].Type != "string" || file.Properties["permissions"].Type != "string" {
+		t.Errorf("Expected the content and permissions of files as strings, got %+v", file)
+	}
+	if operations["filesystem:read"].Response.Ref != "#/$defs/FileWithContent" {
+		t.Errorf("Expected filesystem:read to return a FileWithContent, got %+v", operations["filesystem:read"].Response)
+	}
+
+	watch := operations["filesystem:watch:start"]
+	if len(watch.Events) != 2 || watch.Events[0].Operation != "filesystem:watch:event" || watch.Events[0].WithRequestID {
+		t.Errorf("Expected the watch events without the request id, got %+v", watch.Events)
+	}
+	event := schema.Definitions["WatchEvent"]
+	if event == nil || event.Properties["subscriptionId"] == nil || event.Properties["path"] == nil {
+		t.Errorf("Expected the fields of the file event promoted in WatchEvent, got %+v", event)
+	}
+	if !operations["filesystem:multipart:uploadPart"].Binary {
+		t.Errorf("Expected filesystem:multipart:uploadPart to be binary")
+	}
+}
@@ -0,0 +1,436 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/blaxel-ai/sandbox-api/src/handler"
+	"github.com/blaxel-ai/sandbox-api/src/handler/audit"
+	"github.com/blaxel-ai/sandbox-api/src/lib/activity"
+	"github.com/blaxel-ai/sandbox-api/src/lib/apierror"
+	"github.com/blaxel-ai/sandbox-api/src/lib/cors"
+	"github.com/blaxel-ai/sandbox-api/src/lib/logging"
+	"github.com/blaxel-ai/sandbox-api/src/lib/metrics"
+	"github.com/blaxel-ai/sandbox-api/src/lib/tracing"
+)
+
+const (
+	// pingInterval is the interval between keepalive pings sent to clients
+	pingInterval = 30 * time.Second
+	// pongTimeout is how long a connection stays open without hearing back from the client
+	pongTimeout = 2 * pingInterval
+	// writeTimeout bounds the time spent writing a single message
+	writeTimeout = 10 * time.Second
+)
+
+// Request is a message sent by the client to run an operation. The id is echoed back
+// in the response so clients can match responses to requests.
+type Request struct {
+	ID        string          `json:"id"`
+	Operation string          `json:"operation"`
+	Data      json.RawMessage `json:"data"`
+	// Binary is the body of operations registered with registerBinaryOperation, sent
+	// by the client as a binary message right after the request
+	Binary []byte `json:"-"`
+}
+
+// Response is the result of an operation, or an event pushed by the server when it
+// has no id
+type Response struct {
+	ID        string      `json:"id,omitempty"`
+	Operation string      `json:"operation"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	// Code and Details are set with Error, see apierror.Body
+	Code    apierror.Code  `json:"code,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+	// Chunk is set when the data is too large for a single message, see Chunk
+	Chunk *Chunk `json:"chunk,omitempty"`
+}
+
+// OperationFunc runs the operation of a request for a connection. Streaming operations
+// keep sending responses with the id of the request after returning.
+type OperationFunc func(ctx context.Context, conn *Connection, req Request) (interface{}, error)
+
+// Server serves the /ws endpoint, which runs operations over a single WebSocket connection
+type Server struct {
+	handlers   *Handlers
+	operations map[string]OperationFunc
+	binary     map[string]bool
+	specs      map[string]operationSpec
+	schema     *Schema
+	schemaOnce sync.Once
+	upgrader   websocket.Upgrader
+	engine     *gin.Engine
+	audit      *audit.Logger
+	chunkSize  int
+	pool       PoolConfig
+}
+
+// Handlers contains all the handlers used by the WebSocket server
+type Handlers struct {
+	Codegen    *handler.CodegenHandler
+	FileSystem *handler.FileSystemHandler
+	Network    *handler.NetworkHandler
+	Process    *handler.ProcessHandler
+}
+
+// NewServer creates the WebSocket server and registers its endpoint on the engine
+func NewServer(ginEngine *gin.Engine) *Server {
+	fsHandler := handler.NewFileSystemHandler()
+	server := &Server{
+		handlers: &Handlers{
+			Codegen:    handler.NewCodegenHandler(fsHandler),
+			FileSystem: fsHandler,
+			Network:    handler.NewNetworkHandler(),
+			Process:    handler.NewProcessHandler(),
+		},
+		operations: make(map[string]OperationFunc),
+		binary:     make(map[string]bool),
+		specs:      make(map[string]operationSpec),
+		upgrader: websocket.Upgrader{
+			// Same allowed origins as the CORS middleware of the REST API
+			CheckOrigin: cors.GetPolicy().CheckOrigin,
+			// Negotiate permessage-deflate with clients supporting it
+			EnableCompression: true,
+		},
+		engine:    ginEngine,
+		audit:     audit.GetLogger(),
+		chunkSize: ChunkSizeFromEnv(),
+		pool:      PoolConfigFromEnv(),
+	}
+
+	server.registerCodegenOperations()
+	server.registerFileSystemOperations()
+	server.registerMultipartOperations()
+	server.registerJobOperations()
+	server.registerNetworkOperations()
+	server.registerProcessOperations()
+
+	ginEngine.GET("/ws", server.HandleWebSocket)
+	ginEngine.GET("/ws/schema", server.HandleSchema)
+	logrus.Info("WebSocket endpoint configured at /ws")
+
+	return server
+}
+
+// registerOperation registers the function run for an operation name, and its
+// description served in the schema of the protocol
+func (s *Server) registerOperation(name string, fn OperationFunc, spec operationSpec) {
+	s.operations[name] = fn
+	s.specs[name] = spec
+}
+
+// registerBinaryOperation registers the function run for an operation whose request
+// is followed by a binary message, see Request.Binary
+func (s *Server) registerBinaryOperation(name string, fn OperationFunc, spec operationSpec) {
+	s.registerOperation(name, fn, spec)
+	s.binary[name] = true
+}
+
+// HandleWebSocket upgrades the request and serves operations until the client disconnects
+func (s *Server) HandleWebSocket(c *gin.Context) {
+	wsConn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already replied with an error status
+		logging.FromContext(c.Request.Context()).Errorf("Failed to upgrade WebSocket connection: %v", err)
+		return
+	}
+
+	// Operations are logged with the ID of the upgrade request
+	requestID := logging.RequestID(c.Request.Context())
+	conn := newConnection(wsConn, audit.NewCaller(c.ClientIP(), c.Request.Header), requestID, s.chunkSize)
+	metrics.ActiveWebSocketConnections.Inc()
+	defer metrics.ActiveWebSocketConnections.Dec()
+	defer conn.close()
+
+	go conn.keepalive()
+
+	// Operations run concurrently so that a slow one does not hold up the others, and
+	// their responses are matched to requests by id
+	conn.startWorkers(s.pool.MaxConcurrency, func(req Request) {
+		s.dispatch(conn, req)
+	})
+
+	// next is a message read while expecting the binary message of a request
+	var next []byte
+	for {
+		message := next
+		next = nil
+		if message == nil {
+			messageType, data, err := conn.readMessage()
+			if err != nil {
+				return
+			}
+			if messageType == websocket.BinaryMessage {
+				conn.Send(errorResponse(Request{}, apierror.New(apierror.CodeInvalidRequest, "unexpected binary message")))
+				continue
+			}
+			message = data
+		}
+
+		var req Request
+		if err := json.Unmarshal(message, &req); err != nil {
+			logging.FromContext(conn.ctx).Errorf("WebSocket read error: %v", err)
+			return
+		}
+		if s.binary[req.Operation] {
+			messageType, data, err := conn.readMessage()
+			if err != nil {
+				return
+			}
+			if messageType != websocket.BinaryMessage {
+				// The message is handled as the next request
+				next = data
+				conn.Send(errorResponse(req, apierror.Newf(apierror.CodeInvalidRequest, "operation '%s' must be followed by a binary message", req.Operation)))
+				continue
+			}
+			req.Binary = data
+		}
+		if !conn.enqueue(req) {
+			logging.FromContext(conn.ctx).Warnf("Rejected WebSocket operation %s: too many pending operations", req.Operation)
+			conn.Send(errorResponse(req, apierror.New(apierror.CodeTooManyRequests, "too many pending operations, retry later")))
+		}
+	}
+}
+
+// dispatch runs the operation of a request in a span, sends its response, logs it and
+// records it in the audit log and as an activity of the sandbox
+func (s *Server) dispatch(conn *Connection, req Request) {
+	start := time.Now()
+	activity.GetTracker().Touch(activity.SourceAPI)
+	arguments := audit.SanitizeJSON(req.Data)
+
+	ctx := logging.WithField(conn.ctx, logging.FieldWSMessageID, req.ID)
+	attrs := append(tracing.ArgumentAttributes(arguments), tracing.AttrWSOperation.String(req.Operation), tracing.AttrWSMessageID.String(req.ID))
+	ctx, span := tracing.Start(ctx, "ws "+req.Operation, attrs...)
+	resp := s.run(ctx, conn, req)
+	var err error
+	if !resp.Success {
+		err = errors.New(resp.Error)
+	}
+	tracing.End(span, err)
+	conn.Send(resp)
+
+	duration := time.Since(start)
+	entry := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"operation":  req.Operation,
+		"durationMs": duration.Milliseconds(),
+	})
+	if err != nil {
+		entry.Errorf("WebSocket operation failed: %s (duration: %v, error: %v)", req.Operation, duration, err)
+	} else {
+		entry.Infof("WebSocket operation completed: %s (duration: %v)", req.Operation, duration)
+	}
+
+	s.audit.Record(audit.Entry{
+		Timestamp:  start.UTC(),
+		Source:     audit.SourceWebSocket,
+		Operation:  req.Operation,
+		Arguments:  arguments,
+		Caller:     conn.caller,
+		Success:    resp.Success,
+		Error:      resp.Error,
+		DurationMs: duration.Milliseconds(),
+	})
+}
+
+// run runs the operation of a request and returns its response. An operation still
+// running after the operation timeout is answered with an error and left to finish in
+// the background, its context is cancelled.
+func (s *Server) run(ctx context.Context, conn *Connection, req Request) Response {
+	fn, exists := s.operations[req.Operation]
+	if !exists {
+		return errorResponse(req, apierror.Newf(apierror.CodeInvalidRequest, "unknown operation '%s'", req.Operation))
+	}
+
+	if s.pool.OperationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.pool.OperationTimeout)
+		defer cancel()
+	}
+
+	type result struct {
+		data interface{}
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := fn(ctx, conn, req)
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return errorResponse(req, r.err)
+		}
+		return Response{ID: req.ID, Operation: req.Operation, Success: true, Data: r.data}
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errorResponse(req, apierror.Newf(apierror.CodeTimeout, "operation timed out after %s", s.pool.OperationTimeout))
+		}
+		return errorResponse(req, apierror.New(apierror.CodeUnavailable, "connection closed"))
+	}
+}
+
+// errorResponse returns the response of a failed request, with the code of err, or
+// INTERNAL when it has none
+func errorResponse(req Request, err error) Response {
+	body := apierror.NewBody(err, apierror.CodeInternal)
+	return Response{ID: req.ID, Operation: req.Operation, Error: body.Error, Code: body.Code, Details: body.Details}
+}
+
+// Connection is a client connection. Writes are serialized, and cleanup functions
+// registered by operations run when the connection closes.
+type Connection struct {
+	conn      *websocket.Conn
+	caller    audit.Caller
+	ctx       context.Context
+	cancel    context.CancelFunc
+	chunkSize int
+	writeMu   sync.Mutex
+
+	requests chan Request
+	workers  sync.WaitGroup
+
+	mu       sync.Mutex
+	cleanups map[string]func()
+	closed   bool
+}
+
+// newConnection wraps an upgraded WebSocket connection, upgraded by the request with
+// the given ID. Data larger than chunkSize is sent in chunks.
+func newConnection(wsConn *websocket.Conn, caller audit.Caller, requestID string, chunkSize int) *Connection {
+	ctx, cancel := context.WithCancel(logging.WithRequestID(context.Background(), requestID))
+	_ = wsConn.SetReadDeadline(time.Now().Add(pongTimeout))
+	wsConn.SetPongHandler(func(string) error {
+		return wsConn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+	return &Connection{
+		conn:      wsConn,
+		caller:    caller,
+		ctx:       ctx,
+		cancel:    cancel,
+		chunkSize: chunkSize,
+		cleanups:  make(map[string]func()),
+	}
+}
+
+// Send writes a message to the client, split into consecutive chunks when its data is
+// large. Errors are logged, the read loop notices broken connections.
+func (c *Connection) Send(msg Response) {
+	messages, err := splitResponse(msg, c.chunkSize)
+	if err != nil {
+		messages = []Response{{ID: msg.ID, Operation: msg.Operation, Error: fmt.Sprintf("failed to encode response: %v", err), Code: apierror.CodeInternal}}
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	for _, message := range messages {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := c.conn.WriteJSON(message); err != nil {
+			logging.FromContext(c.ctx).Debugf("Failed to write WebSocket message: %v", err)
+			return
+		}
+	}
+}
+
+// remoteAddr returns the address of the client, which identifies the connection for
+// the stream limits
+func (c *Connection) remoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// readMessage reads the next message sent by the client. Errors other than the client
+// closing the connection are logged.
+func (c *Connection) readMessage() (int, []byte, error) {
+	messageType, data, err := c.conn.ReadMessage()
+	if err != nil && websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		logging.FromContext(c.ctx).Errorf("WebSocket read error: %v", err)
+	}
+	return messageType, data, err
+}
+
+// keepalive pings the client until the connection closes
+func (c *Connection) keepalive() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+			c.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// AddCleanup registers a function run when the connection closes or when the key is
+// removed with RemoveCleanup. It runs right away when the connection is already closed.
+func (c *Connection) AddCleanup(key string, cleanup func()) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		cleanup()
+		return
+	}
+	c.cleanups[key] = cleanup
+	c.mu.Unlock()
+}
+
+// hasCleanup reports whether a cleanup function is registered for the key
+func (c *Connection) hasCleanup(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, exists := c.cleanups[key]
+	return exists
+}
+
+// RemoveCleanup runs and unregisters the cleanup function of a key. It returns false
+// if no function is registered for the key.
+func (c *Connection) RemoveCleanup(key string) bool {
+	c.mu.Lock()
+	cleanup, exists := c.cleanups[key]
+	delete(c.cleanups, key)
+	c.mu.Unlock()
+
+	if exists {
+		cleanup()
+	}
+	return exists
+}
+
+// close cancels the running operations and waits for them, then runs all cleanup
+// functions and closes the underlying connection
+func (c *Connection) close() {
+	c.cancel()
+	c.stopWorkers()
+
+	c.mu.Lock()
+	cleanups := c.cleanups
+	c.cleanups = make(map[string]func())
+	c.closed = true
+	c.mu.Unlock()
+
+	for _, cleanup := range cleanups {
+		cleanup()
+	}
+	_ = c.conn.Close()
+}